@@ -6,10 +6,16 @@
 #include <vector>
 
 #include "interactive/commands.hpp"
+#include "server/protocol.hpp"
 #include "server/server.hpp"
 #include "storage/storage_manager.hpp"
 #include "logger.hpp"
 
+// Версия задается при сборке через CMake
+#ifndef OCTET_VERSION
+#define OCTET_VERSION "unknown"
+#endif
+
 // Вывод справки
 void printHelp(const char *executable)
 {
@@ -29,7 +35,8 @@ void printHelp(const char *executable)
         << "  --snapshot-operations=ЧИСЛО    Порог операций до снапшота (по умолчанию: 100)\n"
         << "  --snapshot-minutes=ЧИСЛО       Интервал снапшотов в минутах (по умолчанию: 10)\n"
         << "  --disable-warnings             Отключить вывод текстовых сообщений-предупреждений\n"
-        << "  --help                         Показать справку\n\n"
+        << "  --help                         Показать справку\n"
+        << "  --version                      Показать версию\n\n"
 
         << "Режимы работы:\n"
         << "  По умолчанию octet выполняет однократную команду, если не указаны "
@@ -78,7 +85,9 @@ void printHelp(const char *executable)
         << "    --snapshot-operations=ЧИСЛО  Порог операций до снапшота (по умолчанию: 100)\n"
         << "    --snapshot-minutes=ЧИСЛО     Интервал снапшотов в минутах (по умолчанию: 10)\n"
         << "    --socket=ПУТЬ                Путь к Unix-сокету (по умолчанию: /tmp/octet.sock).\n"
-        << "                                 Сокет не должен существовать.\n\n"
+        << "                                 Сокет не должен существовать.\n"
+        << "    --max-message-size=ЧИСЛО     Максимальный размер сообщения от клиента в байтах\n"
+        << "                                 (по умолчанию: 67108864).\n\n"
 
         << "  Неподдерживаемые опции для выбранного режима будут проигнорированы.\n\n";
 }
@@ -146,6 +155,12 @@ int main(int argc, char *argv[])
         return 0;
     }
 
+    // Проверка на --version
+    if (hasFlag("--version", args)) {
+        std::cout << OCTET_VERSION << std::endl;
+        return 0;
+    }
+
     // Инициализация логгера
     octet::Logger::getInstance().enable(true, std::nullopt, octet::LogLevel::WARNING, true, false);
 
@@ -156,6 +171,7 @@ int main(int argc, char *argv[])
     const auto socketPath = getOptionValue("--socket", args);
     std::optional<size_t> snapshotOpsThreshold;
     std::optional<size_t> snapshotTimeThreshold;
+    size_t maxMessageSize = octet::server::FrameParser::DEFAULT_MAX_MESSAGE_SIZE;
 
     // Получение пути к хранилищу
     const auto storageOption = getOptionValue("--storage", args);
@@ -195,6 +211,21 @@ int main(int argc, char *argv[])
         }
     }
 
+    // Парсинг максимального размера сообщения в серверном режиме
+    const auto maxMessageOption = getOptionValue("--max-message-size", args);
+    if (maxMessageOption.has_value()) {
+        try {
+            maxMessageSize = std::stoul(*maxMessageOption);
+        }
+        catch (const std::exception &e) {
+            maxMessageSize = 0;
+        }
+        if (maxMessageSize == 0) {
+            LOG_ERROR << "Ошибка: некорректное значение для --max-message-size";
+            return 1;
+        }
+    }
+
     // Для интерактивного и серверного режимов не должно остаться аргументов
     if ((interactiveMode || serverMode) && !checkLastArgs(args)) {
         return 1;
@@ -211,7 +242,7 @@ int main(int argc, char *argv[])
 
     // Запуск в серверном режиме
     if (serverMode) {
-        return octet::server::Server::startServer(storage, socketPath);
+        return octet::server::Server::startServer(storage, socketPath, maxMessageSize);
     }
 
     // Запуск в интерактивном режиме
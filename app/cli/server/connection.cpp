@@ -1,23 +1,91 @@
 #include "connection.hpp"
 
+#include <algorithm>
+
 #include "logger.hpp"
 
 namespace octet::server {
-// Максимальный размер буфера чтения (16 КБ)
-constexpr uint16_t MAX_BUFFER_SIZE = 16384;
+namespace {
+// Текстовое представление состояния сжатия хранилища
+const char *compactionStateToString(CompactionState state)
+{
+    switch (state) {
+    case CompactionState::RUNNING:
+        return "running";
+    case CompactionState::COMPLETED:
+        return "completed";
+    case CompactionState::FAILED:
+        return "failed";
+    case CompactionState::IDLE:
+    default:
+        return "idle";
+    }
+}
+
+// Записи журнала, возвращаемые на один запрос JOURNAL по умолчанию
+constexpr size_t DEFAULT_JOURNAL_LIMIT = 1000;
+// Максимальный суммарный размер данных записей журнала в одном ответе
+constexpr size_t MAX_JOURNAL_BYTES = 4 * 1024 * 1024;
+// UUID, возвращаемые на один запрос LIST по умолчанию
+constexpr size_t DEFAULT_LIST_LIMIT = 1000;
+// Максимальное количество UUID в одном ответе на LIST
+constexpr size_t MAX_LIST_LIMIT = 10000;
+// Рекомендуемая задержка повтора изменений во время сжатия хранилища
+constexpr uint32_t COMPACTION_RETRY_AFTER_MS = 1000;
+// Максимальный срок хранения строки (100 лет)
+constexpr uint64_t MAX_TTL_SECONDS = 100ull * 365 * 24 * 60 * 60;
+
+// Изменяет ли команда данные хранилища
+bool isWriteCommand(CommandType command)
+{
+    return command == CommandType::INSERT || command == CommandType::UPDATE
+        || command == CommandType::REMOVE || command == CommandType::BATCH;
+}
+
+// Текстовое представление типа операции журнала
+const char *operationToString(OperationType type)
+{
+    switch (type) {
+    case OperationType::INSERT:
+        return "insert";
+    case OperationType::UPDATE:
+        return "update";
+    case OperationType::REMOVE:
+        return "remove";
+    case OperationType::EXPIRE:
+        return "expire";
+    case OperationType::CHECKPOINT:
+    default:
+        return "checkpoint";
+    }
+}
+
+// Заполнение ответа состоянием сжатия хранилища
+void setCompactionStatus(Response &response, const CompactionStatus &status)
+{
+    response.compactionState = compactionStateToString(status.state);
+    response.journalSizeBefore = status.journalSizeBefore;
+    response.journalSizeAfter = status.journalSizeAfter;
+}
+} // namespace
+
+// Размер буфера одного чтения из сокета (64 КБ). Размер сообщения ограничивает FrameParser,
+// а сообщения больше буфера собираются из нескольких чтений.
+constexpr size_t READ_BUFFER_SIZE = 64 * 1024;
 
 Connection::SharedConnection Connection::create(boost::asio::io_context &io_context,
-                                                StorageManager &storage)
+                                                StorageManager &storage, size_t maxMessageSize)
 {
-    return SharedConnection(new Connection(io_context, storage));
+    return SharedConnection(new Connection(io_context, storage, maxMessageSize));
 }
 
-Connection::Connection(boost::asio::io_context &io_context, StorageManager &storage)
+Connection::Connection(boost::asio::io_context &io_context, StorageManager &storage,
+                       size_t maxMessageSize)
     : socket_(io_context)
     , storage_(storage)
+    , readBuffer_(READ_BUFFER_SIZE)
+    , parser_(maxMessageSize)
 {
-    // Резервируем место для чтения
-    readBuffer_.reserve(MAX_BUFFER_SIZE);
 }
 
 boost::asio::local::stream_protocol::socket &Connection::socket()
@@ -36,13 +104,11 @@ void Connection::read()
     // Предотвращаем уничтожение указателя во время чтения
     auto self(shared_from_this());
 
-    // Создаем временный буфер
-    auto tmpBuffer = std::make_shared<std::vector<uint8_t>>(1024);
-
-    // Асинхронное чтение
+    // Асинхронное чтение (следующее чтение начинается только после обработки текущего,
+    // поэтому буфер чтения используется повторно)
     socket_.async_read_some(
-        boost::asio::buffer(*tmpBuffer), [this, self = shared_from_this(), tmpBuffer](
-                                             boost::system::error_code ec, std::size_t length) {
+        boost::asio::buffer(readBuffer_),
+        [this, self](boost::system::error_code ec, std::size_t length) {
             // Обрабатываем ошибки
             if (ec) {
                 if (ec != boost::asio::error::operation_aborted) {
@@ -58,45 +124,58 @@ void Connection::read()
                 return;
             }
 
-            // Чистим буффер, если у нас переполнение
-            if (readBuffer_.size() + length > MAX_BUFFER_SIZE) {
-                LOG_ERROR << "Переполнение буфера чтения, очищаем";
-                readBuffer_.clear();
+            // Передаем данные для сборки сообщений и обрабатываем собранные сообщения
+            parser_.append(readBuffer_.data(), length);
+            if (!processMessages()) {
+                return; // Соединение закрыто, чтение не продолжаем
             }
-            // Добавляем данные в буфер
-            readBuffer_.insert(readBuffer_.end(), tmpBuffer->begin(), tmpBuffer->begin() + length);
-
-            // Обрабатываем сообщения из буфера
-            processMessages();
 
             // Продолжаем чтение
             read();
         });
 }
 
-void Connection::processMessages()
+bool Connection::processMessages()
 {
+    std::string jsonMessage;
     while (true) {
-        const auto jsonMessage = ProtocolFrame::extractMessage(readBuffer_);
-        if (!jsonMessage.has_value()) {
-            break; // Нет полных сообщений, выходим из цикла
+        const auto result = parser_.next(jsonMessage);
+        if (result == FrameParser::Result::NEED_MORE) {
+            return true; // Нет полных сообщений, выходим из цикла
+        }
+        if (result == FrameParser::Result::TOO_LARGE) {
+            // Границы следующих фреймов неизвестны, поэтому продолжить чтение нельзя:
+            // закрываем соединение, и клиент получает ошибку вместо ответа
+            LOG_ERROR << "Размер сообщения превышает допустимый: " << parser_.pendingSize()
+                      << " байт, соединение закрывается";
+            boost::system::error_code ec;
+            socket_.close(ec);
+            return false;
         }
-        LOG_DEBUG << "Извлечено сообщение: " << *jsonMessage;
+        // Отвечаем на heartbeat-фреймы, полученные между сообщениями
+        if (result == FrameParser::Result::HEARTBEAT) {
+            writeFrame(ProtocolFrame::heartbeatFrame());
+            continue;
+        }
+        LOG_DEBUG << "Извлечено сообщение: " << jsonMessage;
 
         // Разбираем запрос
-        const auto request = Request::fromJson(*jsonMessage);
+        const auto request = Request::fromJson(jsonMessage);
         if (request.has_value()) {
             // Обрабатываем запрос и отправляем ответ
             const auto response = handleRequest(*request);
             write(response);
         }
         else {
-            LOG_ERROR << "Некорректный формат запроса: " << *jsonMessage;
+            LOG_ERROR << "Некорректный формат запроса: " << jsonMessage;
             // Отправляем ошибку
             Response errorResponse;
             errorResponse.requestId = "error";
             errorResponse.success = false;
             errorResponse.error = "Invalid request format";
+            // Разбор JSON отклоняет некорректный UTF-8, поэтому такие данные обнаруживаются здесь
+            errorResponse.errorCode = isValidUtf8(jsonMessage) ? ErrorCode::INTERNAL
+                                                               : ErrorCode::INVALID_UTF8;
             write(errorResponse);
         }
     }
@@ -106,9 +185,13 @@ void Connection::write(const Response &response)
 {
     // Сериализуем ответ в JSON
     const auto jsonResponse = response.toJson();
+    writeFrame(ProtocolFrame::wrapMessage(jsonResponse));
+}
 
+void Connection::writeFrame(std::vector<uint8_t> frame)
+{
     // Создаем новый буфер
-    auto buffer = std::make_shared<std::vector<uint8_t>>(ProtocolFrame::wrapMessage(jsonResponse));
+    auto buffer = std::make_shared<std::vector<uint8_t>>(std::move(frame));
     // Помещаем буфер в очередь
     {
         std::lock_guard<std::mutex> lock(writeMutex_);
@@ -155,6 +238,21 @@ void Connection::do_write()
                              });
 }
 
+std::optional<std::string> Connection::storedChecksum(const std::string &uuid) const
+{
+    // Считаем контрольную сумму по данным, фактически находящимся в хранилище
+    const auto stored = storage_.get(uuid);
+    if (!stored.has_value()) {
+        return std::nullopt;
+    }
+    return checksum(*stored);
+}
+
+ErrorCode Connection::writeErrorCode(size_t bytes) const
+{
+    return storage_.hasFreeSpace(bytes) ? ErrorCode::INTERNAL : ErrorCode::STORAGE_FULL;
+}
+
 Response Connection::handleRequest(const Request &request)
 {
     Response response;
@@ -162,21 +260,63 @@ Response Connection::handleRequest(const Request &request)
     response.success = true;
 
     try {
+        // Во время сжатия хранилища изменения не принимаются, клиент повторяет их позже
+        if (isWriteCommand(request.command)
+            && storage_.getCompactionStatus().state == CompactionState::RUNNING) {
+            response.success = false;
+            response.error = "Storage compaction in progress";
+            response.errorCode = ErrorCode::RETRY_LATER;
+            response.retryAfterMs = COMPACTION_RETRY_AFTER_MS;
+            return response;
+        }
+
         switch (request.command) {
         case CommandType::INSERT: {
             if (!request.data.has_value()) {
                 response.success = false;
                 response.error = "Missing data for INSERT";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+            if (!isValidUtf8(*request.data)) {
+                response.success = false;
+                response.error = "Data is not valid UTF-8";
+                response.errorCode = ErrorCode::INVALID_UTF8;
                 break;
             }
 
-            auto result = storage_.insert(*request.data);
+            if (request.ttlSeconds.value_or(0) > MAX_TTL_SECONDS) {
+                response.success = false;
+                response.error = "TTL is too large";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+
+            // С указанным UUID строка добавляется, только если такой строки еще нет
+            if (request.uuid.has_value() && !UuidGenerator::isValidUuid(*request.uuid)) {
+                response.success = false;
+                response.error = "Invalid uuid for INSERT";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+
+            auto result
+                = storage_.insert(*request.data, request.ttlSeconds.value_or(0), request.uuid);
             if (result.has_value()) {
                 response.uuid = std::move(*result);
+                if (request.withChecksum) {
+                    response.checksum = storedChecksum(*response.uuid);
+                }
+            }
+            else if (request.uuid.has_value() && storage_.contains(*request.uuid)) {
+                response.success = false;
+                response.error = "Item already exists";
+                response.errorCode = ErrorCode::ALREADY_EXISTS;
             }
             else {
                 response.success = false;
                 response.error = "Failed to insert data";
+                response.errorCode = writeErrorCode(request.data->size());
             }
             break;
         }
@@ -184,16 +324,68 @@ Response Connection::handleRequest(const Request &request)
             if (!request.uuid.has_value()) {
                 response.success = false;
                 response.error = "Missing uuid for GET";
+                response.errorCode = ErrorCode::INTERNAL;
                 break;
             }
 
-            auto result = storage_.get(*request.uuid);
+            uint64_t version = 0;
+            auto result = storage_.get(*request.uuid, &version);
             if (result.has_value()) {
                 response.data = std::move(*result);
+                // Версия неизвестна для строк, добавленных до появления метаданных
+                if (version != 0) {
+                    response.version = version;
+                }
             }
             else {
                 response.success = false;
                 response.error = "Data not found";
+                response.errorCode = ErrorCode::NOT_FOUND;
+            }
+            break;
+        }
+        case CommandType::EXISTS: {
+            if (!request.uuid.has_value()) {
+                response.success = false;
+                response.error = "Missing uuid for EXISTS";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+
+            // Отсутствие строки сообщается так же, как для GET, но без передачи данных
+            if (!storage_.contains(*request.uuid)) {
+                response.success = false;
+                response.error = "Data not found";
+                response.errorCode = ErrorCode::NOT_FOUND;
+            }
+            break;
+        }
+        case CommandType::STAT: {
+            if (!request.uuid.has_value()) {
+                response.success = false;
+                response.error = "Missing uuid for STAT";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+
+            const auto stat = storage_.stat(*request.uuid);
+            if (!stat.has_value()) {
+                response.success = false;
+                response.error = "Data not found";
+                response.errorCode = ErrorCode::NOT_FOUND;
+                break;
+            }
+            response.size = stat->size;
+            response.version = stat->meta.version;
+            // Временные метки строк из снапшота без метаданных неизвестны
+            if (!stat->meta.createdAt.empty()) {
+                response.createdAt = stat->meta.createdAt;
+            }
+            if (!stat->meta.modifiedAt.empty()) {
+                response.modifiedAt = stat->meta.modifiedAt;
+            }
+            if (!stat->meta.expiresAt.empty()) {
+                response.expiresAt = stat->meta.expiresAt;
             }
             break;
         }
@@ -201,13 +393,38 @@ Response Connection::handleRequest(const Request &request)
             if (!request.uuid.has_value() || !request.data.has_value()) {
                 response.success = false;
                 response.error = "Missing UUID or data for UPDATE";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+            if (!isValidUtf8(*request.data)) {
+                response.success = false;
+                response.error = "Data is not valid UTF-8";
+                response.errorCode = ErrorCode::INVALID_UTF8;
                 break;
             }
 
-            const auto result = storage_.update(*request.uuid, *request.data);
+            const auto result
+                = storage_.update(*request.uuid, *request.data, request.expectedVersion);
             if (!result) {
                 response.success = false;
                 response.error = "Failed to update item";
+                // Хранилище не различает отсутствие строки, несовпадение версии и ошибку записи,
+                // поэтому причина уточняется отдельно (версия строки только увеличивается)
+                const auto stat = storage_.stat(*request.uuid);
+                if (!stat.has_value()) {
+                    response.errorCode = ErrorCode::NOT_FOUND;
+                }
+                else if (request.expectedVersion.has_value()
+                         && stat->meta.version != *request.expectedVersion) {
+                    response.error = "Version mismatch";
+                    response.errorCode = ErrorCode::VERSION_MISMATCH;
+                }
+                else {
+                    response.errorCode = writeErrorCode(request.data->size());
+                }
+            }
+            else if (request.withChecksum) {
+                response.checksum = storedChecksum(*request.uuid);
             }
             break;
         }
@@ -215,6 +432,7 @@ Response Connection::handleRequest(const Request &request)
             if (!request.uuid.has_value()) {
                 response.success = false;
                 response.error = "Missing uuid for REMOVE";
+                response.errorCode = ErrorCode::INTERNAL;
                 break;
             }
 
@@ -222,16 +440,97 @@ Response Connection::handleRequest(const Request &request)
             if (!result) {
                 response.success = false;
                 response.error = "Failed to remove item";
+                response.errorCode = storage_.contains(*request.uuid) ? writeErrorCode(0)
+                                                                      : ErrorCode::NOT_FOUND;
             }
             break;
         }
         case CommandType::PING: {
             break;
         }
+        case CommandType::STATS: {
+            response.count = storage_.getEntriesCount();
+            break;
+        }
+        case CommandType::COUNT: {
+            // Количество записей без остальной статистики хранилища
+            response.count = storage_.getEntriesCount();
+            break;
+        }
+        case CommandType::COMPACT: {
+            // Повторный запрос во время сжатия возвращает состояние уже запущенного сжатия
+            storage_.requestCompactionAsync();
+            setCompactionStatus(response, storage_.getCompactionStatus());
+            break;
+        }
+        case CommandType::COMPACT_STATUS: {
+            setCompactionStatus(response, storage_.getCompactionStatus());
+            break;
+        }
+        case CommandType::JOURNAL: {
+            bool more = false;
+            const auto entries = storage_.getJournalEntries(
+                request.since.value_or(""), request.skip,
+                request.limit.value_or(DEFAULT_JOURNAL_LIMIT), MAX_JOURNAL_BYTES, more);
+            if (!entries.has_value()) {
+                response.success = false;
+                response.error = "Failed to read journal";
+                response.errorCode = ErrorCode::INTERNAL;
+                break;
+            }
+
+            std::vector<JournalRecord> records;
+            records.reserve(entries->size());
+            for (const auto &entry : *entries) {
+                records.push_back({ operationToString(entry.type()), entry.uuid(),
+                                    entry.timestamp(), entry.data() });
+            }
+            response.journal = std::move(records);
+            response.more = more;
+            break;
+        }
+        case CommandType::LIST: {
+            bool more = false;
+            const auto limit = std::min(request.limit.value_or(DEFAULT_LIST_LIMIT), MAX_LIST_LIMIT);
+            response.uuids = storage_.listUuids(request.after.value_or(""), limit, more);
+            response.more = more;
+            break;
+        }
+        case CommandType::BATCH: {
+            // Операции выполняются по порядку как отдельные запросы,
+            // ошибка одной операции не прерывает выполнение остальных
+            std::vector<BatchResult> results;
+            results.reserve(request.operations.size());
+            for (const auto &operation : request.operations) {
+                if (operation.command != CommandType::INSERT
+                    && operation.command != CommandType::UPDATE
+                    && operation.command != CommandType::REMOVE) {
+                    results.push_back({ false, std::nullopt, std::nullopt,
+                                        "Unsupported command in BATCH", ErrorCode::INTERNAL });
+                    continue;
+                }
+
+                Request single;
+                single.requestId = request.requestId;
+                single.command = operation.command;
+                single.uuid = operation.uuid;
+                single.data = operation.data;
+                single.ttlSeconds = operation.ttlSeconds;
+                single.expectedVersion = operation.expectedVersion;
+                single.withChecksum = request.withChecksum;
+                auto result = handleRequest(single);
+                results.push_back({ result.success, std::move(result.uuid),
+                                    std::move(result.checksum), std::move(result.error),
+                                    result.errorCode });
+            }
+            response.results = std::move(results);
+            break;
+        }
         case CommandType::UNKNOWN:
         default: {
             response.success = false;
             response.error = "Unknown command";
+            response.errorCode = ErrorCode::INTERNAL;
             break;
         }
         }
@@ -239,7 +538,9 @@ Response Connection::handleRequest(const Request &request)
     catch (const std::exception &e) {
         response.success = false;
         response.error = std::string("Exception: ") + e.what();
-        LOG_ERROR << "Исключение при обработке запроса: " << e.what();
+        response.errorCode = ErrorCode::INTERNAL;
+        LOG_ERROR << "Исключение при обработке запроса: " << e.what()
+                  << " (traceparent: " << request.traceparent.value_or("-") << ")";
     }
 
     return response;
@@ -22,9 +22,11 @@ public:
      * @brief Создает новое соединение
      * @param ioCtx ASIO контекст
      * @param storage Ссылка на хранилище
+     * @param maxMessageSize Максимальный размер сообщения от клиента
      * @return Указатель на новое соединение
      */
-    static SharedConnection create(boost::asio::io_context &ioCtx, StorageManager &storage);
+    static SharedConnection create(boost::asio::io_context &ioCtx, StorageManager &storage,
+                                   size_t maxMessageSize);
 
     /**
      * @brief Получить сокет
@@ -40,7 +42,8 @@ public:
 private:
     StorageManager &storage_; // Хранилище
     boost::asio::local::stream_protocol::socket socket_; // Сокет
-    std::vector<uint8_t> readBuffer_; // Буфер для чтения
+    std::vector<uint8_t> readBuffer_; // Буфер для чтения из сокета
+    FrameParser parser_; // Сборка сообщений из прочитанных фреймов
     std::queue<std::shared_ptr<std::vector<uint8_t>>> writeQueue_; // Очередь буферов для записи
     std::mutex writeMutex_; // Мьютекс для защиты очереди записи
     bool writeInProgress_; // Выполняется ли в данный момент операция записи
@@ -49,8 +52,9 @@ private:
      * @brief Конструктор
      * @param ioCtx ASIO контекст
      * @param storage Ссылка на хранилище
+     * @param maxMessageSize Максимальный размер сообщения от клиента
      */
-    Connection(boost::asio::io_context &ioCtx, StorageManager &storage);
+    Connection(boost::asio::io_context &ioCtx, StorageManager &storage, size_t maxMessageSize);
 
     /**
      * @brief Асинхронное чтение данных
@@ -58,9 +62,10 @@ private:
     void read();
 
     /**
-     * @brief Обработка сообщений в буфере
+     * @brief Обработка сообщений, собранных из прочитанных фреймов
+     * @return false, если соединение закрыто из-за некорректных данных
      */
-    void processMessages();
+    bool processMessages();
 
     /**
      * @brief Постановка данных в очередь на отправку
@@ -68,6 +73,12 @@ private:
      */
     void write(const Response &response);
 
+    /**
+     * @brief Постановка готовых байтов фрейма в очередь на отправку
+     * @param frame Байты фрейма
+     */
+    void writeFrame(std::vector<uint8_t> frame);
+
     /**
      * @brief Асинхронная запись сообщения из очереди
      */
@@ -79,6 +90,20 @@ private:
      * @return Ответ
      */
     Response handleRequest(const Request &request);
+
+    /**
+     * @brief Контрольная сумма данных, сохраненных в хранилище
+     * @param uuid Идентификатор строки
+     * @return Контрольная сумма или std::nullopt, если строка не найдена
+     */
+    std::optional<std::string> storedChecksum(const std::string &uuid) const;
+
+    /**
+     * @brief Код ошибки для неудавшейся записи в хранилище
+     * @param bytes Размер записываемых данных
+     * @return STORAGE_FULL при нехватке места на диске, иначе INTERNAL
+     */
+    ErrorCode writeErrorCode(size_t bytes) const;
 };
 
 } // namespace octet::server
@@ -1,5 +1,8 @@
 #include "protocol.hpp"
 
+#include <algorithm>
+#include <cstdio>
+
 #include "3rdparty/json.hpp"
 #include "logger.hpp"
 
@@ -23,6 +26,10 @@ std::optional<Request> Request::fromJson(const std::string &jsonStr)
         req.requestId = jsonData["request_id"].get<std::string>();
         req.command = stringToCommand(jsonData["command"].get<std::string>());
 
+        if (jsonData.contains("traceparent")) {
+            req.traceparent = jsonData["traceparent"].get<std::string>();
+        }
+
         // Разбор параметров
         const auto &params = jsonData["params"];
         if (params.contains("uuid")) {
@@ -33,6 +40,54 @@ std::optional<Request> Request::fromJson(const std::string &jsonStr)
             req.data = params["data"].get<std::string>();
         }
 
+        if (params.contains("with_checksum")) {
+            req.withChecksum = params["with_checksum"].get<bool>();
+        }
+
+        if (params.contains("ttl_seconds")) {
+            req.ttlSeconds = params["ttl_seconds"].get<uint64_t>();
+        }
+
+        if (params.contains("expected_version")) {
+            req.expectedVersion = params["expected_version"].get<uint64_t>();
+        }
+
+        if (params.contains("since")) {
+            req.since = params["since"].get<std::string>();
+        }
+
+        if (params.contains("skip")) {
+            req.skip = params["skip"].get<size_t>();
+        }
+
+        if (params.contains("limit")) {
+            req.limit = params["limit"].get<size_t>();
+        }
+
+        if (params.contains("after")) {
+            req.after = params["after"].get<std::string>();
+        }
+
+        if (params.contains("operations")) {
+            for (const auto &item : params["operations"]) {
+                BatchOperation operation;
+                operation.command = stringToCommand(item["command"].get<std::string>());
+                if (item.contains("uuid")) {
+                    operation.uuid = item["uuid"].get<std::string>();
+                }
+                if (item.contains("data")) {
+                    operation.data = item["data"].get<std::string>();
+                }
+                if (item.contains("ttl_seconds")) {
+                    operation.ttlSeconds = item["ttl_seconds"].get<uint64_t>();
+                }
+                if (item.contains("expected_version")) {
+                    operation.expectedVersion = item["expected_version"].get<uint64_t>();
+                }
+                req.operations.push_back(std::move(operation));
+            }
+        }
+
         return req;
     }
     catch (const json::exception &e) {
@@ -53,6 +108,24 @@ CommandType Request::stringToCommand(const std::string &cmd_str)
         return CommandType::REMOVE;
     if (cmd_str == "ping")
         return CommandType::PING;
+    if (cmd_str == "stats")
+        return CommandType::STATS;
+    if (cmd_str == "compact")
+        return CommandType::COMPACT;
+    if (cmd_str == "compact_status")
+        return CommandType::COMPACT_STATUS;
+    if (cmd_str == "journal")
+        return CommandType::JOURNAL;
+    if (cmd_str == "batch")
+        return CommandType::BATCH;
+    if (cmd_str == "list")
+        return CommandType::LIST;
+    if (cmd_str == "exists")
+        return CommandType::EXISTS;
+    if (cmd_str == "stat")
+        return CommandType::STAT;
+    if (cmd_str == "count")
+        return CommandType::COUNT;
     return CommandType::UNKNOWN;
 }
 
@@ -69,58 +142,215 @@ std::string Response::toJson() const
     if (data.has_value()) {
         params["data"] = *data;
     }
+    if (checksum.has_value()) {
+        params["checksum"] = *checksum;
+    }
+    if (count.has_value()) {
+        params["count"] = *count;
+    }
+    if (compactionState.has_value()) {
+        params["compaction_state"] = *compactionState;
+    }
+    if (journalSizeBefore.has_value()) {
+        params["journal_size_before"] = *journalSizeBefore;
+    }
+    if (journalSizeAfter.has_value()) {
+        params["journal_size_after"] = *journalSizeAfter;
+    }
+    if (journal.has_value()) {
+        auto records = json::array();
+        for (const auto &record : *journal) {
+            json item;
+            item["op"] = record.operation;
+            item["uuid"] = record.uuid;
+            item["time"] = record.timestamp;
+            if (!record.data.empty()) {
+                item["data"] = record.data;
+            }
+            records.push_back(std::move(item));
+        }
+        params["journal"] = std::move(records);
+    }
+    if (more.has_value()) {
+        params["more"] = *more;
+    }
+    if (uuids.has_value()) {
+        params["uuids"] = *uuids;
+    }
+    if (results.has_value()) {
+        auto items = json::array();
+        for (const auto &result : *results) {
+            json item;
+            item["success"] = result.success;
+            if (result.uuid.has_value()) {
+                item["uuid"] = *result.uuid;
+            }
+            if (result.checksum.has_value()) {
+                item["checksum"] = *result.checksum;
+            }
+            if (result.error.has_value()) {
+                item["error"] = *result.error;
+            }
+            if (result.errorCode.has_value()) {
+                item["error_code"] = errorCodeToString(*result.errorCode);
+            }
+            items.push_back(std::move(item));
+        }
+        params["results"] = std::move(items);
+    }
+    if (size.has_value()) {
+        params["size"] = *size;
+    }
+    if (createdAt.has_value()) {
+        params["created_at"] = *createdAt;
+    }
+    if (modifiedAt.has_value()) {
+        params["modified_at"] = *modifiedAt;
+    }
+    if (version.has_value()) {
+        params["version"] = *version;
+    }
+    if (expiresAt.has_value()) {
+        params["expires_at"] = *expiresAt;
+    }
     jsonData["params"] = params;
 
     if (error.has_value()) {
         jsonData["error"] = *error;
     }
+    if (errorCode.has_value()) {
+        jsonData["error_code"] = errorCodeToString(*errorCode);
+    }
+    if (retryAfterMs.has_value()) {
+        jsonData["retry_after_ms"] = *retryAfterMs;
+    }
 
     return jsonData.dump();
 }
 
-std::vector<uint8_t> ProtocolFrame::wrapMessage(const std::string &jsonMessage)
+std::string errorCodeToString(ErrorCode code)
 {
-    // Вычисляем длину сообщения
-    const uint32_t length = static_cast<uint32_t>(jsonMessage.size());
+    switch (code) {
+    case ErrorCode::NOT_FOUND:
+        return "NOT_FOUND";
+    case ErrorCode::INVALID_UTF8:
+        return "INVALID_UTF8";
+    case ErrorCode::STORAGE_FULL:
+        return "STORAGE_FULL";
+    case ErrorCode::RETRY_LATER:
+        return "RETRY_LATER";
+    case ErrorCode::VERSION_MISMATCH:
+        return "VERSION_MISMATCH";
+    case ErrorCode::ALREADY_EXISTS:
+        return "ALREADY_EXISTS";
+    case ErrorCode::INTERNAL:
+    default:
+        return "INTERNAL";
+    }
+}
 
-    // Кодируем длину
-    auto lengthBytes = encodeLength(length);
+bool isValidUtf8(const std::string &data)
+{
+    size_t i = 0;
+    while (i < data.size()) {
+        const auto byte = static_cast<uint8_t>(data[i]);
+        // Длина последовательности и минимальное кодируемое значение (для отказа от избыточных форм)
+        size_t length = 0;
+        uint32_t codepoint = 0;
+        uint32_t minimum = 0;
+        if (byte < 0x80) {
+            ++i;
+            continue;
+        }
+        else if ((byte & 0xE0) == 0xC0) {
+            length = 2;
+            codepoint = byte & 0x1F;
+            minimum = 0x80;
+        }
+        else if ((byte & 0xF0) == 0xE0) {
+            length = 3;
+            codepoint = byte & 0x0F;
+            minimum = 0x800;
+        }
+        else if ((byte & 0xF8) == 0xF0) {
+            length = 4;
+            codepoint = byte & 0x07;
+            minimum = 0x10000;
+        }
+        else {
+            return false;
+        }
 
-    // Создаем результирующий буфер
-    const auto lengthBytesSize = lengthBytes.size();
-    std::vector<uint8_t> frame(lengthBytesSize + jsonMessage.size());
+        if (i + length > data.size()) {
+            return false;
+        }
+        for (size_t k = 1; k < length; ++k) {
+            const auto next = static_cast<uint8_t>(data[i + k]);
+            if ((next & 0xC0) != 0x80) {
+                return false;
+            }
+            codepoint = (codepoint << 6) | (next & 0x3F);
+        }
+        // Суррогаты UTF-16 и значения за пределами Unicode недопустимы
+        if (codepoint < minimum || codepoint > 0x10FFFF
+            || (codepoint >= 0xD800 && codepoint <= 0xDFFF)) {
+            return false;
+        }
+        i += length;
+    }
+    return true;
+}
 
-    // Копируем заголовок и сообщение
-    std::copy(lengthBytes.begin(), lengthBytes.end(), frame.begin());
-    std::copy(jsonMessage.begin(), jsonMessage.end(), frame.begin() + lengthBytesSize);
+std::string checksum(const std::string &data)
+{
+    // CRC32 (полином IEEE 802.3 в отраженном виде), совместимый с hash/crc32 в Go
+    constexpr uint32_t POLYNOMIAL = 0xEDB88320u;
+    uint32_t crc = 0xFFFFFFFFu;
+    for (const auto byte : data) {
+        crc ^= static_cast<uint8_t>(byte);
+        for (int bit = 0; bit < 8; ++bit) {
+            crc = (crc >> 1) ^ (POLYNOMIAL & (0u - (crc & 1u)));
+        }
+    }
+    crc ^= 0xFFFFFFFFu;
 
-    return frame;
+    char hex[9];
+    std::snprintf(hex, sizeof(hex), "%08x", crc);
+    return std::string(hex);
 }
 
-std::optional<std::string> ProtocolFrame::extractMessage(std::vector<uint8_t> &buffer)
+std::vector<uint8_t> ProtocolFrame::wrapMessage(const std::string &jsonMessage)
 {
-    constexpr size_t headerSize = 4; // 4 байта для длины
-
-    // Проверяем, есть ли достаточно данных для чтения заголовка
-    if (buffer.size() < headerSize) {
-        return std::nullopt;
-    }
+    std::vector<uint8_t> frame;
+    frame.reserve(jsonMessage.size() + sizeof(uint32_t));
 
-    // Извлекаем длину сообщения
-    const auto messageLength = decodeLength(buffer.data());
+    // Разбиваем сообщение на фреймы размером не более CHUNK_SIZE
+    // (пустое сообщение передается одним пустым фреймом)
+    size_t offset = 0;
+    do {
+        const auto chunkSize = std::min(CHUNK_SIZE, jsonMessage.size() - offset);
+        const bool more = offset + chunkSize < jsonMessage.size();
 
-    // Проверяем, получили ли мы все сообщение
-    if (buffer.size() < headerSize + messageLength) {
-        return std::nullopt;
-    }
+        // Кодируем длину вместе с флагом продолжения
+        auto header = static_cast<uint32_t>(chunkSize);
+        if (more) {
+            header |= CONTINUATION_FLAG;
+        }
+        const auto lengthBytes = encodeLength(header);
 
-    // Извлекаем сообщение
-    std::string message(buffer.begin() + headerSize, buffer.begin() + headerSize + messageLength);
+        // Копируем заголовок и часть сообщения
+        frame.insert(frame.end(), lengthBytes.begin(), lengthBytes.end());
+        frame.insert(frame.end(), jsonMessage.begin() + offset,
+                     jsonMessage.begin() + offset + chunkSize);
+        offset += chunkSize;
+    } while (offset < jsonMessage.size());
 
-    // Удаляем обработанные данные из буфера
-    buffer.erase(buffer.begin(), buffer.begin() + headerSize + messageLength);
+    return frame;
+}
 
-    return message;
+std::vector<uint8_t> ProtocolFrame::heartbeatFrame()
+{
+    return encodeLength(CONTINUATION_FLAG);
 }
 
 // !! Для кодирования/декодирования используем формат little-endian
@@ -146,4 +376,90 @@ std::vector<uint8_t> ProtocolFrame::encodeLength(uint32_t length)
     }
     return bytes;
 }
+
+FrameParser::FrameParser(size_t maxMessageSize)
+    : maxMessageSize_(maxMessageSize)
+{
+}
+
+void FrameParser::append(const uint8_t *data, size_t size)
+{
+    buffer_.insert(buffer_.end(), data, data + size);
+}
+
+FrameParser::Result FrameParser::next(std::string &message)
+{
+    constexpr size_t headerSize = 4; // 4 байта для длины
+
+    if (failed_) {
+        return Result::TOO_LARGE;
+    }
+
+    while (true) {
+        // Переносим полученную часть полезной нагрузки текущего фрейма в сообщение
+        if (frameRemaining_ > 0) {
+            const auto chunkSize = std::min(frameRemaining_, buffer_.size() - offset_);
+            const auto begin = buffer_.begin() + offset_;
+            message_.append(begin, begin + chunkSize);
+            offset_ += chunkSize;
+            frameRemaining_ -= chunkSize;
+            if (frameRemaining_ > 0) {
+                compact();
+                return Result::NEED_MORE;
+            }
+        }
+
+        // Последний фрейм получен полностью - сообщение собрано
+        if (inMessage_ && lastFrame_) {
+            message = std::move(message_);
+            message_.clear();
+            inMessage_ = false;
+            declaredSize_ = 0;
+            return Result::MESSAGE;
+        }
+
+        // Проверяем, есть ли достаточно данных для чтения заголовка
+        if (buffer_.size() - offset_ < headerSize) {
+            compact();
+            return Result::NEED_MORE;
+        }
+
+        // Извлекаем длину фрейма и флаг продолжения
+        const auto header = ProtocolFrame::decodeLength(buffer_.data() + offset_);
+        offset_ += headerSize;
+        if (!inMessage_ && header == ProtocolFrame::CONTINUATION_FLAG) {
+            return Result::HEARTBEAT;
+        }
+
+        // Проверяем размер до получения данных, чтобы поврежденный заголовок
+        // не заставил накапливать гигабайты в памяти
+        const size_t frameLength = header & ~ProtocolFrame::CONTINUATION_FLAG;
+        declaredSize_ += frameLength;
+        if (declaredSize_ > maxMessageSize_) {
+            failed_ = true;
+            buffer_.clear();
+            message_.clear();
+            offset_ = 0;
+            return Result::TOO_LARGE;
+        }
+
+        if (!inMessage_) {
+            message_.reserve(frameLength);
+        }
+        inMessage_ = true;
+        lastFrame_ = (header & ProtocolFrame::CONTINUATION_FLAG) == 0;
+        frameRemaining_ = frameLength;
+    }
+}
+
+size_t FrameParser::pendingSize() const
+{
+    return declaredSize_;
+}
+
+void FrameParser::compact()
+{
+    buffer_.erase(buffer_.begin(), buffer_.begin() + offset_);
+    offset_ = 0;
+}
 } // namespace octet::server
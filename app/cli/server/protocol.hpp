@@ -10,7 +10,56 @@ namespace octet::server {
  * @enum CommandType
  * @brief Типы команд для взаимодействия между Go и C++
  */
-enum class CommandType { INSERT, GET, UPDATE, REMOVE, PING, UNKNOWN };
+enum class CommandType {
+    INSERT,
+    GET,
+    UPDATE,
+    REMOVE,
+    PING,
+    STATS,
+    COMPACT,
+    COMPACT_STATUS,
+    JOURNAL,
+    BATCH,
+    LIST,
+    EXISTS,
+    STAT,
+    COUNT,
+    UNKNOWN
+};
+
+/**
+ * @enum ErrorCode
+ * @brief Машиночитаемые коды ошибок в ответах (согласованы с octet-server)
+ */
+enum class ErrorCode {
+    NOT_FOUND, // Строка с указанным UUID отсутствует
+    INVALID_UTF8, // Данные не являются корректной строкой UTF-8
+    STORAGE_FULL, // Недостаточно места на диске для записи
+    RETRY_LATER, // Запрос временно не может быть выполнен (например, идет сжатие хранилища)
+    VERSION_MISMATCH, // Версия строки не совпадает с ожидаемой (для UPDATE с expected_version)
+    ALREADY_EXISTS, // Строка с указанным UUID уже существует (для INSERT с uuid)
+    INTERNAL // Остальные ошибки
+};
+
+/**
+ * @brief Строковое представление кода ошибки
+ * @param code Код ошибки
+ * @return Строка кода ошибки (например, NOT_FOUND)
+ */
+std::string errorCodeToString(ErrorCode code);
+
+/**
+ * @struct BatchOperation
+ * @brief Операция изменения данных в запросе BATCH
+ */
+struct BatchOperation {
+    CommandType command; // INSERT, UPDATE или REMOVE
+    std::optional<std::string> uuid;
+    std::optional<std::string> data;
+    std::optional<uint64_t> ttlSeconds; // Срок хранения добавляемой строки в секундах (для INSERT)
+    std::optional<uint64_t> expectedVersion; // Ожидаемая версия обновляемой строки (для UPDATE)
+};
 
 /**
  * @struct Request
@@ -21,6 +70,15 @@ struct Request {
     CommandType command;
     std::optional<std::string> uuid;
     std::optional<std::string> data;
+    bool withChecksum = false; // Запрошена контрольная сумма сохраненных данных
+    std::optional<uint64_t> ttlSeconds; // Срок хранения добавляемой строки в секундах (для INSERT)
+    std::optional<uint64_t> expectedVersion; // Ожидаемая версия обновляемой строки (для UPDATE)
+    std::optional<std::string> since; // Начальный момент для JOURNAL (ISO 8601)
+    size_t skip = 0; // Количество пропускаемых записей журнала для JOURNAL
+    std::optional<size_t> limit; // Максимальное количество записей для JOURNAL и LIST
+    std::optional<std::string> after; // UUID, после которого начинается страница LIST
+    std::vector<BatchOperation> operations; // Операции для BATCH (выполняются по порядку)
+    std::optional<std::string> traceparent; // Контекст трассировки W3C Trace Context (для логов)
 
     /**
      * @brief Десериализация запроса из JSON
@@ -37,6 +95,29 @@ struct Request {
     static CommandType stringToCommand(const std::string &cmdStr);
 };
 
+/**
+ * @struct JournalRecord
+ * @brief Запись журнала операций в ответе на JOURNAL
+ */
+struct JournalRecord {
+    std::string operation; // insert, update, remove, checkpoint или expire
+    std::string uuid;
+    std::string timestamp;
+    std::string data;
+};
+
+/**
+ * @struct BatchResult
+ * @brief Результат операции в ответе на BATCH
+ */
+struct BatchResult {
+    bool success;
+    std::optional<std::string> uuid;
+    std::optional<std::string> checksum;
+    std::optional<std::string> error;
+    std::optional<ErrorCode> errorCode;
+};
+
 /**
  * @struct Response
  * @brief Структура ответа от C++ к Go
@@ -46,7 +127,24 @@ struct Response {
     bool success;
     std::optional<std::string> uuid;
     std::optional<std::string> data;
+    std::optional<std::string> checksum;
+    std::optional<size_t> count; // Количество записей в хранилище (для STATS и COUNT)
+    // Состояние сжатия хранилища (для COMPACT и COMPACT_STATUS)
+    std::optional<std::string> compactionState;
+    std::optional<uintmax_t> journalSizeBefore; // Размер журнала до сжатия
+    std::optional<uintmax_t> journalSizeAfter; // Размер журнала после сжатия
+    std::optional<std::vector<JournalRecord>> journal; // Записи журнала (для JOURNAL)
+    std::optional<bool> more; // Остались ли записи журнала или UUID, не вошедшие в ответ
+    std::optional<std::vector<std::string>> uuids; // UUID строк в порядке возрастания (для LIST)
+    std::optional<std::vector<BatchResult>> results; // Результаты операций (для BATCH)
+    std::optional<size_t> size; // Размер данных строки в байтах (для STAT)
+    std::optional<std::string> createdAt; // Время добавления строки (для STAT, ISO 8601)
+    std::optional<std::string> modifiedAt; // Время последнего изменения строки (для STAT)
+    std::optional<uint64_t> version; // Версия строки (для GET и STAT)
+    std::optional<std::string> expiresAt; // Момент истечения срока хранения строки (для STAT)
     std::optional<std::string> error;
+    std::optional<ErrorCode> errorCode; // Код ошибки (при success = false)
+    std::optional<uint32_t> retryAfterMs; // Рекомендуемая задержка повтора (при RETRY_LATER)
 
     /**
      * @brief Сериализация ответа в JSON
@@ -55,31 +153,58 @@ struct Response {
     std::string toJson() const;
 };
 
+/**
+ * @brief Вычисление контрольной суммы данных (CRC32 IEEE в шестнадцатеричном виде)
+ * @param data Данные
+ * @return Контрольная сумма из 8 шестнадцатеричных символов
+ */
+std::string checksum(const std::string &data);
+
+/**
+ * @brief Проверка, что данные являются корректной строкой UTF-8
+ * @param data Данные
+ * @return true если данные - корректная строка UTF-8
+ */
+bool isValidUtf8(const std::string &data);
+
 /**
  * @brief Класс для работы с форматом сообщений по протоколу
  *
- * Формат: [4 байта длины сообщения][JSON-сообщение]
+ * Формат фрейма: [4 байта заголовка][часть JSON-сообщения]
+ *
+ * Младшие 31 бит заголовка содержат длину фрейма, старший бит - флаг продолжения.
+ * Сообщение может состоять из нескольких фреймов: все фреймы, кроме последнего,
+ * передаются с установленным флагом продолжения.
+ *
+ * Пустой фрейм с флагом продолжения, полученный между сообщениями, является
+ * heartbeat-фреймом: в ответ на него отправляется такой же фрейм.
+ *
+ * Разбор входящих фреймов выполняет FrameParser.
  */
 class ProtocolFrame {
 public:
+    /// Флаг продолжения в заголовке фрейма
+    static constexpr uint32_t CONTINUATION_FLAG = 0x80000000u;
+    /// Размер полезной нагрузки одного фрейма (1 МБ)
+    static constexpr size_t CHUNK_SIZE = 1u << 20;
+
     /**
-     * @brief Обертывание JSON-сообщения в фрейм протокола
+     * @brief Обертывание JSON-сообщения в фреймы протокола
      * @param jsonMessage Сообщение в формате JSON
-     * @return Байты фрейма протокола
+     * @return Байты одного или нескольких фреймов протокола
      */
     static std::vector<uint8_t> wrapMessage(const std::string &jsonMessage);
 
     /**
-     * @brief Извлечение JSON-сообщения из частичного буфера
-     * @param buffer Буфер с данными
-     * @return std::nullopt, если сообщение неполное, или JSON-сообщение
+     * @brief Формирование heartbeat-фрейма
+     * @return Байты heartbeat-фрейма
      */
-    static std::optional<std::string> extractMessage(std::vector<uint8_t> &buffer);
+    static std::vector<uint8_t> heartbeatFrame();
 
     /**
      * @brief Извлечение длины сообщения из заголовка
      * @param headerBytes Байты заголовка (4 байта)
-     * @return Длина сообщения
+     * @return Длина сообщения (вместе с флагом продолжения)
      */
     static uint32_t decodeLength(const uint8_t *headerBytes);
 
@@ -90,4 +215,71 @@ public:
      */
     static std::vector<uint8_t> encodeLength(uint32_t length);
 };
+
+/**
+ * @class FrameParser
+ * @brief Сборка сообщений из фреймов, поступающих из сокета частями
+ *
+ * Полезная нагрузка фреймов переносится в собираемое сообщение по мере получения,
+ * поэтому в буфере хранится не больше одного непрочитанного заголовка и остатка
+ * последнего чтения, а каждый байт сообщения копируется один раз.
+ */
+class FrameParser {
+public:
+    /// Максимальный размер сообщения по умолчанию (64 МБ, как в octet-server)
+    static constexpr size_t DEFAULT_MAX_MESSAGE_SIZE = 64u << 20;
+
+    /**
+     * @enum Result
+     * @brief Результат разбора очередной части буфера
+     */
+    enum class Result {
+        NEED_MORE, // Для очередного сообщения нужны еще данные
+        MESSAGE, // Сообщение собрано полностью
+        HEARTBEAT, // Получен heartbeat-фрейм
+        TOO_LARGE // Сообщение превышает максимальный размер (дальнейший разбор невозможен)
+    };
+
+    /**
+     * @brief Конструктор
+     * @param maxMessageSize Максимальный суммарный размер полезной нагрузки сообщения
+     */
+    explicit FrameParser(size_t maxMessageSize = DEFAULT_MAX_MESSAGE_SIZE);
+
+    /**
+     * @brief Добавление данных, прочитанных из сокета
+     * @param data Данные
+     * @param size Размер данных
+     */
+    void append(const uint8_t *data, size_t size);
+
+    /**
+     * @brief Разбор накопленных данных до очередного сообщения или heartbeat-фрейма
+     * @param message Собранное сообщение (заполняется при Result::MESSAGE)
+     * @return Результат разбора
+     */
+    Result next(std::string &message);
+
+    /**
+     * @brief Размер собираемого сообщения
+     * @return Количество байт, полученных или заявленных в заголовках фреймов
+     */
+    size_t pendingSize() const;
+
+private:
+    size_t maxMessageSize_; // Максимальный размер сообщения
+    std::vector<uint8_t> buffer_; // Полученные, но еще не разобранные данные
+    size_t offset_ = 0; // Позиция разбора в buffer_
+    std::string message_; // Собираемое сообщение
+    bool inMessage_ = false; // Получен хотя бы один фрейм собираемого сообщения
+    bool lastFrame_ = false; // Текущий фрейм является последним фреймом сообщения
+    size_t frameRemaining_ = 0; // Еще не полученная часть полезной нагрузки текущего фрейма
+    size_t declaredSize_ = 0; // Суммарная длина из заголовков фреймов собираемого сообщения
+    bool failed_ = false; // Превышен максимальный размер сообщения
+
+    /**
+     * @brief Удаление разобранных данных из начала буфера
+     */
+    void compact();
+};
 } // namespace octet::server
@@ -17,8 +17,10 @@ std::filesystem::path getSocketPath(std::optional<std::string> socketPath)
 } // namespace
 
 namespace octet::server {
-Server::Server(StorageManager &storage, std::optional<std::string> socketPath)
+Server::Server(StorageManager &storage, std::optional<std::string> socketPath,
+               size_t maxMessageSize)
     : socketPath_(getSocketPath(socketPath))
+    , maxMessageSize_(maxMessageSize)
     , storage_(storage)
     , running_(false)
 {
@@ -29,9 +31,10 @@ Server::~Server()
     stop();
 }
 
-int Server::startServer(StorageManager &storage, std::optional<std::string> socketPath)
+int Server::startServer(StorageManager &storage, std::optional<std::string> socketPath,
+                        size_t maxMessageSize)
 {
-    Server server(storage, std::move(socketPath));
+    Server server(storage, std::move(socketPath), maxMessageSize);
     return server.start();
 }
 
@@ -147,7 +150,7 @@ void Server::accept()
     }
 
     // Создаем новое соединение
-    auto newConnection = Connection::create(*ioCtx_, storage_);
+    auto newConnection = Connection::create(*ioCtx_, storage_, maxMessageSize_);
 
     // Асинхронно принимаем соединение
     acceptor_->async_accept(newConnection->socket(),
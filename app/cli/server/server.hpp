@@ -16,13 +16,18 @@ class Server {
 public:
     /**
      * @brief Инициализация и запуск сервера
+     * @param storage Ссылка на хранилище
+     * @param socketPath Путь к Unix Domain Socket
+     * @param maxMessageSize Максимальный размер сообщения от клиента
      * @return Код завершения
      */
-    static int startServer(StorageManager &storage, std::optional<std::string> socketPath);
+    static int startServer(StorageManager &storage, std::optional<std::string> socketPath,
+                           size_t maxMessageSize);
 
 private:
     StorageManager &storage_; // Хранилище
     std::filesystem::path socketPath_; // Путь к сокету
+    size_t maxMessageSize_; // Максимальный размер сообщения от клиента
     std::unique_ptr<boost::asio::io_context> ioCtx_; // ASIO контекст
     std::unique_ptr<boost::asio::local::stream_protocol::acceptor> acceptor_; // Ассептор соединений
     std::unique_ptr<boost::asio::signal_set> signalSet_; // Обработчик сигналов завершения
@@ -32,8 +37,9 @@ private:
      * @brief Конструктор сервера
      * @param storagePath Путь к хранилищу
      * @param socketPath Путь к Unix Domain Socket
+     * @param maxMessageSize Максимальный размер сообщения от клиента
      */
-    Server(StorageManager &storage, std::optional<std::string> socketPath);
+    Server(StorageManager &storage, std::optional<std::string> socketPath, size_t maxMessageSize);
 
     /**
      * @brief Деструктор сервера
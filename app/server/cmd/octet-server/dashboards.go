@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Панель дашборда Grafana
+type grafanaPanel struct {
+	Id          int                `json:"id"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Targets     []grafanaTarget    `json:"targets"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	Uid  string `json:"uid"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Запрос PromQL панели
+type grafanaTarget struct {
+	RefId        string            `json:"refId"`
+	Datasource   grafanaDatasource `json:"datasource"`
+	Expr         string            `json:"expr"`
+	LegendFormat string            `json:"legendFormat"`
+	Exemplar     bool              `json:"exemplar"` // Показывать примеры значений со ссылкой на трассу
+}
+
+type grafanaFieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit"`
+	} `json:"defaults"`
+	Overrides []any `json:"overrides"`
+}
+
+// Переменная дашборда: источник данных Prometheus
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaDashboard struct {
+	Uid           string         `json:"uid"`
+	Title         string         `json:"title"`
+	Tags          []string       `json:"tags"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Refresh       string         `json:"refresh"`
+	Time          map[string]any `json:"time"`
+	Templating    struct {
+		List []grafanaVariable `json:"list"`
+	} `json:"templating"`
+	Panels []grafanaPanel `json:"panels"`
+}
+
+// Дашборд RED (rate, errors, duration) по метрикам команд octet из /admin/v1/metrics
+func redDashboard() grafanaDashboard {
+	datasource := grafanaDatasource{Type: "prometheus", Uid: "${datasource}"}
+	dashboard := grafanaDashboard{
+		Uid:           "octet-server-red",
+		Title:         "octet-server: команды octet",
+		Tags:          []string{"octet"},
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          map[string]any{"from": "now-1h", "to": "now"},
+	}
+	dashboard.Templating.List = []grafanaVariable{{
+		Name: "datasource", Label: "Prometheus", Type: "datasource", Query: "prometheus",
+	}}
+
+	panels := []struct {
+		title, description, unit string
+		expr                     string
+		legend                   string
+		exemplar                 bool
+	}{
+		{
+			title: "Команды в секунду", unit: "reqps", legend: "{{command}}",
+			expr: `sum by (command) (rate(octet_backend_commands_total[$__rate_interval]))`,
+		},
+		{
+			title:       "Сбои в секунду",
+			description: "Таймауты, недоступность octet и прочие ошибки (без ошибок, возвращенных octet, например отсутствия строки)",
+			unit:        "reqps", legend: "{{command}}",
+			expr: `sum by (command) (rate(octet_backend_commands_total{result!~"success|backend_error"}[$__rate_interval]))`,
+		},
+		{
+			title: "Длительность команд, p50", unit: "s", legend: "{{command}}", exemplar: true,
+			expr: `histogram_quantile(0.5, sum by (le, command) (rate(octet_backend_command_duration_seconds_bucket[$__rate_interval])))`,
+		},
+		{
+			title: "Длительность команд, p99", unit: "s", legend: "{{command}}", exemplar: true,
+			expr: `histogram_quantile(0.99, sum by (le, command) (rate(octet_backend_command_duration_seconds_bucket[$__rate_interval])))`,
+		},
+		{
+			title: "Размер данных строк, p99", unit: "bytes", legend: "{{command}} {{direction}}",
+			expr: `histogram_quantile(0.99, sum by (le, command, direction) (rate(octet_backend_payload_bytes_bucket[$__rate_interval])))`,
+		},
+		{
+			title: "Таймаут чтения ответа", unit: "s", legend: "{{command}}",
+			expr: `max by (command) (octet_backend_read_timeout_seconds)`,
+		},
+	}
+
+	// Панели располагаются по две в ряд
+	for i, p := range panels {
+		panel := grafanaPanel{
+			Id:          i + 1,
+			Type:        "timeseries",
+			Title:       p.title,
+			Description: p.description,
+			Datasource:  datasource,
+			GridPos:     grafanaGridPos{X: (i % 2) * 12, Y: (i / 2) * 8, W: 12, H: 8},
+		}
+		panel.FieldConfig.Defaults.Unit = p.unit
+		panel.FieldConfig.Overrides = []any{}
+		panel.Targets = []grafanaTarget{{
+			RefId:        "A",
+			Datasource:   datasource,
+			Expr:         p.expr,
+			LegendFormat: p.legend,
+			Exemplar:     p.exemplar,
+		}}
+		dashboard.Panels = append(dashboard.Panels, panel)
+	}
+	return dashboard
+}
+
+// Подкоманда dashboards export: вывод дашборда Grafana по метрикам сервера.
+// Возвращает код завершения процесса.
+func runDashboards(args []string) int {
+	flags := flag.NewFlagSet("dashboards export", flag.ExitOnError)
+	output := flags.String("o", "", "Файл для записи дашборда (по умолчанию - стандартный вывод)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: octet-server dashboards export [-o <файл>]")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "export" {
+		flags.Usage()
+		return 2
+	}
+	flags.Parse(args[1:])
+
+	var w io.Writer = os.Stdout
+	if len(*output) != 0 {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось создать файл дашборда: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(redDashboard()); err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось записать дашборд: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -4,15 +4,12 @@ import (
 	"context"
 	"flag"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"github.com/lildannita/octet-server/internal/api"
 	"github.com/lildannita/octet-server/internal/config"
-	"github.com/lildannita/octet-server/internal/service"
+	"github.com/lildannita/octet-server/server"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -26,7 +23,27 @@ import (
 // @license.name GPL 3.0
 // @license.url https://www.gnu.org/licenses/gpl-3.0.html
 // @BasePath /
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
 func main() {
+	// Подкоманды
+	if len(os.Args) > 1 && os.Args[1] == "verify-backend" {
+		os.Exit(runVerifyBackend(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replicate" {
+		os.Exit(runReplicate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dashboards" {
+		os.Exit(runDashboards(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-layout" {
+		os.Exit(runMigrateLayout(os.Args[2:]))
+	}
+
 	// Парсинг аргументов командной строки
 	configPath := flag.String("config", "", "Путь к файлу конфигурации")
 	logLevel := flag.String("log-level", "info", "Уровень логирования (debug, info, warn, error)")
@@ -61,61 +78,32 @@ func main() {
 		logger.Fatal("Ошибка загрузки конфигурации", zap.Error(err))
 	}
 
-	// Создание и запуск процесса octet
-	procManager := service.NewProcessManager(cfg)
-	if err := procManager.Start(); err != nil {
-		logger.Fatal("Не удалось запустить процесс octet", zap.Error(err))
-	}
-	defer procManager.Stop()
-
-	// Создание клиентского пула соединений
-	clientPool, err := service.NewClientPool(service.ClientPoolConfig{
-		SocketPath:    cfg.SocketPath,
-		MaxClients:    cfg.MaxClients,
-		ConnTimeout:   5 * time.Second,
-		ReadTimeout:   30 * time.Second,
-		WriteTimeout:  30 * time.Second,
-		ClientTimeout: 30 * time.Second,
-	}, logger, procManager)
+	// Создание сервера и запуск процесса octet
+	srv, err := server.New(cfg, server.WithLogger(logger))
 	if err != nil {
-		logger.Fatal("Не удалось создать пул клиентов", zap.Error(err))
+		logger.Fatal("Не удалось запустить сервер", zap.Error(err))
 	}
-	defer clientPool.Close()
 
-	// Создание REST API сервера
-	router := api.NewRouter(api.RouterConfig{
-		ClientPool: clientPool,
-		Logger:     logger,
-	})
-	server := &http.Server{
-		Addr:         cfg.HTTPAddr,
-		Handler:      router,
-		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-
-	// Запуск HTTP сервера в отдельной горутине
-	go func() {
-		logger.Info("Запуск HTTP сервера", zap.String("addr", cfg.HTTPAddr))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatal("Ошибка при запуске HTTP сервера", zap.Error(err))
-		}
-	}()
+	// Диагностические сигналы SIGUSR1/SIGUSR2
+	diagDone := make(chan struct{})
+	defer close(diagDone)
+	go handleDiagnosticSignals(logger, logConfig.Level, srv.ClientPool(), diagDone)
 
 	// Ожидание сигнала для корректного завершения
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-sigChan
-	logger.Info("Получен сигнал завершения", zap.String("signal", sig.String()))
-
-	// Корректное завершение сервера с таймаутом 30 секунд
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	go func() {
+		select {
+		case sig := <-sigChan:
+			logger.Info("Получен сигнал завершения", zap.String("signal", sig.String()))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("Ошибка при корректном завершении HTTP сервера", zap.Error(err))
+	if err := srv.Run(ctx); err != nil {
+		logger.Fatal("Ошибка при работе сервера", zap.Error(err))
 	}
-
-	logger.Info("Сервер успешно завершил работу")
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/protocol"
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Количество UUID, запрашиваемых у octet за одну команду list при проверке переноса
+const migratePageSize = 1000
+
+// Перенос плоского хранилища в разметку по пространствам имен
+type layoutMigration struct {
+	cfg       *config.Config
+	namespace string // Пространство имен, которому передаются строки хранилища
+	socket    string // Сокет временного процесса octet, проверяющего хранилище
+	timeout   time.Duration
+}
+
+// Подкоманда migrate-layout: перенос файлов плоского хранилища в директорию пространства
+// имен по умолчанию с проверкой чтением всех строк до и после переноса.
+// Возвращает код завершения процесса.
+func runMigrateLayout(args []string) int {
+	flags := flag.NewFlagSet("migrate-layout", flag.ExitOnError)
+	configPath := flags.String("config", "", "Путь к файлу конфигурации сервера")
+	dryRun := flags.Bool("dry-run", false, "Вывести план переноса без изменения хранилища")
+	timeout := flags.Duration("timeout", 30*time.Second, "Таймаут одной команды octet при проверке")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: octet-server migrate-layout [-config <путь>] [параметры]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка загрузки конфигурации: %v\n", err)
+		return 1
+	}
+
+	layout, err := config.ReadStorageLayout(cfg.StorageDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if layout.Layout != config.StorageLayoutFlat {
+		fmt.Printf("Хранилище %s уже размечено по пространствам имен, перенос не требуется\n", cfg.StorageDir)
+		return 0
+	}
+
+	m := &layoutMigration{cfg: cfg, namespace: cfg.Namespaces.Default, timeout: *timeout}
+	targetDir := filepath.Join(cfg.StorageDir, m.namespace)
+	if _, err := os.Stat(targetDir); err == nil {
+		fmt.Fprintf(os.Stderr, "Директория %s уже существует\n", targetDir)
+		return 1
+	}
+
+	// Процесс octet сервера не должен работать с хранилищем во время переноса
+	if conn, err := net.DialTimeout("unix", cfg.SocketPath, time.Second); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "На сокете %s работает octet: остановите сервер перед переносом\n", cfg.SocketPath)
+		return 1
+	}
+
+	if *dryRun {
+		files, err := storageFiles(cfg.StorageDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		for _, name := range files {
+			fmt.Printf("%s -> %s\n", filepath.Join(cfg.StorageDir, name), filepath.Join(targetDir, name))
+		}
+		fmt.Printf("Разметка %s: %s\n", filepath.Join(cfg.StorageDir, config.StorageLayoutFile), config.StorageLayoutNamespaces)
+		return 0
+	}
+
+	tmpDir, err := os.MkdirTemp("", "octet-migrate-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось создать временную директорию: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+	m.socket = filepath.Join(tmpDir, "octet.sock")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Строки читаются до переноса, чтобы сравнить с ними перенесенное хранилище
+	fmt.Printf("Чтение строк хранилища %s\n", cfg.StorageDir)
+	before, err := m.readAll(ctx, cfg.StorageDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось прочитать хранилище до переноса: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Прочитано строк: %d\n", len(before))
+
+	// Файлы перечисляются после остановки octet: при работе он мог создать новые
+	files, err := storageFiles(cfg.StorageDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if err := m.move(files, cfg.StorageDir, targetDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось перенести файлы хранилища: %v\n", err)
+		return 1
+	}
+
+	if err := m.verify(ctx, targetDir, before); err != nil {
+		fmt.Fprintf(os.Stderr, "Перенесенное хранилище не прошло проверку: %v\n", err)
+		if err := m.rollback(files, targetDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось вернуть файлы хранилища: %v\n", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "Файлы хранилища возвращены на прежнее место")
+		}
+		return 1
+	}
+
+	// Разметка записывается последней: до этого сервер работает с прежним расположением файлов
+	err = config.WriteStorageLayout(cfg.StorageDir, config.StorageLayout{
+		Layout:     config.StorageLayoutNamespaces,
+		Namespaces: []string{m.namespace},
+		MigratedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		if err := m.rollback(files, targetDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось вернуть файлы хранилища: %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("Хранилище перенесено в %s, проверено строк: %d\n", targetDir, len(before))
+	return 0
+}
+
+// Имена файлов в корне плоского хранилища
+func storageFiles(storageDir string) ([]string, error) {
+	entries, err := os.ReadDir(storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать директорию хранилища: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && entry.Name() != config.StorageLayoutFile {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// Перенос файлов в директорию пространства имен. При ошибке перенесенные файлы возвращаются.
+func (m *layoutMigration) move(files []string, from, to string) error {
+	if err := os.Mkdir(to, 0o755); err != nil {
+		return err
+	}
+	for i, name := range files {
+		if err := os.Rename(filepath.Join(from, name), filepath.Join(to, name)); err != nil {
+			if rollbackErr := m.rollback(files[:i], to); rollbackErr != nil {
+				return errors.Join(err, rollbackErr)
+			}
+			return err
+		}
+		fmt.Printf("%s -> %s\n", filepath.Join(from, name), filepath.Join(to, name))
+	}
+	return nil
+}
+
+// Возврат перенесенных файлов в корень хранилища
+func (m *layoutMigration) rollback(files []string, targetDir string) error {
+	for _, name := range files {
+		if err := os.Rename(filepath.Join(targetDir, name), filepath.Join(m.cfg.StorageDir, name)); err != nil {
+			return err
+		}
+	}
+	// Файлы, созданные octet при проверке, удаляются вместе с директорией
+	return os.RemoveAll(targetDir)
+}
+
+// Проверка перенесенного хранилища: те же строки с теми же данными
+func (m *layoutMigration) verify(ctx context.Context, dir string, before map[string][sha256.Size]byte) error {
+	fmt.Printf("Проверка хранилища %s\n", dir)
+	after, err := m.readAll(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if len(after) != len(before) {
+		return fmt.Errorf("количество строк изменилось: %d вместо %d", len(after), len(before))
+	}
+	for uuid, digest := range before {
+		if got, ok := after[uuid]; !ok {
+			return fmt.Errorf("строка %s не найдена", uuid)
+		} else if got != digest {
+			return fmt.Errorf("данные строки %s изменились", uuid)
+		}
+	}
+	return nil
+}
+
+// Чтение всех строк хранилища временным процессом octet. Возвращает SHA-256 данных каждой строки.
+func (m *layoutMigration) readAll(ctx context.Context, dir string) (map[string][sha256.Size]byte, error) {
+	procManager := service.NewProcessManager(&config.Config{
+		OctetPath:  m.cfg.OctetPath,
+		StorageDir: dir,
+		SocketPath: m.socket,
+	})
+	if err := procManager.Start(); err != nil {
+		return nil, fmt.Errorf("не удалось запустить octet: %w", err)
+	}
+	defer procManager.Stop()
+
+	maxFrameSize := m.cfg.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = protocol.DefaultMaxFrameSize
+	}
+	client, err := service.NewClient(service.ClientConfig{
+		SocketPath:   m.socket,
+		ConnTimeout:  5 * time.Second,
+		ReadTimeout:  m.timeout,
+		WriteTimeout: m.timeout,
+		MaxFrameSize: maxFrameSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	digests := make(map[string][sha256.Size]byte)
+	for after, more := "", true; more; {
+		var uuids []string
+		if uuids, more, err = client.List(ctx, after, migratePageSize); err != nil {
+			return nil, err
+		}
+		for _, uuid := range uuids {
+			data, err := client.Get(ctx, uuid)
+			if err != nil {
+				return nil, fmt.Errorf("не удалось прочитать строку %s: %w", uuid, err)
+			}
+			digests[uuid] = sha256.Sum256([]byte(data))
+		}
+		if len(uuids) == 0 {
+			break
+		}
+		after = uuids[len(uuids)-1]
+	}
+
+	// Количество строк по статистике octet должно совпадать с перечисленными
+	count, err := client.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if count != uint64(len(digests)) {
+		return nil, fmt.Errorf("octet::stats сообщает %d строк, перечислено %d", count, len(digests))
+	}
+	return digests, nil
+}
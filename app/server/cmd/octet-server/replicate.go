@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Количество записей журнала источника, применяемых между сохранениями состояния
+const replicatePageSize = 1000
+
+// Состояние репликации, сохраняемое между запусками
+type replicationState struct {
+	Since string `json:"since"` // Метка времени последней примененной записи журнала источника
+	Skip  int    `json:"skip"`  // Количество примененных записей с этой меткой
+	// Соответствие UUID строк источника UUID строк приемника
+	// (octet сам выбирает UUID добавляемых строк, поэтому они различаются)
+	Uuids map[string]string `json:"uuids"`
+}
+
+// Репликация изменений одного сервера на другой через HTTP API
+type replicator struct {
+	from, to       string
+	fromKey, toKey string
+	statePath      string
+	state          replicationState
+	client         *http.Client
+}
+
+// Подкоманда replicate: непрерывное применение журнала операций одного сервера к другому.
+// Возвращает код завершения процесса.
+func runReplicate(args []string) int {
+	flags := flag.NewFlagSet("replicate", flag.ExitOnError)
+	from := flags.String("from", "", "Адрес сервера-источника (например, http://source:8080)")
+	to := flags.String("to", "", "Адрес сервера-приемника")
+	fromKey := flags.String("from-key", "", "API-ключ источника с доступом к административному API")
+	toKey := flags.String("to-key", "", "API-ключ приемника с доступом на запись")
+	statePath := flags.String("state", "replicate.state.json", "Файл состояния репликации")
+	interval := flags.Duration("interval", time.Second, "Интервал опроса журнала источника")
+	timeout := flags.Duration("timeout", 30*time.Second, "Таймаут одного HTTP запроса")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: octet-server replicate --from <url> --to <url> [параметры]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if len(*from) == 0 || len(*to) == 0 || *interval <= 0 {
+		flags.Usage()
+		return 2
+	}
+
+	r := &replicator{
+		from:      strings.TrimSuffix(*from, "/"),
+		to:        strings.TrimSuffix(*to, "/"),
+		fromKey:   *fromKey,
+		toKey:     *toKey,
+		statePath: *statePath,
+		client:    &http.Client{Timeout: *timeout},
+	}
+	if err := r.loadState(); err != nil {
+		fmt.Fprintf(os.Stderr, "Не удалось загрузить состояние репликации: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Репликация %s -> %s с позиции %q\n", r.from, r.to, r.state.Since)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		// Страницы журнала применяются без ожидания, пока источник не вернет неполную страницу
+		for {
+			applied, err := r.poll(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return 0
+				}
+				fmt.Fprintf(os.Stderr, "Ошибка репликации, повтор через %v: %v\n", *interval, err)
+				break
+			}
+			if applied != 0 {
+				fmt.Printf("Применено записей: %d, позиция %q\n", applied, r.state.Since)
+			}
+			if applied < replicatePageSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+	}
+}
+
+// Чтение и применение одной страницы журнала источника. Возвращает количество примененных записей.
+func (r *replicator) poll(ctx context.Context) (int, error) {
+	query := url.Values{}
+	if len(r.state.Since) != 0 {
+		query.Set("since", r.state.Since)
+	}
+	// Записи с меткой since, примененные ранее, возвращаются повторно
+	query.Set("limit", fmt.Sprint(r.state.Skip+replicatePageSize))
+
+	resp, err := r.do(ctx, r.from, r.fromKey, http.MethodGet, "/admin/v1/journal?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError("чтение журнала источника", resp)
+	}
+
+	var entries []protocol.JournalEntry
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var entry protocol.JournalEntry
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return 0, fmt.Errorf("некорректная запись журнала источника: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// Записи с меткой since, примененные при предыдущем опросе, пропускаются
+	skipped := 0
+	for skipped < len(entries) && skipped < r.state.Skip && entries[skipped].Time == r.state.Since {
+		skipped++
+	}
+
+	for _, entry := range entries[skipped:] {
+		if err := r.apply(ctx, entry); err != nil {
+			// Позиция сохраняется до неудавшейся записи, чтобы повторить ее при следующем опросе
+			if saveErr := r.saveState(); saveErr != nil {
+				return 0, saveErr
+			}
+			return 0, err
+		}
+		if entry.Time == r.state.Since {
+			r.state.Skip++
+		} else {
+			r.state.Since, r.state.Skip = entry.Time, 1
+		}
+		// Соответствие UUID добавленной строки сохраняется сразу, чтобы не добавить ее повторно после сбоя
+		if entry.Op == "insert" {
+			if err := r.saveState(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := r.saveState(); err != nil {
+		return 0, err
+	}
+	return len(entries) - skipped, nil
+}
+
+// Применение записи журнала источника к приемнику
+func (r *replicator) apply(ctx context.Context, entry protocol.JournalEntry) error {
+	switch entry.Op {
+	case "insert":
+		return r.insert(ctx, entry)
+	case "update":
+		target, ok := r.state.Uuids[entry.Uuid]
+		if !ok {
+			// Строка добавлена до начала журнала источника (до сжатия) и на приемнике отсутствует
+			return r.insert(ctx, entry)
+		}
+		return r.send(ctx, http.MethodPut, "/octet/v1/"+target, entry.Data, http.StatusOK, http.StatusNoContent)
+	case "remove":
+		target, ok := r.state.Uuids[entry.Uuid]
+		if !ok {
+			return nil
+		}
+		// Строка, уже отсутствующая на приемнике, считается удаленной
+		if err := r.send(ctx, http.MethodDelete, "/octet/v1/"+target, "",
+			http.StatusOK, http.StatusNoContent, http.StatusAccepted, http.StatusNotFound); err != nil {
+			return err
+		}
+		delete(r.state.Uuids, entry.Uuid)
+		return nil
+	case "expire":
+		// Срок хранения не переносится: по его истечении источник удаляет строку, и удаление применяется как remove
+		return nil
+	case "checkpoint":
+		// Записи, удаленные из журнала источника при сжатии до их применения, восстановить нельзя
+		fmt.Printf("Источник выполнил сжатие хранилища (%s): изменения, не примененные до сжатия, потеряны\n", entry.Time)
+		return nil
+	default:
+		return fmt.Errorf("неизвестная операция журнала источника: %q", entry.Op)
+	}
+}
+
+// Добавление строки на приемник с запоминанием ее UUID
+func (r *replicator) insert(ctx context.Context, entry protocol.JournalEntry) error {
+	body, err := json.Marshal(map[string]string{"data": entry.Data})
+	if err != nil {
+		return err
+	}
+	resp, err := r.do(ctx, r.to, r.toKey, http.MethodPost, "/octet/v1/", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return responseError("добавление строки на приемник", resp)
+	}
+	var created struct {
+		Uuid string `json:"uuid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil || len(created.Uuid) == 0 {
+		return fmt.Errorf("приемник не вернул UUID добавленной строки")
+	}
+	r.state.Uuids[entry.Uuid] = created.Uuid
+	return nil
+}
+
+// Отправка изменения на приемник с проверкой кода ответа
+func (r *replicator) send(ctx context.Context, method, path, data string, expected ...int) error {
+	var body []byte
+	if method != http.MethodDelete {
+		var err error
+		if body, err = json.Marshal(map[string]string{"data": data}); err != nil {
+			return err
+		}
+	}
+	resp, err := r.do(ctx, r.to, r.toKey, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	return responseError(method+" "+path+" на приемнике", resp)
+}
+
+// Выполнение HTTP запроса к серверу
+func (r *replicator) do(ctx context.Context, base, key, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(key) != 0 {
+		req.Header.Set("X-API-Key", key)
+	}
+	return r.client.Do(req)
+}
+
+// Ошибка по неожиданному ответу сервера
+func responseError(action string, resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s: %s: %s", action, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// Чтение состояния репликации (отсутствующий файл - репликация с начала журнала)
+func (r *replicator) loadState() error {
+	r.state = replicationState{Uuids: make(map[string]string)}
+	content, err := os.ReadFile(r.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &r.state); err != nil {
+		return err
+	}
+	if r.state.Uuids == nil {
+		r.state.Uuids = make(map[string]string)
+	}
+	return nil
+}
+
+// Атомарная запись состояния репликации
+func (r *replicator) saveState() error {
+	content, err := json.Marshal(&r.state)
+	if err != nil {
+		return err
+	}
+	tmpPath := r.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return fmt.Errorf("не удалось сохранить состояние репликации: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.statePath); err != nil {
+		return fmt.Errorf("не удалось сохранить состояние репликации: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Обработка сигналов для диагностики без доступа к административному API:
+// SIGUSR1 - вывод стеков горутин и состояния пула в лог,
+// SIGUSR2 - переключение уровня логирования между debug и исходным.
+// Функция завершается при закрытии done.
+func handleDiagnosticSignals(logger *zap.Logger, level zap.AtomicLevel, pool *service.ClientPool, done <-chan struct{}) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigChan)
+
+	initialLevel := level.Level()
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-sigChan:
+			switch sig {
+			case syscall.SIGUSR1:
+				logger.Info("Диагностический снимок по сигналу SIGUSR1",
+					zap.Int("goroutines", runtime.NumGoroutine()),
+					zap.Any("pool", pool.Stats()),
+					zap.String("stacks", goroutineStacks()))
+			case syscall.SIGUSR2:
+				newLevel := zapcore.DebugLevel
+				if level.Level() == zapcore.DebugLevel {
+					newLevel = initialLevel
+				}
+				level.SetLevel(newLevel)
+				logger.Warn("Уровень логирования изменен по сигналу SIGUSR2", zap.Stringer("level", newLevel))
+			}
+		}
+	}
+}
+
+// Стеки всех горутин
+func goroutineStacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
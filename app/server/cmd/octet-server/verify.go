@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/protocoltest"
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Подкоманда verify-backend: проверка соответствия backend протоколу octet.
+// Возвращает код завершения процесса.
+func runVerifyBackend(args []string) int {
+	flags := flag.NewFlagSet("verify-backend", flag.ExitOnError)
+	socketPath := flags.String("socket", "", "Путь к сокету уже запущенного backend")
+	octetPath := flags.String("octet", "", "Путь к исполняемому файлу octet, который будет запущен с временным хранилищем")
+	useFake := flags.Bool("fake", false, "Проверить встроенный фейковый backend")
+	timeout := flags.Duration("timeout", 10*time.Second, "Таймаут одной проверки")
+	largeSize := flags.Int("large-size", 256<<10, "Размер данных для проверки больших сообщений (в байтах)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Использование: octet-server verify-backend (-socket <путь> | -octet <путь> | -fake) [параметры]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	selected := 0
+	for _, set := range []bool{len(*socketPath) != 0, len(*octetPath) != 0, *useFake} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Подготавливаем проверяемый backend
+	switch {
+	case len(*octetPath) != 0 || *useFake:
+		tmpDir, err := os.MkdirTemp("", "octet-verify-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Не удалось создать временную директорию: %v\n", err)
+			return 1
+		}
+		defer os.RemoveAll(tmpDir)
+		*socketPath = filepath.Join(tmpDir, "octet.sock")
+
+		if *useFake {
+			backend, err := protocoltest.NewFakeBackend(*socketPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Не удалось запустить фейковый backend: %v\n", err)
+				return 1
+			}
+			defer backend.Close()
+		} else {
+			procManager := service.NewProcessManager(&config.Config{
+				OctetPath:  *octetPath,
+				StorageDir: filepath.Join(tmpDir, "storage"),
+				SocketPath: *socketPath,
+			})
+			if err := procManager.Start(); err != nil {
+				fmt.Fprintf(os.Stderr, "Не удалось запустить octet: %v\n", err)
+				return 1
+			}
+			defer procManager.Stop()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Выполняем проверки и выводим отчет
+	fmt.Printf("Проверка backend на сокете %s\n", *socketPath)
+	results := protocoltest.Run(ctx, *socketPath, protocoltest.Options{
+		Timeout:          *timeout,
+		LargePayloadSize: *largeSize,
+	})
+
+	passed := 0
+	for _, result := range results {
+		duration := result.Duration.Round(time.Microsecond)
+		if result.Passed() {
+			passed++
+			fmt.Printf("[PASS] %-16s %-10v %s\n", result.Name, duration, result.Description)
+		} else {
+			fmt.Printf("[FAIL] %-16s %-10v %s\n       %v\n", result.Name, duration, result.Description, result.Err)
+		}
+	}
+	fmt.Printf("Пройдено проверок: %d из %d\n", passed, len(results))
+
+	if passed != len(results) {
+		return 1
+	}
+	return 0
+}
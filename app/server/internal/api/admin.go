@@ -0,0 +1,341 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Информация об API-ключе (без значения ключа)
+type APIKeyInfo struct {
+	Name       string       `json:"name"`
+	Scopes     []auth.Scope `json:"scopes"`
+	Namespaces []string     `json:"namespaces,omitempty"`
+	CreatedAt  string       `json:"created_at,omitempty"`
+	Static     bool         `json:"static"`
+}
+
+// Список API-ключей
+type APIKeyListResponse struct {
+	Keys []APIKeyInfo `json:"keys"`
+}
+
+// Запрос на выпуск нового API-ключа
+type APIKeyCreateRequest struct {
+	Name   string       `json:"name"`
+	Scopes []auth.Scope `json:"scopes"`
+	// Пространства имен, строки которых доступны ключу (пусто - все пространства имен)
+	Namespaces []string `json:"namespaces"`
+}
+
+// Ответ с выпущенным API-ключом (значение ключа возвращается только один раз)
+type APIKeyCreateResponse struct {
+	APIKeyInfo
+	Key string `json:"key"`
+}
+
+// Запрос на изменение областей доступа API-ключа. Пространства имен заменяются так же, как области доступа:
+// если они не указаны, ключу становятся доступны все пространства имен.
+type APIKeyUpdateRequest struct {
+	Scopes     []auth.Scope `json:"scopes"`
+	Namespaces []string     `json:"namespaces"`
+}
+
+// Преобразование ключа в информацию для ответа
+func newAPIKeyInfo(key auth.APIKey) APIKeyInfo {
+	info := APIKeyInfo{
+		Name:       key.Name,
+		Scopes:     key.Scopes,
+		Namespaces: key.Namespaces,
+		Static:     key.Static,
+	}
+	if !key.CreatedAt.IsZero() {
+		info.CreatedAt = key.CreatedAt.Format(time.RFC3339)
+	}
+	return info
+}
+
+// ListAPIKeys godoc
+// @Summary Список API-ключей
+// @Description Получение списка API-ключей (без значений ключей)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} APIKeyListResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Router /admin/v1/apikeys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys := h.keyStore.List()
+	response := APIKeyListResponse{Keys: make([]APIKeyInfo, 0, len(keys))}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, newAPIKeyInfo(key))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// CreateAPIKey godoc
+// @Summary Выпуск API-ключа
+// @Description Выпуск нового API-ключа. Значение ключа возвращается только в этом ответе.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body APIKeyCreateRequest true "Имя, области доступа и пространства имен ключа"
+// @Success 201 {object} APIKeyCreateResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Router /admin/v1/apikeys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	// Разбираем запрос
+	var createReq APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Проверяем данные
+	if len(createReq.Name) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'name' не может быть пустым")
+		return
+	}
+	if len(createReq.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'scopes' не может быть пустым")
+		return
+	}
+	if !h.checkKeyNamespaces(w, createReq.Namespaces) {
+		return
+	}
+
+	// Выпускаем ключ
+	value, key, err := h.keyStore.Create(createReq.Name, createReq.Scopes, createReq.Namespaces)
+	if err != nil {
+		h.respondWithKeyStoreError(w, err)
+		return
+	}
+
+	h.logger.Info("Выпущен API-ключ", zap.String("name", key.Name), zap.Any("scopes", key.Scopes),
+		zap.Strings("namespaces", key.Namespaces))
+	respondWithJSON(w, http.StatusCreated, APIKeyCreateResponse{
+		APIKeyInfo: newAPIKeyInfo(key),
+		Key:        value,
+	})
+}
+
+// UpdateAPIKey godoc
+// @Summary Изменение API-ключа
+// @Description Изменение областей доступа и пространств имен выпущенного API-ключа
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя ключа"
+// @Param key body APIKeyUpdateRequest true "Новые области доступа и пространства имен"
+// @Success 200 {object} APIKeyInfo
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Router /admin/v1/apikeys/{name} [put]
+func (h *Handler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// Разбираем запрос
+	var updateReq APIKeyUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+	if len(updateReq.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'scopes' не может быть пустым")
+		return
+	}
+	if !h.checkKeyNamespaces(w, updateReq.Namespaces) {
+		return
+	}
+
+	// Обновляем ключ
+	key, err := h.keyStore.Update(name, updateReq.Scopes, updateReq.Namespaces)
+	if err != nil {
+		h.respondWithKeyStoreError(w, err)
+		return
+	}
+
+	h.logger.Info("Изменен API-ключ", zap.String("name", key.Name), zap.Any("scopes", key.Scopes),
+		zap.Strings("namespaces", key.Namespaces))
+	respondWithJSON(w, http.StatusOK, newAPIKeyInfo(key))
+}
+
+// DeleteAPIKey godoc
+// @Summary Отзыв API-ключа
+// @Description Отзыв выпущенного API-ключа
+// @Tags admin
+// @Param name path string true "Имя ключа"
+// @Success 204
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Router /admin/v1/apikeys/{name} [delete]
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if err := h.keyStore.Delete(name); err != nil {
+		h.respondWithKeyStoreError(w, err)
+		return
+	}
+
+	h.logger.Info("Отозван API-ключ", zap.String("name", name))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Проверка существования пространств имен API-ключа
+func (h *Handler) checkKeyNamespaces(w http.ResponseWriter, namespaces []string) bool {
+	for _, namespace := range namespaces {
+		// Хранилище octet пока содержит одно пространство имен
+		if namespace != h.namespaces.Default {
+			respondWithJSON(w, http.StatusBadRequest, ErrorHeader{
+				Error: fmt.Sprintf("Пространство имен '%s' не найдено", namespace),
+				Code:  ErrorCodeNamespaceNotFound,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// Отправка ответа с ошибкой хранилища API-ключей
+func (h *Handler) respondWithKeyStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, auth.ErrKeyExists), errors.Is(err, auth.ErrStaticKey):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, auth.ErrInvalidKey):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Ошибка хранилища API-ключей", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Ошибка хранилища API-ключей")
+	}
+}
+
+// Записанный обмен сообщениями с octet
+type TraceResponse struct {
+	Entries []service.TraceEntry `json:"entries"`
+}
+
+// Trace godoc
+// @Summary Трассировка обмена с octet
+// @Description Получение последних сообщений, отправленных octet и полученных от него (от старых к новым)
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Количество последних сообщений"
+// @Success 200 {object} TraceResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/trace [get]
+func (h *Handler) Trace(w http.ResponseWriter, r *http.Request) {
+	entries := h.recorder.Entries()
+
+	// Ограничиваем количество сообщений, если указан limit
+	if value := r.URL.Query().Get("limit"); len(value) != 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'limit' должен быть неотрицательным числом")
+			return
+		}
+		if limit < len(entries) {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, TraceResponse{Entries: entries})
+}
+
+// GetFaults godoc
+// @Summary Параметры внедрения сбоев
+// @Description Получение текущих параметров внедряемых сбоев
+// @Tags admin
+// @Produce json
+// @Success 200 {object} service.FaultSettings
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/faults [get]
+func (h *Handler) GetFaults(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.faults.Settings())
+}
+
+// SetFaults godoc
+// @Summary Изменение параметров внедрения сбоев
+// @Description Установка задержек, потери запросов и разрывов соединений с octet
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param settings body service.FaultSettings true "Параметры сбоев"
+// @Success 200 {object} service.FaultSettings
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/faults [put]
+func (h *Handler) SetFaults(w http.ResponseWriter, r *http.Request) {
+	// Разбираем запрос
+	var settings service.FaultSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if err := h.faults.SetSettings(settings); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.logger.Warn("Изменены параметры внедрения сбоев", zap.Any("settings", settings))
+	respondWithJSON(w, http.StatusOK, settings)
+}
+
+// KillBackend godoc
+// @Summary Аварийное завершение octet
+// @Description Принудительное завершение процесса octet сигналом SIGKILL для проверки устойчивости
+// @Tags admin
+// @Success 204
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/faults/kill [post]
+func (h *Handler) KillBackend(w http.ResponseWriter, r *http.Request) {
+	if err := h.faults.KillBackend(); err != nil {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.logger.Warn("Процесс octet принудительно завершен через административный API")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Имя клиента в учете трафика, если аутентификация отключена
+const anonymousIdentity = "anonymous"
+
+// Ограничение скорости передачи данных (token bucket).
+// Запас может уйти в минус, чтобы большие фрагменты передавались без дробления,
+// а следующая передача ожидала восполнения запаса.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // Байт в секунду
+	burst  float64 // Максимальный запас
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int64) *tokenBucket {
+	return &tokenBucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Списание n байт с ожиданием, пока запас не станет неотрицательным
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	b.mutex.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mutex.Unlock()
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Трафик одного клиента
+type identityBandwidth struct {
+	bytesIn  atomic.Uint64 // Прочитано из тел запросов
+	bytesOut atomic.Uint64 // Записано в тела ответов
+	in, out  *tokenBucket  // Ограничения скорости (nil - без ограничения)
+}
+
+// Учет и ограничение трафика тел запросов и ответов по клиентам (API-ключам или субъектам OIDC)
+type Bandwidth struct {
+	config     config.BandwidthConfig
+	mutex      sync.Mutex
+	identities map[string]*identityBandwidth
+}
+
+// Создание учета трафика
+func NewBandwidth(config config.BandwidthConfig) *Bandwidth {
+	return &Bandwidth{config: config, identities: make(map[string]*identityBandwidth)}
+}
+
+// Трафик клиента (создается при первом запросе)
+func (b *Bandwidth) identity(name string) *identityBandwidth {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stats, ok := b.identities[name]
+	if !ok {
+		stats = &identityBandwidth{}
+		if b.config.InBytesPerSecond > 0 {
+			stats.in = newTokenBucket(b.config.InBytesPerSecond, b.config.BurstBytes)
+		}
+		if b.config.OutBytesPerSecond > 0 {
+			stats.out = newTokenBucket(b.config.OutBytesPerSecond, b.config.BurstBytes)
+		}
+		b.identities[name] = stats
+	}
+	return stats
+}
+
+// Запись счетчиков трафика в текстовом формате Prometheus (или OpenMetrics)
+func (b *Bandwidth) WritePrometheus(w io.Writer, openMetrics bool) error {
+	b.mutex.Lock()
+	names := make([]string, 0, len(b.identities))
+	for name := range b.identities {
+		names = append(names, name)
+	}
+	b.mutex.Unlock()
+	sort.Strings(names)
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	family := "octet_http_body_bytes_total"
+	if openMetrics {
+		family = "octet_http_body_bytes"
+	}
+	printf("# HELP %s Размер тел запросов (in) и ответов (out) по клиентам.\n", family)
+	printf("# TYPE %s counter\n", family)
+	for _, name := range names {
+		stats := b.identity(name)
+		printf("octet_http_body_bytes_total{identity=%q,direction=\"in\"} %d\n", name, stats.bytesIn.Load())
+		printf("octet_http_body_bytes_total{identity=%q,direction=\"out\"} %d\n", name, stats.bytesOut.Load())
+	}
+	return err
+}
+
+// Тело запроса с учетом прочитанных байт
+type bandwidthReader struct {
+	io.ReadCloser
+	ctx   context.Context
+	stats *identityBandwidth
+}
+
+func (r *bandwidthReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.stats.bytesIn.Add(uint64(n))
+		if r.stats.in != nil {
+			if waitErr := r.stats.in.wait(r.ctx, n); waitErr != nil && err == nil {
+				err = waitErr
+			}
+		}
+	}
+	return n, err
+}
+
+// Обертка ResponseWriter с учетом записанных байт
+type bandwidthWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	stats *identityBandwidth
+	burst int
+}
+
+func (w *bandwidthWriter) Write(b []byte) (int, error) {
+	if w.stats.out == nil {
+		n, err := w.ResponseWriter.Write(b)
+		w.stats.bytesOut.Add(uint64(n))
+		return n, err
+	}
+
+	// Ответ передается частями не больше запаса, чтобы скорость ограничивалась и внутри одной записи
+	written := 0
+	for written < len(b) {
+		chunk := b[written:min(len(b), written+w.burst)]
+		if err := w.stats.out.wait(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		w.stats.bytesOut.Add(uint64(n))
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (w *bandwidthWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Доступ к исходному ResponseWriter для http.ResponseController
+func (w *bandwidthWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Слой для учета и ограничения трафика тел запросов и ответов по клиентам.
+// Должен следовать за аутентификацией, чтобы клиент был известен.
+func BandwidthMiddleware(bandwidth *Bandwidth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := anonymousIdentity
+			if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+				name = identity.Name
+			}
+			stats := bandwidth.identity(name)
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &bandwidthReader{ReadCloser: r.Body, ctx: r.Context(), stats: stats}
+			}
+			next.ServeHTTP(&bandwidthWriter{
+				ResponseWriter: w,
+				ctx:            r.Context(),
+				stats:          stats,
+				burst:          int(bandwidth.config.BurstBytes),
+			}, r)
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Операции пакетного запроса
+const (
+	BatchOpInsert = "insert"
+	BatchOpUpdate = "update"
+	BatchOpRemove = "remove"
+)
+
+// Операция пакетного запроса
+type BatchItem struct {
+	Op   string `json:"op"`             // insert, update или remove
+	Uuid string `json:"uuid,omitempty"` // Для update и remove
+	Data string `json:"data,omitempty"` // Для insert и update
+	// Срок хранения строки в секундах (для insert, 0 - без ограничения)
+	TtlSeconds int64 `json:"ttl_seconds,omitempty"`
+}
+
+// Пакетный запрос
+type BatchRequest struct {
+	Items []BatchItem `json:"items"`
+}
+
+// Результат выполнения операции пакета
+type BatchItemResult struct {
+	Status int    `json:"status"`         // Код ответа, который вернул бы одиночный запрос
+	Uuid   string `json:"uuid,omitempty"` // UUID добавленной строки (для insert)
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"` // Машиночитаемый код ошибки взаимодействия с octet
+	// Номер изменения (для пространств имен с упорядоченной записью)
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// Результаты пакетного запроса в порядке операций
+type BatchResponse struct {
+	Results []BatchItemResult `json:"results"`
+}
+
+// Ошибка в поле операции пакета
+type BatchItemError struct {
+	Index int    `json:"index"` // Номер операции в пакете (с 0)
+	Field string `json:"field"` // op, uuid, data или ttl_seconds
+	Error string `json:"error"`
+}
+
+// Ответ об отклонении пакета с некорректными операциями
+type BatchValidationError struct {
+	ErrorHeader
+	Items []BatchItemError `json:"items"` // Ошибки всех некорректных операций
+}
+
+// Batch godoc
+// @Summary Пакетное изменение строк
+// @Description Выполнение нескольких операций insert/update/remove одним запросом.
+// @Description Пакет делится на части, выполняемые параллельно на ограниченном числе соединений с octet.
+// @Description Операции не образуют транзакцию: результат каждой операции возвращается отдельно.
+// @Description Пакет проверяется целиком до выполнения: при некорректных операциях возвращается 422
+// @Description со списком ошибок всех таких операций, и ни одна операция не выполняется.
+// @Tags strings
+// @Accept json
+// @Produce json
+// @Param request body BatchRequest true "Операции пакета"
+// @Success 200 {object} BatchResponse
+// @Header 200 {string} X-Octet-Warning "Коды предупреждений о приближении к ограничениям"
+// @Header 200 {integer} X-Octet-Batch-Remaining "Сколько операций еще можно добавить в пакет"
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 413 {object} ErrorHeader
+// @Failure 422 {object} BatchValidationError
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/batch [post]
+func (h *Handler) Batch(w http.ResponseWriter, r *http.Request) {
+	var batchReq BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&batchReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Проверяем пакет целиком до выполнения операций
+	if len(batchReq.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Пакет не содержит операций")
+		return
+	}
+	if len(batchReq.Items) > h.batch.MaxItems {
+		respondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Количество операций в пакете (%d) превышает допустимое (%d)", len(batchReq.Items), h.batch.MaxItems))
+		return
+	}
+	var itemErrors []BatchItemError
+	for i, item := range batchReq.Items {
+		itemErrors = append(itemErrors, validateBatchItem(i, item)...)
+	}
+	if len(itemErrors) != 0 {
+		respondWithJSON(w, http.StatusUnprocessableEntity, BatchValidationError{
+			ErrorHeader: ErrorHeader{
+				Error: fmt.Sprintf("Пакет содержит некорректные операции (ошибок: %d)", len(itemErrors)),
+				Code:  ErrorCodeInvalidBatch,
+			},
+			Items: itemErrors,
+		})
+		return
+	}
+
+	results := h.runBatch(r.Context(), batchReq.Items, h.namespace(r))
+	h.warnBatchLimit(w, len(batchReq.Items))
+	respondWithJSON(w, http.StatusOK, BatchResponse{Results: results})
+}
+
+// Проверка полей операции пакета
+func validateBatchItem(index int, item BatchItem) []BatchItemError {
+	var errs []BatchItemError
+	fail := func(field, message string) {
+		errs = append(errs, BatchItemError{Index: index, Field: field, Error: message})
+	}
+
+	var needUuid, needData bool
+	switch item.Op {
+	case BatchOpInsert:
+		needData = true
+	case BatchOpUpdate:
+		needUuid, needData = true, true
+	case BatchOpRemove:
+		needUuid = true
+	default:
+		fail("op", fmt.Sprintf("неизвестная операция '%s'", item.Op))
+		return errs
+	}
+	if needUuid {
+		if len(item.Uuid) == 0 {
+			fail("uuid", "поле 'uuid' не может быть пустым")
+		} else if !isCanonicalUUID(item.Uuid) {
+			fail("uuid", fmt.Sprintf("'%s' не является UUID", item.Uuid))
+		}
+	}
+	if needData && len(item.Data) == 0 {
+		fail("data", "поле 'data' не может быть пустым")
+	}
+	if item.TtlSeconds != 0 && item.Op != BatchOpInsert {
+		fail("ttl_seconds", "поле 'ttl_seconds' допустимо только для insert")
+	} else if item.TtlSeconds < 0 || item.TtlSeconds > maxTtlSeconds {
+		fail("ttl_seconds", fmt.Sprintf("поле 'ttl_seconds' должно быть от 0 до %d", maxTtlSeconds))
+	}
+	return errs
+}
+
+// Является ли строка UUID в каноническом виде (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx), который использует octet
+func isCanonicalUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+// Выполнение операций пакета в пространстве имен частями на ограниченном числе клиентов пула
+func (h *Handler) runBatch(ctx context.Context, items []BatchItem, namespace string) []BatchItemResult {
+	results := make([]BatchItemResult, len(items))
+	if h.orderedFor(namespace) != nil {
+		// Операции ставятся в очередь упорядоченной записи в порядке пакета
+		h.runBatchChunk(ctx, items, results, namespace)
+		return results
+	}
+	h.forEachChunk(len(items), func(start, end int) {
+		h.runBatchChunk(ctx, items[start:end], results[start:end], namespace)
+	})
+	return results
+}
+
+// Параллельное выполнение run для частей [start, end) из count элементов
+// на ограниченном числе горутин (batch.max_parallel)
+func (h *Handler) forEachChunk(count int, run func(start, end int)) {
+	parallel := h.batch.MaxParallel
+	if parallel == 0 {
+		parallel = max(1, h.clientPool.Stats().Size/2)
+	}
+
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, (count+h.batch.ChunkSize-1)/h.batch.ChunkSize) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				run(start, min(start+h.batch.ChunkSize, count))
+			}
+		}()
+	}
+	for start := 0; start < count; start += h.batch.ChunkSize {
+		chunks <- start
+	}
+	close(chunks)
+	wg.Wait()
+}
+
+// Выполнение части пакета на одном клиенте пула (в пространстве имен с упорядоченной записью - в очереди)
+func (h *Handler) runBatchChunk(ctx context.Context, items []BatchItem, results []BatchItemResult, namespace string) {
+	ordered := h.orderedFor(namespace)
+	var client *service.PooledClient
+	var err error
+	if ordered == nil {
+		if client, err = h.clientPool.GetClientFor(service.PoolClassWrite); err != nil {
+			h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+			for i := range results {
+				results[i] = batchErrorResult(err)
+			}
+			return
+		}
+		defer client.Release()
+	}
+
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			results[i] = batchErrorResult(err)
+			continue
+		}
+		if item.Op != BatchOpInsert && h.namespaces.IsImmutable(namespace) {
+			response := immutableError(namespace)
+			results[i] = BatchItemResult{Status: http.StatusMethodNotAllowed, Error: response.Error, Code: response.Code}
+			continue
+		}
+		if item.Op != BatchOpInsert && h.tombstones.Contains(item.Uuid) {
+			results[i] = batchErrorResult(service.ErrNotFound)
+			continue
+		}
+
+		if item.Op != BatchOpRemove {
+			if err = h.checkReservedValue(item.Data, false); err != nil {
+				results[i] = batchErrorResult(err)
+				continue
+			}
+			if item.Data, err = h.validateValue(ctx, item.Op, namespace, item.Uuid, item.Data); err != nil {
+				results[i] = batchErrorResult(err)
+				continue
+			}
+		}
+
+		if ordered != nil {
+			results[i] = h.runOrderedBatchItem(ctx, ordered, item)
+			continue
+		}
+
+		// Клиент возвращается в пул после всей части, поэтому вызываются методы *service.Client
+		switch item.Op {
+		case BatchOpInsert:
+			var uuid string
+			if uuid, err = client.Client.InsertWithTTL(ctx, item.Data, uint64(item.TtlSeconds)); err == nil {
+				h.publishChange(ctx, events.OpInsert, uuid)
+				results[i] = BatchItemResult{Status: http.StatusCreated, Uuid: uuid}
+			}
+		case BatchOpUpdate:
+			if err = client.Client.Update(ctx, item.Uuid, item.Data); err == nil {
+				h.publishChange(ctx, events.OpUpdate, item.Uuid)
+				results[i] = BatchItemResult{Status: http.StatusNoContent}
+			}
+		case BatchOpRemove:
+			if err = client.Client.Remove(ctx, item.Uuid); err == nil {
+				h.publishChange(ctx, events.OpRemove, item.Uuid)
+				results[i] = BatchItemResult{Status: http.StatusNoContent}
+			}
+		}
+		if err != nil {
+			results[i] = batchErrorResult(err)
+		}
+	}
+}
+
+// Выполнение операции пакета в очереди упорядоченной записи
+func (h *Handler) runOrderedBatchItem(ctx context.Context, ordered *service.OrderedWriter, item BatchItem) BatchItemResult {
+	var result service.OrderedResult
+	var op string
+	status := http.StatusNoContent
+	switch item.Op {
+	case BatchOpInsert:
+		result, op, status = ordered.Insert(ctx, item.Data, uint64(item.TtlSeconds)), events.OpInsert, http.StatusCreated
+		item.Uuid = result.Uuid
+	case BatchOpUpdate:
+		result, op = ordered.Update(ctx, item.Uuid, item.Data, 0), events.OpUpdate
+	case BatchOpRemove:
+		result, op = ordered.Remove(ctx, item.Uuid), events.OpRemove
+	}
+	if result.Err != nil {
+		return batchErrorResult(result.Err)
+	}
+	h.publishChange(ctx, op, item.Uuid)
+	return BatchItemResult{Status: status, Uuid: result.Uuid, Sequence: result.Sequence}
+}
+
+// Результат операции пакета, завершившейся ошибкой
+func batchErrorResult(err error) BatchItemResult {
+	status, response := serviceErrorResponse(err, err.Error())
+	return BatchItemResult{Status: status, Error: response.Error, Code: response.Code}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Начало двоичного значения в octet. octet хранит только строки UTF-8, поэтому двоичное тело
+// сохраняется как префикс, исходный тип содержимого, разделитель и тело в base64.
+const binaryValuePrefix = "\x00octet-binary\x00"
+
+// Значение, переданное не двоичным телом, начинается со служебного префикса двоичных значений
+var errReservedValue = fmt.Errorf("%w: значение не может начинаться со служебного префикса двоичных значений",
+	service.ErrValueRejected)
+
+// Тип содержимого двоичного значения, если он входит в принимаемые (types из конфигурации, допускается "type/*")
+func binaryContentType(contentType string, types []string) (string, bool) {
+	if len(types) == 0 {
+		return "", false
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	for _, accepted := range types {
+		if accepted == mediaType || (strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, accepted[:len(accepted)-1])) {
+			return mime.FormatMediaType(mediaType, params), true
+		}
+	}
+	return "", false
+}
+
+// Передано ли значение строки без JSON-обертки: text/plain или двоичное тело
+func isRawValue(contentType string, binaryTypes []string) bool {
+	_, binary := binaryContentType(contentType, binaryTypes)
+	return binary || isTextPlain(contentType)
+}
+
+// Значение строки для двоичного тела
+func encodeBinaryValue(contentType string, body []byte) string {
+	return binaryValuePrefix + contentType + "\x00" + base64.StdEncoding.EncodeToString(body)
+}
+
+// Исходный тип содержимого и тело двоичного значения (ok = false - значение не двоичное)
+func decodeBinaryValue(data string) (contentType string, body []byte, ok bool) {
+	rest, found := strings.CutPrefix(data, binaryValuePrefix)
+	if !found {
+		return "", nil, false
+	}
+	contentType, encoded, found := strings.Cut(rest, "\x00")
+	if !found {
+		return "", nil, false
+	}
+	body, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+	return contentType, body, true
+}
+
+// Проверка значения, переданного в JSON или text/plain. Служебный префикс в нем недопустим:
+// иначе клиент мог бы сохранить значение, которое GET вернет с произвольным типом содержимого
+// (например, text/html). При импорте (allowBinary) префикс допускается для двоичных значений
+// из выгрузки, если их тип входит в принимаемые.
+func (h *Handler) checkReservedValue(data string, allowBinary bool) error {
+	if !strings.HasPrefix(data, binaryValuePrefix) {
+		return nil
+	}
+	if allowBinary {
+		if _, _, ok := h.binaryValue(data); ok {
+			return nil
+		}
+	}
+	return errReservedValue
+}
+
+// Двоичное значение, тип которого входит в принимаемые (ok = false - значение отдается как текст).
+// Тип проверяется и при чтении, поэтому значение не отдается с типом, который не принимается.
+func (h *Handler) binaryValue(data string) (contentType string, body []byte, ok bool) {
+	contentType, body, ok = decodeBinaryValue(data)
+	if !ok {
+		return "", nil, false
+	}
+	if contentType, ok = binaryContentType(contentType, h.binary.ContentTypes); !ok {
+		return "", nil, false
+	}
+	return contentType, body, true
+}
+
+// Отправка двоичного значения с исходным типом содержимого
+func respondWithBinary(w http.ResponseWriter, code int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(code)
+	w.Write(body)
+}
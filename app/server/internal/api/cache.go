@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Вычисление ETag по версии строки. Строка получает новый UUID при добавлении, а ее версия
+// увеличивается при каждом обновлении, поэтому версия однозначно определяет содержимое строки
+// и ETag сильный. При сжатии ответа он ослабляется, так как тело передается в другом кодировании,
+// но в If-Match принимается и ослабленная форма (см. ifMatchVersions).
+func versionETag(version uint64) string {
+	return `"v` + strconv.FormatUint(version, 10) + `"`
+}
+
+// Вычисление ETag по содержимому строки, версия которой неизвестна
+// (строка на момент времени или строка, добавленная до появления метаданных в octet).
+// ETag слабый, так как тело ответа может передаваться в разных кодированиях сжатия.
+func dataETag(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETag строки: по версии, если она известна, иначе по содержимому
+func entryETag(data string, version uint64) string {
+	if version != 0 {
+		return versionETag(version)
+	}
+	return dataETag(data)
+}
+
+// Установка Cache-Control для строки: по политике пространства имен, если она указана,
+// иначе строки неизменяемых пространств имен кэшируются надолго
+func (h *Handler) setCacheControl(w http.ResponseWriter, r *http.Request) {
+	cacheControl := h.cache.CacheControl
+	if policy := h.cache.Policy(h.namespace(r)); policy != nil {
+		cacheControl = policy.CacheControl
+	} else if h.immutable(r) && len(h.namespaces.ImmutableCacheControl) != 0 {
+		cacheControl = h.namespaces.ImmutableCacheControl
+	}
+	if len(cacheControl) != 0 {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+}
+
+// Установка Last-Modified по времени изменения строки (нулевое время - заголовок не отправляется)
+func setLastModified(w http.ResponseWriter, modifiedAt time.Time) {
+	if !modifiedAt.IsZero() {
+		w.Header().Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Проверка условного запроса If-Modified-Since по времени изменения строки. Заголовок учитывается,
+// только если в запросе нет If-None-Match (RFC 9110); время сравнивается с точностью до секунды,
+// как передается в Last-Modified.
+func notModifiedSince(r *http.Request, modifiedAt time.Time) bool {
+	header := r.Header.Get("If-Modified-Since")
+	if len(header) == 0 || len(r.Header.Get("If-None-Match")) != 0 || modifiedAt.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !modifiedAt.Truncate(time.Second).After(since)
+}
+
+// Проверка, совпадает ли ETag с одним из значений заголовка If-None-Match
+// (сравнение слабое, как требует RFC 9110 для If-None-Match)
+func etagMatches(header string, etag string) bool {
+	if len(header) == 0 {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// Версии строки, перечисленные в заголовке If-Match; wildcard - указано значение "*".
+// Учитываются только ETag по версии. Версия однозначно определяет содержимое строки, поэтому
+// ETag по версии, ослабленный при сжатии ответа (W/"vN"), тоже принимается: иначе клиент,
+// получивший сжатый ответ, не смог бы обновить строку с проверкой версии. Слабые ETag
+// по содержимому не учитываются, так как If-Match требует сильного сравнения (RFC 9110).
+func ifMatchVersions(header string) (versions []uint64, wildcard bool) {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return nil, true
+		}
+		value, ok := strings.CutPrefix(strings.TrimPrefix(candidate, "W/"), `"v`)
+		if !ok {
+			continue
+		}
+		version, err := strconv.ParseUint(strings.TrimSuffix(value, `"`), 10, 64)
+		if err == nil && version != 0 && strings.HasSuffix(value, `"`) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, false
+}
+
+// Версия, с которой должна совпадать текущая версия строки при обновлении по заголовку If-Match
+// (0 - строка обновляется без проверки версии). Если ни одно значение заголовка не может совпасть
+// с версией строки, возвращается service.ErrVersionMismatch.
+func (h *Handler) ifMatchVersion(ctx context.Context, uuid, header string) (uint64, error) {
+	versions, wildcard := ifMatchVersions(header)
+	switch {
+	case wildcard:
+		return 0, nil
+	case len(versions) == 0:
+		return 0, service.ErrVersionMismatch
+	case len(versions) == 1:
+		return versions[0], nil
+	}
+
+	// Из нескольких версий ожидается текущая, если она перечислена
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		return 0, err
+	}
+	stat, err := client.Stat(ctx, uuid)
+	if err != nil {
+		return 0, err
+	}
+	if stat.Version == 0 || !slices.Contains(versions, stat.Version) {
+		return 0, service.ErrVersionMismatch
+	}
+	return stat.Version, nil
+}
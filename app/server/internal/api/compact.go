@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Compact godoc
+// @Summary Сжатие хранилища
+// @Description Запуск сжатия хранилища octet: создание снимка и удаление из журнала операций до него.
+// @Description Сжатие выполняется асинхронно, его завершение отслеживается через GET /admin/v1/compact.
+// @Description Повторный запрос во время сжатия возвращает состояние уже запущенного сжатия.
+// @Tags admin
+// @Produce json
+// @Success 202 {object} service.CompactionStatus
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/compact [post]
+func (h *Handler) Compact(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clientPool.GetClient()
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		respondWithServiceError(w, err, "Сервер недоступен")
+		return
+	}
+
+	status, err := client.Compact(r.Context())
+	if err != nil {
+		h.logger.Error("Не удалось выполнить octet::compact", zap.Error(err))
+		respondWithServiceError(w, err, "Не удалось запустить сжатие хранилища")
+		return
+	}
+
+	h.logger.Info("Запущено сжатие хранилища через административный API",
+		zap.String("state", status.State), zap.Uint64("journal_size", status.JournalSizeBefore))
+	respondWithJSON(w, http.StatusAccepted, status)
+}
+
+// CompactionStatus godoc
+// @Summary Состояние сжатия хранилища
+// @Description Состояние последнего сжатия хранилища (idle, running, completed, failed) и размер журнала до и после него
+// @Tags admin
+// @Produce json
+// @Success 200 {object} service.CompactionStatus
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/compact [get]
+func (h *Handler) CompactionStatus(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clientPool.GetClient()
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		respondWithServiceError(w, err, "Сервер недоступен")
+		return
+	}
+
+	status, err := client.CompactionStatus(r.Context())
+	if err != nil {
+		h.logger.Error("Не удалось выполнить octet::compact_status", zap.Error(err))
+		respondWithServiceError(w, err, "Не удалось получить состояние сжатия хранилища")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Кодирования, поддерживаемые сервером
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+)
+
+// Типы содержимого, ответы с которыми имеет смысл сжимать
+var compressibleTypes = []string{"application/json", "application/x-ndjson", "text/"}
+
+// Кодировщик, поддерживающий повторное использование с другим Writer
+type resettableEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// Слой для сжатия ответов с выбором кодирования по Accept-Encoding (с учетом q-значений)
+func CompressMiddleware(cfg config.CompressionConfig) func(http.Handler) http.Handler {
+	// Пул кодировщиков для каждого включенного кодирования
+	pools := make(map[string]*sync.Pool, len(cfg.Encodings))
+	for _, encoding := range cfg.Encodings {
+		newEncoder := encoderFactory(encoding, cfg)
+		if newEncoder == nil {
+			continue
+		}
+		pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled || len(pools) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// Слой для распаковки тел запросов, сжатых по Content-Encoding. Распаковывается до ограничения
+// размера тела, поэтому ограничение относится к распакованным данным. Тело с неподдерживаемым
+// кодированием (или при выключенной распаковке) отклоняется с 415 и списком кодирований в Accept-Encoding.
+// maxBytes - ограничение размера тела запроса (0 - без ограничения), по нему ограничивается окно zstd.
+func DecompressMiddleware(cfg config.CompressionConfig, maxBytes int64) func(http.Handler) http.Handler {
+	accepted := "identity"
+	if cfg.DecompressRequests {
+		accepted = strings.Join([]string{EncodingGzip, EncodingZstd, EncodingBrotli, "identity"}, ", ")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body io.ReadCloser
+			var err error
+			if cfg.DecompressRequests {
+				body, err = newBodyDecoder(encoding, r.Body, maxBytes)
+			}
+			if body == nil && err == nil {
+				w.Header().Set("Accept-Encoding", accepted)
+				respondWithError(w, http.StatusUnsupportedMediaType,
+					"Неподдерживаемое кодирование тела запроса: "+encoding)
+				return
+			}
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Не удалось распаковать тело запроса: "+err.Error())
+				return
+			}
+
+			// Сервер закрывает только исходное тело, а распаковщик освобождаем сами
+			defer body.Close()
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Минимальное допустимое окно zstd: покрывает окна стандартных уровней сжатия (до 8 МБ)
+const minZstdWindow = 8 << 20
+
+// Максимальное окно zstd (и память распаковщика) при ограничении размера тела maxBytes.
+// Окно задается заголовком кадра, поэтому без ограничения небольшое тело могло бы
+// запросить выделение сотен мегабайт до чтения данных.
+func zstdMaxWindow(maxBytes int64) uint64 {
+	window := uint64(minZstdWindow)
+	if maxBytes > 0 {
+		window = max(window, uint64(maxBytes))
+	}
+	return min(window, zstd.MaxWindowSize)
+}
+
+// Распаковщик тела запроса (nil без ошибки - кодирование не поддерживается)
+func newBodyDecoder(encoding string, body io.ReadCloser, maxBytes int64) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return &decodedBody{Reader: reader, closers: []io.Closer{reader, body}}, nil
+	case EncodingZstd:
+		window := zstdMaxWindow(maxBytes)
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(window), zstd.WithDecoderMaxMemory(window))
+		if err != nil {
+			return nil, err
+		}
+		reader := decoder.IOReadCloser()
+		limit := maxBytes
+		if limit <= 0 {
+			limit = int64(window)
+		}
+		return &decodedBody{Reader: reader, closers: []io.Closer{reader, body}, limit: limit}, nil
+	case EncodingBrotli:
+		return &decodedBody{Reader: brotli.NewReader(body), closers: []io.Closer{body}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Распакованное тело запроса, закрывающее распаковщик и исходное тело
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+	limit   int64 // Ограничение размера тела для ответа 413 на слишком большое окно zstd
+	closed  bool
+}
+
+// Чтение распакованных данных. Кадр zstd с окном больше допустимого отклоняется
+// как слишком большое тело (413), а не как некорректное.
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		err = &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	var err error
+	for _, closer := range b.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Создание функции, порождающей кодировщик с настроенным уровнем сжатия
+func encoderFactory(encoding string, cfg config.CompressionConfig) func() resettableEncoder {
+	switch encoding {
+	case EncodingGzip:
+		return func() resettableEncoder {
+			encoder, err := gzip.NewWriterLevel(io.Discard, cfg.GzipLevel)
+			if err != nil {
+				encoder = gzip.NewWriter(io.Discard)
+			}
+			return encoder
+		}
+	case EncodingZstd:
+		return func() resettableEncoder {
+			encoder, _ := zstd.NewWriter(io.Discard,
+				zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(cfg.ZstdLevel)),
+				zstd.WithEncoderConcurrency(1))
+			return encoder
+		}
+	case EncodingBrotli:
+		return func() resettableEncoder {
+			return brotli.NewWriterLevel(io.Discard, cfg.BrotliLevel)
+		}
+	default:
+		return nil
+	}
+}
+
+// Выбор кодирования по заголовку Accept-Encoding.
+// Выбирается поддерживаемое кодирование с наибольшим q-значением,
+// при равных значениях - первое в порядке предпочтения сервера.
+func negotiateEncoding(header string, supported []string) string {
+	if len(header) == 0 {
+		return ""
+	}
+
+	// Разбираем q-значения из заголовка
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		if name == "*" {
+			wildcard = weight
+		} else {
+			weights[name] = weight
+		}
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range supported {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+// Обертка ResponseWriter, сжимающая тело ответа выбранным кодированием
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	pool        *sync.Pool
+	encoder     resettableEncoder // nil, если ответ передается без сжатия
+	wroteHeader bool
+}
+
+// Решение о сжатии принимается при отправке заголовков, когда известен тип содержимого
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if cw.shouldCompress(code, header) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		// Сильный ETag относится к несжатому телу, поэтому для сжатого он ослабляется
+		// (ETag по версии в ослабленной форме по-прежнему принимается в If-Match)
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.encoder = cw.pool.Get().(resettableEncoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+// Проверка, нужно ли сжимать ответ
+func (cw *compressResponseWriter) shouldCompress(code int, header http.Header) bool {
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		return false
+	}
+	if len(header.Get("Content-Encoding")) != 0 {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	return slices.ContainsFunc(compressibleTypes, func(prefix string) bool {
+		return strings.HasPrefix(contentType, prefix)
+	})
+}
+
+// Запись тела ответа
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Отправка накопленных данных клиенту (для потоковых ответов)
+func (cw *compressResponseWriter) Flush() {
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Доступ к исходному ResponseWriter для http.ResponseController
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Завершение сжатого потока и возврат кодировщика в пул
+func (cw *compressResponseWriter) Close() error {
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	cw.pool.Put(cw.encoder)
+	cw.encoder = nil
+	return err
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Количество строк хранилища
+type CountResponse struct {
+	Count uint64 `json:"count"`
+}
+
+// Count godoc
+// @Summary Количество строк
+// @Description Количество строк хранилища без их перечисления. Строки с меткой удаления не учитываются.
+// @Tags strings
+// @Produce json
+// @Success 200 {object} CountResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/count [get]
+func (h *Handler) Count(w http.ResponseWriter, r *http.Request) {
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		respondWithServiceError(w, err, "Внутренняя ошибка сервера")
+		return
+	}
+	count, err := client.Count(r.Context())
+	if err != nil {
+		h.logger.Error("Ошибка при подсчете строк", zap.Error(err))
+		respondWithServiceError(w, err, "Ошибка при подсчете строк: "+err.Error())
+		return
+	}
+
+	// Строки с меткой удаления считаются удаленными
+	if h.tombstones != nil {
+		count -= min(count, uint64(h.tombstones.Pending()))
+	}
+	respondWithJSON(w, http.StatusOK, CountResponse{Count: count})
+}
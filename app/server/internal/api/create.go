@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Является ли PUT созданием строки: с If-None-Match: * строка с UUID из адреса создается,
+// только если ее еще нет, иначе возвращается 412 (для идемпотентного заполнения хранилища)
+func createOnly(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+}
+
+// Может ли octet выдать такой UUID: строки хранятся с UUID версии 4 в нижнем регистре
+func isOctetUUID(s string) bool {
+	parsed, err := uuid.Parse(s)
+	return err == nil && parsed.String() == s && parsed.Version() == 4 && parsed.Variant() == uuid.RFC4122
+}
+
+// Создание строки с UUID из адреса запроса PUT с If-None-Match: *.
+// Неизменяемость пространства имен не проверяется, так как добавление строк в нем разрешено.
+func (h *Handler) createRow(w http.ResponseWriter, r *http.Request, uuid string) {
+	if !isOctetUUID(uuid) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("'%s' не является UUID версии 4 в нижнем регистре", uuid))
+		return
+	}
+
+	// Разбираем запрос
+	var createReq DataHeader
+	if err := h.decodeDataBody(r, &createReq, &createReq.Data); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+	if len(createReq.Data) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'data' не может быть пустым")
+		return
+	}
+
+	data, err := h.validateValue(r.Context(), BatchOpInsert, h.namespace(r), uuid, createReq.Data)
+	if err != nil {
+		respondWithServiceError(w, err, "Ошибка при проверке данных")
+		return
+	}
+
+	// Создаем строку; octet проверяет отсутствие строки и добавляет ее одной операцией
+	sequence, err := h.create(r.Context(), h.namespace(r), uuid, data, 0)
+	if err != nil {
+		h.logger.Error("Ошибка при создании строки", zap.String("uuid", uuid), zap.Error(err))
+		respondWithServiceError(w, err, "Ошибка при создании строки: "+err.Error())
+		return
+	}
+	h.publishChange(r.Context(), events.OpInsert, uuid)
+	noteRequestUuid(r.Context(), uuid)
+	setSequence(w, sequence)
+
+	w.Header().Set("Location", externalPrefix(r)+r.URL.Path)
+	var etag string
+	if h.cache.ETag {
+		etag = versionETag(1)
+		w.Header().Set("ETag", etag)
+	}
+	if prefers(r, "return=representation") {
+		createdAt := time.Now().UTC()
+		w.Header().Set("Preference-Applied", "return=representation")
+		respondWithJSON(w, http.StatusCreated, InsertResponse{
+			Uuid: uuid,
+			Meta: DataMeta{Uuid: uuid, Bytes: len(data), ETag: etag, CreatedAt: &createdAt},
+		})
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, UuidHeader{Uuid: uuid})
+}
+
+// Выполнение octet::insert с указанным UUID в очереди упорядоченной записи, в составе пакета или отдельным запросом
+func (h *Handler) create(ctx context.Context, namespace, uuid, data string, ttlSeconds uint64) (uint64, error) {
+	if ordered := h.orderedFor(namespace); ordered != nil {
+		result := ordered.Create(ctx, uuid, data, ttlSeconds)
+		return result.Sequence, result.Err
+	}
+	if h.coalescer != nil {
+		return 0, h.coalescer.Create(ctx, uuid, data, ttlSeconds)
+	}
+	client, err := h.clientPool.GetClientFor(service.PoolClassWrite)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		return 0, err
+	}
+	_, err = client.InsertAs(ctx, uuid, data, ttlSeconds)
+	return 0, err
+}
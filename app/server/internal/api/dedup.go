@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/metastore"
+	"go.uber.org/zap"
+)
+
+// Заголовок с ключом идемпотентности, выбираемым клиентом
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Заголовок ответа, повторенного из сохраненного ответа первого запроса
+const ReplayedHeader = "X-Octet-Replayed"
+
+// Максимальная длина ключа идемпотентности
+const maxIdempotencyKeyLength = 255
+
+// Префикс ключей сохраненных ответов в хранилище метаданных
+const dedupStorePrefix = "dedup:"
+
+// Обнаружение повторных изменяющих запросов: запрос с тем же ключом идемпотентности
+// (или, при by_body, с тем же телом) от того же клиента в течение окна не выполняется повторно,
+// а получает сохраненный ответ первого запроса. Пока первый запрос выполняется, повторные ожидают его ответа.
+// Ответы сохраняются и в хранилище метаданных (если оно задано), поэтому повтор после перезапуска
+// сервера (или, для общего Redis, на другом сервере) получает тот же ответ.
+type Deduplicator struct {
+	config config.DedupConfig
+	window time.Duration
+	store  metastore.Store
+	logger *zap.Logger
+
+	mutex   sync.Mutex
+	entries map[string]*dedupEntry
+
+	replayed  atomic.Uint64
+	conflicts atomic.Uint64
+}
+
+// Запомненный запрос
+type dedupEntry struct {
+	bodyHash [sha256.Size]byte
+	done     chan struct{} // Закрывается после получения ответа
+
+	// Заполняются до закрытия done
+	cached  bool // Ответ сохранен (ответы 5xx и 429 не сохраняются, и запрос выполняется повторно)
+	expires time.Time
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+// Ответ, сохраненный в хранилище метаданных
+type storedDedupEntry struct {
+	BodyHash []byte      `json:"body_hash"`
+	Expires  time.Time   `json:"expires"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+}
+
+// Счетчики обнаружения повторных запросов
+type DedupStats struct {
+	Entries   int    `json:"entries"`   // Запомненные запросы
+	Replayed  uint64 `json:"replayed"`  // Ответы, повторенные без обращения к octet
+	Conflicts uint64 `json:"conflicts"` // Ключи идемпотентности, повторно использованные с другим телом
+	// Хранилище сохраненных ответов (отсутствует, если ответы хранятся только в памяти)
+	Store string `json:"store,omitempty"`
+	// Ответы в хранилище (отсутствует, если количество неизвестно)
+	Stored *int `json:"stored,omitempty"`
+}
+
+// Создание обнаружения повторных запросов (nil, если оно отключено).
+// store - хранилище сохраненных ответов (nil - ответы хранятся только в памяти).
+func NewDeduplicator(config config.DedupConfig, store metastore.Store, logger *zap.Logger) *Deduplicator {
+	if !config.Enabled {
+		return nil
+	}
+	return &Deduplicator{
+		config:  config,
+		window:  time.Duration(config.WindowMs) * time.Millisecond,
+		store:   store,
+		logger:  logger,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+// Счетчики обнаружения повторных запросов
+func (d *Deduplicator) Stats() DedupStats {
+	d.mutex.Lock()
+	entries := len(d.entries)
+	d.mutex.Unlock()
+	stats := DedupStats{Entries: entries, Replayed: d.replayed.Load(), Conflicts: d.conflicts.Load()}
+	if d.store != nil {
+		stats.Store = d.store.Backend()
+		if stored := d.store.Len(); stored >= 0 {
+			stats.Stored = &stored
+		}
+	}
+	return stats
+}
+
+// Запоминание нового запроса. Возвращает nil, если запоминать больше некуда.
+func (d *Deduplicator) add(key string, bodyHash [sha256.Size]byte) *dedupEntry {
+	if len(d.entries) >= d.config.MaxEntries {
+		now := time.Now()
+		for k, e := range d.entries {
+			if e.expired(now) {
+				delete(d.entries, k)
+			}
+		}
+		if len(d.entries) >= d.config.MaxEntries {
+			return nil
+		}
+	}
+	entry := &dedupEntry{bodyHash: bodyHash, done: make(chan struct{})}
+	d.entries[key] = entry
+	return entry
+}
+
+// Сохранение ответа запроса (rec == nil - ответ не получен)
+func (d *Deduplicator) complete(key string, entry *dedupEntry, rec *interceptRecorder) {
+	d.mutex.Lock()
+	if rec != nil && rec.status < http.StatusInternalServerError && rec.status != http.StatusTooManyRequests {
+		entry.cached = true
+		entry.expires = time.Now().Add(d.window)
+		entry.status = rec.status
+		entry.header = rec.header
+		entry.body = rec.body.Bytes()
+	} else {
+		delete(d.entries, key)
+	}
+	close(entry.done)
+	d.mutex.Unlock()
+
+	// Поля сохраненного ответа после закрытия done не изменяются
+	if entry.cached {
+		d.save(key, entry)
+	}
+}
+
+// Ключ сохраненного ответа в хранилище метаданных (ключ запроса содержит имя клиента и путь)
+func dedupStoreKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return dedupStorePrefix + hex.EncodeToString(sum[:])
+}
+
+// Запись ответа в хранилище метаданных. Ошибка не мешает ответу: повтор в пределах окна
+// получит ответ из памяти, а после перезапуска выполнится заново.
+func (d *Deduplicator) save(key string, entry *dedupEntry) {
+	if d.store == nil {
+		return
+	}
+	data, err := json.Marshal(storedDedupEntry{
+		BodyHash: entry.bodyHash[:],
+		Expires:  entry.expires,
+		Status:   entry.status,
+		Header:   entry.header,
+		Body:     entry.body,
+	})
+	if err == nil {
+		err = d.store.Set(context.Background(), dedupStoreKey(key), data, entry.expires)
+	}
+	if err != nil {
+		d.logger.Warn("Не удалось сохранить ответ на запрос в хранилище метаданных", zap.Error(err))
+	}
+}
+
+// Чтение ответа из хранилища метаданных (nil - ответ не сохранен или его окно истекло)
+func (d *Deduplicator) load(ctx context.Context, key string) *dedupEntry {
+	if d.store == nil {
+		return nil
+	}
+	data, err := d.store.Get(ctx, dedupStoreKey(key))
+	if errors.Is(err, metastore.ErrNotFound) {
+		return nil
+	}
+	var stored storedDedupEntry
+	if err == nil {
+		err = json.Unmarshal(data, &stored)
+	}
+	if err == nil && len(stored.BodyHash) != sha256.Size {
+		err = errors.New("некорректный хеш тела запроса")
+	}
+	if err != nil {
+		d.logger.Warn("Не удалось прочитать ответ на запрос из хранилища метаданных", zap.Error(err))
+		return nil
+	}
+	if time.Now().After(stored.Expires) {
+		return nil
+	}
+
+	entry := &dedupEntry{
+		done:    make(chan struct{}),
+		cached:  true,
+		expires: stored.Expires,
+		status:  stored.Status,
+		header:  stored.Header,
+		body:    stored.Body,
+	}
+	copy(entry.bodyHash[:], stored.BodyHash)
+	close(entry.done)
+	return entry
+}
+
+// Истекло ли окно запомненного запроса
+func (e *dedupEntry) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return now.After(e.expires)
+	default:
+		return false
+	}
+}
+
+// Слой для обнаружения повторных изменяющих запросов
+func (h *Handler) deduplicate(next http.Handler) http.Handler {
+	d := h.dedup
+	if d == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+		if len(idempotencyKey) == 0 && !d.config.ByBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			respondWithError(w, http.StatusBadRequest, "Заголовок Idempotency-Key не может быть длиннее 255 символов")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithDecodeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		bodyHash := sha256.Sum256(body)
+
+		// Запросы разных клиентов не считаются повторными
+		key := requestClient(r) + "\x00" + r.Method + " " + r.URL.Path + "\x00"
+		if len(idempotencyKey) != 0 {
+			key += "key:" + idempotencyKey
+		} else {
+			key += "body:" + hex.EncodeToString(bodyHash[:])
+		}
+
+		for {
+			d.mutex.Lock()
+			entry := d.entries[key]
+			if entry != nil && entry.expired(time.Now()) {
+				delete(d.entries, key)
+				entry = nil
+			}
+			d.mutex.Unlock()
+			if entry == nil {
+				// Ответ, сохраненный до перезапуска сервера (или другим сервером с общим хранилищем)
+				entry = d.load(r.Context(), key)
+			}
+			if entry == nil {
+				d.mutex.Lock()
+				if d.entries[key] != nil {
+					// Пока читали хранилище, такой же запрос начал выполняться
+					d.mutex.Unlock()
+					continue
+				}
+				entry = d.add(key, bodyHash)
+				d.mutex.Unlock()
+				if entry == nil {
+					next.ServeHTTP(w, r)
+				} else {
+					d.execute(w, r, next, key, entry)
+				}
+				return
+			}
+
+			if entry.bodyHash != bodyHash {
+				d.conflicts.Add(1)
+				respondWithError(w, http.StatusUnprocessableEntity,
+					"Ключ Idempotency-Key уже использован для запроса с другим телом")
+				return
+			}
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if entry.cached {
+				d.replayed.Add(1)
+				replay(w, entry)
+				return
+			}
+			// Первый запрос завершился ошибкой, которая не сохраняется, - выполняем запрос заново
+		}
+	})
+}
+
+// Клиент, отправивший запрос: имя ключа API (субъект) или, без аутентификации, IP-адрес
+func requestClient(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Name
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Выполнение первого запроса с сохранением ответа
+func (d *Deduplicator) execute(w http.ResponseWriter, r *http.Request, next http.Handler, key string, entry *dedupEntry) {
+	var rec *interceptRecorder
+	defer func() {
+		// При панике обработчика ожидающие повторные запросы выполняются заново
+		d.complete(key, entry, rec)
+	}()
+
+	recorder := &interceptRecorder{header: w.Header().Clone(), status: http.StatusOK, encoding: jsonEncodingOf(w)}
+	next.ServeHTTP(recorder, r)
+	rec = recorder
+
+	for name, values := range rec.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
+
+// Отправка сохраненного ответа
+func replay(w http.ResponseWriter, entry *dedupEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
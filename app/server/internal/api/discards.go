@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Закрытия соединений пула с octet после ошибок
+type PoolDiscardsResponse struct {
+	Counts map[service.DiscardReason]uint64 `json:"counts"` // Количество закрытий по причинам с момента запуска
+	Events []service.DiscardEvent           `json:"events"` // Последние закрытия, от старых к новым
+}
+
+// PoolDiscards godoc
+// @Summary Закрытия соединений с octet
+// @Description Соединения пула, закрытые после ошибок: количество по причинам с момента запуска сервера
+// @Description и последние события (не больше 100) с командой и ошибкой. Причины: write_error, timeout,
+// @Description connection_lost, frame_too_large, protocol_error (ответ не удалось разобрать),
+// @Description request_mismatch (ID ответа не совпадает с ID запроса), heartbeat_failed, injected_fault.
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Максимальное количество последних событий"
+// @Success 200 {object} PoolDiscardsResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/pool/discards [get]
+func (h *Handler) PoolDiscards(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if value := r.URL.Query().Get("limit"); len(value) != 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'limit' должен быть положительным числом")
+			return
+		}
+		limit = parsed
+	}
+	events := h.clientPool.Discards(limit)
+	if events == nil {
+		events = []service.DiscardEvent{}
+	}
+	respondWithJSON(w, http.StatusOK, PoolDiscardsResponse{Counts: h.clientPool.Stats().Discards, Events: events})
+}
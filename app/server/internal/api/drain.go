@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+	"go.uber.org/zap"
+)
+
+// Интервал проверки завершения выполняющихся запросов
+const drainPollInterval = 10 * time.Millisecond
+
+// Вывод сервера из балансировки: после начала вывода /ready возвращает 503,
+// чтобы балансировщик перестал направлять новые запросы, а выполняющиеся запросы завершаются.
+// Длительные потоки и подписки не ожидаются: они завершаются при завершении работы сервера.
+type Drainer struct {
+	config   config.DrainConfig
+	streams  *Streams
+	shutdown func() // Завершение работы сервера (nil - завершение недоступно)
+	draining atomic.Bool
+	inFlight atomic.Int64
+	waiters  atomic.Int64 // Запросы на вывод, ожидающие завершения остальных запросов
+
+	mutex   sync.Mutex
+	exitAt  *time.Time // Запланированное завершение работы
+	pending *drainWait // Выполняющееся ожидание завершения запросов
+}
+
+// Ожидание завершения выполняющихся запросов, общее для одновременных запросов на вывод
+type drainWait struct {
+	done     chan struct{} // Закрывается по окончании ожидания
+	drained  bool          // Запросы завершились до таймаута
+	inFlight int64         // Незавершенные запросы (при таймауте)
+}
+
+// Создание вывода из балансировки
+func NewDrainer(config config.DrainConfig, streams *Streams, shutdown func()) *Drainer {
+	return &Drainer{config: config, streams: streams, shutdown: shutdown}
+}
+
+// Выполняется ли вывод из балансировки
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Количество выполняющихся HTTP запросов
+func (d *Drainer) InFlight() int64 {
+	return d.inFlight.Load()
+}
+
+// Слой для учета выполняющихся HTTP запросов
+func (d *Drainer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.inFlight.Add(1)
+		defer d.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Количество запросов, завершения которых ожидает вывод: без самих запросов на вывод,
+// длительных потоков и подписок
+func (d *Drainer) pendingRequests() int64 {
+	return max(d.inFlight.Load()-d.waiters.Load()-d.streams.Active(), 0)
+}
+
+// Ожидание завершения выполняющихся запросов. Одновременные запросы на вывод
+// получают результат одного ожидания, начатого первым из них.
+func (d *Drainer) wait(timeout time.Duration) *drainWait {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.pending == nil {
+		d.pending = &drainWait{done: make(chan struct{})}
+		go d.poll(d.pending, timeout)
+	}
+	return d.pending
+}
+
+// Проверка завершения выполняющихся запросов до таймаута
+func (d *Drainer) poll(pending *drainWait, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for d.pendingRequests() > 0 {
+		select {
+		case <-deadline.C:
+			pending.inFlight = d.pendingRequests()
+			d.finish(pending)
+			return
+		case <-ticker.C:
+		}
+	}
+	pending.drained = true
+	d.finish(pending)
+}
+
+// Окончание ожидания: следующий запрос на вывод начнет новое ожидание
+func (d *Drainer) finish(pending *drainWait) {
+	d.mutex.Lock()
+	d.pending = nil
+	d.mutex.Unlock()
+	close(pending.done)
+}
+
+// Планирование завершения работы сервера через grace. Возвращает false, если оно уже запланировано.
+func (d *Drainer) scheduleExit(grace time.Duration) (time.Time, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.exitAt != nil {
+		return *d.exitAt, false
+	}
+	exitAt := time.Now().Add(grace)
+	d.exitAt = &exitAt
+	time.AfterFunc(grace, d.shutdown)
+	return exitAt, true
+}
+
+// Запланированное завершение работы (nil - не запланировано)
+func (d *Drainer) plannedExit() *time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.exitAt
+}
+
+// Запрос на вывод сервера из балансировки
+type DrainRequest struct {
+	Exit         bool `json:"exit"`          // Завершить работу сервера после вывода
+	GraceSeconds *int `json:"grace_seconds"` // Задержка перед завершением работы (по умолчанию - из конфигурации)
+}
+
+// Результат вывода сервера из балансировки
+type DrainResponse struct {
+	Status     string     `json:"status"`            // drained или timed_out
+	InFlight   int64      `json:"in_flight"`         // Незавершенные запросы (при timed_out)
+	DurationMs int64      `json:"duration_ms"`       // Длительность ожидания завершения запросов
+	ExitAt     *time.Time `json:"exit_at,omitempty"` // Время завершения работы сервера
+}
+
+// Drain godoc
+// @Summary Вывод сервера из балансировки
+// @Description Переводит /ready в состояние draining (503) и ожидает завершения выполняющихся запросов
+// @Description (кроме длительных потоков и подписок). Одновременные запросы получают результат одного ожидания.
+// @Description При exit=true сервер завершает работу через grace_seconds после ответа.
+// @Description Тело запроса необязательно.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body DrainRequest false "Параметры вывода"
+// @Success 200 {object} DrainResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/drain [post]
+func (h *Handler) Drain(w http.ResponseWriter, r *http.Request) {
+	var drainReq DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&drainReq); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+	grace := time.Duration(h.drainer.config.GraceSeconds) * time.Second
+	if drainReq.GraceSeconds != nil {
+		if *drainReq.GraceSeconds < 0 {
+			respondWithError(w, http.StatusBadRequest, "Поле 'grace_seconds' не может быть отрицательным")
+			return
+		}
+		grace = time.Duration(*drainReq.GraceSeconds) * time.Second
+	}
+	if drainReq.Exit && h.drainer.shutdown == nil {
+		respondWithError(w, http.StatusConflict, "Завершение работы сервера через административный API недоступно")
+		return
+	}
+
+	if !h.drainer.draining.Swap(true) {
+		h.logger.Warn("Сервер выводится из балансировки через административный API", zap.Bool("exit", drainReq.Exit))
+	}
+
+	// Сам запрос на вывод учитывается как выполняющийся, но не ожидается
+	h.drainer.waiters.Add(1)
+	defer h.drainer.waiters.Add(-1)
+	start := time.Now()
+	pending := h.drainer.wait(time.Duration(h.drainer.config.TimeoutSeconds) * time.Second)
+	select {
+	case <-pending.done:
+	case <-r.Context().Done():
+		return
+	}
+	response := DrainResponse{Status: "drained"}
+	if !pending.drained {
+		response.Status = "timed_out"
+		response.InFlight = pending.inFlight
+		h.logger.Warn("Не дождались завершения выполняющихся запросов", zap.Int64("in_flight", response.InFlight))
+	}
+	response.DurationMs = time.Since(start).Milliseconds()
+
+	if drainReq.Exit {
+		exitAt, scheduled := h.drainer.scheduleExit(grace)
+		if scheduled {
+			h.logger.Warn("Запланировано завершение работы сервера", zap.Time("exit_at", exitAt))
+		}
+		response.ExitAt = &exitAt
+	} else {
+		response.ExitAt = h.drainer.plannedExit()
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Undrain godoc
+// @Summary Возврат сервера в балансировку
+// @Description Отменяет вывод из балансировки: /ready снова возвращает 200.
+// @Description Недоступно, если запланировано завершение работы сервера.
+// @Tags admin
+// @Success 204
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/drain [delete]
+func (h *Handler) Undrain(w http.ResponseWriter, r *http.Request) {
+	if h.drainer.plannedExit() != nil {
+		respondWithError(w, http.StatusConflict, "Запланировано завершение работы сервера")
+		return
+	}
+	if h.drainer.draining.Swap(false) {
+		h.logger.Info("Сервер возвращен в балансировку через административный API")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
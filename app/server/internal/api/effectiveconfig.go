@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Итоговая конфигурация запущенного сервера
+type EffectiveConfigResponse struct {
+	// Файл, из которого загружена конфигурация (отсутствует для конфигурации по умолчанию)
+	File string `json:"file,omitempty"`
+	// SHA-256 содержимого файла на момент загрузки
+	FileSHA256 string `json:"file_sha256,omitempty"`
+	// SHA-256 текущего содержимого файла (отсутствует, если файл не удалось прочитать).
+	// Отличие от file_sha256 означает, что файл изменен после запуска и изменения еще не применены.
+	CurrentFileSHA256 string `json:"current_file_sha256,omitempty"`
+	// Параметры после применения значений по умолчанию и разрешения путей, секреты заменены на [redacted]
+	Config config.Config `json:"config"`
+}
+
+// EffectiveConfig godoc
+// @Summary Итоговая конфигурация
+// @Description Конфигурация, с которой работает сервер (со значениями по умолчанию и разрешенными путями),
+// @Description без секретов, и хэш файла конфигурации на момент загрузки и текущий
+// @Tags admin
+// @Produce json
+// @Success 200 {object} EffectiveConfigResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/config [get]
+func (h *Handler) EffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	response := EffectiveConfigResponse{
+		File:       h.effectiveConfig.File,
+		FileSHA256: h.effectiveConfig.FileHash,
+		Config:     *h.effectiveConfig,
+	}
+	if len(response.File) != 0 {
+		// Ошибка чтения не мешает вернуть конфигурацию: файл мог быть удален после запуска
+		response.CurrentFileSHA256, _ = config.FileSHA256(response.File)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/events"
+	"go.uber.org/zap"
+)
+
+// Состояние доставки событий изменения данных
+type EventStatsResponse struct {
+	LastSeq  uint64                `json:"last_seq"` // Номер последнего записанного события
+	Webhooks []events.WebhookStats `json:"webhooks"`
+}
+
+// EventStats godoc
+// @Summary Состояние доставки событий
+// @Description Номер последнего события и счетчики доставки для каждого подписчика (отставание, повторы, dead-letter)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} EventStatsResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/events [get]
+func (h *Handler) EventStats(w http.ResponseWriter, r *http.Request) {
+	response := EventStatsResponse{
+		LastSeq:  h.outbox.LastSeq(),
+		Webhooks: make([]events.WebhookStats, 0, len(h.webhooks)),
+	}
+	for _, webhook := range h.webhooks {
+		response.Webhooks = append(response.Webhooks, webhook.Stats())
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Результат перемотки очередей подписчиков
+type EventReplayResponse struct {
+	AfterSeq uint64 `json:"after_seq"` // События с большим номером будут доставлены повторно
+	// Часть событий после указанного момента уже удалена по сроку хранения
+	Truncated bool     `json:"truncated"`
+	Webhooks  []string `json:"webhooks"` // Перемотанные подписчики
+}
+
+// ReplayEvents godoc
+// @Summary Повторная доставка событий
+// @Description Повторная доставка подписчикам всех событий, записанных начиная с указанного момента
+// @Tags admin
+// @Produce json
+// @Param from query string true "Момент времени в формате RFC 3339"
+// @Param webhook query string false "Имя подписчика (по умолчанию - все подписчики)"
+// @Success 200 {object} EventReplayResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/events/replay [post]
+func (h *Handler) ReplayEvents(w http.ResponseWriter, r *http.Request) {
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Параметр 'from' должен быть указан в формате RFC 3339")
+		return
+	}
+
+	webhooks := h.webhooks
+	if name := r.URL.Query().Get("webhook"); len(name) != 0 {
+		webhooks = nil
+		for _, webhook := range h.webhooks {
+			if webhook.Name() == name {
+				webhooks = append(webhooks, webhook)
+			}
+		}
+		if len(webhooks) == 0 {
+			respondWithError(w, http.StatusNotFound, "Подписчик не найден")
+			return
+		}
+	}
+
+	seq, truncated := h.outbox.SeqBefore(from)
+	response := EventReplayResponse{AfterSeq: seq, Truncated: truncated, Webhooks: make([]string, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		webhook.Rewind(seq)
+		response.Webhooks = append(response.Webhooks, webhook.Name())
+	}
+	h.logger.Info("Запрошена повторная доставка событий",
+		zap.Time("from", from), zap.Uint64("after_seq", seq), zap.Strings("webhooks", response.Webhooks))
+	respondWithJSON(w, http.StatusOK, response)
+}
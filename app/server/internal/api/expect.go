@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Ожидает ли клиент разрешения на передачу тела запроса
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
+
+// Слой для проверки добавления и обновления строки с Expect: 100-continue до передачи тела.
+// Сервер отвечает 100 Continue только при первом чтении тела, поэтому клиент, которому отказано
+// здесь или в предыдущих слоях (аутентификация, области доступа, размер по Content-Length,
+// Content-Type, приостановка изменений), не передает тело. Здесь проверяется то, что обработчик
+// проверил бы только после чтения тела: ограничение количества строк для добавления
+// (в том числе PUT с If-None-Match: *), неизменяемость пространства имен и метка удаления для обновления.
+func (h *Handler) expectContinue(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !expectsContinue(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost || r.Method == http.MethodPut && createOnly(r):
+			// Повторный запрос с ключом идемпотентности должен получить сохраненный ответ первого запроса,
+			// поэтому ограничение проверяется после чтения тела
+			if h.keyLimit.Reached() && len(r.Header.Get(IdempotencyKeyHeader)) == 0 {
+				respondWithServiceError(w, service.ErrKeyLimitReached, service.ErrKeyLimitReached.Error())
+				return
+			}
+		case r.Method == http.MethodPut:
+			if h.immutable(r) {
+				respondImmutable(w, h.namespace(r))
+				return
+			}
+			if h.tombstones.Contains(chi.URLParam(r, "uuid")) {
+				respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
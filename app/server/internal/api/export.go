@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Количество строк, читаемых из octet за один раз при выгрузке
+const exportPageSize = 500
+
+// Строка хранилища в выгрузке
+type ExportEntry struct {
+	Uuid string `json:"uuid"`
+	Data string `json:"data"`
+	// Метаданные строки (только с include_metadata=true; время отсутствует, если octet его не сохранил)
+	Namespace  string     `json:"namespace,omitempty"`
+	Version    uint64     `json:"version,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+	// Момент истечения срока хранения (отсутствует для строк без срока). При импорте восстанавливает срок хранения.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Отбор строк и состав записей выгрузки
+type exportFilter struct {
+	namespace     string
+	modifiedSince time.Time // Нулевое значение - без отбора по времени изменения
+	metadata      bool
+}
+
+// Нужны ли для выгрузки метаданные строк
+func (f *exportFilter) needsStat() bool {
+	return f.metadata || !f.modifiedSince.IsZero()
+}
+
+// Export godoc
+// @Summary Выгрузка всех строк
+// @Description Все строки хранилища в порядке возрастания UUID в формате NDJSON (одна строка {uuid, data} в строке ответа).
+// @Description Ответ передается частями по мере чтения из octet, поэтому подходит для резервного копирования
+// @Description хранилищ любого размера. Строки, измененные во время выгрузки, могут попасть в нее в любом из состояний.
+// @Description Прерванную выгрузку можно продолжить, передав в cursor UUID последней полученной строки.
+// @Description С consistent=true изменения строк приостанавливаются на время выгрузки (изменяющие запросы получают 503),
+// @Description и выгрузка отражает состояние хранилища на момент начала.
+// @Description С modified_since выгружаются только строки, измененные в указанный момент или позже
+// @Description (строки, время изменения которых octet не сохранил, выгружаются всегда).
+// @Description С include_metadata=true запись содержит пространство имен, версию, время добавления и изменения
+// @Description и срок хранения строки.
+// @Description Трейлер X-Octet-Stream-End: complete - строки переданы полностью,
+// @Description shutdown - передача остановлена из-за завершения работы сервера.
+// @Tags strings
+// @Produce application/x-ndjson
+// @Param cursor query string false "UUID, после которого начинается выгрузка (по умолчанию - с начала)"
+// @Param consistent query bool false "Приостановить изменения на время выгрузки"
+// @Param namespace query string false "Пространство имен выгружаемых строк (по умолчанию - пространство имен запроса)"
+// @Param modified_since query string false "Выгрузить строки, измененные в этот момент или позже (RFC 3339)"
+// @Param include_metadata query bool false "Добавить в записи метаданные строк"
+// @Success 200 {array} ExportEntry
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/export [get]
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	cursor := r.URL.Query().Get("cursor")
+	if len(cursor) != 0 && !isCanonicalUUID(cursor) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Курсор '%s' некорректен", cursor))
+		return
+	}
+	var consistent bool
+	if value := r.URL.Query().Get("consistent"); len(value) != 0 {
+		var err error
+		if consistent, err = strconv.ParseBool(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'consistent' должен быть логическим значением")
+			return
+		}
+	}
+	filter, ok := h.parseExportFilter(w, r)
+	if !ok {
+		return
+	}
+
+	if consistent {
+		// Выполняющиеся изменения завершаются до начала выгрузки, новые отклоняются до ее окончания
+		if !h.writeGate.pause(r.Context()) {
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, http.StatusServiceUnavailable, "Не удалось дождаться завершения выполняющихся изменений")
+			return
+		}
+		defer h.writeGate.resume()
+	}
+
+	stop, done := h.streams.begin()
+	defer done()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(jsonEncodingOf(w).escapeHTML)
+	flusher, _ := w.(http.Flusher)
+	written := 0
+	started := false
+	end := StreamEndComplete
+	for {
+		entries, more, err := h.exportPage(r.Context(), &cursor, &filter)
+		if err != nil {
+			h.logger.Error("Не удалось выгрузить строки", zap.Error(err))
+			if !started {
+				respondWithServiceError(w, err, "Не удалось выгрузить строки: "+err.Error())
+				return
+			}
+			// Часть строк уже отправлена и код ответа изменить нельзя,
+			// поэтому разрываем соединение, чтобы клиент не принял ответ за полный
+			panic(http.ErrAbortHandler)
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Trailer", StreamEndTrailer)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		for i := range entries {
+			if err := encoder.Encode(&entries[i]); err != nil {
+				// Клиент закрыл соединение
+				return
+			}
+		}
+		written += len(entries)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !more {
+			break
+		}
+
+		// При завершении работы сервера передача останавливается между страницами
+		select {
+		case <-stop:
+			h.logger.Info("Выгрузка строк остановлена из-за завершения работы сервера", zap.Int("written", written))
+			end = StreamEndShutdown
+		default:
+			continue
+		}
+		break
+	}
+	w.Header().Set(StreamEndTrailer, end)
+}
+
+// Разбор параметров отбора строк выгрузки. При ошибке отправляет ответ и возвращает false.
+func (h *Handler) parseExportFilter(w http.ResponseWriter, r *http.Request) (exportFilter, bool) {
+	filter := exportFilter{namespace: h.namespace(r)}
+	query := r.URL.Query()
+	if namespace := query.Get("namespace"); len(namespace) != 0 {
+		// Пространство имен пути уже проверено NamespaceMiddleware
+		if status, response := checkNamespace(h.namespaces, r, namespace); status != http.StatusOK {
+			respondWithJSON(w, status, response)
+			return filter, false
+		}
+		if namespace != filter.namespace {
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("Параметр 'namespace' не совпадает с пространством имен запроса '%s'", filter.namespace))
+			return filter, false
+		}
+	}
+	if value := query.Get("modified_since"); len(value) != 0 {
+		var err error
+		if filter.modifiedSince, err = time.Parse(time.RFC3339, value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'modified_since' должен быть указан в формате RFC 3339")
+			return filter, false
+		}
+	}
+	if value := query.Get("include_metadata"); len(value) != 0 {
+		var err error
+		if filter.metadata, err = strconv.ParseBool(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'include_metadata' должен быть логическим значением")
+			return filter, false
+		}
+	}
+	return filter, true
+}
+
+// Чтение страницы строк после cursor на одном клиенте пула. Курсор сдвигается на последний
+// перечисленный UUID, в том числе строки с меткой удаления, удаленной после перечисления
+// и не прошедшей отбор.
+func (h *Handler) exportPage(ctx context.Context, cursor *string, filter *exportFilter) ([]ExportEntry, bool, error) {
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		return nil, false, err
+	}
+	defer client.Release()
+
+	// Клиент возвращается в пул после всей страницы, поэтому вызываются методы *service.Client
+	uuids, more, err := client.Client.List(ctx, *cursor, exportPageSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(uuids) == 0 {
+		return nil, false, nil
+	}
+	*cursor = uuids[len(uuids)-1]
+
+	entries := make([]ExportEntry, 0, len(uuids))
+	for _, uuid := range uuids {
+		// Строка с меткой удаления считается удаленной
+		if h.tombstones.Contains(uuid) {
+			continue
+		}
+		entry := ExportEntry{Uuid: uuid}
+		if filter.needsStat() {
+			stat, err := client.Client.Stat(ctx, uuid)
+			if errors.Is(err, service.ErrNotFound) {
+				// Строка удалена после перечисления
+				continue
+			} else if err != nil {
+				return nil, false, err
+			}
+			// Время изменения, которое octet не сохранил, неизвестно, поэтому такая строка не отбрасывается
+			if !stat.ModifiedAt.IsZero() && stat.ModifiedAt.Before(filter.modifiedSince) {
+				continue
+			}
+			if filter.metadata {
+				entry.Namespace, entry.Version = filter.namespace, stat.Version
+				if !stat.CreatedAt.IsZero() {
+					entry.CreatedAt = &stat.CreatedAt
+				}
+				if !stat.ModifiedAt.IsZero() {
+					entry.ModifiedAt = &stat.ModifiedAt
+				}
+				if !stat.ExpiresAt.IsZero() {
+					entry.ExpiresAt = &stat.ExpiresAt
+				}
+			}
+		}
+		data, err := client.Client.Get(ctx, uuid)
+		if errors.Is(err, service.ErrNotFound) {
+			// Строка удалена после перечисления
+			continue
+		} else if err != nil {
+			return nil, false, err
+		}
+		entry.Data = data
+		entries = append(entries, entry)
+	}
+	return entries, more, nil
+}
@@ -1,11 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/events"
 	"github.com/lildannita/octet-server/internal/service"
 	"go.uber.org/zap"
 )
@@ -15,26 +23,144 @@ type DataHeader struct {
 	Data string `json:"data"`
 }
 
+// Для получения добавляемой строки
+type InsertRequest struct {
+	Data string `json:"data"`
+	// Срок хранения строки в секундах (0 - без ограничения). По его истечении octet удаляет строку.
+	TtlSeconds int64 `json:"ttl_seconds,omitempty"`
+}
+
+// Максимальный срок хранения строки (100 лет, как в octet)
+const maxTtlSeconds = 100 * 365 * 24 * 60 * 60
+
+// Метаданные строки (версия API 2)
+type DataMeta struct {
+	Uuid      string     `json:"uuid"`
+	Bytes     int        `json:"bytes"`                // Размер строки в байтах
+	ETag      string     `json:"etag,omitempty"`       // Версия строки (если включены заголовки ETag)
+	CreatedAt *time.Time `json:"created_at,omitempty"` // Время добавления строки (только в ответе на добавление)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Истечение срока хранения (только в ответе на добавление)
+}
+
+// Для отправки строки хранилища с метаданными (версия API 2)
+type DataResponseV2 struct {
+	Data string   `json:"data"`
+	Meta DataMeta `json:"meta"`
+}
+
 // Для отправки UUID строки
 type UuidHeader struct {
 	Uuid string `json:"uuid"`
 }
 
+// Ответ на добавление строки с заголовком Prefer: return=representation
+type InsertResponse struct {
+	Uuid string   `json:"uuid"`
+	Meta DataMeta `json:"meta"`
+}
+
 // Для ответа с информацией об ошибке
 type ErrorHeader struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // Машиночитаемый код ошибки взаимодействия с octet
+}
+
+// Коды ошибок взаимодействия с octet
+const (
+	ErrorCodeBackendUnavailable = "backend_unavailable" // octet не запущен или соединение разорвано
+	ErrorCodePoolExhausted      = "pool_exhausted"      // Все соединения с octet заняты
+	ErrorCodeBackendTimeout     = "backend_timeout"     // octet не ответил вовремя
+	ErrorCodeNotFound           = "not_found"           // Строка с указанным UUID не найдена
+	ErrorCodeInvalidData        = "invalid_data"        // Данные не являются строкой UTF-8
+	ErrorCodeStorageFull        = "storage_full"        // На диске octet закончилось место
+	ErrorCodeRetryLater         = "retry_later"         // octet временно не принимает изменения
+	ErrorCodeKeyLimitReached    = "key_limit_reached"   // Достигнуто максимальное количество строк
+	ErrorCodeHistoryUnavailable = "history_unavailable" // Состояние строки на момент времени не сохранилось
+	ErrorCodeValueRejected      = "value_rejected"      // Значение отклонено проверкой
+	ErrorCodeNamespaceNotFound  = "namespace_not_found" // Пространство имен не найдено
+	ErrorCodeNamespaceImmutable = "namespace_immutable" // Строки пространства имен нельзя изменять
+	ErrorCodeNamespaceForbidden = "namespace_forbidden" // Пространство имен недоступно API-ключу
+	ErrorCodePreconditionFailed = "precondition_failed" // Не выполнено условие If-Match или If-None-Match: *
+
+	ErrorCodeUnsupportedAPIVersion = "unsupported_api_version" // Запрошена неподдерживаемая версия API
+	ErrorCodeRequestTooLarge       = "request_too_large"       // Тело запроса больше допустимого
+	ErrorCodeInvalidBatch          = "invalid_batch"           // Пакет содержит некорректные операции
+	ErrorCodeWritesPaused          = "writes_paused"           // Изменения приостановлены на время согласованной выгрузки
+)
+
+// Для ответа об отклонении слишком большого тела запроса
+type BodyTooLargeError struct {
+	ErrorHeader
+	MaxBytes int64 `json:"max_bytes"` // Максимальный размер тела запроса
 }
 
 // Ответ на запрос проверки работоспособности
 type HealthCheckResponse struct {
-	Status    string `json:"status"`
-	Timestamp string `json:"timestamp"`
+	Status    string         `json:"status"`             // ok или degraded
+	Degraded  string         `json:"degraded,omitempty"` // Причина деградации (файл сокета octet удален или заменен)
+	Timestamp string         `json:"timestamp"`
+	Details   *HealthDetails `json:"details,omitempty"` // Только при ?detailed=true
+}
+
+// Сводные счетчики для подробной проверки работоспособности
+type HealthDetails struct {
+	Keys          uint64 `json:"keys"`           // Количество записей в хранилище octet
+	Mutations     uint64 `json:"mutations"`      // Успешные изменения данных с момента запуска
+	UptimeSeconds int64  `json:"uptime_seconds"` // Время работы сервера
 }
 
 // Handler содержит обработчики HTTP-запросов
 type Handler struct {
 	clientPool *service.ClientPool
 	logger     *zap.Logger
+	keyStore   *auth.KeyStore
+	cache      config.CacheConfig
+	recorder   *service.TrafficRecorder
+	faults     *service.FaultInjector
+	info       ServerInfo
+	stats      *RequestStats
+	startedAt  time.Time
+	outbox     *events.Outbox
+	webhooks   []*events.Webhook
+	batch      config.BatchConfig
+	timeTravel config.TimeTravelConfig
+	bandwidth  *Bandwidth // Учет трафика клиентов (nil - отключен)
+	coalescer  *service.WriteCoalescer
+	health     *service.HealthMonitor
+
+	softLimits     config.SoftLimitsConfig
+	namespaces     config.NamespacesConfig
+	requestJournal *RequestJournal
+	tombstones     *service.Tombstones
+	warmup         *service.Warmup
+	upgrader       *service.Upgrader
+	drainer        *Drainer
+	streams        *Streams
+	dedup          *Deduplicator
+	idempotency    *service.Idempotency
+	keyLimit       *service.KeyLimit
+	stateEvents    *service.StateEvents
+	metrics        []MetricsWriter
+	interceptors   Interceptors
+	validator      *service.Validator
+	socketWatcher  *service.SocketWatcher
+	processHistory *service.ProcessHistory
+	healthProbe    *HealthProbe
+	// Упорядоченная запись (nil - отключена)
+	ordered       *service.OrderedWriter
+	orderedWrites config.OrderedWritesConfig
+	// Удаление строк по политикам хранения (nil - политики не заданы)
+	retention *service.Retention
+	// Типы содержимого двоичных значений
+	binary config.BinaryConfig
+	// Окончательное удаление строк (nil - недоступно)
+	purger *service.Purger
+	// Максимальный размер записи импорта (0 - без ограничения)
+	importRecordLimit int64
+	// Приостановка изменений на время согласованной выгрузки
+	writeGate *WriteGate
+	// Итоговая конфигурация без секретов (nil - недоступна)
+	effectiveConfig *config.Config
 }
 
 // HealthCheck godoc
@@ -42,48 +168,108 @@ type Handler struct {
 // @Description Проверка, работает ли сервис и менеджер хранилища
 // @Tags health
 // @Produce json
+// @Param detailed query bool false "Добавить в ответ сводные счетчики"
 // @Success 200 {object} HealthCheckResponse
+// @Failure 429 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
 // @Router /health [get]
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	// Получаем клиент из пула
-	client, err := h.clientPool.GetClient()
+	// Проверяем подключение к octet (результат недавней проверки используется повторно)
+	detailed := r.URL.Query().Get("detailed") == "true"
+	keys, checkedAt, err := h.healthProbe.Check(r.Context(), detailed)
 	if err != nil {
-		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Сервер недоступен")
+		respondWithServiceError(w, err, "Сервер недоступен")
 		return
 	}
 
-	// Проверяем подключение к octet
-	if err := client.Ping(r.Context()); err != nil {
-		h.logger.Error("Не удалось выполнить octet::ping", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Сервер недоступен")
-		return
+	response := HealthCheckResponse{Status: "ok"}
+	if detailed {
+		response.Details = &HealthDetails{
+			Keys:          keys,
+			Mutations:     h.clientPool.Stats().Mutations,
+			UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		}
 	}
 
-	// Все хорошо, отправляем ответ
-	respondWithJSON(w, http.StatusOK, HealthCheckResponse{
-		Status:    "ok",
-		Timestamp: time.Now().Format(time.RFC3339),
-	})
+	// Соединения пула работают, но новые соединения с octet невозможны
+	if status := h.socketWatcher.Status(); status.Degraded {
+		response.Status = "degraded"
+		response.Degraded = status.Reason
+	}
+
+	// Отправляем ответ (время - момент проверки подключения)
+	response.Timestamp = checkedAt.Format(time.RFC3339)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Ответ проверки готовности
+type ReadinessResponse struct {
+	Status string                `json:"status"`           // ready, warming_up или draining
+	Warmup *service.WarmupResult `json:"warmup,omitempty"` // Итоги прогрева (если он выполнялся)
+}
+
+// Readiness godoc
+// @Summary Проверка готовности
+// @Description Готов ли сервер принимать запросы: до завершения прогрева после запуска
+// @Description и после вывода из балансировки (POST /admin/v1/drain) возвращается 503
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /ready [get]
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.drainer.Draining() {
+		respondWithJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Status: "draining"})
+		return
+	}
+	if h.warmup == nil {
+		respondWithJSON(w, http.StatusOK, ReadinessResponse{Status: "ready"})
+		return
+	}
+	if !h.warmup.Done() {
+		respondWithJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Status: "warming_up"})
+		return
+	}
+	result := h.warmup.Result()
+	respondWithJSON(w, http.StatusOK, ReadinessResponse{Status: "ready", Warmup: &result})
 }
 
 // Insert godoc
 // @Summary Добавление новой строки
-// @Description Сохранение строки UTF-8 и получение UUID
+// @Description Сохранение строки UTF-8 и получение UUID. Заголовок Location содержит адрес добавленной строки.
+// @Description С заголовком Prefer: return=representation ответ содержит метаданные строки в поле meta.
+// @Description Строка с ttl_seconds удаляется octet по истечении срока хранения.
+// @Description Повтор запроса с тем же Idempotency-Key возвращает UUID строки, добавленной первым запросом.
+// @Description С Content-Type: text/plain тело целиком является значением строки (без ttl_seconds).
 // @Tags strings
-// @Accept json
+// @Accept json,plain
 // @Produce json
-// @Param data body DataHeader true "Строка для сохранения"
-// @Success 201 {object} UuidHeader
+// @Param data body InsertRequest true "Строка для сохранения"
+// @Param Prefer header string false "return=representation - ответ с метаданными строки"
+// @Param Idempotency-Key header string false "Ключ идемпотентности (до 255 символов)"
+// @Success 201 {object} InsertResponse
+// @Header 201 {string} Location "Адрес добавленной строки"
+// @Header 201 {string} ETag "Версия строки"
 // @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 413 {object} BodyTooLargeError
+// @Failure 422 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
 // @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Failure 507 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /octet/v1 [post]
 func (h *Handler) Insert(w http.ResponseWriter, r *http.Request) {
 	// Разбираем запрос
-	var insertReq DataHeader
-	if err := json.NewDecoder(r.Body).Decode(&insertReq); err != nil {
+	var insertReq InsertRequest
+	if err := h.decodeDataBody(r, &insertReq, &insertReq.Data); err != nil {
 		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Некорректный запрос")
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -92,36 +278,118 @@ func (h *Handler) Insert(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Поле 'data' не может быть пустым")
 		return
 	}
+	if len(r.Header.Get(IdempotencyKeyHeader)) > maxIdempotencyKeyLength {
+		respondWithError(w, http.StatusBadRequest, "Заголовок Idempotency-Key не может быть длиннее 255 символов")
+		return
+	}
+	if insertReq.TtlSeconds < 0 || insertReq.TtlSeconds > maxTtlSeconds {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Поле 'ttl_seconds' должно быть от 0 до %d", maxTtlSeconds))
+		return
+	}
 
-	// Получаем клиент из пула
-	client, err := h.clientPool.GetClient()
+	// Повтор добавления с тем же ключом идемпотентности получает сохраненный ответ первого запроса,
+	// даже если строка с тех пор изменена или удалена
+	var recordKey string
+	var record *service.IdempotencyRecord
+	if key := r.Header.Get(IdempotencyKeyHeader); len(key) != 0 && h.idempotency != nil {
+		requestHash := insertRequestHash(insertReq)
+		recordKey = idempotencyRecordKey(r, h.namespace(r), key, requestHash)
+		replayed, err := h.idempotency.Begin(r.Context(), recordKey, requestHash)
+		if errors.Is(err, service.ErrIdempotencyConflict) {
+			respondWithError(w, http.StatusUnprocessableEntity, "Ключ Idempotency-Key уже использован для добавления другого значения")
+			return
+		}
+		if err != nil {
+			h.logger.Error("Ошибка при проверке ключа идемпотентности", zap.Error(err))
+			respondWithServiceError(w, err, "Ошибка при проверке ключа идемпотентности: "+err.Error())
+			return
+		}
+		if replayed != nil {
+			replayInsert(w, r, replayed)
+			return
+		}
+		// Ответ сохраняется, только если строка добавлена; иначе повтор выполнится заново
+		defer func() {
+			if record != nil {
+				h.idempotency.Complete(recordKey, *record)
+			} else {
+				h.idempotency.Abort(recordKey)
+			}
+		}()
+	}
+
+	data, err := h.validateValue(r.Context(), BatchOpInsert, h.namespace(r), "", insertReq.Data)
 	if err != nil {
-		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		respondWithServiceError(w, err, "Ошибка при проверке данных")
 		return
 	}
 
 	// Отправляем запрос на создание строки
-	uuid, err := client.Insert(r.Context(), insertReq.Data)
+	uuid, sequence, err := h.insert(r.Context(), h.namespace(r), data, uint64(insertReq.TtlSeconds))
 	if err != nil {
 		h.logger.Error("Ошибка при добавлении данных", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Ошибка при добавлении данных: "+err.Error())
+		respondWithServiceError(w, err, "Ошибка при добавлении данных: "+err.Error())
 		return
 	}
 
-	// Отправляем ответ
-	respondWithJSON(w, http.StatusCreated, UuidHeader{Uuid: uuid})
+	createdAt := time.Now().UTC()
+	h.publishChange(r.Context(), events.OpInsert, uuid)
+	noteRequestUuid(r.Context(), uuid)
+	setSequence(w, sequence)
+
+	// Отправляем ответ с адресом строки (по адресу запроса, чтобы сохранить пространство имен и префиксы)
+	w.Header().Set("Location", externalPrefix(r)+strings.TrimSuffix(r.URL.Path, "/")+"/"+uuid)
+	var etag string
+	if h.cache.ETag {
+		// Добавленная строка получает версию 1
+		etag = versionETag(1)
+		w.Header().Set("ETag", etag)
+	}
+	var response any = UuidHeader{Uuid: uuid}
+	if prefers(r, "return=representation") {
+		w.Header().Set("Preference-Applied", "return=representation")
+		meta := DataMeta{Uuid: uuid, Bytes: len(data), ETag: etag, CreatedAt: &createdAt}
+		if insertReq.TtlSeconds != 0 {
+			// Срок отсчитывается octet от момента добавления, поэтому здесь он приблизителен
+			expiresAt := createdAt.Add(time.Duration(insertReq.TtlSeconds) * time.Second)
+			meta.ExpiresAt = &expiresAt
+		}
+		response = InsertResponse{Uuid: uuid, Meta: meta}
+	}
+	if len(recordKey) != 0 {
+		body, _ := json.Marshal(response)
+		record = &service.IdempotencyRecord{Uuid: uuid, Status: http.StatusCreated, Body: body}
+	}
+	respondWithJSON(w, http.StatusCreated, response)
 }
 
 // Get godoc
 // @Summary Получение строки по UUID
-// @Description Извлечение строки из хранилища по её UUID
+// @Description Извлечение строки из хранилища по её UUID.
+// @Description С Accept: text/plain возвращается значение строки без JSON-обертки.
 // @Tags strings
-// @Produce json
+// @Produce json,plain
 // @Param uuid path string true "UUID строки"
+// @Param as_of query string false "Момент времени в формате RFC 3339, на который нужно получить строку"
+// @Param If-None-Match header string false "ETag ранее полученной версии строки"
+// @Param If-Modified-Since header string false "Время изменения ранее полученной версии строки"
+// @Param X-Octet-Api-Version header integer false "Версия формата ответа (2 - с метаданными строки)"
 // @Success 200 {object} DataHeader
+// @Success 304 "Строка не изменилась"
+// @Header 200 {string} ETag "Версия строки"
+// @Header 200 {string} Cache-Control "Политика кэширования"
+// @Header 200 {string} Last-Modified "Время последнего изменения строки"
 // @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 410 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
 // @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /octet/v1/{uuid} [get]
 func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 	// Получаем UUID из URL
@@ -131,37 +399,202 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем клиент из пула
-	client, err := h.clientPool.GetClient()
+	var data string
+	var version uint64
+	var lastModified time.Time
+	if value := r.URL.Query().Get("as_of"); len(value) != 0 {
+		// Чтение строки на момент времени по журналу операций octet
+		if !h.timeTravel.Enabled {
+			respondWithError(w, http.StatusBadRequest, "Чтение на момент времени отключено")
+			return
+		}
+		asOf, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'as_of' должен быть указан в формате RFC 3339")
+			return
+		}
+		if data, err = service.ReadAsOf(r.Context(), h.clientPool, uuid, asOf); err != nil {
+			h.logger.Error("Ошибка при получении строки на момент времени", zap.Error(err))
+			respondWithServiceError(w, err, "Ошибка при получении строки: "+err.Error())
+			return
+		}
+	} else {
+		// Строка с меткой удаления считается удаленной
+		if h.tombstones.Contains(uuid) {
+			respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+			return
+		}
+
+		// Получаем клиент из пула (он возвращается после всех команд запроса,
+		// поэтому вызываются методы *service.Client)
+		client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+		if err != nil {
+			h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+			respondWithServiceError(w, err, "Внутренняя ошибка сервера")
+			return
+		}
+		defer client.Release()
+
+		// Условный запрос проверяется по метаданным строки, чтобы не передавать ее данные через сокет;
+		// они же нужны для Last-Modified
+		var stat service.EntryStat
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if (h.cache.ETag && len(ifNoneMatch) != 0) || h.cache.LastModified {
+			if stat, err = client.Client.Stat(r.Context(), uuid); err != nil {
+				h.logger.Error("Ошибка при получении метаданных строки", zap.Error(err))
+				respondWithServiceError(w, err, "Ошибка при получении строки: "+err.Error())
+				return
+			}
+			if h.cache.LastModified && !stat.ModifiedAt.IsZero() {
+				lastModified = stat.ModifiedAt
+			}
+			if etag := versionETag(stat.Version); h.cache.ETag && stat.Version != 0 && etagMatches(ifNoneMatch, etag) {
+				h.setCacheControl(w, r)
+				w.Header().Set("ETag", etag)
+				setLastModified(w, lastModified)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if notModifiedSince(r, lastModified) {
+				h.setCacheControl(w, r)
+				if h.cache.ETag && stat.Version != 0 {
+					w.Header().Set("ETag", versionETag(stat.Version))
+				}
+				setLastModified(w, lastModified)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		// Получаем строку
+		if data, version, err = client.Client.GetVersion(r.Context(), uuid); err != nil {
+			h.logger.Error("Ошибка при получении строки", zap.Error(err))
+			respondWithServiceError(w, err, "Ошибка при получении строки: "+err.Error())
+			return
+		}
+		// Строка изменена между запросами метаданных и данных: время изменения прочитанной версии неизвестно
+		if version != stat.Version {
+			lastModified = time.Time{}
+		}
+	}
+
+	// Заголовки кэширования
+	h.setCacheControl(w, r)
+	w.Header().Add("Vary", "Accept")
+	setLastModified(w, lastModified)
+	var etag string
+	if h.cache.ETag {
+		etag = entryETag(data, version)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Двоичное значение возвращается с исходным типом содержимого, текстовое - без JSON-обертки
+	// или в формате запрошенной версии API
+	if contentType, body, ok := h.binaryValue(data); ok {
+		respondWithBinary(w, http.StatusOK, contentType, body)
+		return
+	}
+	if prefersTextPlain(r) {
+		respondWithText(w, http.StatusOK, data)
+		return
+	}
+	if apiVersion(r.Context()) >= APIVersion2 {
+		respondWithJSON(w, http.StatusOK, DataResponseV2{
+			Data: data,
+			Meta: DataMeta{Uuid: uuid, Bytes: len(data), ETag: etag},
+		})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, DataHeader{Data: data})
+}
+
+// Exists godoc
+// @Summary Проверка наличия строки
+// @Description Проверка наличия строки по её UUID без передачи значения
+// @Tags strings
+// @Param uuid path string true "UUID строки"
+// @Success 200 "Строка существует"
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404 "Строка не найдена"
+// @Failure 429
+// @Failure 500
+// @Failure 503
+// @Failure 504
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/{uuid} [head]
+func (h *Handler) Exists(w http.ResponseWriter, r *http.Request) {
+	// Получаем UUID из URL
+	uuid := chi.URLParam(r, "uuid")
+	if len(uuid) == 0 {
+		respondWithError(w, http.StatusBadRequest, "UUID не указан")
+		return
+	}
+	// Строка с меткой удаления считается удаленной
+	if h.tombstones.Contains(uuid) {
+		respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+		return
+	}
+
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
 	if err != nil {
 		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		respondWithServiceError(w, err, "Внутренняя ошибка сервера")
 		return
 	}
-
-	// Получаем строку
-	data, err := client.Get(r.Context(), uuid)
+	exists, err := client.Exists(r.Context(), uuid)
 	if err != nil {
-		h.logger.Error("Ошибка при получении строки", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Ошибка при получении строки: "+err.Error())
+		h.logger.Error("Ошибка при проверке наличия строки", zap.Error(err))
+		respondWithServiceError(w, err, "Ошибка при проверке наличия строки: "+err.Error())
 		return
 	}
 
-	// Отправляем ответ
-	respondWithJSON(w, http.StatusOK, DataHeader{Data: data})
+	// Тело ответа на HEAD не передается, результат сообщается только кодом ответа
+	if !exists {
+		respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 // Update godoc
 // @Summary Обновление существующей строки
-// @Description Обновление строки по её UUID
+// @Description Обновление строки по её UUID.
+// @Description С заголовком If-Match строка обновляется, только если ее текущий ETag (версия) совпадает с одним
+// @Description из указанных, иначе возвращается 412 - так параллельные изменения не перезаписывают друг друга.
+// @Description С заголовком If-None-Match: * строка создается с UUID из адреса (версии 4 в нижнем регистре),
+// @Description только если ее еще нет (201), иначе возвращается 412.
+// @Description С Content-Type: text/plain тело целиком является новым значением строки.
 // @Tags strings
-// @Accept json
+// @Accept json,plain
 // @Produce json
 // @Param uuid path string true "UUID строки"
+// @Param If-Match header string false "ETag строки, полученный при чтении"
+// @Param If-None-Match header string false "* - создание строки, если ее еще нет"
 // @Param data body DataHeader true "Новое значение строки"
+// @Success 201 {object} UuidHeader
 // @Success 204
 // @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 405 {object} ErrorHeader
+// @Failure 412 {object} ErrorHeader
+// @Failure 413 {object} BodyTooLargeError
+// @Failure 422 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
 // @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Failure 507 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /octet/v1/{uuid} [put]
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	// Получаем UUID из URL
@@ -170,12 +603,24 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "UUID не указан")
 		return
 	}
+	if createOnly(r) {
+		h.createRow(w, r, uuid)
+		return
+	}
+	if h.immutable(r) {
+		respondImmutable(w, h.namespace(r))
+		return
+	}
+	if h.tombstones.Contains(uuid) {
+		respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+		return
+	}
 
 	// Разбираем запрос
 	var updateReq DataHeader
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+	if err := h.decodeDataBody(r, &updateReq, &updateReq.Data); err != nil {
 		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
-		respondWithError(w, http.StatusBadRequest, "Некорректный запрос")
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -185,20 +630,42 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем клиент из пула
-	client, err := h.clientPool.GetClient()
+	data, err := h.validateValue(r.Context(), BatchOpUpdate, h.namespace(r), uuid, updateReq.Data)
 	if err != nil {
-		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+		respondWithServiceError(w, err, "Ошибка при проверке данных")
 		return
 	}
 
+	// Определяем ожидаемую версию строки по If-Match
+	ifMatch := r.Header.Get("If-Match")
+	var expectedVersion uint64
+	if len(ifMatch) != 0 {
+		expectedVersion, err = h.ifMatchVersion(r.Context(), uuid, ifMatch)
+		if errors.Is(err, service.ErrNotFound) {
+			// Условие If-Match не выполняется для отсутствующей строки
+			err = service.ErrVersionMismatch
+		}
+		if err != nil {
+			respondWithServiceError(w, err, "Ошибка при проверке версии строки: "+err.Error())
+			return
+		}
+	}
+
 	// Обновляем строку
-	if err := client.Update(r.Context(), uuid, updateReq.Data); err != nil {
+	sequence, err := h.update(r.Context(), h.namespace(r), uuid, data, expectedVersion)
+	if err != nil {
+		if len(ifMatch) != 0 && errors.Is(err, service.ErrNotFound) {
+			err = service.ErrVersionMismatch
+		}
 		h.logger.Error("Ошибка при обновлении строки", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Ошибка при обновлении строки: "+err.Error())
+		respondWithServiceError(w, err, "Ошибка при обновлении строки: "+err.Error())
 		return
 	}
+	h.publishChange(r.Context(), events.OpUpdate, uuid)
+	setSequence(w, sequence)
+	if expectedVersion != 0 && h.cache.ETag {
+		w.Header().Set("ETag", versionETag(expectedVersion+1))
+	}
 
 	// Отправляем ответ
 	w.WriteHeader(http.StatusNoContent)
@@ -206,12 +673,25 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 
 // Remove godoc
 // @Summary Удаление строки
-// @Description Удаление строки по её UUID
+// @Description Удаление строки по её UUID.
+// @Description С заголовком Prefer: respond-async (если включено асинхронное удаление) сервер записывает
+// @Description метку удаления и отвечает 202, а строка удаляется из octet в фоне.
 // @Tags strings
 // @Param uuid path string true "UUID строки"
+// @Param Prefer header string false "respond-async - асинхронное удаление"
+// @Success 202
 // @Success 204
 // @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 405 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
 // @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /octet/v1/{uuid} [delete]
 func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
 	// Получаем UUID из URL
@@ -220,41 +700,258 @@ func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "UUID не указан")
 		return
 	}
+	if h.immutable(r) {
+		respondImmutable(w, h.namespace(r))
+		return
+	}
+	if h.tombstones.Contains(uuid) {
+		respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+		return
+	}
 
-	// Получаем клиент из пула
-	client, err := h.clientPool.GetClient()
-	if err != nil {
-		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	// Асинхронное удаление: ответ после записи метки, удаление из octet в фоне.
+	// В пространстве имен с упорядоченной записью удаление выполняется в очереди.
+	if h.tombstones != nil && h.orderedFor(h.namespace(r)) == nil && prefers(r, "respond-async") {
+		if _, err := h.tombstones.Add(uuid); err != nil {
+			h.logger.Error("Ошибка при записи метки удаления", zap.Error(err))
+			respondWithServiceError(w, err, "Ошибка при удалении строки: "+err.Error())
+			return
+		}
+		h.publishChange(r.Context(), events.OpRemove, uuid)
+		w.Header().Set("Preference-Applied", "respond-async")
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
 	// Удаляем строку
-	if err := client.Remove(r.Context(), uuid); err != nil {
+	sequence, err := h.remove(r.Context(), h.namespace(r), uuid)
+	if err != nil {
 		h.logger.Error("Ошибка при удалении строки", zap.Error(err))
-		respondWithError(w, http.StatusInternalServerError, "Ошибка при удалении строки: "+err.Error())
+		respondWithServiceError(w, err, "Ошибка при удалении строки: "+err.Error())
 		return
 	}
+	h.publishChange(r.Context(), events.OpRemove, uuid)
+	setSequence(w, sequence)
 
 	// Отправляем ответ (204 No Content)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Выполнение octet::insert в очереди упорядоченной записи (для таких пространств имен, с возвратом номера изменения),
+// в составе пакета (если включено объединение изменений) или отдельным запросом на клиенте пула
+func (h *Handler) insert(ctx context.Context, namespace, data string, ttlSeconds uint64) (string, uint64, error) {
+	if ordered := h.orderedFor(namespace); ordered != nil {
+		result := ordered.Insert(ctx, data, ttlSeconds)
+		return result.Uuid, result.Sequence, result.Err
+	}
+	if h.coalescer != nil {
+		uuid, err := h.coalescer.Insert(ctx, data, ttlSeconds)
+		return uuid, 0, err
+	}
+	client, err := h.clientPool.GetClientFor(service.PoolClassWrite)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		return "", 0, err
+	}
+	uuid, err := client.InsertWithTTL(ctx, data, ttlSeconds)
+	return uuid, 0, err
+}
+
+// Проверка значения перед добавлением или изменением (без модуля проверки значение не меняется)
+func (h *Handler) validateValue(ctx context.Context, op, namespace, uuid, data string) (string, error) {
+	if h.validator == nil {
+		return data, nil
+	}
+	meta := map[string]string{"namespace": namespace}
+	if len(uuid) != 0 {
+		meta["uuid"] = uuid
+	}
+	if identity := auth.IdentityFromContext(ctx); identity != nil {
+		meta["identity"] = identity.Name
+	}
+	result, err := h.validator.Validate(op, data, meta)
+	if err != nil && !errors.Is(err, service.ErrValueRejected) {
+		h.logger.Error("Ошибка проверки значения", zap.String("op", op), zap.Error(err))
+	}
+	return result, err
+}
+
+// Выполнение octet::update в очереди упорядоченной записи, в составе пакета или отдельным запросом
+func (h *Handler) update(ctx context.Context, namespace, uuid, data string, expectedVersion uint64) (uint64, error) {
+	if ordered := h.orderedFor(namespace); ordered != nil {
+		result := ordered.Update(ctx, uuid, data, expectedVersion)
+		return result.Sequence, result.Err
+	}
+	if h.coalescer != nil {
+		return 0, h.coalescer.Update(ctx, uuid, data, expectedVersion)
+	}
+	client, err := h.clientPool.GetClientFor(service.PoolClassWrite)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		return 0, err
+	}
+	return 0, client.UpdateIf(ctx, uuid, data, expectedVersion)
+}
+
+// Выполнение octet::remove в очереди упорядоченной записи, в составе пакета или отдельным запросом
+func (h *Handler) remove(ctx context.Context, namespace, uuid string) (uint64, error) {
+	if ordered := h.orderedFor(namespace); ordered != nil {
+		result := ordered.Remove(ctx, uuid)
+		return result.Sequence, result.Err
+	}
+	if h.coalescer != nil {
+		return 0, h.coalescer.Remove(ctx, uuid)
+	}
+	client, err := h.clientPool.GetClientFor(service.PoolClassWrite)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		return 0, err
+	}
+	return 0, client.Remove(ctx, uuid)
+}
+
+// Запрошено ли предпочтение в заголовке Prefer (RFC 7240)
+func prefers(r *http.Request, preference string) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, value := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(value), preference) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Запись события об изменении данных для подписчиков.
+// Изменение уже применено, поэтому ошибка записи события только логируется.
+func (h *Handler) publishChange(ctx context.Context, op, uuid string) {
+	if h.outbox == nil {
+		return
+	}
+	if _, err := h.outbox.Publish(op, uuid, service.TraceContextFrom(ctx)); err != nil {
+		h.logger.Error("Не удалось записать событие изменения данных",
+			zap.String("op", op), zap.String("uuid", uuid), zap.Error(err))
+	}
+}
+
 // respondWithError отправляет клиенту ответ с ошибкой
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, ErrorHeader{Error: message})
 }
 
-// respondWithJSON отправляет клиенту ответ в формате JSON
+// respondWithDecodeError отправляет клиенту ответ с ошибкой разбора тела запроса:
+// 413, если тело превысило допустимый размер, 422 для значения со служебным префиксом двоичных значений, иначе 400
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondBodyTooLarge(w, tooLarge.Limit)
+		return
+	}
+	if errors.Is(err, errInvalidText) {
+		respondWithError(w, http.StatusBadRequest, "Тело text/plain должно быть в кодировке UTF-8")
+		return
+	}
+	if errors.Is(err, errReservedValue) {
+		respondWithServiceError(w, err, "")
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Некорректный запрос")
+}
+
+// respondWithServiceError отправляет клиенту ответ с ошибкой взаимодействия с octet.
+// Статус выбирается по классу ошибки: недоступность octet - 503, нет свободных
+// соединений - 429, octet не ответил вовремя - 504, некорректные или отклоненные данные - 422,
+// нет места в хранилище или достигнуто максимальное количество строк - 507, состояние на момент времени не сохранилось - 410,
+// octet временно не принимает изменения - 503 с рекомендованной им задержкой в Retry-After,
+// остальные ошибки - 500.
+func respondWithServiceError(w http.ResponseWriter, err error, message string) {
+	status, response := serviceErrorResponse(err, message)
+	if retryAfter := service.RetryAfter(err); retryAfter > 0 {
+		// Retry-After задается в целых секундах, задержка округляется вверх
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	} else if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	respondWithJSON(w, status, response)
+}
+
+// Код ответа и описание ошибки сервисного слоя
+func serviceErrorResponse(err error, message string) (int, ErrorHeader) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, ErrorHeader{
+			Error: "Строка не найдена",
+			Code:  ErrorCodeNotFound,
+		}
+	case errors.Is(err, service.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, ErrorHeader{
+			Error: "Строка изменилась: ее версия не совпадает с If-Match",
+			Code:  ErrorCodePreconditionFailed,
+		}
+	case errors.Is(err, service.ErrAlreadyExists):
+		return http.StatusPreconditionFailed, ErrorHeader{
+			Error: "Строка с указанным UUID уже существует",
+			Code:  ErrorCodePreconditionFailed,
+		}
+	case errors.Is(err, service.ErrHistoryUnavailable):
+		return http.StatusGone, ErrorHeader{
+			Error: "Состояние строки на указанный момент не сохранилось в журнале операций",
+			Code:  ErrorCodeHistoryUnavailable,
+		}
+	case errors.Is(err, service.ErrInvalidData):
+		return http.StatusUnprocessableEntity, ErrorHeader{
+			Error: "Данные должны быть строкой UTF-8",
+			Code:  ErrorCodeInvalidData,
+		}
+	case errors.Is(err, service.ErrValueRejected):
+		return http.StatusUnprocessableEntity, ErrorHeader{
+			Error: err.Error(),
+			Code:  ErrorCodeValueRejected,
+		}
+	case errors.Is(err, service.ErrStorageFull):
+		return http.StatusInsufficientStorage, ErrorHeader{
+			Error: "Недостаточно места в хранилище",
+			Code:  ErrorCodeStorageFull,
+		}
+	case errors.Is(err, service.ErrRetryLater):
+		return http.StatusServiceUnavailable, ErrorHeader{
+			Error: "Хранилище временно не принимает изменения, повторите запрос позже",
+			Code:  ErrorCodeRetryLater,
+		}
+	case errors.Is(err, service.ErrKeyLimitReached):
+		return http.StatusInsufficientStorage, ErrorHeader{
+			Error: "Достигнуто максимальное количество строк в хранилище",
+			Code:  ErrorCodeKeyLimitReached,
+		}
+	case errors.Is(err, service.ErrTimeout):
+		return http.StatusGatewayTimeout, ErrorHeader{
+			Error: "Превышено время ожидания ответа хранилища",
+			Code:  ErrorCodeBackendTimeout,
+		}
+	case errors.Is(err, service.ErrPoolExhausted):
+		return http.StatusTooManyRequests, ErrorHeader{
+			Error: "Все соединения с хранилищем заняты, повторите запрос позже",
+			Code:  ErrorCodePoolExhausted,
+		}
+	case errors.Is(err, service.ErrBackendUnavailable):
+		return http.StatusServiceUnavailable, ErrorHeader{
+			Error: "Хранилище недоступно",
+			Code:  ErrorCodeBackendUnavailable,
+		}
+	default:
+		return http.StatusInternalServerError, ErrorHeader{Error: message}
+	}
+}
+
+// respondWithJSON отправляет клиенту ответ в формате JSON.
+// Ответ кодируется сразу в ResponseWriter с параметрами, выбранными JSONEncodingMiddleware.
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
+	w.Header()["Content-Type"] = jsonContentType
+
+	responder := getJSONResponder(w, code, jsonEncodingOf(w))
+	defer putJSONResponder(responder)
+	if err := responder.encoder.Encode(payload); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"error":"Ошибка при формировании ответа"}`))
-		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
 }
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Оценка состояния octet и решения о его перезапуске
+type BackendHealthResponse struct {
+	AutoRestart bool                     `json:"auto_restart"` // Включен ли автоматический перезапуск
+	Report      service.HealthReport     `json:"report"`
+	Decisions   []service.HealthDecision `json:"decisions"` // Решения о перезапуске, начиная с последнего
+}
+
+// BackendHealth godoc
+// @Summary Оценка состояния octet
+// @Description Доля неуспешных команд и 99-й процентиль их длительности за окно оценки,
+// @Description запас до порогов и решения о перезапуске octet, принятые при длительном нарушении порогов
+// @Tags admin
+// @Produce json
+// @Success 200 {object} BackendHealthResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/health [get]
+func (h *Handler) BackendHealth(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, BackendHealthResponse{
+		AutoRestart: h.health.AutoRestart(),
+		Report:      h.health.Report(),
+		Decisions:   h.health.Decisions(),
+	})
+}
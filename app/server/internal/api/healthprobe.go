@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Результат проверки подключения к octet для /health
+type healthVerdict struct {
+	done      chan struct{} // Закрывается по завершении проверки
+	keys      uint64        // Количество записей (только для подробной проверки)
+	err       error
+	checkedAt time.Time
+}
+
+// Проверка подключения к octet для /health. Одновременные запросы /health ожидают
+// одну и ту же проверку, а ее результат используется повторно в течение maxStale,
+// поэтому частые запросы оркестраторов не занимают клиенты пула.
+type HealthProbe struct {
+	pool     *service.ClientPool
+	maxStale time.Duration
+	logger   *zap.Logger
+
+	mutex    sync.Mutex
+	verdicts [2]*healthVerdict // Последние проверки: octet::ping и octet::stats (для ?detailed=true)
+}
+
+// Создание проверки подключения (maxStale = 0 - объединяются только одновременные проверки)
+func NewHealthProbe(pool *service.ClientPool, maxStale time.Duration, logger *zap.Logger) *HealthProbe {
+	return &HealthProbe{pool: pool, maxStale: maxStale, logger: logger}
+}
+
+// Результат проверки подключения не старше maxStale и время его получения.
+// При detailed проверка выполняется запросом статистики и возвращает количество записей.
+func (p *HealthProbe) Check(ctx context.Context, detailed bool) (uint64, time.Time, error) {
+	slot := 0
+	if detailed {
+		slot = 1
+	}
+
+	p.mutex.Lock()
+	verdict := p.verdicts[slot]
+	if verdict == nil || p.stale(verdict) {
+		verdict = &healthVerdict{done: make(chan struct{})}
+		p.verdicts[slot] = verdict
+		// Проверка не прерывается отменой запроса, который ее начал:
+		// ее результата могут ожидать другие запросы
+		go p.run(context.WithoutCancel(ctx), verdict, detailed)
+	}
+	p.mutex.Unlock()
+
+	select {
+	case <-verdict.done:
+		return verdict.keys, verdict.checkedAt, verdict.err
+	case <-ctx.Done():
+		return 0, time.Time{}, ctx.Err()
+	}
+}
+
+// Устарел ли завершенный результат проверки. Вызывается под блокировкой.
+func (p *HealthProbe) stale(verdict *healthVerdict) bool {
+	select {
+	case <-verdict.done:
+		return time.Since(verdict.checkedAt) >= p.maxStale
+	default:
+		// Проверка еще выполняется
+		return false
+	}
+}
+
+// Выполнение проверки и публикация ее результата.
+// Ошибка логируется один раз на проверку, а не на каждый запрос /health.
+func (p *HealthProbe) run(ctx context.Context, verdict *healthVerdict, detailed bool) {
+	defer close(verdict.done)
+	defer func() { verdict.checkedAt = time.Now() }()
+
+	client, err := p.pool.GetClient()
+	if err != nil {
+		p.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		verdict.err = err
+		return
+	}
+	if detailed {
+		// Запрос статистики одновременно проверяет подключение к octet
+		if verdict.keys, verdict.err = client.Stats(ctx); verdict.err != nil {
+			p.logger.Error("Не удалось выполнить octet::stats", zap.Error(verdict.err))
+		}
+	} else if verdict.err = client.Ping(ctx); verdict.err != nil {
+		p.logger.Error("Не удалось выполнить octet::ping", zap.Error(verdict.err))
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/metastore"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Создание сохранения результатов добавлений с ключом идемпотентности (nil, если оно отключено)
+func newIdempotency(cfg config.IdempotencyConfig, store metastore.Store, logger *zap.Logger) *service.Idempotency {
+	if !cfg.Enabled {
+		return nil
+	}
+	return service.NewIdempotency(service.IdempotencyConfig{
+		TTL:        time.Duration(cfg.TTLSeconds) * time.Second,
+		MaxEntries: cfg.MaxEntries,
+	}, store, logger)
+}
+
+// Хеш запроса на добавление: повтор с тем же ключом идемпотентности должен добавлять то же значение
+func insertRequestHash(req InsertRequest) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strconv.FormatInt(req.TtlSeconds, 10) + "\x00" + req.Data))
+}
+
+// Ключ результата добавления: клиент, пространство имен и ключ идемпотентности.
+// Без аутентификации клиент определяется только IP-адресом, общим для клиентов за одним NAT,
+// поэтому в ключ входит и хеш запроса: такие клиенты получают общий ответ только на одинаковые
+// запросы, а совпадение их ключей у разных запросов не считается конфликтом.
+func idempotencyRecordKey(r *http.Request, namespace, key string, requestHash [sha256.Size]byte) string {
+	recordKey := requestClient(r) + "\x00" + namespace + "\x00" + key
+	if auth.IdentityFromContext(r.Context()) == nil {
+		recordKey += "\x00" + hex.EncodeToString(requestHash[:])
+	}
+	return recordKey
+}
+
+// Отправка сохраненного ответа на добавление с тем же ключом идемпотентности.
+// ETag не передается, так как строка могла измениться после добавления.
+func replayInsert(w http.ResponseWriter, r *http.Request, record *service.IdempotencyRecord) {
+	noteRequestUuid(r.Context(), record.Uuid)
+	w.Header().Set("Location", externalPrefix(r)+strings.TrimSuffix(r.URL.Path, "/")+"/"+record.Uuid)
+	w.Header().Set(ReplayedHeader, "true")
+	respondWithJSON(w, record.Status, json.RawMessage(record.Body))
+}
@@ -0,0 +1,363 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Тип тела запроса импорта
+const importContentType = "application/x-ndjson"
+
+// Максимальное количество ошибок записей в ответе импорта
+const maxImportErrors = 100
+
+// Максимальное количество итогов записей в ответе импорта
+const maxImportRecords = 1000
+
+// Время ожидания следующей записи тела импорта
+const importReadTimeout = 60 * time.Second
+
+// Запись тела импорта превышает допустимый размер
+var errImportRecordTooLarge = errors.New("размер записи превышает допустимый")
+
+// Политика импорта записи, строка с UUID которой уже есть в хранилище
+type importConflict string
+
+const (
+	importConflictOverwrite importConflict = "overwrite" // Строка обновляется значением записи
+	importConflictSkip      importConflict = "skip"      // Запись пропускается, строка не меняется
+	importConflictFail      importConflict = "fail"      // Запись считается ошибкой, строка не меняется
+	importConflictRename    importConflict = "rename"    // Запись добавляется строкой с новым UUID
+)
+
+// Итоги импорта записи (поле outcome)
+const (
+	ImportOutcomeInserted = "inserted" // Добавлена строка
+	ImportOutcomeUpdated  = "updated"  // Обновлена существующая строка
+	ImportOutcomeSkipped  = "skipped"  // Запись пропущена
+	ImportOutcomeRenamed  = "renamed"  // Строка с UUID записи уже есть, добавлена строка с новым UUID
+	ImportOutcomeFailed   = "failed"   // Запись не удалось импортировать
+)
+
+// Итог импорта одной записи
+type ImportRecord struct {
+	Line int    `json:"line"`           // Номер строки тела запроса (с 1)
+	Uuid string `json:"uuid,omitempty"` // UUID строки в хранилище
+	// UUID из записи, если строка получила другой UUID
+	SourceUuid string `json:"source_uuid,omitempty"`
+	Outcome    string `json:"outcome"`
+}
+
+// Ошибка импорта одной записи
+type ImportError struct {
+	Line  int    `json:"line"` // Номер строки тела запроса (с 1)
+	Uuid  string `json:"uuid,omitempty"`
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // Машиночитаемый код ошибки взаимодействия с octet
+}
+
+// Итог импорта
+type ImportResponse struct {
+	Inserted int `json:"inserted"` // Добавлено строк
+	Updated  int `json:"updated"`  // Обновлено существующих строк
+	Renamed  int `json:"renamed"`  // Добавлено строк с новым UUID вместо существующих (conflict=rename)
+	// Пропущено записей: строк, срок хранения которых уже истек, и существующих строк (conflict=skip)
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"` // Записей, которые не удалось импортировать
+	// Итоги первых записей (не больше 1000)
+	Records []ImportRecord `json:"records,omitempty"`
+	// Первые ошибки записей (не больше 100)
+	Errors []ImportError `json:"errors,omitempty"`
+	// Причина прерывания импорта (отсутствует, если тело прочитано полностью)
+	Error string `json:"error,omitempty"`
+}
+
+// Является ли запрос импортом: его тело читается потоком и ограничивается построчно в обработчике
+func isImportRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/import")
+}
+
+// Import godoc
+// @Summary Импорт строк
+// @Description Загрузка строк в формате выгрузки (NDJSON, одна запись {uuid, data} в строке тела).
+// @Description Если строки с UUID записи нет в хранилище, создается строка с этим UUID
+// @Description (UUID версии 4 в нижнем регистре; иначе octet выдает строке новый UUID). Записи без UUID
+// @Description и записи строк с меткой удаления добавляются с новыми UUID.
+// @Description Запись существующей строки обрабатывается по политике conflict: overwrite - строка обновляется,
+// @Description skip - запись пропускается, fail - запись считается ошибкой, rename - добавляется строка с новым UUID.
+// @Description Ответ содержит итог каждой из первых 1000 записей. Тело читается потоком,
+// @Description поэтому размер импорта не ограничен, а размер одной записи ограничен request_limits.max_body_bytes.
+// @Description Запись с expires_at (выгрузка с include_metadata=true) создает строку с оставшимся сроком хранения,
+// @Description а запись с истекшим сроком пропускается; срок хранения существующей строки не меняется.
+// @Description Пространство имен, версия и время из записи не восстанавливаются.
+// @Description Ошибка записи не прерывает импорт; если не удалось импортировать хотя бы одну запись, возвращается 207.
+// @Tags strings
+// @Accept application/x-ndjson
+// @Produce json
+// @Param conflict query string false "Политика для существующих строк: overwrite (по умолчанию), skip, fail или rename"
+// @Param request body ExportEntry true "Записи выгрузки"
+// @Success 200 {object} ImportResponse
+// @Success 207 {object} ImportResponse
+// @Failure 400 {object} ImportResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 415 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/import [post]
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != importContentType {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type должен быть "+importContentType)
+		return
+	}
+	conflict := importConflictOverwrite
+	if value := r.URL.Query().Get("conflict"); len(value) != 0 {
+		conflict = importConflict(value)
+		switch conflict {
+		case importConflictOverwrite, importConflictSkip, importConflictFail, importConflictRename:
+		default:
+			respondWithError(w, http.StatusBadRequest, "Параметр 'conflict' должен быть одним из: overwrite, skip, fail, rename")
+			return
+		}
+	}
+
+	// Импорт может длиться дольше таймаутов сервера, поэтому вместо них ограничивается ожидание каждой записи.
+	// Отмена запроса не прерывает импорт: он завершится ошибкой чтения тела.
+	ctx := context.WithoutCancel(r.Context())
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	namespace := h.namespace(r)
+	reader := bufio.NewReaderSize(r.Body, 64<<10)
+	var response ImportResponse
+	var line []byte
+	for number := 1; ; number++ {
+		controller.SetReadDeadline(time.Now().Add(importReadTimeout))
+		var err error
+		line, err = readImportLine(reader, line, h.importRecordLimit)
+		if errors.Is(err, errImportRecordTooLarge) {
+			response.fail(number, "", fmt.Sprintf("Размер записи превышает допустимый (%d байт)", h.importRecordLimit), "")
+			continue
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			h.importRecord(ctx, namespace, conflict, number, line, &response)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			h.logger.Error("Импорт прерван: ошибка чтения тела запроса", zap.Int("line", number), zap.Error(err))
+			response.Error = fmt.Sprintf("Импорт прерван на строке %d: ошибка чтения тела запроса: %v", number, err)
+			respondWithJSON(w, http.StatusBadRequest, response)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if response.Failed != 0 {
+		status = http.StatusMultiStatus
+	}
+	respondWithJSON(w, status, response)
+}
+
+// Импорт одной записи
+func (h *Handler) importRecord(ctx context.Context, namespace string, conflict importConflict, number int, line []byte,
+	response *ImportResponse) {
+	var entry ExportEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		response.fail(number, "", "Некорректная запись: "+err.Error(), "")
+		return
+	}
+	if len(entry.Uuid) != 0 && !isCanonicalUUID(entry.Uuid) {
+		response.fail(number, entry.Uuid, fmt.Sprintf("'%s' не является UUID", entry.Uuid), "")
+		return
+	}
+	if len(entry.Data) == 0 {
+		response.fail(number, entry.Uuid, "Поле 'data' не может быть пустым", "")
+		return
+	}
+	// Срок хранения восстанавливается по моменту истечения, округляя оставшееся время вверх
+	var ttlSeconds uint64
+	if entry.ExpiresAt != nil {
+		remaining := time.Until(*entry.ExpiresAt)
+		if remaining <= 0 {
+			response.record(number, entry.Uuid, entry.Uuid, ImportOutcomeSkipped)
+			return
+		}
+		if remaining > maxTtlSeconds*time.Second {
+			response.fail(number, entry.Uuid, fmt.Sprintf("Срок хранения строки превышает %d секунд", maxTtlSeconds), "")
+			return
+		}
+		ttlSeconds = uint64(math.Ceil(remaining.Seconds()))
+	}
+
+	// Строка с меткой удаления считается удаленной, поэтому запись добавляется заново
+	known := len(entry.Uuid) != 0 && !h.tombstones.Contains(entry.Uuid)
+	// Существующая строка изменяется только с conflict=overwrite
+	update := known && conflict == importConflictOverwrite
+	if update && h.namespaces.IsImmutable(namespace) {
+		immutable := immutableError(namespace)
+		response.fail(number, entry.Uuid, immutable.Error, immutable.Code)
+		return
+	}
+
+	op := BatchOpInsert
+	if update {
+		op = BatchOpUpdate
+	}
+	if err := h.checkReservedValue(entry.Data, true); err != nil {
+		response.failWith(number, entry.Uuid, err)
+		return
+	}
+	data, err := h.validateValue(ctx, op, namespace, entry.Uuid, entry.Data)
+	if err != nil {
+		response.failWith(number, entry.Uuid, err)
+		return
+	}
+
+	outcome := ImportOutcomeInserted
+	if update {
+		// octet::update не меняет срок хранения, поэтому у существующей строки остается прежний
+		_, err = h.update(ctx, namespace, entry.Uuid, data, 0)
+		if err == nil {
+			h.publishChange(ctx, events.OpUpdate, entry.Uuid)
+			response.record(number, entry.Uuid, entry.Uuid, ImportOutcomeUpdated)
+			return
+		}
+		if !errors.Is(err, service.ErrNotFound) {
+			response.failWith(number, entry.Uuid, err)
+			return
+		}
+		// Строки нет в хранилище: создаем ее с UUID из выгрузки, чтобы ссылки на строку остались действительными
+		if isOctetUUID(entry.Uuid) {
+			if _, err := h.create(ctx, namespace, entry.Uuid, data, ttlSeconds); err != nil {
+				response.failWith(number, entry.Uuid, err)
+				return
+			}
+			h.publishChange(ctx, events.OpInsert, entry.Uuid)
+			response.record(number, entry.Uuid, entry.Uuid, ImportOutcomeInserted)
+			return
+		}
+	} else if known {
+		exists, err := h.importCreate(ctx, namespace, entry.Uuid, data, ttlSeconds)
+		if err != nil {
+			response.failWith(number, entry.Uuid, err)
+			return
+		}
+		if !exists && isOctetUUID(entry.Uuid) {
+			h.publishChange(ctx, events.OpInsert, entry.Uuid)
+			response.record(number, entry.Uuid, entry.Uuid, ImportOutcomeInserted)
+			return
+		}
+		if exists {
+			switch conflict {
+			case importConflictSkip:
+				response.record(number, entry.Uuid, entry.Uuid, ImportOutcomeSkipped)
+				return
+			case importConflictFail:
+				response.failWith(number, entry.Uuid, service.ErrAlreadyExists)
+				return
+			}
+			outcome = ImportOutcomeRenamed
+		}
+	}
+
+	uuid, _, err := h.insert(ctx, namespace, data, ttlSeconds)
+	if err != nil {
+		response.failWith(number, entry.Uuid, err)
+		return
+	}
+	h.publishChange(ctx, events.OpInsert, uuid)
+	response.record(number, entry.Uuid, uuid, outcome)
+}
+
+// Создание строки с UUID записи, если ее еще нет в хранилище. Возвращает true, если строка уже есть.
+// Строку с UUID не версии 4 в нижнем регистре octet создать не может, поэтому для нее только проверяется наличие.
+func (h *Handler) importCreate(ctx context.Context, namespace, uuid, data string, ttlSeconds uint64) (bool, error) {
+	if isOctetUUID(uuid) {
+		_, err := h.create(ctx, namespace, uuid, data, ttlSeconds)
+		if errors.Is(err, service.ErrAlreadyExists) {
+			return true, nil
+		}
+		return false, err
+	}
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		return false, err
+	}
+	return client.Exists(ctx, uuid)
+}
+
+// Учет импортированной или пропущенной записи
+func (r *ImportResponse) record(line int, sourceUuid, uuid, outcome string) {
+	switch outcome {
+	case ImportOutcomeInserted:
+		r.Inserted++
+	case ImportOutcomeUpdated:
+		r.Updated++
+	case ImportOutcomeSkipped:
+		r.Skipped++
+	case ImportOutcomeRenamed:
+		r.Renamed++
+	}
+	if len(r.Records) < maxImportRecords {
+		record := ImportRecord{Line: line, Uuid: uuid, Outcome: outcome}
+		if sourceUuid != uuid {
+			record.SourceUuid = sourceUuid
+		}
+		r.Records = append(r.Records, record)
+	}
+}
+
+// Учет записи, которую не удалось импортировать
+func (r *ImportResponse) fail(line int, uuid, message, code string) {
+	r.Failed++
+	if len(r.Records) < maxImportRecords {
+		r.Records = append(r.Records, ImportRecord{Line: line, Uuid: uuid, Outcome: ImportOutcomeFailed})
+	}
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, ImportError{Line: line, Uuid: uuid, Error: message, Code: code})
+	}
+}
+
+// Учет записи, импорт которой завершился ошибкой взаимодействия с octet
+func (r *ImportResponse) failWith(line int, uuid string, err error) {
+	_, response := serviceErrorResponse(err, err.Error())
+	r.fail(line, uuid, response.Error, response.Code)
+}
+
+// Чтение строки тела импорта в buf. Строка больше maxBytes (0 - без ограничения) пропускается
+// с ошибкой errImportRecordTooLarge. В конце тела возвращается последняя строка и io.EOF.
+func readImportLine(reader *bufio.Reader, buf []byte, maxBytes int64) ([]byte, error) {
+	buf = buf[:0]
+	tooLarge := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLarge {
+			buf = append(buf, chunk...)
+			if maxBytes > 0 && int64(len(bytes.TrimRight(buf, "\r\n"))) > maxBytes {
+				tooLarge, buf = true, buf[:0]
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLarge && (err == nil || errors.Is(err, io.EOF)) {
+			return buf, errImportRecordTooLarge
+		}
+		return buf, err
+	}
+}
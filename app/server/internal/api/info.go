@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Сведения о сборке и окружении HTTP-сервера
+type ServerBuildInfo struct {
+	Version    string `json:"version"`
+	Revision   string `json:"revision,omitempty"`
+	GoVersion  string `json:"go_version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	NumCPU     int    `json:"num_cpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	PID        int    `json:"pid"`
+	StartedAt  string `json:"started_at"`
+}
+
+// Сведения об исполняемом файле octet
+type OctetBinaryInfo struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	ModTime string `json:"mod_time,omitempty"`
+	Error   string `json:"error,omitempty"` // Ошибка получения сведений о файле
+}
+
+// Итоговые параметры конфигурации (без секретов)
+type ConfigInfo struct {
+	StorageDir        string   `json:"storage_dir"`
+	SocketPath        string   `json:"socket_path"`
+	HTTPAddr          []string `json:"http_addr"`
+	MaxClients        int      `json:"max_clients"`
+	MaxFrameSize      int64    `json:"max_frame_size"`
+	HeartbeatInterval int      `json:"heartbeat_interval"`
+	VerifyChecksum    bool     `json:"verify_checksum"`
+	AuthMode          string   `json:"auth_mode"`
+	Compression       []string `json:"compression"` // Включенные кодирования (пусто - сжатие отключено)
+	CacheControl      string   `json:"cache_control"`
+	ETag              bool     `json:"etag"`
+	Trace             bool     `json:"trace"`
+	Faults            bool     `json:"faults"`
+}
+
+// Сведения о запущенном сервере для отчетов и обращений в поддержку
+type ServerInfo struct {
+	Server ServerBuildInfo `json:"server"`
+	Octet  OctetBinaryInfo `json:"octet"`
+	Config ConfigInfo      `json:"config"`
+}
+
+// Сбор сведений о сервере, окружении и исполняемом файле octet
+func CollectServerInfo(cfg *config.Config) ServerInfo {
+	info := ServerInfo{
+		Server: ServerBuildInfo{
+			Version:    "(devel)",
+			GoVersion:  runtime.Version(),
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			NumCPU:     runtime.NumCPU(),
+			GOMAXPROCS: runtime.GOMAXPROCS(0),
+			PID:        os.Getpid(),
+			StartedAt:  time.Now().Format(time.RFC3339),
+		},
+		Octet: collectOctetInfo(cfg.OctetPath),
+		Config: ConfigInfo{
+			StorageDir:        cfg.StorageDir,
+			SocketPath:        cfg.SocketPath,
+			HTTPAddr:          cfg.HTTPAddr,
+			MaxClients:        cfg.MaxClients,
+			MaxFrameSize:      cfg.MaxFrameSize,
+			HeartbeatInterval: cfg.HeartbeatInterval,
+			VerifyChecksum:    cfg.VerifyChecksum,
+			AuthMode:          cfg.Auth.Mode,
+			CacheControl:      cfg.Cache.CacheControl,
+			ETag:              cfg.Cache.ETag,
+			Trace:             cfg.Trace.Enabled,
+			Faults:            cfg.Faults.Enabled,
+		},
+	}
+	if cfg.Compression.Enabled {
+		info.Config.Compression = cfg.Compression.Encodings
+	}
+
+	// Версия и ревизия из сведений о сборке Go
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if len(buildInfo.Main.Version) != 0 {
+			info.Server.Version = buildInfo.Main.Version
+		}
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Server.Revision = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// Сбор сведений об исполняемом файле octet: версия, размер и хэш
+func collectOctetInfo(path string) OctetBinaryInfo {
+	info := OctetBinaryInfo{Path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	defer file.Close()
+
+	if stat, err := file.Stat(); err == nil {
+		info.Size = stat.Size()
+		info.ModTime = stat.ModTime().Format(time.RFC3339)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	// Старые версии octet не поддерживают --version, в этом случае версия не указывается
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, path, "--version").Output(); err == nil {
+		info.Version = strings.TrimSpace(string(output))
+	}
+
+	return info
+}
+
+// Info godoc
+// @Summary Сведения о сервере
+// @Description Версии, окружение, исполняемый файл octet и итоговая конфигурация сервера
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ServerInfo
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/info [get]
+func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.info)
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/lildannita/octet-server/internal/config"
+	"go.uber.org/zap"
+)
+
+// Перехватчик запросов и ответов маршрута. Позволяет сборкам сервера изменять запросы
+// (например, добавлять идентификатор клиента) и ответы (например, удалять поля) без изменения обработчиков.
+type Interceptor interface {
+	// Изменение запроса до обработчика. Ошибка прерывает обработку запроса.
+	InterceptRequest(r *http.Request) (*http.Request, error)
+	// Изменение ответа обработчика до отправки клиенту
+	InterceptResponse(r *http.Request, resp *InterceptedResponse) error
+}
+
+// Ответ обработчика, доступный перехватчикам для изменения
+type InterceptedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Создание перехватчика с параметрами из конфигурации
+type InterceptorFactory func(params map[string]string) (Interceptor, error)
+
+// Ошибка перехватчика с кодом ответа клиенту (остальные ошибки возвращаются как 500)
+type InterceptError struct {
+	Status  int
+	Message string
+}
+
+func (e *InterceptError) Error() string {
+	return e.Message
+}
+
+// Встроенные в сборку перехватчики
+var (
+	interceptorsMutex sync.Mutex
+	interceptors      = make(map[string]InterceptorFactory)
+)
+
+// Регистрация перехватчика. Вызывается из init() файлов, добавленных в сборку сервера.
+func RegisterInterceptor(name string, factory InterceptorFactory) {
+	interceptorsMutex.Lock()
+	defer interceptorsMutex.Unlock()
+	if factory == nil {
+		panic("перехватчик " + name + " не указан")
+	}
+	if _, ok := interceptors[name]; ok {
+		panic("перехватчик " + name + " зарегистрирован повторно")
+	}
+	interceptors[name] = factory
+}
+
+// Цепочки перехватчиков по маршрутам
+type Interceptors map[string][]Interceptor
+
+// Создание цепочек перехватчиков по конфигурации
+func NewInterceptors(configs []config.InterceptorConfig) (Interceptors, error) {
+	interceptorsMutex.Lock()
+	defer interceptorsMutex.Unlock()
+
+	chains := make(Interceptors)
+	for _, cfg := range configs {
+		factory, ok := interceptors[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("перехватчик '%s' не встроен в сборку сервера", cfg.Name)
+		}
+		interceptor, err := factory(cfg.Params)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось создать перехватчик '%s': %w", cfg.Name, err)
+		}
+		for _, route := range cfg.Routes {
+			chains[route] = append(chains[route], interceptor)
+		}
+	}
+	return chains, nil
+}
+
+// Ответ обработчика, накапливаемый для перехватчиков
+type interceptRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	encoding    jsonEncoding // Параметры кодирования JSON-ответа исходного ResponseWriter
+}
+
+func (rec *interceptRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *interceptRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *interceptRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+func (rec *interceptRecorder) jsonEncoding() jsonEncoding {
+	return rec.encoding
+}
+
+// Слой для применения перехватчиков маршрута: запрос проходит их в порядке перечисления,
+// ответ - в обратном порядке.
+func (h *Handler) intercept(route string) func(http.Handler) http.Handler {
+	chain := h.interceptors[route]
+	return func(next http.Handler) http.Handler {
+		if len(chain) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var err error
+			for _, interceptor := range chain {
+				if r, err = interceptor.InterceptRequest(r); err != nil {
+					h.respondInterceptError(w, route, err)
+					return
+				}
+			}
+
+			rec := &interceptRecorder{header: w.Header().Clone(), status: http.StatusOK, encoding: jsonEncodingOf(w)}
+			next.ServeHTTP(rec, r)
+
+			resp := &InterceptedResponse{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+			for i := len(chain) - 1; i >= 0; i-- {
+				if err := chain[i].InterceptResponse(r, resp); err != nil {
+					h.respondInterceptError(w, route, err)
+					return
+				}
+			}
+
+			// Заголовки заменяются целиком, чтобы перехватчики могли и удалять их
+			clear(w.Header())
+			for key, values := range resp.Header {
+				w.Header()[key] = values
+			}
+			if w.Header().Get("Content-Length") != "" {
+				w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+			}
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+		})
+	}
+}
+
+// Ответ об ошибке перехватчика
+func (h *Handler) respondInterceptError(w http.ResponseWriter, route string, err error) {
+	var interceptErr *InterceptError
+	if errors.As(err, &interceptErr) {
+		respondWithError(w, interceptErr.Status, interceptErr.Message)
+		return
+	}
+	h.logger.Error("Ошибка перехватчика", zap.String("route", route), zap.Error(err))
+	respondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+}
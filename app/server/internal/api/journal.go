@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Количество записей журнала, запрашиваемых у octet за один раз
+const journalPageSize = 500
+
+// Journal godoc
+// @Summary Журнал операций octet
+// @Description Записи журнала операций octet (insert, update, remove, checkpoint), выполненных начиная с указанного момента,
+// @Description в формате NDJSON (одна запись в строке). Записи до последнего сжатия хранилища недоступны.
+// @Description Трейлер X-Octet-Stream-End: complete - записи переданы полностью,
+// @Description shutdown - передача остановлена из-за завершения работы сервера.
+// @Tags admin
+// @Produce application/x-ndjson
+// @Param since query string false "Момент времени в формате RFC 3339 (по умолчанию - с начала журнала)"
+// @Param limit query int false "Максимальное количество записей (по умолчанию - без ограничения)"
+// @Success 200 {array} protocol.JournalEntry
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/journal [get]
+func (h *Handler) Journal(w http.ResponseWriter, r *http.Request) {
+	var since string
+	if value := r.URL.Query().Get("since"); len(value) != 0 {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'since' должен быть указан в формате RFC 3339")
+			return
+		}
+		since = t.UTC().Format(protocol.JournalTimeFormat)
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); len(value) != 0 {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'limit' должен быть положительным числом")
+			return
+		}
+	}
+
+	stop, done := h.streams.begin()
+	defer done()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(jsonEncodingOf(w).escapeHTML)
+	flusher, _ := w.(http.Flusher)
+	written, skip := 0, 0
+	started := false
+	end := StreamEndComplete
+	for limit == 0 || written < limit {
+		pageSize := journalPageSize
+		if limit != 0 {
+			pageSize = min(pageSize, limit-written)
+		}
+
+		client, err := h.clientPool.GetClient()
+		if err == nil {
+			var entries []protocol.JournalEntry
+			var more bool
+			if entries, more, err = client.Journal(r.Context(), since, skip, pageSize); err == nil {
+				if !started {
+					w.Header().Set("Content-Type", "application/x-ndjson")
+					w.Header().Set("Trailer", StreamEndTrailer)
+					w.WriteHeader(http.StatusOK)
+					started = true
+				}
+				for _, entry := range entries {
+					if err = encoder.Encode(&entry); err != nil {
+						// Клиент закрыл соединение
+						return
+					}
+				}
+				written += len(entries)
+				if flusher != nil {
+					flusher.Flush()
+				}
+				if !more || len(entries) == 0 {
+					break
+				}
+				since, skip = service.NextJournalCursor(since, skip, entries)
+
+				// При завершении работы сервера передача останавливается между страницами
+				select {
+				case <-stop:
+					h.logger.Info("Передача журнала операций остановлена из-за завершения работы сервера",
+						zap.Int("written", written))
+					end = StreamEndShutdown
+				default:
+					continue
+				}
+				break
+			}
+		}
+
+		h.logger.Error("Не удалось прочитать журнал операций octet", zap.Error(err))
+		if !started {
+			respondWithServiceError(w, err, "Не удалось прочитать журнал операций")
+			return
+		}
+		// Часть записей уже отправлена и код ответа изменить нельзя,
+		// поэтому разрываем соединение, чтобы клиент не принял ответ за полный
+		panic(http.ErrAbortHandler)
+	}
+	if started {
+		w.Header().Set(StreamEndTrailer, end)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Параметры кодирования JSON-ответа
+type jsonEncoding struct {
+	escapeHTML bool // Экранирование <, > и & в строках
+	indent     bool // Форматирование с отступами
+}
+
+// Кодирование по умолчанию (для ответов вне JSONEncodingMiddleware) совпадает с json.Marshal
+var defaultJSONEncoding = jsonEncoding{escapeHTML: true}
+
+// ResponseWriter, знающий параметры кодирования JSON-ответа
+type jsonEncodingSource interface {
+	jsonEncoding() jsonEncoding
+}
+
+// Обертка ResponseWriter с параметрами кодирования JSON-ответа запроса
+type jsonEncodingWriter struct {
+	http.ResponseWriter
+	encoding jsonEncoding
+}
+
+func (w *jsonEncodingWriter) jsonEncoding() jsonEncoding {
+	return w.encoding
+}
+
+func (w *jsonEncodingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Доступ к исходному ResponseWriter для http.ResponseController
+func (w *jsonEncodingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Параметры кодирования JSON-ответа, заданные JSONEncodingMiddleware.
+// Поиск идет по цепочке оберток ResponseWriter, реализующих Unwrap.
+func jsonEncodingOf(w http.ResponseWriter) jsonEncoding {
+	for {
+		if source, ok := w.(jsonEncodingSource); ok {
+			return source.jsonEncoding()
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return defaultJSONEncoding
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// Слой для выбора параметров кодирования JSON-ответов: экранирование HTML задается конфигурацией,
+// форматирование с отступами - параметром запроса ?pretty=1, если оно разрешено
+func JSONEncodingMiddleware(cfg config.JSONConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := jsonEncoding{escapeHTML: cfg.EscapeHTML}
+			if cfg.AllowPretty {
+				encoding.indent, _ = strconv.ParseBool(r.URL.Query().Get("pretty"))
+			}
+			next.ServeHTTP(&jsonEncodingWriter{ResponseWriter: w, encoding: encoding}, r)
+		})
+	}
+}
+
+// Отправка кода ответа перед первой записью тела: json.Encoder ничего не записывает
+// при ошибке кодирования, и тогда вместо кода можно отправить ошибку
+type statusOnWrite struct {
+	w           http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (sw *statusOnWrite) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		sw.w.WriteHeader(sw.code)
+	}
+	return sw.w.Write(b)
+}
+
+// Значение заголовка Content-Type JSON-ответов, выделенное один раз
+// (заголовок заменяется или дополняется, но не изменяется на месте)
+var jsonContentType = []string{"application/json"}
+
+// Кодировщик JSON-ответа, переиспользуемый между запросами
+type jsonResponder struct {
+	out     statusOnWrite
+	encoder *json.Encoder
+}
+
+var jsonResponderPool = sync.Pool{
+	New: func() any {
+		responder := new(jsonResponder)
+		responder.encoder = json.NewEncoder(&responder.out)
+		return responder
+	},
+}
+
+// Получение кодировщика для ответа с кодом code в w
+func getJSONResponder(w http.ResponseWriter, code int, encoding jsonEncoding) *jsonResponder {
+	responder := jsonResponderPool.Get().(*jsonResponder)
+	responder.out = statusOnWrite{w: w, code: code}
+	responder.encoder.SetEscapeHTML(encoding.escapeHTML)
+	if encoding.indent {
+		responder.encoder.SetIndent("", "  ")
+	} else {
+		responder.encoder.SetIndent("", "")
+	}
+	return responder
+}
+
+// Возврат кодировщика в пул (ResponseWriter запроса не удерживается)
+func putJSONResponder(responder *jsonResponder) {
+	responder.out = statusOnWrite{}
+	jsonResponderPool.Put(responder)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Количество UUID на странице перечисления по умолчанию и максимальное
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// Страница UUID строк хранилища
+type ListResponse struct {
+	Uuids []string `json:"uuids"`
+	// Курсор следующей страницы (отсутствует на последней странице)
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// List godoc
+// @Summary Перечисление UUID строк
+// @Description UUID строк хранилища в порядке возрастания, постранично. Для получения следующей страницы
+// @Description передается курсор next_cursor из предыдущего ответа (он же указывается в заголовке Link с rel="next").
+// @Description Страница может содержать меньше limit UUID, даже если она не последняя.
+// @Tags strings
+// @Produce json
+// @Param cursor query string false "Курсор из next_cursor предыдущей страницы (по умолчанию - с начала)"
+// @Param limit query int false "Максимальное количество UUID на странице (по умолчанию 100, не более 1000)"
+// @Success 200 {object} ListResponse
+// @Header 200 {string} Link "Адрес следующей страницы"
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1 [get]
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	// Курсор - последний UUID предыдущей страницы
+	cursor := r.URL.Query().Get("cursor")
+	if len(cursor) != 0 && !isCanonicalUUID(cursor) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Курсор '%s' некорректен", cursor))
+		return
+	}
+	limit := defaultListLimit
+	if value := r.URL.Query().Get("limit"); len(value) != 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'limit' должен быть положительным числом")
+			return
+		}
+		limit = min(parsed, maxListLimit)
+	}
+
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		respondWithServiceError(w, err, "Внутренняя ошибка сервера")
+		return
+	}
+	uuids, more, err := client.List(r.Context(), cursor, limit)
+	if err != nil {
+		h.logger.Error("Ошибка при перечислении строк", zap.Error(err))
+		respondWithServiceError(w, err, "Ошибка при перечислении строк: "+err.Error())
+		return
+	}
+
+	response := ListResponse{Uuids: make([]string, 0, len(uuids))}
+	if more && len(uuids) != 0 {
+		// Курсор продолжает перечисление и после строк с меткой удаления, исключенных из ответа
+		response.NextCursor = uuids[len(uuids)-1]
+		query := url.Values{"cursor": {response.NextCursor}, "limit": {strconv.Itoa(limit)}}
+		w.Header().Set("Link", fmt.Sprintf("<%s%s?%s>; rel=\"next\"", externalPrefix(r), r.URL.Path, query.Encode()))
+	}
+	for _, uuid := range uuids {
+		// Строка с меткой удаления считается удаленной
+		if !h.tombstones.Contains(uuid) {
+			response.Uuids = append(response.Uuids, uuid)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Размер и метаданные строки
+type MetaResponse struct {
+	Uuid string `json:"uuid"`
+	Size uint64 `json:"size"` // Размер значения в байтах
+	// Время добавления и последнего изменения (отсутствуют, если octet их не сохранил)
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+	// Версия строки: 1 после добавления, увеличивается при каждом обновлении (0 - неизвестна)
+	Version uint64 `json:"version"`
+	// Момент истечения срока хранения (отсутствует для строк без срока)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Meta godoc
+// @Summary Метаданные строки
+// @Description Размер, время добавления и последнего изменения, версия и срок хранения строки без передачи ее значения.
+// @Description Позволяет решить, нужно ли повторно загружать большое значение.
+// @Tags strings
+// @Produce json
+// @Param uuid path string true "UUID строки"
+// @Success 200 {object} MetaResponse
+// @Header 200 {string} Last-Modified "Время последнего изменения строки"
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 404 {object} ErrorHeader
+// @Failure 429 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Failure 503 {object} ErrorHeader
+// @Failure 504 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/{uuid}/meta [get]
+func (h *Handler) Meta(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+	if len(uuid) == 0 {
+		respondWithError(w, http.StatusBadRequest, "UUID не указан")
+		return
+	}
+	// Строка с меткой удаления считается удаленной
+	if h.tombstones.Contains(uuid) {
+		respondWithServiceError(w, service.ErrNotFound, "Строка не найдена")
+		return
+	}
+
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		respondWithServiceError(w, err, "Внутренняя ошибка сервера")
+		return
+	}
+	stat, err := client.Stat(r.Context(), uuid)
+	if err != nil {
+		h.logger.Error("Ошибка при получении метаданных строки", zap.Error(err))
+		respondWithServiceError(w, err, "Ошибка при получении метаданных строки: "+err.Error())
+		return
+	}
+
+	response := MetaResponse{Uuid: uuid, Size: stat.Size, Version: stat.Version}
+	if !stat.CreatedAt.IsZero() {
+		response.CreatedAt = &stat.CreatedAt
+	}
+	if !stat.ModifiedAt.IsZero() {
+		response.ModifiedAt = &stat.ModifiedAt
+		setLastModified(w, stat.ModifiedAt)
+	}
+	if !stat.ExpiresAt.IsZero() {
+		response.ExpiresAt = &stat.ExpiresAt
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lildannita/octet-server/internal/events"
+	"go.uber.org/zap"
+)
+
+// Metrics godoc
+// @Summary Метрики команд octet
+// @Description Счетчики команд octet по типу команды и результату (success, backend_error, timeout, unavailable, error),
+// @Description гистограммы длительности команд и размера данных строк, процентили длительности последних команд
+// @Description текущие таймауты чтения ответа и счетчики доставки событий подписчикам в текстовом формате Prometheus.
+// @Description При Accept: application/openmetrics-text ответ в формате OpenMetrics с примерами значений
+// @Description гистограммы длительности (trace_id запросов с заголовком traceparent)
+// @Tags admin
+// @Produce plain
+// @Success 200 {string} string
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/metrics [get]
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	err := h.clientPool.Metrics().Commands.WritePrometheus(w, openMetrics)
+	if err == nil {
+		err = h.clientPool.WriteLatencyMetrics(w)
+	}
+	if err == nil && h.bandwidth != nil {
+		err = h.bandwidth.WritePrometheus(w, openMetrics)
+	}
+	if err == nil && h.keyLimit != nil {
+		err = h.keyLimit.WritePrometheus(w, openMetrics)
+	}
+	if err == nil && len(h.webhooks) != 0 {
+		err = events.WritePrometheus(w, h.webhooks, openMetrics)
+	}
+	for _, writer := range h.metrics {
+		if err == nil {
+			err = writer(w, openMetrics)
+		}
+	}
+	if err == nil && openMetrics {
+		_, err = io.WriteString(w, "# EOF\n")
+	}
+	if err != nil {
+		h.logger.Debug("Не удалось отправить метрики", zap.Error(err))
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Результаты получения строки в пакетном чтении
+const (
+	MgetFound    = "found"     // Строка получена
+	MgetNotFound = "not_found" // Строки с указанным UUID нет
+	MgetError    = "error"     // Строку не удалось получить
+)
+
+// Пакетное чтение строк
+type MgetRequest struct {
+	Uuids []string `json:"uuids"`
+}
+
+// Результат получения одной строки
+type MgetItemResult struct {
+	Uuid   string `json:"uuid"`
+	Status string `json:"status"`          // found, not_found или error
+	Data   string `json:"data,omitempty"`  // Для found
+	Error  string `json:"error,omitempty"` // Для error
+	Code   string `json:"code,omitempty"`  // Машиночитаемый код ошибки взаимодействия с octet
+}
+
+// Результаты пакетного чтения в порядке UUID запроса
+type MgetResponse struct {
+	Results []MgetItemResult `json:"results"`
+}
+
+// Mget godoc
+// @Summary Пакетное чтение строк
+// @Description Получение нескольких строк одним запросом. Отсутствие строки или ошибка при ее получении
+// @Description не прерывают запрос: результат каждой строки возвращается отдельно со статусом found, not_found или error.
+// @Description Если получены все строки, возвращается 200, иначе 207 Multi-Status.
+// @Description Запрос делится на части так же, как пакетное изменение, и ограничен batch.max_items.
+// @Tags strings
+// @Accept json
+// @Produce json
+// @Param request body MgetRequest true "UUID строк"
+// @Success 200 {object} MgetResponse
+// @Success 207 {object} MgetResponse
+// @Header 200 {string} X-Octet-Warning "Коды предупреждений о приближении к ограничениям"
+// @Header 200 {integer} X-Octet-Batch-Remaining "Сколько UUID еще можно добавить в запрос"
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 413 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /octet/v1/mget [post]
+func (h *Handler) Mget(w http.ResponseWriter, r *http.Request) {
+	var mgetReq MgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&mgetReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	if len(mgetReq.Uuids) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Запрос не содержит UUID")
+		return
+	}
+	if len(mgetReq.Uuids) > h.batch.MaxItems {
+		respondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Количество UUID в запросе (%d) превышает допустимое (%d)", len(mgetReq.Uuids), h.batch.MaxItems))
+		return
+	}
+
+	results := make([]MgetItemResult, len(mgetReq.Uuids))
+	h.forEachChunk(len(mgetReq.Uuids), func(start, end int) {
+		h.runMgetChunk(r.Context(), mgetReq.Uuids[start:end], results[start:end])
+	})
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status != MgetFound {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+	h.warnBatchLimit(w, len(mgetReq.Uuids))
+	respondWithJSON(w, status, MgetResponse{Results: results})
+}
+
+// Получение части строк на одном клиенте пула
+func (h *Handler) runMgetChunk(ctx context.Context, uuids []string, results []MgetItemResult) {
+	client, err := h.clientPool.GetClientFor(service.PoolClassRead)
+	if err != nil {
+		h.logger.Error("Не удалось получить клиент из пула", zap.Error(err))
+		for i, uuid := range uuids {
+			results[i] = mgetErrorResult(uuid, err)
+		}
+		return
+	}
+	defer client.Release()
+
+	for i, uuid := range uuids {
+		if !isCanonicalUUID(uuid) {
+			results[i] = MgetItemResult{Uuid: uuid, Status: MgetError, Error: fmt.Sprintf("'%s' не является UUID", uuid)}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			results[i] = mgetErrorResult(uuid, err)
+			continue
+		}
+		// Строка с меткой удаления считается удаленной
+		if h.tombstones.Contains(uuid) {
+			results[i] = MgetItemResult{Uuid: uuid, Status: MgetNotFound}
+			continue
+		}
+
+		// Клиент возвращается в пул после всей части, поэтому вызываются методы *service.Client
+		data, err := client.Client.Get(ctx, uuid)
+		switch {
+		case err == nil:
+			results[i] = MgetItemResult{Uuid: uuid, Status: MgetFound, Data: data}
+		case errors.Is(err, service.ErrNotFound):
+			results[i] = MgetItemResult{Uuid: uuid, Status: MgetNotFound}
+		default:
+			results[i] = mgetErrorResult(uuid, err)
+		}
+	}
+}
+
+// Результат получения строки, завершившегося ошибкой
+func mgetErrorResult(uuid string, err error) MgetItemResult {
+	_, response := serviceErrorResponse(err, err.Error())
+	return MgetItemResult{Uuid: uuid, Status: MgetError, Error: response.Error, Code: response.Code}
+}
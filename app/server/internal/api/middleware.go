@@ -1,11 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/service"
 	"go.uber.org/zap"
 )
 
@@ -18,7 +25,7 @@ func LoggerMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 			start := time.Now()
 			defer func() {
 				// Логируем информацию о запросе
-				logger.Info("HTTP Request",
+				fields := []zap.Field{
 					zap.String("method", r.Method),
 					zap.String("path", r.URL.Path),
 					zap.String("query", r.URL.RawQuery),
@@ -27,7 +34,11 @@ func LoggerMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 					zap.Int("status", ww.Status()),
 					zap.Int("bytes", ww.BytesWritten()),
 					zap.String("request_id", middleware.GetReqID(r.Context())),
-				)
+				}
+				if trace := service.TraceContextFrom(r.Context()); len(trace.Parent) != 0 {
+					fields = append(fields, zap.String("traceparent", trace.Parent))
+				}
+				logger.Info("HTTP Request", fields...)
 			}()
 
 			next.ServeHTTP(ww, r)
@@ -35,17 +46,97 @@ func LoggerMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 	}
 }
 
-// Слой для проверки Content-Type для POST и PUT запросов
-func ContentTypeMiddleware(contentType string) func(http.Handler) http.Handler {
+// Слой для присвоения запросу идентификатора: из заголовка X-Request-Id или нового
+// (при uuidv7 = false - в формате chi: host/random-000001)
+func RequestIDMiddleware(uuidv7 bool) func(http.Handler) http.Handler {
+	if !uuidv7 {
+		return middleware.RequestID
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(middleware.RequestIDHeader)
+			if len(requestID) == 0 {
+				id, err := uuid.NewV7()
+				if err != nil {
+					id = uuid.New()
+				}
+				requestID = id.String()
+			}
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, requestID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Слой для приема контекста трассировки W3C Trace Context (заголовки traceparent и tracestate).
+// Контекст передается в логи, запросы к octet и события для подписчиков независимо от того,
+// экспортирует ли сервер собственные трассы.
+func TraceContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trace, ok := service.ParseTraceContext(r.Header.Get("traceparent"), r.Header.Get("tracestate")); ok {
+			r = r.WithContext(service.WithTraceContext(r.Context(), trace))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Слой для ограничения размера тела запроса (maxBytes <= 0 - без ограничения).
+// Запрос с заявленным Content-Length больше ограничения отклоняется до чтения тела,
+// а тело неизвестной длины (chunked) прерывается при превышении ограничения во время чтения.
+func BodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			// Тело импорта ограничивается построчно в обработчике
+			if isImportRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > maxBytes {
+				respondBodyTooLarge(w, maxBytes)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Ответ об отклонении слишком большого тела запроса
+func respondBodyTooLarge(w http.ResponseWriter, maxBytes int64) {
+	respondWithJSON(w, http.StatusRequestEntityTooLarge, BodyTooLargeError{
+		ErrorHeader: ErrorHeader{
+			Error: fmt.Sprintf("Размер тела запроса превышает допустимый (%d байт)", maxBytes),
+			Code:  ErrorCodeRequestTooLarge,
+		},
+		MaxBytes: maxBytes,
+	})
+}
+
+// Слой для проверки Content-Type для POST и PUT запросов. Добавление и изменение строки
+// принимают также text/plain и типы двоичных значений binaryTypes.
+func ContentTypeMiddleware(contentType string, binaryTypes []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Тип тела импорта проверяет обработчик
+			if (r.Method == http.MethodPost || r.Method == http.MethodPut) && !isImportRequest(r) {
 				ct := r.Header.Get("Content-Type")
-				if ct != contentType {
+				// Значение строки при добавлении и изменении можно передать без JSON-обертки
+				rawValue := acceptsRawValue(r)
+				if ct != contentType && !(rawValue && isRawValue(ct, binaryTypes)) {
+					message := "Content-Type должен быть " + contentType
+					if rawValue {
+						message += " или text/plain"
+						if len(binaryTypes) != 0 {
+							message += ", " + strings.Join(binaryTypes, ", ")
+						}
+					}
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusUnsupportedMediaType)
 					json.NewEncoder(w).Encode(map[string]string{
-						"error": "Content-Type должен быть application/json",
+						"error": message,
 					})
 					return
 				}
@@ -55,3 +146,43 @@ func ContentTypeMiddleware(contentType string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// Слой для аутентификации клиентов (при authenticator == nil аутентификация отключена)
+func AuthMiddleware(authenticator auth.Authenticator, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authenticator == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, err := authenticator.Authenticate(r)
+			if err != nil {
+				if !errors.Is(err, auth.ErrNoCredentials) {
+					logger.Warn("Отклонен запрос с неверными учетными данными",
+						zap.String("remote_addr", r.RemoteAddr), zap.Error(err))
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="octet"`)
+				respondWithError(w, http.StatusUnauthorized, "Требуется аутентификация")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// Слой для проверки области доступа аутентифицированного клиента
+func RequireScope(scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Если аутентификация отключена, данных о клиенте в контексте нет
+			identity := auth.IdentityFromContext(r.Context())
+			if identity != nil && !identity.HasScope(scope) {
+				respondWithError(w, http.StatusForbidden, "Недостаточно прав для выполнения операции")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Слой для проверки пространства имен из пути запроса (для прежних адресов - пространства имен по умолчанию)
+// и его доступности клиенту
+func NamespaceMiddleware(cfg config.NamespacesConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			namespace := chi.URLParam(r, "namespace")
+			if len(namespace) == 0 {
+				namespace = cfg.Default
+			}
+			if status, response := checkNamespace(cfg, r, namespace); status != http.StatusOK {
+				respondWithJSON(w, status, response)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Проверка существования пространства имен и его доступности клиенту запроса.
+// Возвращает код ответа (200, если пространство имен доступно) и описание ошибки.
+func checkNamespace(cfg config.NamespacesConfig, r *http.Request, namespace string) (int, ErrorHeader) {
+	// Хранилище octet пока содержит одно пространство имен
+	if namespace != cfg.Default {
+		return http.StatusNotFound, ErrorHeader{
+			Error: fmt.Sprintf("Пространство имен '%s' не найдено", namespace),
+			Code:  ErrorCodeNamespaceNotFound,
+		}
+	}
+	// Если аутентификация отключена, данных о клиенте в контексте нет
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil && !identity.HasNamespace(namespace) {
+		return http.StatusForbidden, ErrorHeader{
+			Error: fmt.Sprintf("Пространство имен '%s' недоступно", namespace),
+			Code:  ErrorCodeNamespaceForbidden,
+		}
+	}
+	return http.StatusOK, ErrorHeader{}
+}
+
+// Пространство имен запроса (для прежних адресов - пространство имен по умолчанию)
+func (h *Handler) namespace(r *http.Request) string {
+	if namespace := chi.URLParam(r, "namespace"); len(namespace) != 0 {
+		return namespace
+	}
+	return h.namespaces.Default
+}
+
+// Является ли пространство имен запроса неизменяемым
+func (h *Handler) immutable(r *http.Request) bool {
+	return h.namespaces.IsImmutable(h.namespace(r))
+}
+
+// Отказ в изменении строки неизменяемого пространства имен
+func respondImmutable(w http.ResponseWriter, namespace string) {
+	w.Header().Set("Allow", "GET")
+	respondWithJSON(w, http.StatusMethodNotAllowed, immutableError(namespace))
+}
+
+// Описание ошибки изменения строки неизменяемого пространства имен
+func immutableError(namespace string) ErrorHeader {
+	return ErrorHeader{
+		Error: fmt.Sprintf("Строки пространства имен '%s' нельзя изменять и удалять", namespace),
+		Code:  ErrorCodeNamespaceImmutable,
+	}
+}
+
+// Перенаправление прежнего адреса без пространства имен на адрес в пространстве имен по умолчанию.
+// Код 308 сохраняет метод и тело запроса.
+func namespaceRedirect(cfg config.NamespacesConfig, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest, _ := strings.CutPrefix(r.URL.Path, basePath+"/v1")
+		target := externalPrefix(r) + basePath + "/v1/ns/" + cfg.Default + rest
+		if len(r.URL.RawQuery) != 0 {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	}
+}
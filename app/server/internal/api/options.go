@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lildannita/octet-server/internal/auth"
+)
+
+// Базовый путь API работы со строками по умолчанию
+const DefaultBasePath = "/octet"
+
+// Пути, занятые служебными маршрутами роутера
+var reservedPaths = []string{"/health", "/ready", "/admin", "/swagger"}
+
+// Вывод дополнительных метрик в формате Prometheus (openMetrics - в формате OpenMetrics без # EOF)
+type MetricsWriter func(w io.Writer, openMetrics bool) error
+
+// Параметр роутера
+type RouterOption func(*routerOptions) error
+
+// Параметры роутера, не входящие в RouterConfig
+type routerOptions struct {
+	authenticator auth.Authenticator
+	metrics       []MetricsWriter
+	middlewares   []func(http.Handler) http.Handler
+	basePath      string
+}
+
+// Аутентификация клиентов (без параметра аутентификация отключена)
+func WithAuth(authenticator auth.Authenticator) RouterOption {
+	return func(o *routerOptions) error {
+		if authenticator == nil {
+			return errors.New("аутентификация не указана")
+		}
+		o.authenticator = authenticator
+		return nil
+	}
+}
+
+// Дополнительные метрики, выводимые в /admin/v1/metrics после метрик сервера
+func WithMetrics(writers ...MetricsWriter) RouterOption {
+	return func(o *routerOptions) error {
+		for _, writer := range writers {
+			if writer == nil {
+				return errors.New("вывод метрик не указан")
+			}
+		}
+		o.metrics = append(o.metrics, writers...)
+		return nil
+	}
+}
+
+// Слои, через которые проходят все запросы после базовых слоев роутера
+// (идентификатор запроса, восстановление после паники, логирование).
+// Первый слой получает запрос первым.
+func WithMiddleware(middlewares ...func(http.Handler) http.Handler) RouterOption {
+	return func(o *routerOptions) error {
+		for _, middleware := range middlewares {
+			if middleware == nil {
+				return errors.New("слой обработки запросов не указан")
+			}
+		}
+		o.middlewares = append(o.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// Базовый путь API работы со строками вместо /octet (например, /storage)
+func WithBasePath(path string) RouterOption {
+	return func(o *routerOptions) error {
+		if !strings.HasPrefix(path, "/") || len(path) == 1 || strings.HasSuffix(path, "/") {
+			return fmt.Errorf("базовый путь '%s' должен начинаться с '/' и не заканчиваться '/'", path)
+		}
+		if strings.ContainsAny(path, "{}*?#") {
+			return fmt.Errorf("базовый путь '%s' содержит недопустимые символы", path)
+		}
+		for _, reserved := range reservedPaths {
+			if path == reserved || strings.HasPrefix(path, reserved+"/") {
+				return fmt.Errorf("базовый путь '%s' совпадает со служебным маршрутом %s", path, reserved)
+			}
+		}
+		o.basePath = path
+		return nil
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Заголовок с номером изменения в пространстве имен с упорядоченной записью
+const HeaderSequence = "X-Octet-Sequence"
+
+// Очередь упорядоченной записи пространства имен (nil - изменения выполняются без упорядочения)
+func (h *Handler) orderedFor(namespace string) *service.OrderedWriter {
+	if h.ordered != nil && h.orderedWrites.Covers(namespace) {
+		return h.ordered
+	}
+	return nil
+}
+
+// Передача номера изменения клиенту (0 - изменение выполнено без упорядочения)
+func setSequence(w http.ResponseWriter, sequence uint64) {
+	if sequence != 0 {
+		w.Header().Set(HeaderSequence, strconv.FormatUint(sequence, 10))
+	}
+}
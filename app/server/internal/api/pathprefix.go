@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lildannita/octet-server/docs"
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Заголовок с префиксом пути, удаленным обратным прокси из пути запроса
+const ForwardedPrefixHeader = "X-Forwarded-Prefix"
+
+type externalPrefixKey struct{}
+
+// Префикс пути, под которым клиент обращается к серверу (пусто - маршруты от корня)
+func externalPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(externalPrefixKey{}).(string)
+	return prefix
+}
+
+// Слой работы под префиксом пути: префикс удаляется из пути запроса, а ссылки в ответах
+// строятся с префиксом, под которым обращается клиент (X-Forwarded-Prefix и prefix).
+// Запросы вне префикса отклоняются с кодом 404.
+func pathPrefixHandler(cfg config.PathPrefixConfig, next http.Handler) http.Handler {
+	if len(cfg.Prefix) == 0 && !cfg.TrustForwarded {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := cfg.Prefix
+		if len(cfg.Prefix) != 0 {
+			path, ok := strings.CutPrefix(r.URL.Path, cfg.Prefix)
+			if !ok || (len(path) != 0 && path[0] != '/') {
+				http.NotFound(w, r)
+				return
+			}
+			if len(path) == 0 {
+				path = "/"
+			}
+			rawPath, _ := strings.CutPrefix(r.URL.RawPath, cfg.Prefix)
+
+			r = r.Clone(r.Context())
+			r.URL.Path = path
+			r.URL.RawPath = rawPath
+			// Обработчики, разбирающие RequestURI (документация OpenAPI), получают путь без префикса
+			r.RequestURI = r.URL.RequestURI()
+		}
+		if cfg.TrustForwarded {
+			prefix = forwardedPrefix(r.Header.Get(ForwardedPrefixHeader)) + prefix
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), externalPrefixKey{}, prefix)))
+	})
+}
+
+// Префикс из заголовка X-Forwarded-Prefix. Значение, которое нельзя использовать
+// как начало пути ссылки на этот сервер, не учитывается.
+func forwardedPrefix(value string) string {
+	// Несколько прокси могут указать префиксы через запятую, используется внешний
+	value, _, _ = strings.Cut(value, ",")
+	value = strings.TrimRight(strings.TrimSpace(value), "/")
+	if len(value) == 0 || value[0] != '/' || strings.HasPrefix(value, "//") {
+		return ""
+	}
+	if strings.ContainsAny(value, "?#\\") || strings.ContainsFunc(value, func(c rune) bool { return c <= ' ' || c == 0x7f }) {
+		return ""
+	}
+	if parsed, err := url.Parse(value); err != nil || len(parsed.Host) != 0 || len(parsed.Scheme) != 0 {
+		return ""
+	}
+	return value
+}
+
+// Документация OpenAPI с базовым путем, учитывающим префикс
+func swaggerDoc(w http.ResponseWriter, r *http.Request) {
+	spec := *docs.SwaggerInfo
+	if prefix := externalPrefix(r); len(prefix) != 0 {
+		spec.BasePath = prefix
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	io.WriteString(w, spec.ReadDoc())
+}
+
+// Перенаправление на страницу документации OpenAPI
+func swaggerIndex(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, externalPrefix(r)+"/swagger/index.html", http.StatusMovedPermanently)
+}
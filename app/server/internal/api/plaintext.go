@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Тип содержимого значения строки без JSON-обертки (для curl и скриптов)
+const textPlainType = "text/plain"
+
+// Заголовок Content-Type ответа со значением строки без JSON-обертки
+var textPlainContentType = []string{"text/plain; charset=utf-8"}
+
+// Тело text/plain не является текстом в UTF-8
+var errInvalidText = errors.New("тело text/plain должно быть в кодировке UTF-8")
+
+// Передано ли значение строки без JSON-обертки (допускается только кодировка UTF-8)
+func isTextPlain(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != textPlainType {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+// Принимает ли маршрут значение строки без JSON-обертки (text/plain или двоичное тело):
+// добавление (POST в коллекцию) и изменение (PUT /{uuid})
+func acceptsRawValue(r *http.Request) bool {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return false
+	}
+	pattern := rctx.Routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+	switch r.Method {
+	case http.MethodPost:
+		return strings.HasSuffix(pattern, "/v1/") || strings.HasSuffix(pattern, "/{namespace}/")
+	case http.MethodPut:
+		return strings.HasSuffix(pattern, "/{uuid}")
+	default:
+		return false
+	}
+}
+
+// Разбор тела запроса с значением строки: JSON-объект разбирается в req, тело text/plain
+// целиком становится значением *data, а двоичное тело сохраняется в *data вместе с типом содержимого.
+// Значение JSON или text/plain со служебным префиксом двоичных значений отклоняется (errReservedValue).
+func (h *Handler) decodeDataBody(r *http.Request, req any, data *string) error {
+	contentType := r.Header.Get("Content-Type")
+	binaryType, binary := binaryContentType(contentType, h.binary.ContentTypes)
+	if !binary && !isTextPlain(contentType) {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return err
+		}
+		return h.checkReservedValue(*data, false)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(body) == 0:
+		*data = ""
+	case binary:
+		*data = encodeBinaryValue(binaryType, body)
+	case !utf8.Valid(body):
+		return errInvalidText
+	default:
+		*data = string(body)
+		return h.checkReservedValue(*data, false)
+	}
+	return nil
+}
+
+// Запрошено ли значение строки без JSON-обертки: text/plain выбирается, только если клиент
+// предпочитает его application/json (*/* без явного text/plain означает JSON)
+func prefersTextPlain(r *http.Request) bool {
+	header := r.Header.Get("Accept")
+	if len(header) == 0 {
+		return false
+	}
+	textWeight, jsonWeight := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		switch name {
+		case textPlainType, "text/*":
+			textWeight = max(textWeight, weight)
+		case "application/json", "application/*", "*/*":
+			jsonWeight = max(jsonWeight, weight)
+		}
+	}
+	return textWeight > 0 && textWeight > jsonWeight
+}
+
+// Отправка значения строки без JSON-обертки
+func respondWithText(w http.ResponseWriter, code int, data string) {
+	w.Header()["Content-Type"] = textPlainContentType
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(code)
+	io.WriteString(w, data)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// История процесса octet
+type ProcessHistoryResponse struct {
+	Events []service.ProcessEvent `json:"events"` // От старых к новым
+}
+
+// ProcessHistory godoc
+// @Summary История процесса octet
+// @Description Сохраняемые между перезапусками сервера события жизненного цикла процесса octet, от старых к новым:
+// @Description started и start_failed - запуск с причиной (startup, upgrade, rollback, health_policy, socket_watch),
+// @Description stopped - остановка сервером, crashed - завершение без запроса сервера с кодом завершения.
+// @Description Для завершений указывается время работы процесса.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ProcessHistoryResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/process/history [get]
+func (h *Handler) ProcessHistory(w http.ResponseWriter, r *http.Request) {
+	events := h.processHistory.Records()
+	if events == nil {
+		events = []service.ProcessEvent{}
+	}
+	respondWithJSON(w, http.StatusOK, ProcessHistoryResponse{Events: events})
+}
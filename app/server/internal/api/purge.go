@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Заголовок с подписью тела отчета об окончательном удалении
+const SignatureHeader = "X-Octet-Signature"
+
+// Запрос окончательного удаления строк
+type PurgeRequest struct {
+	Uuids []string `json:"uuids"` // UUID удаляемых строк
+}
+
+// Purge godoc
+// @Summary Окончательное удаление строк
+// @Description Удаление строк вместе с их историей (например, по требованию субъекта данных).
+// @Description Строки удаляются из octet сразу, без асинхронного удаления и очередей записи, затем сжатие хранилища
+// @Description удаляет их прежние значения из журнала операций, а из буфера трассировки удаляется обмен с ними.
+// @Description Тело ответа - отчет об удалении, подписанный HMAC-SHA256 с ключом purge.signing_key
+// @Description (заголовок X-Octet-Signature: sha256=<hex>); поле complete показывает, удалены ли все строки и их история.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body PurgeRequest true "UUID удаляемых строк"
+// @Success 200 {object} service.PurgeReport
+// @Header 200 {string} X-Octet-Signature "Подпись тела ответа"
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Failure 413 {object} BodyTooLargeError
+// @Failure 500 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/purge [post]
+func (h *Handler) Purge(w http.ResponseWriter, r *http.Request) {
+	var purgeReq PurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&purgeReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+	if len(purgeReq.Uuids) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'uuids' не может быть пустым")
+		return
+	}
+	if len(purgeReq.Uuids) > h.purger.MaxUuids() {
+		respondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Количество строк в запросе превышает допустимое (%d)", h.purger.MaxUuids()))
+		return
+	}
+	for _, uuid := range purgeReq.Uuids {
+		if !isOctetUUID(uuid) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("'%s' не является UUID версии 4 в нижнем регистре", uuid))
+			return
+		}
+	}
+
+	// Удаление не прерывается при отключении клиента, чтобы не оставить историю строк в журнале
+	ctx := context.WithoutCancel(r.Context())
+	report, err := h.purger.Purge(ctx, purgeReq.Uuids)
+	if errors.Is(err, service.ErrPurgeRunning) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Error("Не удалось выполнить окончательное удаление строк", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, item := range report.Items {
+		if item.Status == service.PurgeRemoved {
+			h.publishChange(ctx, events.OpRemove, item.Uuid)
+		}
+	}
+
+	// Подписывается тело ответа как есть, поэтому оно отправляется без форматирования
+	body, err := json.Marshal(report)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Ошибка при формировании ответа")
+		return
+	}
+	w.Header()["Content-Type"] = jsonContentType
+	w.Header().Set(SignatureHeader, "sha256="+h.purger.Sign(body))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Максимальное время ожидания завершения выполняющихся изменений перед согласованной выгрузкой
+const quiesceTimeout = 10 * time.Second
+
+// Приостановка изменений на время согласованной выгрузки. Пока она выполняется, изменяющие запросы
+// отклоняются с 503, а чтение продолжается, поэтому выгрузка отражает состояние хранилища на момент начала.
+type WriteGate struct {
+	mutex  sync.Mutex
+	active int // Выполняющиеся изменяющие запросы
+	paused int // Выполняющиеся согласованные выгрузки
+}
+
+// Начало изменяющего запроса. Возвращает false, если изменения приостановлены.
+func (g *WriteGate) enter() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.paused != 0 {
+		return false
+	}
+	g.active++
+	return true
+}
+
+// Окончание изменяющего запроса
+func (g *WriteGate) leave() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.active--
+}
+
+// Приостановлены ли изменения
+func (g *WriteGate) Paused() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.paused != 0
+}
+
+// Приостановка изменений с ожиданием завершения выполняющихся (не дольше quiesceTimeout).
+// При успехе изменения должны быть возобновлены вызовом resume.
+func (g *WriteGate) pause(ctx context.Context) bool {
+	g.mutex.Lock()
+	g.paused++
+	g.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, quiesceTimeout)
+	defer cancel()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		g.mutex.Lock()
+		active := g.active
+		g.mutex.Unlock()
+		if active == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			g.resume()
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// Возобновление изменений после согласованной выгрузки
+func (g *WriteGate) resume() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.paused--
+}
+
+// Слой для отклонения изменяющих запросов на время согласованной выгрузки
+func (h *Handler) quiesce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.writeGate.enter() {
+			w.Header().Set("Retry-After", "1")
+			respondWithJSON(w, http.StatusServiceUnavailable, ErrorHeader{
+				Error: "Изменения приостановлены на время согласованной выгрузки",
+				Code:  ErrorCodeWritesPaused,
+			})
+			return
+		}
+		defer h.writeGate.leave()
+		next.ServeHTTP(w, r)
+	})
+}
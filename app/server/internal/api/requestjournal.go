@@ -0,0 +1,317 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// Размер одной записи в файле журнала запросов (JSON, дополненный пробелами, и перевод строки)
+const requestRecordSize = 256
+
+// Количество записей, возвращаемых по умолчанию
+const defaultRequestRecordsLimit = 1000
+
+// Запись о выполненном HTTP запросе
+type RequestRecord struct {
+	Seq           uint64    `json:"seq"` // Порядковый номер записи
+	Time          time.Time `json:"time"`
+	Method        string    `json:"method"`
+	Route         string    `json:"route"`          // Шаблон маршрута (например, /octet/v1/{uuid})
+	Uuid          string    `json:"uuid,omitempty"` // UUID строки, к которой относится запрос
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int       `json:"response_bytes"`
+	Status        int       `json:"status"`
+	LatencyUs     int64     `json:"latency_us"`
+	RequestID     string    `json:"request_id,omitempty"`
+}
+
+// Журнал запросов в кольцевом файле из записей фиксированного размера.
+// Новые записи перезаписывают самые старые, поэтому размер файла не растет,
+// а журнал сохраняется после перезапуска сервера для разбора инцидентов.
+type RequestJournal struct {
+	mutex   sync.Mutex
+	file    *os.File
+	slots   int    // Количество записей в файле
+	next    int    // Позиция следующей записи
+	nextSeq uint64 // Номер следующей записи
+	failed  atomic.Bool
+}
+
+// Открытие журнала запросов. Если файл уже содержит записи, запись продолжается после последней из них.
+func OpenRequestJournal(path string, maxRecords int) (*RequestJournal, error) {
+	if maxRecords <= 0 {
+		return nil, fmt.Errorf("количество записей журнала запросов должно быть положительным")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию журнала запросов: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал запросов: %w", err)
+	}
+
+	journal := &RequestJournal{file: file, slots: maxRecords, nextSeq: 1}
+	records, err := journal.read()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Записи за пределами текущего размера (если он был уменьшен) отбрасываются
+	if err := file.Truncate(int64(maxRecords) * requestRecordSize); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("не удалось изменить размер журнала запросов: %w", err)
+	}
+	for slot, record := range records {
+		if record.Seq >= journal.nextSeq {
+			journal.nextSeq = record.Seq + 1
+			journal.next = (slot + 1) % maxRecords
+		}
+	}
+	return journal, nil
+}
+
+// Закрытие файла журнала
+func (j *RequestJournal) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// Записи не старше since (нулевое значение - все записи) от старых к новым,
+// не более limit, и признак наличия следующих записей
+func (j *RequestJournal) Records(since time.Time, limit int) ([]RequestRecord, bool, error) {
+	j.mutex.Lock()
+	slots, err := j.read()
+	j.mutex.Unlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	records := make([]RequestRecord, 0, len(slots))
+	for _, record := range slots {
+		if record.Seq != 0 && !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a].Seq < records[b].Seq })
+	if len(records) > limit {
+		return records[:limit], true, nil
+	}
+	return records, false, nil
+}
+
+// Добавление записи на место самой старой
+func (j *RequestJournal) record(record RequestRecord) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	record.Seq = j.nextSeq
+	slot, err := encodeRequestRecord(record)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.WriteAt(slot, int64(j.next)*requestRecordSize); err != nil {
+		return fmt.Errorf("не удалось записать запрос в журнал: %w", err)
+	}
+	j.nextSeq++
+	j.next = (j.next + 1) % j.slots
+	return nil
+}
+
+// Чтение всех записей файла по позициям (пустые позиции имеют нулевой Seq).
+// Вызывается под блокировкой.
+func (j *RequestJournal) read() ([]RequestRecord, error) {
+	if j.file == nil {
+		return nil, fmt.Errorf("журнал запросов закрыт")
+	}
+	data, err := io.ReadAll(io.NewSectionReader(j.file, 0, int64(j.slots)*requestRecordSize))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать журнал запросов: %w", err)
+	}
+
+	records := make([]RequestRecord, len(data)/requestRecordSize)
+	for i := range records {
+		slot := bytes.Trim(data[i*requestRecordSize:(i+1)*requestRecordSize], " \n\x00")
+		if len(slot) == 0 {
+			continue
+		}
+		// Поврежденная запись (например, при сбое во время записи) пропускается
+		if err := json.Unmarshal(slot, &records[i]); err != nil {
+			records[i] = RequestRecord{}
+		}
+	}
+	return records, nil
+}
+
+// Кодирование записи в позицию фиксированного размера.
+// Слишком длинные строковые поля укорачиваются, чтобы запись поместилась.
+func encodeRequestRecord(record RequestRecord) ([]byte, error) {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if len(encoded) >= requestRecordSize {
+		record.RequestID = ""
+		record.Route = record.Route[:min(len(record.Route), 64)]
+		record.Uuid = record.Uuid[:min(len(record.Uuid), 36)]
+		if encoded, err = json.Marshal(record); err != nil {
+			return nil, err
+		}
+		if len(encoded) >= requestRecordSize {
+			return nil, fmt.Errorf("запись журнала запросов превышает %d байт", requestRecordSize)
+		}
+	}
+
+	slot := bytes.Repeat([]byte{' '}, requestRecordSize)
+	copy(slot, encoded)
+	slot[requestRecordSize-1] = '\n'
+	return slot, nil
+}
+
+// Ключ контекста для UUID строки, созданной при обработке запроса
+type journalUuidKey struct{}
+
+// Указание UUID строки, созданной при обработке запроса (для записи в журнал запросов)
+func noteRequestUuid(ctx context.Context, uuid string) {
+	if holder, ok := ctx.Value(journalUuidKey{}).(*string); ok {
+		*holder = uuid
+	}
+}
+
+// Подсчет прочитанных байт тела запроса
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// Слой для записи каждого запроса в журнал запросов
+func RequestJournalMiddleware(journal *RequestJournal, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			body := &countingBody{ReadCloser: r.Body}
+			r.Body = body
+			var createdUuid string
+			r = r.WithContext(context.WithValue(r.Context(), journalUuidKey{}, &createdUuid))
+
+			start := time.Now()
+			defer func() {
+				record := RequestRecord{
+					Time:          start,
+					Method:        r.Method,
+					Route:         r.URL.Path,
+					Uuid:          createdUuid,
+					RequestBytes:  body.n,
+					ResponseBytes: ww.BytesWritten(),
+					Status:        ww.Status(),
+					LatencyUs:     time.Since(start).Microseconds(),
+					RequestID:     middleware.GetReqID(r.Context()),
+				}
+				if record.Status == 0 {
+					record.Status = http.StatusOK
+				}
+				if rctx := chi.RouteContext(r.Context()); rctx != nil {
+					if pattern := rctx.RoutePattern(); len(pattern) != 0 {
+						record.Route = pattern
+					}
+					if uuid := rctx.URLParam("uuid"); len(uuid) != 0 {
+						record.Uuid = uuid
+					}
+				}
+
+				// Ошибка записи журнала не должна влиять на обработку запросов,
+				// поэтому она логируется однократно до восстановления записи
+				if err := journal.record(record); err != nil {
+					if !journal.failed.Swap(true) {
+						logger.Error("Не удалось записать запрос в журнал запросов", zap.Error(err))
+					}
+				} else if journal.failed.Swap(false) {
+					logger.Info("Запись в журнал запросов восстановлена")
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// Записи журнала запросов
+type RequestJournalResponse struct {
+	Records []RequestRecord `json:"records"` // От старых к новым
+	More    bool            `json:"more"`    // Есть ли записи сверх limit
+}
+
+// Requests godoc
+// @Summary Журнал запросов
+// @Description Записи о выполненных HTTP запросах (время, маршрут, UUID, размер, код ответа, длительность)
+// @Description из кольцевого файла журнала запросов, от старых к новым
+// @Tags admin
+// @Produce json
+// @Param since query string false "Время, начиная с которого возвращаются записи (RFC 3339)"
+// @Param limit query int false "Максимальное количество записей (по умолчанию 1000)"
+// @Success 200 {object} RequestJournalResponse
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 500 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/requests [get]
+func (h *Handler) Requests(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); len(value) != 0 {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'since' должен быть временем в формате RFC 3339")
+			return
+		}
+		since = parsed
+	}
+	limit := defaultRequestRecordsLimit
+	if value := r.URL.Query().Get("limit"); len(value) != 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'limit' должен быть положительным числом")
+			return
+		}
+		limit = parsed
+	}
+
+	records, more, err := h.requestJournal.Records(since, limit)
+	if err != nil {
+		h.logger.Error("Ошибка при чтении журнала запросов", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, "Ошибка при чтении журнала запросов")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, RequestJournalResponse{Records: records, More: more})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Состояние удаления строк по политикам хранения
+type RetentionStatus struct {
+	Scheduled bool                      `json:"scheduled"` // Выполняется ли проверка по расписанию
+	DryRun    bool                      `json:"dry_run"`   // Режим проверки по расписанию: только отчет без удаления
+	Running   bool                      `json:"running"`   // Выполняется ли проверка в данный момент
+	Reports   []service.RetentionReport `json:"reports"`   // Отчеты последней проверки (пусто - проверка не выполнялась)
+}
+
+// RetentionStatus godoc
+// @Summary Состояние удаления строк по политикам хранения
+// @Description Режим проверки строк по политикам хранения и отчеты последней проверки:
+// @Description количество проверенных строк, строк старше срока хранения и удаленных строк по каждому пространству имен
+// @Tags admin
+// @Produce json
+// @Success 200 {object} RetentionStatus
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/retention [get]
+func (h *Handler) RetentionStatus(w http.ResponseWriter, r *http.Request) {
+	reports := h.retention.Reports()
+	if reports == nil {
+		reports = []service.RetentionReport{}
+	}
+	respondWithJSON(w, http.StatusOK, RetentionStatus{
+		Scheduled: h.retention.Scheduled(),
+		DryRun:    h.retention.DryRun(),
+		Running:   h.retention.Running(),
+		Reports:   reports,
+	})
+}
+
+// RunRetention godoc
+// @Summary Проверка строк по политикам хранения
+// @Description Немедленная проверка строк по политикам хранения с ожиданием ее завершения.
+// @Description С dry_run=true строки старше срока хранения не удаляются, а только перечисляются в отчете,
+// @Description что позволяет проверить политику до включения удаления.
+// @Tags admin
+// @Produce json
+// @Param dry_run query bool false "Только отчет без удаления (по умолчанию - как в конфигурации)"
+// @Success 200 {array} service.RetentionReport
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/retention/run [post]
+func (h *Handler) RunRetention(w http.ResponseWriter, r *http.Request) {
+	dryRun := h.retention.DryRun()
+	if value := r.URL.Query().Get("dry_run"); len(value) != 0 {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Параметр 'dry_run' должен быть логическим значением")
+			return
+		}
+	}
+
+	if !dryRun {
+		h.logger.Warn("Запущено удаление строк по политикам хранения через административный API")
+	}
+	reports, err := h.retention.Run(r.Context(), dryRun)
+	if errors.Is(err, service.ErrRetentionRunning) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Error("Не удалось проверить строки по политикам хранения", zap.Error(err))
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -8,6 +9,10 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	_ "github.com/lildannita/octet-server/docs"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/metastore"
 	"github.com/lildannita/octet-server/internal/service"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
@@ -19,61 +24,350 @@ type RouterConfig struct {
 	ClientPool *service.ClientPool
 	// Логгер
 	Logger *zap.Logger
+	// Параметры сжатия ответов
+	Compression config.CompressionConfig
+	// Параметры кодирования JSON-ответов
+	JSON config.JSONConfig
+	// Хранилище API-ключей для административного API (nil - управление ключами недоступно)
+	KeyStore *auth.KeyStore
+	// Параметры заголовков кэширования
+	Cache config.CacheConfig
+	// Запись обмена сообщениями с octet (nil - трассировка недоступна)
+	Recorder *service.TrafficRecorder
+	// Внедрение сбоев (nil - управление сбоями недоступно)
+	Faults *service.FaultInjector
+	// Сведения о сервере для /admin/v1/info
+	Info ServerInfo
+	// Хранилище событий изменения данных (nil - события не записываются)
+	Outbox *events.Outbox
+	// Подписчики на события для /admin/v1/events
+	Webhooks []*events.Webhook
+	// Ограничения HTTP запросов
+	RequestLimits config.RequestLimitsConfig
+	// Параметры чтения строк на момент времени
+	TimeTravel config.TimeTravelConfig
+	// Параметры учета и ограничения трафика клиентов
+	Bandwidth config.BandwidthConfig
+	// Параметры пакетных запросов (MaxItems = 0 - пакетные запросы недоступны)
+	Batch config.BatchConfig
+	// Параметры предупреждений о приближении к ограничениям
+	SoftLimits config.SoftLimitsConfig
+	// Пространство имен по умолчанию и обработка прежних адресов
+	Namespaces config.NamespacesConfig
+	// Версия формата ответов по умолчанию (0 - версия 1)
+	APIVersion config.APIVersionConfig
+	// Объединение одиночных изменений в пакеты (nil - изменения отправляются по одному)
+	Coalescer *service.WriteCoalescer
+	// Оценка состояния octet (nil - оценка не выполняется)
+	Health *service.HealthMonitor
+	// Журнал запросов (nil - запросы не записываются)
+	RequestJournal *RequestJournal
+	// Асинхронное удаление с метками удаления (nil - удаление всегда синхронное)
+	Tombstones *service.Tombstones
+	// Прогрев после запуска (nil - сервер готов сразу)
+	Warmup *service.Warmup
+	// Обновление octet (nil - обновление недоступно)
+	Upgrader *service.Upgrader
+	// Параметры вывода сервера из балансировки
+	Drain config.DrainConfig
+	// Завершение работы сервера после вывода из балансировки (nil - завершение недоступно)
+	Shutdown func()
+	// Перехватчики запросов и ответов по маршрутам
+	Interceptors Interceptors
+	// Проверка значений перед добавлением и изменением (nil - без проверки)
+	Validator *service.Validator
+	// Отслеживание файла сокета octet (nil - отслеживание отключено)
+	SocketWatcher *service.SocketWatcher
+	// Длительные потоки, останавливаемые при завершении работы (nil - учитываются только роутером)
+	Streams *Streams
+	// Формат идентификаторов HTTP запросов без заголовка X-Request-Id
+	RequestID config.RequestIDConfig
+	// Параметры обнаружения повторных изменяющих запросов
+	Dedup config.DedupConfig
+	// Параметры сохранения результатов добавлений с ключом идемпотентности
+	Idempotency config.IdempotencyConfig
+	// Ограничение количества строк в хранилище (nil - без ограничения)
+	KeyLimit *service.KeyLimit
+	// События изменения состояния соединений и процесса octet (nil - подписка недоступна)
+	StateEvents *service.StateEvents
+	// История запусков и завершений процесса octet (nil - история не записывается)
+	ProcessHistory *service.ProcessHistory
+	// Префикс пути маршрутов и учет X-Forwarded-Prefix
+	PathPrefix config.PathPrefixConfig
+	// Параметры проверки работоспособности (/health)
+	HealthCheck config.HealthCheckConfig
+	// Итоговая конфигурация для /admin/v1/config (nil - конфигурация недоступна)
+	EffectiveConfig *config.Config
+	// Очередь упорядоченной записи (nil - отключена) и пространства имен, изменения которых через нее проходят
+	Ordered       *service.OrderedWriter
+	OrderedWrites config.OrderedWritesConfig
+	// Удаление строк по политикам хранения (nil - политики не заданы)
+	Retention *service.Retention
+	// Типы содержимого двоичных значений
+	Binary config.BinaryConfig
+	// Окончательное удаление строк (nil - недоступно)
+	Purger *service.Purger
+	// Хранилище сохраненных ответов на повторные запросы и результатов добавлений с ключом идемпотентности
+	// (nil - они хранятся только в памяти)
+	MetadataStore metastore.Store
 }
 
 // NewRouter создает новый роутер с настроенными маршрутами
-func NewRouter(config RouterConfig) http.Handler {
+func NewRouter(config RouterConfig, options ...RouterOption) (http.Handler, error) {
 	if config.ClientPool == nil {
-		panic("пул клиентов не указан")
+		return nil, errors.New("пул клиентов не указан")
 	}
 	if config.Logger == nil {
-		panic("логгер не указан")
+		return nil, errors.New("логгер не указан")
+	}
+	o := routerOptions{basePath: DefaultBasePath}
+	for _, option := range options {
+		if err := option(&o); err != nil {
+			return nil, err
+		}
 	}
 
 	r := chi.NewRouter()
+	stats := NewRequestStats()
+	streams := config.Streams
+	if streams == nil {
+		streams = NewStreams()
+	}
+	drainer := NewDrainer(config.Drain, streams, config.Shutdown)
 
 	// Базовые middleware
-	r.Use(middleware.RequestID)
+	r.Use(RequestIDMiddleware(config.RequestID.UUIDv7()))
 	r.Use(middleware.RealIP)
+	// Значения строк отдаются с сохраненным типом содержимого, поэтому браузер не должен его угадывать
+	r.Use(middleware.SetHeader("X-Content-Type-Options", "nosniff"))
 	r.Use(middleware.Recoverer)
+	r.Use(TraceContextMiddleware)
 	r.Use(middleware.Timeout(60 * time.Second))
 	r.Use(LoggerMiddleware(config.Logger))
+	r.Use(o.middlewares...)
+	r.Use(StatsMiddleware(stats))
+	r.Use(drainer.Middleware)
+	if config.RequestJournal != nil {
+		r.Use(RequestJournalMiddleware(config.RequestJournal, config.Logger))
+	}
+	r.Use(DecompressMiddleware(config.Compression, config.RequestLimits.MaxBodyBytes))
+	r.Use(BodyLimitMiddleware(config.RequestLimits.MaxBodyBytes))
+	r.Use(JSONEncodingMiddleware(config.JSON))
+	r.Use(CompressMiddleware(config.Compression))
 	// CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", "X-API-Key", "If-None-Match", "If-Match", HeaderAPIVersion, "traceparent", "tracestate", "Prefer"},
+		ExposedHeaders:   []string{"Link", "Location", "ETag", HeaderWarning, HeaderPoolRemaining, HeaderBatchRemaining, HeaderAPIVersion, "Preference-Applied"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 	// Проверка Content-Type middleware
-	r.Use(ContentTypeMiddleware("application/json"))
+	r.Use(ContentTypeMiddleware("application/json", config.Binary.ContentTypes))
 
 	// Обработчики API
 	h := &Handler{
 		clientPool: config.ClientPool,
 		logger:     config.Logger,
+		keyStore:   config.KeyStore,
+		cache:      config.Cache,
+		recorder:   config.Recorder,
+		faults:     config.Faults,
+		info:       config.Info,
+		stats:      stats,
+		startedAt:  time.Now(),
+		outbox:     config.Outbox,
+		webhooks:   config.Webhooks,
+		batch:      config.Batch,
+		timeTravel: config.TimeTravel,
+		coalescer:  config.Coalescer,
+		health:     config.Health,
+
+		softLimits:     config.SoftLimits,
+		namespaces:     config.Namespaces,
+		requestJournal: config.RequestJournal,
+		tombstones:     config.Tombstones,
+		warmup:         config.Warmup,
+		upgrader:       config.Upgrader,
+		drainer:        drainer,
+		streams:        streams,
+		dedup:          NewDeduplicator(config.Dedup, config.MetadataStore, config.Logger),
+		idempotency:    newIdempotency(config.Idempotency, config.MetadataStore, config.Logger),
+		keyLimit:       config.KeyLimit,
+		stateEvents:    config.StateEvents,
+		metrics:        o.metrics,
+		interceptors:   config.Interceptors,
+		validator:      config.Validator,
+		socketWatcher:  config.SocketWatcher,
+		processHistory: config.ProcessHistory,
+		healthProbe:    NewHealthProbe(config.ClientPool, time.Duration(config.HealthCheck.MaxStaleMs)*time.Millisecond, config.Logger),
+		ordered:        config.Ordered,
+		orderedWrites:  config.OrderedWrites,
+		retention:      config.Retention,
+		binary:         config.Binary,
+		purger:         config.Purger,
+
+		importRecordLimit: config.RequestLimits.MaxBodyBytes,
+		writeGate:         new(WriteGate),
+	}
+	if config.Bandwidth.Enabled {
+		h.bandwidth = NewBandwidth(config.Bandwidth)
+	}
+	if config.EffectiveConfig != nil {
+		// Секреты удаляются один раз, а не при каждом запросе
+		redacted := config.EffectiveConfig.Redacted()
+		h.effectiveConfig = &redacted
 	}
 
 	// Маршруты
 	r.Get("/health", h.HealthCheck)
+	r.Get("/ready", h.Readiness)
 
 	// API
-	r.Route("/octet", func(r chi.Router) {
+	r.Route(o.basePath, func(r chi.Router) {
+		r.Use(AuthMiddleware(o.authenticator, config.Logger))
+		if h.bandwidth != nil {
+			r.Use(BandwidthMiddleware(h.bandwidth))
+		}
+
 		// API v1
 		r.Route("/v1", func(r chi.Router) {
-			r.Post("/", h.Insert)
-			r.Get("/{uuid}", h.Get)
-			r.Put("/{uuid}", h.Update)
-			r.Delete("/{uuid}", h.Remove)
+			r.Use(APIVersionMiddleware(max(config.APIVersion.Default, APIVersion1)))
+			if config.SoftLimits.Enabled {
+				r.Use(SoftLimitsMiddleware(config.ClientPool, config.SoftLimits.Threshold))
+			}
+
+			// Адреса в пространстве имен
+			r.Route("/ns/{namespace}", func(r chi.Router) {
+				r.Use(NamespaceMiddleware(config.Namespaces))
+				dataRoutes(r, h, config.Batch.MaxItems > 0)
+			})
+
+			// Прежние адреса без пространства имен
+			legacyDataRoutes(r, h, config, o.basePath)
 		})
 	})
 
-	// OpenAPI документация
+	// Административный API
+	r.Route("/admin", func(r chi.Router) {
+		// Страница панели состояния (данные запрашиваются ей через API с аутентификацией)
+		r.Get("/ui", h.StatusPage)
+
+		r.Route("/v1", func(r chi.Router) {
+			r.Use(AuthMiddleware(o.authenticator, config.Logger))
+			r.Use(RequireScope(auth.ScopeAdmin))
+
+			r.Get("/info", h.Info)
+			if h.effectiveConfig != nil {
+				r.Get("/config", h.EffectiveConfig)
+			}
+			r.Get("/status", h.Status)
+			r.Get("/metrics", h.Metrics)
+			r.Get("/pool/discards", h.PoolDiscards)
+			r.Get("/compact", h.CompactionStatus)
+			r.Post("/compact", h.Compact)
+			r.Get("/journal", h.Journal)
+			r.Post("/drain", h.Drain)
+			r.Delete("/drain", h.Undrain)
+			if config.StateEvents != nil {
+				r.Get("/state/events", h.StateEvents)
+			}
+			if config.KeyStore != nil {
+				r.Get("/apikeys", h.ListAPIKeys)
+				r.Post("/apikeys", h.CreateAPIKey)
+				r.Put("/apikeys/{name}", h.UpdateAPIKey)
+				r.Delete("/apikeys/{name}", h.DeleteAPIKey)
+			}
+			if config.Recorder != nil {
+				r.Get("/trace", h.Trace)
+			}
+			if config.Faults != nil {
+				r.Get("/faults", h.GetFaults)
+				r.Put("/faults", h.SetFaults)
+				r.Post("/faults/kill", h.KillBackend)
+			}
+			if config.Health != nil {
+				r.Get("/health", h.BackendHealth)
+			}
+			if config.Upgrader != nil {
+				r.Get("/process/upgrade", h.UpgradeStatus)
+				r.Post("/process/upgrade", h.UpgradeBackend)
+			}
+			if config.ProcessHistory != nil {
+				r.Get("/process/history", h.ProcessHistory)
+			}
+			if config.RequestJournal != nil {
+				r.Get("/requests", h.Requests)
+			}
+			if config.Outbox != nil {
+				r.Get("/events", h.EventStats)
+				r.Post("/events/replay", h.ReplayEvents)
+			}
+			if config.Retention != nil {
+				r.Get("/retention", h.RetentionStatus)
+				r.Post("/retention/run", h.RunRetention)
+			}
+			if config.Purger != nil {
+				r.Post("/purge", h.Purge)
+			}
+		})
+	})
+
+	// OpenAPI документация (адрес описания относительный, чтобы работать под префиксом пути)
+	r.Get("/swagger", swaggerIndex)
+	r.Get("/swagger/", swaggerIndex)
+	r.Get("/swagger/doc.json", swaggerDoc)
 	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("/swagger/doc.json"),
+		httpSwagger.URL("doc.json"),
 	))
 
-	return r
+	return pathPrefixHandler(config.PathPrefix, r), nil
+}
+
+// Регистрация прежних маршрутов работы со строками без пространства имен
+func legacyDataRoutes(r chi.Router, h *Handler, cfg RouterConfig, basePath string) {
+	batch := cfg.Batch.MaxItems > 0
+	switch cfg.Namespaces.LegacyRoutes {
+	case "", config.LegacyRoutesRewrite:
+		// Выполнение в пространстве имен по умолчанию
+		r.Group(func(r chi.Router) {
+			r.Use(NamespaceMiddleware(cfg.Namespaces))
+			dataRoutes(r, h, batch)
+		})
+	case config.LegacyRoutesRedirect:
+		redirect := namespaceRedirect(cfg.Namespaces, basePath)
+		r.Get("/", redirect)
+		r.Get("/count", redirect)
+		r.Get("/export", redirect)
+		r.Post("/import", redirect)
+		r.Post("/", redirect)
+		if batch {
+			r.Post("/batch", redirect)
+			r.Post("/mget", redirect)
+		}
+		r.Get("/{uuid}", redirect)
+		r.Head("/{uuid}", redirect)
+		r.Get("/{uuid}/meta", redirect)
+		r.Put("/{uuid}", redirect)
+		r.Delete("/{uuid}", redirect)
+	}
+}
+
+// Регистрация маршрутов работы со строками
+func dataRoutes(r chi.Router, h *Handler, batch bool) {
+	r.With(RequireScope(auth.ScopeRead)).Get("/", h.List)
+	r.With(RequireScope(auth.ScopeRead)).Get("/count", h.Count)
+	r.With(RequireScope(auth.ScopeRead)).Get("/export", h.Export)
+	r.With(RequireScope(auth.ScopeWrite), h.quiesce).Post("/import", h.Import)
+	r.With(RequireScope(auth.ScopeWrite), h.quiesce, h.expectContinue, h.deduplicate, h.intercept(config.RouteInsert)).Post("/", h.Insert)
+	if batch {
+		r.With(RequireScope(auth.ScopeWrite), h.quiesce, h.deduplicate, h.intercept(config.RouteBatch)).Post("/batch", h.Batch)
+		r.With(RequireScope(auth.ScopeRead)).Post("/mget", h.Mget)
+	}
+	r.With(RequireScope(auth.ScopeRead), h.intercept(config.RouteGet)).Get("/{uuid}", h.Get)
+	r.With(RequireScope(auth.ScopeRead)).Head("/{uuid}", h.Exists)
+	r.With(RequireScope(auth.ScopeRead)).Get("/{uuid}/meta", h.Meta)
+	r.With(RequireScope(auth.ScopeWrite), h.quiesce, h.expectContinue, h.deduplicate, h.intercept(config.RouteUpdate)).Put("/{uuid}", h.Update)
+	r.With(RequireScope(auth.ScopeWrite), h.quiesce, h.deduplicate, h.intercept(config.RouteRemove)).Delete("/{uuid}", h.Remove)
 }
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Заголовки предупреждений о приближении к ограничениям
+const (
+	// Коды предупреждений через запятую
+	HeaderWarning = "X-Octet-Warning"
+	// Количество свободных соединений с octet
+	HeaderPoolRemaining = "X-Octet-Pool-Remaining"
+	// Количество операций, которые еще можно добавить в пакет
+	HeaderBatchRemaining = "X-Octet-Batch-Remaining"
+)
+
+// Коды предупреждений о приближении к ограничениям
+const (
+	WarningPoolNearCapacity = "pool_near_capacity" // Занята большая часть соединений с octet (далее - 429)
+	WarningBatchNearLimit   = "batch_near_limit"   // Пакет близок к максимальному размеру (далее - 413)
+)
+
+// Обертка ResponseWriter, добавляющая предупреждения к успешным ответам
+type softLimitsWriter struct {
+	http.ResponseWriter
+	pool        *service.ClientPool
+	threshold   float64
+	wroteHeader bool
+}
+
+func (w *softLimitsWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code < http.StatusBadRequest {
+			// Состояние пула на момент ответа: соединение этого запроса уже освобождено
+			stats := w.pool.Stats()
+			if float64(stats.InUse) >= w.threshold*float64(stats.Size) {
+				addWarning(w.Header(), WarningPoolNearCapacity)
+				w.Header().Set(HeaderPoolRemaining, strconv.Itoa(stats.Idle))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *softLimitsWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Доступ к исходному ResponseWriter для http.ResponseController
+func (w *softLimitsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Слой для добавления к успешным ответам предупреждений о приближении к ограничениям,
+// чтобы клиенты могли снизить нагрузку до получения 429
+func SoftLimitsMiddleware(pool *service.ClientPool, threshold float64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&softLimitsWriter{ResponseWriter: w, pool: pool, threshold: threshold}, r)
+		})
+	}
+}
+
+// Предупреждение о приближении пакета к максимальному размеру
+func (h *Handler) warnBatchLimit(w http.ResponseWriter, items int) {
+	if !h.softLimits.Enabled || float64(items) < h.softLimits.Threshold*float64(h.batch.MaxItems) {
+		return
+	}
+	addWarning(w.Header(), WarningBatchNearLimit)
+	w.Header().Set(HeaderBatchRemaining, strconv.Itoa(h.batch.MaxItems-items))
+}
+
+// Добавление кода предупреждения к заголовку
+func addWarning(header http.Header, code string) {
+	if warning := header.Get(HeaderWarning); len(warning) != 0 {
+		code = warning + ", " + code
+	}
+	header.Set(HeaderWarning, code)
+}
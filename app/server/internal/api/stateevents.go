@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Размер буфера событий одного подписчика
+const stateEventsBuffer = 64
+
+// Запас до истечения времени запроса, с которым завершается подписка
+const stateEventsDeadlineMargin = time.Second
+
+// StateEvents godoc
+// @Summary Подписка на события изменения состояния
+// @Description События установки, закрытия и разрыва соединений с octet, изменения состояния
+// @Description и перезапуска процесса octet в формате NDJSON (одно событие в строке) по мере их возникновения.
+// @Description Подписка завершается до истечения времени запроса (трейлер X-Octet-Stream-End: timeout),
+// @Description после чего клиенту следует переподключиться, и при завершении работы сервера (shutdown).
+// @Description События, не отправленные клиенту вовремя, отбрасываются.
+// @Tags admin
+// @Produce application/x-ndjson
+// @Success 200 {array} service.StateEvent
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/state/events [get]
+func (h *Handler) StateEvents(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := h.stateEvents.Channel(stateEventsBuffer)
+	defer unsubscribe()
+	stop, done := h.streams.subscribe()
+	defer done()
+
+	// Подписка завершается сама, чтобы не получить ответ о превышении времени запроса
+	var deadline <-chan time.Time
+	if until, ok := r.Context().Deadline(); ok {
+		timer := time.NewTimer(time.Until(until) - stateEventsDeadlineMargin)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", StreamEndTrailer)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(jsonEncodingOf(w).escapeHTML)
+	var end string
+	for len(end) == 0 {
+		select {
+		case event := <-events:
+			if err := encoder.Encode(&event); err != nil {
+				// Клиент закрыл соединение
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-stop:
+			end = StreamEndShutdown
+		case <-deadline:
+			end = StreamEndTimeout
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set(StreamEndTrailer, end)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Количество последних ошибок, хранимых для панели состояния
+const recentErrorsSize = 50
+
+// Запись о запросе, завершившемся ошибкой сервера
+type RecentError struct {
+	Time      string `json:"time"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Снимок счетчиков HTTP запросов
+type RequestStatsSnapshot struct {
+	Total        uint64        `json:"total"`
+	Status2xx    uint64        `json:"status_2xx"`
+	Status3xx    uint64        `json:"status_3xx"`
+	Status4xx    uint64        `json:"status_4xx"`
+	Status5xx    uint64        `json:"status_5xx"`
+	RecentErrors []RecentError `json:"recent_errors"` // От новых к старым
+}
+
+// Счетчики HTTP запросов с момента запуска
+type RequestStats struct {
+	total   atomic.Uint64
+	byClass [6]atomic.Uint64 // Индекс - первая цифра кода ответа
+
+	mutex  sync.Mutex
+	errors []RecentError // Кольцевой буфер последних ошибок
+	next   int
+}
+
+// Создание новых счетчиков запросов
+func NewRequestStats() *RequestStats {
+	return &RequestStats{errors: make([]RecentError, 0, recentErrorsSize)}
+}
+
+// Учет завершенного запроса
+func (s *RequestStats) record(r *http.Request, status int) {
+	if status == 0 {
+		// Обработчик не установил код ответа явно
+		status = http.StatusOK
+	}
+	s.total.Add(1)
+	if class := status / 100; class > 0 && class < len(s.byClass) {
+		s.byClass[class].Add(1)
+	}
+	if status < http.StatusInternalServerError {
+		return
+	}
+
+	entry := RecentError{
+		Time:      time.Now().Format(time.RFC3339),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    status,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	s.mutex.Lock()
+	if len(s.errors) < recentErrorsSize {
+		s.errors = append(s.errors, entry)
+	} else {
+		s.errors[s.next] = entry
+	}
+	s.next = (s.next + 1) % recentErrorsSize
+	s.mutex.Unlock()
+}
+
+// Снимок текущих счетчиков
+func (s *RequestStats) Snapshot() RequestStatsSnapshot {
+	snapshot := RequestStatsSnapshot{
+		Total:     s.total.Load(),
+		Status2xx: s.byClass[2].Load(),
+		Status3xx: s.byClass[3].Load(),
+		Status4xx: s.byClass[4].Load(),
+		Status5xx: s.byClass[5].Load(),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot.RecentErrors = make([]RecentError, 0, len(s.errors))
+	for i := range len(s.errors) {
+		index := (s.next - 1 - i + 2*recentErrorsSize) % recentErrorsSize
+		if len(s.errors) < recentErrorsSize {
+			index = len(s.errors) - 1 - i
+		}
+		snapshot.RecentErrors = append(snapshot.RecentErrors, s.errors[index])
+	}
+	return snapshot
+}
+
+// Слой для учета HTTP запросов в счетчиках
+func StatsMiddleware(stats *RequestStats) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				stats.record(r, ww.Status())
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/service"
+)
+
+// Страница панели состояния
+//
+//go:embed ui/index.html
+var statusPage []byte
+
+// Состояние процесса octet
+type BackendStatus struct {
+	State    string `json:"state"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Сводное состояние сервера для панели состояния
+type StatusResponse struct {
+	Time          string               `json:"time"`
+	UptimeSeconds int64                `json:"uptime_seconds"`
+	Goroutines    int                  `json:"goroutines"`
+	Backend       BackendStatus        `json:"backend"`
+	Pool          service.PoolStats    `json:"pool"`
+	Requests      RequestStatsSnapshot `json:"requests"`
+	// Строки с меткой удаления, ожидающие удаления в octet (только при асинхронном удалении)
+	PendingDeletes *int `json:"pending_deletes,omitempty"`
+	// Обнаружение повторных изменяющих запросов (только если оно включено)
+	Duplicates *DedupStats `json:"duplicates,omitempty"`
+	// Сохранение результатов добавлений с ключом идемпотентности (только если оно включено)
+	Idempotency *service.IdempotencyStats `json:"idempotency,omitempty"`
+	// Ограничение количества строк (только если оно задано)
+	KeyLimit *service.KeyLimitStats `json:"key_limit,omitempty"`
+}
+
+// Status godoc
+// @Summary Сводное состояние сервера
+// @Description Состояние процесса octet, загрузка пула соединений, счетчики запросов и последние ошибки
+// @Tags admin
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/status [get]
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	state, exitCode, err := h.clientPool.BackendState()
+	backend := BackendStatus{State: state.String(), ExitCode: exitCode}
+	if err != nil {
+		backend.Error = err.Error()
+	}
+
+	response := StatusResponse{
+		Time:          time.Now().Format(time.RFC3339),
+		UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		Backend:       backend,
+		Pool:          h.clientPool.Stats(),
+		Requests:      h.stats.Snapshot(),
+	}
+	if h.tombstones != nil {
+		pending := h.tombstones.Pending()
+		response.PendingDeletes = &pending
+	}
+	if h.dedup != nil {
+		duplicates := h.dedup.Stats()
+		response.Duplicates = &duplicates
+	}
+	if h.idempotency != nil {
+		idempotency := h.idempotency.Stats()
+		response.Idempotency = &idempotency
+	}
+	if h.keyLimit != nil {
+		keyLimit := h.keyLimit.Stats()
+		response.KeyLimit = &keyLimit
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Отдача встроенной страницы панели состояния.
+// Страница не содержит данных и доступна без аутентификации,
+// данные запрашиваются ей из /admin/v1/status с ключом, введенным пользователем.
+func (h *Handler) StatusPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(statusPage)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Заголовок-трейлер с причиной окончания длительного потока
+const StreamEndTrailer = "X-Octet-Stream-End"
+
+// Причины окончания длительного потока
+const (
+	StreamEndComplete = "complete" // Поток передан полностью
+	StreamEndShutdown = "shutdown" // Поток остановлен из-за завершения работы сервера
+	StreamEndTimeout  = "timeout"  // Подписка завершена до истечения времени запроса, клиенту следует переподключиться
+)
+
+// Интервал проверки завершения длительных потоков при завершении работы
+const streamsPollInterval = 100 * time.Millisecond
+
+// Длительные потоки (ответы, передаваемые частями в течение минут). При завершении работы сервера
+// они получают отдельное время на завершение, после чего останавливаются и сообщают клиенту
+// о завершении работы, чтобы он мог продолжить с места остановки на другом сервере.
+// Подписки (потоки без естественного окончания) останавливаются сразу в начале завершения работы.
+type Streams struct {
+	active      atomic.Int64
+	stopping    chan struct{}
+	stopOnce    sync.Once
+	closing     chan struct{}
+	closingOnce sync.Once
+}
+
+// Создание учета длительных потоков
+func NewStreams() *Streams {
+	return &Streams{stopping: make(chan struct{}), closing: make(chan struct{})}
+}
+
+// Количество выполняющихся длительных потоков
+func (s *Streams) Active() int64 {
+	return s.active.Load()
+}
+
+// Начало длительного потока. Возвращает канал, закрываемый при остановке потоков,
+// и функцию, вызываемую при окончании потока.
+func (s *Streams) begin() (<-chan struct{}, func()) {
+	s.active.Add(1)
+	return s.stopping, func() { s.active.Add(-1) }
+}
+
+// Начало подписки. Возвращает канал, закрываемый в начале завершения работы,
+// и функцию, вызываемую при окончании подписки.
+func (s *Streams) subscribe() (<-chan struct{}, func()) {
+	s.active.Add(1)
+	return s.closing, func() { s.active.Add(-1) }
+}
+
+// Остановка длительных потоков
+func (s *Streams) Stop() {
+	s.closingOnce.Do(func() { close(s.closing) })
+	s.stopOnce.Do(func() { close(s.stopping) })
+}
+
+// Контекст завершения работы HTTP серверов. Подписки останавливаются сразу.
+// Контекст истекает через timeout_seconds, если длительных потоков нет.
+// Иначе потоки останавливаются через stream_grace_seconds, и контекст истекает после их окончания,
+// но не позже чем через timeout_seconds после остановки.
+func (s *Streams) ShutdownContext(config config.ShutdownConfig) (context.Context, context.CancelFunc) {
+	s.closingOnce.Do(func() { close(s.closing) })
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	grace := time.Duration(config.StreamGraceSeconds) * time.Second
+
+	go func() {
+		requests := time.NewTimer(timeout)
+		defer requests.Stop()
+		streams := time.NewTimer(grace)
+		defer streams.Stop()
+		ticker := time.NewTicker(streamsPollInterval)
+		defer ticker.Stop()
+
+		requestsExpired := false
+		var deadline <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-requests.C:
+				requestsExpired = true
+			case <-streams.C:
+				s.Stop()
+				deadline = time.After(timeout)
+			case <-deadline:
+				cancel()
+				return
+			case <-ticker.C:
+			}
+			if requestsExpired && s.Active() == 0 {
+				cancel()
+				return
+			}
+		}
+	}()
+	return ctx, cancel
+}
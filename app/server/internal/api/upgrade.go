@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Запрос на обновление octet
+type UpgradeRequest struct {
+	Path string `json:"path"` // Путь к новому исполняемому файлу octet
+}
+
+// UpgradeBackend godoc
+// @Summary Обновление octet
+// @Description Замена исполняемого файла octet без перезапуска сервера: ожидание завершения запросов,
+// @Description остановка процесса, замена файла, запуск и проверка нового процесса.
+// @Description Если новый процесс не запускается или не отвечает, восстанавливается прежний исполняемый файл.
+// @Description Обновление выполняется асинхронно, его завершение отслеживается через GET /admin/v1/process/upgrade.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body UpgradeRequest true "Новый исполняемый файл"
+// @Success 202 {object} service.UpgradeStatus
+// @Failure 400 {object} ErrorHeader
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Failure 409 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/process/upgrade [post]
+func (h *Handler) UpgradeBackend(w http.ResponseWriter, r *http.Request) {
+	var upgradeReq UpgradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&upgradeReq); err != nil {
+		h.logger.Error("Ошибка при разборе запроса", zap.Error(err))
+		respondWithDecodeError(w, err)
+		return
+	}
+	if len(upgradeReq.Path) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Поле 'path' не может быть пустым")
+		return
+	}
+
+	status, err := h.upgrader.Start(upgradeReq.Path)
+	switch {
+	case errors.Is(err, service.ErrUpgradeInProgress), errors.Is(err, service.ErrNotManaged):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.logger.Warn("Запущено обновление octet через административный API", zap.String("path", upgradeReq.Path))
+	respondWithJSON(w, http.StatusAccepted, status)
+}
+
+// UpgradeStatus godoc
+// @Summary Состояние обновления octet
+// @Description Состояние последнего обновления octet (idle, running, completed, rolled_back, failed) и его текущий этап
+// @Tags admin
+// @Produce json
+// @Success 200 {object} service.UpgradeStatus
+// @Failure 401 {object} ErrorHeader
+// @Failure 403 {object} ErrorHeader
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /admin/v1/process/upgrade [get]
+func (h *Handler) UpgradeStatus(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.upgrader.Status())
+}
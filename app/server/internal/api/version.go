@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Заголовок с версией формата ответов API
+const HeaderAPIVersion = "X-Octet-Api-Version"
+
+// Версии формата ответов API
+const (
+	APIVersion1 = 1 // Исходный формат
+	APIVersion2 = 2 // GET возвращает метаданные строки в поле meta
+)
+
+// Ключ контекста для версии формата ответов
+type apiVersionKey struct{}
+
+// Версия формата ответов, запрошенная клиентом
+func apiVersion(ctx context.Context) int {
+	if version, ok := ctx.Value(apiVersionKey{}).(int); ok {
+		return version
+	}
+	return APIVersion1
+}
+
+// Слой для выбора версии формата ответов по заголовку X-Octet-Api-Version.
+// Без заголовка используется версия из конфигурации, поэтому изменения формата
+// включаются для каждого клиента отдельно.
+func APIVersionMiddleware(defaultVersion int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := defaultVersion
+			if value := r.Header.Get(HeaderAPIVersion); len(value) != 0 {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed < APIVersion1 || parsed > config.LatestAPIVersion {
+					respondWithJSON(w, http.StatusBadRequest, ErrorHeader{
+						Error: fmt.Sprintf("Неподдерживаемая версия API '%s' (поддерживаются версии %d-%d)",
+							value, APIVersion1, config.LatestAPIVersion),
+						Code: ErrorCodeUnsupportedAPIVersion,
+					})
+					return
+				}
+				version = parsed
+			}
+
+			w.Header().Add("Vary", HeaderAPIVersion)
+			w.Header().Set(HeaderAPIVersion, strconv.Itoa(version))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package apitest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Переменная окружения для перезаписи эталонных файлов фактическими ответами
+const UpdateEnv = "APITEST_UPDATE"
+
+// Заголовки ответа, сохраняемые в эталонных файлах
+var goldenHeaders = []string{"Content-Type", "Cache-Control", "ETag", "Link", "Location", "Preference-Applied", "Retry-After",
+	"X-Octet-Warning", "X-Octet-Pool-Remaining", "X-Octet-Batch-Remaining", "X-Octet-Api-Version", "X-Octet-Replayed",
+	"Accept-Encoding", "Content-Encoding"}
+
+// Значения, меняющиеся от запуска к запуску
+var (
+	uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	timePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// Запрос и проверка ответа по эталонному файлу <Name>.golden
+type Case struct {
+	Name   string
+	Method string
+	// Путь и тело запроса; {uuid} заменяется UUID, полученным в последнем ответе с полем uuid
+	Path string
+	Body string
+	// Заголовки запроса; {etag} заменяется последним полученным ETag
+	Header http.Header
+	// Поля JSON-ответа (на любом уровне вложенности) или метрики ответа в формате Prometheus,
+	// значения которых не сравниваются
+	Ignore []string
+}
+
+// Запросы ко всем маршрутам тестового сервера в порядке выполнения
+func Cases() []Case {
+	noAuth := http.Header{"X-Api-Key": {""}}
+	return []Case{
+		{Name: "health", Method: http.MethodGet, Path: "/health"},
+		{Name: "health_detailed", Method: http.MethodGet, Path: "/health?detailed=true",
+			Ignore: []string{"keys", "mutations", "uptime_seconds"}},
+
+		{Name: "ready", Method: http.MethodGet, Path: "/ready"},
+		{Name: "insert", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`},
+		{Name: "insert_representation", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Prefer": {"return=representation"}}},
+		{Name: "insert_idempotent", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Idempotency-Key": {"apitest"}}},
+		{Name: "insert_idempotent_replay", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Idempotency-Key": {"apitest"}}},
+		{Name: "insert_idempotent_conflict", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest other"}`,
+			Header: http.Header{"Idempotency-Key": {"apitest"}}},
+		{Name: "insert_idempotent_remove", Method: http.MethodDelete, Path: "/octet/v1/{uuid}"},
+		{Name: "insert_idempotent_after_remove", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Idempotency-Key": {"apitest"}}},
+		{Name: "insert_idempotent_after_remove_get", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "insert_after_idempotent", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`},
+		{Name: "insert_empty", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":""}`},
+		{Name: "insert_invalid_ttl", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest","ttl_seconds":-1}`},
+		{Name: "insert_malformed", Method: http.MethodPost, Path: "/octet/v1/", Body: `{`},
+		{Name: "insert_wrong_content_type", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Content-Type": {"application/xml"}}},
+		{Name: "insert_too_large", Method: http.MethodPost, Path: "/octet/v1/",
+			Body: `{"data":"` + strings.Repeat("apitest ", 32) + `"}`},
+		{Name: "insert_unauthorized", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: noAuth},
+		{Name: "batch", Method: http.MethodPost, Path: "/octet/v1/batch",
+			Body: `{"items":[{"op":"insert","data":"apitest batch"},{"op":"update","uuid":"00000000-0000-0000-0000-000000000000","data":"apitest"},{"op":"remove","uuid":"00000000-0000-0000-0000-000000000000"}]}`},
+		{Name: "batch_too_large", Method: http.MethodPost, Path: "/octet/v1/batch",
+			Body: `{"items":[{"op":"remove","uuid":"a"},{"op":"remove","uuid":"b"},{"op":"remove","uuid":"c"},{"op":"remove","uuid":"d"}]}`},
+		{Name: "batch_invalid_item", Method: http.MethodPost, Path: "/octet/v1/batch",
+			Body: `{"items":[{"op":"insert","data":"apitest"},{"op":"upsert","uuid":"a"},{"op":"update","uuid":"a"}]}`},
+		{Name: "get", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "exists", Method: http.MethodHead, Path: "/octet/v1/{uuid}"},
+		{Name: "meta", Method: http.MethodGet, Path: "/octet/v1/{uuid}/meta"},
+		{Name: "list", Method: http.MethodGet, Path: "/octet/v1?limit=1"},
+		{Name: "list_invalid_cursor", Method: http.MethodGet, Path: "/octet/v1?cursor=a"},
+		{Name: "count", Method: http.MethodGet, Path: "/octet/v1/count"},
+		{Name: "export_invalid_cursor", Method: http.MethodGet, Path: "/octet/v1/export?cursor=a"},
+		{Name: "export_invalid_consistent", Method: http.MethodGet, Path: "/octet/v1/export?consistent=maybe"},
+		{Name: "export_invalid_modified_since", Method: http.MethodGet, Path: "/octet/v1/export?modified_since=yesterday"},
+		{Name: "export_invalid_include_metadata", Method: http.MethodGet, Path: "/octet/v1/export?include_metadata=maybe"},
+		{Name: "export_namespace_not_found", Method: http.MethodGet, Path: "/octet/v1/export?namespace=unknown"},
+		{Name: "export_modified_since", Method: http.MethodGet, Path: "/octet/v1/export?modified_since=2100-01-01T00:00:00Z"},
+		{Name: "mget", Method: http.MethodPost, Path: "/octet/v1/mget",
+			Body: `{"uuids":["{uuid}","00000000-0000-0000-0000-000000000000","a"]}`},
+		{Name: "mget_too_large", Method: http.MethodPost, Path: "/octet/v1/mget",
+			Body: `{"uuids":["a","b","c","d"]}`},
+		{Name: "get_namespace", Method: http.MethodGet, Path: "/octet/v1/ns/default/{uuid}"},
+		{Name: "get_namespace_forbidden", Method: http.MethodGet, Path: "/octet/v1/ns/default/{uuid}",
+			Header: http.Header{"X-Api-Key": {TenantKey}}},
+		{Name: "get_legacy_forbidden", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"X-Api-Key": {TenantKey}}},
+		{Name: "get_namespace_not_found", Method: http.MethodGet, Path: "/octet/v1/ns/unknown/{uuid}"},
+		{Name: "get_api_version_2", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"X-Octet-Api-Version": {"2"}}},
+		{Name: "get_api_version_unsupported", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"X-Octet-Api-Version": {"3"}}},
+		{Name: "get_not_modified", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"If-None-Match": {"{etag}"}}},
+		{Name: "update", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest updated"}`},
+		{Name: "update_if_match_stale", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest stale"}`,
+			Header: http.Header{"If-Match": {"{etag}"}}},
+		{Name: "update_if_match", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest updated"}`,
+			Header: http.Header{"If-Match": {`"v2"`}}},
+		{Name: "create_exists", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest"}`,
+			Header: http.Header{"If-None-Match": {"*"}}},
+		{Name: "get_updated", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "get_if_modified_since", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"If-Modified-Since": {"Fri, 01 Jan 2100 00:00:00 GMT"}}},
+		{Name: "get_modified_since", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"If-Modified-Since": {"Mon, 01 Jan 2001 00:00:00 GMT"}}},
+		// Сжатый ответ содержит ослабленный ETag по версии, с которым обновление проходит проверку If-Match
+		{Name: "get_compressed", Method: http.MethodGet, Path: "/octet/v1/{uuid}",
+			Header: http.Header{"Accept-Encoding": {"gzip"}}},
+		{Name: "update_if_match_compressed", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest updated"}`,
+			Header: http.Header{"If-Match": {"{etag}"}, "Accept-Encoding": {"gzip"}}},
+		{Name: "get_as_of", Method: http.MethodGet, Path: "/octet/v1/{uuid}?as_of=2100-01-01T00:00:00Z"},
+		{Name: "get_as_of_before_insert", Method: http.MethodGet, Path: "/octet/v1/{uuid}?as_of=2000-01-01T00:00:00Z"},
+		{Name: "get_as_of_invalid", Method: http.MethodGet, Path: "/octet/v1/{uuid}?as_of=yesterday"},
+		{Name: "import", Method: http.MethodPost, Path: "/octet/v1/import",
+			Body:   "{\"uuid\":\"{uuid}\",\"data\":\"apitest imported\"}\n{\"data\":\"apitest\"}\n{\"uuid\":\"a\",\"data\":\"apitest\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		// Запись существующей строки обрабатывается по политике conflict
+		{Name: "import_conflict_invalid", Method: http.MethodPost, Path: "/octet/v1/import?conflict=merge",
+			Body:   "{\"uuid\":\"{uuid}\",\"data\":\"apitest\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		{Name: "import_conflict_skip", Method: http.MethodPost, Path: "/octet/v1/import?conflict=skip",
+			Body:   "{\"uuid\":\"{uuid}\",\"data\":\"apitest skipped\"}\n{\"uuid\":\"1b4e28ba-2fa1-41d2-883f-0016d3cca427\",\"data\":\"apitest\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		{Name: "import_conflict_fail", Method: http.MethodPost, Path: "/octet/v1/import?conflict=fail",
+			Body:   "{\"uuid\":\"{uuid}\",\"data\":\"apitest failed\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		{Name: "import_conflict_rename", Method: http.MethodPost, Path: "/octet/v1/import?conflict=rename",
+			Body:   "{\"uuid\":\"{uuid}\",\"data\":\"apitest renamed\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		// Значение строки не изменилось ни одним из импортов выше
+		{Name: "get_after_import_conflict", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "remove", Method: http.MethodDelete, Path: "/octet/v1/{uuid}"},
+		{Name: "get_not_found", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "exists_not_found", Method: http.MethodHead, Path: "/octet/v1/{uuid}"},
+		{Name: "meta_not_found", Method: http.MethodGet, Path: "/octet/v1/{uuid}/meta"},
+		{Name: "update_not_found", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: `{"data":"apitest"}`},
+		{Name: "remove_not_found", Method: http.MethodDelete, Path: "/octet/v1/{uuid}"},
+		{Name: "create", Method: http.MethodPut, Path: "/octet/v1/0f8fad5b-d9cb-469f-a165-70867728950e",
+			Body: `{"data":"apitest created"}`, Header: http.Header{"If-None-Match": {"*"}}},
+		{Name: "create_invalid_uuid", Method: http.MethodPut, Path: "/octet/v1/0F8FAD5B-D9CB-469F-A165-70867728950E",
+			Body: `{"data":"apitest"}`, Header: http.Header{"If-None-Match": {"*"}}},
+		{Name: "import_missing", Method: http.MethodPost, Path: "/octet/v1/import",
+			Body:   "{\"uuid\":\"7c9e6679-7425-40de-944b-e07fc1f90ae7\",\"data\":\"apitest restored\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		{Name: "get_imported", Method: http.MethodGet, Path: "/octet/v1/7c9e6679-7425-40de-944b-e07fc1f90ae7"},
+		// Запись с истекшим сроком хранения пропускается, остальные создаются с оставшимся сроком
+		{Name: "import_expires", Method: http.MethodPost, Path: "/octet/v1/import",
+			Body: "{\"uuid\":\"ffffffff-ffff-4fff-bfff-ffffffffffff\",\"data\":\"apitest expiring\",\"expires_at\":\"2100-01-01T00:00:00Z\"}\n" +
+				"{\"uuid\":\"ffffffff-ffff-4fff-bfff-fffffffffffe\",\"data\":\"apitest expired\",\"expires_at\":\"2000-01-01T00:00:00Z\"}\n",
+			Header: http.Header{"Content-Type": {"application/x-ndjson"}}},
+		// Курсор перед наибольшим UUID выгружает только строку, созданную импортом
+		{Name: "export_metadata", Method: http.MethodGet,
+			Path: "/octet/v1/ns/default/export?cursor=ffffffff-ffff-4fff-bfff-fffffffffffe&namespace=default&include_metadata=true"},
+		{Name: "insert_text", Method: http.MethodPost, Path: "/octet/v1/", Body: "apitest \"text\"\n",
+			Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}},
+		{Name: "get_text", Method: http.MethodGet, Path: "/octet/v1/{uuid}", Header: http.Header{"Accept": {"text/plain"}}},
+		{Name: "update_text", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: "apitest text updated",
+			Header: http.Header{"Content-Type": {"text/plain"}}},
+		{Name: "insert_text_invalid_utf8", Method: http.MethodPost, Path: "/octet/v1/", Body: "apitest \xff",
+			Header: http.Header{"Content-Type": {"text/plain"}}},
+		{Name: "batch_text", Method: http.MethodPost, Path: "/octet/v1/batch", Body: "apitest",
+			Header: http.Header{"Content-Type": {"text/plain"}}},
+		{Name: "insert_binary", Method: http.MethodPost, Path: "/octet/v1/", Body: "\x00\x01apitest\xfe\xff",
+			Header: http.Header{"Content-Type": {"application/octet-stream"}}},
+		{Name: "get_binary", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "update_binary", Method: http.MethodPut, Path: "/octet/v1/{uuid}", Body: "\x89PNG\r\n\x1a\n",
+			Header: http.Header{"Content-Type": {"image/png"}}},
+		{Name: "get_binary_updated", Method: http.MethodGet, Path: "/octet/v1/{uuid}", Header: http.Header{"Accept": {"application/json"}}},
+		{Name: "insert_reserved_prefix", Method: http.MethodPost, Path: "/octet/v1/",
+			Body: `{"data":"\u0000octet-binary\u0000text/html\u0000PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg=="}`},
+		{Name: "insert_reserved_prefix_text", Method: http.MethodPost, Path: "/octet/v1/",
+			Body:   "\x00octet-binary\x00text/html\x00PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==",
+			Header: http.Header{"Content-Type": {"text/plain"}}},
+		{Name: "batch_reserved_prefix", Method: http.MethodPost, Path: "/octet/v1/batch",
+			Body: `{"items":[{"op":"insert","data":"\u0000octet-binary\u0000text/html\u0000PHNjcmlwdD4="}]}`},
+
+		{Name: "insert_gzip", Method: http.MethodPost, Path: "/octet/v1/", Body: gzipBody(`{"data":"apitest gzip"}`),
+			Header: http.Header{"Content-Encoding": {"gzip"}}},
+		{Name: "get_gzip", Method: http.MethodGet, Path: "/octet/v1/{uuid}"},
+		{Name: "insert_gzip_too_large", Method: http.MethodPost, Path: "/octet/v1/",
+			Body: gzipBody(`{"data":"` + strings.Repeat("a", 1024) + `"}`), Header: http.Header{"Content-Encoding": {"gzip"}}},
+		{Name: "insert_unsupported_encoding", Method: http.MethodPost, Path: "/octet/v1/", Body: `{"data":"apitest"}`,
+			Header: http.Header{"Content-Encoding": {"compress"}}},
+
+		{Name: "admin_ui", Method: http.MethodGet, Path: "/admin/ui", Header: noAuth},
+		{Name: "admin_unauthorized", Method: http.MethodGet, Path: "/admin/v1/info", Header: noAuth},
+		{Name: "admin_info", Method: http.MethodGet, Path: "/admin/v1/info"},
+		{Name: "admin_status", Method: http.MethodGet, Path: "/admin/v1/status",
+			Ignore: []string{"uptime_seconds", "goroutines", "requests", "pool"}},
+		{Name: "admin_metrics", Method: http.MethodGet, Path: "/admin/v1/metrics",
+			Ignore: []string{"octet_backend_command_duration_seconds_bucket", "octet_backend_command_duration_seconds_sum",
+				"octet_backend_command_latency_seconds"}},
+		{Name: "admin_health", Method: http.MethodGet, Path: "/admin/v1/health"},
+		{Name: "admin_pool_discards", Method: http.MethodGet, Path: "/admin/v1/pool/discards"},
+		{Name: "admin_pool_discards_invalid_limit", Method: http.MethodGet, Path: "/admin/v1/pool/discards?limit=0"},
+		{Name: "admin_compact", Method: http.MethodPost, Path: "/admin/v1/compact",
+			Header: http.Header{"Content-Type": {"application/json"}}},
+		{Name: "admin_compact_status", Method: http.MethodGet, Path: "/admin/v1/compact"},
+		{Name: "admin_journal", Method: http.MethodGet, Path: "/admin/v1/journal?limit=3"},
+		{Name: "admin_journal_invalid_since", Method: http.MethodGet, Path: "/admin/v1/journal?since=yesterday"},
+		{Name: "admin_requests", Method: http.MethodGet, Path: "/admin/v1/requests?limit=2",
+			Ignore: []string{"time", "request_id", "latency_us", "uuid"}},
+		{Name: "admin_requests_invalid_since", Method: http.MethodGet, Path: "/admin/v1/requests?since=yesterday"},
+		{Name: "admin_apikeys_list", Method: http.MethodGet, Path: "/admin/v1/apikeys"},
+		{Name: "admin_apikeys_create", Method: http.MethodPost, Path: "/admin/v1/apikeys",
+			Body: `{"name":"apitest-reader","scopes":["read"]}`, Ignore: []string{"key"}},
+		{Name: "admin_apikeys_update", Method: http.MethodPut, Path: "/admin/v1/apikeys/apitest-reader",
+			Body: `{"scopes":["read","write"]}`},
+		{Name: "admin_apikeys_delete", Method: http.MethodDelete, Path: "/admin/v1/apikeys/apitest-reader"},
+		{Name: "admin_trace", Method: http.MethodGet, Path: "/admin/v1/trace?limit=2",
+			Ignore: []string{"request_id", "latency_us"}},
+		{Name: "admin_faults_get", Method: http.MethodGet, Path: "/admin/v1/faults"},
+		{Name: "admin_faults_set", Method: http.MethodPut, Path: "/admin/v1/faults",
+			Body: `{"latency_ms":0,"latency_rate":0,"drop_rate":0,"reset_rate":0}`},
+		{Name: "admin_faults_kill", Method: http.MethodPost, Path: "/admin/v1/faults/kill",
+			Header: http.Header{"Content-Type": {"application/json"}}},
+		{Name: "admin_events", Method: http.MethodGet, Path: "/admin/v1/events"},
+		{Name: "admin_events_replay", Method: http.MethodPost, Path: "/admin/v1/events/replay?from=2000-01-01T00:00:00Z",
+			Header: http.Header{"Content-Type": {"application/json"}}},
+		{Name: "admin_purge", Method: http.MethodPost, Path: "/admin/v1/purge",
+			Body:   `{"uuids":["{uuid}","0f8fad5b-d9cb-469f-a165-70867728950e","3f2504e0-4f89-41d3-9a0c-0305e82c3301"]}`,
+			Ignore: []string{"id", "started_at", "completed_at", "journal_size_before", "journal_size_after"}},
+		{Name: "admin_purge_invalid_uuid", Method: http.MethodPost, Path: "/admin/v1/purge", Body: `{"uuids":["apitest"]}`},
+	}
+}
+
+// Тело запроса, сжатое gzip
+func gzipBody(body string) string {
+	var out bytes.Buffer
+	writer := gzip.NewWriter(&out)
+	writer.Write([]byte(body))
+	writer.Close()
+	return out.String()
+}
+
+// Выполнение запросов и сравнение ответов с эталонными файлами в директории dir.
+// При UpdateEnv=1 эталонные файлы перезаписываются фактическими ответами.
+func RunGolden(t testing.TB, server *Server, dir string, cases []Case) {
+	t.Helper()
+	update := os.Getenv(UpdateEnv) == "1"
+
+	var lastUuid, lastETag string
+	for _, c := range cases {
+		path := strings.ReplaceAll(c.Path, "{uuid}", lastUuid)
+		header := make(http.Header, len(c.Header))
+		for name, values := range c.Header {
+			for _, value := range values {
+				header.Add(name, strings.ReplaceAll(value, "{etag}", lastETag))
+			}
+		}
+		resp, err := server.Do(c.Method, path, strings.ReplaceAll(c.Body, "{uuid}", lastUuid), header)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+		body, err := readBody(resp)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+
+		if etag := resp.Header.Get("ETag"); len(etag) != 0 {
+			lastETag = etag
+		}
+		var parsed struct {
+			Uuid string `json:"uuid"`
+		}
+		if json.Unmarshal(body, &parsed) == nil && len(parsed.Uuid) != 0 {
+			lastUuid = parsed.Uuid
+		}
+
+		actual := formatExchange(c, resp, body)
+		goldenPath := filepath.Join(dir, c.Name+".golden")
+		if update {
+			if err := os.WriteFile(goldenPath, []byte(actual), 0644); err != nil {
+				t.Fatalf("%s: %v", c.Name, err)
+			}
+			continue
+		}
+		expected, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("%s: эталонный файл не найден (запустите с %s=1): %v", c.Name, UpdateEnv, err)
+			continue
+		}
+		if string(expected) != actual {
+			t.Errorf("%s: ответ отличается от %s\n--- ожидалось:\n%s\n--- получено:\n%s",
+				c.Name, goldenPath, expected, actual)
+		}
+	}
+}
+
+// Чтение тела ответа. Сжатое тело распаковывается: клиент не распаковывает его сам,
+// так как Accept-Encoding передается только в запросах, где он указан явно.
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return io.ReadAll(resp.Body)
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// Текстовое представление запроса и ответа для эталонного файла
+func formatExchange(c Case, resp *http.Response, body []byte) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s %s\n", c.Method, c.Path)
+	if len(c.Body) != 0 {
+		fmt.Fprintf(&out, "%s\n", c.Body)
+	}
+	fmt.Fprintf(&out, "\n%s\n", resp.Status)
+	for _, name := range goldenHeaders {
+		if value := resp.Header.Get(name); len(value) != 0 {
+			fmt.Fprintf(&out, "%s: %s\n", name, uuidPattern.ReplaceAllString(value, "<uuid>"))
+		}
+	}
+	if len(body) != 0 {
+		fmt.Fprintf(&out, "\n%s\n", normalizeBody(body, c.Ignore))
+	}
+	return out.String()
+}
+
+// Приведение тела ответа к стабильному виду: форматирование JSON,
+// замена UUID, времени и игнорируемых полей
+func normalizeBody(body []byte, ignore []string) string {
+	var value any
+	if err := json.Unmarshal(body, &value); err == nil {
+		value = maskFields(value, ignore)
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(value)
+		body = bytes.TrimRight(buf.Bytes(), "\n")
+	} else if len(ignore) != 0 {
+		body = maskMetrics(body, ignore)
+	} else if len(body) > 512 {
+		// Большие не-JSON ответы (например, HTML) сравниваются только по размеру
+		return fmt.Sprintf("<%d байт>", len(body))
+	}
+	text := uuidPattern.ReplaceAllString(string(body), "<uuid>")
+	return timePattern.ReplaceAllString(text, "<time>")
+}
+
+// Замена значений игнорируемых метрик в текстовом формате Prometheus
+func maskMetrics(body []byte, ignore []string) []byte {
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		name, _, _ := strings.Cut(line, "{")
+		name, _, _ = strings.Cut(name, " ")
+		if slices.Contains(ignore, name) {
+			if index := strings.LastIndexByte(line, ' '); index >= 0 {
+				lines[i] = line[:index] + " <ignored>"
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Замена значений игнорируемых полей
+func maskFields(value any, ignore []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if slices.Contains(ignore, key) {
+				v[key] = "<ignored>"
+			} else {
+				v[key] = maskFields(field, ignore)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = maskFields(item, ignore)
+		}
+	}
+	return value
+}
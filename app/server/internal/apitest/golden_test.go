@@ -0,0 +1,33 @@
+package apitest_test
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/lildannita/octet-server/internal/apitest"
+	"github.com/lildannita/octet-server/internal/protocoltest"
+)
+
+// Перезапись эталонных файлов фактическими ответами (go test ./internal/apitest/ -update)
+var update = flag.Bool("update", false, "перезаписать эталонные файлы фактическими ответами")
+
+func TestGolden(t *testing.T) {
+	if *update {
+		t.Setenv(apitest.UpdateEnv, "1")
+	}
+
+	backend, err := protocoltest.NewFakeBackend(filepath.Join(t.TempDir(), "octet.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+
+	server, err := apitest.NewServer(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	apitest.RunGolden(t, server, "testdata", apitest.Cases())
+}
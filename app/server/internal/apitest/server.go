@@ -0,0 +1,212 @@
+// Пакет apitest содержит тестовый HTTP-сервер со всеми маршрутами API
+// и проверку ответов по эталонным (golden) файлам.
+package apitest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/api"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// API-ключ тестового сервера со всеми областями доступа
+const AdminKey = "apitest-admin-key"
+
+// API-ключ тестового сервера, которому доступно только чтение строк пространства имен tenant
+const TenantKey = "apitest-tenant-key"
+
+// Хранилище, с которым работает тестовый сервер
+// (например, protocoltest.FakeBackend или запущенный octet)
+type Store interface {
+	SocketPath() string
+}
+
+// Тестовый HTTP-сервер со всеми маршрутами API, включая необязательные
+// (API-ключи, трассировка, внедрение сбоев, события, журнал запросов)
+type Server struct {
+	*httptest.Server
+	Pool     *service.ClientPool
+	KeyStore *auth.KeyStore
+	Recorder *service.TrafficRecorder
+	Faults   *service.FaultInjector
+	Outbox   *events.Outbox
+	Journal  *api.RequestJournal
+
+	eventsDir string
+}
+
+// Создание и запуск тестового сервера поверх хранилища
+func NewServer(store Store) (*Server, error) {
+	logger := zap.NewNop()
+	pm := service.NewExternalProcessManager()
+	recorder, err := service.NewTrafficRecorder(100, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := service.NewClientPool(service.ClientPoolConfig{
+		SocketPath:        store.SocketPath(),
+		MaxClients:        2,
+		ClientTimeout:     5 * time.Second,
+		HeartbeatInterval: -1,
+		Recorder:          recorder,
+	}, logger, pm)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать пул клиентов: %w", err)
+	}
+	keyStore, err := auth.NewKeyStore(auth.KeysFromConfig([]config.APIKeyConfig{{
+		Name:   "apitest",
+		Key:    AdminKey,
+		Scopes: []string{string(auth.ScopeRead), string(auth.ScopeWrite), string(auth.ScopeAdmin)},
+	}, {
+		Name:       "apitest-tenant",
+		Key:        TenantKey,
+		Scopes:     []string{string(auth.ScopeRead)},
+		Namespaces: []string{"tenant"},
+	}}))
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	faults, err := service.NewFaultInjector(service.FaultSettings{}, pm)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	eventsDir, err := os.MkdirTemp("", "octet-apitest-events-")
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	outbox, err := events.OpenOutbox(eventsDir)
+	if err != nil {
+		pool.Close()
+		os.RemoveAll(eventsDir)
+		return nil, err
+	}
+
+	// Оценка состояния не запускается, поэтому ответ не зависит от длительности команд
+	health, err := service.NewHealthMonitor(pool, pm, service.HealthPolicy{
+		Interval:     time.Minute,
+		Window:       time.Minute,
+		MaxErrorRate: 0.5,
+	}, logger)
+	if err != nil {
+		pool.Close()
+		os.RemoveAll(eventsDir)
+		return nil, err
+	}
+
+	requestJournal, err := api.OpenRequestJournal(filepath.Join(eventsDir, "requests.journal"), 100)
+	if err != nil {
+		pool.Close()
+		outbox.Close()
+		os.RemoveAll(eventsDir)
+		return nil, err
+	}
+
+	purger, err := service.NewPurger(pool, recorder, service.PurgeConfig{
+		SigningKey:     "apitest-purge-signing-key-0123456789",
+		MaxUuids:       3,
+		CompactTimeout: 5 * time.Second,
+	}, logger)
+	if err != nil {
+		pool.Close()
+		outbox.Close()
+		requestJournal.Close()
+		os.RemoveAll(eventsDir)
+		return nil, err
+	}
+
+	router, err := api.NewRouter(api.RouterConfig{
+		ClientPool:    pool,
+		Logger:        logger,
+		JSON:          config.JSONConfig{EscapeHTML: true, AllowPretty: true},
+		KeyStore:      keyStore,
+		Cache:         config.CacheConfig{CacheControl: "no-cache", ETag: true, LastModified: true},
+		Recorder:      recorder,
+		Faults:        faults,
+		Info:          api.ServerInfo{Server: api.ServerBuildInfo{Version: "apitest"}},
+		Outbox:        outbox,
+		RequestLimits: config.RequestLimitsConfig{MaxBodyBytes: 256},
+		TimeTravel:    config.TimeTravelConfig{Enabled: true},
+		Batch:         config.BatchConfig{MaxItems: 3, ChunkSize: 2},
+		SoftLimits:    config.SoftLimitsConfig{Enabled: true, Threshold: 0.8},
+		Namespaces:    config.NamespacesConfig{Default: "default", LegacyRoutes: config.LegacyRoutesRewrite},
+		Idempotency:   config.IdempotencyConfig{Enabled: true, TTLSeconds: 3600, MaxEntries: 100},
+		APIVersion:    config.APIVersionConfig{Default: 1},
+		Health:        health,
+		Drain:         config.DrainConfig{TimeoutSeconds: 5},
+		Binary:        config.BinaryConfig{ContentTypes: []string{"application/octet-stream", "image/*"}},
+		Compression:   config.CompressionConfig{Enabled: true, Encodings: []string{"gzip"}, DecompressRequests: true},
+
+		RequestJournal: requestJournal,
+		Purger:         purger,
+	}, api.WithAuth(keyStore))
+	if err != nil {
+		pool.Close()
+		outbox.Close()
+		requestJournal.Close()
+		os.RemoveAll(eventsDir)
+		return nil, err
+	}
+
+	// Клиент не запрашивает сжатие сам: ответы сжимаются только для запросов с явным Accept-Encoding
+	httpServer := httptest.NewServer(router)
+	httpServer.Client().Transport.(*http.Transport).DisableCompression = true
+
+	return &Server{
+		Server:    httpServer,
+		Pool:      pool,
+		KeyStore:  keyStore,
+		Recorder:  recorder,
+		Faults:    faults,
+		Outbox:    outbox,
+		Journal:   requestJournal,
+		eventsDir: eventsDir,
+	}, nil
+}
+
+// Выполнение запроса к серверу.
+// Если заголовок аутентификации не указан, используется AdminKey.
+func (s *Server) Do(method, path, body string, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if len(body) != 0 {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if len(body) != 0 && len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if _, ok := header["X-Api-Key"]; !ok && len(req.Header.Get("Authorization")) == 0 {
+		req.Header.Set("X-API-Key", AdminKey)
+	}
+	return s.Client().Do(req)
+}
+
+// Остановка сервера и освобождение ресурсов
+func (s *Server) Close() {
+	s.Server.Close()
+	s.Pool.Close()
+	s.Recorder.Close()
+	s.Outbox.Close()
+	s.Journal.Close()
+	os.RemoveAll(s.eventsDir)
+}
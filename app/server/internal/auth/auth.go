@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Scope определяет набор операций, разрешенных ключу
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // Чтение данных
+	ScopeWrite Scope = "write" // Добавление, обновление и удаление данных
+	ScopeAdmin Scope = "admin" // Административные операции
+)
+
+// Ошибки аутентификации
+var (
+	ErrNoCredentials      = errors.New("учетные данные не указаны")
+	ErrInvalidCredentials = errors.New("неверные учетные данные")
+)
+
+// Ошибки управления ключами
+var (
+	ErrKeyExists   = errors.New("API-ключ с таким именем уже существует")
+	ErrKeyNotFound = errors.New("API-ключ не найден")
+	ErrStaticKey   = errors.New("API-ключ задан в файле конфигурации и не может быть изменен")
+	ErrInvalidKey  = errors.New("некорректное описание API-ключа")
+)
+
+// Identity описывает аутентифицированного клиента
+type Identity struct {
+	Name       string   // Имя ключа (или субъекта)
+	Scopes     []Scope  // Разрешенные области доступа
+	Namespaces []string // Доступные пространства имен (пусто - все пространства имен)
+}
+
+// Проверка, разрешена ли клиенту указанная область доступа
+func (i *Identity) HasScope(scope Scope) bool {
+	return slices.Contains(i.Scopes, scope) || slices.Contains(i.Scopes, ScopeAdmin)
+}
+
+// Проверка, доступны ли клиенту строки пространства имен
+func (i *Identity) HasNamespace(namespace string) bool {
+	return len(i.Namespaces) == 0 || slices.Contains(i.Namespaces, namespace)
+}
+
+// Authenticator определяет клиента по HTTP-запросу
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// Описание API-ключа
+type APIKey struct {
+	Name       string    `json:"name"`                 // Имя ключа (для логов)
+	Hash       string    `json:"hash"`                 // SHA-256 хэш ключа в шестнадцатеричном виде
+	Scopes     []Scope   `json:"scopes"`               // Разрешенные области доступа
+	Namespaces []string  `json:"namespaces,omitempty"` // Доступные пространства имен (пусто - все)
+	CreatedAt  time.Time `json:"created_at"`           // Время выпуска ключа
+	Static     bool      `json:"-"`                    // Ключ задан в файле конфигурации
+}
+
+// Хранилище API-ключей.
+// Ключи из конфигурации доступны только для чтения, ключи, выпущенные во время работы,
+// сохраняются (в виде хэшей) в файл и восстанавливаются при перезапуске.
+type KeyStore struct {
+	mutex sync.RWMutex
+	keys  map[string]APIKey // Ключи, проиндексированные по хэшу
+	path  string            // Файл для хранения выпущенных ключей (пусто - без сохранения)
+}
+
+// Создание хранилища API-ключей
+func NewKeyStore(keys []APIKey) (*KeyStore, error) {
+	store := &KeyStore{keys: make(map[string]APIKey, len(keys))}
+	for _, key := range keys {
+		key.Static = true
+		if err := store.add(key); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Добавление ключа с проверкой уникальности имени и значения
+func (s *KeyStore) add(key APIKey) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if _, exists := s.keys[key.Hash]; exists {
+		return fmt.Errorf("API-ключ '%s' указан повторно", key.Name)
+	}
+	if _, exists := s.findByName(key.Name); exists {
+		return fmt.Errorf("%w: %s", ErrKeyExists, key.Name)
+	}
+	s.keys[key.Hash] = key
+	return nil
+}
+
+// Поиск ключа по имени (вызывается под блокировкой)
+func (s *KeyStore) findByName(name string) (APIKey, bool) {
+	for _, key := range s.keys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// Загрузка выпущенных ранее ключей из файла и включение сохранения новых ключей в него
+func (s *KeyStore) Open(path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("не удалось прочитать файл API-ключей: %w", err)
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("не удалось разобрать файл API-ключей: %w", err)
+	}
+	for _, key := range keys {
+		if err := s.add(key); err != nil {
+			return fmt.Errorf("некорректный API-ключ в файле %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Сохранение выпущенных ключей в файл (вызывается под блокировкой)
+func (s *KeyStore) save() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if !key.Static {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return strings.Compare(a.Name, b.Name) })
+
+	data, err := json.MarshalIndent(keys, "", "    ")
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать API-ключи: %w", err)
+	}
+
+	// Записываем во временный файл и атомарно заменяем им основной
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("не удалось создать директорию для файла API-ключей: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("не удалось записать файл API-ключей: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("не удалось заменить файл API-ключей: %w", err)
+	}
+	return nil
+}
+
+// Список всех ключей, упорядоченный по имени
+func (s *KeyStore) List() []APIKey {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return strings.Compare(a.Name, b.Name) })
+	return keys
+}
+
+// Выпуск нового ключа. Значение ключа возвращается только один раз,
+// в хранилище остается лишь его хэш.
+func (s *KeyStore) Create(name string, scopes []Scope, namespaces []string) (string, APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, fmt.Errorf("не удалось сгенерировать API-ключ: %w", err)
+	}
+	value := "octet_" + hex.EncodeToString(secret)
+
+	key := APIKey{
+		Name:       name,
+		Hash:       HashKey(value),
+		Scopes:     scopes,
+		Namespaces: namespaces,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.add(key); err != nil {
+		return "", APIKey{}, err
+	}
+	if err := s.save(); err != nil {
+		delete(s.keys, key.Hash)
+		return "", APIKey{}, err
+	}
+	return value, key, nil
+}
+
+// Изменение областей доступа и пространств имен выпущенного ключа
+func (s *KeyStore) Update(name string, scopes []Scope, namespaces []string) (APIKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.findByName(name)
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	if key.Static {
+		return APIKey{}, ErrStaticKey
+	}
+
+	updated := key
+	updated.Scopes = scopes
+	updated.Namespaces = namespaces
+	if err := validateKey(updated); err != nil {
+		return APIKey{}, err
+	}
+
+	s.keys[key.Hash] = updated
+	if err := s.save(); err != nil {
+		s.keys[key.Hash] = key
+		return APIKey{}, err
+	}
+	return updated, nil
+}
+
+// Отзыв выпущенного ключа
+func (s *KeyStore) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.findByName(name)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.Static {
+		return ErrStaticKey
+	}
+
+	delete(s.keys, key.Hash)
+	if err := s.save(); err != nil {
+		s.keys[key.Hash] = key
+		return err
+	}
+	return nil
+}
+
+// Преобразование описаний ключей из конфигурации
+func KeysFromConfig(keys []config.APIKeyConfig) []APIKey {
+	result := make([]APIKey, 0, len(keys))
+	for _, key := range keys {
+		hash := strings.ToLower(key.KeyHash)
+		if len(key.Key) != 0 {
+			hash = HashKey(key.Key)
+		}
+		scopes := make([]Scope, 0, len(key.Scopes))
+		for _, scope := range key.Scopes {
+			scopes = append(scopes, Scope(scope))
+		}
+		result = append(result, APIKey{Name: key.Name, Hash: hash, Scopes: scopes, Namespaces: key.Namespaces})
+	}
+	return result
+}
+
+// Проверка описания ключа
+func validateKey(key APIKey) error {
+	if len(key.Name) == 0 {
+		return fmt.Errorf("%w: не указано имя", ErrInvalidKey)
+	}
+	if len(key.Hash) != sha256.Size*2 {
+		return fmt.Errorf("%w: некорректный хэш API-ключа '%s'", ErrInvalidKey, key.Name)
+	}
+	for _, scope := range key.Scopes {
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+		default:
+			return fmt.Errorf("%w: неизвестная область доступа '%s' у API-ключа '%s'", ErrInvalidKey, scope, key.Name)
+		}
+	}
+	// Административные операции затрагивают все пространства имен
+	if len(key.Namespaces) != 0 && slices.Contains(key.Scopes, ScopeAdmin) {
+		return fmt.Errorf("%w: API-ключ '%s', ограниченный пространствами имен, не может иметь область доступа '%s'",
+			ErrInvalidKey, key.Name, ScopeAdmin)
+	}
+	return nil
+}
+
+// Вычисление хэша ключа, под которым он хранится
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Поиск ключа по его значению
+func (s *KeyStore) Lookup(key string) (APIKey, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	apiKey, ok := s.keys[HashKey(key)]
+	return apiKey, ok
+}
+
+// Аутентификация по API-ключу из заголовка X-API-Key или Authorization: Bearer
+func (s *KeyStore) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get("X-API-Key")
+	if len(key) == 0 {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if len(key) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	apiKey, ok := s.Lookup(key)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Name: apiKey.Name, Scopes: apiKey.Scopes, Namespaces: apiKey.Namespaces}, nil
+}
+
+type contextKey struct{}
+
+// Сохранение данных клиента в контексте запроса
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// Получение данных клиента из контекста запроса (nil, если аутентификация отключена)
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
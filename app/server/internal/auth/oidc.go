@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Допустимое расхождение часов при проверке времени действия токена
+const clockSkew = 30 * time.Second
+
+// Минимальный интервал между внеплановыми обновлениями набора ключей (при неизвестном kid)
+const jwksMinRefreshInterval = 30 * time.Second
+
+// Аутентификация по access-токенам OpenID Connect (JWT, подписанный ключом провайдера)
+type OIDCAuthenticator struct {
+	issuer     string
+	audience   string
+	rolesClaim []string           // Путь к claim с ролями (через точку для вложенных объектов)
+	roleScopes map[string][]Scope // Соответствие ролей областям доступа
+	client     *http.Client
+	jwksURI    string
+
+	mutex       sync.RWMutex
+	keys        map[string]crypto.PublicKey // Ключи провайдера по kid
+	lastRefresh time.Time
+}
+
+// Создание аутентификатора OIDC с получением параметров провайдера (discovery)
+func NewOIDCAuthenticator(ctx context.Context, cfg config.OIDCConfig) (*OIDCAuthenticator, error) {
+	roleScopes := make(map[string][]Scope, len(cfg.RoleScopes))
+	for role, scopes := range cfg.RoleScopes {
+		for _, scope := range scopes {
+			switch Scope(scope) {
+			case ScopeRead, ScopeWrite, ScopeAdmin:
+				roleScopes[role] = append(roleScopes[role], Scope(scope))
+			default:
+				return nil, fmt.Errorf("неизвестная область доступа '%s' для роли '%s'", scope, role)
+			}
+		}
+	}
+
+	a := &OIDCAuthenticator{
+		issuer:     strings.TrimSuffix(cfg.Issuer, "/"),
+		audience:   cfg.Audience,
+		rolesClaim: strings.Split(cfg.RolesClaim, "."),
+		roleScopes: roleScopes,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// Получаем адрес набора ключей из документа discovery
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := a.fetchJSON(ctx, a.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("не удалось получить параметры OIDC провайдера: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != a.issuer {
+		return nil, fmt.Errorf("издатель в параметрах OIDC провайдера (%s) не совпадает с настроенным (%s)",
+			discovery.Issuer, a.issuer)
+	}
+	if len(discovery.JWKSURI) == 0 {
+		return nil, errors.New("OIDC провайдер не указал адрес набора ключей (jwks_uri)")
+	}
+	a.jwksURI = discovery.JWKSURI
+
+	if err := a.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Аутентификация по токену из заголовка Authorization: Bearer
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(token) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	// Имя клиента - предпочтительное имя пользователя или идентификатор субъекта
+	name, _ := claims["preferred_username"].(string)
+	if len(name) == 0 {
+		name, _ = claims["sub"].(string)
+	}
+
+	// Преобразуем роли в области доступа
+	var scopes []Scope
+	for _, role := range a.roles(claims) {
+		for _, scope := range a.roleScopes[role] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return &Identity{Name: name, Scopes: scopes}, nil
+}
+
+// Проверка подписи и стандартных claims токена
+func (a *OIDCAuthenticator) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("токен не является JWT")
+	}
+
+	// Разбираем заголовок
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("некорректный заголовок токена: %w", err)
+	}
+
+	// Проверяем подпись
+	key, err := a.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("некорректная подпись токена: %w", err)
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	// Проверяем claims
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("некорректное содержимое токена: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != a.issuer {
+		return nil, fmt.Errorf("неверный издатель токена: %s", iss)
+	}
+	if !audienceContains(claims["aud"], a.audience) {
+		return nil, errors.New("токен выпущен не для этого сервиса")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("срок действия токена истек")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("токен еще не действителен")
+	}
+
+	return claims, nil
+}
+
+// Извлечение ролей из claims по настроенному пути
+func (a *OIDCAuthenticator) roles(claims map[string]any) []string {
+	var value any = claims
+	for _, field := range a.rolesClaim {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[field]
+	}
+
+	switch roles := value.(type) {
+	case string:
+		return strings.Fields(roles)
+	case []any:
+		result := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				result = append(result, name)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// Получение ключа провайдера по kid (с обновлением набора ключей при ротации)
+func (a *OIDCAuthenticator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	a.mutex.RLock()
+	key, ok := a.keys[kid]
+	canRefresh := time.Since(a.lastRefresh) >= jwksMinRefreshInterval
+	a.mutex.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	// Неизвестный kid может означать ротацию ключей у провайдера
+	if canRefresh {
+		if err := a.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+		a.mutex.RLock()
+		key, ok = a.keys[kid]
+		a.mutex.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный ключ подписи токена: %s", kid)
+}
+
+// Загрузка набора ключей провайдера (JWKS)
+func (a *OIDCAuthenticator) refreshKeys(ctx context.Context) error {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := a.fetchJSON(ctx, a.jwksURI, &jwks); err != nil {
+		return fmt.Errorf("не удалось получить набор ключей OIDC провайдера: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if len(jwk.Use) != 0 && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(jwk.N)
+			e, errE := decodeBigInt(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := decodeBigInt(jwk.X)
+			y, errY := decodeBigInt(jwk.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("набор ключей OIDC провайдера не содержит поддерживаемых ключей подписи")
+	}
+
+	a.mutex.Lock()
+	a.keys = keys
+	a.lastRefresh = time.Now()
+	a.mutex.Unlock()
+	return nil
+}
+
+// Получение JSON-документа провайдера
+func (a *OIDCAuthenticator) fetchJSON(ctx context.Context, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: неожиданный статус ответа %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// Проверка подписи JWT указанным алгоритмом
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("неподдерживаемый алгоритм подписи токена: %s", alg)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return errors.New("неверная подпись токена")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			break
+		}
+		// Подпись ECDSA в JWT - это конкатенация r и s фиксированной длины
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("неверная подпись токена")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("неверная подпись токена")
+		}
+		return nil
+	}
+	return fmt.Errorf("алгоритм подписи %s не соответствует типу ключа", alg)
+}
+
+// Проверка, что aud (строка или массив) содержит ожидаемую аудиторию
+func audienceContains(aud any, audience string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == audience
+	case []any:
+		return slices.ContainsFunc(value, func(item any) bool { return item == audience })
+	default:
+		return false
+	}
+}
+
+// Декодирование сегмента JWT
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// Декодирование числа из base64url (параметры ключей JWK)
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -1,25 +1,1270 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 // Экспортируемая переменная, которую можно задать при компиляции
 var OctetPath string
 
+// Префикс адреса HTTP сервера, обозначающий UNIX domain socket
+const UnixAddrPrefix = "unix:"
+
+// Допустимое имя пространства имен
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Список адресов, который в JSON может быть задан как одной строкой, так и массивом строк
+type AddrList []string
+
+// Разбор списка адресов из строки или массива строк
+func (a *AddrList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = AddrList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("адрес должен быть строкой или массивом строк: %w", err)
+	}
+	*a = list
+	return nil
+}
+
 // Config содержит все конфигурационные параметры приложения
 type Config struct {
 	StorageDir string `json:"storage_dir"` // Путь к директории хранилища данных
 	SocketPath string `json:"socket_path"` // Путь к UNIX domain socket для связи с C++ процессом
-	OctetPath  string `json:"octet_path"`  // Путь к исполняемому файлу octet
-	HTTPAddr   string `json:"http_addr"`   // Адрес и порт для HTTP сервера
-	MaxClients int    `json:"max_clients"` // Максимальное количество клиентов
+	// Права доступа к сокету octet
+	SocketPermissions SocketPermissionsConfig `json:"socket_permissions"`
+	OctetPath         string                  `json:"octet_path"`  // Путь к исполняемому файлу octet
+	HTTPAddr          AddrList                `json:"http_addr"`   // Адреса для HTTP сервера (host:port или unix:/path/to.sock)
+	MaxClients        int                     `json:"max_clients"` // Максимальное количество клиентов
+	// Максимальный размер сообщения между сервером и octet в байтах (0 - значение по умолчанию).
+	// Передается octet при запуске и ограничивает как ответы, так и запросы.
+	MaxFrameSize int64 `json:"max_frame_size"`
+	// Интервал heartbeat на простаивающих соединениях с octet в секундах
+	// (0 - значение по умолчанию, отрицательное значение отключает heartbeat)
+	HeartbeatInterval int `json:"heartbeat_interval"`
+	// Максимальное количество одновременных проверок heartbeat при обходе пула
+	HeartbeatConcurrency int `json:"heartbeat_concurrency"`
+	// Доля интервала heartbeat, по которой случайно распределяются проверки соединений ([0, 1))
+	HeartbeatJitter float64 `json:"heartbeat_jitter"`
+	// Проверка контрольной суммы данных, сохраненных octet при insert/update
+	VerifyChecksum bool `json:"verify_checksum"`
+	// Параметры таймаута чтения, рассчитываемого по длительности последних команд
+	AdaptiveTimeout AdaptiveTimeoutConfig `json:"adaptive_timeout"`
+	// Параметры повторных попыток подключения к octet
+	DialRetry DialRetryConfig `json:"dial_retry"`
+	// Параметры сжатия HTTP ответов и распаковки тел запросов
+	Compression CompressionConfig `json:"compression"`
+	// Параметры кодирования JSON-ответов
+	JSON JSONConfig `json:"json"`
+	// Параметры аутентификации клиентов
+	Auth AuthConfig `json:"auth"`
+	// Параметры заголовков кэширования для GET запросов
+	Cache CacheConfig `json:"cache"`
+	// Параметры записи обмена сообщениями с octet
+	Trace TraceConfig `json:"trace"`
+	// Параметры внедрения сбоев (только для тестовых сред)
+	Faults FaultsConfig `json:"faults"`
+	// Параметры журнала намерений изменений
+	IntentLog IntentLogConfig `json:"intent_log"`
+	// Параметры доставки событий изменения данных
+	Events EventsConfig `json:"events"`
+	// Параметры пакетных запросов
+	Batch BatchConfig `json:"batch"`
+	// Параметры объединения одиночных изменений в пакеты
+	Coalesce CoalesceConfig `json:"coalesce"`
+	// Параметры оценки состояния octet и автоматического перезапуска
+	HealthPolicy HealthPolicyConfig `json:"health_policy"`
+	// Параметры журнала запросов
+	RequestJournal RequestJournalConfig `json:"request_journal"`
+	// Параметры предупреждений о приближении к ограничениям
+	SoftLimits SoftLimitsConfig `json:"soft_limits"`
+	// Параметры пространств имен
+	Namespaces NamespacesConfig `json:"namespaces"`
+	// Параметры версий формата ответов API
+	APIVersion APIVersionConfig `json:"api_version"`
+	// Параметры разделения пула соединений между классами запросов
+	PoolPartitions PoolPartitionsConfig `json:"pool_partitions"`
+	// Параметры асинхронного удаления
+	AsyncDelete AsyncDeleteConfig `json:"async_delete"`
+	// Параметры прогрева после запуска
+	Warmup WarmupConfig `json:"warmup"`
+	// Ограничения HTTP запросов
+	RequestLimits RequestLimitsConfig `json:"request_limits"`
+	// Параметры обновления octet через административный API
+	Upgrade UpgradeConfig `json:"upgrade"`
+	// Параметры чтения строк на момент времени
+	TimeTravel TimeTravelConfig `json:"time_travel"`
+	// Параметры учета и ограничения трафика клиентов
+	Bandwidth BandwidthConfig `json:"bandwidth"`
+	// Параметры вывода сервера из балансировки
+	Drain DrainConfig `json:"drain"`
+	// Перехватчики запросов и ответов маршрутов (применяются в порядке перечисления)
+	Interceptors []InterceptorConfig `json:"interceptors"`
+	// Параметры проверки значений перед добавлением и изменением
+	Validation ValidationConfig `json:"validation"`
+	// Параметры отслеживания файла сокета octet
+	SocketWatch SocketWatchConfig `json:"socket_watch"`
+	// Параметры завершения работы сервера
+	Shutdown ShutdownConfig `json:"shutdown"`
+	// Формат создаваемых сервером идентификаторов запросов
+	RequestID RequestIDConfig `json:"request_id"`
+	// Параметры обнаружения повторных изменяющих запросов
+	Dedup DedupConfig `json:"dedup"`
+	// Параметры сохранения результатов добавлений с ключом идемпотентности
+	Idempotency IdempotencyConfig `json:"idempotency"`
+	// Ограничение количества строк в хранилище
+	KeyLimit KeyLimitConfig `json:"key_limit"`
+	// Параметры работы под префиксом пути за обратным прокси
+	PathPrefix PathPrefixConfig `json:"path_prefix"`
+	// Параметры истории запусков и завершений процесса octet
+	ProcessHistory ProcessHistoryConfig `json:"process_history"`
+	// Параметры проверки работоспособности (/health)
+	HealthCheck HealthCheckConfig `json:"health_check"`
+	// Параметры упорядоченной записи
+	OrderedWrites OrderedWritesConfig `json:"ordered_writes"`
+	// Параметры удаления строк по политикам хранения
+	Retention RetentionConfig `json:"retention"`
+	// Параметры хранения двоичных значений
+	Binary BinaryConfig `json:"binary"`
+	// Параметры окончательного удаления строк через административный API
+	Purge PurgeConfig `json:"purge"`
+	// Хранилище метаданных сервера, переживающих перезапуск
+	MetadataStore MetadataStoreConfig `json:"metadata_store"`
+
+	// Файл, из которого загружена конфигурация (пусто - конфигурация по умолчанию)
+	File string `json:"-"`
+	// SHA-256 содержимого файла конфигурации на момент загрузки
+	FileHash string `json:"-"`
+}
+
+// Значение, которым заменяются секреты в Redacted
+const RedactedValue = "[redacted]"
+
+// Имена параметров перехватчиков, значения которых считаются секретами
+var secretParamPattern = regexp.MustCompile(`(?i)secret|token|password|passwd|key|credential`)
+
+// Копия конфигурации без секретов: значений API-ключей и их хэшей, ключей подписи событий,
+// паролей в адресах подписчиков и параметров перехватчиков с именами, похожими на секреты
+func (c *Config) Redacted() Config {
+	redacted := *c
+
+	redacted.Auth.APIKeys = slices.Clone(c.Auth.APIKeys)
+	for i := range redacted.Auth.APIKeys {
+		key := &redacted.Auth.APIKeys[i]
+		if len(key.Key) != 0 {
+			key.Key = RedactedValue
+		}
+		if len(key.KeyHash) != 0 {
+			key.KeyHash = RedactedValue
+		}
+	}
+
+	redacted.Events.Webhooks = slices.Clone(c.Events.Webhooks)
+	for i := range redacted.Events.Webhooks {
+		webhook := &redacted.Events.Webhooks[i]
+		if len(webhook.Secret) != 0 {
+			webhook.Secret = RedactedValue
+		}
+		if parsed, err := url.Parse(webhook.URL); err == nil {
+			webhook.URL = parsed.Redacted()
+		}
+	}
+
+	redacted.Interceptors = slices.Clone(c.Interceptors)
+	for i := range redacted.Interceptors {
+		interceptor := &redacted.Interceptors[i]
+		if interceptor.Params == nil {
+			continue
+		}
+		params := make(map[string]string, len(interceptor.Params))
+		for name, value := range interceptor.Params {
+			if secretParamPattern.MatchString(name) {
+				value = RedactedValue
+			}
+			params[name] = value
+		}
+		interceptor.Params = params
+	}
+
+	if len(redacted.Purge.SigningKey) != 0 {
+		redacted.Purge.SigningKey = RedactedValue
+	}
+	if len(redacted.MetadataStore.Redis.Password) != 0 {
+		redacted.MetadataStore.Redis.Password = RedactedValue
+	}
+	return redacted
+}
+
+// SHA-256 содержимого файла в шестнадцатеричном виде
+func FileSHA256(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Проверка работоспособности (/health): одновременные запросы объединяются в одну проверку
+// подключения к octet, результат которой используется повторно в течение max_stale_ms
+type HealthCheckConfig struct {
+	MaxStaleMs int `json:"max_stale_ms"` // Максимальный возраст результата проверки (0 - без повторного использования)
+}
+
+// Проверка параметров проверки работоспособности
+func (c *HealthCheckConfig) validate() error {
+	if c.MaxStaleMs < 0 {
+		return fmt.Errorf("максимальный возраст результата проверки работоспособности не может быть отрицательным")
+	}
+	return nil
+}
+
+// Упорядоченная запись: изменения строк перечисленных пространств имен выполняются по одному
+// через общую очередь и получают возрастающие номера, задающие полный порядок изменений
+type OrderedWritesConfig struct {
+	Namespaces   []string `json:"namespaces"`    // Пространства имен с упорядоченной записью (пусто - отключена)
+	SequenceFile string   `json:"sequence_file"` // Файл последнего выданного номера
+	Sync         bool     `json:"sync"`          // Сброс номера на диск до выполнения изменения
+}
+
+// Включена ли упорядоченная запись для пространства имен
+func (c *OrderedWritesConfig) Covers(namespace string) bool {
+	return slices.Contains(c.Namespaces, namespace)
+}
+
+// Проверка параметров упорядоченной записи
+func (c *OrderedWritesConfig) validate(namespaces NamespacesConfig) error {
+	if len(c.Namespaces) == 0 {
+		return nil
+	}
+	for _, namespace := range c.Namespaces {
+		// Хранилище octet пока содержит одно пространство имен
+		if namespace != namespaces.Default {
+			return fmt.Errorf("пространство имен с упорядоченной записью '%s' не найдено", namespace)
+		}
+	}
+	if len(c.SequenceFile) == 0 {
+		return fmt.Errorf("не указан файл номеров упорядоченной записи")
+	}
+	return nil
+}
+
+// Окончательное удаление строк (POST /admin/v1/purge) с подписанным отчетом для подтверждения удаления
+type PurgeConfig struct {
+	Enabled               bool   `json:"enabled"`                 // Доступно ли окончательное удаление
+	SigningKey            string `json:"signing_key"`             // Ключ HMAC-SHA256 для подписи отчетов
+	MaxUuids              int    `json:"max_uuids"`               // Максимальное количество строк в одном запросе
+	CompactTimeoutSeconds int    `json:"compact_timeout_seconds"` // Максимальное ожидание сжатия хранилища
+}
+
+// Проверка параметров окончательного удаления
+func (c *PurgeConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.SigningKey) < 32 {
+		return fmt.Errorf("ключ подписи отчетов об окончательном удалении должен содержать не менее 32 символов")
+	}
+	if c.MaxUuids <= 0 {
+		return fmt.Errorf("максимальное количество строк окончательного удаления должно быть положительным")
+	}
+	if c.CompactTimeoutSeconds <= 0 {
+		return fmt.Errorf("время ожидания сжатия хранилища при окончательном удалении должно быть положительным")
+	}
+	return nil
+}
+
+// Хранилища метаданных сервера
+const (
+	MetadataStoreMemory = "memory" // Только в памяти, метаданные теряются при перезапуске
+	MetadataStoreBolt   = "bbolt"  // Локальный файл bbolt
+	MetadataStoreRedis  = "redis"  // Redis (общий для нескольких серверов)
+)
+
+// Хранилище метаданных сервера (сохраненные ответы на повторные запросы и результаты добавлений
+// с Idempotency-Key), которые должны переживать перезапуск сервера
+type MetadataStoreConfig struct {
+	Backend string                   `json:"backend"` // memory, bbolt или redis
+	File    string                   `json:"file"`    // Файл хранилища bbolt
+	Redis   RedisMetadataStoreConfig `json:"redis"`
+}
+
+// Параметры подключения к Redis для хранилища метаданных
+type RedisMetadataStoreConfig struct {
+	Address   string `json:"address"`    // Адрес Redis (host:port)
+	Password  string `json:"password"`   // Пароль (пусто - без аутентификации)
+	DB        int    `json:"db"`         // Номер базы
+	Prefix    string `json:"prefix"`     // Префикс ключей
+	TimeoutMs int    `json:"timeout_ms"` // Таймаут подключения и команд
+}
+
+// Проверка параметров хранилища метаданных
+func (c *MetadataStoreConfig) validate() error {
+	switch c.Backend {
+	case MetadataStoreMemory:
+		return nil
+	case MetadataStoreBolt:
+		if len(c.File) == 0 {
+			return fmt.Errorf("не указан файл хранилища метаданных")
+		}
+		return nil
+	case MetadataStoreRedis:
+		if len(c.Redis.Address) == 0 {
+			return fmt.Errorf("не указан адрес Redis для хранилища метаданных")
+		}
+		if c.Redis.DB < 0 {
+			return fmt.Errorf("номер базы Redis не может быть отрицательным")
+		}
+		if c.Redis.TimeoutMs <= 0 {
+			return fmt.Errorf("таймаут Redis должен быть положительным")
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестное хранилище метаданных: %s", c.Backend)
+	}
+}
+
+// Двоичные значения: тело POST и PUT с одним из перечисленных типов содержимого сохраняется как есть
+// (в octet - в base64 вместе с типом), а GET возвращает его с исходным Content-Type
+type BinaryConfig struct {
+	// Принимаемые типы содержимого, допускается "type/*" (пусто - двоичные значения не принимаются)
+	ContentTypes []string `json:"content_types"`
+}
+
+// Проверка типов содержимого двоичных значений
+func (c *BinaryConfig) validate() error {
+	for _, contentType := range c.ContentTypes {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != contentType {
+			return fmt.Errorf("некорректный тип содержимого двоичных значений '%s'", contentType)
+		}
+		if mediaType == "*/*" || mediaType == "application/json" || strings.HasPrefix(mediaType, "text/") {
+			return fmt.Errorf("тип содержимого '%s' не может использоваться для двоичных значений", contentType)
+		}
+	}
+	return nil
+}
+
+// Признаки, от которых отсчитывается возраст строки в политиках хранения
+const (
+	RetentionBasedOnCreated  = "created"  // От добавления строки
+	RetentionBasedOnModified = "modified" // От последнего изменения строки
+)
+
+// Политика хранения: строки пространства имен старше max_age_days удаляются
+type RetentionPolicyConfig struct {
+	Namespace  string `json:"namespace"`    // Пространство имен
+	MaxAgeDays int    `json:"max_age_days"` // Срок хранения строки в днях
+	BasedOn    string `json:"based_on"`     // Признак возраста строки: created или modified (по умолчанию - created)
+}
+
+// Удаление строк старше срока хранения по политикам пространств имен (требования к хранению данных).
+// В режиме dry_run строки не удаляются, а отчет проверки показывает, что было бы удалено.
+type RetentionConfig struct {
+	Enabled         bool                    `json:"enabled"`          // Выполнять ли проверку по расписанию (иначе - только через административный API)
+	IntervalMinutes int                     `json:"interval_minutes"` // Интервал проверки строк
+	DryRun          bool                    `json:"dry_run"`          // Только сообщать о строках старше срока хранения
+	Policies        []RetentionPolicyConfig `json:"policies"`         // Политики хранения пространств имен
+}
+
+// Проверка параметров удаления строк по политикам хранения
+func (c *RetentionConfig) validate(namespaces NamespacesConfig) error {
+	if c.Enabled {
+		if c.IntervalMinutes <= 0 {
+			return fmt.Errorf("интервал проверки строк по политикам хранения должен быть положительным")
+		}
+		if len(c.Policies) == 0 {
+			return fmt.Errorf("не указаны политики хранения строк")
+		}
+	}
+	// Политики проверяются и при выключенной проверке по расписанию: их можно применить через административный API
+	seen := make(map[string]bool, len(c.Policies))
+	for i := range c.Policies {
+		policy := &c.Policies[i]
+		// Хранилище octet пока содержит одно пространство имен
+		if policy.Namespace != namespaces.Default {
+			return fmt.Errorf("пространство имен политики хранения '%s' не найдено", policy.Namespace)
+		}
+		if seen[policy.Namespace] {
+			return fmt.Errorf("политика хранения пространства имен '%s' указана повторно", policy.Namespace)
+		}
+		seen[policy.Namespace] = true
+		if policy.MaxAgeDays <= 0 {
+			return fmt.Errorf("срок хранения строк пространства имен '%s' должен быть положительным", policy.Namespace)
+		}
+		switch policy.BasedOn {
+		case "":
+			policy.BasedOn = RetentionBasedOnCreated
+		case RetentionBasedOnCreated, RetentionBasedOnModified:
+		default:
+			return fmt.Errorf("неизвестный признак возраста строки '%s' (допустимо: %s, %s)",
+				policy.BasedOn, RetentionBasedOnCreated, RetentionBasedOnModified)
+		}
+	}
+	return nil
+}
+
+// История запусков и завершений процесса octet для разбора инцидентов
+type ProcessHistoryConfig struct {
+	Enabled    bool   `json:"enabled"`     // Включена ли запись
+	File       string `json:"file"`        // Файл истории
+	MaxRecords int    `json:"max_records"` // Количество хранимых событий
+}
+
+// Проверка параметров истории процесса
+func (c *ProcessHistoryConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.File) == 0 {
+		return fmt.Errorf("не указан файл истории процесса")
+	}
+	if c.MaxRecords <= 0 {
+		return fmt.Errorf("количество событий истории процесса должно быть положительным")
+	}
+	return nil
+}
+
+// Параметры работы под префиксом пути (например, /api/storage) за общим обратным прокси.
+// Ссылки в ответах (Location, адрес документации OpenAPI) строятся с учетом префикса.
+type PathPrefixConfig struct {
+	// Префикс пути всех маршрутов сервера (пусто - маршруты от корня)
+	Prefix string `json:"prefix"`
+	// Учитывать заголовок X-Forwarded-Prefix от прокси, удаляющего префикс из пути
+	TrustForwarded bool `json:"trust_forwarded"`
+}
+
+// Проверка префикса пути
+func (c *PathPrefixConfig) validate() error {
+	if len(c.Prefix) == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(c.Prefix, "/") || strings.HasSuffix(c.Prefix, "/") || strings.Contains(c.Prefix, "//") {
+		return fmt.Errorf("префикс пути '%s' должен начинаться с '/' и не заканчиваться '/'", c.Prefix)
+	}
+	if strings.ContainsAny(c.Prefix, "{}*?#%\\ ") {
+		return fmt.Errorf("префикс пути '%s' содержит недопустимые символы", c.Prefix)
+	}
+	return nil
 }
 
+// Ограничение количества строк в хранилище: при достижении max_keys добавление строк отклоняется
+type KeyLimitConfig struct {
+	MaxKeys        uint64 `json:"max_keys"`        // Максимальное количество строк (0 - без ограничения)
+	RefreshSeconds int    `json:"refresh_seconds"` // Интервал обновления количества строк из octet::stats
+}
+
+// Проверка ограничения количества строк
+func (c *KeyLimitConfig) validate() error {
+	if c.MaxKeys != 0 && c.RefreshSeconds <= 0 {
+		return fmt.Errorf("интервал обновления количества строк должен быть положительным")
+	}
+	return nil
+}
+
+// Параметры обнаружения повторных изменяющих запросов (двойные нажатия, повторы клиентов).
+// Повторный запрос в течение окна получает сохраненный ответ первого запроса без обращения к octet.
+type DedupConfig struct {
+	Enabled    bool `json:"enabled"`     // Обнаруживать ли повторные запросы
+	WindowMs   int  `json:"window_ms"`   // Окно, в течение которого запрос считается повторным
+	ByBody     bool `json:"by_body"`     // Сравнивать запросы без Idempotency-Key по хешу тела
+	MaxEntries int  `json:"max_entries"` // Максимальное количество запоминаемых запросов
+}
+
+// Проверка параметров обнаружения повторных запросов
+func (c *DedupConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WindowMs <= 0 {
+		return fmt.Errorf("окно обнаружения повторных запросов должно быть положительным")
+	}
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("количество запоминаемых запросов должно быть положительным")
+	}
+	return nil
+}
+
+// Параметры сохранения результатов добавлений с ключом идемпотентности (POST с Idempotency-Key):
+// повтор добавления с тем же ключом в течение срока хранения получает ответ первого запроса
+type IdempotencyConfig struct {
+	Enabled    bool `json:"enabled"`     // Сохранять ли результаты добавлений
+	TTLSeconds int  `json:"ttl_seconds"` // Срок хранения результата
+	MaxEntries int  `json:"max_entries"` // Максимальное количество результатов в памяти
+}
+
+// Проверка параметров сохранения результатов добавлений
+func (c *IdempotencyConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.TTLSeconds <= 0 {
+		return fmt.Errorf("срок хранения результатов добавлений с ключом идемпотентности должен быть положительным")
+	}
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("количество результатов добавлений с ключом идемпотентности должно быть положительным")
+	}
+	return nil
+}
+
+// Форматы идентификаторов запросов
+const (
+	RequestIDFormatDefault = "default" // HTTP: host/random-000001, запросы к octet: UUIDv4
+	RequestIDFormatUUIDv7  = "uuidv7"  // UUIDv7, упорядоченные по времени создания
+)
+
+// Параметры идентификаторов запросов: HTTP запросов без заголовка X-Request-Id и запросов к octet
+type RequestIDConfig struct {
+	Format string `json:"format"` // default или uuidv7
+}
+
+// Проверка параметров идентификаторов запросов
+func (c *RequestIDConfig) validate() error {
+	switch c.Format {
+	case RequestIDFormatDefault, RequestIDFormatUUIDv7:
+		return nil
+	default:
+		return fmt.Errorf("неизвестный формат идентификаторов запросов '%s'", c.Format)
+	}
+}
+
+// Создаются ли идентификаторы запросов в виде UUIDv7
+func (c RequestIDConfig) UUIDv7() bool {
+	return c.Format == RequestIDFormatUUIDv7
+}
+
+// Параметры завершения работы сервера. Длительные потоки (например, /admin/v1/journal)
+// получают отдельное время на завершение, после которого они останавливаются с признаком завершения работы.
+type ShutdownConfig struct {
+	TimeoutSeconds     int `json:"timeout_seconds"`      // Ожидание завершения обычных запросов
+	StreamGraceSeconds int `json:"stream_grace_seconds"` // Ожидание завершения длительных потоков до их остановки
+}
+
+// Проверка параметров завершения работы
+func (c *ShutdownConfig) validate() error {
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("таймаут завершения работы должен быть положительным")
+	}
+	if c.StreamGraceSeconds < 0 {
+		return fmt.Errorf("время завершения длительных потоков не может быть отрицательным")
+	}
+	return nil
+}
+
+// Параметры отслеживания удаления и замены файла сокета octet (inotify, только Linux).
+// Процесс перезапускается, если включен автоматический перезапуск в health_policy.
+type SocketWatchConfig struct {
+	Enabled bool `json:"enabled"` // Отслеживать ли файл сокета
+}
+
+// Параметры проверки значений подключаемым модулем Go (собранным с -buildmode=plugin)
+type ValidationConfig struct {
+	Plugin string `json:"plugin"` // Путь к модулю (пустой - проверка отключена)
+}
+
+// Маршруты работы со строками, к которым применяются перехватчики
+const (
+	RouteInsert = "insert" // POST /octet/v1/
+	RouteBatch  = "batch"  // POST /octet/v1/batch
+	RouteGet    = "get"    // GET /octet/v1/{uuid}
+	RouteUpdate = "update" // PUT /octet/v1/{uuid}
+	RouteRemove = "remove" // DELETE /octet/v1/{uuid}
+)
+
+// Перехватчик запросов и ответов, встроенный в сборку сервера
+type InterceptorConfig struct {
+	Name   string            `json:"name"`   // Имя зарегистрированного перехватчика
+	Routes []string          `json:"routes"` // Маршруты, к которым применяется перехватчик
+	Params map[string]string `json:"params"` // Параметры перехватчика
+}
+
+// Проверка параметров перехватчика
+func (c *InterceptorConfig) validate() error {
+	if len(c.Name) == 0 {
+		return fmt.Errorf("имя перехватчика не указано")
+	}
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("маршруты перехватчика '%s' не указаны", c.Name)
+	}
+	for _, route := range c.Routes {
+		switch route {
+		case RouteInsert, RouteBatch, RouteGet, RouteUpdate, RouteRemove:
+		default:
+			return fmt.Errorf("неизвестный маршрут '%s' перехватчика '%s'", route, c.Name)
+		}
+	}
+	return nil
+}
+
+// Параметры вывода сервера из балансировки (POST /admin/v1/drain)
+type DrainConfig struct {
+	TimeoutSeconds int `json:"timeout_seconds"` // Максимальное ожидание завершения выполняющихся запросов
+	GraceSeconds   int `json:"grace_seconds"`   // Задержка перед завершением работы после вывода
+}
+
+// Проверка параметров вывода из балансировки
+func (c *DrainConfig) validate() error {
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("таймаут вывода из балансировки должен быть положительным")
+	}
+	if c.GraceSeconds < 0 {
+		return fmt.Errorf("задержка завершения работы не может быть отрицательной")
+	}
+	return nil
+}
+
+// Параметры учета трафика тел запросов и ответов /octet/v1 по клиентам
+// и ограничения скорости передачи для каждого клиента
+type BandwidthConfig struct {
+	Enabled           bool  `json:"enabled"`              // Учитывать ли трафик клиентов
+	InBytesPerSecond  int64 `json:"in_bytes_per_second"`  // Скорость чтения тел запросов клиента (0 - без ограничения)
+	OutBytesPerSecond int64 `json:"out_bytes_per_second"` // Скорость записи тел ответов клиенту (0 - без ограничения)
+	BurstBytes        int64 `json:"burst_bytes"`          // Объем, передаваемый без ожидания после простоя
+}
+
+// Проверка параметров учета трафика
+func (c *BandwidthConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.InBytesPerSecond < 0 || c.OutBytesPerSecond < 0 {
+		return fmt.Errorf("ограничение скорости передачи не может быть отрицательным")
+	}
+	if (c.InBytesPerSecond > 0 || c.OutBytesPerSecond > 0) && c.BurstBytes <= 0 {
+		return fmt.Errorf("объем передачи без ожидания должен быть положительным")
+	}
+	return nil
+}
+
+// Параметры чтения строк на момент времени (GET /octet/v1/{uuid}?as_of=...).
+// Каждое такое чтение просматривает журнал операций octet, поэтому по умолчанию отключено.
+type TimeTravelConfig struct {
+	Enabled bool `json:"enabled"` // Доступно ли чтение на момент времени
+}
+
+// Параметры обновления octet через административный API (POST /admin/v1/process/upgrade).
+// Обновление запускает произвольный исполняемый файл, поэтому по умолчанию отключено.
+type UpgradeConfig struct {
+	Enabled             bool `json:"enabled"`               // Доступно ли обновление
+	DrainTimeoutSeconds int  `json:"drain_timeout_seconds"` // Ожидание завершения выполняющихся запросов
+	VerifyPings         int  `json:"verify_pings"`          // Количество проверок связи с новым процессом
+	PingTimeoutMs       int  `json:"ping_timeout_ms"`       // Таймаут одной проверки связи
+}
+
+// Проверка параметров обновления octet
+func (c *UpgradeConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.DrainTimeoutSeconds <= 0 || c.VerifyPings <= 0 || c.PingTimeoutMs <= 0 {
+		return fmt.Errorf("параметры обновления octet должны быть положительными")
+	}
+	return nil
+}
+
+// Ограничения HTTP запросов
+type RequestLimitsConfig struct {
+	MaxBodyBytes int64 `json:"max_body_bytes"` // Максимальный размер тела запроса (0 - без ограничения)
+}
+
+// Проверка ограничений HTTP запросов
+func (c *RequestLimitsConfig) validate() error {
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("максимальный размер тела запроса не может быть отрицательным")
+	}
+	return nil
+}
+
+// Параметры прогрева после запуска: до его завершения /ready возвращает 503,
+// чтобы балансировщик не направлял запросы на сервер с холодными соединениями и кэшем
+type WarmupConfig struct {
+	Enabled        bool     `json:"enabled"`         // Выполнять ли прогрев
+	Uuids          []string `json:"uuids"`           // UUID часто читаемых строк
+	UuidsFile      string   `json:"uuids_file"`      // Файл с UUID часто читаемых строк (по одному в строке)
+	Pings          int      `json:"pings"`           // Количество проверок связи с octet
+	TimeoutSeconds int      `json:"timeout_seconds"` // Максимальная длительность прогрева
+}
+
+// Проверка параметров прогрева
+func (c *WarmupConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Pings < 0 {
+		return fmt.Errorf("количество проверок связи при прогреве не может быть отрицательным")
+	}
+	if c.TimeoutSeconds <= 0 {
+		return fmt.Errorf("длительность прогрева должна быть положительной")
+	}
+	return nil
+}
+
+// Параметры асинхронного удаления: DELETE с заголовком Prefer: respond-async записывает метку удаления
+// и сразу отвечает 202, а строка удаляется из octet в фоне
+type AsyncDeleteConfig struct {
+	Enabled         bool   `json:"enabled"`           // Включено ли асинхронное удаление
+	File            string `json:"file"`              // Файл меток удаления
+	RetryIntervalMs int    `json:"retry_interval_ms"` // Интервал повторных попыток удаления в octet
+}
+
+// Проверка параметров асинхронного удаления
+func (c *AsyncDeleteConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.File) == 0 {
+		return fmt.Errorf("не указан файл меток удаления")
+	}
+	if c.RetryIntervalMs <= 0 {
+		return fmt.Errorf("интервал повторного удаления должен быть положительным")
+	}
+	return nil
+}
+
+// Параметры разделения пула соединений: запросы чтения и изменения строк могут одновременно
+// занимать не больше своей доли пула, поэтому поток медленных чтений не блокирует изменения
+// и проверки состояния
+type PoolPartitionsConfig struct {
+	Enabled    bool    `json:"enabled"`     // Включено ли разделение
+	ReadShare  float64 `json:"read_share"`  // Доля пула (0-1] для чтения строк
+	WriteShare float64 `json:"write_share"` // Доля пула (0-1] для изменения строк
+}
+
+// Проверка параметров разделения пула
+func (c *PoolPartitionsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ReadShare <= 0 || c.ReadShare > 1 {
+		return fmt.Errorf("доля пула для чтения должна быть в интервале (0, 1]")
+	}
+	if c.WriteShare <= 0 || c.WriteShare > 1 {
+		return fmt.Errorf("доля пула для изменений должна быть в интервале (0, 1]")
+	}
+	return nil
+}
+
+// Последняя версия формата ответов API
+const LatestAPIVersion = 2
+
+// Параметры версий формата ответов API. Клиент выбирает версию заголовком X-Octet-Api-Version,
+// без заголовка используется версия по умолчанию.
+type APIVersionConfig struct {
+	Default int `json:"default"` // Версия по умолчанию
+}
+
+// Проверка параметров версий формата ответов
+func (c *APIVersionConfig) validate() error {
+	if c.Default < 1 || c.Default > LatestAPIVersion {
+		return fmt.Errorf("версия API по умолчанию должна быть от 1 до %d", LatestAPIVersion)
+	}
+	return nil
+}
+
+// Обработка прежних адресов без пространства имен (/octet/v1/{uuid})
+const (
+	LegacyRoutesRewrite  = "rewrite"  // Выполнение в пространстве имен по умолчанию
+	LegacyRoutesRedirect = "redirect" // Перенаправление (308) на адрес в пространстве имен по умолчанию
+	LegacyRoutesOff      = "off"      // Прежние адреса отключены
+)
+
+// Параметры пространств имен. Строки адресуются как /octet/v1/ns/{namespace}/{uuid};
+// прежние адреса без пространства имен относятся к пространству имен по умолчанию.
+type NamespacesConfig struct {
+	Default      string `json:"default"`       // Пространство имен по умолчанию
+	LegacyRoutes string `json:"legacy_routes"` // rewrite, redirect или off
+	// Неизменяемые пространства имен: добавление строк разрешено, изменение и удаление - нет
+	Immutable []string `json:"immutable"`
+	// Cache-Control для GET в неизменяемых пространствах имен
+	ImmutableCacheControl string `json:"immutable_cache_control"`
+}
+
+// Является ли пространство имен неизменяемым
+func (c *NamespacesConfig) IsImmutable(namespace string) bool {
+	return slices.Contains(c.Immutable, namespace)
+}
+
+// Проверка параметров пространств имен
+func (c *NamespacesConfig) validate() error {
+	if !namespacePattern.MatchString(c.Default) {
+		return fmt.Errorf("некорректное имя пространства имен по умолчанию: '%s'", c.Default)
+	}
+	for _, namespace := range c.Immutable {
+		// Хранилище octet пока содержит одно пространство имен
+		if namespace != c.Default {
+			return fmt.Errorf("неизменяемое пространство имен '%s' не найдено", namespace)
+		}
+	}
+	switch c.LegacyRoutes {
+	case LegacyRoutesRewrite, LegacyRoutesRedirect, LegacyRoutesOff:
+		return nil
+	default:
+		return fmt.Errorf("неизвестный режим прежних адресов: '%s'", c.LegacyRoutes)
+	}
+}
+
+// Параметры предупреждений о приближении к ограничениям (заголовки X-Octet-Warning в успешных ответах).
+// Предупреждение добавляется, когда занятая доля ограничения не меньше threshold.
+type SoftLimitsConfig struct {
+	Enabled   bool    `json:"enabled"`   // Добавлять ли предупреждения
+	Threshold float64 `json:"threshold"` // Доля ограничения (0-1), начиная с которой добавляется предупреждение
+}
+
+// Проверка параметров предупреждений
+func (c *SoftLimitsConfig) validate() error {
+	if c.Enabled && (c.Threshold <= 0 || c.Threshold > 1) {
+		return fmt.Errorf("порог предупреждений о приближении к ограничениям должен быть в интервале (0, 1]")
+	}
+	return nil
+}
+
+// Параметры журнала запросов: краткие записи о каждом HTTP запросе в локальном кольцевом файле
+// для восстановления событий перед инцидентом, если централизованные логи отстают
+type RequestJournalConfig struct {
+	Enabled    bool   `json:"enabled"`     // Включена ли запись
+	File       string `json:"file"`        // Кольцевой файл журнала
+	MaxRecords int    `json:"max_records"` // Количество хранимых записей (по 256 байт)
+}
+
+// Проверка параметров журнала запросов
+func (c *RequestJournalConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.File) == 0 {
+		return fmt.Errorf("не указан файл журнала запросов")
+	}
+	if c.MaxRecords <= 0 {
+		return fmt.Errorf("количество записей журнала запросов должно быть положительным")
+	}
+	return nil
+}
+
+// Параметры доставки событий изменения данных подписчикам
+type EventsConfig struct {
+	Enabled  bool            `json:"enabled"`  // Включена ли запись событий
+	Dir      string          `json:"dir"`      // Директория для хранения событий и состояния доставки
+	Webhooks []WebhookConfig `json:"webhooks"` // Подписчики
+	// Срок хранения доставленных событий в часах (0 - события не удаляются).
+	// Определяет, насколько далеко в прошлое возможна повторная доставка.
+	RetentionHours int `json:"retention_hours"`
+}
+
+// Параметры подписчика на события
+type WebhookConfig struct {
+	Name        string `json:"name"`         // Уникальное имя подписчика
+	URL         string `json:"url"`          // Адрес, на который отправляются события
+	Secret      string `json:"secret"`       // Ключ подписи HMAC-SHA256 (пусто - без подписи)
+	Workers     int    `json:"workers"`      // Количество параллельных очередей доставки (0 - 4)
+	MaxAttempts int    `json:"max_attempts"` // Количество попыток доставки одного события (0 - 10)
+	Timeout     int    `json:"timeout"`      // Таймаут одной попытки в секундах (0 - 10)
+	// Задержка перед первой повторной попыткой в миллисекундах, удваивается с каждой попыткой (0 - 1000)
+	RetryInitialMs int `json:"retry_initial_ms"`
+	// Максимальная задержка между попытками в миллисекундах (0 - 60000)
+	RetryMaxMs int `json:"retry_max_ms"`
+}
+
+// Параметры журнала намерений (write-ahead log) изменений данных.
+// Незавершенные намерения сверяются с octet при запуске сервера.
+type IntentLogConfig struct {
+	Enabled bool   `json:"enabled"` // Включен ли журнал
+	File    string `json:"file"`    // Файл журнала
+	// Повторно применять незавершенные удаления при сверке
+	Repair bool `json:"repair"`
+}
+
+// Права доступа к сокету octet, устанавливаемые после его создания
+// и проверяемые перед каждым подключением
+type SocketPermissionsConfig struct {
+	Mode  string `json:"mode"`  // Права доступа в восьмеричном виде (например, "0600")
+	Owner string `json:"owner"` // Владелец (имя или UID, пусто - не изменять)
+	Group string `json:"group"` // Группа (имя или GID, пусто - не изменять)
+	// Проверка учетных данных (PID/UID) процесса на другом конце сокета (SO_PEERCRED)
+	VerifyPeer bool `json:"verify_peer"`
+}
+
+// Права доступа в числовом виде
+func (c *SocketPermissionsConfig) FileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("некорректные права доступа к сокету: '%s'", c.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Параметры таймаута чтения ответа octet, рассчитываемого по длительности последних выполнений команды.
+// Таймаут равен процентилю длительности, умноженному на factor, в границах от min_ms до max_ms.
+type AdaptiveTimeoutConfig struct {
+	Enabled    bool    `json:"enabled"`    // Включен ли расчет таймаута (иначе таймаут чтения фиксирован)
+	Percentile float64 `json:"percentile"` // Процентиль длительности (0-1)
+	Factor     float64 `json:"factor"`     // Множитель процентиля
+	MinMs      int     `json:"min_ms"`     // Нижняя граница таймаута в миллисекундах
+	MaxMs      int     `json:"max_ms"`     // Верхняя граница таймаута в миллисекундах (0 - фиксированный таймаут чтения)
+	// Количество измерений команды, до накопления которого используется фиксированный таймаут чтения
+	MinSamples int `json:"min_samples"`
+}
+
+// Повторные попытки подключения к сокету octet, который еще не принимает соединения
+// (например, сразу после перезапуска octet)
+type DialRetryConfig struct {
+	Attempts       int `json:"attempts"`         // Количество повторных попыток (0 - без повторов)
+	InitialDelayMs int `json:"initial_delay_ms"` // Задержка перед первой повторной попыткой в миллисекундах
+	MaxDelayMs     int `json:"max_delay_ms"`     // Максимальная задержка между попытками в миллисекундах
+}
+
+// Проверка параметров повторных попыток подключения
+func (c *DialRetryConfig) validate() error {
+	if c.Attempts < 0 {
+		return fmt.Errorf("количество повторных попыток подключения не может быть отрицательным")
+	}
+	if c.Attempts == 0 {
+		return nil
+	}
+	if c.InitialDelayMs <= 0 {
+		return fmt.Errorf("задержка перед повторным подключением должна быть положительной")
+	}
+	if c.MaxDelayMs < c.InitialDelayMs {
+		return fmt.Errorf("максимальная задержка между попытками подключения не может быть меньше начальной")
+	}
+	return nil
+}
+
+// Проверка параметров таймаута чтения
+func (c *AdaptiveTimeoutConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Percentile <= 0 || c.Percentile > 1 {
+		return fmt.Errorf("процентиль для расчета таймаута должен быть в интервале (0, 1]")
+	}
+	if c.Factor < 1 {
+		return fmt.Errorf("множитель для расчета таймаута не может быть меньше 1")
+	}
+	if c.MinMs <= 0 {
+		return fmt.Errorf("нижняя граница таймаута чтения должна быть положительной")
+	}
+	if c.MaxMs < 0 || (c.MaxMs > 0 && c.MaxMs < c.MinMs) {
+		return fmt.Errorf("верхняя граница таймаута чтения не может быть меньше нижней")
+	}
+	if c.MinSamples < 0 {
+		return fmt.Errorf("количество измерений для расчета таймаута не может быть отрицательным")
+	}
+	return nil
+}
+
+// Параметры оценки состояния octet по доле неуспешных команд и их длительности.
+// Если пороги нарушаются дольше sustain_seconds, принимается решение о перезапуске octet.
+type HealthPolicyConfig struct {
+	Enabled         bool    `json:"enabled"`          // Включена ли оценка состояния
+	AutoRestart     bool    `json:"auto_restart"`     // Перезапускать octet (иначе решение только записывается)
+	IntervalSeconds int     `json:"interval_seconds"` // Интервал оценки
+	WindowSeconds   int     `json:"window_seconds"`   // Окно, по командам которого оценивается состояние
+	MinCommands     int     `json:"min_commands"`     // Минимальное количество команд в окне для оценки
+	MaxErrorRate    float64 `json:"max_error_rate"`   // Допустимая доля неуспешных команд (0-1)
+	MaxLatencyMs    int     `json:"max_latency_ms"`   // Допустимый 99-й процентиль длительности (0 - не проверяется)
+	SustainSeconds  int     `json:"sustain_seconds"`  // Длительность нарушения до принятия решения
+	CooldownSeconds int     `json:"cooldown_seconds"` // Минимальный интервал между решениями
+}
+
+// Проверка параметров оценки состояния
+func (c *HealthPolicyConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.IntervalSeconds <= 0 || c.WindowSeconds < c.IntervalSeconds {
+		return fmt.Errorf("окно оценки состояния octet должно быть не меньше положительного интервала оценки")
+	}
+	if c.MaxErrorRate <= 0 || c.MaxErrorRate > 1 {
+		return fmt.Errorf("допустимая доля неуспешных команд должна быть в интервале (0, 1]")
+	}
+	if c.MinCommands < 0 || c.MaxLatencyMs < 0 || c.SustainSeconds < 0 || c.CooldownSeconds < 0 {
+		return fmt.Errorf("параметры оценки состояния octet не могут быть отрицательными")
+	}
+	return nil
+}
+
+// Параметры внедрения сбоев во взаимодействие с octet.
+// Значения вероятностей - начальные, их можно изменить через административный API.
+type FaultsConfig struct {
+	Enabled     bool    `json:"enabled"`      // Включен ли режим внедрения сбоев
+	LatencyMs   int     `json:"latency_ms"`   // Задержка перед отправкой запроса в миллисекундах
+	LatencyRate float64 `json:"latency_rate"` // Вероятность задержки (0-1)
+	DropRate    float64 `json:"drop_rate"`    // Вероятность потери запроса (0-1)
+	ResetRate   float64 `json:"reset_rate"`   // Вероятность разрыва соединения (0-1)
+}
+
+// Параметры записи обмена сообщениями с octet (для отладки протокола)
+type TraceConfig struct {
+	Enabled    bool   `json:"enabled"`     // Включена ли запись
+	BufferSize int    `json:"buffer_size"` // Количество последних сообщений, хранимых в памяти
+	File       string `json:"file"`        // Файл для записи всех сообщений (пусто - только в памяти)
+	// Скрывать данные строк в записанных сообщениях
+	RedactPayload bool `json:"redact_payload"`
+}
+
+// Параметры пакетных запросов.
+// Пакет делится на части, выполняемые параллельно на разных клиентах пула,
+// чтобы один пакет не занимал все соединения с octet.
+type BatchConfig struct {
+	MaxItems  int `json:"max_items"`  // Максимальное количество операций в одном запросе (0 - пакетные запросы отключены)
+	ChunkSize int `json:"chunk_size"` // Количество операций, выполняемых на одном клиенте подряд
+	// Максимальное количество клиентов пула, одновременно занятых одним пакетом
+	// (0 - половина пула)
+	MaxParallel int `json:"max_parallel"`
+}
+
+// Проверка параметров пакетных запросов
+func (c *BatchConfig) validate() error {
+	if c.MaxItems < 0 {
+		return fmt.Errorf("максимальное количество операций в пакете не может быть отрицательным")
+	}
+	if c.MaxItems > 0 && c.ChunkSize <= 0 {
+		return fmt.Errorf("размер части пакета должен быть положительным")
+	}
+	if c.MaxParallel < 0 {
+		return fmt.Errorf("количество параллельно выполняемых частей пакета не может быть отрицательным")
+	}
+	return nil
+}
+
+// Параметры объединения одиночных изменений данных, пришедших в течение короткого окна,
+// в один запрос batch к octet. Увеличивает задержку записи не более чем на окно,
+// но снижает количество обменов с octet при большом потоке записей.
+type CoalesceConfig struct {
+	Enabled  bool `json:"enabled"`   // Включено ли объединение
+	WindowMs int  `json:"window_ms"` // Окно ожидания следующих изменений в миллисекундах
+	MaxOps   int  `json:"max_ops"`   // Максимальное количество операций в одном запросе
+	// Максимальный размер данных операций в одном запросе в байтах
+	// (более крупные операции выполняются по одной)
+	MaxBytes int `json:"max_bytes"`
+}
+
+// Проверка параметров объединения изменений
+func (c *CoalesceConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WindowMs <= 0 {
+		return fmt.Errorf("окно объединения изменений должно быть положительным")
+	}
+	if c.MaxOps <= 0 {
+		return fmt.Errorf("максимальное количество объединяемых операций должно быть положительным")
+	}
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("максимальный размер объединяемых операций должен быть положительным")
+	}
+	return nil
+}
+
+// Параметры заголовков кэширования для GET запросов
+type CacheConfig struct {
+	// Значение заголовка Cache-Control (пустая строка - заголовок не отправляется)
+	CacheControl string `json:"cache_control"`
+	// Отправка ETag и обработка If-None-Match
+	ETag bool `json:"etag"`
+	// Отправка Last-Modified и обработка If-Modified-Since (метаданные строки запрашиваются
+	// у octet перед каждым чтением)
+	LastModified bool `json:"last_modified"`
+	// Политики кэширования пространств имен (заменяют общее значение Cache-Control)
+	Policies []CachePolicyConfig `json:"policies"`
+}
+
+// Политика кэширования строк пространства имен
+type CachePolicyConfig struct {
+	Namespace    string `json:"namespace"`     // Пространство имен
+	CacheControl string `json:"cache_control"` // Значение заголовка Cache-Control (пустая строка - заголовок не отправляется)
+}
+
+// Политика кэширования пространства имен (nil - используется общее значение)
+func (c *CacheConfig) Policy(namespace string) *CachePolicyConfig {
+	for i := range c.Policies {
+		if c.Policies[i].Namespace == namespace {
+			return &c.Policies[i]
+		}
+	}
+	return nil
+}
+
+// Проверка политик кэширования
+func (c *CacheConfig) validate(namespaces NamespacesConfig) error {
+	seen := make(map[string]bool, len(c.Policies))
+	for _, policy := range c.Policies {
+		// Хранилище octet пока содержит одно пространство имен
+		if policy.Namespace != namespaces.Default {
+			return fmt.Errorf("пространство имен политики кэширования '%s' не найдено", policy.Namespace)
+		}
+		if seen[policy.Namespace] {
+			return fmt.Errorf("политика кэширования пространства имен '%s' указана повторно", policy.Namespace)
+		}
+		seen[policy.Namespace] = true
+	}
+	return nil
+}
+
+// Режимы аутентификации
+const (
+	AuthModeNone   = "none"    // Аутентификация отключена
+	AuthModeAPIKey = "api_key" // Аутентификация по API-ключам
+	AuthModeOIDC   = "oidc"    // Аутентификация по access-токенам OpenID Connect
+)
+
+// Параметры аутентификации клиентов
+type AuthConfig struct {
+	Mode    string         `json:"mode"`     // Режим аутентификации (none, api_key, oidc)
+	APIKeys []APIKeyConfig `json:"api_keys"` // API-ключи
+	// Файл для хранения API-ключей, выпущенных через административный API
+	KeysFile string     `json:"keys_file"`
+	OIDC     OIDCConfig `json:"oidc"` // Параметры OpenID Connect
+}
+
+// Параметры проверки access-токенов OpenID Connect
+type OIDCConfig struct {
+	Issuer   string `json:"issuer"`   // Адрес издателя (провайдера) токенов
+	Audience string `json:"audience"` // Ожидаемая аудитория токена (claim aud)
+	// Claim с ролями пользователя (вложенные поля через точку, например realm_access.roles)
+	RolesClaim string `json:"roles_claim"`
+	// Соответствие ролей областям доступа (read, write, admin)
+	RoleScopes map[string][]string `json:"role_scopes"`
+}
+
+// Описание API-ключа в конфигурации
+type APIKeyConfig struct {
+	Name    string   `json:"name"`     // Имя ключа (для логов)
+	Key     string   `json:"key"`      // Значение ключа
+	KeyHash string   `json:"key_hash"` // SHA-256 хэш ключа (вместо значения)
+	Scopes  []string `json:"scopes"`   // Области доступа (read, write, admin)
+	// Пространства имен, строки которых доступны ключу (пусто - все пространства имен)
+	Namespaces []string `json:"namespaces"`
+}
+
+// Параметры сжатия HTTP ответов
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"` // Включено ли сжатие ответов
+	// Поддерживаемые кодирования в порядке предпочтения сервера (zstd, br, gzip)
+	Encodings   []string `json:"encodings"`
+	GzipLevel   int      `json:"gzip_level"`   // Уровень сжатия gzip (1-9)
+	ZstdLevel   int      `json:"zstd_level"`   // Уровень сжатия zstd (1-22)
+	BrotliLevel int      `json:"brotli_level"` // Уровень сжатия brotli (0-11)
+	// Распаковывать тела запросов с Content-Encoding gzip, zstd или br (иначе такие запросы отклоняются)
+	DecompressRequests bool `json:"decompress_requests"`
+}
+
+// Параметры кодирования JSON-ответов
+type JSONConfig struct {
+	// Экранировать символы <, > и & в строках ответа (\u003c, \u003e, \u0026)
+	EscapeHTML bool `json:"escape_html"`
+	// Разрешить форматирование ответа с отступами параметром запроса ?pretty=1
+	AllowPretty bool `json:"allow_pretty"`
+}
+
+// Проверка параметров сжатия
+func (c *CompressionConfig) validate() error {
+	for _, encoding := range c.Encodings {
+		switch encoding {
+		case "gzip", "zstd", "br":
+		default:
+			return fmt.Errorf("неподдерживаемое кодирование сжатия: %s", encoding)
+		}
+	}
+	if c.GzipLevel < 1 || c.GzipLevel > 9 {
+		return fmt.Errorf("уровень сжатия gzip должен быть от 1 до 9: %d", c.GzipLevel)
+	}
+	if c.ZstdLevel < 1 || c.ZstdLevel > 22 {
+		return fmt.Errorf("уровень сжатия zstd должен быть от 1 до 22: %d", c.ZstdLevel)
+	}
+	if c.BrotliLevel < 0 || c.BrotliLevel > 11 {
+		return fmt.Errorf("уровень сжатия brotli должен быть от 0 до 11: %d", c.BrotliLevel)
+	}
+	return nil
+}
+
+// Проверка параметров аутентификации
+func (c *AuthConfig) validate(namespaces NamespacesConfig) error {
+	switch c.Mode {
+	case "", AuthModeNone:
+		return nil
+	case AuthModeAPIKey:
+		if len(c.APIKeys) == 0 && len(c.KeysFile) == 0 {
+			return fmt.Errorf("для режима аутентификации %s не указано ни одного API-ключа", c.Mode)
+		}
+		for _, key := range c.APIKeys {
+			if (len(key.Key) == 0) == (len(key.KeyHash) == 0) {
+				return fmt.Errorf("для API-ключа '%s' должно быть указано либо значение, либо хэш", key.Name)
+			}
+			for _, namespace := range key.Namespaces {
+				// Хранилище octet пока содержит одно пространство имен
+				if namespace != namespaces.Default {
+					return fmt.Errorf("пространство имен '%s' API-ключа '%s' не найдено", namespace, key.Name)
+				}
+			}
+		}
+		return nil
+	case AuthModeOIDC:
+		if len(c.OIDC.Issuer) == 0 {
+			return fmt.Errorf("для режима аутентификации %s не указан издатель токенов", c.Mode)
+		}
+		if len(c.OIDC.Audience) == 0 {
+			return fmt.Errorf("для режима аутентификации %s не указана аудитория токенов", c.Mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("неизвестный режим аутентификации: %s", c.Mode)
+	}
+}
+
+func (c *EventsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RetentionHours < 0 {
+		return fmt.Errorf("срок хранения событий не может быть отрицательным")
+	}
+	names := make(map[string]bool, len(c.Webhooks))
+	for _, webhook := range c.Webhooks {
+		// Имя используется в именах файлов состояния доставки
+		if len(webhook.Name) == 0 || strings.ContainsAny(webhook.Name, `/\`) || strings.HasPrefix(webhook.Name, ".") {
+			return fmt.Errorf("некорректное имя подписчика на события: '%s'", webhook.Name)
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("имя подписчика на события '%s' указано несколько раз", webhook.Name)
+		}
+		names[webhook.Name] = true
+		if len(webhook.URL) == 0 {
+			return fmt.Errorf("для подписчика на события '%s' не указан адрес", webhook.Name)
+		}
+		if webhook.Workers < 0 || webhook.Workers > maxWebhookWorkers {
+			return fmt.Errorf("количество очередей доставки подписчика '%s' должно быть от 0 до %d",
+				webhook.Name, maxWebhookWorkers)
+		}
+		if webhook.MaxAttempts < 0 || webhook.Timeout < 0 || webhook.RetryInitialMs < 0 || webhook.RetryMaxMs < 0 {
+			return fmt.Errorf("параметры доставки подписчика '%s' не могут быть отрицательными", webhook.Name)
+		}
+		if webhook.RetryMaxMs != 0 && webhook.RetryMaxMs < webhook.RetryInitialMs {
+			return fmt.Errorf("максимальная задержка повторов подписчика '%s' меньше начальной", webhook.Name)
+		}
+	}
+	return nil
+}
+
+// Максимальное количество очередей доставки одного подписчика
+const maxWebhookWorkers = 256
+
 // Загрузка конфигурации из JSON файла по указанному пути
 func loadFromFile(path string, config *Config) error {
 	// Проверяем существование файла
@@ -38,6 +1283,9 @@ func loadFromFile(path string, config *Config) error {
 		return fmt.Errorf("не удалось разобрать файл конфигурации: %w", err)
 	}
 
+	sum := sha256.Sum256(data)
+	config.File = path
+	config.FileHash = hex.EncodeToString(sum[:])
 	return nil
 }
 
@@ -56,8 +1304,179 @@ func Load(configPath string) (*Config, error) {
 	config := &Config{
 		StorageDir: filepath.Join(octetDir, "storage"),
 		SocketPath: filepath.Join(octetDir, "octet.sock"),
-		OctetPath:  "",
-		HTTPAddr:   ":8080",
+		SocketPermissions: SocketPermissionsConfig{
+			Mode: "0600",
+		},
+		OctetPath: "",
+		HTTPAddr:  AddrList{":8080"},
+
+		HeartbeatConcurrency: 4,
+		HeartbeatJitter:      0.5,
+
+		Compression: CompressionConfig{
+			Enabled:     true,
+			Encodings:   []string{"zstd", "br", "gzip"},
+			GzipLevel:   5,
+			ZstdLevel:   3,
+			BrotliLevel: 4,
+
+			DecompressRequests: true,
+		},
+		JSON: JSONConfig{
+			EscapeHTML:  true,
+			AllowPretty: true,
+		},
+		Auth: AuthConfig{
+			Mode:     AuthModeNone,
+			KeysFile: filepath.Join(octetDir, "apikeys.json"),
+			OIDC: OIDCConfig{
+				RolesClaim: "roles",
+			},
+		},
+		Cache: CacheConfig{
+			CacheControl: "no-cache",
+			ETag:         true,
+			LastModified: true,
+		},
+		Trace: TraceConfig{
+			BufferSize:    1000,
+			RedactPayload: true,
+		},
+		IntentLog: IntentLogConfig{
+			File: filepath.Join(octetDir, "intents.wal"),
+		},
+		Events: EventsConfig{
+			Dir:            filepath.Join(octetDir, "events"),
+			RetentionHours: 168,
+		},
+		Batch: BatchConfig{
+			MaxItems:  1000,
+			ChunkSize: 100,
+		},
+		DialRetry: DialRetryConfig{
+			Attempts:       5,
+			InitialDelayMs: 50,
+			MaxDelayMs:     1000,
+		},
+		AdaptiveTimeout: AdaptiveTimeoutConfig{
+			Percentile: 0.99,
+			Factor:     10,
+			MinMs:      100,
+			MinSamples: 100,
+		},
+		HealthPolicy: HealthPolicyConfig{
+			IntervalSeconds: 5,
+			WindowSeconds:   60,
+			MinCommands:     20,
+			MaxErrorRate:    0.5,
+			MaxLatencyMs:    1000,
+			SustainSeconds:  30,
+			CooldownSeconds: 300,
+		},
+		APIVersion: APIVersionConfig{
+			Default: 1,
+		},
+		PoolPartitions: PoolPartitionsConfig{
+			Enabled:    false,
+			ReadShare:  0.8,
+			WriteShare: 1,
+		},
+		Namespaces: NamespacesConfig{
+			Default:      "default",
+			LegacyRoutes: LegacyRoutesRewrite,
+
+			ImmutableCacheControl: "public, max-age=31536000, immutable",
+		},
+		SoftLimits: SoftLimitsConfig{
+			Enabled:   true,
+			Threshold: 0.8,
+		},
+		RequestJournal: RequestJournalConfig{
+			File:       filepath.Join(octetDir, "requests.journal"),
+			MaxRecords: 100000,
+		},
+		Upgrade: UpgradeConfig{
+			DrainTimeoutSeconds: 30,
+			VerifyPings:         3,
+			PingTimeoutMs:       2000,
+		},
+		RequestLimits: RequestLimitsConfig{
+			MaxBodyBytes: 8 << 20,
+		},
+		Bandwidth: BandwidthConfig{
+			BurstBytes: 1 << 20,
+		},
+		SocketWatch: SocketWatchConfig{
+			Enabled: true,
+		},
+		Drain: DrainConfig{
+			TimeoutSeconds: 30,
+			GraceSeconds:   5,
+		},
+		HealthCheck: HealthCheckConfig{
+			MaxStaleMs: 1000,
+		},
+		OrderedWrites: OrderedWritesConfig{
+			SequenceFile: filepath.Join(octetDir, "sequence"),
+			Sync:         true,
+		},
+		Retention: RetentionConfig{
+			IntervalMinutes: 60,
+			DryRun:          true,
+		},
+		Binary: BinaryConfig{
+			ContentTypes: []string{"application/octet-stream"},
+		},
+		Purge: PurgeConfig{
+			MaxUuids:              1000,
+			CompactTimeoutSeconds: 300,
+		},
+		MetadataStore: MetadataStoreConfig{
+			Backend: MetadataStoreBolt,
+			File:    filepath.Join(octetDir, "metadata.db"),
+			Redis: RedisMetadataStoreConfig{
+				Prefix:    "octet:",
+				TimeoutMs: 1000,
+			},
+		},
+		Shutdown: ShutdownConfig{
+			TimeoutSeconds:     30,
+			StreamGraceSeconds: 300,
+		},
+		RequestID: RequestIDConfig{
+			Format: RequestIDFormatDefault,
+		},
+		Dedup: DedupConfig{
+			WindowMs:   2000,
+			ByBody:     true,
+			MaxEntries: 10000,
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled:    true,
+			TTLSeconds: 86400,
+			MaxEntries: 10000,
+		},
+		KeyLimit: KeyLimitConfig{
+			RefreshSeconds: 60,
+		},
+		Warmup: WarmupConfig{
+			Pings:          10,
+			TimeoutSeconds: 60,
+		},
+		AsyncDelete: AsyncDeleteConfig{
+			File:            filepath.Join(octetDir, "tombstones.log"),
+			RetryIntervalMs: 5000,
+		},
+		Coalesce: CoalesceConfig{
+			WindowMs: 2,
+			MaxOps:   64,
+			MaxBytes: 8 << 10,
+		},
+		ProcessHistory: ProcessHistoryConfig{
+			Enabled:    true,
+			File:       filepath.Join(octetDir, "process_history.log"),
+			MaxRecords: 1000,
+		},
 	}
 
 	var baseDir string
@@ -93,6 +1512,22 @@ func Load(configPath string) (*Config, error) {
 	}
 	config.StorageDir = resolve(config.StorageDir)
 	config.SocketPath = resolve(config.SocketPath)
+	config.Auth.KeysFile = resolve(config.Auth.KeysFile)
+	config.Trace.File = resolve(config.Trace.File)
+	config.IntentLog.File = resolve(config.IntentLog.File)
+	config.Events.Dir = resolve(config.Events.Dir)
+	config.RequestJournal.File = resolve(config.RequestJournal.File)
+	config.AsyncDelete.File = resolve(config.AsyncDelete.File)
+	config.ProcessHistory.File = resolve(config.ProcessHistory.File)
+	config.MetadataStore.File = resolve(config.MetadataStore.File)
+	config.OrderedWrites.SequenceFile = resolve(config.OrderedWrites.SequenceFile)
+	config.Warmup.UuidsFile = resolve(config.Warmup.UuidsFile)
+	config.Validation.Plugin = resolve(config.Validation.Plugin)
+	for i, addr := range config.HTTPAddr {
+		if path, ok := strings.CutPrefix(addr, UnixAddrPrefix); ok {
+			config.HTTPAddr[i] = UnixAddrPrefix + resolve(path)
+		}
+	}
 
 	config.OctetPath = resolve(config.OctetPath)
 	// Если путь к octet не задан в конфиге, то используем путь, указанный при компиляции
@@ -104,6 +1539,122 @@ func Load(configPath string) (*Config, error) {
 	if len(config.StorageDir) == 0 {
 		return nil, fmt.Errorf("путь к директории с хранилищем не указан")
 	}
+	if len(config.HTTPAddr) == 0 {
+		return nil, fmt.Errorf("адрес HTTP сервера не указан")
+	}
+	if config.HeartbeatConcurrency <= 0 {
+		return nil, fmt.Errorf("количество одновременных проверок heartbeat должно быть положительным")
+	}
+	if config.HeartbeatJitter < 0 || config.HeartbeatJitter >= 1 {
+		return nil, fmt.Errorf("доля интервала heartbeat для разброса проверок должна быть в интервале [0, 1)")
+	}
+	if err := config.Compression.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Auth.validate(config.Namespaces); err != nil {
+		return nil, err
+	}
+	if err := config.Events.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Batch.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Coalesce.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.AdaptiveTimeout.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.DialRetry.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.HealthPolicy.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.RequestJournal.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.SoftLimits.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Namespaces.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.APIVersion.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.PoolPartitions.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.AsyncDelete.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Warmup.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.RequestLimits.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Upgrade.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Bandwidth.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Drain.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.HealthCheck.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.OrderedWrites.validate(config.Namespaces); err != nil {
+		return nil, err
+	}
+	if err := config.Retention.validate(config.Namespaces); err != nil {
+		return nil, err
+	}
+	if err := config.Cache.validate(config.Namespaces); err != nil {
+		return nil, err
+	}
+	if err := config.Binary.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Purge.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.MetadataStore.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Shutdown.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.RequestID.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Dedup.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Idempotency.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.KeyLimit.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.PathPrefix.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.ProcessHistory.validate(); err != nil {
+		return nil, err
+	}
+	for i := range config.Interceptors {
+		if err := config.Interceptors[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := config.SocketPermissions.FileMode(); err != nil {
+		return nil, err
+	}
 	if len(config.OctetPath) == 0 {
 		return nil, fmt.Errorf("путь к исполняемому файлу octet не указан")
 	} else if _, err := os.Stat(config.OctetPath); err != nil {
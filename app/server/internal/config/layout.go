@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Файл разметки хранилища в корне storage_dir
+const StorageLayoutFile = "octet-layout.json"
+
+// Разметка директории хранилища
+const (
+	StorageLayoutFlat       = "flat"       // Файлы octet лежат в корне storage_dir
+	StorageLayoutNamespaces = "namespaces" // Файлы octet каждого пространства имен лежат в storage_dir/{namespace}
+)
+
+// Описание разметки хранилища. Отсутствие файла разметки означает плоскую разметку.
+type StorageLayout struct {
+	Layout     string    `json:"layout"`               // flat или namespaces
+	Namespaces []string  `json:"namespaces,omitempty"` // Пространства имен с собственными директориями
+	MigratedAt time.Time `json:"migrated_at,omitempty"`
+}
+
+// Чтение разметки хранилища из storage_dir
+func ReadStorageLayout(storageDir string) (StorageLayout, error) {
+	data, err := os.ReadFile(filepath.Join(storageDir, StorageLayoutFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return StorageLayout{Layout: StorageLayoutFlat}, nil
+	} else if err != nil {
+		return StorageLayout{}, fmt.Errorf("не удалось прочитать разметку хранилища: %w", err)
+	}
+
+	var layout StorageLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return StorageLayout{}, fmt.Errorf("некорректная разметка хранилища: %w", err)
+	}
+	switch layout.Layout {
+	case StorageLayoutFlat, StorageLayoutNamespaces:
+	default:
+		return StorageLayout{}, fmt.Errorf("неизвестная разметка хранилища '%s'", layout.Layout)
+	}
+	return layout, nil
+}
+
+// Запись разметки хранилища в storage_dir (через временный файл, чтобы разметка не оказалась неполной)
+func WriteStorageLayout(storageDir string, layout StorageLayout) error {
+	data, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(storageDir, StorageLayoutFile)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("не удалось записать разметку хранилища: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("не удалось записать разметку хранилища: %w", err)
+	}
+	return nil
+}
+
+// Директория файлов octet пространства имен
+func (l *StorageLayout) NamespaceDir(storageDir, namespace string) (string, error) {
+	if l.Layout != StorageLayoutNamespaces {
+		return storageDir, nil
+	}
+	if !slices.Contains(l.Namespaces, namespace) {
+		return "", fmt.Errorf("пространство имен '%s' отсутствует в разметке хранилища", namespace)
+	}
+	return filepath.Join(storageDir, namespace), nil
+}
+
+// Директория файлов octet, с которой запускается процесс: для разметки по пространствам
+// имен - директория пространства имен по умолчанию
+func (c *Config) OctetStorageDir() (string, error) {
+	layout, err := ReadStorageLayout(c.StorageDir)
+	if err != nil {
+		return "", err
+	}
+	return layout.NamespaceDir(c.StorageDir, c.Namespaces.Default)
+}
@@ -0,0 +1,240 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Операции изменения данных
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpRemove = "remove"
+)
+
+// Событие изменения данных
+type Event struct {
+	Seq  uint64    `json:"seq"` // Порядковый номер события
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	Uuid string    `json:"uuid"`
+	// Контекст трассировки запроса, изменившего данные (передается подписчикам в заголовках)
+	protocol.TraceContext
+}
+
+// Хранилище исходящих событий (outbox).
+// События дописываются в файл до доставки подписчикам, что гарантирует
+// их доставку после перезапуска сервера или недоступности подписчика.
+type Outbox struct {
+	mutex   sync.Mutex
+	dir     string
+	path    string
+	file    *os.File
+	events  []Event       // Все события по возрастанию Seq
+	nextSeq uint64        // Номер следующего события
+	notify  chan struct{} // Закрывается при добавлении события
+}
+
+// Открытие хранилища событий в указанной директории
+func OpenOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию событий: %w", err)
+	}
+
+	outbox := &Outbox{
+		dir:     dir,
+		path:    filepath.Join(dir, "outbox.ndjson"),
+		nextSeq: 1,
+		notify:  make(chan struct{}),
+	}
+	if err := outbox.load(outbox.path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(outbox.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл событий: %w", err)
+	}
+	outbox.file = file
+	return outbox, nil
+}
+
+// Добавление события об изменении данных
+func (o *Outbox) Publish(op, uuid string, trace protocol.TraceContext) (Event, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.file == nil {
+		return Event{}, errors.New("хранилище событий закрыто")
+	}
+
+	event := Event{Seq: o.nextSeq, Time: time.Now().UTC(), Op: op, Uuid: uuid, TraceContext: trace}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return Event{}, err
+	}
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return Event{}, fmt.Errorf("не удалось записать событие: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return Event{}, fmt.Errorf("не удалось записать событие: %w", err)
+	}
+
+	o.nextSeq++
+	o.events = append(o.events, event)
+	close(o.notify)
+	o.notify = make(chan struct{})
+	return event, nil
+}
+
+// События с номером больше after (не более limit, 0 - без ограничения)
+func (o *Outbox) Since(after uint64, limit int) []Event {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	start := sort.Search(len(o.events), func(i int) bool {
+		return o.events[i].Seq > after
+	})
+	end := len(o.events)
+	if limit > 0 {
+		end = min(end, start+limit)
+	}
+	return append([]Event(nil), o.events[start:end]...)
+}
+
+// Номер последнего события (0 - событий не было)
+func (o *Outbox) LastSeq() uint64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.nextSeq - 1
+}
+
+// Номер последнего события, записанного до указанного момента.
+// truncated - часть событий после этого момента уже удалена по сроку хранения.
+func (o *Outbox) SeqBefore(t time.Time) (seq uint64, truncated bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	index := sort.Search(len(o.events), func(i int) bool {
+		return !o.events[i].Time.Before(t)
+	})
+	if index < len(o.events) {
+		seq = o.events[index].Seq - 1
+	} else {
+		seq = o.nextSeq - 1
+	}
+	// События до первого сохраненного удалены
+	if index == 0 && len(o.events) != 0 && o.events[0].Seq > 1 {
+		truncated = true
+	}
+	return seq, truncated
+}
+
+// Удаление событий, записанных до указанного момента, с номером не больше maxSeq.
+// Возвращает количество удаленных событий.
+func (o *Outbox) Prune(before time.Time, maxSeq uint64) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	// Последнее событие сохраняется всегда, чтобы номера событий продолжились после перезапуска
+	count := 0
+	for count < len(o.events)-1 && o.events[count].Time.Before(before) && o.events[count].Seq <= maxSeq {
+		count++
+	}
+	if count == 0 || o.file == nil {
+		return 0, nil
+	}
+
+	// Перезаписываем файл только с оставшимися событиями
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось удалить устаревшие события: %w", err)
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, event := range o.events[count:] {
+		if err = encoder.Encode(&event); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmpPath, o.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("не удалось удалить устаревшие события: %w", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось открыть файл событий: %w", err)
+	}
+	o.file.Close()
+	o.file = file
+	o.events = append([]Event(nil), o.events[count:]...)
+	return count, nil
+}
+
+// Канал, закрываемый при добавлении следующего события
+func (o *Outbox) Wait() <-chan struct{} {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.notify
+}
+
+// Директория хранилища событий
+func (o *Outbox) Dir() string {
+	return o.dir
+}
+
+// Закрытие файла событий
+func (o *Outbox) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// Чтение сохраненных событий
+func (o *Outbox) load(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("не удалось прочитать файл событий: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Seq < o.nextSeq {
+			// Последняя запись могла быть записана не полностью при аварийном завершении
+			continue
+		}
+		o.events = append(o.events, event)
+		o.nextSeq = event.Seq + 1
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать файл событий: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package events
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Интервал проверки устаревших событий
+const retentionInterval = 10 * time.Minute
+
+// Периодическое удаление событий старше retention.
+// Удаляются только события, обработанные всеми подписчиками.
+func RunRetention(outbox *Outbox, webhooks []*Webhook, retention time.Duration, logger *zap.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		processed := outbox.LastSeq()
+		for _, webhook := range webhooks {
+			processed = min(processed, webhook.Processed())
+		}
+		pruned, err := outbox.Prune(time.Now().Add(-retention), processed)
+		if err != nil {
+			logger.Error("Не удалось удалить устаревшие события", zap.Error(err))
+		} else if pruned != 0 {
+			logger.Info("Удалены устаревшие события", zap.Int("count", pruned))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Количество событий, обрабатываемых за один проход
+const deliveryBatchSize = 100
+
+// Параметры доставки событий подписчику
+type WebhookConfig struct {
+	Name        string        // Уникальное имя подписчика
+	URL         string        // Адрес, на который отправляются события (POST)
+	Secret      string        // Ключ подписи HMAC-SHA256 (пусто - без подписи)
+	Workers     int           // Количество параллельных очередей (порядок сохраняется в пределах UUID)
+	MaxAttempts int           // Количество попыток доставки до переноса события в dead-letter
+	Timeout     time.Duration // Таймаут одной попытки
+
+	RetryInitial time.Duration // Задержка перед первой повторной попыткой (удваивается с каждой попыткой)
+	RetryMax     time.Duration // Максимальная задержка между попытками (и ожидание по Retry-After)
+}
+
+// Счетчики доставки событий подписчику
+type WebhookStats struct {
+	Name         string `json:"name"`
+	Delivered    uint64 `json:"delivered"`            // Доставленные события
+	Retries      uint64 `json:"retries"`              // Повторные попытки доставки
+	DeadLettered uint64 `json:"dead_lettered"`        // События, доставка которых прекращена
+	Lag          uint64 `json:"lag"`                  // Количество событий, ожидающих доставки
+	InFlight     uint64 `json:"in_flight"`            // Отправляемые в данный момент события
+	LastError    string `json:"last_error,omitempty"` // Последняя ошибка доставки
+}
+
+// Ошибка доставки из-за ответа подписчика
+type statusError struct {
+	status     int
+	retryAfter time.Duration // Запрошенная подписчиком задержка (Retry-After для 429 и 503)
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("подписчик вернул статус %d", e.status)
+}
+
+// Сохраняемое положение очередей подписчика
+type webhookCursors struct {
+	Cursors []uint64 `json:"cursors"` // Номер последнего обработанного события для каждой очереди
+}
+
+// Доставка событий подписчику с повторными попытками (at-least-once).
+// События распределяются по очередям по UUID, поэтому события одной записи
+// доставляются строго по порядку, а недоступность подписчика для одной записи
+// не блокирует доставку остальных.
+type Webhook struct {
+	config WebhookConfig
+	outbox *Outbox
+	client *http.Client
+	logger *zap.Logger
+
+	mutex     sync.Mutex
+	cursors   []uint64
+	epoch     uint64        // Увеличивается при перемотке очередей
+	rewound   chan struct{} // Закрывается при перемотке очередей
+	lastError string
+	saveMutex sync.Mutex // Сохранение положения очередей из нескольких очередей одновременно
+
+	delivered    atomic.Uint64
+	retries      atomic.Uint64
+	deadLettered atomic.Uint64
+	inFlight     atomic.Int64
+	requests     atomic.Uint64 // Отправленные подписчику запросы
+	requestNanos atomic.Uint64 // Суммарная длительность запросов
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Создание подписчика с восстановлением положения очередей
+func NewWebhook(config WebhookConfig, outbox *Outbox, logger *zap.Logger) (*Webhook, error) {
+	if len(config.Name) == 0 {
+		return nil, errors.New("имя подписчика не указано")
+	}
+	if len(config.URL) == 0 {
+		return nil, fmt.Errorf("адрес подписчика %q не указан", config.Name)
+	}
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 10
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.RetryInitial <= 0 {
+		config.RetryInitial = time.Second
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = time.Minute
+	}
+	config.RetryMax = max(config.RetryMax, config.RetryInitial)
+
+	webhook := &Webhook{
+		config:  config,
+		outbox:  outbox,
+		client:  &http.Client{Timeout: config.Timeout},
+		logger:  logger.With(zap.String("webhook", config.Name)),
+		rewound: make(chan struct{}),
+	}
+	if err := webhook.loadCursors(); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Запуск доставки событий
+func (w *Webhook) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	for i := range w.config.Workers {
+		w.wg.Add(1)
+		go w.run(ctx, i)
+	}
+}
+
+// Остановка доставки событий. Недоставленные события будут доставлены после запуска.
+func (w *Webhook) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.saveCursors()
+}
+
+// Снимок счетчиков доставки
+func (w *Webhook) Stats() WebhookStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	stats := WebhookStats{
+		Name:         w.config.Name,
+		Delivered:    w.delivered.Load(),
+		Retries:      w.retries.Load(),
+		DeadLettered: w.deadLettered.Load(),
+		InFlight:     uint64(max(w.inFlight.Load(), 0)),
+		LastError:    w.lastError,
+	}
+	last := w.outbox.LastSeq()
+	for _, cursor := range w.cursors {
+		stats.Lag = max(stats.Lag, last-min(cursor, last))
+	}
+	return stats
+}
+
+// Запись счетчиков доставки событий подписчикам в текстовом формате Prometheus (или OpenMetrics)
+func WritePrometheus(w io.Writer, webhooks []*Webhook, openMetrics bool) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	counter := func(name, help string, value func(*Webhook) uint64) {
+		family := name + "_total"
+		if openMetrics {
+			family = name
+		}
+		printf("# HELP %s %s\n", family, help)
+		printf("# TYPE %s counter\n", family)
+		for _, webhook := range webhooks {
+			printf("%s_total{webhook=%q} %d\n", name, webhook.config.Name, value(webhook))
+		}
+	}
+	counter("octet_webhook_delivered", "Доставленные подписчику события.",
+		func(webhook *Webhook) uint64 { return webhook.delivered.Load() })
+	counter("octet_webhook_retries", "Повторные попытки доставки событий подписчику.",
+		func(webhook *Webhook) uint64 { return webhook.retries.Load() })
+	counter("octet_webhook_dead_lettered", "События, доставка которых подписчику прекращена.",
+		func(webhook *Webhook) uint64 { return webhook.deadLettered.Load() })
+
+	printf("# HELP octet_webhook_lag Количество событий, ожидающих доставки подписчику.\n")
+	printf("# TYPE octet_webhook_lag gauge\n")
+	for _, webhook := range webhooks {
+		printf("octet_webhook_lag{webhook=%q} %d\n", webhook.config.Name, webhook.Stats().Lag)
+	}
+	printf("# HELP octet_webhook_in_flight Отправляемые подписчику в данный момент события.\n")
+	printf("# TYPE octet_webhook_in_flight gauge\n")
+	for _, webhook := range webhooks {
+		printf("octet_webhook_in_flight{webhook=%q} %d\n", webhook.config.Name, max(webhook.inFlight.Load(), 0))
+	}
+	printf("# HELP octet_webhook_request_duration_seconds Длительность запросов к подписчику.\n")
+	printf("# TYPE octet_webhook_request_duration_seconds summary\n")
+	for _, webhook := range webhooks {
+		seconds := time.Duration(webhook.requestNanos.Load()).Seconds()
+		printf("octet_webhook_request_duration_seconds_sum{webhook=%q} %g\n", webhook.config.Name, seconds)
+		printf("octet_webhook_request_duration_seconds_count{webhook=%q} %d\n", webhook.config.Name, webhook.requests.Load())
+	}
+	return err
+}
+
+// Номер события, до которого (включительно) все события обработаны всеми очередями
+func (w *Webhook) Processed() uint64 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return slices.Min(w.cursors)
+}
+
+// Повторная доставка всех событий с номером больше seq
+func (w *Webhook) Rewind(seq uint64) {
+	w.mutex.Lock()
+	for i := range w.cursors {
+		w.cursors[i] = min(w.cursors[i], seq)
+	}
+	w.epoch++
+	close(w.rewound)
+	w.rewound = make(chan struct{})
+	w.mutex.Unlock()
+
+	w.saveCursors()
+	w.logger.Info("Очереди подписчика перемотаны для повторной доставки", zap.Uint64("after_seq", seq))
+}
+
+// Имя подписчика
+func (w *Webhook) Name() string {
+	return w.config.Name
+}
+
+// Обработка одной очереди
+func (w *Webhook) run(ctx context.Context, worker int) {
+	defer w.wg.Done()
+
+	for {
+		// Канал запрашиваем до чтения событий, чтобы не пропустить добавленные между ними
+		wait := w.outbox.Wait()
+		cursor, epoch, rewound := w.cursor(worker)
+		batch := w.outbox.Since(cursor, deliveryBatchSize)
+		if len(batch) == 0 {
+			select {
+			case <-wait:
+				continue
+			case <-rewound:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for _, event := range batch {
+			if w.worker(event.Uuid) == worker {
+				if !w.deliver(ctx, event) {
+					return
+				}
+			}
+			// После перемотки продолжаем с нового положения
+			if !w.setCursor(worker, event.Seq, epoch) {
+				break
+			}
+		}
+		w.saveCursors()
+	}
+}
+
+// Доставка события с повторными попытками.
+// Возвращает false, если доставка прервана остановкой.
+func (w *Webhook) deliver(ctx context.Context, event Event) bool {
+	body, err := json.Marshal(event)
+	if err != nil {
+		w.deadLetter(event, err)
+		return true
+	}
+
+	backoff := w.config.RetryInitial
+	for attempt := 1; ; attempt++ {
+		err := w.send(ctx, event, body)
+		if err == nil {
+			w.delivered.Add(1)
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		w.mutex.Lock()
+		w.lastError = err.Error()
+		w.mutex.Unlock()
+		if attempt >= w.config.MaxAttempts {
+			w.deadLetter(event, err)
+			return true
+		}
+
+		w.logger.Debug("Не удалось доставить событие, повторная попытка",
+			zap.Uint64("seq", event.Seq), zap.Int("attempt", attempt), zap.Error(err))
+		w.retries.Add(1)
+		delay := backoff
+		var status *statusError
+		if errors.As(err, &status) && status.retryAfter > delay {
+			delay = min(status.retryAfter, w.config.RetryMax)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false
+		}
+		backoff = min(2*backoff, w.config.RetryMax)
+	}
+}
+
+// Отправка события подписчику
+func (w *Webhook) send(ctx context.Context, event Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Octet-Event-Seq", strconv.FormatUint(event.Seq, 10))
+	if len(event.TraceContext.Parent) != 0 {
+		req.Header.Set("traceparent", event.TraceContext.Parent)
+		if len(event.TraceContext.State) != 0 {
+			req.Header.Set("tracestate", event.TraceContext.State)
+		}
+	}
+	if len(w.config.Secret) != 0 {
+		mac := hmac.New(sha256.New, []byte(w.config.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Octet-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	w.inFlight.Add(1)
+	start := time.Now()
+	defer func() {
+		w.inFlight.Add(-1)
+		w.requests.Add(1)
+		w.requestNanos.Add(uint64(time.Since(start)))
+	}()
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := &statusError{status: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			err.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return err
+	}
+	return nil
+}
+
+// Задержка из заголовка Retry-After (количество секунд или дата), 0 - не указана
+func parseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// Перенос события, которое не удалось доставить, в dead-letter файл подписчика
+func (w *Webhook) deadLetter(event Event, cause error) {
+	w.deadLettered.Add(1)
+	w.logger.Error("Доставка события прекращена", zap.Uint64("seq", event.Seq),
+		zap.String("uuid", event.Uuid), zap.Error(cause))
+
+	entry := struct {
+		Event
+		Error string `json:"error"`
+	}{event, cause.Error()}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		path := filepath.Join(w.outbox.Dir(), w.config.Name+".dead.ndjson")
+		var file *os.File
+		file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err == nil {
+			_, err = file.Write(append(line, '\n'))
+			file.Close()
+		}
+	}
+	if err != nil {
+		w.logger.Error("Не удалось записать событие в dead-letter файл", zap.Error(err))
+	}
+}
+
+// Номер очереди для UUID
+func (w *Webhook) worker(uuid string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(uuid))
+	return int(hash.Sum32() % uint32(w.config.Workers))
+}
+
+// Положение очереди и признаки ее перемотки
+func (w *Webhook) cursor(worker int) (uint64, uint64, <-chan struct{}) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.cursors[worker], w.epoch, w.rewound
+}
+
+// Продвижение очереди, если она не была перемотана после чтения положения
+func (w *Webhook) setCursor(worker int, seq, epoch uint64) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.epoch != epoch {
+		return false
+	}
+	w.cursors[worker] = seq
+	return true
+}
+
+// Путь к файлу положения очередей
+func (w *Webhook) cursorsPath() string {
+	return filepath.Join(w.outbox.Dir(), w.config.Name+".cursors.json")
+}
+
+// Чтение положения очередей
+func (w *Webhook) loadCursors() error {
+	w.cursors = make([]uint64, w.config.Workers)
+
+	data, err := os.ReadFile(w.cursorsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("не удалось прочитать положение очередей подписчика %q: %w", w.config.Name, err)
+	}
+	var saved webhookCursors
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("не удалось прочитать положение очередей подписчика %q: %w", w.config.Name, err)
+	}
+	if len(saved.Cursors) == len(w.cursors) {
+		copy(w.cursors, saved.Cursors)
+		return nil
+	}
+	// Количество очередей изменилось: продолжаем с наименьшего положения,
+	// часть событий может быть доставлена повторно
+	if len(saved.Cursors) != 0 {
+		lowest := saved.Cursors[0]
+		for _, cursor := range saved.Cursors {
+			lowest = min(lowest, cursor)
+		}
+		for i := range w.cursors {
+			w.cursors[i] = lowest
+		}
+	}
+	return nil
+}
+
+// Сохранение положения очередей
+func (w *Webhook) saveCursors() {
+	w.saveMutex.Lock()
+	defer w.saveMutex.Unlock()
+
+	w.mutex.Lock()
+	data, err := json.Marshal(webhookCursors{Cursors: w.cursors})
+	w.mutex.Unlock()
+	if err == nil {
+		path := w.cursorsPath()
+		if err = os.WriteFile(path+".tmp", data, 0600); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		w.logger.Error("Не удалось сохранить положение очередей подписчика", zap.Error(err))
+	}
+}
@@ -0,0 +1,171 @@
+package metastore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// Имя хранилища метаданных в локальном файле
+const BackendBolt = "bbolt"
+
+// Интервал удаления записей с истекшим сроком
+const boltSweepInterval = time.Minute
+
+// Bucket с записями
+var boltBucket = []byte("entries")
+
+// Хранилище метаданных в локальном файле bbolt. Запись хранится как срок (Unix-время в наносекундах,
+// 8 байт big-endian) и значение. Записи с истекшим сроком удаляются при открытии и периодически.
+type BoltStore struct {
+	db     *bolt.DB
+	logger *zap.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Открытие (или создание) файла хранилища с удалением записей, срок которых истек, пока сервер был остановлен
+func OpenBolt(path string, logger *zap.Logger) (*BoltStore, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("не указан файл хранилища метаданных")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию хранилища метаданных: %w", err)
+	}
+	// Файл открыт другим процессом (например, вторым экземпляром сервера) - не ждем бесконечно
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть хранилище метаданных %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("не удалось подготовить хранилище метаданных %s: %w", path, err)
+	}
+
+	store := &BoltStore{db: db, logger: logger, stop: make(chan struct{})}
+	removed, err := store.sweep()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("не удалось удалить устаревшие записи хранилища метаданных: %w", err)
+	}
+	logger.Info("Хранилище метаданных восстановлено", zap.String("file", path),
+		zap.Int("entries", store.Len()), zap.Int("expired", removed))
+
+	store.wg.Add(1)
+	go store.sweepLoop()
+	return store, nil
+}
+
+// Значение записи (ErrNotFound - записи нет или ее срок истек)
+func (s *BoltStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(key))
+		if data == nil || boltExpired(data, time.Now()) {
+			return ErrNotFound
+		}
+		// Данные действительны только внутри транзакции
+		value = append([]byte(nil), data[8:]...)
+		return nil
+	})
+	return value, err
+}
+
+// Сохранение записи до момента expires
+func (s *BoltStore) Set(ctx context.Context, key string, value []byte, expires time.Time) error {
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expires.UnixNano()))
+	copy(data[8:], value)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), data)
+	})
+}
+
+// Удаление записи
+func (s *BoltStore) Delete(ctx context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+// Количество записей в файле, включая еще не удаленные записи с истекшим сроком
+func (s *BoltStore) Len() int {
+	count := -1
+	s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(boltBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Имя хранилища для логов и состояния сервера
+func (s *BoltStore) Backend() string {
+	return BackendBolt
+}
+
+// Остановка периодического удаления и закрытие файла
+func (s *BoltStore) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// Периодическое удаление записей с истекшим сроком
+func (s *BoltStore) sweepLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(boltSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.sweep(); err != nil {
+			s.logger.Warn("Не удалось удалить устаревшие записи хранилища метаданных", zap.Error(err))
+		}
+	}
+}
+
+// Удаление записей с истекшим сроком. Возвращает количество удаленных записей.
+func (s *BoltStore) sweep() (int, error) {
+	removed := 0
+	now := time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+		for key, data := cursor.First(); key != nil; {
+			if !boltExpired(data, now) {
+				key, data = cursor.Next()
+				continue
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			removed++
+			// После удаления курсор указывает на следующую запись
+			key, data = cursor.Seek(key)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Истек ли срок записи (записи без срока считаются поврежденными и истекшими)
+func boltExpired(data []byte, now time.Time) bool {
+	if len(data) < 8 {
+		return true
+	}
+	return now.UnixNano() >= int64(binary.BigEndian.Uint64(data))
+}
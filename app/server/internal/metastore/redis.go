@@ -0,0 +1,246 @@
+package metastore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Имя хранилища метаданных в Redis
+const BackendRedis = "redis"
+
+// Максимальное количество простаивающих соединений с Redis
+const redisMaxIdle = 8
+
+// Параметры хранилища метаданных в Redis
+type RedisConfig struct {
+	Address  string        // Адрес Redis (host:port)
+	Password string        // Пароль (пусто - без аутентификации)
+	DB       int           // Номер базы
+	Prefix   string        // Префикс ключей (для нескольких серверов с общим Redis)
+	Timeout  time.Duration // Таймаут подключения и команд
+}
+
+// Хранилище метаданных в Redis. Срок записей отслеживает сам Redis, поэтому после перезапуска
+// сервера действительны все оставшиеся записи, а несколько серверов за балансировщиком
+// могут использовать общие записи. Используются только GET, SET PX и DEL, поэтому команды
+// передаются по протоколу RESP напрямую, без клиентской библиотеки.
+type RedisStore struct {
+	config RedisConfig
+
+	mutex  sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// Соединение с Redis
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Ошибка, возвращенная Redis (соединение остается пригодным)
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Подключение к Redis с проверкой доступности
+func OpenRedis(ctx context.Context, config RedisConfig, logger *zap.Logger) (*RedisStore, error) {
+	if len(config.Address) == 0 {
+		return nil, fmt.Errorf("не указан адрес Redis для хранилища метаданных")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Second
+	}
+	store := &RedisStore{config: config}
+	if _, err := store.do(ctx, "PING"); err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к Redis %s: %w", config.Address, err)
+	}
+	logger.Info("Подключено хранилище метаданных", zap.String("backend", BackendRedis),
+		zap.String("address", config.Address), zap.Int("db", config.DB))
+	return store, nil
+}
+
+// Значение записи (ErrNotFound - записи нет или ее срок истек)
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.do(ctx, "GET", s.config.Prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// Сохранение записи с TTL до момента expires (запись с прошедшим сроком удаляется)
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, expires time.Time) error {
+	ttl := time.Until(expires).Milliseconds()
+	if ttl <= 0 {
+		return s.Delete(ctx, key)
+	}
+	_, err := s.do(ctx, "SET", s.config.Prefix+key, string(value), "PX", strconv.FormatInt(ttl, 10))
+	return err
+}
+
+// Удаление записи
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", s.config.Prefix+key)
+	return err
+}
+
+// Количество записей в Redis неизвестно: база может быть общей с другими приложениями
+func (s *RedisStore) Len() int {
+	return -1
+}
+
+// Имя хранилища для логов и состояния сервера
+func (s *RedisStore) Backend() string {
+	return BackendRedis
+}
+
+// Закрытие простаивающих соединений
+func (s *RedisStore) Close() error {
+	s.mutex.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.closed = true
+	s.mutex.Unlock()
+	for _, conn := range idle {
+		conn.conn.Close()
+	}
+	return nil
+}
+
+// Выполнение команды. Возвращает значение ответа (nil - пустой ответ Redis).
+func (s *RedisStore) do(ctx context.Context, args ...string) ([]byte, error) {
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, err := conn.command(ctx, s.config.Timeout, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// Ответ мог быть прочитан не полностью - соединение больше не используется
+		conn.conn.Close()
+		return nil, err
+	}
+	s.release(conn)
+	return value, err
+}
+
+// Простаивающее или новое соединение
+func (s *RedisStore) acquire(ctx context.Context) (*redisConn, error) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, errors.New("хранилище метаданных закрыто")
+	}
+	if n := len(s.idle); n != 0 {
+		conn := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mutex.Unlock()
+		return conn, nil
+	}
+	s.mutex.Unlock()
+
+	dialer := net.Dialer{Timeout: s.config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.config.Address)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+	if len(s.config.Password) != 0 {
+		if _, err := conn.command(ctx, s.config.Timeout, "AUTH", s.config.Password); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if s.config.DB != 0 {
+		if _, err := conn.command(ctx, s.config.Timeout, "SELECT", strconv.Itoa(s.config.DB)); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Возврат соединения в список простаивающих
+func (s *RedisStore) release(conn *redisConn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed || len(s.idle) >= redisMaxIdle {
+		conn.conn.Close()
+		return
+	}
+	s.idle = append(s.idle, conn)
+}
+
+// Отправка команды и чтение ответа
+func (c *redisConn) command(ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	c.conn.SetDeadline(deadline)
+
+	// Команда передается массивом bulk-строк: *<n>\r\n$<len>\r\n<arg>\r\n...
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+// Чтение ответа: простая строка, ошибка, число или bulk-строка
+func (c *redisConn) reply() ([]byte, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("некорректный ответ Redis: %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+', ':':
+		return []byte(payload), nil
+	case '-':
+		return nil, redisError(payload)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный ответ Redis: %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	default:
+		return nil, fmt.Errorf("неожиданный ответ Redis: %q", line)
+	}
+}
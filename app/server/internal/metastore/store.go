@@ -0,0 +1,27 @@
+package metastore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Запись не найдена или ее срок истек
+var ErrNotFound = errors.New("запись не найдена")
+
+// Хранилище метаданных сервера (сохраненные ответы на запросы с ключом идемпотентности и т.п.),
+// которые должны переживать перезапуск. Ключи разных потребителей разделяются префиксом.
+type Store interface {
+	// Значение записи (ErrNotFound - записи нет или ее срок истек)
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Сохранение записи до момента expires
+	Set(ctx context.Context, key string, value []byte, expires time.Time) error
+	// Удаление записи
+	Delete(ctx context.Context, key string) error
+	// Количество хранимых записей (-1 - неизвестно)
+	Len() int
+	// Имя хранилища для логов и состояния сервера
+	Backend() string
+	// Закрытие хранилища
+	Close() error
+}
@@ -0,0 +1,247 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Флаг продолжения (старший бит заголовка): фрейм является частью составного
+// сообщения и за ним следует как минимум еще один фрейм
+const continuationFlag = uint32(1) << 31
+
+// Максимальный размер полезной нагрузки одного фрейма
+// (младшие 31 бит заголовка отводятся под длину)
+const MaxChunkSize = int(continuationFlag - 1)
+
+// Размер полезной нагрузки фрейма по умолчанию (1 МБ),
+// сообщения большего размера разбиваются на несколько фреймов
+const DefaultChunkSize = 1 << 20
+
+// Максимальный размер сообщения по умолчанию (64 МБ)
+const DefaultMaxFrameSize = 64 << 20
+
+// Ошибка превышения максимального размера сообщения
+var ErrFrameTooLarge = errors.New("размер фрейма превышает допустимый")
+
+// Разбор заголовка фрейма на длину полезной нагрузки и признак продолжения
+func parseHeader(header []byte) (length uint32, more bool) {
+	value := binary.LittleEndian.Uint32(header)
+	return value &^ continuationFlag, value&continuationFlag != 0
+}
+
+// Формирование заголовка фрейма
+func putHeader(header []byte, length int, more bool) {
+	value := uint32(length)
+	if more {
+		value |= continuationFlag
+	}
+	binary.LittleEndian.PutUint32(header, value)
+}
+
+// FrameWriter разбивает поток данных одного сообщения на фреймы.
+// Все фреймы, кроме последнего, отправляются с флагом продолжения,
+// последний фрейм отправляется при вызове Close.
+// Буфер фрейма берется из пула и возвращается в него при Close,
+// поэтому после Close FrameWriter нельзя использовать.
+type FrameWriter struct {
+	writer    io.Writer
+	chunkSize int
+	pooled    *[]byte // Буфер из пула (nil после Close)
+	buf       []byte  // [заголовок][накопленная полезная нагрузка]
+}
+
+// Создание нового FrameWriter
+func NewFrameWriter(writer io.Writer, chunkSize int) *FrameWriter {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		chunkSize = DefaultChunkSize
+	}
+	pooled := getChunk()
+	return &FrameWriter{
+		writer:    writer,
+		chunkSize: chunkSize,
+		pooled:    pooled,
+		buf:       *pooled,
+	}
+}
+
+// Запись части сообщения
+func (fw *FrameWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		space := fw.chunkSize - (len(fw.buf) - headerSize)
+		if len(p) <= space {
+			fw.buf = append(fw.buf, p...)
+			written += len(p)
+			break
+		}
+
+		// Фрейм заполнен, а данные еще остаются - отправляем промежуточный фрейм
+		fw.buf = append(fw.buf, p[:space]...)
+		written += space
+		p = p[space:]
+		if err := fw.flush(true); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Отправка последнего фрейма сообщения и возврат буфера в пул
+func (fw *FrameWriter) Close() error {
+	err := fw.flush(false)
+	*fw.pooled = fw.buf
+	putChunk(fw.pooled)
+	fw.pooled, fw.buf = nil, nil
+	return err
+}
+
+// Отправка сообщения, сериализованного в buf после зарезервированного места под заголовок.
+// Сообщение размером до chunkSize отправляется одной записью, большее - фреймами
+// с заголовками из предварительно выделенного массива, без копирования полезной нагрузки.
+func writeMessage(writer io.Writer, buf []byte, chunkSize int) error {
+	payload := buf[headerSize:]
+	if len(payload) <= chunkSize {
+		putHeader(buf[:headerSize], len(payload), false)
+		if _, err := writer.Write(buf); err != nil {
+			return fmt.Errorf("ошибка записи фрейма: %w", err)
+		}
+		return nil
+	}
+
+	var header [headerSize]byte
+	for len(payload) > 0 {
+		chunk := payload[:min(len(payload), chunkSize)]
+		payload = payload[len(chunk):]
+		putHeader(header[:], len(chunk), len(payload) > 0)
+		frame := net.Buffers{header[:], chunk}
+		if _, err := frame.WriteTo(writer); err != nil {
+			return fmt.Errorf("ошибка записи фрейма: %w", err)
+		}
+	}
+	return nil
+}
+
+// Отправка накопленных данных одним фреймом
+func (fw *FrameWriter) flush(more bool) error {
+	putHeader(fw.buf[:headerSize], len(fw.buf)-headerSize, more)
+	if _, err := fw.writer.Write(fw.buf); err != nil {
+		return fmt.Errorf("ошибка записи фрейма: %w", err)
+	}
+	fw.buf = fw.buf[:headerSize]
+	return nil
+}
+
+// FrameReader читает полезную нагрузку одного сообщения, собирая ее
+// из последовательности фреймов. После последнего фрейма возвращает io.EOF.
+type FrameReader struct {
+	reader    io.Reader
+	maxSize   int64  // Максимальный суммарный размер сообщения (0 - без ограничений)
+	total     int64  // Суммарный размер уже заявленных фреймов
+	remaining uint32 // Непрочитанный остаток текущего фрейма
+	last      bool   // Текущий фрейм является последним фреймом сообщения
+	err       error  // Ошибка чтения из нижележащего Reader
+}
+
+// Создание нового FrameReader без ограничения размера сообщения
+func NewFrameReader(reader io.Reader) *FrameReader {
+	return &FrameReader{reader: reader}
+}
+
+// Создание нового FrameReader с ограничением размера сообщения
+func NewLimitedFrameReader(reader io.Reader, maxSize int64) *FrameReader {
+	return &FrameReader{reader: reader, maxSize: maxSize}
+}
+
+// Чтение части сообщения
+func (fr *FrameReader) Read(p []byte) (int, error) {
+	if fr.err != nil {
+		return 0, fr.err
+	}
+
+	// Пропускаем пустые фреймы и переходим к следующему, пока сообщение не закончилось
+	for fr.remaining == 0 {
+		if fr.last {
+			return 0, io.EOF
+		}
+		if err := fr.readHeader(); err != nil {
+			fr.err = err
+			return 0, err
+		}
+	}
+
+	if uint32(len(p)) > fr.remaining {
+		p = p[:fr.remaining]
+	}
+	n, err := fr.reader.Read(p)
+	fr.remaining -= uint32(n)
+	if err == io.EOF && fr.remaining == 0 {
+		// Фрейм дочитан полностью, конец потока будет обработан при чтении следующего заголовка
+		err = nil
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		fr.err = fmt.Errorf("ошибка чтения данных фрейма: %w", err)
+		return n, fr.err
+	}
+	return n, nil
+}
+
+// Ошибка чтения из нижележащего Reader (nil, если чтение прошло успешно)
+func (fr *FrameReader) Err() error {
+	return fr.err
+}
+
+// Чтение заголовка очередного фрейма
+func (fr *FrameReader) readHeader() error {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(fr.reader, header[:]); err != nil {
+		return fmt.Errorf("ошибка чтения длины фрейма: %w", err)
+	}
+	length, more := parseHeader(header[:])
+
+	// Проверяем размер до чтения данных, чтобы поврежденный заголовок
+	// не заставил читать гигабайты из сокета
+	fr.total += int64(length)
+	if fr.maxSize > 0 && fr.total > fr.maxSize {
+		return fmt.Errorf("%w: %d > %d байт", ErrFrameTooLarge, fr.total, fr.maxSize)
+	}
+
+	fr.remaining = length
+	fr.last = !more
+	return nil
+}
+
+// Заголовок heartbeat-фрейма: пустой фрейм с флагом продолжения.
+// FrameWriter никогда не формирует такой фрейм, поэтому между сообщениями
+// он однозначно распознается как heartbeat, на который получатель отвечает тем же фреймом.
+const heartbeatHeader = continuationFlag
+
+// Ошибка получения фрейма, не соответствующего ожидаемому
+var ErrUnexpectedFrame = errors.New("получен неожиданный фрейм")
+
+// Отправка heartbeat-фрейма
+func WriteHeartbeat(writer io.Writer) error {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint32(header[:], heartbeatHeader)
+	if _, err := writer.Write(header[:]); err != nil {
+		return fmt.Errorf("ошибка записи heartbeat-фрейма: %w", err)
+	}
+	return nil
+}
+
+// Чтение ответного heartbeat-фрейма
+func ReadHeartbeat(reader io.Reader) error {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return fmt.Errorf("ошибка чтения heartbeat-фрейма: %w", err)
+	}
+	if value := binary.LittleEndian.Uint32(header[:]); value != heartbeatHeader {
+		return fmt.Errorf("%w: ожидался heartbeat, получен заголовок %#08x", ErrUnexpectedFrame, value)
+	}
+	return nil
+}
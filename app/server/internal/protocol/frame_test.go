@@ -0,0 +1,242 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Заголовок фрейма в разобранном виде
+type testFrame struct {
+	length uint32
+	more   bool
+}
+
+// Разбор потока на заголовки фреймов и объединенную полезную нагрузку
+func splitFrames(t *testing.T, data []byte) ([]testFrame, []byte) {
+	t.Helper()
+	var frames []testFrame
+	var payload []byte
+	for len(data) > 0 {
+		if len(data) < headerSize {
+			t.Fatalf("неполный заголовок фрейма: %d байт", len(data))
+		}
+		length, more := parseHeader(data[:headerSize])
+		data = data[headerSize:]
+		if uint32(len(data)) < length {
+			t.Fatalf("неполный фрейм: %d из %d байт", len(data), length)
+		}
+		frames = append(frames, testFrame{length, more})
+		payload = append(payload, data[:length]...)
+		data = data[length:]
+	}
+	return frames, payload
+}
+
+// Заголовок фрейма
+func frameHeader(frame testFrame) []byte {
+	header := make([]byte, headerSize)
+	putHeader(header, int(frame.length), frame.more)
+	return header
+}
+
+// Поток из фреймов с указанными заголовками и полезной нагрузкой из символов 'a'
+func buildFrames(frames ...testFrame) []byte {
+	var buf bytes.Buffer
+	for _, frame := range frames {
+		buf.Write(frameHeader(frame))
+		buf.WriteString(strings.Repeat("a", int(frame.length)))
+	}
+	return buf.Bytes()
+}
+
+// Reader, возвращающий данные частями указанного размера
+type chunkedReader struct {
+	data []byte
+	size int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.size)], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestFrameWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int
+		writes    []int // Размеры последовательных вызовов Write
+		frames    []testFrame
+	}{
+		{"empty", 4, nil, []testFrame{{0, false}}},
+		{"single", 4, []int{3}, []testFrame{{3, false}}},
+		// Заполненный фрейм отправляется, только когда появляются следующие данные,
+		// поэтому сообщение никогда не заканчивается пустым фреймом
+		{"exact", 4, []int{4}, []testFrame{{4, false}}},
+		{"continuation", 4, []int{10}, []testFrame{{4, true}, {4, true}, {2, false}}},
+		{"multiple writes", 4, []int{3, 3, 4}, []testFrame{{4, true}, {4, true}, {2, false}}},
+		{"chunk multiple", 4, []int{8}, []testFrame{{4, true}, {4, false}}},
+		{"default chunk", 0, []int{DefaultChunkSize + 1}, []testFrame{{DefaultChunkSize, true}, {1, false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var want []byte
+			fw := NewFrameWriter(&out, tt.chunkSize)
+			for i, size := range tt.writes {
+				p := bytes.Repeat([]byte{byte('a' + i)}, size)
+				want = append(want, p...)
+				if n, err := fw.Write(p); err != nil || n != size {
+					t.Fatalf("Write(%d) = %d, %v", size, n, err)
+				}
+			}
+			if err := fw.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			frames, payload := splitFrames(t, out.Bytes())
+			if len(frames) != len(tt.frames) {
+				t.Fatalf("фреймы = %v, ожидалось %v", frames, tt.frames)
+			}
+			for i := range frames {
+				if frames[i] != tt.frames[i] {
+					t.Fatalf("фреймы = %v, ожидалось %v", frames, tt.frames)
+				}
+			}
+			if !bytes.Equal(payload, want) {
+				t.Fatalf("полезная нагрузка отличается от записанных данных")
+			}
+		})
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+		want    int   // Ожидаемый размер сообщения
+		err     error // Ожидаемая ошибка (проверяется errors.Is)
+	}{
+		{"single", buildFrames(testFrame{5, false}), 0, 5, nil},
+		{"empty", buildFrames(testFrame{0, false}), 0, 0, nil},
+		{"continuation", buildFrames(testFrame{3, true}, testFrame{3, true}, testFrame{2, false}), 0, 8, nil},
+		{"empty continuation", buildFrames(testFrame{3, true}, testFrame{0, true}, testFrame{2, false}), 0, 5, nil},
+		{"limit exact", buildFrames(testFrame{3, true}, testFrame{2, false}), 5, 5, nil},
+		{"limit exceeded", buildFrames(testFrame{3, true}, testFrame{3, false}), 5, 0, ErrFrameTooLarge},
+		// Размер проверяется по заголовку до чтения данных фрейма
+		{"limit header only", append(buildFrames(testFrame{4, true}), frameHeader(testFrame{2, false})...), 5, 0, ErrFrameTooLarge},
+		{"truncated header", buildFrames(testFrame{3, true})[:headerSize+3+2], 0, 0, io.ErrUnexpectedEOF},
+		{"truncated payload", buildFrames(testFrame{5, false})[:headerSize+3], 0, 0, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		for _, chunk := range []int{1, 3, len(tt.data) + 1} {
+			reader := NewLimitedFrameReader(&chunkedReader{data: tt.data, size: chunk}, tt.maxSize)
+			got, err := io.ReadAll(reader)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("%s/%d: ошибка = %v, ожидалось %v", tt.name, chunk, err, tt.err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s/%d: ошибка = %v", tt.name, chunk, err)
+				continue
+			}
+			if len(got) != tt.want {
+				t.Errorf("%s/%d: размер = %d, ожидалось %d", tt.name, chunk, len(got), tt.want)
+			}
+		}
+	}
+}
+
+func TestFrameReaderStopsAtMessageEnd(t *testing.T) {
+	// Следующее сообщение в том же потоке не читается FrameReader текущего сообщения
+	data := append(buildFrames(testFrame{3, true}, testFrame{1, false}), buildFrames(testFrame{2, false})...)
+	stream := bytes.NewReader(data)
+	for _, want := range []int{4, 2} {
+		got, err := io.ReadAll(NewFrameReader(stream))
+		if err != nil || len(got) != want {
+			t.Fatalf("сообщение = %d байт, %v, ожидалось %d байт", len(got), err, want)
+		}
+	}
+	if stream.Len() != 0 {
+		t.Fatalf("остались непрочитанные данные: %d байт", stream.Len())
+	}
+}
+
+func TestWriteFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		frames int
+	}{
+		{"small", 10, 1},
+		{"continuation", 3*DefaultChunkSize + 10, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := strings.Repeat("x", tt.size)
+			var out bytes.Buffer
+			if err := WriteFrame(&out, NewInsertRequest("id", data)); err != nil {
+				t.Fatal(err)
+			}
+
+			frames, _ := splitFrames(t, out.Bytes())
+			if len(frames) != tt.frames {
+				t.Fatalf("фреймов = %d, ожидалось %d", len(frames), tt.frames)
+			}
+			for i, frame := range frames {
+				if frame.more != (i < len(frames)-1) {
+					t.Fatalf("флаг продолжения фрейма %d = %v", i, frame.more)
+				}
+			}
+
+			payload, err := io.ReadAll(NewLimitedFrameReader(&out, DefaultMaxFrameSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var request Request
+			if err := json.Unmarshal(payload, &request); err != nil {
+				t.Fatal(err)
+			}
+			if request.Command != CommandInsert || request.Params.Data != data {
+				t.Fatalf("запрос после разбора отличается от отправленного")
+			}
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteHeartbeat(&out); err != nil {
+		t.Fatal(err)
+	}
+	frames, _ := splitFrames(t, out.Bytes())
+	if len(frames) != 1 || frames[0] != (testFrame{0, true}) {
+		t.Fatalf("heartbeat = %v, ожидался пустой фрейм с флагом продолжения", frames)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"heartbeat", out.Bytes(), nil},
+		{"message", buildFrames(testFrame{0, false}), ErrUnexpectedFrame},
+		{"continuation", buildFrames(testFrame{1, true}), ErrUnexpectedFrame},
+		{"truncated", out.Bytes()[:2], io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		if err := ReadHeartbeat(bytes.NewReader(tt.data)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: ошибка = %v, ожидалось %v", tt.name, err, tt.err)
+		}
+	}
+}
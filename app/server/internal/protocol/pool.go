@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Буферы больше этого размера не возвращаются в пул,
+// чтобы редкие большие сообщения не удерживали память
+const maxPooledBufferSize = 4 * DefaultChunkSize
+
+// Буферы сериализации и чтения сообщений
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Буферы фреймов FrameWriter: [заголовок][полезная нагрузка фрейма]
+var chunkPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, headerSize, headerSize+DefaultChunkSize)
+		return &buf
+	},
+}
+
+var (
+	requestPool  = sync.Pool{New: func() any { return new(Request) }}
+	responsePool = sync.Pool{New: func() any { return new(Response) }}
+)
+
+// Получение пустого буфера из пула
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// Возврат буфера в пул
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// Получение буфера фрейма из пула
+func getChunk() *[]byte {
+	buf := chunkPool.Get().(*[]byte)
+	*buf = (*buf)[:headerSize]
+	return buf
+}
+
+// Возврат буфера фрейма в пул
+func putChunk(buf *[]byte) {
+	if cap(*buf) > headerSize+maxPooledBufferSize {
+		return
+	}
+	chunkPool.Put(buf)
+}
+
+// Получение пустого запроса из пула. Запросы New*Request создаются из пула,
+// после отправки запрос можно вернуть в пул вызовом ReleaseRequest.
+func AcquireRequest() *Request {
+	return requestPool.Get().(*Request)
+}
+
+// Возврат запроса в пул. После вызова запрос нельзя использовать.
+func ReleaseRequest(req *Request) {
+	if req == nil {
+		return
+	}
+	*req = Request{}
+	requestPool.Put(req)
+}
+
+// Возврат ответа, полученного ReadFrame, в пул. Значения полей (строки и срезы)
+// остаются действительными, но сам ответ после вызова нельзя использовать.
+func ReleaseResponse(resp *Response) {
+	if resp == nil {
+		return
+	}
+	*resp = Response{}
+	responsePool.Put(resp)
+}
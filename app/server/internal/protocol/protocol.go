@@ -1,10 +1,11 @@
 package protocol
 
 import (
-	"encoding/binary"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 )
 
@@ -17,6 +18,35 @@ const (
 	CommandUpdate CommandType = "update"
 	CommandRemove CommandType = "remove"
 	CommandPing   CommandType = "ping"
+	CommandStats  CommandType = "stats"
+	// Запуск сжатия хранилища и запрос его состояния
+	CommandCompact       CommandType = "compact"
+	CommandCompactStatus CommandType = "compact_status"
+	// Чтение журнала операций octet
+	CommandJournal CommandType = "journal"
+	// Выполнение нескольких операций insert/update/remove одним запросом
+	CommandBatch CommandType = "batch"
+	// Постраничное перечисление UUID строк хранилища
+	CommandList CommandType = "list"
+	// Проверка наличия строки без передачи данных
+	CommandExists CommandType = "exists"
+	// Размер и метаданные строки без передачи данных
+	CommandStat CommandType = "stat"
+	// Количество строк хранилища
+	CommandCount CommandType = "count"
+)
+
+type ErrorCode string
+
+// Коды ошибок в ответах C++ процесса (согласованы с octet)
+const (
+	ErrorCodeNotFound        ErrorCode = "NOT_FOUND"        // Строка с указанным UUID отсутствует
+	ErrorCodeInvalidUtf8     ErrorCode = "INVALID_UTF8"     // Данные не являются корректной строкой UTF-8
+	ErrorCodeStorageFull     ErrorCode = "STORAGE_FULL"     // Недостаточно места на диске для записи
+	ErrorCodeRetryLater      ErrorCode = "RETRY_LATER"      // Запрос временно не может быть выполнен (например, идет сжатие)
+	ErrorCodeVersionMismatch ErrorCode = "VERSION_MISMATCH" // Версия строки не совпадает с ожидаемой (update с expected_version)
+	ErrorCodeAlreadyExists   ErrorCode = "ALREADY_EXISTS"   // Строка с указанным UUID уже существует (insert с uuid)
+	ErrorCodeInternal        ErrorCode = "INTERNAL"         // Остальные ошибки
 )
 
 // Request представляет запрос к C++ процессу
@@ -24,6 +54,14 @@ type Request struct {
 	RequestId string           `json:"request_id"`
 	Command   CommandType      `json:"command"`
 	Params    AdditionalParams `json:"params"`
+	TraceContext
+}
+
+// Контекст трассировки W3C Trace Context внешнего HTTP запроса.
+// octet не интерпретирует его и только выводит в логи.
+type TraceContext struct {
+	Parent string `json:"traceparent,omitempty"`
+	State  string `json:"tracestate,omitempty"`
 }
 
 // Response представляет ответ от C++ процесса
@@ -32,38 +70,104 @@ type Response struct {
 	Success   bool             `json:"success"`
 	Params    AdditionalParams `json:"params"`
 	Error     string           `json:"error,omitempty"`
+	ErrorCode ErrorCode        `json:"error_code,omitempty"`
+	// Рекомендуемая задержка повтора в миллисекундах (при RETRY_LATER)
+	RetryAfterMs uint32 `json:"retry_after_ms,omitempty"`
 }
 
 // AdditionalParams содержит дополнительные данные для Request/Response
 type AdditionalParams struct {
 	Uuid string `json:"uuid,omitempty"`
 	Data string `json:"data,omitempty"`
+	// Запрос контрольной суммы сохраненных данных в ответе на insert/update
+	WithChecksum bool `json:"with_checksum,omitempty"`
+	// Срок хранения добавляемой строки в секундах (запрос insert, 0 - без ограничения)
+	TtlSeconds uint64 `json:"ttl_seconds,omitempty"`
+	// Ожидаемая версия обновляемой строки (запрос update, 0 - без проверки версии)
+	ExpectedVersion uint64 `json:"expected_version,omitempty"`
+	// Контрольная сумма сохраненных данных (CRC32 IEEE в шестнадцатеричном виде)
+	Checksum string `json:"checksum,omitempty"`
+	// Количество записей в хранилище (ответ на stats и count)
+	Count uint64 `json:"count,omitempty"`
+	// Состояние сжатия хранилища (ответ на compact/compact_status)
+	CompactionState   string `json:"compaction_state,omitempty"`
+	JournalSizeBefore uint64 `json:"journal_size_before,omitempty"` // Размер журнала до сжатия
+	JournalSizeAfter  uint64 `json:"journal_size_after,omitempty"`  // Размер журнала после сжатия
+	// Параметры запроса journal: записи начиная с момента since (ISO 8601, UTC, миллисекунды),
+	// без первых skip записей, не более limit записей
+	Since string `json:"since,omitempty"`
+	Skip  int    `json:"skip,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+	// UUID, после которого начинается страница list (пустая строка - с начала)
+	After string `json:"after,omitempty"`
+	// UUID строк в порядке возрастания (ответ на list)
+	Uuids []string `json:"uuids,omitempty"`
+	// Записи журнала (ответ на journal)
+	Journal []JournalEntry `json:"journal,omitempty"`
+	// Остались ли записи журнала или UUID, не вошедшие в ответ
+	More bool `json:"more,omitempty"`
+	// Операции запроса batch (выполняются octet по порядку)
+	Operations []BatchOperation `json:"operations,omitempty"`
+	// Результаты операций в порядке операций запроса (ответ на batch)
+	Results []BatchResult `json:"results,omitempty"`
+	// Размер данных строки в байтах, время ее добавления и последнего изменения
+	// (в формате JournalTimeFormat) и версия строки (ответ на stat, версия - также ответ на get)
+	Size       uint64 `json:"size,omitempty"`
+	CreatedAt  string `json:"created_at,omitempty"`
+	ModifiedAt string `json:"modified_at,omitempty"`
+	Version    uint64 `json:"version,omitempty"`
+	// Момент истечения срока хранения строки (ответ на stat, отсутствует для строк без срока)
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
-// Длина заголовка сообщения - 4 байта
-// (т.к. в качестве заголовока используем длину сообщения типом uint32)
-const headerSize = 4
+// Операция изменения данных в запросе batch
+type BatchOperation struct {
+	Command    CommandType `json:"command"` // insert, update или remove
+	Uuid       string      `json:"uuid,omitempty"`
+	Data       string      `json:"data,omitempty"`
+	TtlSeconds uint64      `json:"ttl_seconds,omitempty"` // Срок хранения добавляемой строки в секундах
+	// Ожидаемая версия обновляемой строки (0 - без проверки версии)
+	ExpectedVersion uint64 `json:"expected_version,omitempty"`
+}
 
-// Сериализация запроса в бинарный формат
-func Encode(request *Request) ([]byte, error) {
-	// Сериализуем запрос в JSON
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
-	}
+// Результат операции в ответе на batch
+type BatchResult struct {
+	Success   bool      `json:"success"`
+	Uuid      string    `json:"uuid,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode ErrorCode `json:"error_code,omitempty"`
+}
+
+// Запись журнала операций octet
+type JournalEntry struct {
+	Op   string `json:"op"` // insert, update, remove или checkpoint
+	Uuid string `json:"uuid"`
+	Time string `json:"time"`
+	Data string `json:"data,omitempty"`
+}
 
-	// Подготавливаем результирующий буфер
-	// [4 байта длины сообщения][JSON-сообщение]
-	messageLength := uint32(len(jsonData))
-	result := make([]byte, headerSize+len(jsonData))
+// Формат временных меток журнала octet
+const JournalTimeFormat = "2006-01-02T15:04:05.000Z"
 
-	// Записываем длину сообщения (в формате little endian)
-	binary.LittleEndian.PutUint32(result[:headerSize], messageLength)
+// Вычисление контрольной суммы данных в формате, используемом octet
+func Checksum(data string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(data)))
+}
 
-	// Копируем JSON-данные
-	copy(result[headerSize:], jsonData)
+// Длина заголовка фрейма - 4 байта
+// (т.к. в качестве заголовока используем длину фрейма типом uint32,
+// старший бит которого является флагом продолжения)
+const headerSize = 4
 
-	return result, nil
+// Сериализация запроса в бинарный формат
+// [4 байта длины фрейма][часть JSON-сообщения]...
+func Encode(request *Request) ([]byte, error) {
+	var result bytes.Buffer
+	if err := WriteFrame(&result, request); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
 }
 
 // Десериализация ответа из бинарного формата
@@ -72,17 +176,12 @@ func Decode(data []byte) (*Response, error) {
 		return nil, errors.New("недостаточно данных для чтения заголовка")
 	}
 
-	// Читаем длину сообщения
-	messageLength := binary.LittleEndian.Uint32(data[:headerSize])
-
-	// Проверяем, что у нас достаточно данных
-	if len(data) < headerSize+int(messageLength) {
+	// Собираем JSON-сообщение из всех фреймов
+	jsonData, err := io.ReadAll(NewFrameReader(bytes.NewReader(data)))
+	if err != nil {
 		return nil, errors.New("недостаточно данных для чтения сообщения")
 	}
 
-	// Читаем JSON-данные
-	jsonData := data[headerSize : headerSize+messageLength]
-
 	// Десериализуем JSON
 	var response Response
 	if err := json.Unmarshal(jsonData, &response); err != nil {
@@ -92,97 +191,195 @@ func Decode(data []byte) (*Response, error) {
 	return &response, nil
 }
 
-// Чтение одного фрейма из Reader
+// Чтение одного сообщения (одного или нескольких фреймов) из Reader
+// с ограничением размера по умолчанию
 func ReadFrame(reader io.Reader) (*Response, error) {
-	// Чтение длины сообщения
-	lengthBuf := make([]byte, 4)
-	if _, err := io.ReadFull(reader, lengthBuf); err != nil {
-		return nil, fmt.Errorf("ошибка чтения длины фрейма: %w", err)
-	}
-
-	// Декодирование длины сообщения
-	messageLength := binary.LittleEndian.Uint32(lengthBuf)
+	return ReadFrameLimit(reader, DefaultMaxFrameSize)
+}
 
-	// Чтение сообщения
-	messageBuf := make([]byte, messageLength)
-	if _, err := io.ReadFull(reader, messageBuf); err != nil {
-		return nil, fmt.Errorf("ошибка чтения данных фрейма: %w", err)
+// Чтение одного сообщения (одного или нескольких фреймов) из Reader.
+// Если суммарный размер сообщения превышает maxSize, возвращается ErrFrameTooLarge.
+// Ответ берется из пула, и после использования его можно вернуть вызовом ReleaseResponse.
+func ReadFrameLimit(reader io.Reader, maxSize int64) (*Response, error) {
+	// Сообщение собирается в буфер из пула: json.Decoder все равно накапливал бы
+	// JSON-значение целиком, но в собственном буфере, выделяемом для каждого ответа
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if _, err := buf.ReadFrom(NewLimitedFrameReader(reader, maxSize)); err != nil {
+		return nil, err
 	}
 
-	// Десериализация JSON-ответа
-	var response Response
-	if err := json.Unmarshal(messageBuf, &response); err != nil {
+	// Строки ответа копируются из буфера, поэтому буфер можно вернуть в пул
+	response := responsePool.Get().(*Response)
+	if err := json.Unmarshal(buf.Bytes(), response); err != nil {
+		ReleaseResponse(response)
 		return nil, fmt.Errorf("ошибка десериализации ответа: %w", err)
 	}
-
-	return &response, nil
+	return response, nil
 }
 
-// Запись одного фрейма в Writer
+// Запись одного сообщения (одного или нескольких фреймов) в Writer
 func WriteFrame(writer io.Writer, request *Request) error {
-	// Сериализация запроса в бинарный формат
-	data, err := Encode(request)
-	if err != nil {
-		return err
+	// Сериализуем запрос в JSON в буфер из пула после места под заголовок первого фрейма
+	buf := getBuffer()
+	defer putBuffer(buf)
+	var header [headerSize]byte
+	buf.Write(header[:])
+	if err := json.NewEncoder(buf).Encode(request); err != nil {
+		return fmt.Errorf("ошибка сериализации запроса: %w", err)
 	}
+	// json.Encoder завершает значение переводом строки, которого нет в json.Marshal
+	buf.Truncate(buf.Len() - 1)
 
-	// Запись всех данных
-	if _, err := writer.Write(data); err != nil {
-		return fmt.Errorf("ошибка записи фрейма: %w", err)
-	}
+	return writeMessage(writer, buf.Bytes(), DefaultChunkSize)
+}
 
-	return nil
+// Запрос из пула с указанными полями
+func newRequest(fields Request) *Request {
+	req := AcquireRequest()
+	*req = fields
+	return req
 }
 
 // Создание нового запроса добавления данных
 func NewInsertRequest(requestId, data string) *Request {
-	return &Request{
+	return newRequest(Request{
 		RequestId: requestId,
 		Command:   CommandInsert,
 		Params: AdditionalParams{
 			Data: data,
 		},
-	}
+	})
 }
 
 // Создание нового запроса получения данных
 func NewGetRequest(requestId, uuid string) *Request {
-	return &Request{
+	return newRequest(Request{
 		RequestId: requestId,
 		Command:   CommandGet,
 		Params: AdditionalParams{
 			Uuid: uuid,
 		},
-	}
+	})
+}
+
+// Создание нового запроса проверки наличия строки
+func NewExistsRequest(requestId, uuid string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandExists,
+		Params: AdditionalParams{
+			Uuid: uuid,
+		},
+	})
+}
+
+// Создание нового запроса размера и метаданных строки
+func NewStatRequest(requestId, uuid string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandStat,
+		Params: AdditionalParams{
+			Uuid: uuid,
+		},
+	})
 }
 
 // Создание нового запроса обновления данных
 func NewUpdateRequest(requestId, uuid, data string) *Request {
-	return &Request{
+	return newRequest(Request{
 		RequestId: requestId,
 		Command:   CommandUpdate,
 		Params: AdditionalParams{
 			Uuid: uuid,
 			Data: data,
 		},
-	}
+	})
 }
 
 // Создание нового запроса удаления данных
 func NewRemoveRequest(requestId, uuid string) *Request {
-	return &Request{
+	return newRequest(Request{
 		RequestId: requestId,
 		Command:   CommandRemove,
 		Params: AdditionalParams{
 			Uuid: uuid,
 		},
-	}
+	})
 }
 
 // Создание нового запроса удаления данных
 func NewPingRequest(requestId string) *Request {
-	return &Request{
+	return newRequest(Request{
 		RequestId: requestId,
 		Command:   CommandPing,
-	}
+	})
+}
+
+// Создание нового запроса статистики хранилища
+func NewStatsRequest(requestId string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandStats,
+	})
+}
+
+// Создание нового запроса количества строк хранилища
+func NewCountRequest(requestId string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandCount,
+	})
+}
+
+// Создание нового запроса на запуск сжатия хранилища
+func NewCompactRequest(requestId string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandCompact,
+	})
+}
+
+// Создание нового запроса состояния сжатия хранилища
+func NewCompactStatusRequest(requestId string) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandCompactStatus,
+	})
+}
+
+// Создание нового запроса записей журнала операций
+func NewJournalRequest(requestId, since string, skip, limit int) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandJournal,
+		Params: AdditionalParams{
+			Since: since,
+			Skip:  skip,
+			Limit: limit,
+		},
+	})
+}
+
+// Создание нового запроса выполнения нескольких операций
+func NewBatchRequest(requestId string, operations []BatchOperation) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandBatch,
+		Params: AdditionalParams{
+			Operations: operations,
+		},
+	})
+}
+
+// Создание нового запроса страницы UUID строк хранилища
+func NewListRequest(requestId, after string, limit int) *Request {
+	return newRequest(Request{
+		RequestId: requestId,
+		Command:   CommandList,
+		Params: AdditionalParams{
+			After: after,
+			Limit: limit,
+		},
+	})
 }
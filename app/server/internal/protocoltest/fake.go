@@ -0,0 +1,378 @@
+package protocoltest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	guuid "github.com/google/uuid"
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// FakeBackend - хранящая данные в памяти реализация протокола octet на Unix сокете.
+// Используется для проверки самого набора тестов и для тестов без собранного octet.
+type FakeBackend struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	data     map[string]string
+	expires  map[string]string // Момент истечения срока хранения строк (в формате журнала)
+	journal  []protocol.JournalEntry
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// Запуск фейкового backend на указанном пути сокета
+func NewFakeBackend(socketPath string) (*FakeBackend, error) {
+	// Удаляем оставшийся от предыдущего запуска файл сокета
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fb := &FakeBackend{
+		listener: listener,
+		data:     make(map[string]string),
+		expires:  make(map[string]string),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	fb.wg.Add(1)
+	go fb.accept()
+	return fb, nil
+}
+
+// Путь к сокету фейкового backend
+func (fb *FakeBackend) SocketPath() string {
+	return fb.listener.Addr().String()
+}
+
+// Остановка фейкового backend и закрытие всех соединений
+func (fb *FakeBackend) Close() error {
+	err := fb.listener.Close()
+	fb.mutex.Lock()
+	for conn := range fb.conns {
+		conn.Close()
+	}
+	fb.mutex.Unlock()
+	fb.wg.Wait()
+	return err
+}
+
+// Прием новых соединений
+func (fb *FakeBackend) accept() {
+	defer fb.wg.Done()
+	for {
+		conn, err := fb.listener.Accept()
+		if err != nil {
+			return
+		}
+		fb.mutex.Lock()
+		fb.conns[conn] = struct{}{}
+		fb.mutex.Unlock()
+
+		fb.wg.Add(1)
+		go fb.serve(conn)
+	}
+}
+
+// Обработка запросов одного соединения
+func (fb *FakeBackend) serve(conn net.Conn) {
+	defer fb.wg.Done()
+	defer func() {
+		fb.mutex.Lock()
+		delete(fb.conns, conn)
+		fb.mutex.Unlock()
+		conn.Close()
+	}()
+
+	var heartbeat bytes.Buffer
+	protocol.WriteHeartbeat(&heartbeat)
+
+	reader := bufio.NewReader(conn)
+	for {
+		// Отвечаем на heartbeat-фреймы, полученные между сообщениями
+		header, err := reader.Peek(heartbeat.Len())
+		if err != nil {
+			return
+		}
+		if bytes.Equal(header, heartbeat.Bytes()) {
+			reader.Discard(len(header))
+			if err := protocol.WriteHeartbeat(conn); err != nil {
+				return
+			}
+			continue
+		}
+
+		// Собираем сообщение из фреймов
+		message, err := io.ReadAll(protocol.NewFrameReader(reader))
+		if err != nil {
+			return
+		}
+
+		var response protocol.Response
+		var request protocol.Request
+		if err := json.Unmarshal(message, &request); err != nil {
+			response = protocol.Response{RequestId: "error", Error: "Invalid request format", ErrorCode: protocol.ErrorCodeInternal}
+			if !utf8.Valid(message) {
+				response.ErrorCode = protocol.ErrorCodeInvalidUtf8
+			}
+		} else {
+			response = fb.handle(&request)
+		}
+
+		payload, err := json.Marshal(&response)
+		if err != nil {
+			return
+		}
+		writer := protocol.NewFrameWriter(conn, protocol.DefaultChunkSize)
+		if _, err := writer.Write(payload); err != nil {
+			return
+		}
+		if err := writer.Close(); err != nil {
+			return
+		}
+	}
+}
+
+// Выполнение команды над данными в памяти (ответы повторяют ответы octet)
+func (fb *FakeBackend) handle(request *protocol.Request) protocol.Response {
+	fb.mutex.Lock()
+	defer fb.mutex.Unlock()
+	fb.removeExpired()
+	return fb.execute(request)
+}
+
+// Выполнение команды (вызывается под блокировкой)
+func (fb *FakeBackend) execute(request *protocol.Request) protocol.Response {
+	response := protocol.Response{RequestId: request.RequestId, Success: true}
+	fail := func(code protocol.ErrorCode, message string) protocol.Response {
+		response.Success = false
+		response.ErrorCode = code
+		response.Error = message
+		return response
+	}
+
+	params := request.Params
+	switch request.Command {
+	case protocol.CommandInsert:
+		if len(params.Data) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing data for INSERT")
+		}
+		if !utf8.ValidString(params.Data) {
+			return fail(protocol.ErrorCodeInvalidUtf8, "Data is not valid UTF-8")
+		}
+		// С указанным UUID строка добавляется, только если такой строки еще нет
+		uuid := params.Uuid
+		if len(uuid) == 0 {
+			uuid = guuid.NewString()
+		} else if !octetUUID(uuid) {
+			return fail(protocol.ErrorCodeInternal, "Invalid uuid for INSERT")
+		} else if _, ok := fb.data[uuid]; ok {
+			return fail(protocol.ErrorCodeAlreadyExists, "Item already exists")
+		}
+		fb.data[uuid] = params.Data
+		fb.record(protocol.CommandInsert, uuid, params.Data)
+		if params.TtlSeconds > 0 {
+			expiresAt := time.Now().UTC().Add(time.Duration(params.TtlSeconds) * time.Second).Format(protocol.JournalTimeFormat)
+			fb.expires[uuid] = expiresAt
+			fb.record("expire", uuid, expiresAt)
+		}
+		response.Params.Uuid = uuid
+		if params.WithChecksum {
+			response.Params.Checksum = protocol.Checksum(params.Data)
+		}
+	case protocol.CommandGet:
+		if len(params.Uuid) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing uuid for GET")
+		}
+		data, ok := fb.data[params.Uuid]
+		if !ok {
+			return fail(protocol.ErrorCodeNotFound, "Data not found")
+		}
+		response.Params.Data = data
+		_, _, response.Params.Version = fb.meta(params.Uuid)
+	case protocol.CommandExists:
+		if len(params.Uuid) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing uuid for EXISTS")
+		}
+		if _, ok := fb.data[params.Uuid]; !ok {
+			return fail(protocol.ErrorCodeNotFound, "Data not found")
+		}
+	case protocol.CommandStat:
+		if len(params.Uuid) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing uuid for STAT")
+		}
+		data, ok := fb.data[params.Uuid]
+		if !ok {
+			return fail(protocol.ErrorCodeNotFound, "Data not found")
+		}
+		response.Params.Size = uint64(len(data))
+		response.Params.CreatedAt, response.Params.ModifiedAt, response.Params.Version = fb.meta(params.Uuid)
+		response.Params.ExpiresAt = fb.expires[params.Uuid]
+	case protocol.CommandUpdate:
+		if len(params.Uuid) == 0 || len(params.Data) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing UUID or data for UPDATE")
+		}
+		if !utf8.ValidString(params.Data) {
+			return fail(protocol.ErrorCodeInvalidUtf8, "Data is not valid UTF-8")
+		}
+		if _, ok := fb.data[params.Uuid]; !ok {
+			return fail(protocol.ErrorCodeNotFound, "Failed to update item")
+		}
+		if params.ExpectedVersion != 0 {
+			if _, _, version := fb.meta(params.Uuid); version != params.ExpectedVersion {
+				return fail(protocol.ErrorCodeVersionMismatch, "Version mismatch")
+			}
+		}
+		fb.data[params.Uuid] = params.Data
+		fb.record(protocol.CommandUpdate, params.Uuid, params.Data)
+		if params.WithChecksum {
+			response.Params.Checksum = protocol.Checksum(params.Data)
+		}
+	case protocol.CommandRemove:
+		if len(params.Uuid) == 0 {
+			return fail(protocol.ErrorCodeInternal, "Missing uuid for REMOVE")
+		}
+		if _, ok := fb.data[params.Uuid]; !ok {
+			return fail(protocol.ErrorCodeNotFound, "Failed to remove item")
+		}
+		delete(fb.data, params.Uuid)
+		delete(fb.expires, params.Uuid)
+		fb.record(protocol.CommandRemove, params.Uuid, "")
+	case protocol.CommandPing:
+	case protocol.CommandStats, protocol.CommandCount:
+		response.Params.Count = uint64(len(fb.data))
+	case protocol.CommandCompact, protocol.CommandCompactStatus:
+		// Данные хранятся только в памяти, поэтому сжатие завершается сразу
+		response.Params.CompactionState = "completed"
+	case protocol.CommandJournal:
+		response.Params.Journal, response.Params.More = fb.journalSince(params.Since, params.Skip, params.Limit)
+	case protocol.CommandList:
+		response.Params.Uuids, response.Params.More = fb.list(params.After, params.Limit)
+	case protocol.CommandBatch:
+		response.Params.Results = make([]protocol.BatchResult, len(params.Operations))
+		for i, op := range params.Operations {
+			if op.Command != protocol.CommandInsert && op.Command != protocol.CommandUpdate &&
+				op.Command != protocol.CommandRemove {
+				response.Params.Results[i] = protocol.BatchResult{Error: "Unsupported command in BATCH", ErrorCode: protocol.ErrorCodeInternal}
+				continue
+			}
+			single := fb.execute(&protocol.Request{
+				RequestId: request.RequestId,
+				Command:   op.Command,
+				Params: protocol.AdditionalParams{
+					Uuid: op.Uuid, Data: op.Data, TtlSeconds: op.TtlSeconds, ExpectedVersion: op.ExpectedVersion,
+					WithChecksum: params.WithChecksum,
+				},
+			})
+			response.Params.Results[i] = protocol.BatchResult{
+				Success:   single.Success,
+				Uuid:      single.Params.Uuid,
+				Checksum:  single.Params.Checksum,
+				Error:     single.Error,
+				ErrorCode: single.ErrorCode,
+			}
+		}
+	default:
+		return fail(protocol.ErrorCodeInternal, "Unknown command")
+	}
+	return response
+}
+
+// Запись операции в журнал (вызывается под блокировкой)
+func (fb *FakeBackend) record(op protocol.CommandType, uuid, data string) {
+	fb.journal = append(fb.journal, protocol.JournalEntry{
+		Op:   string(op),
+		Uuid: uuid,
+		Time: time.Now().UTC().Format(protocol.JournalTimeFormat),
+		Data: data,
+	})
+}
+
+// Удаление строк, срок хранения которых истек (вызывается под блокировкой)
+func (fb *FakeBackend) removeExpired() {
+	now := time.Now().UTC().Format(protocol.JournalTimeFormat)
+	for uuid, expiresAt := range fb.expires {
+		if expiresAt > now {
+			continue
+		}
+		delete(fb.data, uuid)
+		delete(fb.expires, uuid)
+		fb.record(protocol.CommandRemove, uuid, "")
+	}
+}
+
+// Записи журнала начиная с момента since (вызывается под блокировкой)
+func (fb *FakeBackend) journalSince(since string, skip, limit int) ([]protocol.JournalEntry, bool) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	entries := []protocol.JournalEntry{}
+	for _, entry := range fb.journal {
+		if entry.Time < since {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if len(entries) == limit {
+			return entries, true
+		}
+		entries = append(entries, entry)
+	}
+	return entries, false
+}
+
+// Время добавления и последнего изменения строки и ее версия по журналу (вызывается под блокировкой)
+func (fb *FakeBackend) meta(uuid string) (createdAt, modifiedAt string, version uint64) {
+	for _, entry := range fb.journal {
+		if entry.Uuid != uuid {
+			continue
+		}
+		switch protocol.CommandType(entry.Op) {
+		case protocol.CommandInsert:
+			createdAt, version = entry.Time, 1
+		case protocol.CommandUpdate:
+			version++
+		case "expire":
+			continue
+		}
+		modifiedAt = entry.Time
+	}
+	return createdAt, modifiedAt, version
+}
+
+// UUID строк после after в порядке возрастания (вызывается под блокировкой)
+func (fb *FakeBackend) list(after string, limit int) ([]string, bool) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	uuids := []string{}
+	for uuid := range fb.data {
+		if uuid > after {
+			uuids = append(uuids, uuid)
+		}
+	}
+	slices.Sort(uuids)
+	if len(uuids) > limit {
+		return uuids[:limit], true
+	}
+	return uuids, false
+}
+
+// Может ли octet выдать такой UUID: версия 4 в нижнем регистре
+func octetUUID(uuid string) bool {
+	parsed, err := guuid.Parse(uuid)
+	return err == nil && parsed.String() == uuid && parsed.Version() == 4 && parsed.Variant() == guuid.RFC4122
+}
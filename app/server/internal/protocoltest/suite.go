@@ -0,0 +1,660 @@
+// Package protocoltest содержит набор проверок соответствия протоколу octet,
+// выполняемых против любого Unix сокета (настоящего octet или FakeBackend).
+package protocoltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Параметры запуска проверок
+type Options struct {
+	Timeout          time.Duration // Таймаут одной проверки
+	LargePayloadSize int           // Размер данных для проверки больших сообщений
+}
+
+// Проверка соответствия протоколу
+type Check struct {
+	Name        string
+	Description string
+	run         func(c *conn) error
+}
+
+// Результат выполнения проверки
+type Result struct {
+	Name        string
+	Description string
+	Err         error // nil, если проверка пройдена
+	Duration    time.Duration
+}
+
+// Пройдена ли проверка
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Список всех проверок в порядке выполнения
+func Checks(opts Options) []Check {
+	large := opts.LargePayloadSize
+	if large <= 0 {
+		large = 256 << 10
+	}
+
+	return []Check{
+		{"ping", "ping завершается успешно и возвращает request_id запроса", checkPing},
+		{"crud", "insert/get/exists/update/remove изменяют и возвращают данные", checkCRUD},
+		{"unicode", "строки UTF-8 и спецсимволы JSON сохраняются без изменений", checkUnicode},
+		{"stats", "stats и count возвращают количество записей в хранилище", checkStats},
+		{"stat", "stat возвращает размер, время добавления и изменения и версию строки, get - версию вместе с данными", checkStat},
+		{"insert_uuid", "insert с uuid добавляет строку с этим UUID, только если ее еще нет", checkInsertUUID},
+		{"expected_version", "update с expected_version изменяет строку только при совпадении ее версии", checkExpectedVersion},
+		{"ttl", "строка, добавленная с ttl_seconds, удаляется по истечении срока хранения", checkTTL},
+		{"compact", "compact запускает сжатие хранилища, compact_status сообщает о его завершении", checkCompact},
+		{"journal", "journal возвращает выполненные операции начиная с указанного момента", checkJournal},
+		{"checksum", "insert/update с with_checksum возвращают CRC32 сохраненных данных", checkChecksum},
+		{"batch", "batch выполняет операции по порядку и возвращает результат каждой", checkBatch},
+		{"not_found", "операции с несуществующим UUID завершаются ошибкой", checkNotFound},
+		{"missing_params", "команды без обязательных параметров завершаются ошибкой", checkMissingParams},
+		{"unknown_command", "неизвестная команда отклоняется без разрыва соединения", checkUnknownCommand},
+		{"malformed_json", "некорректный JSON отклоняется без разрыва соединения", checkMalformedJSON},
+		{"multi_frame", "запрос, разбитый на несколько фреймов, собирается целиком", checkMultiFrame},
+		{"large_payload", fmt.Sprintf("сообщения размером %d байт передаются в обе стороны", large),
+			func(c *conn) error { return checkLargePayload(c, large) }},
+		{"heartbeat", "heartbeat-фрейм получает ответный heartbeat-фрейм", checkHeartbeat},
+		{"pipelining", "несколько запросов подряд получают ответы в том же порядке", checkPipelining},
+	}
+}
+
+// Выполнение всех проверок против сокета по указанному пути.
+// Каждая проверка выполняется на отдельном соединении.
+func Run(ctx context.Context, socketPath string, opts Options) []Result {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	checks := Checks(opts)
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		start := time.Now()
+		err := ctx.Err()
+		if err == nil {
+			err = runCheck(ctx, socketPath, opts.Timeout, check)
+		}
+		results = append(results, Result{
+			Name:        check.Name,
+			Description: check.Description,
+			Err:         err,
+			Duration:    time.Since(start),
+		})
+	}
+	return results
+}
+
+// Выполнение одной проверки на новом соединении
+func runCheck(ctx context.Context, socketPath string, timeout time.Duration, check Check) error {
+	dialer := net.Dialer{Timeout: timeout}
+	netConn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к сокету: %w", err)
+	}
+	defer netConn.Close()
+
+	if err := netConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	return check.run(&conn{Conn: netConn})
+}
+
+// Соединение с проверяемым backend
+type conn struct {
+	net.Conn
+}
+
+// Отправка запроса фреймами указанного размера
+func (c *conn) send(req *protocol.Request, chunkSize int) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.sendRaw(payload, chunkSize)
+}
+
+// Отправка произвольной полезной нагрузки одним сообщением
+func (c *conn) sendRaw(payload []byte, chunkSize int) error {
+	writer := protocol.NewFrameWriter(c.Conn, chunkSize)
+	if _, err := writer.Write(payload); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Получение ответа
+func (c *conn) receive() (*protocol.Response, error) {
+	resp, err := protocol.ReadFrameLimit(c.Conn, 0)
+	if err != nil {
+		return nil, fmt.Errorf("не получен ответ: %w", err)
+	}
+	return resp, nil
+}
+
+// Отправка запроса и получение ответа с проверкой request_id
+func (c *conn) call(req *protocol.Request) (*protocol.Response, error) {
+	if err := c.send(req, protocol.DefaultChunkSize); err != nil {
+		return nil, fmt.Errorf("%s: ошибка отправки запроса: %w", req.Command, err)
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", req.Command, err)
+	}
+	if resp.RequestId != req.RequestId {
+		return nil, fmt.Errorf("%s: request_id ответа %q не совпадает с запросом %q",
+			req.Command, resp.RequestId, req.RequestId)
+	}
+	return resp, nil
+}
+
+// Выполнение запроса, который должен завершиться успешно
+func (c *conn) expectSuccess(req *protocol.Request) (*protocol.Response, error) {
+	resp, err := c.call(req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s: ожидался успех, получена ошибка %q", req.Command, resp.Error)
+	}
+	return resp, nil
+}
+
+// Выполнение запроса, который должен завершиться ошибкой с указанным кодом
+func (c *conn) expectFailure(req *protocol.Request, code protocol.ErrorCode) error {
+	resp, err := c.call(req)
+	if err != nil {
+		return err
+	}
+	if resp.Success {
+		return fmt.Errorf("%s: ожидалась ошибка, получен успешный ответ", req.Command)
+	}
+	if len(resp.Error) == 0 {
+		return fmt.Errorf("%s: ответ с ошибкой не содержит ее описания", req.Command)
+	}
+	if resp.ErrorCode != code {
+		return fmt.Errorf("%s: ожидался код ошибки %q, получен %q", req.Command, code, resp.ErrorCode)
+	}
+	return nil
+}
+
+// Добавление данных с возвратом UUID
+func (c *conn) insert(data string) (string, error) {
+	resp, err := c.expectSuccess(protocol.NewInsertRequest(newRequestId(), data))
+	if err != nil {
+		return "", err
+	}
+	if _, err := guuid.Parse(resp.Params.Uuid); err != nil {
+		return "", fmt.Errorf("insert: некорректный UUID в ответе %q", resp.Params.Uuid)
+	}
+	return resp.Params.Uuid, nil
+}
+
+// Проверка, что по UUID хранятся ожидаемые данные
+func (c *conn) expectData(uuid, expected string) error {
+	resp, err := c.expectSuccess(protocol.NewGetRequest(newRequestId(), uuid))
+	if err != nil {
+		return err
+	}
+	if resp.Params.Data != expected {
+		return fmt.Errorf("get: получены данные длиной %d, ожидались данные длиной %d (%s)",
+			len(resp.Params.Data), len(expected), firstDifference(resp.Params.Data, expected))
+	}
+	return nil
+}
+
+// Удаление данных, созданных проверкой
+func (c *conn) remove(uuid string) error {
+	_, err := c.expectSuccess(protocol.NewRemoveRequest(newRequestId(), uuid))
+	return err
+}
+
+func newRequestId() string {
+	return guuid.NewString()
+}
+
+// Описание первого расхождения строк для сообщения об ошибке
+func firstDifference(actual, expected string) string {
+	for i := range min(len(actual), len(expected)) {
+		if actual[i] != expected[i] {
+			return fmt.Sprintf("первое расхождение на байте %d", i)
+		}
+	}
+	return "одна строка является префиксом другой"
+}
+
+func checkPing(c *conn) error {
+	_, err := c.expectSuccess(protocol.NewPingRequest(newRequestId()))
+	return err
+}
+
+func checkCRUD(c *conn) error {
+	uuid, err := c.insert("protocoltest: исходное значение")
+	if err != nil {
+		return err
+	}
+	if err := c.expectData(uuid, "protocoltest: исходное значение"); err != nil {
+		return err
+	}
+	if _, err := c.expectSuccess(protocol.NewExistsRequest(newRequestId(), uuid)); err != nil {
+		return err
+	}
+	if _, err := c.expectSuccess(protocol.NewUpdateRequest(newRequestId(), uuid, "protocoltest: новое значение")); err != nil {
+		return err
+	}
+	if err := c.expectData(uuid, "protocoltest: новое значение"); err != nil {
+		return err
+	}
+	if err := c.remove(uuid); err != nil {
+		return err
+	}
+	if err := c.expectFailure(protocol.NewGetRequest(newRequestId(), uuid), protocol.ErrorCodeNotFound); err != nil {
+		return fmt.Errorf("после remove: %w", err)
+	}
+	return nil
+}
+
+func checkUnicode(c *conn) error {
+	data := "Кириллица, 中文, emoji 🚀, \"кавычки\", \\слэш\\, \t\n\r, \u0001, </script>"
+	uuid, err := c.insert(data)
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+	return c.expectData(uuid, data)
+}
+
+func checkStats(c *conn) error {
+	uuid, err := c.insert("protocoltest: статистика")
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+	for _, req := range []*protocol.Request{
+		protocol.NewStatsRequest(newRequestId()),
+		protocol.NewCountRequest(newRequestId()),
+	} {
+		resp, err := c.expectSuccess(req)
+		if err != nil {
+			return err
+		}
+		// Хранилище может использоваться параллельно, поэтому проверяем только нижнюю границу
+		if resp.Params.Count < 1 {
+			return fmt.Errorf("%s: количество записей %d, ожидалось не меньше 1", req.Command, resp.Params.Count)
+		}
+	}
+	return nil
+}
+
+func checkStat(c *conn) error {
+	uuid, err := c.insert("protocoltest: метаданные")
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+	inserted, err := c.expectSuccess(protocol.NewStatRequest(newRequestId(), uuid))
+	if err != nil {
+		return err
+	}
+	if inserted.Params.Size != uint64(len("protocoltest: метаданные")) {
+		return fmt.Errorf("размер %d, ожидался %d", inserted.Params.Size, len("protocoltest: метаданные"))
+	}
+	if inserted.Params.Version != 1 || len(inserted.Params.CreatedAt) == 0 ||
+		inserted.Params.ModifiedAt != inserted.Params.CreatedAt {
+		return fmt.Errorf("после insert: версия %d, добавление '%s', изменение '%s'",
+			inserted.Params.Version, inserted.Params.CreatedAt, inserted.Params.ModifiedAt)
+	}
+	createdAt := inserted.Params.CreatedAt
+
+	if _, err := c.expectSuccess(protocol.NewUpdateRequest(newRequestId(), uuid, "protocoltest")); err != nil {
+		return err
+	}
+	updated, err := c.expectSuccess(protocol.NewStatRequest(newRequestId(), uuid))
+	if err != nil {
+		return err
+	}
+	if updated.Params.Size != uint64(len("protocoltest")) || updated.Params.Version != 2 ||
+		updated.Params.CreatedAt != createdAt || updated.Params.ModifiedAt < createdAt {
+		return fmt.Errorf("после update: размер %d, версия %d, добавление '%s', изменение '%s'",
+			updated.Params.Size, updated.Params.Version, updated.Params.CreatedAt, updated.Params.ModifiedAt)
+	}
+
+	got, err := c.expectSuccess(protocol.NewGetRequest(newRequestId(), uuid))
+	if err != nil {
+		return err
+	}
+	if got.Params.Data != "protocoltest" || got.Params.Version != updated.Params.Version {
+		return fmt.Errorf("get: данные '%s', версия %d, ожидалась версия %d",
+			got.Params.Data, got.Params.Version, updated.Params.Version)
+	}
+	return nil
+}
+
+func checkInsertUUID(c *conn) error {
+	uuid := guuid.NewString()
+	req := protocol.NewInsertRequest(newRequestId(), "protocoltest: UUID клиента")
+	req.Params.Uuid = uuid
+	inserted, err := c.expectSuccess(req)
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+	if inserted.Params.Uuid != uuid {
+		return fmt.Errorf("получен UUID '%s', ожидался '%s'", inserted.Params.Uuid, uuid)
+	}
+
+	repeated := protocol.NewInsertRequest(newRequestId(), "protocoltest: повтор")
+	repeated.Params.Uuid = uuid
+	if err := c.expectFailure(repeated, protocol.ErrorCodeAlreadyExists); err != nil {
+		return err
+	}
+	return c.expectData(uuid, "protocoltest: UUID клиента")
+}
+
+func checkExpectedVersion(c *conn) error {
+	uuid, err := c.insert("protocoltest: версия")
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+
+	stale := protocol.NewUpdateRequest(newRequestId(), uuid, "protocoltest: устаревшая версия")
+	stale.Params.ExpectedVersion = 2
+	if err := c.expectFailure(stale, protocol.ErrorCodeVersionMismatch); err != nil {
+		return err
+	}
+	if err := c.expectData(uuid, "protocoltest: версия"); err != nil {
+		return err
+	}
+
+	current := protocol.NewUpdateRequest(newRequestId(), uuid, "protocoltest")
+	current.Params.ExpectedVersion = 1
+	if _, err := c.expectSuccess(current); err != nil {
+		return err
+	}
+	return c.expectData(uuid, "protocoltest")
+}
+
+func checkTTL(c *conn) error {
+	req := protocol.NewInsertRequest(newRequestId(), "protocoltest: срок хранения")
+	req.Params.TtlSeconds = 1
+	inserted, err := c.expectSuccess(req)
+	if err != nil {
+		return err
+	}
+	uuid := inserted.Params.Uuid
+	stat, err := c.expectSuccess(protocol.NewStatRequest(newRequestId(), uuid))
+	if err != nil {
+		return err
+	}
+	if stat.Params.ExpiresAt <= stat.Params.CreatedAt {
+		c.remove(uuid)
+		return fmt.Errorf("stat: истечение срока '%s', добавление '%s'", stat.Params.ExpiresAt, stat.Params.CreatedAt)
+	}
+
+	// octet проверяет сроки хранения периодически, поэтому строка удаляется не сразу
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := c.call(protocol.NewExistsRequest(newRequestId(), uuid))
+		if err != nil {
+			return err
+		}
+		if !resp.Success && resp.ErrorCode == protocol.ErrorCodeNotFound {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	c.remove(uuid)
+	return fmt.Errorf("строка не удалена через 5 секунд после добавления с ttl_seconds = 1")
+}
+
+func checkCompact(c *conn) error {
+	resp, err := c.expectSuccess(protocol.NewCompactRequest(newRequestId()))
+	if err != nil {
+		return err
+	}
+	// Сжатие выполняется асинхронно, поэтому опрашиваем состояние до его завершения
+	for resp.Params.CompactionState == "running" {
+		time.Sleep(10 * time.Millisecond)
+		if resp, err = c.expectSuccess(protocol.NewCompactStatusRequest(newRequestId())); err != nil {
+			return err
+		}
+	}
+	if resp.Params.CompactionState != "completed" {
+		return fmt.Errorf("состояние сжатия '%s', ожидалось 'completed'", resp.Params.CompactionState)
+	}
+	return nil
+}
+
+func checkJournal(c *conn) error {
+	// Метки времени журнала имеют точность в миллисекунды, поэтому начинаем чуть раньше
+	since := time.Now().Add(-time.Second).UTC().Format(protocol.JournalTimeFormat)
+	data := "protocoltest: журнал"
+	uuid, err := c.insert(data)
+	if err != nil {
+		return err
+	}
+	if err := c.remove(uuid); err != nil {
+		return err
+	}
+
+	var ops []string
+	skip := 0
+	for {
+		resp, err := c.expectSuccess(protocol.NewJournalRequest(newRequestId(), since, skip, 100))
+		if err != nil {
+			return err
+		}
+		for _, entry := range resp.Params.Journal {
+			if entry.Time < since {
+				return fmt.Errorf("запись журнала от %s раньше запрошенного момента %s", entry.Time, since)
+			}
+			if entry.Uuid != uuid {
+				continue
+			}
+			if entry.Op == "insert" && entry.Data != data {
+				return fmt.Errorf("данные insert в журнале: %q, ожидалось %q", entry.Data, data)
+			}
+			ops = append(ops, entry.Op)
+		}
+		if !resp.Params.More {
+			break
+		}
+		skip += len(resp.Params.Journal)
+	}
+	if strings.Join(ops, ",") != "insert,remove" {
+		return fmt.Errorf("операции в журнале: [%s], ожидалось [insert,remove]", strings.Join(ops, ","))
+	}
+	return nil
+}
+
+func checkChecksum(c *conn) error {
+	data := "protocoltest: контрольная сумма"
+	req := protocol.NewInsertRequest(newRequestId(), data)
+	req.Params.WithChecksum = true
+	resp, err := c.expectSuccess(req)
+	if err != nil {
+		return err
+	}
+	uuid := resp.Params.Uuid
+	defer c.remove(uuid)
+	if resp.Params.Checksum != protocol.Checksum(data) {
+		return fmt.Errorf("insert: контрольная сумма %q, ожидалась %q", resp.Params.Checksum, protocol.Checksum(data))
+	}
+
+	data = "protocoltest: обновленная контрольная сумма"
+	req = protocol.NewUpdateRequest(newRequestId(), uuid, data)
+	req.Params.WithChecksum = true
+	if resp, err = c.expectSuccess(req); err != nil {
+		return err
+	}
+	if resp.Params.Checksum != protocol.Checksum(data) {
+		return fmt.Errorf("update: контрольная сумма %q, ожидалась %q", resp.Params.Checksum, protocol.Checksum(data))
+	}
+	return nil
+}
+
+func checkBatch(c *conn) error {
+	data := "protocoltest: пакет"
+	req := protocol.NewBatchRequest(newRequestId(), []protocol.BatchOperation{
+		{Command: protocol.CommandInsert, Data: data},
+		{Command: protocol.CommandRemove, Uuid: guuid.NewString()},
+		{Command: protocol.CommandGet, Uuid: guuid.NewString()},
+	})
+	req.Params.WithChecksum = true
+	resp, err := c.expectSuccess(req)
+	if err != nil {
+		return err
+	}
+	results := resp.Params.Results
+	if len(results) != 3 {
+		return fmt.Errorf("получено %d результатов, ожидалось 3", len(results))
+	}
+	if !results[0].Success || results[0].Uuid == "" {
+		return fmt.Errorf("insert в пакете не выполнен: %+v", results[0])
+	}
+	defer c.remove(results[0].Uuid)
+	if results[0].Checksum != protocol.Checksum(data) {
+		return fmt.Errorf("insert в пакете: контрольная сумма %q, ожидалась %q", results[0].Checksum, protocol.Checksum(data))
+	}
+	if results[1].Success || results[1].Error == "" {
+		return fmt.Errorf("remove несуществующей записи в пакете завершился успешно")
+	}
+	if results[2].Success {
+		return fmt.Errorf("get в пакете должен отклоняться")
+	}
+
+	// Ошибка операции не прерывает выполнение следующих
+	if resp, err = c.expectSuccess(protocol.NewGetRequest(newRequestId(), results[0].Uuid)); err != nil {
+		return err
+	}
+	if resp.Params.Data != data {
+		return fmt.Errorf("get: получено %q, ожидалось %q", resp.Params.Data, data)
+	}
+	return nil
+}
+
+func checkNotFound(c *conn) error {
+	uuid := guuid.NewString()
+	for _, req := range []*protocol.Request{
+		protocol.NewGetRequest(newRequestId(), uuid),
+		protocol.NewExistsRequest(newRequestId(), uuid),
+		protocol.NewStatRequest(newRequestId(), uuid),
+		protocol.NewUpdateRequest(newRequestId(), uuid, "protocoltest"),
+		protocol.NewRemoveRequest(newRequestId(), uuid),
+	} {
+		if err := c.expectFailure(req, protocol.ErrorCodeNotFound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkMissingParams(c *conn) error {
+	for _, req := range []*protocol.Request{
+		protocol.NewInsertRequest(newRequestId(), ""),
+		protocol.NewGetRequest(newRequestId(), ""),
+		protocol.NewExistsRequest(newRequestId(), ""),
+		protocol.NewStatRequest(newRequestId(), ""),
+		protocol.NewUpdateRequest(newRequestId(), guuid.NewString(), ""),
+		protocol.NewRemoveRequest(newRequestId(), ""),
+	} {
+		if err := c.expectFailure(req, protocol.ErrorCodeInternal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkUnknownCommand(c *conn) error {
+	req := &protocol.Request{RequestId: newRequestId(), Command: "protocoltest_unknown"}
+	if err := c.expectFailure(req, protocol.ErrorCodeInternal); err != nil {
+		return err
+	}
+	return checkPing(c)
+}
+
+func checkMalformedJSON(c *conn) error {
+	if err := c.sendRaw([]byte(`{"request_id": "protocoltest", "command": `), protocol.DefaultChunkSize); err != nil {
+		return fmt.Errorf("ошибка отправки запроса: %w", err)
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if resp.Success {
+		return errors.New("ожидалась ошибка, получен успешный ответ")
+	}
+	return checkPing(c)
+}
+
+func checkMultiFrame(c *conn) error {
+	data := strings.Repeat("многофреймовое сообщение; ", 200)
+	req := protocol.NewInsertRequest(newRequestId(), data)
+	if err := c.send(req, 512); err != nil {
+		return fmt.Errorf("ошибка отправки запроса: %w", err)
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("insert: ожидался успех, получена ошибка %q", resp.Error)
+	}
+	defer c.remove(resp.Params.Uuid)
+	return c.expectData(resp.Params.Uuid, data)
+}
+
+func checkLargePayload(c *conn, size int) error {
+	data := strings.Repeat("0123456789abcdef", size/16+1)[:size]
+	uuid, err := c.insert(data)
+	if err != nil {
+		return err
+	}
+	defer c.remove(uuid)
+	return c.expectData(uuid, data)
+}
+
+func checkHeartbeat(c *conn) error {
+	if err := protocol.WriteHeartbeat(c.Conn); err != nil {
+		return err
+	}
+	if err := protocol.ReadHeartbeat(c.Conn); err != nil {
+		return err
+	}
+	return checkPing(c)
+}
+
+func checkPipelining(c *conn) error {
+	const count = 16
+	requests := make([]*protocol.Request, count)
+	for i := range requests {
+		requests[i] = protocol.NewPingRequest(newRequestId())
+		if err := c.send(requests[i], protocol.DefaultChunkSize); err != nil {
+			return fmt.Errorf("ошибка отправки запроса %d: %w", i, err)
+		}
+	}
+	for i, req := range requests {
+		resp, err := c.receive()
+		if err != nil {
+			return fmt.Errorf("запрос %d: %w", i, err)
+		}
+		if resp.RequestId != req.RequestId {
+			return fmt.Errorf("ответ %d получен на запрос %q вместо %q", i, resp.RequestId, req.RequestId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// octet временно не принимает изменения (например, во время сжатия хранилища)
+var ErrRetryLater = errors.New("octet временно не принимает изменения")
+
+// Задержка повтора, если octet ее не указал
+const defaultRetryAfter = time.Second
+
+// Размыкатель изменений: после ответа RETRY_LATER изменения не отправляются в octet
+// до истечения рекомендованной им задержки и сразу завершаются ошибкой ErrRetryLater.
+// Чтение продолжает выполняться. Общий для всех клиентов пула.
+type WriteBreaker struct {
+	openUntil atomic.Int64  // Момент замыкания (UnixNano), 0 - замкнут
+	Trips     atomic.Uint64 // Количество ответов RETRY_LATER
+	Rejected  atomic.Uint64 // Изменения, отклоненные без отправки в octet
+}
+
+// Изменяет ли команда данные хранилища
+func isWriteCommand(command protocol.CommandType) bool {
+	switch command {
+	case protocol.CommandInsert, protocol.CommandUpdate, protocol.CommandRemove, protocol.CommandBatch:
+		return true
+	}
+	return false
+}
+
+// Размыкание по ответу RETRY_LATER с рекомендованной задержкой
+func (b *WriteBreaker) trip(retryAfter time.Duration) {
+	if b == nil {
+		return
+	}
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	b.Trips.Add(1)
+	until := time.Now().Add(retryAfter).UnixNano()
+	for {
+		current := b.openUntil.Load()
+		if current >= until || b.openUntil.CompareAndSwap(current, until) {
+			return
+		}
+	}
+}
+
+// Оставшееся время, в течение которого команда не отправляется в octet (0 - отправляется)
+func (b *WriteBreaker) remaining(command protocol.CommandType) time.Duration {
+	if b == nil || !isWriteCommand(command) {
+		return 0
+	}
+	remaining := time.Until(time.Unix(0, b.openUntil.Load()))
+	if remaining <= 0 {
+		return 0
+	}
+	b.Rejected.Add(1)
+	return remaining
+}
+
+// Рекомендуемая задержка повтора для ошибки ErrRetryLater (0 - ошибка другого класса)
+func RetryAfter(err error) time.Duration {
+	var backendErr *BackendError
+	if !errors.As(err, &backendErr) || backendErr.Code != protocol.ErrorCodeRetryLater {
+		return 0
+	}
+	if backendErr.RetryAfter <= 0 {
+		return defaultRetryAfter
+	}
+	return backendErr.RetryAfter
+}
@@ -1,12 +1,17 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	guuid "github.com/google/uuid"
@@ -16,17 +21,155 @@ import (
 
 // Конфигурация для клиента
 type ClientConfig struct {
-	SocketPath   string        // Путь к сокету
-	ConnTimeout  time.Duration // Таймаут соединения
-	ReadTimeout  time.Duration // Таймаут чтения
-	WriteTimeout time.Duration // Таймаут записи
+	SocketPath   string         // Путь к сокету
+	ConnTimeout  time.Duration  // Таймаут соединения
+	ReadTimeout  time.Duration  // Таймаут чтения
+	WriteTimeout time.Duration  // Таймаут записи
+	MaxFrameSize int64          // Максимальный размер сообщения от octet
+	Metrics      *ClientMetrics // Счетчики клиента (могут быть общими для нескольких клиентов)
+	// Проверка контрольной суммы сохраненных данных при insert/update
+	VerifyChecksum bool
+	Recorder       *TrafficRecorder // Запись обмена сообщениями (nil - отключена)
+	Faults         *FaultInjector   // Внедрение сбоев (nil - отключено)
+	Intents        *IntentLog       // Журнал намерений изменений (nil - отключен)
+	// Ожидаемые права доступа к сокету, проверяемые перед подключением (nil - без проверки)
+	SocketPermissions *SocketPermissions
+	// Процесс, учетные данные которого проверяются после подключения (nil - без проверки)
+	Peer *ProcessManager
+	// Таймаут чтения по длительности последних выполнений команды (nil - всегда ReadTimeout).
+	// Длительности берутся из Metrics.Latency.
+	AdaptiveTimeout *AdaptiveTimeout
+	// Размыкатель изменений по ответам RETRY_LATER (nil - изменения отправляются всегда)
+	Breaker *WriteBreaker
+	// Идентификаторы запросов к octet в виде UUIDv7 (упорядоченных по времени) вместо UUIDv4
+	RequestIDv7 bool
+	// Ограничение количества строк в хранилище (nil - без ограничения)
+	KeyLimit *KeyLimit
+	// События установки и закрытия соединения (nil - события не рассылаются)
+	Events *StateEvents
+	// Повторные попытки подключения, пока octet не принимает соединения (nil - без повторов)
+	DialRetry *DialRetry
+	// Учет закрытий соединения после ошибок (nil - не учитываются)
+	Discards *DiscardLog
+}
+
+// Повторные попытки подключения к сокету, который еще не создан или не принимает соединения
+// (например, сразу после перезапуска octet). Задержка между попытками удваивается от Initial
+// до Max, а случайная половина задержки разносит попытки разных клиентов во времени.
+type DialRetry struct {
+	Attempts int           // Количество повторных попыток
+	Initial  time.Duration // Задержка перед первой повторной попыткой
+	Max      time.Duration // Максимальная задержка между попытками
+}
+
+// Задержка перед повторной попыткой с номером attempt (начиная с 0)
+func (d *DialRetry) delay(attempt int) time.Duration {
+	delay := d.Initial << min(attempt, 30)
+	if delay <= 0 || delay > d.Max {
+		delay = d.Max
+	}
+	if delay < 2 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+// Можно ли повторить подключение после ошибки: сокет еще не создан или не принимает соединения
+func retryableDial(err error) bool {
+	return errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EAGAIN)
+}
+
+// Ошибка несоответствия сохраненных данных отправленным
+var ErrIntegrity = errors.New("нарушение целостности данных")
+
+// Классы ошибок взаимодействия с octet
+var (
+	ErrBackendUnavailable = errors.New("octet недоступен")               // Процесс не запущен или соединение разорвано
+	ErrPoolExhausted      = errors.New("нет свободных клиентов")         // Все клиенты пула заняты
+	ErrTimeout            = errors.New("превышено время ожидания octet") // octet не ответил вовремя
+	ErrNotFound           = errors.New("запись не найдена")              // Запись с указанным UUID отсутствует
+	ErrInvalidData        = errors.New("некорректные данные")            // Данные не являются строкой UTF-8
+	ErrStorageFull        = errors.New("недостаточно места в хранилище") // На диске octet закончилось место
+	ErrVersionMismatch    = errors.New("версия строки изменилась")       // Версия строки не совпадает с ожидаемой
+	ErrAlreadyExists      = errors.New("запись уже существует")          // Запись с указанным UUID уже есть
+)
+
+// Ошибка, возвращенная octet в ответе на запрос
+type BackendError struct {
+	Command    protocol.CommandType
+	Code       protocol.ErrorCode // Код ошибки octet
+	Message    string             // Сообщение octet
+	RetryAfter time.Duration      // Рекомендуемая задержка повтора (для RETRY_LATER)
+}
+
+func (e *BackendError) Error() string {
+	return e.Message
+}
+
+// Ошибки, соответствующие кодам ошибок octet
+var backendErrorCodes = map[protocol.ErrorCode]error{
+	protocol.ErrorCodeNotFound:        ErrNotFound,
+	protocol.ErrorCodeInvalidUtf8:     ErrInvalidData,
+	protocol.ErrorCodeStorageFull:     ErrStorageFull,
+	protocol.ErrorCodeRetryLater:      ErrRetryLater,
+	protocol.ErrorCodeVersionMismatch: ErrVersionMismatch,
+	protocol.ErrorCodeAlreadyExists:   ErrAlreadyExists,
+}
+
+// Сопоставление кода ошибки octet с ErrNotFound, ErrInvalidData, ErrStorageFull, ErrRetryLater,
+// ErrVersionMismatch и ErrAlreadyExists для errors.Is
+func (e *BackendError) Is(target error) bool {
+	mapped, ok := backendErrorCodes[e.Code]
+	return ok && target == mapped
+}
+
+// Счетчики событий клиентов
+type ClientMetrics struct {
+	OversizedFrames    atomic.Uint64  // Количество сообщений, отклоненных из-за превышения размера
+	BackendUnavailable atomic.Uint64  // Количество отказов из-за недоступности octet
+	PoolExhausted      atomic.Uint64  // Количество отказов из-за отсутствия свободных клиентов
+	BackendTimeouts    atomic.Uint64  // Количество запросов, на которые octet не ответил вовремя
+	Mutations          atomic.Uint64  // Количество успешных изменений данных (insert/update/remove)
+	Commands           CommandMetrics // Счетчики и гистограммы по типам команд
+	Latency            LatencyTracker // Процентили длительности последних команд
+}
+
+// Учет ошибки взаимодействия с octet в счетчиках
+func (m *ClientMetrics) record(err error) {
+	if m == nil {
+		return
+	}
+	switch {
+	case errors.Is(err, ErrTimeout):
+		m.BackendTimeouts.Add(1)
+	case errors.Is(err, ErrPoolExhausted):
+		m.PoolExhausted.Add(1)
+	case errors.Is(err, ErrBackendUnavailable):
+		m.BackendUnavailable.Add(1)
+	}
+}
+
+// Учет успешного изменения данных
+func (m *ClientMetrics) recordMutation() {
+	if m != nil {
+		m.Mutations.Add(1)
+	}
+}
+
+// Определение класса ошибки ввода-вывода на соединении с octet
+func ioErrorClass(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ErrBackendUnavailable
 }
 
 // Клиент для взаимодействия с C++ процессом
 type Client struct {
-	config ClientConfig
-	conn   net.Conn
-	mutex  sync.Mutex
+	config       ClientConfig
+	conn         net.Conn
+	mutex        sync.Mutex
+	lastActivity time.Time // Время последнего обмена данными с octet
 }
 
 // Создание нового клиента
@@ -51,19 +194,50 @@ func (c *Client) Connect() error {
 	if c.conn != nil {
 		c.conn.Close()
 		c.conn = nil
+		c.config.Events.publish(StateEvent{Kind: StateConnectionClosed})
 	}
 
-	// Устанавливаем новое соединение с таймаутом
-	dialer := net.Dialer{Timeout: c.config.ConnTimeout}
-	conn, err := dialer.Dial("unix", c.config.SocketPath)
+	conn, err := c.dial()
+	for attempt := 0; err != nil && retryableDial(err) && c.config.DialRetry != nil && attempt < c.config.DialRetry.Attempts; attempt++ {
+		time.Sleep(c.config.DialRetry.delay(attempt))
+		conn, err = c.dial()
+	}
 	if err != nil {
-		return fmt.Errorf("не удалось подключиться к сокету: %w", err)
+		return err
+	}
+
+	// Не доверяем соединению с посторонним процессом, занявшим путь сокета
+	if c.config.Peer != nil {
+		if err := c.config.Peer.VerifyPeer(conn); err != nil {
+			conn.Close()
+			return fmt.Errorf("процесс на сокете не прошел проверку: %w", err)
+		}
 	}
 
 	c.conn = conn
+	c.lastActivity = time.Now()
+	c.config.Events.publish(StateEvent{Kind: StateConnectionOpened})
 	return nil
 }
 
+// Подключение к сокету с проверкой прав доступа
+func (c *Client) dial() (net.Conn, error) {
+	// Не подключаемся к сокету, доступ к которому не ограничен
+	if c.config.SocketPermissions != nil {
+		if err := c.config.SocketPermissions.Verify(c.config.SocketPath); err != nil {
+			return nil, fmt.Errorf("сокет не прошел проверку прав доступа: %w", err)
+		}
+	}
+
+	// Устанавливаем новое соединение с таймаутом
+	dialer := net.Dialer{Timeout: c.config.ConnTimeout}
+	conn, err := dialer.Dial("unix", c.config.SocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к сокету: %w", err)
+	}
+	return conn, nil
+}
+
 // Закрытие соединения
 func (c *Client) Close() error {
 	c.mutex.Lock()
@@ -72,11 +246,20 @@ func (c *Client) Close() error {
 	if c.conn != nil {
 		err := c.conn.Close()
 		c.conn = nil
+		c.config.Events.publish(StateEvent{Kind: StateConnectionClosed})
 		return err
 	}
 	return nil
 }
 
+// Закрытие соединения после ошибки при выполнении команды command (вызывается с удержанием блокировки)
+func (c *Client) dropConnection(reason DiscardReason, command protocol.CommandType, cause error) {
+	c.conn.Close()
+	c.conn = nil
+	c.config.Discards.record(reason, command, cause)
+	c.config.Events.publish(StateEvent{Kind: StateConnectionBroken, Error: cause.Error()})
+}
+
 // Проверка, установлено ли соединение
 func (c *Client) IsConnected() bool {
 	c.mutex.Lock()
@@ -86,103 +269,536 @@ func (c *Client) IsConnected() bool {
 
 // Отправка запроса и получение ответа
 func (c *Client) SendAndGet(req *protocol.Request) (*protocol.Response, error) {
+	startedAt := time.Now()
+	resp, err := c.sendAndGet(req)
+	if c.config.Metrics != nil {
+		elapsed := time.Since(startedAt)
+		c.config.Metrics.Commands.observe(req, resp, elapsed, err)
+		// Учитываются только команды, на которые octet ответил или не ответил вовремя:
+		// отказы до отправки запроса не отражают скорость octet
+		if result := commandResult(err); result != ResultUnavailable && result != ResultError {
+			c.config.Metrics.Latency.observe(req.Command, elapsed)
+		}
+	}
+	if err != nil {
+		c.config.Metrics.record(err)
+	}
+	return resp, err
+}
+
+// Обмен запросом и ответом без учета ошибок в счетчиках
+func (c *Client) sendAndGet(req *protocol.Request) (*protocol.Response, error) {
+	// Пока octet просит повторить изменения позже, они не отправляются
+	if wait := c.config.Breaker.remaining(req.Command); wait > 0 {
+		return nil, &BackendError{Command: req.Command, Code: protocol.ErrorCodeRetryLater,
+			Message: ErrRetryLater.Error(), RetryAfter: wait}
+	}
+
 	// Проверяем соединение
 	if !c.IsConnected() {
-		return nil, fmt.Errorf("соединение не установлено")
+		return nil, fmt.Errorf("%w: соединение не установлено", ErrBackendUnavailable)
 	}
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	// Внедряем сбой, если включен режим внедрения сбоев
+	// (задержка выполняется до установки таймаута записи)
+	fault := c.config.Faults.beforeSend()
+	if fault == faultReset {
+		c.dropConnection(DiscardInjectedFault, req.Command, ErrInjectedFault)
+		return nil, fmt.Errorf("%w: соединение разорвано (%w)", ErrBackendUnavailable, ErrInjectedFault)
+	}
+
 	// Устанавливаем таймаут записи
 	if err := c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout)); err != nil {
 		return nil, fmt.Errorf("не удалось установить таймаут записи: %w", err)
 	}
 
-	// Отправляем запрос
-	if err := protocol.WriteFrame(c.conn, req); err != nil {
+	// Отправляем запрос (потерянный запрос не отправляется, и ответ не придет до истечения таймаута чтения)
+	var err error
+	if fault != faultDrop {
+		err = protocol.WriteFrame(c.conn, req)
+	}
+	c.config.Recorder.RecordRequest(req, err)
+	if err != nil {
 		// Закрываем соединение при ошибке
-		c.conn.Close()
-		c.conn = nil
-		return nil, fmt.Errorf("ошибка отправки запроса: %w", err)
+		c.dropConnection(DiscardWriteError, req.Command, err)
+		return nil, fmt.Errorf("%w: ошибка отправки запроса: %w", ioErrorClass(err), err)
 	}
 
 	// Устанавливаем таймаут чтения
-	if err := c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout)); err != nil {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout(req.Command))); err != nil {
 		return nil, fmt.Errorf("не удалось установить таймаут чтения: %w", err)
 	}
 
 	// Читаем ответ
-	resp, err := protocol.ReadFrame(c.conn)
+	sentAt := time.Now()
+	resp, err := protocol.ReadFrameLimit(c.conn, c.config.MaxFrameSize)
+	c.config.Recorder.RecordResponse(resp, time.Since(sentAt), err)
 	if err != nil {
-		// Закрываем соединение при ошибке
-		c.conn.Close()
-		c.conn = nil
-		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+		// Закрываем соединение при ошибке: после отклоненного или частично
+		// прочитанного сообщения граница следующего фрейма неизвестна
+		c.dropConnection(readDiscardReason(err), req.Command, err)
+		if errors.Is(err, protocol.ErrFrameTooLarge) && c.config.Metrics != nil {
+			c.config.Metrics.OversizedFrames.Add(1)
+		}
+		if errors.Is(err, protocol.ErrFrameTooLarge) {
+			return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+		}
+		return nil, fmt.Errorf("%w: ошибка чтения ответа: %w", ioErrorClass(err), err)
 	}
 
+	c.lastActivity = time.Now()
+
 	// Проверяем, что ID запроса совпадает с ID ответа
 	if resp.RequestId != req.RequestId {
-		return nil, fmt.Errorf("несоответствие ID запроса и ответа: %s != %s", req.RequestId, resp.RequestId)
+		defer protocol.ReleaseResponse(resp)
+		// Ответ относится к другому запросу, поэтому следующие ответы на соединении
+		// тоже не соответствуют запросам: соединение закрывается
+		err := fmt.Errorf("несоответствие ID запроса и ответа: %s != %s", req.RequestId, resp.RequestId)
+		c.dropConnection(DiscardRequestMismatch, req.Command, err)
+		return nil, err
 	}
 
 	// Если операция не успешна, возвращаем ошибку
 	if !resp.Success {
-		return nil, fmt.Errorf("%s", resp.Error)
+		defer protocol.ReleaseResponse(resp)
+		backendErr := &BackendError{Command: req.Command, Code: resp.ErrorCode, Message: resp.Error}
+		if resp.ErrorCode == protocol.ErrorCodeRetryLater {
+			backendErr.RetryAfter = time.Duration(resp.RetryAfterMs) * time.Millisecond
+			c.config.Breaker.trip(backendErr.RetryAfter)
+		}
+		return nil, backendErr
 	}
 
 	return resp, nil
 }
 
+// Таймаут чтения ответа на команду
+func (c *Client) readTimeout(command protocol.CommandType) time.Duration {
+	if c.config.Metrics == nil {
+		return c.config.ReadTimeout
+	}
+	return c.config.AdaptiveTimeout.timeout(&c.config.Metrics.Latency, command, c.config.ReadTimeout)
+}
+
+// Обмен heartbeat-фреймами с octet для проверки живости соединения
+func (c *Client) Heartbeat(timeout time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("соединение не установлено")
+	}
+
+	// Устанавливаем общий таймаут на отправку и получение heartbeat
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("не удалось установить таймаут heartbeat: %w", err)
+	}
+
+	// Отправляем heartbeat и ждем ответный фрейм
+	err := protocol.WriteHeartbeat(c.conn)
+	if err == nil {
+		err = protocol.ReadHeartbeat(c.conn)
+	}
+	if err != nil {
+		// Закрываем полуоткрытое соединение, оно будет переустановлено при следующем использовании
+		c.dropConnection(DiscardHeartbeat, "", err)
+		return err
+	}
+
+	c.lastActivity = time.Now()
+	return nil
+}
+
+// Время простоя соединения (с момента последнего обмена данными)
+func (c *Client) IdleTime() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return time.Since(c.lastActivity)
+}
+
+// Проверка контрольной суммы, возвращенной octet, если проверка включена
+func (c *Client) verifyChecksum(resp *protocol.Response, data string) error {
+	return c.compareChecksum(resp.Params.Checksum, data)
+}
+
+// Сравнение контрольной суммы, возвращенной octet, с контрольной суммой отправленных данных
+func (c *Client) compareChecksum(checksum, data string) error {
+	if !c.config.VerifyChecksum {
+		return nil
+	}
+	if checksum == "" {
+		return fmt.Errorf("%w: octet не вернул контрольную сумму", ErrIntegrity)
+	}
+	if expected := protocol.Checksum(data); checksum != expected {
+		return fmt.Errorf("%w: контрольная сумма сохраненных данных %s не совпадает с отправленной %s",
+			ErrIntegrity, checksum, expected)
+	}
+	return nil
+}
+
+// Идентификатор нового запроса к octet
+func (c *Client) newRequestID() string {
+	if c.config.RequestIDv7 {
+		if id, err := guuid.NewV7(); err == nil {
+			return id.String()
+		}
+	}
+	return guuid.New().String()
+}
+
 // Выполнение octet::insert
 func (c *Client) Insert(ctx context.Context, data string) (string, error) {
-	requestId := guuid.New().String()
-	req := protocol.NewInsertRequest(requestId, data)
+	return c.InsertWithTTL(ctx, data, 0)
+}
+
+// Выполнение octet::insert со сроком хранения строки в секундах (0 - без ограничения).
+// По истечении срока octet удаляет строку сам.
+func (c *Client) InsertWithTTL(ctx context.Context, data string, ttlSeconds uint64) (string, error) {
+	return c.InsertAs(ctx, "", data, ttlSeconds)
+}
+
+// Выполнение octet::insert с указанным UUID (пустой - octet выдает новый): строка добавляется,
+// только если строки с таким UUID еще нет, иначе возвращается ErrAlreadyExists
+func (c *Client) InsertAs(ctx context.Context, uuid, data string, ttlSeconds uint64) (string, error) {
+	requestId := c.newRequestID()
+	req := withTrace(ctx, protocol.NewInsertRequest(requestId, data))
+	defer protocol.ReleaseRequest(req)
+	req.Params.Uuid = uuid
+	req.Params.WithChecksum = c.config.VerifyChecksum
+	req.Params.TtlSeconds = ttlSeconds
+	if err := c.config.KeyLimit.reserve(); err != nil {
+		return "", err
+	}
+	intent, err := c.config.Intents.Begin(protocol.CommandInsert, uuid, data)
+	if err != nil {
+		c.config.KeyLimit.release()
+		return "", err
+	}
 	resp, err := c.SendAndGet(req)
+	c.config.Intents.Complete(intent, err)
 	if err != nil {
+		c.config.KeyLimit.release()
 		return "", err
 	}
+	defer protocol.ReleaseResponse(resp)
 	if resp.Params.Uuid == "" {
 		return "", fmt.Errorf("получен пустой UUID в ответе")
 	}
+	c.config.Metrics.recordMutation()
+	if err := c.verifyChecksum(resp, data); err != nil {
+		return "", fmt.Errorf("%w (UUID: %s)", err, resp.Params.Uuid)
+	}
 	return resp.Params.Uuid, nil
 }
 
 // Выполнение octet::get
 func (c *Client) Get(ctx context.Context, uuid string) (string, error) {
-	requestId := guuid.New().String()
-	req := protocol.NewGetRequest(requestId, uuid)
+	data, _, err := c.GetVersion(ctx, uuid)
+	return data, err
+}
+
+// Выполнение octet::get с версией строки, соответствующей возвращенным данным
+// (0 - версия неизвестна, например для строки, добавленной до появления метаданных в octet)
+func (c *Client) GetVersion(ctx context.Context, uuid string) (string, uint64, error) {
+	requestId := c.newRequestID()
+	req := withTrace(ctx, protocol.NewGetRequest(requestId, uuid))
+	defer protocol.ReleaseRequest(req)
 	resp, err := c.SendAndGet(req)
 	if err != nil {
-		return "", err
+		return "", 0, err
+	}
+	defer protocol.ReleaseResponse(resp)
+	return resp.Params.Data, resp.Params.Version, nil
+}
+
+// Выполнение octet::exists: наличие строки проверяется без передачи ее данных
+func (c *Client) Exists(ctx context.Context, uuid string) (bool, error) {
+	req := withTrace(ctx, protocol.NewExistsRequest(c.newRequestID(), uuid))
+	defer protocol.ReleaseRequest(req)
+	resp, err := c.SendAndGet(req)
+	protocol.ReleaseResponse(resp)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
 	}
-	return resp.Params.Data, nil
+	return true, nil
+}
+
+// Размер и метаданные строки
+type EntryStat struct {
+	Size       uint64    // Размер данных в байтах
+	CreatedAt  time.Time // Время добавления (нулевое - неизвестно)
+	ModifiedAt time.Time // Время последнего изменения (нулевое - неизвестно)
+	Version    uint64    // Версия строки (0 - неизвестна)
+	ExpiresAt  time.Time // Момент истечения срока хранения (нулевое - без ограничения)
+}
+
+// Выполнение octet::stat: размер и метаданные строки без передачи ее данных.
+// Время и версия строки, добавленной до появления метаданных в octet, неизвестны.
+func (c *Client) Stat(ctx context.Context, uuid string) (EntryStat, error) {
+	req := withTrace(ctx, protocol.NewStatRequest(c.newRequestID(), uuid))
+	defer protocol.ReleaseRequest(req)
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return EntryStat{}, err
+	}
+	defer protocol.ReleaseResponse(resp)
+
+	stat := EntryStat{Size: resp.Params.Size, Version: resp.Params.Version}
+	for _, field := range []struct {
+		value string
+		dst   *time.Time
+	}{
+		{resp.Params.CreatedAt, &stat.CreatedAt},
+		{resp.Params.ModifiedAt, &stat.ModifiedAt},
+		{resp.Params.ExpiresAt, &stat.ExpiresAt},
+	} {
+		if len(field.value) == 0 {
+			continue
+		}
+		parsed, err := time.Parse(protocol.JournalTimeFormat, field.value)
+		if err != nil {
+			return EntryStat{}, fmt.Errorf("некорректное время в ответе на stat: %w", err)
+		}
+		*field.dst = parsed
+	}
+	return stat, nil
 }
 
 // Выполнение octet::update
 func (c *Client) Update(ctx context.Context, uuid, data string) error {
-	requestID := guuid.New().String()
-	req := protocol.NewUpdateRequest(requestID, uuid, data)
-	_, err := c.SendAndGet(req)
-	return err
+	return c.UpdateIf(ctx, uuid, data, 0)
+}
+
+// Выполнение octet::update только при совпадении версии строки с expectedVersion
+// (0 - без проверки версии). При несовпадении возвращается ErrVersionMismatch.
+func (c *Client) UpdateIf(ctx context.Context, uuid, data string, expectedVersion uint64) error {
+	requestID := c.newRequestID()
+	req := withTrace(ctx, protocol.NewUpdateRequest(requestID, uuid, data))
+	defer protocol.ReleaseRequest(req)
+	req.Params.WithChecksum = c.config.VerifyChecksum
+	req.Params.ExpectedVersion = expectedVersion
+	intent, err := c.config.Intents.Begin(protocol.CommandUpdate, uuid, data)
+	if err != nil {
+		return err
+	}
+	resp, err := c.SendAndGet(req)
+	c.config.Intents.Complete(intent, err)
+	if err != nil {
+		return err
+	}
+	defer protocol.ReleaseResponse(resp)
+	c.config.Metrics.recordMutation()
+	return c.verifyChecksum(resp, data)
 }
 
 // Выполнение octet::remove
 func (c *Client) Remove(ctx context.Context, uuid string) error {
-	requestID := guuid.New().String()
-	req := protocol.NewRemoveRequest(requestID, uuid)
-	_, err := c.SendAndGet(req)
-	return err
+	requestID := c.newRequestID()
+	req := withTrace(ctx, protocol.NewRemoveRequest(requestID, uuid))
+	defer protocol.ReleaseRequest(req)
+	intent, err := c.config.Intents.Begin(protocol.CommandRemove, uuid, "")
+	if err != nil {
+		return err
+	}
+	resp, err := c.SendAndGet(req)
+	protocol.ReleaseResponse(resp)
+	c.config.Intents.Complete(intent, err)
+	if err != nil {
+		return err
+	}
+	c.config.Metrics.recordMutation()
+	c.config.KeyLimit.removed()
+	return nil
 }
 
 // Выполнение octet::ping
 func (c *Client) Ping(ctx context.Context) error {
-	requestID := guuid.New().String()
-	req := protocol.NewPingRequest(requestID)
-	_, err := c.SendAndGet(req)
+	requestID := c.newRequestID()
+	req := withTrace(ctx, protocol.NewPingRequest(requestID))
+	defer protocol.ReleaseRequest(req)
+	resp, err := c.SendAndGet(req)
+	protocol.ReleaseResponse(resp)
 	return err
 }
 
+// Выполнение octet::stats, возвращает количество записей в хранилище
+func (c *Client) Stats(ctx context.Context) (uint64, error) {
+	requestID := c.newRequestID()
+	req := withTrace(ctx, protocol.NewStatsRequest(requestID))
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Params.Count, nil
+}
+
+// Выполнение octet::count, возвращает количество строк в хранилище
+func (c *Client) Count(ctx context.Context) (uint64, error) {
+	req := withTrace(ctx, protocol.NewCountRequest(c.newRequestID()))
+	defer protocol.ReleaseRequest(req)
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return 0, err
+	}
+	defer protocol.ReleaseResponse(resp)
+	return resp.Params.Count, nil
+}
+
+// Состояние последнего сжатия хранилища
+type CompactionStatus struct {
+	State             string `json:"state"`               // idle, running, completed или failed
+	JournalSizeBefore uint64 `json:"journal_size_before"` // Размер журнала до сжатия в байтах
+	JournalSizeAfter  uint64 `json:"journal_size_after"`  // Размер журнала после сжатия в байтах
+}
+
+// Запуск сжатия хранилища (если сжатие уже выполняется, возвращается его состояние)
+func (c *Client) Compact(ctx context.Context) (CompactionStatus, error) {
+	return c.compaction(withTrace(ctx, protocol.NewCompactRequest(c.newRequestID())))
+}
+
+// Состояние последнего сжатия хранилища
+func (c *Client) CompactionStatus(ctx context.Context) (CompactionStatus, error) {
+	return c.compaction(withTrace(ctx, protocol.NewCompactStatusRequest(c.newRequestID())))
+}
+
+func (c *Client) compaction(req *protocol.Request) (CompactionStatus, error) {
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return CompactionStatus{}, err
+	}
+	return CompactionStatus{
+		State:             resp.Params.CompactionState,
+		JournalSizeBefore: resp.Params.JournalSizeBefore,
+		JournalSizeAfter:  resp.Params.JournalSizeAfter,
+	}, nil
+}
+
+// Записи журнала операций octet начиная с момента since (пустая строка - с начала журнала).
+// more - остались ли записи, не вошедшие в ответ.
+func (c *Client) Journal(ctx context.Context, since string, skip, limit int) (entries []protocol.JournalEntry, more bool, err error) {
+	req := withTrace(ctx, protocol.NewJournalRequest(c.newRequestID(), since, skip, limit))
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Params.Journal, resp.Params.More, nil
+}
+
+// Страница UUID строк хранилища в порядке возрастания, следующих за after (пустая строка - с начала).
+// more - остались ли UUID, не вошедшие в ответ.
+func (c *Client) List(ctx context.Context, after string, limit int) (uuids []string, more bool, err error) {
+	req := withTrace(ctx, protocol.NewListRequest(c.newRequestID(), after, limit))
+	resp, err := c.SendAndGet(req)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Params.Uuids, resp.Params.More, nil
+}
+
+// Результат операции, выполненной в составе octet::batch
+type BatchResult struct {
+	Uuid string // UUID добавленной строки (для insert)
+	Err  error
+}
+
+// Выполнение octet::batch: операции выполняются octet по порядку одним запросом.
+// Ошибка возвращается, если запрос не выполнен целиком, иначе результат каждой операции - в BatchResult.
+// Добавления сверх ограничения количества строк не отправляются и завершаются ErrKeyLimitReached.
+func (c *Client) Batch(ctx context.Context, operations []protocol.BatchOperation) ([]BatchResult, error) {
+	results := make([]BatchResult, len(operations))
+	send := make([]protocol.BatchOperation, 0, len(operations))
+	indexes := make([]int, 0, len(operations)) // Номера отправляемых операций в пакете
+	for i, op := range operations {
+		if op.Command == protocol.CommandInsert {
+			if err := c.config.KeyLimit.reserve(); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+		send = append(send, op)
+		indexes = append(indexes, i)
+	}
+	// Отмена резервирования неотправленных или невыполненных добавлений
+	release := func(op protocol.BatchOperation) {
+		if op.Command == protocol.CommandInsert {
+			c.config.KeyLimit.release()
+		}
+	}
+	if len(send) == 0 {
+		return results, nil
+	}
+
+	req := withTrace(ctx, protocol.NewBatchRequest(c.newRequestID(), send))
+	req.Params.WithChecksum = c.config.VerifyChecksum
+
+	intents := make([]uint64, 0, len(send))
+	for _, op := range send {
+		intent, err := c.config.Intents.Begin(op.Command, op.Uuid, op.Data)
+		if err != nil {
+			// Запрос не отправлен, поэтому записанные намерения завершаются
+			for _, id := range intents {
+				c.config.Intents.Complete(id, err)
+			}
+			for _, op := range send {
+				release(op)
+			}
+			return nil, err
+		}
+		intents = append(intents, intent)
+	}
+
+	resp, err := c.SendAndGet(req)
+	if err == nil && len(resp.Params.Results) != len(send) {
+		err = fmt.Errorf("количество результатов в ответе (%d) не совпадает с количеством операций (%d)",
+			len(resp.Params.Results), len(send))
+	}
+	if err != nil {
+		for _, id := range intents {
+			c.config.Intents.Complete(id, err)
+		}
+		for _, op := range send {
+			release(op)
+		}
+		return nil, err
+	}
+
+	for j, op := range send {
+		i := indexes[j]
+		result := resp.Params.Results[j]
+		if !result.Success {
+			results[i].Err = &BackendError{Command: op.Command, Code: result.ErrorCode, Message: result.Error}
+			c.config.Intents.Complete(intents[j], results[i].Err)
+			release(op)
+			continue
+		}
+		c.config.Intents.Complete(intents[j], nil)
+		c.config.Metrics.recordMutation()
+		if op.Command == protocol.CommandRemove {
+			c.config.KeyLimit.removed()
+		}
+
+		if op.Command == protocol.CommandInsert {
+			if result.Uuid == "" {
+				results[i].Err = fmt.Errorf("получен пустой UUID в ответе")
+				continue
+			}
+			results[i].Uuid = result.Uuid
+		}
+		if op.Command != protocol.CommandRemove {
+			if err := c.compareChecksum(result.Checksum, op.Data); err != nil {
+				results[i].Err = fmt.Errorf("%w (UUID: %s)", err, cmp.Or(result.Uuid, op.Uuid))
+			}
+		}
+	}
+	return results, nil
+}
+
 // Конфигурация для пула клиентов
 type ClientPoolConfig struct {
 	SocketPath    string        // Путь к сокету
@@ -191,13 +807,59 @@ type ClientPoolConfig struct {
 	ReadTimeout   time.Duration // Таймаут чтения
 	WriteTimeout  time.Duration // Таймаут записи
 	ClientTimeout time.Duration // Время ожидания клиента
+	MaxFrameSize  int64         // Максимальный размер сообщения от octet
+	// Интервал обмена heartbeat-фреймами на простаивающих соединениях
+	// (0 - значение по умолчанию, отрицательное значение отключает heartbeat)
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration    // Таймаут ожидания ответного heartbeat-фрейма
+	VerifyChecksum    bool             // Проверка контрольной суммы сохраненных данных при insert/update
+	RequestIDv7       bool             // Идентификаторы запросов к octet в виде UUIDv7
+	KeyLimit          *KeyLimit        // Ограничение количества строк в хранилище (nil - без ограничения)
+	Events            *StateEvents     // События изменения состояния соединений (nil - не рассылаются)
+	Recorder          *TrafficRecorder // Запись обмена сообщениями с octet (nil - отключена)
+	Faults            *FaultInjector   // Внедрение сбоев (nil - отключено)
+	Intents           *IntentLog       // Журнал намерений изменений (nil - отключен)
+	// Максимальное количество одновременных проверок heartbeat (0 - значение по умолчанию)
+	HeartbeatConcurrency int
+	// Доля интервала heartbeat, по которой случайно распределяется начало проверок
+	// соединений при обходе пула (0 - все проверки начинаются сразу)
+	HeartbeatJitter float64
+	// Ожидаемые права доступа к сокету, проверяемые перед подключением (nil - без проверки)
+	SocketPermissions *SocketPermissions
+	// Проверка, что на другом конце сокета находится процесс octet, запущенный сервером
+	VerifyPeer bool
+	// Таймаут чтения по длительности последних выполнений команды (nil - всегда ReadTimeout)
+	AdaptiveTimeout *AdaptiveTimeout
+	// Повторные попытки подключения, пока octet не принимает соединения (nil - без повторов)
+	DialRetry *DialRetry
+	// Доли пула, которые могут одновременно занимать запросы чтения и изменения строк
+	// (0 - без ограничения). Остаток пула остается доступным остальным классам запросов.
+	ReadShare  float64
+	WriteShare float64
 }
 
+// Класс запросов для разделения пула
+type PoolClass string
+
+const (
+	PoolClassRead   PoolClass = "read"   // Чтение строк
+	PoolClassWrite  PoolClass = "write"  // Изменение строк
+	PoolClassSystem PoolClass = "system" // Проверка состояния и административные команды (без ограничения)
+)
+
 // Пул клиентов, взаимодействующих с процессом octet
 type ClientPool struct {
 	config         ClientPoolConfig
 	clients        chan *Client
 	processManager *ProcessManager
+	metrics        *ClientMetrics
+	breaker        *WriteBreaker
+	discards       *DiscardLog
+	logger         *zap.Logger
+	waiting        atomic.Int64                // Количество запросов, ожидающих свободного клиента
+	partitions     map[PoolClass]chan struct{} // Занятые клиенты классов запросов с ограниченной долей пула
+	done           chan struct{}               // Закрывается при закрытии пула
+	wg             sync.WaitGroup              // Фоновые горутины пула
 }
 
 // Создание нового пула клиентов
@@ -227,21 +889,70 @@ func NewClientPool(config ClientPoolConfig, logger *zap.Logger, pm *ProcessManag
 	if config.WriteTimeout == 0 {
 		config.WriteTimeout = 30 * time.Second
 	}
+	if config.MaxFrameSize <= 0 {
+		config.MaxFrameSize = protocol.DefaultMaxFrameSize
+	}
+	if config.HeartbeatInterval == 0 {
+		config.HeartbeatInterval = 5 * time.Second
+	}
+	if config.HeartbeatTimeout <= 0 {
+		config.HeartbeatTimeout = 2 * time.Second
+	}
+	if config.HeartbeatConcurrency <= 0 {
+		config.HeartbeatConcurrency = 4
+	}
+	config.HeartbeatJitter = min(max(config.HeartbeatJitter, 0), 1)
+	if config.AdaptiveTimeout != nil && config.AdaptiveTimeout.Max == 0 {
+		adaptive := *config.AdaptiveTimeout
+		adaptive.Max = config.ReadTimeout
+		config.AdaptiveTimeout = &adaptive
+	}
 
 	// Создаем пул
 	pool := &ClientPool{
 		config:         config,
 		clients:        make(chan *Client, config.MaxClients),
 		processManager: pm,
+		metrics:        &ClientMetrics{},
+		breaker:        &WriteBreaker{},
+		discards:       NewDiscardLog(discardHistorySize),
+		logger:         logger,
+		done:           make(chan struct{}),
+		partitions:     make(map[PoolClass]chan struct{}),
+	}
+	for class, share := range map[PoolClass]float64{PoolClassRead: config.ReadShare, PoolClassWrite: config.WriteShare} {
+		if limit := max(1, int(share*float64(config.MaxClients))); share > 0 && limit < config.MaxClients {
+			pool.partitions[class] = make(chan struct{}, limit)
+		}
+	}
+
+	var peer *ProcessManager
+	if config.VerifyPeer {
+		peer = pm
 	}
 
 	// Создаем и подключаем клиентов
 	for i := range config.MaxClients {
 		client, err := NewClient(ClientConfig{
-			SocketPath:   config.SocketPath,
-			ConnTimeout:  config.ConnTimeout,
-			ReadTimeout:  config.ReadTimeout,
-			WriteTimeout: config.WriteTimeout,
+			SocketPath:        config.SocketPath,
+			ConnTimeout:       config.ConnTimeout,
+			ReadTimeout:       config.ReadTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			MaxFrameSize:      config.MaxFrameSize,
+			Metrics:           pool.metrics,
+			VerifyChecksum:    config.VerifyChecksum,
+			RequestIDv7:       config.RequestIDv7,
+			KeyLimit:          config.KeyLimit,
+			Events:            config.Events,
+			Recorder:          config.Recorder,
+			Faults:            config.Faults,
+			Intents:           config.Intents,
+			SocketPermissions: config.SocketPermissions,
+			Peer:              peer,
+			AdaptiveTimeout:   config.AdaptiveTimeout,
+			Breaker:           pool.breaker,
+			DialRetry:         config.DialRetry,
+			Discards:          pool.discards,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("не удалось создать клиент %d: %w", i, err)
@@ -257,48 +968,354 @@ func NewClientPool(config ClientPoolConfig, logger *zap.Logger, pm *ProcessManag
 		pool.clients <- client
 	}
 
+	// Запускаем периодическую проверку простаивающих соединений
+	if config.HeartbeatInterval > 0 {
+		pool.wg.Add(1)
+		go pool.heartbeatLoop()
+	}
+
 	return pool, nil
 }
 
-// Получение клиента из пула
+// Периодический обмен heartbeat-фреймами на простаивающих соединениях,
+// чтобы обнаруживать полуоткрытые сокеты до прихода пользовательского запроса
+func (p *ClientPool) heartbeatLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.checkIdleClients()
+		}
+	}
+}
+
+// Проверка свободных клиентов, простаивающих дольше интервала heartbeat.
+// Одновременно выполняется не больше HeartbeatConcurrency проверок, а их начало случайно
+// распределяется по доле интервала HeartbeatJitter, чтобы octet не получал heartbeat-фреймы
+// всех простаивающих соединений одновременно.
+func (p *ClientPool) checkIdleClients() {
+	// Проверяем только те клиенты, которые свободны на момент начала обхода
+	idleCount := len(p.clients)
+	if idleCount == 0 {
+		return
+	}
+	workers := min(idleCount, p.config.HeartbeatConcurrency)
+	// Каждый обработчик выполняет около idleCount/workers проверок, поэтому задержка перед
+	// каждой из них ограничена так, чтобы обход укладывался в окно разброса
+	window := time.Duration(p.config.HeartbeatJitter * float64(p.config.HeartbeatInterval))
+	maxDelay := window / time.Duration((idleCount+workers-1)/workers)
+
+	var remaining atomic.Int64
+	remaining.Store(int64(idleCount))
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for remaining.Add(-1) >= 0 {
+				if !p.heartbeatDelay(maxDelay) || !p.checkIdleClient() {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Случайная задержка перед проверкой соединения. Возвращает false при закрытии пула.
+func (p *ClientPool) heartbeatDelay(maxDelay time.Duration) bool {
+	if maxDelay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(rand.N(maxDelay))
+	defer timer.Stop()
+	select {
+	case <-p.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Проверка одного свободного клиента с возвратом в пул сразу после проверки.
+// Возвращает false, если свободных клиентов не осталось.
+func (p *ClientPool) checkIdleClient() bool {
+	var client *Client
+	select {
+	case client = <-p.clients:
+	default:
+		return false
+	}
+
+	if client.IsConnected() && client.IdleTime() >= p.config.HeartbeatInterval {
+		if err := client.Heartbeat(p.config.HeartbeatTimeout); err != nil {
+			p.logger.Warn("Соединение с octet не ответило на heartbeat и было закрыто", zap.Error(err))
+		}
+	}
+	p.clients <- client
+	return true
+}
+
+// Закрытие соединений свободных клиентов (например, после перезапуска octet).
+// Клиенты переподключаются при следующем использовании.
+func (p *ClientPool) DisconnectIdle() {
+	idleCount := len(p.clients)
+	for range idleCount {
+		var client *Client
+		select {
+		case client = <-p.clients:
+		default:
+			return
+		}
+		client.Close()
+		p.clients <- client
+	}
+}
+
+// Изъятие всех клиентов из пула после завершения выполняющихся запросов (например, перед остановкой octet).
+// Новые запросы ожидают клиентов, пока они не будут возвращены вызовом Restore.
+// Если за timeout освободились не все клиенты, изъятые клиенты возвращаются в пул.
+func (p *ClientPool) Drain(timeout time.Duration) ([]*Client, error) {
+	drained := make([]*Client, 0, p.config.MaxClients)
+	deadline := time.After(timeout)
+	for len(drained) < p.config.MaxClients {
+		select {
+		case client := <-p.clients:
+			drained = append(drained, client)
+		case <-deadline:
+			inUse := p.config.MaxClients - len(drained)
+			p.Restore(drained)
+			return nil, fmt.Errorf("за %v не завершились выполняющиеся запросы (занято клиентов: %d)", timeout, inUse)
+		}
+	}
+	return drained, nil
+}
+
+// Возврат изъятых клиентов в пул. Соединения закрываются, так как могли относиться к прежнему
+// процессу octet, и клиенты переподключаются при следующем использовании.
+func (p *ClientPool) Restore(clients []*Client) {
+	for _, client := range clients {
+		client.Close()
+		p.clients <- client
+	}
+}
+
+// Ошибка получения клиента из пула с состоянием пула на момент отказа
+type PoolError struct {
+	Err     error         // Исходная ошибка
+	InUse   int           // Количество занятых клиентов
+	Size    int           // Размер пула
+	Waiting int64         // Количество других запросов, ожидающих клиента
+	Waited  time.Duration // Время, проведенное в ожидании клиента
+}
+
+func (e *PoolError) Error() string {
+	return fmt.Sprintf("%v (занято клиентов: %d/%d, в очереди: %d, ожидание: %v)",
+		e.Err, e.InUse, e.Size, e.Waiting, e.Waited.Round(time.Millisecond))
+}
+
+func (e *PoolError) Unwrap() error {
+	return e.Err
+}
+
+// Получение клиента из пула для проверки состояния и административных команд
 func (p *ClientPool) GetClient() (*PooledClient, error) {
+	return p.GetClientFor(PoolClassSystem)
+}
+
+// Получение клиента из пула для запроса указанного класса.
+// Если доля пула класса занята, запрос ожидает освобождения клиента этого класса.
+func (p *ClientPool) GetClientFor(class PoolClass) (*PooledClient, error) {
+	start := time.Now()
+	slots := p.partitions[class]
+	client, err := p.acquireSlot(slots, class)
+	if err == nil {
+		client, err = p.getClient(p.remainingWait(start))
+		if err != nil && slots != nil {
+			<-slots
+		}
+	}
+	if err != nil {
+		p.metrics.record(err)
+		return nil, &PoolError{
+			Err:     err,
+			InUse:   p.config.MaxClients - len(p.clients),
+			Size:    p.config.MaxClients,
+			Waiting: p.waiting.Load(),
+			Waited:  time.Since(start),
+		}
+	}
+	client.slot = slots
+	return client, nil
+}
+
+// Оставшееся время ожидания клиента (отрицательное - без ограничения)
+func (p *ClientPool) remainingWait(start time.Time) time.Duration {
+	if p.config.ClientTimeout <= 0 {
+		return p.config.ClientTimeout
+	}
+	return max(p.config.ClientTimeout-time.Since(start), 0)
+}
+
+// Занятие места в доле пула класса запросов (slots == nil - класс не ограничен)
+func (p *ClientPool) acquireSlot(slots chan struct{}, class PoolClass) (*PooledClient, error) {
+	if slots == nil {
+		return nil, nil
+	}
+	switch {
+	case p.config.ClientTimeout < 0:
+		p.waiting.Add(1)
+		slots <- struct{}{}
+		p.waiting.Add(-1)
+		return nil, nil
+	case p.config.ClientTimeout == 0:
+		select {
+		case slots <- struct{}{}:
+			return nil, nil
+		default:
+		}
+	default:
+		p.waiting.Add(1)
+		defer p.waiting.Add(-1)
+		select {
+		case slots <- struct{}{}:
+			return nil, nil
+		case <-time.After(p.config.ClientTimeout):
+		}
+	}
+	return nil, fmt.Errorf("%w: все клиенты для запросов класса %s заняты (не больше %d)",
+		ErrPoolExhausted, class, cap(slots))
+}
+
+// Получение клиента из пула без учета ошибок в счетчиках
+// (wait < 0 - ожидание без ограничения, 0 - без ожидания)
+func (p *ClientPool) getClient(wait time.Duration) (*PooledClient, error) {
 	// Проверяем состояние процесса
 	if !p.processManager.IsRunning() {
 		state, exitCode, err := p.processManager.GetState()
 		if state == ProcessFailed {
-			return nil, fmt.Errorf("octet не запущен (код выхода: %d): %v",
-				exitCode, err)
+			return nil, fmt.Errorf("%w: octet не запущен (код выхода: %d): %v",
+				ErrBackendUnavailable, exitCode, err)
 		}
-		return nil, fmt.Errorf("octet не в рабочем состоянии: %v", state)
+		return nil, fmt.Errorf("%w: octet не в рабочем состоянии: %v", ErrBackendUnavailable, state)
 	}
 
-	// Определяем стратегию ожидания на основе настроенного таймаута
+	// Определяем стратегию ожидания на основе оставшегося времени
 	switch {
-	case p.config.ClientTimeout < 0:
+	case wait < 0:
 		// Ждем бесконечно, пока не освободится клиент
+		p.waiting.Add(1)
 		client := <-p.clients
+		p.waiting.Add(-1)
 		return p.prepareClient(client)
 
-	case p.config.ClientTimeout == 0:
+	case wait == 0:
 		// Не ждем, сразу возвращаем ошибку если клиентов нет
 		select {
 		case client := <-p.clients:
 			return p.prepareClient(client)
 		default:
-			return nil, fmt.Errorf("все клиенты заняты")
+			return nil, fmt.Errorf("%w: все клиенты заняты", ErrPoolExhausted)
 		}
 
 	default:
 		// Ждем указанное время
+		p.waiting.Add(1)
 		select {
 		case client := <-p.clients:
+			p.waiting.Add(-1)
 			return p.prepareClient(client)
-		case <-time.After(p.config.ClientTimeout):
-			return nil, fmt.Errorf("превышено время ожидания свободного клиента (%v)", p.config.ClientTimeout)
+		case <-time.After(wait):
+			p.waiting.Add(-1)
+			return nil, fmt.Errorf("%w: превышено время ожидания свободного клиента (%v)",
+				ErrPoolExhausted, p.config.ClientTimeout)
 		}
 	}
 }
 
+// Счетчики событий всех клиентов пула
+func (p *ClientPool) Metrics() *ClientMetrics {
+	return p.metrics
+}
+
+// Не больше limit последних закрытий соединений после ошибок (limit <= 0 - все хранимые), от старых к новым
+func (p *ClientPool) Discards(limit int) []DiscardEvent {
+	return p.discards.Recent(limit)
+}
+
+// Запись процентилей длительности команд и текущих таймаутов чтения в текстовом формате Prometheus
+func (p *ClientPool) WriteLatencyMetrics(w io.Writer) error {
+	return p.metrics.Latency.writePrometheus(w, p.config.AdaptiveTimeout, p.config.ReadTimeout)
+}
+
+// Состояние процесса octet, с которым работает пул
+func (p *ClientPool) BackendState() (ProcessState, int, error) {
+	return p.processManager.GetState()
+}
+
+// Состояние пула клиентов
+type PoolStats struct {
+	Size               int    `json:"size"`                 // Размер пула
+	Idle               int    `json:"idle"`                 // Свободные клиенты
+	InUse              int    `json:"in_use"`               // Занятые клиенты
+	Waiting            int64  `json:"waiting"`              // Запросы, ожидающие свободного клиента
+	OversizedFrames    uint64 `json:"oversized_frames"`     // Отклоненные из-за размера сообщения
+	BackendUnavailable uint64 `json:"backend_unavailable"`  // Отказы из-за недоступности octet
+	PoolExhausted      uint64 `json:"pool_exhausted"`       // Отказы из-за отсутствия свободных клиентов
+	BackendTimeouts    uint64 `json:"backend_timeouts"`     // Запросы, на которые octet не ответил вовремя
+	Mutations          uint64 `json:"mutations"`            // Успешные изменения данных с момента запуска
+	RetryLater         uint64 `json:"retry_later"`          // Ответы octet с просьбой повторить изменение позже
+	RetryLaterRejected uint64 `json:"retry_later_rejected"` // Изменения, отклоненные без отправки в octet после такого ответа
+	// Закрытия соединений после ошибок по причинам
+	Discards map[DiscardReason]uint64 `json:"discards,omitempty"`
+	// Доли пула классов запросов (только при разделении пула)
+	Partitions []PoolPartitionStats `json:"partitions,omitempty"`
+}
+
+// Состояние доли пула класса запросов
+type PoolPartitionStats struct {
+	Class PoolClass `json:"class"`
+	Limit int       `json:"limit"`  // Максимум одновременно занятых клиентов
+	InUse int       `json:"in_use"` // Занятые клиенты
+}
+
+// Снимок текущего состояния пула
+func (p *ClientPool) Stats() PoolStats {
+	idle := len(p.clients)
+	return PoolStats{
+		Size:               p.config.MaxClients,
+		Idle:               idle,
+		InUse:              p.config.MaxClients - idle,
+		Waiting:            p.waiting.Load(),
+		OversizedFrames:    p.metrics.OversizedFrames.Load(),
+		BackendUnavailable: p.metrics.BackendUnavailable.Load(),
+		PoolExhausted:      p.metrics.PoolExhausted.Load(),
+		BackendTimeouts:    p.metrics.BackendTimeouts.Load(),
+		Mutations:          p.metrics.Mutations.Load(),
+		RetryLater:         p.breaker.Trips.Load(),
+		RetryLaterRejected: p.breaker.Rejected.Load(),
+		Discards:           p.discards.Counts(),
+		Partitions:         p.partitionStats(),
+	}
+}
+
+// Состояние долей пула в порядке классов запросов
+func (p *ClientPool) partitionStats() []PoolPartitionStats {
+	var stats []PoolPartitionStats
+	for _, class := range []PoolClass{PoolClassRead, PoolClassWrite} {
+		if slots := p.partitions[class]; slots != nil {
+			stats = append(stats, PoolPartitionStats{Class: class, Limit: cap(slots), InUse: len(slots)})
+		}
+	}
+	return stats
+}
+
 // Подготовка клиента к использованию
 func (p *ClientPool) prepareClient(client *Client) (*PooledClient, error) {
 	// Проверяем, установлено ли соединение
@@ -307,7 +1324,7 @@ func (p *ClientPool) prepareClient(client *Client) (*PooledClient, error) {
 		if err := client.Connect(); err != nil {
 			// Возвращаем клиент в пул и возвращаем ошибку
 			p.clients <- client
-			return nil, fmt.Errorf("не удалось подключить клиент: %w", err)
+			return nil, fmt.Errorf("%w: не удалось подключить клиент: %w", ErrBackendUnavailable, err)
 		}
 	}
 
@@ -321,6 +1338,10 @@ func (p *ClientPool) prepareClient(client *Client) (*PooledClient, error) {
 
 // Закрытие всех соединений и освобождение ресурсов
 func (p *ClientPool) Close() {
+	// Останавливаем фоновые горутины до закрытия канала клиентов
+	close(p.done)
+	p.wg.Wait()
+
 	// Закрываем все клиенты
 	clientsCount := len(p.clients)
 	for i := 0; i < clientsCount; i++ {
@@ -342,6 +1363,7 @@ type PooledClient struct {
 	*Client
 	pool *ClientPool
 	used bool
+	slot chan struct{} // Доля пула класса запроса (nil - класс не ограничен)
 }
 
 // Возврат клиента в пул
@@ -351,6 +1373,9 @@ func (pc *PooledClient) Release() {
 	}
 	pc.used = true
 	pc.pool.clients <- pc.Client
+	if pc.slot != nil {
+		<-pc.slot
+	}
 }
 
 // Выполнение octet::insert и возврат клиента в пул
@@ -359,18 +1384,48 @@ func (pc *PooledClient) Insert(ctx context.Context, data string) (string, error)
 	return pc.Client.Insert(ctx, data)
 }
 
+// Выполнение octet::insert со сроком хранения строки и возврат клиента в пул
+func (pc *PooledClient) InsertWithTTL(ctx context.Context, data string, ttlSeconds uint64) (string, error) {
+	defer pc.Release()
+	return pc.Client.InsertWithTTL(ctx, data, ttlSeconds)
+}
+
+// Выполнение octet::insert с указанным UUID и возврат клиента в пул
+func (pc *PooledClient) InsertAs(ctx context.Context, uuid, data string, ttlSeconds uint64) (string, error) {
+	defer pc.Release()
+	return pc.Client.InsertAs(ctx, uuid, data, ttlSeconds)
+}
+
 // Выполнение octet::get и возврат клиента в пул
 func (pc *PooledClient) Get(ctx context.Context, uuid string) (string, error) {
 	defer pc.Release()
 	return pc.Client.Get(ctx, uuid)
 }
 
+// Выполнение octet::exists и возврат клиента в пул
+func (pc *PooledClient) Exists(ctx context.Context, uuid string) (bool, error) {
+	defer pc.Release()
+	return pc.Client.Exists(ctx, uuid)
+}
+
+// Выполнение octet::stat и возврат клиента в пул
+func (pc *PooledClient) Stat(ctx context.Context, uuid string) (EntryStat, error) {
+	defer pc.Release()
+	return pc.Client.Stat(ctx, uuid)
+}
+
 // Выполнение octet::update и возврат клиента в пул
 func (pc *PooledClient) Update(ctx context.Context, uuid, data string) error {
 	defer pc.Release()
 	return pc.Client.Update(ctx, uuid, data)
 }
 
+// Выполнение octet::update с проверкой версии строки и возврат клиента в пул
+func (pc *PooledClient) UpdateIf(ctx context.Context, uuid, data string, expectedVersion uint64) error {
+	defer pc.Release()
+	return pc.Client.UpdateIf(ctx, uuid, data, expectedVersion)
+}
+
 // Выполнение octet::remove и возврат клиента в пул
 func (pc *PooledClient) Remove(ctx context.Context, uuid string) error {
 	defer pc.Release()
@@ -382,3 +1437,45 @@ func (pc *PooledClient) Ping(ctx context.Context) error {
 	defer pc.Release()
 	return pc.Client.Ping(ctx)
 }
+
+// Выполнение octet::stats с автоматическим возвратом клиента в пул
+func (pc *PooledClient) Stats(ctx context.Context) (uint64, error) {
+	defer pc.Release()
+	return pc.Client.Stats(ctx)
+}
+
+// Выполнение octet::count и возврат клиента в пул
+func (pc *PooledClient) Count(ctx context.Context) (uint64, error) {
+	defer pc.Release()
+	return pc.Client.Count(ctx)
+}
+
+// Выполнение octet::compact с автоматическим возвратом клиента в пул
+func (pc *PooledClient) Compact(ctx context.Context) (CompactionStatus, error) {
+	defer pc.Release()
+	return pc.Client.Compact(ctx)
+}
+
+// Выполнение octet::compact_status с автоматическим возвратом клиента в пул
+func (pc *PooledClient) CompactionStatus(ctx context.Context) (CompactionStatus, error) {
+	defer pc.Release()
+	return pc.Client.CompactionStatus(ctx)
+}
+
+// Выполнение octet::journal с автоматическим возвратом клиента в пул
+func (pc *PooledClient) Journal(ctx context.Context, since string, skip, limit int) ([]protocol.JournalEntry, bool, error) {
+	defer pc.Release()
+	return pc.Client.Journal(ctx, since, skip, limit)
+}
+
+// Выполнение octet::batch с автоматическим возвратом клиента в пул
+func (pc *PooledClient) Batch(ctx context.Context, operations []protocol.BatchOperation) ([]BatchResult, error) {
+	defer pc.Release()
+	return pc.Client.Batch(ctx, operations)
+}
+
+// Выполнение octet::list с автоматическим возвратом клиента в пул
+func (pc *PooledClient) List(ctx context.Context, after string, limit int) ([]string, bool, error) {
+	defer pc.Release()
+	return pc.Client.List(ctx, after, limit)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+	"go.uber.org/zap"
+)
+
+// Параметры объединения изменений
+type CoalesceConfig struct {
+	Window   time.Duration // Окно ожидания следующих изменений после первого
+	MaxOps   int           // Максимальное количество операций в одном запросе batch
+	MaxBytes int           // Максимальный размер операций в одном запросе batch (в JSON)
+}
+
+// Изменение, ожидающее отправки в составе пакета
+type coalescedOp struct {
+	op   protocol.BatchOperation
+	size int
+	done chan BatchResult
+}
+
+// Объединение одиночных изменений данных, пришедших в течение окна, в один запрос batch к octet.
+// Каждый пакет выполняется на отдельном клиенте пула, поэтому пакеты отправляются параллельно.
+type WriteCoalescer struct {
+	pool   *ClientPool
+	config CoalesceConfig
+	logger *zap.Logger
+	ops    chan *coalescedOp
+	done   chan struct{}  // Закрывается при остановке
+	wg     sync.WaitGroup // Горутина сбора и отправляемые пакеты
+	once   sync.Once
+}
+
+// Создание и запуск объединения изменений
+func NewWriteCoalescer(pool *ClientPool, config CoalesceConfig, logger *zap.Logger) (*WriteCoalescer, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if config.Window <= 0 || config.MaxOps <= 0 || config.MaxBytes <= 0 {
+		return nil, fmt.Errorf("некорректные параметры объединения изменений")
+	}
+
+	c := &WriteCoalescer{
+		pool:   pool,
+		config: config,
+		logger: logger,
+		ops:    make(chan *coalescedOp),
+		done:   make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.collectLoop()
+	return c, nil
+}
+
+// Выполнение octet::insert в составе пакета (ttlSeconds - срок хранения строки, 0 - без ограничения)
+func (c *WriteCoalescer) Insert(ctx context.Context, data string, ttlSeconds uint64) (string, error) {
+	result := c.submit(ctx, protocol.BatchOperation{Command: protocol.CommandInsert, Data: data, TtlSeconds: ttlSeconds})
+	return result.Uuid, result.Err
+}
+
+// Выполнение octet::insert с указанным UUID в составе пакета (только если строки с таким UUID еще нет,
+// ttlSeconds - срок хранения строки, 0 - без ограничения)
+func (c *WriteCoalescer) Create(ctx context.Context, uuid, data string, ttlSeconds uint64) error {
+	return c.submit(ctx, protocol.BatchOperation{Command: protocol.CommandInsert, Uuid: uuid, Data: data, TtlSeconds: ttlSeconds}).Err
+}
+
+// Выполнение octet::update в составе пакета (expectedVersion - ожидаемая версия строки, 0 - без проверки)
+func (c *WriteCoalescer) Update(ctx context.Context, uuid, data string, expectedVersion uint64) error {
+	return c.submit(ctx, protocol.BatchOperation{Command: protocol.CommandUpdate, Uuid: uuid, Data: data,
+		ExpectedVersion: expectedVersion}).Err
+}
+
+// Выполнение octet::remove в составе пакета
+func (c *WriteCoalescer) Remove(ctx context.Context, uuid string) error {
+	return c.submit(ctx, protocol.BatchOperation{Command: protocol.CommandRemove, Uuid: uuid}).Err
+}
+
+// Остановка объединения с ожиданием отправки уже собранных пакетов
+func (c *WriteCoalescer) Close() {
+	c.once.Do(func() { close(c.done) })
+	c.wg.Wait()
+}
+
+// Передача изменения в текущий пакет и ожидание его результата.
+// Изменение, не помещающееся в пакет, выполняется отдельным запросом.
+func (c *WriteCoalescer) submit(ctx context.Context, op protocol.BatchOperation) BatchResult {
+	encoded, err := json.Marshal(op)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	if len(encoded) > c.config.MaxBytes {
+		return c.runSingle(ctx, op)
+	}
+
+	pending := &coalescedOp{op: op, size: len(encoded), done: make(chan BatchResult, 1)}
+	select {
+	case c.ops <- pending:
+	case <-ctx.Done():
+		return BatchResult{Err: ctx.Err()}
+	case <-c.done:
+		return BatchResult{Err: fmt.Errorf("%w: объединение изменений остановлено", ErrBackendUnavailable)}
+	}
+	// Переданное изменение будет отправлено в любом случае, поэтому ожидаем его результат
+	return <-pending.done
+}
+
+// Выполнение изменения отдельным запросом
+func (c *WriteCoalescer) runSingle(ctx context.Context, op protocol.BatchOperation) BatchResult {
+	client, err := c.pool.GetClientFor(PoolClassWrite)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+
+	var result BatchResult
+	switch op.Command {
+	case protocol.CommandInsert:
+		result.Uuid, result.Err = client.InsertAs(ctx, op.Uuid, op.Data, op.TtlSeconds)
+	case protocol.CommandUpdate:
+		result.Err = client.UpdateIf(ctx, op.Uuid, op.Data, op.ExpectedVersion)
+	case protocol.CommandRemove:
+		result.Err = client.Remove(ctx, op.Uuid)
+	default:
+		client.Release()
+		result.Err = fmt.Errorf("команда %s не поддерживается в пакете", op.Command)
+	}
+	return result
+}
+
+// Сбор изменений в пакеты: пакет отправляется по истечении окна после первого изменения
+// или при достижении ограничения на количество операций или размер
+func (c *WriteCoalescer) collectLoop() {
+	defer c.wg.Done()
+
+	var next *coalescedOp // Изменение, не поместившееся в предыдущий пакет
+	for {
+		if next == nil {
+			select {
+			case next = <-c.ops:
+			case <-c.done:
+				return
+			}
+		}
+
+		batch := []*coalescedOp{next}
+		size := next.size
+		next = nil
+
+		timer := time.NewTimer(c.config.Window)
+	collect:
+		for len(batch) < c.config.MaxOps {
+			select {
+			case pending := <-c.ops:
+				if size+pending.size > c.config.MaxBytes {
+					next = pending
+					break collect
+				}
+				batch = append(batch, pending)
+				size += pending.size
+			case <-timer.C:
+				break collect
+			case <-c.done:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		c.wg.Add(1)
+		go c.flush(batch)
+	}
+}
+
+// Отправка пакета и передача результатов ожидающим изменениям
+func (c *WriteCoalescer) flush(batch []*coalescedOp) {
+	defer c.wg.Done()
+
+	operations := make([]protocol.BatchOperation, len(batch))
+	for i, pending := range batch {
+		operations[i] = pending.op
+	}
+
+	results, err := c.runBatch(operations)
+	if err != nil {
+		c.logger.Error("Не удалось выполнить пакет изменений",
+			zap.Int("Количество операций", len(batch)), zap.Error(err))
+		for _, pending := range batch {
+			pending.done <- BatchResult{Err: err}
+		}
+		return
+	}
+	for i, pending := range batch {
+		pending.done <- results[i]
+	}
+}
+
+// Выполнение пакета на клиенте пула
+func (c *WriteCoalescer) runBatch(operations []protocol.BatchOperation) ([]BatchResult, error) {
+	client, err := c.pool.GetClientFor(PoolClassWrite)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Release()
+	return client.Client.Batch(context.Background(), operations)
+}
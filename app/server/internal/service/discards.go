@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Причина закрытия соединения с octet после ошибки
+type DiscardReason string
+
+const (
+	DiscardWriteError      DiscardReason = "write_error"      // Не удалось отправить запрос
+	DiscardTimeout         DiscardReason = "timeout"          // octet не ответил вовремя
+	DiscardConnectionLost  DiscardReason = "connection_lost"  // octet закрыл или сбросил соединение
+	DiscardFrameTooLarge   DiscardReason = "frame_too_large"  // Размер ответа превышает допустимый
+	DiscardProtocolError   DiscardReason = "protocol_error"   // Ответ не удалось разобрать
+	DiscardRequestMismatch DiscardReason = "request_mismatch" // ID ответа не совпадает с ID запроса
+	DiscardHeartbeat       DiscardReason = "heartbeat_failed" // Соединение не ответило на heartbeat
+	DiscardInjectedFault   DiscardReason = "injected_fault"   // Разрыв при внедрении сбоев
+)
+
+// Количество последних закрытий соединений, хранимых пулом
+const discardHistorySize = 100
+
+// Закрытие соединения с octet после ошибки
+type DiscardEvent struct {
+	Time    time.Time            `json:"time"`
+	Reason  DiscardReason        `json:"reason"`
+	Command protocol.CommandType `json:"command,omitempty"` // Команда, при выполнении которой произошла ошибка
+	Error   string               `json:"error"`
+}
+
+// Учет закрытий соединений с octet после ошибок: счетчики по причинам и последние события,
+// чтобы повторяющиеся сбои протокола были видны, а не скрывались переподключениями.
+// Нулевой указатель закрытия не учитывает.
+type DiscardLog struct {
+	mutex  sync.Mutex
+	counts map[DiscardReason]uint64
+	events []DiscardEvent // Кольцевой буфер последних событий
+	next   int            // Позиция следующего события
+	full   bool           // Буфер заполнен хотя бы один раз
+}
+
+// Создание учета закрытий с хранением size последних событий
+func NewDiscardLog(size int) *DiscardLog {
+	return &DiscardLog{
+		counts: make(map[DiscardReason]uint64),
+		events: make([]DiscardEvent, max(size, 1)),
+	}
+}
+
+// Учет закрытия соединения
+func (l *DiscardLog) record(reason DiscardReason, command protocol.CommandType, cause error) {
+	if l == nil {
+		return
+	}
+	event := DiscardEvent{Time: time.Now(), Reason: reason, Command: command, Error: cause.Error()}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.counts[reason]++
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Количество закрытий по причинам
+func (l *DiscardLog) Counts() map[DiscardReason]uint64 {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	counts := make(map[DiscardReason]uint64, len(l.counts))
+	for reason, count := range l.counts {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// Не больше limit последних событий (limit <= 0 - все хранимые), от старых к новым
+func (l *DiscardLog) Recent(limit int) []DiscardEvent {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stored := l.next
+	if l.full {
+		stored = len(l.events)
+	}
+	if limit <= 0 || limit > stored {
+		limit = stored
+	}
+	events := make([]DiscardEvent, 0, limit)
+	for i := stored - limit; i < stored; i++ {
+		index := i
+		if l.full {
+			index = (l.next + i) % len(l.events)
+		}
+		events = append(events, l.events[index])
+	}
+	return events
+}
+
+// Причина закрытия соединения по ошибке чтения ответа
+func readDiscardReason(err error) DiscardReason {
+	switch {
+	case errors.Is(err, protocol.ErrFrameTooLarge):
+		return DiscardFrameTooLarge
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return DiscardTimeout
+	case connectionLost(err):
+		return DiscardConnectionLost
+	}
+	return DiscardProtocolError
+}
+
+// Закрыто ли соединение другой стороной
+func connectionLost(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
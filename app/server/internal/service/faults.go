@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Ошибка, внедренная FaultInjector
+var ErrInjectedFault = errors.New("внедренная ошибка")
+
+// Параметры внедряемых сбоев (вероятности задаются в диапазоне от 0 до 1)
+type FaultSettings struct {
+	LatencyMs   int     `json:"latency_ms"`   // Задержка перед отправкой запроса в миллисекундах
+	LatencyRate float64 `json:"latency_rate"` // Вероятность задержки
+	DropRate    float64 `json:"drop_rate"`    // Вероятность потери запроса (ответ не придет до таймаута)
+	ResetRate   float64 `json:"reset_rate"`   // Вероятность разрыва соединения перед отправкой запроса
+}
+
+// Проверка параметров сбоев
+func (s FaultSettings) Validate() error {
+	if s.LatencyMs < 0 {
+		return fmt.Errorf("задержка не может быть отрицательной: %d", s.LatencyMs)
+	}
+	for name, rate := range map[string]float64{
+		"latency_rate": s.LatencyRate,
+		"drop_rate":    s.DropRate,
+		"reset_rate":   s.ResetRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("вероятность %s должна быть от 0 до 1: %v", name, rate)
+		}
+	}
+	return nil
+}
+
+// Действие над запросом, выбранное FaultInjector
+type faultAction int
+
+const (
+	faultNone  faultAction = iota // Запрос отправляется как обычно
+	faultDrop                     // Запрос не отправляется
+	faultReset                    // Соединение разрывается
+)
+
+// Внедрение сбоев во взаимодействие с octet для проверки устойчивости в тестовых средах
+type FaultInjector struct {
+	mutex          sync.RWMutex
+	settings       FaultSettings
+	processManager *ProcessManager
+}
+
+// Создание нового FaultInjector с начальными параметрами сбоев
+func NewFaultInjector(settings FaultSettings, pm *ProcessManager) (*FaultInjector, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return &FaultInjector{settings: settings, processManager: pm}, nil
+}
+
+// Текущие параметры сбоев
+func (f *FaultInjector) Settings() FaultSettings {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.settings
+}
+
+// Изменение параметров сбоев
+func (f *FaultInjector) SetSettings(settings FaultSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	f.settings = settings
+	f.mutex.Unlock()
+	return nil
+}
+
+// Принудительное завершение процесса octet (имитация аварийного падения)
+func (f *FaultInjector) KillBackend() error {
+	if f.processManager == nil {
+		return errors.New("процесс octet не управляется сервером")
+	}
+	return f.processManager.Kill()
+}
+
+// Выбор сбоя перед отправкой запроса (с выполнением задержки, если она выпала)
+func (f *FaultInjector) beforeSend() faultAction {
+	if f == nil {
+		return faultNone
+	}
+	settings := f.Settings()
+
+	if settings.LatencyMs > 0 && rand.Float64() < settings.LatencyRate {
+		time.Sleep(time.Duration(settings.LatencyMs) * time.Millisecond)
+	}
+	if rand.Float64() < settings.ResetRate {
+		return faultReset
+	}
+	if rand.Float64() < settings.DropRate {
+		return faultDrop
+	}
+	return faultNone
+}
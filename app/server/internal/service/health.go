@@ -0,0 +1,272 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+	"go.uber.org/zap"
+)
+
+// Команды, по которым оценивается состояние octet
+// (journal и batch выполняются заметно дольше и искажали бы длительность)
+var healthCommands = []protocol.CommandType{
+	protocol.CommandInsert, protocol.CommandGet, protocol.CommandExists, protocol.CommandStat, protocol.CommandUpdate, protocol.CommandRemove,
+	protocol.CommandPing, protocol.CommandStats, protocol.CommandCount,
+}
+
+// Количество хранимых решений о перезапуске
+const maxHealthDecisions = 50
+
+// Действия по решению о перезапуске octet
+const (
+	HealthActionRestarted = "restarted" // octet перезапущен
+	HealthActionFailed    = "failed"    // Перезапуск не удался
+	HealthActionNone      = "none"      // Автоматический перезапуск отключен, решение только записано
+)
+
+// Пороги состояния octet и параметры автоматического перезапуска
+type HealthPolicy struct {
+	Interval     time.Duration // Интервал оценки состояния
+	Window       time.Duration // Окно, по командам которого оценивается состояние
+	MinCommands  uint64        // Минимальное количество команд в окне для оценки
+	MaxErrorRate float64       // Допустимая доля неуспешных команд (0-1)
+	MaxLatency   time.Duration // Допустимый 99-й процентиль длительности команд (0 - не проверяется)
+	Sustain      time.Duration // Длительность нарушения порогов, после которой принимается решение
+	Cooldown     time.Duration // Минимальный интервал между решениями
+	AutoRestart  bool          // Перезапускать octet (иначе решение только записывается)
+}
+
+// Оценка состояния octet по командам за окно
+type HealthReport struct {
+	CheckedAt  *time.Time `json:"checked_at,omitempty"` // Время последней оценки
+	Commands   uint64     `json:"commands"`             // Количество команд в окне
+	ErrorRate  float64    `json:"error_rate"`           // Доля неуспешных команд
+	LatencyP99 float64    `json:"latency_p99_seconds"`  // Оценка 99-го процентиля длительности сверху
+	// Запас до порогов: 100 - далеко от порогов, 0 - пороги достигнуты
+	Score         int        `json:"score"`
+	Breached      bool       `json:"breached"`                 // Нарушены ли пороги
+	BreachedSince *time.Time `json:"breached_since,omitempty"` // Начало непрерывного нарушения
+}
+
+// Решение о перезапуске octet
+type HealthDecision struct {
+	Time       time.Time `json:"time"`
+	Reason     string    `json:"reason"`
+	ErrorRate  float64   `json:"error_rate"`
+	LatencyP99 float64   `json:"latency_p99_seconds"`
+	Action     string    `json:"action"` // restarted, failed или none
+	Error      string    `json:"error,omitempty"`
+}
+
+// Периодическая оценка состояния octet по доле ошибок и длительности команд
+// и перезапуск octet при длительном нарушении порогов
+type HealthMonitor struct {
+	pool           *ClientPool
+	processManager *ProcessManager
+	policy         HealthPolicy
+	logger         *zap.Logger
+
+	mutex        sync.Mutex
+	history      []commandTotals // Счетчики на момент каждой оценки в пределах окна
+	report       HealthReport
+	breachSince  time.Time
+	lastDecision time.Time
+	decisions    []HealthDecision
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Создание монитора состояния octet
+func NewHealthMonitor(pool *ClientPool, pm *ProcessManager, policy HealthPolicy, logger *zap.Logger) (*HealthMonitor, error) {
+	if pool == nil || pm == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: не указан пул клиентов или ProcessManager")
+	}
+	if policy.Interval <= 0 || policy.Window < policy.Interval {
+		return nil, fmt.Errorf("окно оценки состояния должно быть не меньше интервала оценки")
+	}
+	return &HealthMonitor{
+		pool:           pool,
+		processManager: pm,
+		policy:         policy,
+		logger:         logger,
+		report:         HealthReport{Score: 100},
+		done:           make(chan struct{}),
+	}, nil
+}
+
+// Запуск периодической оценки состояния
+func (m *HealthMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.policy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case now := <-ticker.C:
+				m.check(now)
+			}
+		}
+	}()
+}
+
+// Остановка оценки состояния
+func (m *HealthMonitor) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Включен ли автоматический перезапуск
+func (m *HealthMonitor) AutoRestart() bool {
+	return m.policy.AutoRestart
+}
+
+// Последняя оценка состояния
+func (m *HealthMonitor) Report() HealthReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.report
+}
+
+// Решения о перезапуске, начиная с последнего
+func (m *HealthMonitor) Decisions() []HealthDecision {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	decisions := make([]HealthDecision, len(m.decisions))
+	for i, decision := range m.decisions {
+		decisions[len(m.decisions)-1-i] = decision
+	}
+	return decisions
+}
+
+// Оценка состояния по командам за окно и перезапуск при длительном нарушении порогов
+func (m *HealthMonitor) check(now time.Time) {
+	if reason, report := m.evaluate(now); reason != "" {
+		m.decide(now, reason, report)
+	}
+}
+
+// Оценка состояния. Возвращает причину перезапуска, если решение о нем должно быть принято.
+func (m *HealthMonitor) evaluate(now time.Time) (string, HealthReport) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.history = append(m.history, m.pool.metrics.Commands.totals(healthCommands))
+	if maxLen := int(m.policy.Window/m.policy.Interval) + 1; len(m.history) > maxLen {
+		m.history = m.history[len(m.history)-maxLen:]
+	}
+	oldest, newest := m.history[0], m.history[len(m.history)-1]
+
+	report := HealthReport{CheckedAt: &now, Commands: newest.commands - oldest.commands, Score: 100}
+	if report.Commands != 0 {
+		report.ErrorRate = float64(newest.failed-oldest.failed) / float64(report.Commands)
+		report.LatencyP99 = durationPercentile(oldest.duration, newest.duration, 0.99)
+	}
+	reason := ""
+	if report.Commands >= m.policy.MinCommands {
+		ratio := report.ErrorRate / m.policy.MaxErrorRate
+		if m.policy.MaxLatency > 0 {
+			ratio = max(ratio, report.LatencyP99/m.policy.MaxLatency.Seconds())
+		}
+		report.Score = int(math.Round(100 * min(max(1-ratio, 0), 1)))
+
+		switch {
+		case report.ErrorRate >= m.policy.MaxErrorRate:
+			reason = fmt.Sprintf("доля неуспешных команд %.1f%% не ниже допустимой %.1f%%",
+				100*report.ErrorRate, 100*m.policy.MaxErrorRate)
+		case m.policy.MaxLatency > 0 && report.LatencyP99 >= m.policy.MaxLatency.Seconds():
+			reason = fmt.Sprintf("99-й процентиль длительности команд %gс не ниже допустимого %v",
+				report.LatencyP99, m.policy.MaxLatency)
+		}
+	}
+
+	if reason == "" {
+		m.breachSince = time.Time{}
+		m.report = report
+		return "", report
+	}
+	if m.breachSince.IsZero() {
+		m.breachSince = now
+	}
+	since := m.breachSince
+	report.Breached = true
+	report.BreachedSince = &since
+	m.report = report
+
+	if now.Sub(m.breachSince) < m.policy.Sustain ||
+		(!m.lastDecision.IsZero() && now.Sub(m.lastDecision) < m.policy.Cooldown) {
+		return "", report
+	}
+	m.lastDecision = now
+	return reason, report
+}
+
+// Принятие решения о перезапуске. Перезапуск выполняется без блокировки,
+// чтобы оценка состояния оставалась доступной во время ожидания нового процесса.
+func (m *HealthMonitor) decide(now time.Time, reason string, report HealthReport) {
+	decision := HealthDecision{
+		Time:       now,
+		Reason:     reason,
+		ErrorRate:  report.ErrorRate,
+		LatencyP99: report.LatencyP99,
+		Action:     HealthActionNone,
+	}
+
+	if m.policy.AutoRestart {
+		m.logger.Warn("Пороги состояния octet нарушены, перезапускаем процесс", zap.String("reason", reason))
+		if err := m.processManager.Restart("health_policy: " + reason); err != nil {
+			decision.Action = HealthActionFailed
+			decision.Error = err.Error()
+			m.logger.Error("Не удалось перезапустить процесс octet", zap.Error(err))
+		} else {
+			decision.Action = HealthActionRestarted
+			// Соединения с прежним процессом больше не работают
+			m.pool.DisconnectIdle()
+			m.logger.Info("Процесс octet перезапущен")
+		}
+	} else {
+		m.logger.Warn("Пороги состояния octet нарушены, автоматический перезапуск отключен",
+			zap.String("reason", reason))
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.policy.AutoRestart {
+		// Состояние нового процесса оценивается по новым командам
+		m.history = nil
+		m.breachSince = time.Time{}
+	}
+	m.decisions = append(m.decisions, decision)
+	if len(m.decisions) > maxHealthDecisions {
+		m.decisions = m.decisions[len(m.decisions)-maxHealthDecisions:]
+	}
+}
+
+// Оценка процентиля q длительности сверху по разности гистограмм
+// (верхняя граница интервала, в который попадает процентиль)
+func durationPercentile(before, after []uint64, q float64) float64 {
+	var total uint64
+	for i := range after {
+		total += after[i] - before[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	threshold := uint64(math.Ceil(q * float64(total)))
+	var cumulative uint64
+	for i, bound := range durationBuckets {
+		cumulative += after[i] - before[i]
+		if cumulative >= threshold {
+			return bound
+		}
+	}
+	// Процентиль выше последней границы гистограммы
+	return durationBuckets[len(durationBuckets)-1]
+}
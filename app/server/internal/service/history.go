@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Ошибка чтения строки на момент, для которого журнал операций octet не сохранил ее состояние
+var ErrHistoryUnavailable = errors.New("состояние строки на указанный момент недоступно")
+
+// Количество записей журнала, запрашиваемых у octet за один раз при чтении на момент времени
+const historyPageSize = 500
+
+// Чтение строки в состоянии на момент asOf по журналу операций octet.
+// Журнал содержит операции начиная с последнего сжатия хранилища (контрольной точки),
+// поэтому состояние строки до сжатия известно, только если строка не изменялась после него.
+// Отсутствовавшая на момент asOf строка - ErrNotFound, неизвестное состояние - ErrHistoryUnavailable.
+func ReadAsOf(ctx context.Context, pool *ClientPool, uuid string, asOf time.Time) (string, error) {
+	// Метки журнала сравниваются как строки одного формата
+	until := asOf.UTC().Format(protocol.JournalTimeFormat)
+
+	var last, next *protocol.JournalEntry // Последняя операция со строкой до asOf и первая после
+	// Без контрольной точки в начале журнал содержит все операции с момента создания хранилища
+	first, fromCheckpoint := true, false
+	var historyStart string
+
+	since, skip := "", 0
+	for next == nil {
+		client, err := pool.GetClientFor(PoolClassRead)
+		if err != nil {
+			return "", err
+		}
+		entries, more, err := client.Journal(ctx, since, skip, historyPageSize)
+		if err != nil {
+			return "", err
+		}
+
+		done := false
+		for i := range entries {
+			entry := &entries[i]
+			if first {
+				first = false
+				if entry.Op == "checkpoint" {
+					fromCheckpoint, historyStart = true, entry.Time
+				}
+			}
+			// Запись срока хранения (expire) не меняет значение строки
+			if entry.Uuid != uuid || entry.Op == "checkpoint" || entry.Op == "expire" {
+				// Последующие операции не меняют состояние строки на момент asOf
+				if entry.Time > until && last != nil {
+					done = true
+					break
+				}
+				continue
+			}
+			if entry.Time <= until {
+				last = entry
+				continue
+			}
+			if last == nil {
+				next = entry
+			}
+			done = true
+			break
+		}
+		if done || !more || len(entries) == 0 {
+			break
+		}
+		since, skip = NextJournalCursor(since, skip, entries)
+	}
+
+	switch {
+	case last != nil:
+		if last.Op == "remove" {
+			return "", ErrNotFound
+		}
+		return last.Data, nil
+	case !fromCheckpoint:
+		// Строка добавлена после asOf или не добавлялась вовсе
+		return "", ErrNotFound
+	case until < historyStart:
+		return "", ErrHistoryUnavailable
+	case next != nil:
+		// Строка, добавленная после asOf, на момент asOf отсутствовала,
+		// а значение строки, изменявшейся после сжатия, сохранилось только в снимке
+		if next.Op == "insert" {
+			return "", ErrNotFound
+		}
+		return "", ErrHistoryUnavailable
+	}
+
+	// Строка не изменялась после сжатия, поэтому на момент asOf ее значение совпадает с текущим
+	client, err := pool.GetClientFor(PoolClassRead)
+	if err != nil {
+		return "", err
+	}
+	return client.Get(ctx, uuid)
+}
+
+// Позиция следующей страницы журнала: метка времени последней полученной записи
+// и количество уже полученных записей с этой меткой
+// (метки имеют точность в миллисекунды и могут совпадать у нескольких записей)
+func NextJournalCursor(since string, skip int, entries []protocol.JournalEntry) (string, int) {
+	last := entries[len(entries)-1].Time
+	count := 0
+	for i := len(entries) - 1; i >= 0 && entries[i].Time == last; i-- {
+		count++
+	}
+	if last == since {
+		return since, skip + count
+	}
+	return last, count
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/metastore"
+	"go.uber.org/zap"
+)
+
+// Ключ идемпотентности уже использован для запроса с другим телом
+var ErrIdempotencyConflict = errors.New("ключ Idempotency-Key уже использован для другого запроса")
+
+// Префикс ключей записей в хранилище метаданных
+const idempotencyStorePrefix = "idempotency:"
+
+// Параметры сохранения результатов добавлений с ключом идемпотентности
+type IdempotencyConfig struct {
+	TTL        time.Duration // Срок хранения записи
+	MaxEntries int           // Максимальное количество записей в памяти
+}
+
+// Результат добавления строки с ключом идемпотентности: повтор запроса с тем же ключом получает
+// сохраненный ответ, даже если строка с тех пор изменена или удалена
+type IdempotencyRecord struct {
+	RequestHash []byte    `json:"request_hash"` // Хеш тела первого запроса
+	Uuid        string    `json:"uuid"`         // UUID добавленной строки
+	Status      int       `json:"status"`       // Статус ответа
+	Body        []byte    `json:"body"`         // Тело ответа
+	Expires     time.Time `json:"expires"`      // Окончание срока хранения записи
+}
+
+// Сохранение результатов добавлений с ключом идемпотентности. Записи хранятся в памяти
+// и, если задано, в хранилище метаданных, поэтому повтор после перезапуска сервера
+// (или, для общего Redis, на другом сервере) получает тот же ответ. Пока первый запрос
+// выполняется, повторные ожидают его результата.
+type Idempotency struct {
+	config IdempotencyConfig
+	store  metastore.Store
+	logger *zap.Logger
+
+	mutex   sync.Mutex
+	pending map[string]*idempotencyCall
+	records map[string]*IdempotencyRecord
+
+	replayed  atomic.Uint64
+	conflicts atomic.Uint64
+}
+
+// Выполняющийся первый запрос с ключом идемпотентности
+type idempotencyCall struct {
+	requestHash [sha256.Size]byte
+	done        chan struct{} // Закрывается после сохранения результата или отказа от него
+}
+
+// Создание сохранения результатов добавлений (nil, если срок хранения не задан).
+// store - хранилище метаданных (nil - записи хранятся только в памяти).
+func NewIdempotency(config IdempotencyConfig, store metastore.Store, logger *zap.Logger) *Idempotency {
+	if config.TTL <= 0 {
+		return nil
+	}
+	return &Idempotency{
+		config:  config,
+		store:   store,
+		logger:  logger,
+		pending: make(map[string]*idempotencyCall),
+		records: make(map[string]*IdempotencyRecord),
+	}
+}
+
+// Начало запроса с ключом идемпотентности. Возвращает сохраненную запись, если запрос уже выполнен,
+// или ErrIdempotencyConflict, если ключ использован для запроса с другим телом. Если записи нет
+// (nil, nil), запрос выполняется вызывающим, который должен вызвать Complete или Abort.
+func (i *Idempotency) Begin(ctx context.Context, key string, requestHash [sha256.Size]byte) (*IdempotencyRecord, error) {
+	for {
+		i.mutex.Lock()
+		if call := i.pending[key]; call != nil {
+			i.mutex.Unlock()
+			if call.requestHash != requestHash {
+				i.conflicts.Add(1)
+				return nil, ErrIdempotencyConflict
+			}
+			select {
+			case <-call.done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		record := i.records[key]
+		if record != nil && time.Now().After(record.Expires) {
+			delete(i.records, key)
+			record = nil
+		}
+		i.mutex.Unlock()
+
+		if record == nil {
+			// Запись, сохраненная до перезапуска сервера (или другим сервером с общим хранилищем)
+			var err error
+			if record, err = i.load(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		if record != nil {
+			if [sha256.Size]byte(record.RequestHash) != requestHash {
+				i.conflicts.Add(1)
+				return nil, ErrIdempotencyConflict
+			}
+			i.replayed.Add(1)
+			return record, nil
+		}
+
+		i.mutex.Lock()
+		if i.pending[key] != nil {
+			// Пока читали хранилище, такой же запрос начал выполняться
+			i.mutex.Unlock()
+			continue
+		}
+		i.pending[key] = &idempotencyCall{requestHash: requestHash, done: make(chan struct{})}
+		i.mutex.Unlock()
+		return nil, nil
+	}
+}
+
+// Сохранение результата запроса, начатого Begin (хеш запроса и срок хранения заполняются здесь).
+// Ошибка записи в хранилище метаданных не мешает ответу: повтор получит запись из памяти,
+// пока сервер не перезапущен.
+func (i *Idempotency) Complete(key string, record IdempotencyRecord) {
+	i.mutex.Lock()
+	call := i.pending[key]
+	i.mutex.Unlock()
+	if call == nil {
+		return
+	}
+	record.RequestHash = call.requestHash[:]
+	record.Expires = time.Now().Add(i.config.TTL)
+	if i.store != nil {
+		data, err := json.Marshal(record)
+		if err == nil {
+			err = i.store.Set(context.Background(), idempotencyStoreKey(key), data, record.Expires)
+		}
+		if err != nil {
+			i.logger.Warn("Не удалось сохранить результат добавления с ключом идемпотентности", zap.Error(err))
+		}
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if len(i.records) >= i.config.MaxEntries {
+		now := time.Now()
+		for k, r := range i.records {
+			if now.After(r.Expires) {
+				delete(i.records, k)
+			}
+		}
+	}
+	if len(i.records) < i.config.MaxEntries {
+		i.records[key] = &record
+	}
+	i.finish(key)
+}
+
+// Отказ от сохранения результата запроса, начатого Begin (запрос завершился ошибкой,
+// и повтор должен выполниться заново)
+func (i *Idempotency) Abort(key string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.finish(key)
+}
+
+// Состояние сохранения результатов добавлений с ключом идемпотентности
+type IdempotencyStats struct {
+	Entries   int    `json:"entries"`   // Записи в памяти
+	Replayed  uint64 `json:"replayed"`  // Повторенные ответы
+	Conflicts uint64 `json:"conflicts"` // Ключи, повторно использованные с другим телом запроса
+	// Хранилище записей (отсутствует, если записи хранятся только в памяти)
+	Store string `json:"store,omitempty"`
+	// Записи в хранилище (отсутствует, если количество неизвестно)
+	Stored *int `json:"stored,omitempty"`
+}
+
+// Состояние сохранения результатов добавлений
+func (i *Idempotency) Stats() IdempotencyStats {
+	i.mutex.Lock()
+	entries := len(i.records)
+	i.mutex.Unlock()
+	stats := IdempotencyStats{Entries: entries, Replayed: i.replayed.Load(), Conflicts: i.conflicts.Load()}
+	if i.store != nil {
+		stats.Store = i.store.Backend()
+		if stored := i.store.Len(); stored >= 0 {
+			stats.Stored = &stored
+		}
+	}
+	return stats
+}
+
+// Окончание выполнения первого запроса (вызывается под блокировкой)
+func (i *Idempotency) finish(key string) {
+	if call := i.pending[key]; call != nil {
+		delete(i.pending, key)
+		close(call.done)
+	}
+}
+
+// Ключ записи в хранилище метаданных (ключ содержит имя клиента)
+func idempotencyStoreKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return idempotencyStorePrefix + hex.EncodeToString(sum[:])
+}
+
+// Чтение записи из хранилища метаданных (nil - записи нет или ее срок истек)
+func (i *Idempotency) load(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	if i.store == nil {
+		return nil, nil
+	}
+	data, err := i.store.Get(ctx, idempotencyStoreKey(key))
+	if errors.Is(err, metastore.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		// Без записи повтор добавил бы строку второй раз, поэтому запрос не выполняется
+		return nil, err
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil || len(record.RequestHash) != sha256.Size {
+		i.logger.Warn("Некорректная запись добавления с ключом идемпотентности в хранилище метаданных", zap.Error(err))
+		return nil, nil
+	}
+	if time.Now().After(record.Expires) {
+		return nil, nil
+	}
+	return &record, nil
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Размер журнала, после превышения которого он перезаписывается только с незавершенными намерениями
+const intentLogCompactSize = 1 << 20
+
+// Запись журнала намерений: намерение изменить данные или отметка о его завершении
+type intentRecord struct {
+	ID   uint64               `json:"id"`
+	Time time.Time            `json:"time,omitzero"`
+	Op   protocol.CommandType `json:"op,omitempty"`
+	Uuid string               `json:"uuid,omitempty"`
+	Hash string               `json:"hash,omitempty"` // SHA-256 данных insert/update
+	Done bool                 `json:"done,omitempty"`
+}
+
+// Результат сверки незавершенного намерения с octet
+type IntentStatus string
+
+const (
+	IntentApplied    IntentStatus = "applied"     // Изменение применено
+	IntentNotApplied IntentStatus = "not_applied" // Изменение не применено или данные расходятся
+	IntentRepaired   IntentStatus = "repaired"    // Изменение применено повторно при сверке
+	IntentUnknown    IntentStatus = "unknown"     // Результат невозможно проверить
+	IntentSuperseded IntentStatus = "superseded"  // Вытеснено более поздним намерением для того же UUID
+)
+
+// Отчет о сверке одного незавершенного намерения
+type IntentReport struct {
+	ID     uint64
+	Time   time.Time
+	Op     protocol.CommandType
+	Uuid   string
+	Status IntentStatus
+	Detail string
+}
+
+// Журнал намерений (write-ahead log) изменений данных.
+// Намерение записывается на диск до отправки запроса в octet и отмечается завершенным
+// после получения ответа, что позволяет после аварийного завершения сервера
+// найти изменения с неизвестным результатом и сверить их с octet.
+type IntentLog struct {
+	mutex     sync.Mutex
+	path      string
+	file      *os.File
+	size      int64
+	nextID    uint64
+	open      map[uint64]intentRecord // Незавершенные намерения
+	recovered []intentRecord          // Незавершенные намерения предыдущего запуска
+}
+
+// Открытие журнала намерений с восстановлением незавершенных намерений предыдущего запуска
+func OpenIntentLog(path string) (*IntentLog, error) {
+	log := &IntentLog{
+		path:   path,
+		nextID: 1,
+		open:   make(map[uint64]intentRecord),
+	}
+	if err := log.load(); err != nil {
+		return nil, err
+	}
+	for _, record := range log.open {
+		log.recovered = append(log.recovered, record)
+	}
+	slices.SortFunc(log.recovered, func(a, b intentRecord) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал намерений: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("не удалось открыть журнал намерений: %w", err)
+	}
+	log.file = file
+	log.size = info.Size()
+	return log, nil
+}
+
+// Количество незавершенных намерений предыдущего запуска, ожидающих сверки
+func (l *IntentLog) Recovered() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.recovered)
+}
+
+// Запись намерения перед отправкой запроса в octet.
+// Возвращает идентификатор намерения для последующего вызова Complete.
+func (l *IntentLog) Begin(op protocol.CommandType, uuid, data string) (uint64, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record := intentRecord{ID: l.nextID, Time: time.Now(), Op: op, Uuid: uuid}
+	if len(data) != 0 {
+		record.Hash = payloadHash(data)
+	}
+	// Намерение должно оказаться на диске до отправки запроса
+	if err := l.append(record, true); err != nil {
+		return 0, fmt.Errorf("не удалось записать намерение в журнал: %w", err)
+	}
+	l.nextID++
+	l.open[record.ID] = record
+	return record.ID, nil
+}
+
+// Отметка о завершении намерения после получения ответа от octet.
+// Если результат запроса неизвестен (соединение разорвано или истек таймаут),
+// намерение остается незавершенным и будет сверено при следующем запуске.
+func (l *IntentLog) Complete(id uint64, err error) {
+	if l == nil || outcomeUnknown(err) {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, ok := l.open[id]; !ok {
+		return
+	}
+	delete(l.open, id)
+	// Потеря отметки о завершении не опасна: намерение будет сверено повторно,
+	// поэтому синхронизация с диском не требуется
+	l.append(intentRecord{ID: id, Done: true}, false)
+	if l.size > intentLogCompactSize {
+		l.compact()
+	}
+}
+
+// Сверка незавершенных намерений предыдущего запуска с octet.
+// Если repair включен, незавершенные удаления применяются повторно.
+// При недоступности octet сверка прерывается, а непроверенные намерения остаются в журнале.
+func (l *IntentLog) Reconcile(ctx context.Context, pool *ClientPool, repair bool) ([]IntentReport, error) {
+	l.mutex.Lock()
+	recovered := l.recovered
+	l.mutex.Unlock()
+
+	// Для каждого UUID проверяем только последнее намерение
+	latest := make(map[string]uint64)
+	for _, record := range recovered {
+		if len(record.Uuid) != 0 {
+			latest[record.Uuid] = record.ID
+		}
+	}
+
+	reports := make([]IntentReport, 0, len(recovered))
+	resolved := make(map[uint64]bool, len(recovered))
+	var reconcileErr error
+	for _, record := range recovered {
+		report := IntentReport{ID: record.ID, Time: record.Time, Op: record.Op, Uuid: record.Uuid}
+		switch {
+		case len(record.Uuid) != 0 && latest[record.Uuid] != record.ID:
+			report.Status = IntentSuperseded
+		case record.Op == protocol.CommandInsert && len(record.Uuid) == 0:
+			// UUID новой записи назначается octet и до получения ответа неизвестен
+			report.Status = IntentUnknown
+			report.Detail = "UUID добавленной записи неизвестен"
+		default:
+			status, detail, err := reconcileIntent(ctx, pool, record, repair)
+			if err != nil {
+				reconcileErr = fmt.Errorf("сверка намерения %d прервана: %w", record.ID, err)
+			}
+			report.Status, report.Detail = status, detail
+		}
+		if reconcileErr != nil {
+			break
+		}
+		reports = append(reports, report)
+		resolved[record.ID] = true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.recovered = slices.DeleteFunc(l.recovered, func(record intentRecord) bool {
+		return resolved[record.ID]
+	})
+	for id := range resolved {
+		delete(l.open, id)
+	}
+	if err := l.compact(); err != nil && reconcileErr == nil {
+		reconcileErr = err
+	}
+	return reports, reconcileErr
+}
+
+// Закрытие журнала намерений
+func (l *IntentLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Сверка одного намерения update/remove (или insert с указанным UUID) с текущими данными в octet
+func reconcileIntent(ctx context.Context, pool *ClientPool, record intentRecord, repair bool) (IntentStatus, string, error) {
+	client, err := pool.GetClient()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := client.Get(ctx, record.Uuid)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", "", err
+	}
+	found := err == nil
+
+	switch record.Op {
+	case protocol.CommandUpdate, protocol.CommandInsert:
+		if !found {
+			return IntentNotApplied, "запись не найдена", nil
+		}
+		if payloadHash(data) != record.Hash {
+			return IntentNotApplied, "данные записи не совпадают с отправленными", nil
+		}
+		return IntentApplied, "", nil
+	case protocol.CommandRemove:
+		if !found {
+			return IntentApplied, "", nil
+		}
+		if !repair {
+			return IntentNotApplied, "запись не удалена", nil
+		}
+		client, err := pool.GetClient()
+		if err != nil {
+			return "", "", err
+		}
+		if err := client.Remove(ctx, record.Uuid); err != nil {
+			if outcomeUnknown(err) {
+				return "", "", err
+			}
+			return IntentNotApplied, fmt.Sprintf("не удалось повторить удаление: %v", err), nil
+		}
+		return IntentRepaired, "", nil
+	default:
+		return IntentUnknown, fmt.Sprintf("неизвестная операция %q", record.Op), nil
+	}
+}
+
+// Чтение журнала и восстановление незавершенных намерений
+func (l *IntentLog) load() error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("не удалось прочитать журнал намерений: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		var record intentRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Последняя запись могла быть записана не полностью при аварийном завершении
+			continue
+		}
+		if record.Done {
+			delete(l.open, record.ID)
+		} else {
+			l.open[record.ID] = record
+		}
+		l.nextID = max(l.nextID, record.ID+1)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать журнал намерений: %w", err)
+	}
+	return nil
+}
+
+// Добавление записи в конец журнала
+func (l *IntentLog) append(record intentRecord, sync bool) error {
+	if l.file == nil {
+		return errors.New("журнал намерений закрыт")
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if sync {
+		return l.file.Sync()
+	}
+	return nil
+}
+
+// Перезапись журнала только с незавершенными намерениями
+func (l *IntentLog) compact() error {
+	if l.file == nil {
+		return nil
+	}
+
+	records := make([]intentRecord, 0, len(l.open))
+	for _, record := range l.open {
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b intentRecord) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось сжать журнал намерений: %w", err)
+	}
+	encoder := json.NewEncoder(tmp)
+	for _, record := range records {
+		if err = encoder.Encode(&record); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	info, statErr := tmp.Stat()
+	tmp.Close()
+	if err == nil {
+		err = statErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, l.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("не удалось сжать журнал намерений: %w", err)
+	}
+
+	// Продолжаем запись в новый файл журнала
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть журнал намерений: %w", err)
+	}
+	l.file.Close()
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Хеш данных для сверки без хранения самих данных в журнале
+func payloadHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// Неизвестен ли результат запроса, завершившегося ошибкой
+func outcomeUnknown(err error) bool {
+	return errors.Is(err, ErrBackendUnavailable) || errors.Is(err, ErrTimeout) ||
+		errors.Is(err, protocol.ErrFrameTooLarge)
+}
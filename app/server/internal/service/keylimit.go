@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Достигнуто максимальное количество строк в хранилище
+var ErrKeyLimitReached = errors.New("достигнуто максимальное количество строк в хранилище")
+
+// Ограничение количества строк в хранилище. Количество берется из octet::stats при запуске
+// и с интервалом обновления, а между обновлениями учитываются добавления и удаления через сервер.
+// Пока количество не получено, добавления не ограничиваются.
+// Нулевой указатель не ограничивает добавления.
+type KeyLimit struct {
+	max      int64
+	interval time.Duration
+	logger   *zap.Logger
+
+	count    atomic.Int64 // Оценка количества строк
+	known    atomic.Bool  // Количество получено от octet
+	reached  atomic.Bool
+	Rejected atomic.Uint64 // Отклоненные добавления
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Создание ограничения количества строк
+func NewKeyLimit(max uint64, interval time.Duration, logger *zap.Logger) (*KeyLimit, error) {
+	if max == 0 || interval <= 0 {
+		return nil, fmt.Errorf("внутренняя ошибка: не указано ограничение или интервал обновления количества строк")
+	}
+	return &KeyLimit{
+		max:      int64(max),
+		interval: interval,
+		logger:   logger,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Запуск обновления количества строк из octet
+func (l *KeyLimit) Start(pool *ClientPool) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+		for {
+			l.refresh(pool)
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Остановка обновления количества строк
+func (l *KeyLimit) Stop() {
+	close(l.done)
+	l.wg.Wait()
+}
+
+// Получение количества строк от octet
+func (l *KeyLimit) refresh(pool *ClientPool) {
+	client, err := pool.GetClient()
+	if err != nil {
+		l.logger.Debug("Не удалось обновить количество строк", zap.Error(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.interval)
+	defer cancel()
+	count, err := client.Stats(ctx)
+	if err != nil {
+		l.logger.Debug("Не удалось обновить количество строк", zap.Error(err))
+		return
+	}
+	l.count.Store(int64(count))
+	l.known.Store(true)
+	l.update()
+}
+
+// Учет достижения ограничения
+func (l *KeyLimit) update() {
+	count := l.count.Load()
+	reached := count >= l.max
+	if l.reached.Swap(reached) == reached {
+		return
+	}
+	if reached {
+		l.logger.Warn("Достигнуто максимальное количество строк в хранилище, добавление строк отклоняется",
+			zap.Int64("count", count), zap.Int64("max", l.max))
+	} else {
+		l.logger.Info("Количество строк в хранилище ниже ограничения, добавление строк возобновлено",
+			zap.Int64("count", count), zap.Int64("max", l.max))
+	}
+}
+
+// Резервирование места под добавляемую строку
+func (l *KeyLimit) reserve() error {
+	if l == nil || !l.known.Load() {
+		return nil
+	}
+	if l.count.Add(1) > l.max {
+		l.count.Add(-1)
+		l.Rejected.Add(1)
+		l.update()
+		return ErrKeyLimitReached
+	}
+	return nil
+}
+
+// Отклоняются ли добавления строк (нулевой указатель добавления не ограничивает)
+func (l *KeyLimit) Reached() bool {
+	return l != nil && l.reached.Load()
+}
+
+// Отмена резервирования после неудачного добавления
+func (l *KeyLimit) release() {
+	if l == nil || !l.known.Load() {
+		return
+	}
+	l.count.Add(-1)
+}
+
+// Учет удаленной строки
+func (l *KeyLimit) removed() {
+	if l == nil || !l.known.Load() {
+		return
+	}
+	l.count.Add(-1)
+	l.update()
+}
+
+// Состояние ограничения количества строк
+type KeyLimitStats struct {
+	Max      uint64 `json:"max"`      // Максимальное количество строк
+	Count    *int64 `json:"count"`    // Оценка количества строк (null - еще не получено от octet)
+	Reached  bool   `json:"reached"`  // Добавление строк отклоняется
+	Rejected uint64 `json:"rejected"` // Отклоненные добавления
+}
+
+// Состояние ограничения количества строк
+func (l *KeyLimit) Stats() KeyLimitStats {
+	stats := KeyLimitStats{Max: uint64(l.max), Reached: l.reached.Load(), Rejected: l.Rejected.Load()}
+	if l.known.Load() {
+		count := l.count.Load()
+		stats.Count = &count
+	}
+	return stats
+}
+
+// Вывод метрик ограничения количества строк в формате Prometheus
+func (l *KeyLimit) WritePrometheus(w io.Writer, openMetrics bool) error {
+	stats := l.Stats()
+	reached := 0
+	if stats.Reached {
+		reached = 1
+	}
+	rejected := "octet_key_limit_rejected_total"
+	if openMetrics {
+		rejected = "octet_key_limit_rejected"
+	}
+
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	printf("# HELP octet_key_limit Максимальное количество строк в хранилище.\n")
+	printf("# TYPE octet_key_limit gauge\n")
+	printf("octet_key_limit %d\n", stats.Max)
+	if stats.Count != nil {
+		printf("# HELP octet_keys Оценка количества строк в хранилище.\n")
+		printf("# TYPE octet_keys gauge\n")
+		printf("octet_keys %d\n", *stats.Count)
+	}
+	printf("# HELP octet_key_limit_reached Достигнуто ли максимальное количество строк (1 - добавление отклоняется).\n")
+	printf("# TYPE octet_key_limit_reached gauge\n")
+	printf("octet_key_limit_reached %d\n", reached)
+	printf("# HELP %s Добавления, отклоненные из-за максимального количества строк.\n", rejected)
+	printf("# TYPE %s counter\n", rejected)
+	printf("octet_key_limit_rejected_total %d\n", stats.Rejected)
+	return err
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Количество последних измерений длительности, хранимых для каждой команды
+const latencyWindow = 1024
+
+// Количество новых измерений, после которого процентили пересчитываются
+// (чтобы не сортировать окно при каждом запросе)
+const latencyResortInterval = 64
+
+// Процентили длительности команд, публикуемые в метриках
+var latencyQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Скользящее окно длительностей одной команды
+type commandLatency struct {
+	samples []time.Duration // Кольцевой буфер последних измерений
+	next    int             // Позиция следующего измерения в буфере
+	sorted  []time.Duration // Отсортированная копия окна на момент последнего пересчета
+	pending int             // Измерения, добавленные после последнего пересчета
+}
+
+// Процентили длительности последних команд octet по типу команды
+type LatencyTracker struct {
+	mutex    sync.Mutex
+	commands map[protocol.CommandType]*commandLatency
+}
+
+// Учет длительности выполненной команды
+func (t *LatencyTracker) observe(command protocol.CommandType, elapsed time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.commands == nil {
+		t.commands = make(map[protocol.CommandType]*commandLatency)
+	}
+	latency, ok := t.commands[command]
+	if !ok {
+		latency = &commandLatency{samples: make([]time.Duration, 0, latencyWindow)}
+		t.commands[command] = latency
+	}
+
+	if len(latency.samples) < latencyWindow {
+		latency.samples = append(latency.samples, elapsed)
+	} else {
+		latency.samples[latency.next] = elapsed
+	}
+	latency.next = (latency.next + 1) % latencyWindow
+	latency.pending++
+}
+
+// Процентиль q (0-1) длительности последних выполнений команды
+// и количество измерений, по которым он рассчитан
+func (t *LatencyTracker) Percentile(command protocol.CommandType, q float64) (time.Duration, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	latency, ok := t.commands[command]
+	if !ok {
+		return 0, 0
+	}
+	return latency.percentile(q), len(latency.sorted)
+}
+
+// Процентиль по отсортированному окну (вызывается под блокировкой)
+func (l *commandLatency) percentile(q float64) time.Duration {
+	if l.sorted == nil || l.pending >= latencyResortInterval {
+		l.sorted = slices.Clone(l.samples)
+		slices.Sort(l.sorted)
+		l.pending = 0
+	}
+	index := int(math.Ceil(q*float64(len(l.sorted)))) - 1
+	return l.sorted[min(max(index, 0), len(l.sorted)-1)]
+}
+
+// Параметры таймаута чтения, рассчитываемого по длительности последних выполнений команды
+type AdaptiveTimeout struct {
+	Percentile float64       // Процентиль длительности (0-1), от которого рассчитывается таймаут
+	Factor     float64       // Множитель процентиля
+	Min        time.Duration // Нижняя граница таймаута
+	Max        time.Duration // Верхняя граница таймаута (0 - таймаут чтения пула)
+	// Количество измерений, до накопления которого используется таймаут чтения пула
+	MinSamples int
+}
+
+// Таймаут чтения ответа на команду (fallback - если измерений недостаточно)
+func (a *AdaptiveTimeout) timeout(latency *LatencyTracker, command protocol.CommandType, fallback time.Duration) time.Duration {
+	if a == nil || latency == nil {
+		return fallback
+	}
+	percentile, samples := latency.Percentile(command, a.Percentile)
+	if samples < a.MinSamples {
+		return fallback
+	}
+	return min(max(time.Duration(float64(percentile)*a.Factor), a.Min), a.Max)
+}
+
+// Запись процентилей длительности команд и текущих таймаутов чтения в текстовом формате Prometheus
+func (t *LatencyTracker) writePrometheus(w io.Writer, adaptive *AdaptiveTimeout, fallback time.Duration) error {
+	t.mutex.Lock()
+	commands := make([]protocol.CommandType, 0, len(t.commands))
+	for command := range t.commands {
+		commands = append(commands, command)
+	}
+	t.mutex.Unlock()
+	sort.Slice(commands, func(i, j int) bool { return commands[i] < commands[j] })
+
+	out := errWriter{w: w}
+
+	out.printf("# HELP octet_backend_command_latency_seconds Процентили длительности последних %d выполнений команд octet.\n", latencyWindow)
+	out.printf("# TYPE octet_backend_command_latency_seconds gauge\n")
+	for _, command := range commands {
+		for _, q := range latencyQuantiles {
+			percentile, _ := t.Percentile(command, q)
+			out.printf("octet_backend_command_latency_seconds{command=%q,quantile=\"%s\"} %g\n",
+				command, strconv.FormatFloat(q, 'f', -1, 64), percentile.Seconds())
+		}
+	}
+
+	out.printf("# HELP octet_backend_read_timeout_seconds Текущий таймаут чтения ответа на команду octet.\n")
+	out.printf("# TYPE octet_backend_read_timeout_seconds gauge\n")
+	for _, command := range commands {
+		out.printf("octet_backend_read_timeout_seconds{command=%q} %g\n",
+			command, adaptive.timeout(t, command, fallback).Seconds())
+	}
+	return out.err
+}
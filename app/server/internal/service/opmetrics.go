@@ -0,0 +1,255 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Результаты выполнения команд octet
+const (
+	ResultSuccess      = "success"       // Команда выполнена успешно
+	ResultBackendError = "backend_error" // octet вернул ошибку (в том числе отсутствие записи)
+	ResultTimeout      = "timeout"       // octet не ответил вовремя
+	ResultUnavailable  = "unavailable"   // octet недоступен или соединение разорвано
+	ResultError        = "error"         // Прочие ошибки (например, слишком большое сообщение)
+)
+
+// Границы интервалов гистограммы длительности команд в секундах
+var durationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Границы интервалов гистограммы размера данных в байтах
+var payloadBuckets = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// Пример значения гистограммы (exemplar) со ссылкой на трассу запроса
+type exemplar struct {
+	traceId string
+	value   float64
+	time    time.Time
+}
+
+// Гистограмма с фиксированными границами интервалов
+type histogram struct {
+	counts []uint64 // Количество значений в каждом интервале (последний - выше всех границ)
+	sum    float64
+	count  uint64
+	// Последнее значение каждого интервала из запроса с контекстом трассировки
+	exemplars []exemplar
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{counts: make([]uint64, len(bounds)+1)}
+}
+
+// Учет значения; непустой traceId сохраняется как пример значения интервала
+func (h *histogram) observe(bounds []float64, value float64, traceId string) {
+	index, _ := slices.BinarySearch(bounds, value)
+	h.counts[index]++
+	h.sum += value
+	h.count++
+	if len(traceId) != 0 {
+		if h.exemplars == nil {
+			h.exemplars = make([]exemplar, len(bounds)+1)
+		}
+		h.exemplars[index] = exemplar{traceId: traceId, value: value, time: time.Now()}
+	}
+}
+
+// Счетчики одной команды
+type commandStats struct {
+	results      map[string]uint64
+	duration     histogram
+	requestData  histogram // Размер данных в запросе (insert/update)
+	responseData histogram // Размер данных в ответе (get)
+}
+
+// Счетчики и гистограммы команд octet по типу команды и результату выполнения
+type CommandMetrics struct {
+	mutex    sync.Mutex
+	commands map[protocol.CommandType]*commandStats
+}
+
+// Учет выполненной команды
+func (m *CommandMetrics) observe(req *protocol.Request, resp *protocol.Response, elapsed time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.commands == nil {
+		m.commands = make(map[protocol.CommandType]*commandStats)
+	}
+	stats, ok := m.commands[req.Command]
+	if !ok {
+		stats = &commandStats{
+			results:      make(map[string]uint64),
+			duration:     newHistogram(durationBuckets),
+			requestData:  newHistogram(payloadBuckets),
+			responseData: newHistogram(payloadBuckets),
+		}
+		m.commands[req.Command] = stats
+	}
+
+	stats.results[commandResult(err)]++
+	stats.duration.observe(durationBuckets, elapsed.Seconds(), traceId(req))
+	if len(req.Params.Data) != 0 {
+		stats.requestData.observe(payloadBuckets, float64(len(req.Params.Data)), "")
+	}
+	if resp != nil && len(resp.Params.Data) != 0 {
+		stats.responseData.observe(payloadBuckets, float64(len(resp.Params.Data)), "")
+	}
+}
+
+// Суммарные счетчики команд для оценки состояния octet
+type commandTotals struct {
+	commands uint64   // Количество команд
+	failed   uint64   // Команды, завершившиеся таймаутом, недоступностью octet или прочей ошибкой
+	duration []uint64 // Количество команд по интервалам гистограммы длительности
+}
+
+// Суммарные счетчики указанных команд
+func (m *CommandMetrics) totals(commands []protocol.CommandType) commandTotals {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	totals := commandTotals{duration: make([]uint64, len(durationBuckets)+1)}
+	for _, command := range commands {
+		stats, ok := m.commands[command]
+		if !ok {
+			continue
+		}
+		for result, count := range stats.results {
+			totals.commands += count
+			if result != ResultSuccess && result != ResultBackendError {
+				totals.failed += count
+			}
+		}
+		for i, count := range stats.duration.counts {
+			totals.duration[i] += count
+		}
+	}
+	return totals
+}
+
+// trace_id из контекста трассировки запроса (пустая строка - запрос без контекста)
+func traceId(req *protocol.Request) string {
+	if req.TraceContext.Parent == "" {
+		return ""
+	}
+	// Формат traceparent проверен при разборе заголовка: версия-trace_id-parent_id-флаги
+	return req.TraceContext.Parent[3:35]
+}
+
+// Результат выполнения команды по ошибке
+func commandResult(err error) string {
+	var backendErr *BackendError
+	switch {
+	case err == nil:
+		return ResultSuccess
+	case errors.As(err, &backendErr):
+		return ResultBackendError
+	case errors.Is(err, ErrTimeout):
+		return ResultTimeout
+	case errors.Is(err, ErrBackendUnavailable):
+		return ResultUnavailable
+	default:
+		return ResultError
+	}
+}
+
+// Запись метрик в текстовом формате Prometheus. В формате OpenMetrics интервалы гистограммы
+// длительности дополняются примерами значений с trace_id, по которым можно перейти к трассе запроса.
+func (m *CommandMetrics) WritePrometheus(w io.Writer, openMetrics bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	commands := make([]protocol.CommandType, 0, len(m.commands))
+	for command := range m.commands {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i] < commands[j] })
+
+	out := errWriter{w: w, openMetrics: openMetrics}
+
+	// В OpenMetrics имя семейства счетчиков указывается без суффикса _total
+	if openMetrics {
+		out.printf("# HELP octet_backend_commands Количество команд octet по результату выполнения.\n")
+		out.printf("# TYPE octet_backend_commands counter\n")
+	} else {
+		out.printf("# HELP octet_backend_commands_total Количество команд octet по результату выполнения.\n")
+		out.printf("# TYPE octet_backend_commands_total counter\n")
+	}
+	for _, command := range commands {
+		results := make([]string, 0, len(m.commands[command].results))
+		for result := range m.commands[command].results {
+			results = append(results, result)
+		}
+		sort.Strings(results)
+		for _, result := range results {
+			out.printf("octet_backend_commands_total{command=%q,result=%q} %d\n",
+				command, result, m.commands[command].results[result])
+		}
+	}
+
+	out.printf("# HELP octet_backend_command_duration_seconds Длительность выполнения команд octet.\n")
+	out.printf("# TYPE octet_backend_command_duration_seconds histogram\n")
+	for _, command := range commands {
+		out.histogram("octet_backend_command_duration_seconds", fmt.Sprintf("command=%q", command),
+			durationBuckets, &m.commands[command].duration)
+	}
+
+	out.printf("# HELP octet_backend_payload_bytes Размер данных строк в командах octet.\n")
+	out.printf("# TYPE octet_backend_payload_bytes histogram\n")
+	for _, command := range commands {
+		stats := m.commands[command]
+		if stats.requestData.count != 0 {
+			out.histogram("octet_backend_payload_bytes", fmt.Sprintf("command=%q,direction=\"request\"", command),
+				payloadBuckets, &stats.requestData)
+		}
+		if stats.responseData.count != 0 {
+			out.histogram("octet_backend_payload_bytes", fmt.Sprintf("command=%q,direction=\"response\"", command),
+				payloadBuckets, &stats.responseData)
+		}
+	}
+	return out.err
+}
+
+// Запись с сохранением первой ошибки
+type errWriter struct {
+	w           io.Writer
+	err         error
+	openMetrics bool // Формат OpenMetrics (с примерами значений гистограмм)
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+// Запись гистограммы с накопленными значениями интервалов
+func (e *errWriter) histogram(name, labels string, bounds []float64, h *histogram) {
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += h.counts[i]
+		e.printf("%s_bucket{%s,le=\"%s\"} %d%s\n", name, labels, strconv.FormatFloat(bound, 'f', -1, 64), cumulative,
+			e.exemplar(h, i))
+	}
+	e.printf("%s_bucket{%s,le=\"+Inf\"} %d%s\n", name, labels, h.count, e.exemplar(h, len(bounds)))
+	e.printf("%s_sum{%s} %g\n", name, labels, h.sum)
+	e.printf("%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// Пример значения интервала гистограммы в формате OpenMetrics (пустая строка - примера нет)
+func (e *errWriter) exemplar(h *histogram, index int) string {
+	if !e.openMetrics || h.exemplars == nil || len(h.exemplars[index].traceId) == 0 {
+		return ""
+	}
+	ex := h.exemplars[index]
+	return fmt.Sprintf(" # {trace_id=%q} %g %.3f", ex.traceId, ex.value, float64(ex.time.UnixMilli())/1000)
+}
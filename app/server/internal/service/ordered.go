@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+	"go.uber.org/zap"
+)
+
+// Параметры упорядоченной записи
+type OrderedWriterConfig struct {
+	SequenceFile string // Файл последнего выданного номера
+	Sync         bool   // Сброс номера на диск до выполнения изменения
+}
+
+// Изменение, ожидающее выполнения в очереди упорядоченной записи
+type orderedOp struct {
+	ctx  context.Context
+	op   protocol.BatchOperation
+	done chan OrderedResult
+}
+
+// Результат упорядоченного изменения
+type OrderedResult struct {
+	Uuid     string // UUID добавленной строки (для insert)
+	Sequence uint64 // Номер изменения (0 - изменение не выполнено)
+	Err      error
+}
+
+// Упорядоченная запись: изменения выполняются строго по одному в порядке поступления в очередь,
+// и каждое выполненное изменение получает следующий номер. Номер сохраняется в файл до выполнения
+// изменения, поэтому после перезапуска (с Sync - и после сбоя ОС) номера не выдаются повторно
+// и задают полный порядок изменений. Номера возрастают без пропусков, кроме номера изменения,
+// во время выполнения которого сервер аварийно завершился.
+type OrderedWriter struct {
+	pool     *ClientPool
+	config   OrderedWriterConfig
+	logger   *zap.Logger
+	file     *os.File
+	sequence atomic.Uint64 // Последний выданный номер (изменяется только горутиной очереди)
+	stored   uint64        // Номер, сохраненный в файле (не меньше выданного)
+	ops      chan *orderedOp
+	done     chan struct{} // Закрывается при остановке
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+// Открытие файла номеров и запуск очереди упорядоченной записи
+func OpenOrderedWriter(pool *ClientPool, config OrderedWriterConfig, logger *zap.Logger) (*OrderedWriter, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.SequenceFile), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию файла номеров: %w", err)
+	}
+	file, err := os.OpenFile(config.SequenceFile, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл номеров: %w", err)
+	}
+
+	w := &OrderedWriter{
+		pool:   pool,
+		config: config,
+		logger: logger,
+		file:   file,
+		ops:    make(chan *orderedOp),
+		done:   make(chan struct{}),
+	}
+	sequence, err := w.load()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	w.sequence.Store(sequence)
+	w.stored = sequence
+	w.wg.Add(1)
+	go w.runLoop()
+	return w, nil
+}
+
+// Последний выданный номер
+func (w *OrderedWriter) Sequence() uint64 {
+	return w.sequence.Load()
+}
+
+// Выполнение octet::insert в очереди (ttlSeconds - срок хранения строки, 0 - без ограничения)
+func (w *OrderedWriter) Insert(ctx context.Context, data string, ttlSeconds uint64) OrderedResult {
+	return w.submit(ctx, protocol.BatchOperation{Command: protocol.CommandInsert, Data: data, TtlSeconds: ttlSeconds})
+}
+
+// Выполнение octet::insert с указанным UUID в очереди (только если строки с таким UUID еще нет,
+// ttlSeconds - срок хранения строки, 0 - без ограничения)
+func (w *OrderedWriter) Create(ctx context.Context, uuid, data string, ttlSeconds uint64) OrderedResult {
+	return w.submit(ctx, protocol.BatchOperation{Command: protocol.CommandInsert, Uuid: uuid, Data: data, TtlSeconds: ttlSeconds})
+}
+
+// Выполнение octet::update в очереди (expectedVersion - ожидаемая версия строки, 0 - без проверки)
+func (w *OrderedWriter) Update(ctx context.Context, uuid, data string, expectedVersion uint64) OrderedResult {
+	return w.submit(ctx, protocol.BatchOperation{Command: protocol.CommandUpdate, Uuid: uuid, Data: data,
+		ExpectedVersion: expectedVersion})
+}
+
+// Выполнение octet::remove в очереди
+func (w *OrderedWriter) Remove(ctx context.Context, uuid string) OrderedResult {
+	return w.submit(ctx, protocol.BatchOperation{Command: protocol.CommandRemove, Uuid: uuid})
+}
+
+// Остановка очереди с ожиданием выполняемого изменения
+func (w *OrderedWriter) Close() error {
+	w.once.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return w.file.Close()
+}
+
+// Постановка изменения в очередь и ожидание его результата
+func (w *OrderedWriter) submit(ctx context.Context, op protocol.BatchOperation) OrderedResult {
+	pending := &orderedOp{ctx: ctx, op: op, done: make(chan OrderedResult, 1)}
+	select {
+	case w.ops <- pending:
+	case <-ctx.Done():
+		return OrderedResult{Err: ctx.Err()}
+	case <-w.done:
+		return OrderedResult{Err: fmt.Errorf("%w: упорядоченная запись остановлена", ErrBackendUnavailable)}
+	}
+	// Изменение, взятое из очереди, выполняется, поэтому ожидаем его результат
+	return <-pending.done
+}
+
+// Выполнение изменений из очереди по одному
+func (w *OrderedWriter) runLoop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case pending := <-w.ops:
+			pending.done <- w.execute(pending)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Выполнение изменения и выдача ему следующего номера
+func (w *OrderedWriter) execute(pending *orderedOp) OrderedResult {
+	if err := pending.ctx.Err(); err != nil {
+		return OrderedResult{Err: err}
+	}
+
+	client, err := w.pool.GetClientFor(PoolClassWrite)
+	if err != nil {
+		return OrderedResult{Err: err}
+	}
+
+	// Номер сохраняется до выполнения изменения: если сервер завершится после изменения,
+	// следующий запуск продолжит с номера после него. Без сохраненного номера изменение
+	// не выполняется. Номер изменения, завершившегося ошибкой, остается сохраненным
+	// и выдается следующему изменению без повторной записи.
+	sequence := w.sequence.Load() + 1
+	if sequence > w.stored {
+		if err := w.store(sequence); err != nil {
+			client.Release()
+			w.logger.Error("Не удалось сохранить номер упорядоченной записи",
+				zap.Uint64("sequence", sequence), zap.Error(err))
+			return OrderedResult{Err: fmt.Errorf("не удалось сохранить номер упорядоченной записи: %w", err)}
+		}
+		w.stored = sequence
+	}
+
+	var result OrderedResult
+	switch pending.op.Command {
+	case protocol.CommandInsert:
+		result.Uuid, result.Err = client.InsertAs(pending.ctx, pending.op.Uuid, pending.op.Data, pending.op.TtlSeconds)
+	case protocol.CommandUpdate:
+		result.Err = client.UpdateIf(pending.ctx, pending.op.Uuid, pending.op.Data, pending.op.ExpectedVersion)
+	case protocol.CommandRemove:
+		result.Err = client.Remove(pending.ctx, pending.op.Uuid)
+	default:
+		client.Release()
+		result.Err = fmt.Errorf("команда %s не поддерживается упорядоченной записью", pending.op.Command)
+	}
+	if result.Err != nil {
+		return result
+	}
+
+	w.sequence.Store(sequence)
+	result.Sequence = sequence
+	return result
+}
+
+// Чтение последнего выданного номера (пустой файл - номера еще не выдавались)
+func (w *OrderedWriter) load() (uint64, error) {
+	data, err := os.ReadFile(w.config.SequenceFile)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать файл номеров: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if len(value) == 0 {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("файл номеров упорядоченной записи поврежден")
+	}
+	return sequence, nil
+}
+
+// Запись номера, выдаваемого следующему изменению. Номер дополняется пробелами до постоянной длины,
+// поэтому файл перезаписывается на месте без усечения.
+func (w *OrderedWriter) store(sequence uint64) error {
+	line := fmt.Sprintf("%-20d\n", sequence)
+	if _, err := w.file.WriteAt([]byte(line), 0); err != nil {
+		return err
+	}
+	if w.config.Sync {
+		return w.file.Sync()
+	}
+	return nil
+}
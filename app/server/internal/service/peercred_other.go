@@ -0,0 +1,13 @@
+//go:build !linux
+
+package service
+
+import (
+	"errors"
+	"net"
+)
+
+// Учетные данные процесса на другом конце Unix-сокета (поддерживается только в Linux)
+func peerCredentials(conn *net.UnixConn) (pid, uid int, err error) {
+	return 0, 0, errors.New("проверка учетных данных процесса не поддерживается в этой ОС")
+}
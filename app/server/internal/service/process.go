@@ -2,13 +2,16 @@ package service
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/protocol"
 	"go.uber.org/zap"
 )
 
@@ -22,9 +25,26 @@ const (
 	ProcessFailed                         // Процесс завершился с ошибкой
 )
 
+// Текстовое представление состояния процесса
+func (s ProcessState) String() string {
+	switch s {
+	case ProcessNotStarted:
+		return "not_started"
+	case ProcessRunning:
+		return "running"
+	case ProcessStopped:
+		return "stopped"
+	case ProcessFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
 // Структура управления процессом octet
 type ProcessManager struct {
 	config       *config.Config
+	octetPath    string // Исполняемый файл octet (может быть заменен при обновлении)
 	cmd          *exec.Cmd
 	logger       *zap.Logger
 	mutex        sync.Mutex
@@ -32,20 +52,58 @@ type ProcessManager struct {
 	exitCode     int
 	exitError    error
 	stateChanged chan struct{}
+	exit         *processExit    // Завершение запущенного процесса
+	startedAt    time.Time       // Время запуска текущего процесса
+	events       *StateEvents    // События изменения состояния (nil - не рассылаются)
+	history      *ProcessHistory // История запусков и завершений (nil - не записывается)
+}
+
+// Результат завершения запущенного процесса
+type processExit struct {
+	done chan struct{} // Закрывается после завершения процесса
+	err  error         // Ошибка завершения (доступна после закрытия done)
 }
 
 // Создание нового ProcessManager
 func NewProcessManager(config *config.Config) *ProcessManager {
 	return &ProcessManager{
 		config:       config,
+		octetPath:    config.OctetPath,
 		logger:       zap.NewNop(),
 		state:        ProcessNotStarted,
 		stateChanged: make(chan struct{}, 1),
 	}
 }
 
+// Создание ProcessManager для процесса octet, запущенного вне сервера
+// (например, тестового backend). Процесс считается работающим, но не может
+// быть остановлен или завершен через ProcessManager.
+func NewExternalProcessManager() *ProcessManager {
+	pm := NewProcessManager(&config.Config{})
+	pm.state = ProcessRunning
+	return pm
+}
+
 // Запуск процесса octet
 func (pm *ProcessManager) Start() error {
+	return pm.start(ProcessReasonStartup)
+}
+
+// Запуск процесса octet с записью в историю процесса
+func (pm *ProcessManager) start(reason string) error {
+	event := ProcessEvent{Kind: ProcessEventStarted, Reason: reason, OctetPath: pm.OctetPath()}
+	err := pm.launch()
+	if err != nil {
+		event.Kind, event.Error = ProcessEventStartFailed, err.Error()
+	} else {
+		event.Pid = pm.Pid()
+	}
+	pm.recordHistory(event)
+	return err
+}
+
+// Запуск процесса и ожидание создания им файла сокета
+func (pm *ProcessManager) launch() error {
 	pm.mutex.Lock()
 
 	if pm.state == ProcessRunning {
@@ -53,13 +111,21 @@ func (pm *ProcessManager) Start() error {
 		return fmt.Errorf("процесс уже запущен")
 	}
 
+	// Директория хранилища определяется при каждом запуске: разметка могла измениться
+	storageDir, err := pm.config.OctetStorageDir()
+	if err != nil {
+		pm.mutex.Unlock()
+		pm.changeState(ProcessFailed)
+		return err
+	}
+
 	pm.logger.Info("Запуск процесса octet",
-		zap.String("octet", pm.config.OctetPath),
-		zap.String("storage", pm.config.StorageDir),
+		zap.String("octet", pm.octetPath),
+		zap.String("storage", storageDir),
 		zap.String("socket", pm.config.SocketPath))
 
 	// Проверяем, что исполняемый файл существует
-	if _, err := os.Stat(pm.config.OctetPath); err != nil {
+	if _, err := os.Stat(pm.octetPath); err != nil {
 		pm.mutex.Unlock()
 		pm.changeState(ProcessFailed)
 		return fmt.Errorf("исполняемый файл не найден: %w", err)
@@ -77,11 +143,17 @@ func (pm *ProcessManager) Start() error {
 	}
 
 	// Создаем команду для запуска процесса
+	// Ограничение размера сообщений совпадает с ограничением для ответов octet
+	maxFrameSize := pm.config.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = protocol.DefaultMaxFrameSize
+	}
 	pm.cmd = exec.Command(
-		pm.config.OctetPath,
-		"--storage="+pm.config.StorageDir,
+		pm.octetPath,
+		"--storage="+storageDir,
 		"--server",
 		"--socket="+pm.config.SocketPath,
+		"--max-message-size="+strconv.FormatInt(maxFrameSize, 10),
 	)
 
 	// Настраиваем перенаправление stdout и stderr
@@ -122,15 +194,50 @@ func (pm *ProcessManager) Start() error {
 		return fmt.Errorf("файл сокета не был создан в течение таймаута")
 	}
 
+	// Ограничиваем доступ к сокету, чтобы с octet не могли взаимодействовать другие пользователи
+	if err := pm.restrictSocket(); err != nil {
+		pm.cmd.Process.Kill()
+		pm.cmd.Wait()
+		pm.mutex.Unlock()
+		pm.changeState(ProcessFailed)
+		return err
+	}
+
+	pm.exit = &processExit{done: make(chan struct{})}
+	pm.startedAt = time.Now()
 	pm.mutex.Unlock()
 	pm.changeState(ProcessRunning)
-	go pm.monitorProcess()
+	go pm.monitorProcess(pm.cmd, pm.exit)
 
 	return nil
 }
 
 // Остановка процесса octet
 func (pm *ProcessManager) Stop() error {
+	return pm.stop(ProcessReasonShutdown)
+}
+
+// Остановка процесса octet с записью в историю процесса
+func (pm *ProcessManager) stop(reason string) error {
+	pm.mutex.Lock()
+	cmd, startedAt := pm.cmd, pm.startedAt
+	pm.mutex.Unlock()
+
+	if err := pm.halt(); err != nil {
+		return err
+	}
+	event := ProcessEvent{Kind: ProcessEventStopped, Pid: cmd.Process.Pid, Reason: reason,
+		UptimeMs: time.Since(startedAt).Milliseconds()}
+	if cmd.ProcessState != nil {
+		exitCode := cmd.ProcessState.ExitCode()
+		event.ExitCode = &exitCode
+	}
+	pm.recordHistory(event)
+	return nil
+}
+
+// Завершение процесса сигналом SIGTERM, а при таймауте - SIGKILL
+func (pm *ProcessManager) halt() error {
 	pm.mutex.Lock()
 
 	if pm.state != ProcessRunning || pm.cmd == nil {
@@ -152,15 +259,10 @@ func (pm *ProcessManager) Stop() error {
 	}
 
 	// Ждем завершения процесса с таймаутом
-	done := make(chan error, 1)
-	go func() {
-		done <- pm.cmd.Wait()
-	}()
-
-	var err error
+	// (завершения процесса ожидает monitorProcess, поэтому Wait здесь не вызывается)
 	select {
-	case err = <-done:
-		if err != nil {
+	case <-pm.exit.done:
+		if err := pm.exit.err; err != nil {
 			pm.logger.Warn("Процесс завершился с ошибкой", zap.Error(err))
 		} else {
 			pm.logger.Info("Процесс успешно завершился")
@@ -174,20 +276,168 @@ func (pm *ProcessManager) Stop() error {
 			return fmt.Errorf("не удалось принудительно завершить процесс: %w", err)
 		}
 
-		err = <-done
-		if err != nil {
+		<-pm.exit.done
+		if err := pm.exit.err; err != nil {
 			pm.logger.Warn("Процесс завершился с ошибкой после принудительного завершения",
 				zap.Error(err))
 		}
 	}
 
+	pm.state = ProcessStopped
+	pm.cmd = nil
 	pm.mutex.Unlock()
 	pm.changeState(ProcessStopped)
-	pm.cmd = nil
 
 	return nil
 }
 
+// Перезапуск процесса octet: остановка работающего процесса и запуск нового.
+// Процесс, завершившийся с ошибкой, запускается повторно. Причина записывается в историю процесса.
+func (pm *ProcessManager) Restart(reason string) error {
+	pm.mutex.Lock()
+	state, cmd := pm.state, pm.cmd
+	pm.mutex.Unlock()
+
+	if cmd == nil && state == ProcessRunning {
+		return fmt.Errorf("процесс octet запущен вне сервера и не может быть перезапущен")
+	}
+	if state == ProcessRunning {
+		if err := pm.stop(reason); err != nil {
+			return fmt.Errorf("не удалось остановить процесс octet: %w", err)
+		}
+	}
+	if err := pm.start(reason); err != nil {
+		return err
+	}
+	pm.events.publish(StateEvent{Kind: StateProcessRestarted, Pid: pm.Pid()})
+	return nil
+}
+
+// Подписки на события изменения состояния процесса (устанавливаются до Start)
+func (pm *ProcessManager) SetStateEvents(events *StateEvents) {
+	pm.events = events
+}
+
+// История запусков и завершений процесса (устанавливается до Start)
+func (pm *ProcessManager) SetHistory(history *ProcessHistory) {
+	pm.history = history
+}
+
+// Запись события в историю процесса. Ошибка записи не влияет на управление процессом.
+func (pm *ProcessManager) recordHistory(event ProcessEvent) {
+	event.Time = time.Now()
+	if err := pm.history.record(event); err != nil {
+		pm.logger.Warn("Не удалось записать событие в историю процесса octet", zap.Error(err))
+	}
+}
+
+// Исполняемый файл octet, запускаемый ProcessManager
+func (pm *ProcessManager) OctetPath() string {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.octetPath
+}
+
+// Замена исполняемого файла octet. Новый файл используется при следующем запуске процесса.
+func (pm *ProcessManager) SetOctetPath(path string) error {
+	if err := CheckOctetPath(path); err != nil {
+		return err
+	}
+
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.octetPath = path
+	return nil
+}
+
+// Проверка, что по пути находится исполняемый файл
+func CheckOctetPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("исполняемый файл не найден: %w", err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("файл '%s' не является исполняемым", path)
+	}
+	return nil
+}
+
+// Управляется ли процесс octet сервером (для процесса, запущенного вне сервера, - false)
+func (pm *ProcessManager) Managed() bool {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.cmd != nil || pm.state != ProcessRunning
+}
+
+// PID запущенного процесса octet (0 - процесс не запущен или запущен вне сервера)
+func (pm *ProcessManager) Pid() int {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	if pm.cmd == nil || pm.cmd.Process == nil {
+		return 0
+	}
+	return pm.cmd.Process.Pid
+}
+
+// Принудительное завершение процесса octet сигналом SIGKILL.
+// В отличие от Stop, завершение считается аварийным (состояние ProcessFailed).
+func (pm *ProcessManager) Kill() error {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if pm.state != ProcessRunning || pm.cmd == nil {
+		return fmt.Errorf("процесс octet не запущен")
+	}
+
+	pm.logger.Warn("Принудительное завершение процесса octet")
+	if err := pm.cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("не удалось завершить процесс: %w", err)
+	}
+	return nil
+}
+
+// Установка прав доступа к сокету, созданному octet
+func (pm *ProcessManager) restrictSocket() error {
+	perms, err := NewSocketPermissions(pm.config.SocketPermissions)
+	if err != nil {
+		return err
+	}
+	if err := perms.Apply(pm.config.SocketPath); err != nil {
+		return err
+	}
+	pm.logger.Info("Установлены права доступа к сокету",
+		zap.String("socket", pm.config.SocketPath), zap.String("mode", fmt.Sprintf("%04o", perms.Mode)))
+	return nil
+}
+
+// Проверка, что на другом конце соединения находится запущенный этим
+// ProcessManager процесс octet, а не посторонний процесс, занявший путь сокета.
+// Для процесса, запущенного вне сервера, проверка не выполняется.
+func (pm *ProcessManager) VerifyPeer(conn net.Conn) error {
+	pm.mutex.Lock()
+	cmd := pm.cmd
+	pm.mutex.Unlock()
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("соединение не является Unix-сокетом")
+	}
+	pid, uid, err := peerCredentials(unixConn)
+	if err != nil {
+		return fmt.Errorf("не удалось получить учетные данные процесса: %w", err)
+	}
+	if pid != cmd.Process.Pid {
+		return fmt.Errorf("PID процесса на сокете: %d, ожидался %d", pid, cmd.Process.Pid)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("UID процесса на сокете: %d, ожидался %d", uid, os.Getuid())
+	}
+	return nil
+}
+
 // Геттер для текущего состояния процесса
 func (pm *ProcessManager) GetState() (ProcessState, int, error) {
 	pm.mutex.Lock()
@@ -215,29 +465,32 @@ func (pm *ProcessManager) WaitForStateChange(timeout time.Duration) bool {
 }
 
 // Отслеживание работы процесса
-func (pm *ProcessManager) monitorProcess() {
-	if pm.cmd == nil {
-		return
-	}
-
-	// Ждем завершения процесса
-	err := pm.cmd.Wait()
+func (pm *ProcessManager) monitorProcess(cmd *exec.Cmd, exit *processExit) {
+	// Ждем завершения процесса (Stop ожидает закрытия exit.done, удерживая блокировку)
+	err := cmd.Wait()
+	exit.err = err
+	close(exit.done)
 
 	// Если процесс завершился, обновляем состояние
 	pm.mutex.Lock()
 
-	// Проверяем, был ли процесс остановлен намеренно
-	if pm.state == ProcessStopped {
+	// Проверяем, был ли процесс остановлен намеренно или уже заменен новым
+	if pm.state == ProcessStopped || pm.cmd != cmd {
 		pm.mutex.Unlock()
 		return
 	}
 
 	exitCode := 0
-	if pm.cmd.ProcessState != nil {
-		exitCode = pm.cmd.ProcessState.ExitCode()
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
 	}
 	pm.exitCode = exitCode
 	pm.exitError = err
+	event := ProcessEvent{Kind: ProcessEventCrashed, Pid: cmd.Process.Pid, ExitCode: &exitCode,
+		UptimeMs: time.Since(pm.startedAt).Milliseconds()}
+	if err != nil {
+		event.Error = err.Error()
+	}
 
 	if err != nil {
 		pm.logger.Error("Процесс octet завершился с ошибкой",
@@ -249,6 +502,7 @@ func (pm *ProcessManager) monitorProcess() {
 	}
 
 	pm.mutex.Unlock()
+	pm.recordHistory(event)
 
 	// Изменяем состояние
 	pm.changeState(ProcessFailed)
@@ -258,7 +512,12 @@ func (pm *ProcessManager) changeState(state ProcessState) {
 	// Изменяем состояние
 	pm.mutex.Lock()
 	pm.state = state
+	event := StateEvent{Kind: StateProcessChanged, State: state.String()}
+	if pm.cmd != nil && pm.cmd.Process != nil {
+		event.Pid = pm.cmd.Process.Pid
+	}
 	pm.mutex.Unlock()
+	pm.events.publish(event)
 
 	// Уведомляем об изменении состояния
 	select {
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Вид события жизненного цикла процесса octet
+type ProcessEventKind string
+
+const (
+	ProcessEventStarted     ProcessEventKind = "started"      // Процесс запущен
+	ProcessEventStartFailed ProcessEventKind = "start_failed" // Процесс не удалось запустить
+	ProcessEventStopped     ProcessEventKind = "stopped"      // Процесс остановлен сервером
+	ProcessEventCrashed     ProcessEventKind = "crashed"      // Процесс завершился без запроса сервера
+)
+
+// Причины запуска и остановки процесса octet
+const (
+	ProcessReasonStartup  = "startup"  // Запуск сервера
+	ProcessReasonShutdown = "shutdown" // Завершение работы сервера
+	ProcessReasonUpgrade  = "upgrade"  // Обновление исполняемого файла
+	ProcessReasonRollback = "rollback" // Возврат к прежнему исполняемому файлу после неудачного обновления
+)
+
+// Событие жизненного цикла процесса octet
+type ProcessEvent struct {
+	Seq       uint64           `json:"seq"` // Порядковый номер события
+	Time      time.Time        `json:"time"`
+	Kind      ProcessEventKind `json:"kind"`
+	Pid       int              `json:"pid,omitempty"`
+	Reason    string           `json:"reason,omitempty"`     // Причина запуска или остановки
+	OctetPath string           `json:"octet_path,omitempty"` // Исполняемый файл запущенного процесса
+	ExitCode  *int             `json:"exit_code,omitempty"`  // Код завершения (-1 - завершен сигналом)
+	Error     string           `json:"error,omitempty"`
+	UptimeMs  int64            `json:"uptime_ms,omitempty"` // Время работы завершившегося процесса
+}
+
+// История событий жизненного цикла процесса octet в файле (одно событие в строке).
+// Хранится не больше maxRecords последних событий, поэтому история переживает перезапуски
+// сервера и ротацию логов, но не растет неограниченно.
+type ProcessHistory struct {
+	mutex      sync.Mutex
+	path       string
+	maxRecords int
+	file       *os.File
+	records    []ProcessEvent
+	lines      int // Количество строк в файле
+	nextSeq    uint64
+}
+
+// Открытие файла истории процесса. Записанные ранее события сохраняются.
+func OpenProcessHistory(path string, maxRecords int) (*ProcessHistory, error) {
+	if maxRecords <= 0 {
+		return nil, fmt.Errorf("количество событий истории процесса должно быть положительным")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию истории процесса: %w", err)
+	}
+
+	h := &ProcessHistory{path: path, maxRecords: maxRecords, nextSeq: 1}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	if err := h.rewrite(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Закрытие файла истории
+func (h *ProcessHistory) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+// События от старых к новым
+func (h *ProcessHistory) Records() []ProcessEvent {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]ProcessEvent(nil), h.records...)
+}
+
+// Добавление события. При превышении количества событий самые старые удаляются из файла
+// не при каждой записи, а когда файл вдвое превышает размер истории.
+func (h *ProcessHistory) record(event ProcessEvent) error {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return fmt.Errorf("история процесса закрыта")
+	}
+	event.Seq = h.nextSeq
+	h.nextSeq++
+	h.records = append(h.records, event)
+	if len(h.records) > h.maxRecords {
+		h.records = h.records[len(h.records)-h.maxRecords:]
+	}
+
+	if h.lines >= 2*h.maxRecords {
+		return h.rewrite()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("не удалось записать событие в историю процесса: %w", err)
+	}
+	if err := h.file.Sync(); err != nil {
+		return fmt.Errorf("не удалось записать событие в историю процесса: %w", err)
+	}
+	h.lines++
+	return nil
+}
+
+// Чтение последних maxRecords событий из файла
+func (h *ProcessHistory) load() error {
+	file, err := os.Open(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл истории процесса: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event ProcessEvent
+		// Поврежденная строка (например, при сбое во время записи) пропускается
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Seq == 0 {
+			continue
+		}
+		h.records = append(h.records, event)
+		h.nextSeq = max(h.nextSeq, event.Seq+1)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать файл истории процесса: %w", err)
+	}
+	if len(h.records) > h.maxRecords {
+		h.records = h.records[len(h.records)-h.maxRecords:]
+	}
+	return nil
+}
+
+// Перезапись файла истории только с хранимыми событиями (вызывается под блокировкой)
+func (h *ProcessHistory) rewrite() error {
+	tmpPath := h.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось перезаписать файл истории процесса: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, event := range h.records {
+		line, _ := json.Marshal(event)
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось перезаписать файл истории процесса: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось перезаписать файл истории процесса: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		return fmt.Errorf("не удалось перезаписать файл истории процесса: %w", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл истории процесса: %w", err)
+	}
+	if h.file != nil {
+		h.file.Close()
+	}
+	h.file = file
+	h.lines = len(h.records)
+	return nil
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Окончательное удаление уже выполняется
+var ErrPurgeRunning = errors.New("окончательное удаление строк уже выполняется")
+
+// Интервал опроса состояния сжатия хранилища при окончательном удалении
+const purgeCompactionPoll = 100 * time.Millisecond
+
+// Итог окончательного удаления строки
+const (
+	PurgeRemoved  = "removed"   // Строка удалена
+	PurgeNotFound = "not_found" // Строки не было (ее история удаляется сжатием)
+	PurgeFailed   = "failed"    // Строку не удалось удалить
+)
+
+// Параметры окончательного удаления строк
+type PurgeConfig struct {
+	SigningKey     string        // Ключ HMAC-SHA256 для подписи отчетов
+	MaxUuids       int           // Максимальное количество строк в одном удалении
+	CompactTimeout time.Duration // Максимальное ожидание сжатия хранилища
+}
+
+// Итог окончательного удаления одной строки
+type PurgeItem struct {
+	Uuid   string `json:"uuid"`
+	Status string `json:"status"` // removed, not_found или failed
+	Error  string `json:"error,omitempty"`
+}
+
+// Отчет об окончательном удалении строк
+type PurgeReport struct {
+	ID          string      `json:"id"` // Идентификатор отчета
+	StartedAt   time.Time   `json:"started_at"`
+	CompletedAt time.Time   `json:"completed_at"`
+	Items       []PurgeItem `json:"items"`
+	// Сжатие хранилища, удалившее из журнала операций octet прежние значения строк
+	Compaction CompactionStatus `json:"compaction"`
+	// Удалено записей обмена с octet из буфера трассировки
+	TraceEntriesRemoved int `json:"trace_entries_removed"`
+	// Все строки удалены вместе с историей (иначе причина - в error и в итогах строк)
+	Complete bool   `json:"complete"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Окончательное удаление строк (например, по требованию субъекта данных): строки удаляются
+// из octet без асинхронного удаления и очередей записи, затем сжатие хранилища удаляет их прежние
+// значения из журнала операций, а из буфера трассировки удаляется обмен, относящийся к строкам.
+// Отчет подписывается для использования как подтверждение удаления.
+type Purger struct {
+	pool     *ClientPool
+	recorder *TrafficRecorder
+	config   PurgeConfig
+	logger   *zap.Logger
+	mutex    sync.Mutex
+}
+
+// Создание окончательного удаления строк
+func NewPurger(pool *ClientPool, recorder *TrafficRecorder, config PurgeConfig, logger *zap.Logger) (*Purger, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if len(config.SigningKey) == 0 {
+		return nil, fmt.Errorf("не указан ключ подписи отчетов об окончательном удалении")
+	}
+	if config.MaxUuids <= 0 {
+		return nil, fmt.Errorf("максимальное количество строк окончательного удаления должно быть положительным")
+	}
+	if config.CompactTimeout <= 0 {
+		return nil, fmt.Errorf("время ожидания сжатия хранилища должно быть положительным")
+	}
+	return &Purger{pool: pool, recorder: recorder, config: config, logger: logger}, nil
+}
+
+// Окончательное удаление строк uuids. Одновременно выполняется только одно удаление,
+// иначе возвращается ErrPurgeRunning. Ошибки удаления отдельных строк и сжатия отражаются в отчете.
+func (p *Purger) Purge(ctx context.Context, uuids []string) (PurgeReport, error) {
+	if !p.mutex.TryLock() {
+		return PurgeReport{}, ErrPurgeRunning
+	}
+	defer p.mutex.Unlock()
+	if len(uuids) > p.config.MaxUuids {
+		return PurgeReport{}, fmt.Errorf("количество строк превышает допустимое (%d)", p.config.MaxUuids)
+	}
+
+	report := PurgeReport{ID: uuid.NewString(), StartedAt: time.Now().UTC(), Complete: true}
+	purged := make(map[string]bool, len(uuids))
+	for _, id := range uuids {
+		if purged[id] {
+			continue
+		}
+		purged[id] = true
+		item := p.remove(ctx, id)
+		if item.Status == PurgeFailed {
+			report.Complete = false
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	// Прежние значения остаются в журнале операций octet до сжатия хранилища
+	status, err := p.compact(ctx)
+	report.Compaction = status
+	if err != nil {
+		report.Complete = false
+		report.Error = "не удалось сжать хранилище: " + err.Error()
+	}
+	report.TraceEntriesRemoved = p.recorder.Forget(purged)
+	report.CompletedAt = time.Now().UTC()
+
+	p.logger.Warn("Выполнено окончательное удаление строк", zap.String("report", report.ID),
+		zap.Int("uuids", len(report.Items)), zap.Bool("complete", report.Complete))
+	return report, nil
+}
+
+// Максимальное количество строк в одном удалении
+func (p *Purger) MaxUuids() int {
+	return p.config.MaxUuids
+}
+
+// Подпись тела отчета (HMAC-SHA256 в шестнадцатеричном виде)
+func (p *Purger) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.config.SigningKey))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Удаление строки из octet
+func (p *Purger) remove(ctx context.Context, uuid string) PurgeItem {
+	item := PurgeItem{Uuid: uuid, Status: PurgeRemoved}
+	client, err := p.pool.GetClientFor(PoolClassWrite)
+	if err == nil {
+		err = client.Remove(ctx, uuid)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotFound):
+		item.Status = PurgeNotFound
+	default:
+		item.Status = PurgeFailed
+		item.Error = err.Error()
+	}
+	return item
+}
+
+// Сжатие хранилища с ожиданием завершения. Сжатие, запущенное до удаления строк, могло создать
+// снапшот с ними, поэтому сначала дожидаемся его завершения и запускаем новое.
+func (p *Purger) compact(ctx context.Context) (CompactionStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.CompactTimeout)
+	defer cancel()
+
+	client, err := p.pool.GetClientFor(PoolClassWrite)
+	if err != nil {
+		return CompactionStatus{}, err
+	}
+	defer client.Release()
+
+	status, err := p.waitCompaction(ctx, client.Client)
+	if err != nil {
+		return status, err
+	}
+	if status, err = client.Client.Compact(ctx); err != nil {
+		return status, err
+	}
+	if status, err = p.waitCompaction(ctx, client.Client); err != nil {
+		return status, err
+	}
+	if status.State != "completed" {
+		return status, fmt.Errorf("сжатие завершилось в состоянии %s", status.State)
+	}
+	return status, nil
+}
+
+// Ожидание завершения выполняемого сжатия хранилища
+func (p *Purger) waitCompaction(ctx context.Context, client *Client) (CompactionStatus, error) {
+	for {
+		status, err := client.CompactionStatus(ctx)
+		if err != nil || status.State != "running" {
+			return status, err
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(purgeCompactionPoll):
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Проверка строк по политикам хранения уже выполняется
+var ErrRetentionRunning = errors.New("проверка строк по политикам хранения уже выполняется")
+
+// Количество строк, запрашиваемых у octet за один раз при проверке
+const retentionPageSize = 1000
+
+// Количество UUID строк старше срока хранения, сохраняемых в отчете
+const retentionSampleSize = 100
+
+// Признак, от которого отсчитывается возраст строки
+type RetentionBasis string
+
+const (
+	RetentionByCreated  RetentionBasis = "created"  // От добавления строки
+	RetentionByModified RetentionBasis = "modified" // От последнего изменения строки
+)
+
+// Политика хранения строк пространства имен
+type RetentionPolicy struct {
+	Namespace string
+	MaxAge    time.Duration  // Строки старше удаляются
+	BasedOn   RetentionBasis // Признак возраста строки
+}
+
+// Параметры удаления строк по политикам хранения
+type RetentionConfig struct {
+	Interval time.Duration // Интервал проверки строк по расписанию (0 - только по запросу)
+	DryRun   bool          // Только сообщать о строках старше срока хранения, не удаляя их
+	Policies []RetentionPolicy
+	// Вызывается после удаления строки (например, для записи события изменения данных)
+	OnRemove func(ctx context.Context, namespace, uuid string)
+}
+
+// Итог проверки строк пространства имен по политике хранения
+type RetentionReport struct {
+	Namespace  string         `json:"namespace"`
+	BasedOn    RetentionBasis `json:"based_on"`
+	Cutoff     time.Time      `json:"cutoff"` // Строки, добавленные или измененные раньше, старше срока хранения
+	DryRun     bool           `json:"dry_run"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Scanned    int            `json:"scanned"` // Проверено строк
+	Expired    int            `json:"expired"` // Строк старше срока хранения
+	Removed    int            `json:"removed"` // Удалено строк (при dry_run - 0)
+	Unknown    int            `json:"unknown"` // Строк с неизвестным временем добавления или изменения (не удаляются)
+	Failed     int            `json:"failed"`  // Строк, которые не удалось проверить или удалить
+	// Первые UUID строк старше срока хранения (не больше 100)
+	Sample []string `json:"sample,omitempty"`
+	// Причина прерывания проверки (отсутствует, если проверены все строки)
+	Error string `json:"error,omitempty"`
+}
+
+// Удаление строк старше срока хранения по политикам пространств имен.
+// Строки проверяются периодически: octet перечисляет UUID, а время добавления и последнего изменения
+// берется из метаданных строки (octet::stat). В режиме dry_run строки не удаляются, а отчет
+// показывает, что было бы удалено, чтобы политику можно было проверить до включения.
+type Retention struct {
+	pool   *ClientPool
+	config RetentionConfig
+	logger *zap.Logger
+
+	running atomic.Bool
+	mutex   sync.Mutex
+	reports []RetentionReport // Отчеты последней проверки
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Создание удаления строк по политикам хранения
+func NewRetention(pool *ClientPool, config RetentionConfig, logger *zap.Logger) (*Retention, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if len(config.Policies) == 0 {
+		return nil, fmt.Errorf("не указаны политики хранения строк")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Retention{pool: pool, config: config, logger: logger, ctx: ctx, cancel: cancel}, nil
+}
+
+// Запуск периодической проверки строк
+func (r *Retention) Start() {
+	if r.config.Interval <= 0 {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := r.Run(r.ctx, r.config.DryRun); err != nil && !errors.Is(err, ErrRetentionRunning) {
+				r.logger.Warn("Проверка строк по политикам хранения не выполнена", zap.Error(err))
+			}
+		}
+	}()
+}
+
+// Остановка периодической проверки с ожиданием выполняемой
+func (r *Retention) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// Включен ли режим dry_run в конфигурации
+func (r *Retention) DryRun() bool {
+	return r.config.DryRun
+}
+
+// Выполняется ли проверка по расписанию
+func (r *Retention) Scheduled() bool {
+	return r.config.Interval > 0
+}
+
+// Выполняется ли проверка в данный момент
+func (r *Retention) Running() bool {
+	return r.running.Load()
+}
+
+// Отчеты последней проверки (nil - проверка еще не выполнялась)
+func (r *Retention) Reports() []RetentionReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.reports
+}
+
+// Проверка строк по всем политикам (dryRun - только отчет без удаления).
+// Одновременно выполняется только одна проверка, иначе возвращается ErrRetentionRunning.
+func (r *Retention) Run(ctx context.Context, dryRun bool) ([]RetentionReport, error) {
+	if !r.running.CompareAndSwap(false, true) {
+		return nil, ErrRetentionRunning
+	}
+	defer r.running.Store(false)
+
+	reports := make([]RetentionReport, 0, len(r.config.Policies))
+	for _, policy := range r.config.Policies {
+		report := r.apply(ctx, policy, dryRun)
+		if len(report.Error) != 0 {
+			r.logger.Warn("Проверка строк по политике хранения прервана", zap.String("namespace", report.Namespace),
+				zap.Int("scanned", report.Scanned), zap.String("error", report.Error))
+		} else if report.Expired != 0 {
+			r.logger.Info("Проверены строки по политике хранения", zap.String("namespace", report.Namespace),
+				zap.Bool("dry_run", dryRun), zap.Int("scanned", report.Scanned), zap.Int("expired", report.Expired),
+				zap.Int("removed", report.Removed), zap.Int("failed", report.Failed))
+		}
+		reports = append(reports, report)
+	}
+
+	r.mutex.Lock()
+	r.reports = reports
+	r.mutex.Unlock()
+	return reports, nil
+}
+
+// Проверка строк по политике хранения. При недоступности octet проверка прерывается до следующей.
+func (r *Retention) apply(ctx context.Context, policy RetentionPolicy, dryRun bool) RetentionReport {
+	now := time.Now()
+	report := RetentionReport{
+		Namespace: policy.Namespace,
+		BasedOn:   policy.BasedOn,
+		Cutoff:    now.Add(-policy.MaxAge).UTC(),
+		DryRun:    dryRun,
+		StartedAt: now.UTC(),
+	}
+	defer func() { report.FinishedAt = time.Now().UTC() }()
+
+	// Хранилище octet пока содержит одно пространство имен, поэтому проверяются все строки
+	after := ""
+	for {
+		client, err := r.pool.GetClientFor(PoolClassRead)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		uuids, more, err := client.List(ctx, after, retentionPageSize)
+		if err != nil {
+			report.Error = err.Error()
+			return report
+		}
+		for _, uuid := range uuids {
+			if err := r.check(ctx, policy, uuid, dryRun, &report); err != nil {
+				report.Error = err.Error()
+				return report
+			}
+		}
+		if !more || len(uuids) == 0 {
+			return report
+		}
+		after = uuids[len(uuids)-1]
+	}
+}
+
+// Проверка и, если строка старше срока хранения, удаление одной строки.
+// Ошибка возвращается, только если проверку нужно прервать.
+func (r *Retention) check(ctx context.Context, policy RetentionPolicy, uuid string, dryRun bool, report *RetentionReport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	client, err := r.pool.GetClientFor(PoolClassRead)
+	if err != nil {
+		return err
+	}
+	stat, err := client.Stat(ctx, uuid)
+	if errors.Is(err, ErrNotFound) {
+		// Строка удалена после перечисления
+		return nil
+	} else if err != nil {
+		if outcomeUnknown(err) {
+			return err
+		}
+		report.Failed++
+		return nil
+	}
+	report.Scanned++
+
+	at := stat.CreatedAt
+	if policy.BasedOn == RetentionByModified {
+		at = stat.ModifiedAt
+	}
+	if at.IsZero() {
+		report.Unknown++
+		return nil
+	}
+	if !at.Before(report.Cutoff) {
+		return nil
+	}
+	report.Expired++
+	if len(report.Sample) < retentionSampleSize {
+		report.Sample = append(report.Sample, uuid)
+	}
+	if dryRun {
+		return nil
+	}
+
+	client, err = r.pool.GetClientFor(PoolClassWrite)
+	if err != nil {
+		return err
+	}
+	err = client.Remove(ctx, uuid)
+	switch {
+	case err == nil:
+		report.Removed++
+		if r.config.OnRemove != nil {
+			r.config.OnRemove(ctx, policy.Namespace, uuid)
+		}
+	case errors.Is(err, ErrNotFound):
+	case outcomeUnknown(err):
+		return err
+	default:
+		report.Failed++
+		r.logger.Warn("Не удалось удалить строку старше срока хранения", zap.String("uuid", uuid), zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/lildannita/octet-server/internal/config"
+)
+
+// Права доступа к сокету octet
+type SocketPermissions struct {
+	Mode os.FileMode
+	UID  int // -1 - владелец не проверяется и не изменяется
+	GID  int // -1 - группа не проверяется и не изменяется
+}
+
+// Разрешение прав доступа из конфигурации (имена пользователя и группы преобразуются в UID/GID)
+func NewSocketPermissions(cfg config.SocketPermissionsConfig) (*SocketPermissions, error) {
+	mode, err := cfg.FileMode()
+	if err != nil {
+		return nil, err
+	}
+	perms := &SocketPermissions{Mode: mode, UID: -1, GID: -1}
+
+	if len(cfg.Owner) != 0 {
+		if perms.UID, err = strconv.Atoi(cfg.Owner); err != nil {
+			owner, err := user.Lookup(cfg.Owner)
+			if err != nil {
+				return nil, fmt.Errorf("владелец сокета не найден: %w", err)
+			}
+			perms.UID, _ = strconv.Atoi(owner.Uid)
+		}
+	}
+	if len(cfg.Group) != 0 {
+		if perms.GID, err = strconv.Atoi(cfg.Group); err != nil {
+			group, err := user.LookupGroup(cfg.Group)
+			if err != nil {
+				return nil, fmt.Errorf("группа сокета не найдена: %w", err)
+			}
+			perms.GID, _ = strconv.Atoi(group.Gid)
+		}
+	}
+	return perms, nil
+}
+
+// Установка владельца и прав доступа к сокету
+func (p *SocketPermissions) Apply(path string) error {
+	if p.UID != -1 || p.GID != -1 {
+		if err := os.Lchown(path, p.UID, p.GID); err != nil {
+			return fmt.Errorf("не удалось изменить владельца сокета: %w", err)
+		}
+	}
+	if err := os.Chmod(path, p.Mode); err != nil {
+		return fmt.Errorf("не удалось изменить права доступа к сокету: %w", err)
+	}
+	return nil
+}
+
+// Проверка, что по пути находится сокет с ожидаемыми владельцем и правами доступа
+func (p *SocketPermissions) Verify(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%s не является сокетом", path)
+	}
+	if info.Mode().Perm() != p.Mode {
+		return fmt.Errorf("права доступа к сокету %s: %04o, ожидались %04o", path, info.Mode().Perm(), p.Mode)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if p.UID != -1 && int(stat.Uid) != p.UID {
+		return fmt.Errorf("владелец сокета %s: %d, ожидался %d", path, stat.Uid, p.UID)
+	}
+	if p.GID != -1 && int(stat.Gid) != p.GID {
+		return fmt.Errorf("группа сокета %s: %d, ожидалась %d", path, stat.Gid, p.GID)
+	}
+	return nil
+}
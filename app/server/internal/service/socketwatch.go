@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Состояние файла сокета octet
+type SocketWatchStatus struct {
+	Degraded   bool       `json:"degraded"`         // Файл сокета удален или заменен при работающем процессе
+	Reason     string     `json:"reason,omitempty"` // Что произошло с файлом сокета
+	Since      *time.Time `json:"since,omitempty"`  // Когда это обнаружено
+	Detections int        `json:"detections"`       // Количество обнаружений с момента запуска
+}
+
+// Отслеживание файла сокета octet: если файл удален или заменен, пока процесс считается работающим,
+// новые соединения с octet невозможны. Вместо накопления непонятных ошибок подключения
+// состояние octet отмечается как деградировавшее, соединения пула переподключаются,
+// а при включенном автоматическом перезапуске процесс octet перезапускается.
+type SocketWatcher struct {
+	pool           *ClientPool
+	processManager *ProcessManager
+	path           string
+	autoRestart    bool
+	logger         *zap.Logger
+
+	mutex    sync.Mutex
+	pid      int         // Процесс, для которого запомнен файл сокета
+	expected os.FileInfo // Файл сокета, созданный этим процессом
+	status   SocketWatchStatus
+
+	stop func() // Остановка отслеживания каталога сокета
+}
+
+// Создание отслеживания файла сокета
+func NewSocketWatcher(pool *ClientPool, pm *ProcessManager, path string, autoRestart bool, logger *zap.Logger) (*SocketWatcher, error) {
+	if pool == nil || pm == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: не указан пул клиентов или ProcessManager")
+	}
+	return &SocketWatcher{
+		pool:           pool,
+		processManager: pm,
+		path:           path,
+		autoRestart:    autoRestart,
+		logger:         logger,
+	}, nil
+}
+
+// Запуск отслеживания. Отслеживается каталог сокета, чтобы замечать и удаление, и повторное создание файла.
+func (w *SocketWatcher) Start() error {
+	w.check()
+	name := filepath.Base(w.path)
+	stop, err := watchDir(filepath.Dir(w.path), func(changed string) {
+		if changed == name {
+			w.check()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось начать отслеживание файла сокета: %w", err)
+	}
+	w.stop = stop
+	return nil
+}
+
+// Остановка отслеживания
+func (w *SocketWatcher) Stop() {
+	if w.stop != nil {
+		w.stop()
+	}
+}
+
+// Состояние файла сокета (нулевой указатель - отслеживание отключено)
+func (w *SocketWatcher) Status() SocketWatchStatus {
+	if w == nil {
+		return SocketWatchStatus{}
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.status
+}
+
+// Сверка файла сокета с созданным работающим процессом
+func (w *SocketWatcher) check() {
+	// Во время запуска и остановки процесса файл сокета пересоздается
+	// (IsRunning ожидает завершения Start и Stop)
+	if !w.processManager.IsRunning() {
+		w.mutex.Lock()
+		w.pid, w.expected = 0, nil
+		w.mutex.Unlock()
+		return
+	}
+	pid := w.processManager.Pid()
+	info, err := os.Stat(w.path)
+
+	w.mutex.Lock()
+	if pid != w.pid || w.expected == nil {
+		// Новый процесс: его файл сокета становится ожидаемым
+		w.pid = pid
+		if err == nil {
+			w.expected = info
+			w.status.Degraded, w.status.Reason, w.status.Since = false, "", nil
+			w.mutex.Unlock()
+			return
+		}
+	}
+
+	var reason string
+	switch {
+	case err != nil:
+		reason = fmt.Sprintf("файл сокета %s удален", w.path)
+	case !os.SameFile(w.expected, info):
+		reason = fmt.Sprintf("файл сокета %s заменен другим файлом", w.path)
+	}
+	if len(reason) == 0 || w.status.Degraded {
+		w.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	w.status.Degraded, w.status.Reason, w.status.Since = true, reason, &now
+	w.status.Detections++
+	w.mutex.Unlock()
+
+	w.react(reason)
+}
+
+// Реакция на удаление или замену файла сокета
+func (w *SocketWatcher) react(reason string) {
+	w.logger.Error("Файл сокета octet изменен вне сервера, новые соединения с octet невозможны",
+		zap.String("reason", reason), zap.Int("pid", w.processManager.Pid()))
+
+	// Соединения с заменившим сокет процессом не должны использоваться как соединения с octet
+	w.pool.DisconnectIdle()
+
+	if !w.autoRestart {
+		w.logger.Warn("Автоматический перезапуск отключен, требуется перезапуск octet вручную")
+		return
+	}
+	if !w.processManager.Managed() {
+		w.logger.Warn("Процесс octet запущен вне сервера и не может быть перезапущен")
+		return
+	}
+	w.logger.Warn("Перезапускаем процесс octet после изменения файла сокета")
+	if err := w.processManager.Restart("socket_watch: " + reason); err != nil {
+		w.logger.Error("Не удалось перезапустить процесс octet", zap.Error(err))
+		return
+	}
+	w.pool.DisconnectIdle()
+	w.logger.Info("Процесс octet перезапущен")
+	w.check()
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// События каталога, после которых проверяется файл сокета
+const socketDirEvents = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Отслеживание изменений файлов каталога через inotify.
+// changed вызывается с именем созданного, удаленного или перемещенного файла.
+func watchDir(dir string, changed func(name string)) (stop func(), err error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, socketDirEvents); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// Неблокирующий дескриптор читается через планировщик Go, закрытие файла прерывает чтение
+	file := os.NewFile(uintptr(fd), "inotify")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameStart := offset + syscall.SizeofInotifyEvent
+				name := buf[nameStart : nameStart+int(event.Len)]
+				offset = nameStart + int(event.Len)
+				if event.Mask&socketDirEvents != 0 {
+					changed(string(bytes.TrimRight(name, "\x00")))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		file.Close()
+		<-done
+	}, nil
+}
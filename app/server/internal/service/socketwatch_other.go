@@ -0,0 +1,10 @@
+//go:build !linux
+
+package service
+
+import "errors"
+
+// Отслеживание изменений файлов каталога (поддерживается только в Linux)
+func watchDir(dir string, changed func(name string)) (stop func(), err error) {
+	return nil, errors.New("отслеживание файла сокета не поддерживается в этой ОС")
+}
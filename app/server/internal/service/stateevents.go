@@ -0,0 +1,89 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Вид события изменения состояния
+type StateEventKind string
+
+const (
+	StateConnectionOpened StateEventKind = "connection_opened" // Установлено соединение с octet
+	StateConnectionClosed StateEventKind = "connection_closed" // Соединение закрыто сервером (простой, переподключение)
+	StateConnectionBroken StateEventKind = "connection_broken" // Соединение разорвано из-за ошибки
+	StateProcessChanged   StateEventKind = "process_state"     // Изменилось состояние процесса octet
+	StateProcessRestarted StateEventKind = "process_restarted" // Процесс octet перезапущен
+)
+
+// Событие изменения состояния соединений с octet или процесса octet
+type StateEvent struct {
+	Kind  StateEventKind `json:"kind"`
+	Time  time.Time      `json:"time"`
+	State string         `json:"state,omitempty"` // Новое состояние процесса (для process_state)
+	Pid   int            `json:"pid,omitempty"`   // Процесс octet
+	Error string         `json:"error,omitempty"` // Причина разрыва соединения
+}
+
+// Подписки на события изменения состояния пула клиентов и ProcessManager.
+// Обработчики вызываются синхронно в момент изменения, поэтому не должны блокироваться
+// и обращаться к пулу клиентов или ProcessManager. Нулевой указатель события не рассылает.
+type StateEvents struct {
+	mutex       sync.Mutex
+	next        uint64
+	subscribers map[uint64]func(StateEvent)
+
+	Dropped atomic.Uint64 // События, не доставленные подписчикам-каналам из-за заполненного буфера
+}
+
+// Создание подписок на события изменения состояния
+func NewStateEvents() *StateEvents {
+	return &StateEvents{subscribers: make(map[uint64]func(StateEvent))}
+}
+
+// Подписка обработчиком. Возвращает функцию отмены подписки.
+func (e *StateEvents) Subscribe(handler func(StateEvent)) func() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	id := e.next
+	e.next++
+	e.subscribers[id] = handler
+	return func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		delete(e.subscribers, id)
+	}
+}
+
+// Подписка каналом с буфером size. События, не поместившиеся в буфер, отбрасываются.
+// Возвращает канал и функцию отмены подписки. Канал не закрывается.
+func (e *StateEvents) Channel(size int) (<-chan StateEvent, func()) {
+	events := make(chan StateEvent, size)
+	unsubscribe := e.Subscribe(func(event StateEvent) {
+		select {
+		case events <- event:
+		default:
+			e.Dropped.Add(1)
+		}
+	})
+	return events, unsubscribe
+}
+
+// Рассылка события подписчикам
+func (e *StateEvents) publish(event StateEvent) {
+	if e == nil {
+		return
+	}
+	event.Time = time.Now()
+	e.mutex.Lock()
+	handlers := make([]func(StateEvent), 0, len(e.subscribers))
+	for _, handler := range e.subscribers {
+		handlers = append(handlers, handler)
+	}
+	e.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Параметры асинхронного удаления
+type TombstonesConfig struct {
+	File          string        // Файл меток удаления
+	RetryInterval time.Duration // Интервал повторных попыток удаления в octet
+}
+
+// Запись файла меток удаления: метка или отметка о завершении удаления в octet
+type tombstoneRecord struct {
+	Uuid string    `json:"uuid"`
+	Time time.Time `json:"time,omitzero"`
+	Done bool      `json:"done,omitempty"`
+}
+
+// Асинхронное удаление строк с метками удаления (tombstones).
+// Метка записывается на диск до ответа клиенту, строка с меткой считается удаленной,
+// а удаление из octet выполняется в фоне и повторяется до успеха, в том числе после перезапуска сервера.
+// Поэтому время ответа на удаление не зависит от размера строки.
+type Tombstones struct {
+	mutex   sync.Mutex
+	pool    *ClientPool
+	config  TombstonesConfig
+	logger  *zap.Logger
+	file    *os.File
+	pending map[string]time.Time // UUID строк, ожидающих удаления в octet, и время записи метки
+	wake    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Открытие файла меток удаления и запуск фонового удаления строк с метками, в том числе
+// оставшихся от предыдущего запуска
+func OpenTombstones(pool *ClientPool, config TombstonesConfig, logger *zap.Logger) (*Tombstones, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if config.RetryInterval <= 0 {
+		return nil, fmt.Errorf("интервал повторного удаления должен быть положительным")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.File), 0700); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию меток удаления: %w", err)
+	}
+
+	t := &Tombstones{
+		pool:    pool,
+		config:  config,
+		logger:  logger,
+		pending: make(map[string]time.Time),
+		wake:    make(chan struct{}, 1),
+	}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	// Файл перезаписывается только с метками, удаление которых не завершено
+	if err := t.rewrite(); err != nil {
+		return nil, err
+	}
+
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.wg.Add(1)
+	go t.removeLoop()
+	if len(t.pending) != 0 {
+		logger.Info("Найдены строки, ожидающие удаления", zap.Int("count", len(t.pending)))
+		t.notify()
+	}
+	return t, nil
+}
+
+// Запись метки удаления. Возвращает false, если строка уже ожидает удаления.
+func (t *Tombstones) Add(uuid string) (bool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.file == nil {
+		return false, fmt.Errorf("%w: асинхронное удаление остановлено", ErrBackendUnavailable)
+	}
+	if _, ok := t.pending[uuid]; ok {
+		return false, nil
+	}
+	record := tombstoneRecord{Uuid: uuid, Time: time.Now()}
+	// Метка должна оказаться на диске до ответа клиенту
+	if err := t.append(record, true); err != nil {
+		return false, fmt.Errorf("не удалось записать метку удаления: %w", err)
+	}
+	t.pending[uuid] = record.Time
+	t.notify()
+	return true, nil
+}
+
+// Ожидает ли строка удаления (для nil - всегда false)
+func (t *Tombstones) Contains(uuid string) bool {
+	if t == nil {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, ok := t.pending[uuid]
+	return ok
+}
+
+// Количество строк, ожидающих удаления в octet
+func (t *Tombstones) Pending() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.pending)
+}
+
+// Остановка фонового удаления. Незавершенные удаления будут выполнены после следующего запуска.
+func (t *Tombstones) Close() error {
+	t.cancel()
+	t.wg.Wait()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+// Пробуждение фонового удаления без блокировки
+func (t *Tombstones) notify() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Фоновое удаление строк с метками: по новой метке и периодически для неудавшихся удалений
+func (t *Tombstones) removeLoop() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.config.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-t.wake:
+		case <-ticker.C:
+		}
+		t.removePending()
+	}
+}
+
+// Удаление из octet всех строк с метками. При недоступности octet проход прерывается до следующей попытки.
+func (t *Tombstones) removePending() {
+	t.mutex.Lock()
+	uuids := make([]string, 0, len(t.pending))
+	for uuid := range t.pending {
+		uuids = append(uuids, uuid)
+	}
+	t.mutex.Unlock()
+
+	for _, uuid := range uuids {
+		if t.ctx.Err() != nil {
+			return
+		}
+		client, err := t.pool.GetClientFor(PoolClassWrite)
+		if err == nil {
+			err = client.Remove(t.ctx, uuid)
+		}
+		// Строка, которой уже нет в octet, считается удаленной
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			if t.ctx.Err() == nil {
+				t.logger.Warn("Не удалось удалить строку с меткой удаления, удаление будет повторено",
+					zap.String("uuid", uuid), zap.Error(err))
+			}
+			return
+		}
+		t.complete(uuid)
+	}
+}
+
+// Отметка о завершении удаления строки в octet
+func (t *Tombstones) complete(uuid string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.pending, uuid)
+	if t.file == nil {
+		return
+	}
+	// Потеря отметки не опасна: повторное удаление отсутствующей строки завершается успешно
+	t.append(tombstoneRecord{Uuid: uuid, Done: true}, false)
+	if len(t.pending) == 0 {
+		t.rewrite()
+	}
+}
+
+// Чтение файла меток удаления. Поврежденная последняя строка (сбой во время записи) пропускается.
+func (t *Tombstones) load() error {
+	file, err := os.Open(t.config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл меток удаления: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record tombstoneRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || len(record.Uuid) == 0 {
+			continue
+		}
+		if record.Done {
+			delete(t.pending, record.Uuid)
+		} else {
+			t.pending[record.Uuid] = record.Time
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("не удалось прочитать файл меток удаления: %w", err)
+	}
+	return nil
+}
+
+// Перезапись файла меток удаления только с незавершенными удалениями
+func (t *Tombstones) rewrite() error {
+	tmpPath := t.config.File + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось перезаписать файл меток удаления: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for uuid, created := range t.pending {
+		line, _ := json.Marshal(tombstoneRecord{Uuid: uuid, Time: created})
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось перезаписать файл меток удаления: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось перезаписать файл меток удаления: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpPath, t.config.File); err != nil {
+		return fmt.Errorf("не удалось перезаписать файл меток удаления: %w", err)
+	}
+
+	file, err := os.OpenFile(t.config.File, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл меток удаления: %w", err)
+	}
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.file = file
+	return nil
+}
+
+// Добавление записи в файл (вызывается под блокировкой)
+func (t *Tombstones) append(record tombstoneRecord, sync bool) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := t.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return t.file.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Направление записанного сообщения
+type TraceDirection string
+
+const (
+	TraceRequest  TraceDirection = "request"  // Запрос к octet
+	TraceResponse TraceDirection = "response" // Ответ от octet
+)
+
+// Запись об одном сообщении, отправленном или полученном клиентом
+type TraceEntry struct {
+	Time      time.Time          `json:"time"`
+	Direction TraceDirection     `json:"direction"`
+	Request   *protocol.Request  `json:"request,omitempty"`
+	Response  *protocol.Response `json:"response,omitempty"`
+	Error     string             `json:"error,omitempty"`      // Ошибка отправки или чтения сообщения
+	LatencyUs int64              `json:"latency_us,omitempty"` // Время ожидания ответа в микросекундах
+}
+
+// Запись обмена сообщениями с octet в кольцевой буфер и (опционально) в файл
+// для отладки расхождений в протоколе
+type TrafficRecorder struct {
+	mutex   sync.Mutex
+	entries []TraceEntry // Кольцевой буфер
+	next    int          // Позиция следующей записи в буфере
+	full    bool         // Буфер заполнен хотя бы один раз
+	redact  bool         // Скрывать данные строк
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Создание нового TrafficRecorder.
+// Если path не пуст, записи дополнительно дописываются в файл в формате NDJSON.
+func NewTrafficRecorder(size int, path string, redact bool) (*TrafficRecorder, error) {
+	if size <= 0 {
+		size = 1000
+	}
+
+	recorder := &TrafficRecorder{
+		entries: make([]TraceEntry, size),
+		redact:  redact,
+	}
+	if len(path) != 0 {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть файл трассировки: %w", err)
+		}
+		recorder.file = file
+		recorder.encoder = json.NewEncoder(file)
+	}
+	return recorder, nil
+}
+
+// Запись отправленного запроса
+func (r *TrafficRecorder) RecordRequest(req *protocol.Request, err error) {
+	if r == nil {
+		return
+	}
+	copied := *req
+	copied.Params = r.redactParams(req.Params)
+	r.record(TraceEntry{Direction: TraceRequest, Request: &copied}, err)
+}
+
+// Запись полученного ответа (или ошибки его чтения)
+func (r *TrafficRecorder) RecordResponse(resp *protocol.Response, latency time.Duration, err error) {
+	if r == nil {
+		return
+	}
+	entry := TraceEntry{Direction: TraceResponse, LatencyUs: latency.Microseconds()}
+	if resp != nil {
+		copied := *resp
+		copied.Params = r.redactParams(resp.Params)
+		entry.Response = &copied
+	}
+	r.record(entry, err)
+}
+
+// Все записи буфера от старых к новым
+func (r *TrafficRecorder) Entries() []TraceEntry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.full {
+		return append([]TraceEntry(nil), r.entries[:r.next]...)
+	}
+	result := make([]TraceEntry, 0, len(r.entries))
+	result = append(result, r.entries[r.next:]...)
+	return append(result, r.entries[:r.next]...)
+}
+
+// Удаление из буфера обмена, относящегося к строкам uuids (запросы и ответы на них),
+// например при окончательном удалении строк. Файл трассировки не изменяется.
+// Возвращается количество удаленных записей.
+func (r *TrafficRecorder) Forget(uuids map[string]bool) int {
+	if r == nil || len(uuids) == 0 {
+		return 0
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Запрос и ответ связаны идентификатором запроса: UUID добавленной строки есть только в ответе
+	requestIds := make(map[string]bool)
+	for _, entry := range r.entries {
+		switch {
+		case entry.Request != nil && traceMentions(entry.Request.Params, uuids):
+			requestIds[entry.Request.RequestId] = true
+		case entry.Response != nil && traceMentions(entry.Response.Params, uuids):
+			requestIds[entry.Response.RequestId] = true
+		}
+	}
+	if len(requestIds) == 0 {
+		return 0
+	}
+
+	// Буфер пересобирается от старых записей к новым без удаленных
+	count := len(r.entries)
+	start := 0
+	if !r.full {
+		count = r.next
+	} else {
+		start = r.next
+	}
+	kept := make([]TraceEntry, 0, count)
+	for i := 0; i < count; i++ {
+		entry := r.entries[(start+i)%len(r.entries)]
+		if (entry.Request != nil && requestIds[entry.Request.RequestId]) ||
+			(entry.Response != nil && requestIds[entry.Response.RequestId]) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	removed := count - len(kept)
+	clear(r.entries)
+	copy(r.entries, kept)
+	r.next = len(kept) % len(r.entries)
+	r.full = len(kept) == len(r.entries)
+	return removed
+}
+
+// Упоминаются ли в параметрах запроса или ответа строки uuids
+func traceMentions(params protocol.AdditionalParams, uuids map[string]bool) bool {
+	if uuids[params.Uuid] {
+		return true
+	}
+	for _, op := range params.Operations {
+		if uuids[op.Uuid] {
+			return true
+		}
+	}
+	for _, result := range params.Results {
+		if uuids[result.Uuid] {
+			return true
+		}
+	}
+	for _, entry := range params.Journal {
+		if uuids[entry.Uuid] {
+			return true
+		}
+	}
+	return false
+}
+
+// Закрытие файла трассировки
+func (r *TrafficRecorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	r.encoder = nil
+	return err
+}
+
+// Добавление записи в буфер и файл
+func (r *TrafficRecorder) record(entry TraceEntry, err error) {
+	entry.Time = time.Now()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries[r.next] = entry
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+
+	if r.encoder != nil {
+		// Ошибка записи в файл не должна влиять на обработку запроса
+		r.encoder.Encode(&entry)
+	}
+}
+
+// Скрытие данных строки с сохранением ее размера
+func (r *TrafficRecorder) redactParams(params protocol.AdditionalParams) protocol.AdditionalParams {
+	if r.redact && len(params.Data) != 0 {
+		params.Data = fmt.Sprintf("<скрыто: %d байт>", len(params.Data))
+	}
+	return params
+}
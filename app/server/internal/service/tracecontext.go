@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/lildannita/octet-server/internal/protocol"
+)
+
+// Формат заголовка traceparent (W3C Trace Context): версия-trace_id-parent_id-флаги
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// Максимальная длина заголовка tracestate
+const maxTracestateLength = 512
+
+// Ключ контекста для контекста трассировки
+type traceContextKey struct{}
+
+// Проверка заголовков W3C Trace Context. Некорректный traceparent отбрасывается вместе с tracestate,
+// как того требует спецификация.
+func ParseTraceContext(traceparent, tracestate string) (protocol.TraceContext, bool) {
+	traceparent = strings.TrimSpace(traceparent)
+	if !traceparentPattern.MatchString(traceparent) || strings.HasPrefix(traceparent, "ff") {
+		return protocol.TraceContext{}, false
+	}
+	// Нулевые trace_id и parent_id недопустимы
+	if traceparent[3:35] == strings.Repeat("0", 32) || traceparent[36:52] == strings.Repeat("0", 16) {
+		return protocol.TraceContext{}, false
+	}
+
+	trace := protocol.TraceContext{Parent: traceparent}
+	if tracestate = strings.TrimSpace(tracestate); len(tracestate) <= maxTracestateLength {
+		trace.State = tracestate
+	}
+	return trace, true
+}
+
+// Контекст с контекстом трассировки внешнего запроса
+func WithTraceContext(ctx context.Context, trace protocol.TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// Контекст трассировки внешнего запроса (пустой, если запрос его не содержал)
+func TraceContextFrom(ctx context.Context) protocol.TraceContext {
+	trace, _ := ctx.Value(traceContextKey{}).(protocol.TraceContext)
+	return trace
+}
+
+// Передача контекста трассировки в запросе к octet
+func withTrace(ctx context.Context, req *protocol.Request) *protocol.Request {
+	if ctx != nil {
+		req.TraceContext = TraceContextFrom(ctx)
+	}
+	return req
+}
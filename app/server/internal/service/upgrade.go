@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Ошибки запуска обновления octet
+var (
+	ErrUpgradeInProgress = errors.New("обновление octet уже выполняется")
+	ErrNotManaged        = errors.New("процесс octet запущен вне сервера и не может быть обновлен")
+)
+
+// Параметры обновления octet
+type UpgradeConfig struct {
+	DrainTimeout time.Duration // Максимальное ожидание завершения выполняющихся запросов
+	VerifyPings  int           // Количество проверок связи с новым процессом
+	PingTimeout  time.Duration // Таймаут одной проверки связи
+}
+
+// Состояние обновления octet
+const (
+	UpgradeIdle       = "idle"        // Обновление не выполнялось
+	UpgradeRunning    = "running"     // Обновление выполняется
+	UpgradeCompleted  = "completed"   // Новый процесс запущен и отвечает
+	UpgradeRolledBack = "rolled_back" // Новый процесс не прошел проверку, восстановлен прежний
+	UpgradeFailed     = "failed"      // Обновление прервано до замены файла или прежний процесс восстановить не удалось
+)
+
+// Этапы обновления octet
+const (
+	UpgradeStepDrain    = "drain"    // Ожидание завершения выполняющихся запросов
+	UpgradeStepStop     = "stop"     // Остановка прежнего процесса
+	UpgradeStepSwap     = "swap"     // Замена исполняемого файла
+	UpgradeStepStart    = "start"    // Запуск нового процесса
+	UpgradeStepVerify   = "verify"   // Проверка связи с новым процессом
+	UpgradeStepRollback = "rollback" // Возврат к прежнему исполняемому файлу
+)
+
+// Состояние последнего обновления octet
+type UpgradeStatus struct {
+	State      string     `json:"state"`
+	Step       string     `json:"step,omitempty"` // Текущий или последний выполненный этап
+	From       string     `json:"from,omitempty"` // Прежний исполняемый файл
+	To         string     `json:"to,omitempty"`   // Новый исполняемый файл
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Обновление исполняемого файла octet без перезапуска сервера:
+// ожидание завершения запросов, остановка процесса, замена файла, запуск и проверка нового процесса.
+// Если новый процесс не запускается или не отвечает, восстанавливается прежний исполняемый файл.
+type Upgrader struct {
+	pool           *ClientPool
+	processManager *ProcessManager
+	config         UpgradeConfig
+	logger         *zap.Logger
+	mutex          sync.Mutex
+	status         UpgradeStatus
+}
+
+// Создание обновления octet
+func NewUpgrader(pool *ClientPool, pm *ProcessManager, config UpgradeConfig, logger *zap.Logger) (*Upgrader, error) {
+	if pool == nil || pm == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool или ProcessManager")
+	}
+	if config.DrainTimeout <= 0 || config.VerifyPings <= 0 || config.PingTimeout <= 0 {
+		return nil, fmt.Errorf("некорректные параметры обновления octet")
+	}
+	return &Upgrader{
+		pool:           pool,
+		processManager: pm,
+		config:         config,
+		logger:         logger,
+		status:         UpgradeStatus{State: UpgradeIdle},
+	}, nil
+}
+
+// Состояние последнего обновления
+func (u *Upgrader) Status() UpgradeStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.status
+}
+
+// Запуск обновления на исполняемый файл path. Обновление выполняется асинхронно,
+// его завершение отслеживается через Status.
+func (u *Upgrader) Start(path string) (UpgradeStatus, error) {
+	if !u.processManager.Managed() {
+		return UpgradeStatus{}, ErrNotManaged
+	}
+	if err := CheckOctetPath(path); err != nil {
+		return UpgradeStatus{}, err
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.status.State == UpgradeRunning {
+		return u.status, ErrUpgradeInProgress
+	}
+	now := time.Now()
+	u.status = UpgradeStatus{
+		State:     UpgradeRunning,
+		From:      u.processManager.OctetPath(),
+		To:        path,
+		StartedAt: &now,
+	}
+	go u.run(u.status.From, path)
+	return u.status, nil
+}
+
+// Выполнение обновления
+func (u *Upgrader) run(from, to string) {
+	u.logger.Warn("Обновление octet", zap.String("from", from), zap.String("to", to))
+
+	u.step(UpgradeStepDrain)
+	drained, err := u.pool.Drain(u.config.DrainTimeout)
+	if err != nil {
+		u.finish(UpgradeFailed, fmt.Errorf("не удалось дождаться завершения запросов: %w", err))
+		return
+	}
+	// Новые запросы ожидают клиентов, пока процесс заменяется
+	defer u.pool.Restore(drained)
+
+	u.step(UpgradeStepStop)
+	if u.processManager.IsRunning() {
+		if err := u.processManager.stop(ProcessReasonUpgrade); err != nil {
+			u.finish(UpgradeFailed, fmt.Errorf("не удалось остановить процесс octet: %w", err))
+			return
+		}
+	}
+
+	u.step(UpgradeStepSwap)
+	if err := u.processManager.SetOctetPath(to); err != nil {
+		u.rollback(from, drained[0], err)
+		return
+	}
+
+	u.step(UpgradeStepStart)
+	if err := u.processManager.start(ProcessReasonUpgrade); err != nil {
+		u.rollback(from, drained[0], fmt.Errorf("не удалось запустить новый процесс octet: %w", err))
+		return
+	}
+
+	u.step(UpgradeStepVerify)
+	if err := u.verify(drained[0]); err != nil {
+		u.rollback(from, drained[0], fmt.Errorf("новый процесс octet не отвечает: %w", err))
+		return
+	}
+	u.finish(UpgradeCompleted, nil)
+}
+
+// Возврат к прежнему исполняемому файлу после неудачного обновления
+func (u *Upgrader) rollback(from string, client *Client, cause error) {
+	u.logger.Error("Обновление octet не удалось, возврат к прежнему исполняемому файлу", zap.Error(cause))
+	u.step(UpgradeStepRollback)
+
+	if u.processManager.IsRunning() {
+		if err := u.processManager.stop(ProcessReasonRollback); err != nil {
+			u.finish(UpgradeFailed, fmt.Errorf("%w; не удалось остановить новый процесс: %w", cause, err))
+			return
+		}
+	}
+	if err := u.processManager.SetOctetPath(from); err != nil {
+		u.finish(UpgradeFailed, fmt.Errorf("%w; не удалось вернуть прежний исполняемый файл: %w", cause, err))
+		return
+	}
+	if err := u.processManager.start(ProcessReasonRollback); err != nil {
+		u.finish(UpgradeFailed, fmt.Errorf("%w; не удалось запустить прежний процесс: %w", cause, err))
+		return
+	}
+	if err := u.verify(client); err != nil {
+		u.finish(UpgradeFailed, fmt.Errorf("%w; прежний процесс не отвечает: %w", cause, err))
+		return
+	}
+	u.finish(UpgradeRolledBack, cause)
+}
+
+// Проверка связи с запущенным процессом на изъятом из пула клиенте
+func (u *Upgrader) verify(client *Client) error {
+	client.Close()
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	for range u.config.VerifyPings {
+		ctx, cancel := context.WithTimeout(context.Background(), u.config.PingTimeout)
+		err := client.Ping(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Переход к следующему этапу
+func (u *Upgrader) step(step string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.status.Step = step
+}
+
+// Завершение обновления
+func (u *Upgrader) finish(state string, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	now := time.Now()
+	u.status.State = state
+	u.status.FinishedAt = &now
+	if err != nil {
+		u.status.Error = err.Error()
+	}
+
+	switch state {
+	case UpgradeCompleted:
+		u.logger.Info("Обновление octet завершено", zap.String("octet", u.status.To))
+	case UpgradeRolledBack:
+		u.logger.Warn("Восстановлен прежний процесс octet", zap.String("octet", u.status.From))
+	default:
+		u.logger.Error("Обновление octet прервано", zap.String("step", u.status.Step), zap.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+)
+
+// Значение отклонено проверкой перед добавлением или изменением
+var ErrValueRejected = errors.New("значение отклонено проверкой")
+
+// Имя функции проверки в подключаемом модуле
+const validatorSymbol = "Validate"
+
+// Функция проверки значения в подключаемом модуле:
+// op - insert или update, meta - uuid (для update), namespace и identity (если известен клиент).
+// Возвращает значение для записи (исходное или преобразованное) или ошибку с причиной отклонения.
+type ValidateFunc = func(op, data string, meta map[string]string) (string, error)
+
+// Проверка значений перед добавлением и изменением подключаемым модулем Go.
+// Нулевой указатель пропускает значения без проверки.
+type Validator struct {
+	path     string
+	validate ValidateFunc
+}
+
+// Загрузка подключаемого модуля проверки значений
+func LoadValidator(path string) (*Validator, error) {
+	module, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось загрузить модуль проверки значений: %w", err)
+	}
+	symbol, err := module.Lookup(validatorSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("модуль проверки значений не содержит функцию %s: %w", validatorSymbol, err)
+	}
+	validate, ok := symbol.(ValidateFunc)
+	if !ok {
+		return nil, fmt.Errorf("функция %s модуля проверки значений имеет тип %T, ожидается %T",
+			validatorSymbol, symbol, ValidateFunc(nil))
+	}
+	return &Validator{path: path, validate: validate}, nil
+}
+
+// Проверка значения. Отклонение возвращается как ErrValueRejected с причиной,
+// сбой модуля (паника) - как внутренняя ошибка.
+func (v *Validator) Validate(op, data string, meta map[string]string) (result string, err error) {
+	if v == nil {
+		return data, nil
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			result, err = "", fmt.Errorf("сбой модуля проверки значений %s: %v", v.path, p)
+		}
+	}()
+
+	result, err = v.validate(op, data, meta)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrValueRejected, err)
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("%w: значение после преобразования пустое", ErrValueRejected)
+	}
+	return result, nil
+}
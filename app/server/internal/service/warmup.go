@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Параметры прогрева после запуска
+type WarmupConfig struct {
+	Uuids     []string      // UUID часто читаемых строк
+	UuidsFile string        // Файл с UUID часто читаемых строк (по одному в строке, # - комментарий)
+	Pings     int           // Количество проверок связи с octet
+	Timeout   time.Duration // Максимальная длительность прогрева
+}
+
+// Итоги прогрева
+type WarmupResult struct {
+	Pings      int   `json:"pings"`       // Успешные проверки связи
+	PingErrors int   `json:"ping_errors"` // Неудавшиеся проверки связи
+	Loaded     int   `json:"loaded"`      // Прочитанные строки
+	Missing    int   `json:"missing"`     // Отсутствующие в octet строки
+	LoadErrors int   `json:"load_errors"` // Строки, которые не удалось прочитать
+	DurationMs int64 `json:"duration_ms"`
+	TimedOut   bool  `json:"timed_out,omitempty"`
+}
+
+// Прогрев после запуска: проверки связи устанавливают соединения пула с octet,
+// а чтение часто запрашиваемых строк загружает их данные в кэш файловой системы.
+// До завершения прогрева сервер сообщает, что не готов принимать запросы.
+type Warmup struct {
+	pool   *ClientPool
+	config WarmupConfig
+	logger *zap.Logger
+	uuids  []string
+	done   atomic.Bool
+	result WarmupResult
+}
+
+// Создание прогрева с чтением файла UUID часто читаемых строк
+func NewWarmup(pool *ClientPool, config WarmupConfig, logger *zap.Logger) (*Warmup, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("внутренняя ошибка: передан пустой указатель на ClientPool")
+	}
+	if config.Pings < 0 || config.Timeout <= 0 {
+		return nil, fmt.Errorf("некорректные параметры прогрева")
+	}
+
+	uuids := append([]string(nil), config.Uuids...)
+	if len(config.UuidsFile) != 0 {
+		fromFile, err := readWarmupUuids(config.UuidsFile)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, fromFile...)
+	}
+	return &Warmup{pool: pool, config: config, logger: logger, uuids: uuids}, nil
+}
+
+// Завершен ли прогрев
+func (w *Warmup) Done() bool {
+	return w.done.Load()
+}
+
+// Итоги прогрева (до завершения - нулевое значение)
+func (w *Warmup) Result() WarmupResult {
+	if !w.Done() {
+		return WarmupResult{}
+	}
+	return w.result
+}
+
+// Выполнение прогрева. Ошибки не прерывают прогрев и только учитываются в итогах,
+// по истечении таймаута прогрев завершается досрочно.
+func (w *Warmup) Run(ctx context.Context) WarmupResult {
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var result WarmupResult
+	var mutex sync.Mutex
+	count := func(counter *int) {
+		mutex.Lock()
+		*counter++
+		mutex.Unlock()
+	}
+
+	// Проверки связи выполняются параллельно, чтобы установить все соединения пула
+	pings := make(chan struct{})
+	var wg sync.WaitGroup
+	for range min(w.config.Pings, w.pool.Stats().Size) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range pings {
+				client, err := w.pool.GetClient()
+				if err == nil {
+					err = client.Ping(ctx)
+				}
+				if err != nil {
+					count(&result.PingErrors)
+				} else {
+					count(&result.Pings)
+				}
+			}
+		}()
+	}
+	for range w.config.Pings {
+		pings <- struct{}{}
+	}
+	close(pings)
+	wg.Wait()
+
+	for _, uuid := range w.uuids {
+		if ctx.Err() != nil {
+			break
+		}
+		client, err := w.pool.GetClientFor(PoolClassRead)
+		if err == nil {
+			_, err = client.Get(ctx, uuid)
+		}
+		switch {
+		case err == nil:
+			result.Loaded++
+		case errors.Is(err, ErrNotFound):
+			result.Missing++
+		default:
+			result.LoadErrors++
+			w.logger.Warn("Не удалось прочитать строку при прогреве", zap.String("uuid", uuid), zap.Error(err))
+		}
+	}
+
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result.DurationMs = time.Since(start).Milliseconds()
+	w.result = result
+	w.done.Store(true)
+	return result
+}
+
+// Чтение UUID из файла: по одному в строке, пустые строки и комментарии (#) пропускаются
+func readWarmupUuids(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл UUID для прогрева: %w", err)
+	}
+	defer file.Close()
+
+	var uuids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) != 0 && !strings.HasPrefix(line, "#") {
+			uuids = append(uuids, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл UUID для прогрева: %w", err)
+	}
+	return uuids, nil
+}
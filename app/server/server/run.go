@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/config"
+	"go.uber.org/zap"
+)
+
+// Запуск HTTP серверов на адресах из конфигурации. Возвращает управление после завершения работы
+// по отмене ctx, вызову Shutdown, запросу вывода из балансировки или ошибке HTTP сервера.
+// Перед возвратом выполняется корректное завершение работы и освобождение ресурсов.
+func (s *Server) Run(ctx context.Context) error {
+	// Открываем все адреса заранее, чтобы не запускаться частично
+	listeners := make([]net.Listener, 0, len(s.cfg.HTTPAddr))
+	for _, addr := range s.cfg.HTTPAddr {
+		listener, err := listen(addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			s.Shutdown(context.Background())
+			return fmt.Errorf("не удалось открыть адрес HTTP сервера %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	// Запуск HTTP сервера на каждом адресе в отдельной горутине с общим обработчиком
+	serveErr := make(chan error, len(listeners))
+	s.mutex.Lock()
+	if s.stopped {
+		s.mutex.Unlock()
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return http.ErrServerClosed
+	}
+	for i, listener := range listeners {
+		server := &http.Server{
+			Handler:      s.handler,
+			ReadTimeout:  60 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		}
+		s.servers = append(s.servers, server)
+
+		addr := s.cfg.HTTPAddr[i]
+		go func() {
+			s.logger.Info("Запуск HTTP сервера", zap.String("addr", addr))
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				serveErr <- fmt.Errorf("ошибка HTTP сервера %s: %w", addr, err)
+			}
+		}()
+	}
+	s.mutex.Unlock()
+
+	// Прогрев выполняется после открытия адресов, до его завершения /ready возвращает 503
+	if s.warmup != nil {
+		go func() {
+			result := s.warmup.Run(context.Background())
+			s.logger.Info("Прогрев завершен", zap.Int("pings", result.Pings), zap.Int("ping_errors", result.PingErrors),
+				zap.Int("loaded", result.Loaded), zap.Int("missing", result.Missing), zap.Int("load_errors", result.LoadErrors),
+				zap.Int64("duration_ms", result.DurationMs), zap.Bool("timed_out", result.TimedOut))
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-s.stopping:
+	case <-s.drained:
+		s.logger.Info("Завершение работы после вывода из балансировки")
+	case err = <-serveErr:
+	}
+	if shutdownErr := s.Shutdown(context.Background()); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// Корректное завершение работы: обычные запросы ожидаются timeout_seconds,
+// длительные потоки - stream_grace_seconds до их остановки, но не дольше истечения ctx.
+// Затем освобождаются ресурсы и останавливается процесс octet. Повторные вызовы
+// ожидают завершения первого.
+func (s *Server) Shutdown(ctx context.Context) error {
+	first := false
+	s.stopOnce.Do(func() { first = true })
+	if !first {
+		select {
+		case <-s.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer close(s.done)
+
+	s.mutex.Lock()
+	s.stopped = true
+	servers := s.servers
+	s.mutex.Unlock()
+	close(s.stopping)
+
+	if active := s.streams.Active(); active > 0 {
+		s.logger.Info("Ожидание завершения длительных потоков", zap.Int64("streams", active),
+			zap.Int("stream_grace_seconds", s.cfg.Shutdown.StreamGraceSeconds))
+	}
+	shutdownCtx, cancel := s.streams.ShutdownContext(s.cfg.Shutdown)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	// Завершаем все серверы одновременно в рамках общего таймаута
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				s.logger.Error("Ошибка при корректном завершении HTTP сервера",
+					zap.String("addr", s.cfg.HTTPAddr[i]), zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.release()
+	s.logger.Info("Сервер успешно завершил работу")
+	return ctx.Err()
+}
+
+// Открытие адреса HTTP сервера: TCP (host:port) или UNIX domain socket (unix:/path/to.sock)
+func listen(addr string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(addr, config.UnixAddrPrefix)
+	if !isUnix {
+		return net.Listen("tcp", addr)
+	}
+
+	// Удаляем оставшийся от предыдущего запуска файл сокета
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("не удалось удалить существующий файл сокета: %w", err)
+	}
+	return net.Listen("unix", path)
+}
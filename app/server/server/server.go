@@ -0,0 +1,720 @@
+// Package server - HTTP сервер octet, который можно встроить в другую программу на Go
+// вместо запуска исполняемого файла octet-server.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lildannita/octet-server/internal/api"
+	"github.com/lildannita/octet-server/internal/auth"
+	"github.com/lildannita/octet-server/internal/config"
+	"github.com/lildannita/octet-server/internal/events"
+	"github.com/lildannita/octet-server/internal/metastore"
+	"github.com/lildannita/octet-server/internal/service"
+	"go.uber.org/zap"
+)
+
+// Конфигурация сервера
+type Config = config.Config
+
+// Событие изменения состояния соединений с octet или процесса octet
+type StateEvent = service.StateEvent
+
+// Аутентификация клиентов: возвращает клиента запроса или ошибку
+type Authenticator = auth.Authenticator
+
+// Клиент, прошедший аутентификацию
+type Identity = auth.Identity
+
+// Область доступа клиента
+type Scope = auth.Scope
+
+// Области доступа
+const (
+	ScopeRead  = auth.ScopeRead
+	ScopeWrite = auth.ScopeWrite
+	ScopeAdmin = auth.ScopeAdmin
+)
+
+// Вывод дополнительных метрик в формате Prometheus
+type MetricsWriter = api.MetricsWriter
+
+// Загрузка конфигурации из файла (пустой путь - конфигурация по умолчанию)
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// Параметр создания сервера
+type Option func(*options)
+
+type options struct {
+	logger        *zap.Logger
+	authenticator Authenticator
+	router        []api.RouterOption
+}
+
+// Логгер сервера (по умолчанию - глобальный логгер zap)
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Аутентификация клиентов вместо настроенной в конфигурации (auth)
+func WithAuth(authenticator Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+		o.router = append(o.router, api.WithAuth(authenticator))
+	}
+}
+
+// Дополнительные метрики, выводимые в /admin/v1/metrics после метрик сервера
+func WithMetrics(writers ...MetricsWriter) Option {
+	return func(o *options) {
+		o.router = append(o.router, api.WithMetrics(writers...))
+	}
+}
+
+// Слои, через которые проходят все запросы после базовых слоев сервера
+// (идентификатор запроса, восстановление после паники, логирование).
+// Первый слой получает запрос первым.
+func WithMiddleware(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.router = append(o.router, api.WithMiddleware(middlewares...))
+	}
+}
+
+// Базовый путь API работы со строками вместо /octet (например, /storage)
+func WithBasePath(path string) Option {
+	return func(o *options) {
+		o.router = append(o.router, api.WithBasePath(path))
+	}
+}
+
+// HTTP сервер octet вместе с процессом octet и пулом соединений с ним
+type Server struct {
+	cfg         *config.Config
+	logger      *zap.Logger
+	handler     http.Handler
+	clientPool  *service.ClientPool
+	stateEvents *service.StateEvents
+	streams     *api.Streams
+	warmup      *service.Warmup
+
+	// Запрос завершения работы после вывода из балансировки
+	drained chan struct{}
+	// Освобождение ресурсов в обратном порядке создания
+	closers []func()
+
+	mutex    sync.Mutex
+	servers  []*http.Server
+	stopped  bool
+	stopOnce sync.Once
+	stopping chan struct{} // Закрывается в начале завершения работы
+	done     chan struct{} // Закрывается после завершения работы
+}
+
+// Создание сервера: запуск процесса octet и подготовка пула соединений и обработчиков запросов.
+// HTTP адреса открываются в Run. При ошибке уже созданные ресурсы освобождаются.
+func New(cfg *Config, opts ...Option) (*Server, error) {
+	o := options{logger: zap.L()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	logger := o.logger
+
+	s := &Server{
+		cfg:         cfg,
+		logger:      logger,
+		stateEvents: service.NewStateEvents(),
+		streams:     api.NewStreams(),
+		drained:     make(chan struct{}, 1),
+		stopping:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	var err error
+	defer func() {
+		if err != nil {
+			s.release()
+		}
+	}()
+
+	// Сведения о сервере и окружении одной записью, чтобы логи были самодостаточными
+	serverInfo := api.CollectServerInfo(cfg)
+	logger.Info("Параметры запуска сервера",
+		zap.Any("server", serverInfo.Server),
+		zap.Any("octet", serverInfo.Octet),
+		zap.Any("config", serverInfo.Config))
+
+	// Настройка аутентификации клиентов (если она не передана параметром)
+	var authenticator auth.Authenticator
+	var keyStore *auth.KeyStore
+	switch {
+	case o.authenticator != nil:
+		authenticator = o.authenticator
+	case cfg.Auth.Mode == config.AuthModeAPIKey:
+		keyStore, err = auth.NewKeyStore(auth.KeysFromConfig(cfg.Auth.APIKeys))
+		if err != nil {
+			return nil, fmt.Errorf("некорректные параметры API-ключей: %w", err)
+		}
+		if len(cfg.Auth.KeysFile) != 0 {
+			if err = keyStore.Open(cfg.Auth.KeysFile); err != nil {
+				return nil, fmt.Errorf("не удалось загрузить выпущенные API-ключи: %w", err)
+			}
+		}
+		authenticator = keyStore
+	case cfg.Auth.Mode == config.AuthModeOIDC:
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		authenticator, err = auth.NewOIDCAuthenticator(ctx, cfg.Auth.OIDC)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось настроить аутентификацию OpenID Connect: %w", err)
+		}
+		logger.Info("Включена аутентификация OpenID Connect", zap.String("issuer", cfg.Auth.OIDC.Issuer))
+	}
+
+	// История запусков и завершений процесса octet
+	var processHistory *service.ProcessHistory
+	if cfg.ProcessHistory.Enabled {
+		processHistory, err = service.OpenProcessHistory(cfg.ProcessHistory.File, cfg.ProcessHistory.MaxRecords)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть историю процесса octet: %w", err)
+		}
+		s.onClose(func() { processHistory.Close() })
+	}
+
+	// Создание и запуск процесса octet
+	procManager := service.NewProcessManager(cfg)
+	procManager.SetStateEvents(s.stateEvents)
+	procManager.SetHistory(processHistory)
+	if err = procManager.Start(); err != nil {
+		return nil, fmt.Errorf("не удалось запустить процесс octet: %w", err)
+	}
+	s.onClose(func() { procManager.Stop() })
+
+	// Запись обмена сообщениями с octet
+	var recorder *service.TrafficRecorder
+	if cfg.Trace.Enabled {
+		recorder, err = service.NewTrafficRecorder(cfg.Trace.BufferSize, cfg.Trace.File, cfg.Trace.RedactPayload)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось включить запись обмена сообщениями с octet: %w", err)
+		}
+		s.onClose(func() { recorder.Close() })
+		logger.Warn("Включена запись обмена сообщениями с octet",
+			zap.Bool("redact_payload", cfg.Trace.RedactPayload), zap.String("file", cfg.Trace.File))
+	}
+
+	// Внедрение сбоев для проверки устойчивости
+	var faults *service.FaultInjector
+	if cfg.Faults.Enabled {
+		faults, err = service.NewFaultInjector(service.FaultSettings{
+			LatencyMs:   cfg.Faults.LatencyMs,
+			LatencyRate: cfg.Faults.LatencyRate,
+			DropRate:    cfg.Faults.DropRate,
+			ResetRate:   cfg.Faults.ResetRate,
+		}, procManager)
+		if err != nil {
+			return nil, fmt.Errorf("некорректные параметры внедрения сбоев: %w", err)
+		}
+		logger.Warn("Включен режим внедрения сбоев, не используйте его в рабочей среде")
+	}
+
+	// Журнал намерений изменений данных
+	var intents *service.IntentLog
+	if cfg.IntentLog.Enabled {
+		intents, err = service.OpenIntentLog(cfg.IntentLog.File)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть журнал намерений: %w", err)
+		}
+		s.onClose(func() { intents.Close() })
+	}
+
+	// Права доступа к сокету, проверяемые перед подключением
+	socketPerms, err := service.NewSocketPermissions(cfg.SocketPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("некорректные права доступа к сокету: %w", err)
+	}
+
+	// Таймаут чтения по длительности последних выполнений команды
+	var adaptiveTimeout *service.AdaptiveTimeout
+	if cfg.AdaptiveTimeout.Enabled {
+		adaptiveTimeout = &service.AdaptiveTimeout{
+			Percentile: cfg.AdaptiveTimeout.Percentile,
+			Factor:     cfg.AdaptiveTimeout.Factor,
+			Min:        time.Duration(cfg.AdaptiveTimeout.MinMs) * time.Millisecond,
+			Max:        time.Duration(cfg.AdaptiveTimeout.MaxMs) * time.Millisecond,
+			MinSamples: cfg.AdaptiveTimeout.MinSamples,
+		}
+	}
+
+	// Повторные попытки подключения к octet, еще не принимающему соединения
+	var dialRetry *service.DialRetry
+	if cfg.DialRetry.Attempts > 0 {
+		dialRetry = &service.DialRetry{
+			Attempts: cfg.DialRetry.Attempts,
+			Initial:  time.Duration(cfg.DialRetry.InitialDelayMs) * time.Millisecond,
+			Max:      time.Duration(cfg.DialRetry.MaxDelayMs) * time.Millisecond,
+		}
+	}
+
+	// Доли пула для классов запросов (0 - без ограничения)
+	var readShare, writeShare float64
+	if cfg.PoolPartitions.Enabled {
+		readShare, writeShare = cfg.PoolPartitions.ReadShare, cfg.PoolPartitions.WriteShare
+	}
+
+	// Ограничение количества строк в хранилище
+	var keyLimit *service.KeyLimit
+	if cfg.KeyLimit.MaxKeys != 0 {
+		keyLimit, err = service.NewKeyLimit(cfg.KeyLimit.MaxKeys, time.Duration(cfg.KeyLimit.RefreshSeconds)*time.Second, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить ограничение количества строк: %w", err)
+		}
+	}
+
+	// Создание клиентского пула соединений
+	clientPool, err := service.NewClientPool(service.ClientPoolConfig{
+		SocketPath:        cfg.SocketPath,
+		MaxClients:        cfg.MaxClients,
+		ConnTimeout:       5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		ClientTimeout:     30 * time.Second,
+		MaxFrameSize:      cfg.MaxFrameSize,
+		HeartbeatInterval: time.Duration(cfg.HeartbeatInterval) * time.Second,
+		VerifyChecksum:    cfg.VerifyChecksum,
+		RequestIDv7:       cfg.RequestID.UUIDv7(),
+		Recorder:          recorder,
+		Faults:            faults,
+		Intents:           intents,
+		SocketPermissions: socketPerms,
+		VerifyPeer:        cfg.SocketPermissions.VerifyPeer,
+		AdaptiveTimeout:   adaptiveTimeout,
+		DialRetry:         dialRetry,
+		ReadShare:         readShare,
+		WriteShare:        writeShare,
+		KeyLimit:          keyLimit,
+		Events:            s.stateEvents,
+
+		HeartbeatConcurrency: cfg.HeartbeatConcurrency,
+		HeartbeatJitter:      cfg.HeartbeatJitter,
+	}, logger, procManager)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать пул клиентов: %w", err)
+	}
+	s.clientPool = clientPool
+	s.onClose(clientPool.Close)
+	if keyLimit != nil {
+		keyLimit.Start(clientPool)
+		s.onClose(keyLimit.Stop)
+		logger.Info("Включено ограничение количества строк", zap.Uint64("max_keys", cfg.KeyLimit.MaxKeys))
+	}
+
+	// Сверка изменений, результат которых неизвестен после аварийного завершения
+	if intents != nil && intents.Recovered() != 0 {
+		reconcileIntents(logger, intents, clientPool, cfg.IntentLog.Repair)
+	}
+
+	// Оценка состояния octet и перезапуск при длительном нарушении порогов
+	var healthMonitor *service.HealthMonitor
+	if cfg.HealthPolicy.Enabled {
+		healthMonitor, err = service.NewHealthMonitor(clientPool, procManager, service.HealthPolicy{
+			Interval:     time.Duration(cfg.HealthPolicy.IntervalSeconds) * time.Second,
+			Window:       time.Duration(cfg.HealthPolicy.WindowSeconds) * time.Second,
+			MinCommands:  uint64(cfg.HealthPolicy.MinCommands),
+			MaxErrorRate: cfg.HealthPolicy.MaxErrorRate,
+			MaxLatency:   time.Duration(cfg.HealthPolicy.MaxLatencyMs) * time.Millisecond,
+			Sustain:      time.Duration(cfg.HealthPolicy.SustainSeconds) * time.Second,
+			Cooldown:     time.Duration(cfg.HealthPolicy.CooldownSeconds) * time.Second,
+			AutoRestart:  cfg.HealthPolicy.AutoRestart,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось запустить оценку состояния octet: %w", err)
+		}
+		healthMonitor.Start()
+		s.onClose(healthMonitor.Stop)
+		logger.Info("Включена оценка состояния octet", zap.Bool("auto_restart", cfg.HealthPolicy.AutoRestart))
+	}
+
+	// Отслеживание удаления и замены файла сокета octet
+	var socketWatcher *service.SocketWatcher
+	if cfg.SocketWatch.Enabled {
+		socketWatcher, err = service.NewSocketWatcher(clientPool, procManager, cfg.SocketPath,
+			cfg.HealthPolicy.Enabled && cfg.HealthPolicy.AutoRestart, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить отслеживание файла сокета: %w", err)
+		}
+		if watchErr := socketWatcher.Start(); watchErr != nil {
+			// Без отслеживания сервер работает как прежде
+			logger.Warn("Отслеживание файла сокета недоступно", zap.Error(watchErr))
+			socketWatcher = nil
+		} else {
+			s.onClose(socketWatcher.Stop)
+		}
+	}
+
+	// Объединение одиночных изменений в пакеты
+	var coalescer *service.WriteCoalescer
+	if cfg.Coalesce.Enabled {
+		coalescer, err = service.NewWriteCoalescer(clientPool, service.CoalesceConfig{
+			Window:   time.Duration(cfg.Coalesce.WindowMs) * time.Millisecond,
+			MaxOps:   cfg.Coalesce.MaxOps,
+			MaxBytes: cfg.Coalesce.MaxBytes,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось запустить объединение изменений: %w", err)
+		}
+		s.onClose(coalescer.Close)
+		logger.Info("Включено объединение изменений в пакеты",
+			zap.Int("window_ms", cfg.Coalesce.WindowMs), zap.Int("max_ops", cfg.Coalesce.MaxOps))
+	}
+
+	// Запись и доставка событий изменения данных
+	var outbox *events.Outbox
+	var webhooks []*events.Webhook
+	if cfg.Events.Enabled {
+		outbox, err = events.OpenOutbox(cfg.Events.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть хранилище событий: %w", err)
+		}
+		s.onClose(func() { outbox.Close() })
+		for _, webhookCfg := range cfg.Events.Webhooks {
+			var webhook *events.Webhook
+			webhook, err = events.NewWebhook(events.WebhookConfig{
+				Name:        webhookCfg.Name,
+				URL:         webhookCfg.URL,
+				Secret:      webhookCfg.Secret,
+				Workers:     webhookCfg.Workers,
+				MaxAttempts: webhookCfg.MaxAttempts,
+				Timeout:     time.Duration(webhookCfg.Timeout) * time.Second,
+
+				RetryInitial: time.Duration(webhookCfg.RetryInitialMs) * time.Millisecond,
+				RetryMax:     time.Duration(webhookCfg.RetryMaxMs) * time.Millisecond,
+			}, outbox, logger)
+			if err != nil {
+				return nil, fmt.Errorf("не удалось создать подписчика на события: %w", err)
+			}
+			webhook.Start()
+			s.onClose(webhook.Stop)
+			webhooks = append(webhooks, webhook)
+		}
+		logger.Info("Включена запись событий изменения данных",
+			zap.String("dir", cfg.Events.Dir), zap.Int("webhooks", len(webhooks)))
+
+		if cfg.Events.RetentionHours > 0 {
+			retentionDone := make(chan struct{})
+			s.onClose(func() { close(retentionDone) })
+			go events.RunRetention(outbox, webhooks,
+				time.Duration(cfg.Events.RetentionHours)*time.Hour, logger, retentionDone)
+		}
+	}
+
+	// Журнал запросов
+	var requestJournal *api.RequestJournal
+	if cfg.RequestJournal.Enabled {
+		requestJournal, err = api.OpenRequestJournal(cfg.RequestJournal.File, cfg.RequestJournal.MaxRecords)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть журнал запросов: %w", err)
+		}
+		s.onClose(func() { requestJournal.Close() })
+		logger.Info("Включен журнал запросов", zap.String("file", cfg.RequestJournal.File),
+			zap.Int("max_records", cfg.RequestJournal.MaxRecords))
+	}
+
+	// Асинхронное удаление с метками удаления
+	var tombstones *service.Tombstones
+	if cfg.AsyncDelete.Enabled {
+		tombstones, err = service.OpenTombstones(clientPool, service.TombstonesConfig{
+			File:          cfg.AsyncDelete.File,
+			RetryInterval: time.Duration(cfg.AsyncDelete.RetryIntervalMs) * time.Millisecond,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть файл меток удаления: %w", err)
+		}
+		s.onClose(func() { tombstones.Close() })
+		logger.Info("Включено асинхронное удаление", zap.String("file", cfg.AsyncDelete.File),
+			zap.Int("pending", tombstones.Pending()))
+	}
+
+	// Упорядоченная запись
+	var ordered *service.OrderedWriter
+	if len(cfg.OrderedWrites.Namespaces) != 0 {
+		ordered, err = service.OpenOrderedWriter(clientPool, service.OrderedWriterConfig{
+			SequenceFile: cfg.OrderedWrites.SequenceFile,
+			Sync:         cfg.OrderedWrites.Sync,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось запустить упорядоченную запись: %w", err)
+		}
+		s.onClose(func() { ordered.Close() })
+		logger.Info("Включена упорядоченная запись", zap.Strings("namespaces", cfg.OrderedWrites.Namespaces),
+			zap.Uint64("sequence", ordered.Sequence()))
+	}
+
+	// Удаление строк по политикам хранения
+	var retention *service.Retention
+	if len(cfg.Retention.Policies) != 0 {
+		policies := make([]service.RetentionPolicy, 0, len(cfg.Retention.Policies))
+		for _, policy := range cfg.Retention.Policies {
+			policies = append(policies, service.RetentionPolicy{
+				Namespace: policy.Namespace,
+				MaxAge:    time.Duration(policy.MaxAgeDays) * 24 * time.Hour,
+				BasedOn:   service.RetentionBasis(policy.BasedOn),
+			})
+		}
+		retentionCfg := service.RetentionConfig{
+			DryRun:   cfg.Retention.DryRun,
+			Policies: policies,
+		}
+		if cfg.Retention.Enabled {
+			retentionCfg.Interval = time.Duration(cfg.Retention.IntervalMinutes) * time.Minute
+		}
+		if outbox != nil {
+			retentionCfg.OnRemove = func(ctx context.Context, namespace, uuid string) {
+				if _, err := outbox.Publish(events.OpRemove, uuid, service.TraceContextFrom(ctx)); err != nil {
+					logger.Warn("Не удалось записать событие удаления строки по политике хранения",
+						zap.String("uuid", uuid), zap.Error(err))
+				}
+			}
+		}
+		retention, err = service.NewRetention(clientPool, retentionCfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить удаление строк по политикам хранения: %w", err)
+		}
+		retention.Start()
+		s.onClose(retention.Stop)
+		if cfg.Retention.Enabled {
+			logger.Info("Включено удаление строк по политикам хранения",
+				zap.Int("policies", len(policies)), zap.Bool("dry_run", cfg.Retention.DryRun))
+		}
+	}
+
+	// Окончательное удаление строк
+	var purger *service.Purger
+	if cfg.Purge.Enabled {
+		purger, err = service.NewPurger(clientPool, recorder, service.PurgeConfig{
+			SigningKey:     cfg.Purge.SigningKey,
+			MaxUuids:       cfg.Purge.MaxUuids,
+			CompactTimeout: time.Duration(cfg.Purge.CompactTimeoutSeconds) * time.Second,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить окончательное удаление строк: %w", err)
+		}
+	}
+
+	// Хранилище метаданных: сохраненные ответы на повторные запросы и результаты добавлений
+	// с ключом идемпотентности переживают перезапуск
+	var metadataStore metastore.Store
+	if cfg.Dedup.Enabled || cfg.Idempotency.Enabled {
+		metadataStore, err = openMetadataStore(cfg.MetadataStore, logger)
+		if err != nil {
+			return nil, err
+		}
+		if metadataStore != nil {
+			s.onClose(func() {
+				if err := metadataStore.Close(); err != nil {
+					logger.Warn("Не удалось закрыть хранилище метаданных", zap.Error(err))
+				}
+			})
+		}
+	}
+
+	// Прогрев после запуска
+	if cfg.Warmup.Enabled {
+		s.warmup, err = service.NewWarmup(clientPool, service.WarmupConfig{
+			Uuids:     cfg.Warmup.Uuids,
+			UuidsFile: cfg.Warmup.UuidsFile,
+			Pings:     cfg.Warmup.Pings,
+			Timeout:   time.Duration(cfg.Warmup.TimeoutSeconds) * time.Second,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить прогрев: %w", err)
+		}
+	}
+
+	// Обновление octet через административный API
+	var upgrader *service.Upgrader
+	if cfg.Upgrade.Enabled {
+		upgrader, err = service.NewUpgrader(clientPool, procManager, service.UpgradeConfig{
+			DrainTimeout: time.Duration(cfg.Upgrade.DrainTimeoutSeconds) * time.Second,
+			VerifyPings:  cfg.Upgrade.VerifyPings,
+			PingTimeout:  time.Duration(cfg.Upgrade.PingTimeoutMs) * time.Millisecond,
+		}, logger)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить обновление octet: %w", err)
+		}
+	}
+
+	// Перехватчики запросов и ответов, встроенные в сборку
+	interceptors, err := api.NewInterceptors(cfg.Interceptors)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подготовить перехватчики: %w", err)
+	}
+
+	// Проверка значений подключаемым модулем
+	var validator *service.Validator
+	if len(cfg.Validation.Plugin) != 0 {
+		validator, err = service.LoadValidator(cfg.Validation.Plugin)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подготовить проверку значений: %w", err)
+		}
+		logger.Info("Включена проверка значений", zap.String("plugin", cfg.Validation.Plugin))
+	}
+
+	// Создание REST API сервера
+	routerOptions := o.router
+	if authenticator != nil && o.authenticator == nil {
+		routerOptions = append([]api.RouterOption{api.WithAuth(authenticator)}, routerOptions...)
+	}
+	s.handler, err = api.NewRouter(api.RouterConfig{
+		ClientPool:    clientPool,
+		Logger:        logger,
+		Compression:   cfg.Compression,
+		JSON:          cfg.JSON,
+		KeyStore:      keyStore,
+		Cache:         cfg.Cache,
+		RequestLimits: cfg.RequestLimits,
+		TimeTravel:    cfg.TimeTravel,
+		Bandwidth:     cfg.Bandwidth,
+		Batch:         cfg.Batch,
+		SoftLimits:    cfg.SoftLimits,
+		Namespaces:    cfg.Namespaces,
+		APIVersion:    cfg.APIVersion,
+		Coalescer:     coalescer,
+		Health:        healthMonitor,
+		Recorder:      recorder,
+		Faults:        faults,
+		Info:          serverInfo,
+		Outbox:        outbox,
+		Webhooks:      webhooks,
+
+		RequestJournal:  requestJournal,
+		Tombstones:      tombstones,
+		Warmup:          s.warmup,
+		Upgrader:        upgrader,
+		Drain:           cfg.Drain,
+		Interceptors:    interceptors,
+		Validator:       validator,
+		SocketWatcher:   socketWatcher,
+		Streams:         s.streams,
+		RequestID:       cfg.RequestID,
+		Dedup:           cfg.Dedup,
+		Idempotency:     cfg.Idempotency,
+		KeyLimit:        keyLimit,
+		StateEvents:     s.stateEvents,
+		ProcessHistory:  processHistory,
+		PathPrefix:      cfg.PathPrefix,
+		HealthCheck:     cfg.HealthCheck,
+		EffectiveConfig: cfg,
+		Ordered:         ordered,
+		OrderedWrites:   cfg.OrderedWrites,
+		Retention:       retention,
+		Binary:          cfg.Binary,
+		Purger:          purger,
+		MetadataStore:   metadataStore,
+		Shutdown: func() {
+			select {
+			case s.drained <- struct{}{}:
+			default:
+			}
+		},
+	}, routerOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать обработчики запросов: %w", err)
+	}
+	return s, nil
+}
+
+// Обработчик всех запросов сервера, например для подключения к собственному http.Server
+// вместо открытия адресов из конфигурации в Run
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Пул соединений с octet
+func (s *Server) ClientPool() *service.ClientPool {
+	return s.clientPool
+}
+
+// Подписки на события изменения состояния соединений и процесса octet
+func (s *Server) StateEvents() *service.StateEvents {
+	return s.stateEvents
+}
+
+// Регистрация освобождения ресурса при завершении работы
+func (s *Server) onClose(closer func()) {
+	s.closers = append(s.closers, closer)
+}
+
+// Открытие хранилища метаданных (nil - метаданные хранятся только в памяти)
+func openMetadataStore(cfg config.MetadataStoreConfig, logger *zap.Logger) (metastore.Store, error) {
+	switch cfg.Backend {
+	case config.MetadataStoreBolt:
+		store, err := metastore.OpenBolt(cfg.File, logger)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case config.MetadataStoreRedis:
+		timeout := time.Duration(cfg.Redis.TimeoutMs) * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		store, err := metastore.OpenRedis(ctx, metastore.RedisConfig{
+			Address:  cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Redis.Prefix,
+			Timeout:  timeout,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Освобождение ресурсов в обратном порядке создания
+func (s *Server) release() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.closers = nil
+}
+
+// Сверка незавершенных намерений предыдущего запуска с octet и вывод результатов в лог
+func reconcileIntents(logger *zap.Logger, intents *service.IntentLog, pool *service.ClientPool, repair bool) {
+	logger.Warn("Найдены незавершенные изменения предыдущего запуска, выполняется сверка с octet",
+		zap.Int("count", intents.Recovered()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	reports, err := intents.Reconcile(ctx, pool, repair)
+	for _, report := range reports {
+		fields := []zap.Field{
+			zap.Uint64("id", report.ID),
+			zap.Time("time", report.Time),
+			zap.String("op", string(report.Op)),
+			zap.String("uuid", report.Uuid),
+			zap.String("status", string(report.Status)),
+		}
+		if len(report.Detail) != 0 {
+			fields = append(fields, zap.String("detail", report.Detail))
+		}
+		switch report.Status {
+		case service.IntentApplied, service.IntentRepaired, service.IntentSuperseded:
+			logger.Info("Сверка изменения", fields...)
+		default:
+			logger.Warn("Расхождение при сверке изменения", fields...)
+		}
+	}
+	if err != nil {
+		logger.Error("Сверка изменений не завершена, оставшиеся изменения будут сверены при следующем запуске",
+			zap.Error(err))
+	}
+}
@@ -16,7 +16,8 @@ enum class OperationType : uint8_t {
     INSERT, // Добавление новой строки
     UPDATE, // Обновление существующей строки
     REMOVE, // Удаление строки
-    CHECKPOINT // Контрольная точка (снимок состояния)
+    CHECKPOINT, // Контрольная точка (снимок состояния)
+    EXPIRE // Срок хранения строки (данные - момент истечения в формате ISO 8601)
 };
 
 /**
@@ -74,6 +75,15 @@ struct JournalEntry {
         return data_;
     }
 
+    /**
+     * @brief Геттер для временной метки операции
+     * @return Временная метка в формате ISO 8601 (UTC, с миллисекундами)
+     */
+    std::string timestamp() const
+    {
+        return timestamp_;
+    }
+
 private:
     OperationType type_; // Тип операции
     std::string uuid_; // Идентификатор строки
@@ -81,6 +91,17 @@ private:
     std::string timestamp_; // Временная метка операции
 };
 
+/**
+ * @struct EntryMeta
+ * @brief Метаданные строки хранилища
+ */
+struct EntryMeta {
+    std::string createdAt; // Временная метка добавления (ISO 8601)
+    std::string modifiedAt; // Временная метка последнего изменения (ISO 8601)
+    uint64_t version = 0; // Версия строки: 1 после добавления, увеличивается при каждом обновлении
+    std::string expiresAt; // Момент истечения срока хранения (ISO 8601, пустая строка - без ограничения)
+};
+
 /**
  * @class JournalManager
  * @brief Управляет журналом операций и обеспечивает восстановление данных.
@@ -108,26 +129,31 @@ public:
      * @param opType Тип операции
      * @param uuid Идентификатор строки
      * @param data Данные операции (для INSERT и UPDATE)
+     * @param[out] timestamp Временная метка записанной операции (опционально)
      * @return true если запись выполнена успешно
      */
     bool writeOperation(OperationType opType, const std::string &uuid,
-                        const std::string &data = "");
+                        const std::string &data = "", std::string *timestamp = nullptr);
 
     /**
      * @brief Записывает операцию INSERT в журнал
      * @param uuid Идентификатор строки
      * @param data Данные операции
+     * @param[out] timestamp Временная метка записанной операции (опционально)
      * @return true если запись выполнена успешно
      */
-    bool writeInsert(const std::string &uuid, const std::string &data = "");
+    bool writeInsert(const std::string &uuid, const std::string &data = "",
+                     std::string *timestamp = nullptr);
 
     /**
      * @brief Записывает операцию UPDATE в журнал
      * @param uuid Идентификатор строки
      * @param data Данные операции
+     * @param[out] timestamp Временная метка записанной операции (опционально)
      * @return true если запись выполнена успешно
      */
-    bool writeUpdate(const std::string &uuid, const std::string &data = "");
+    bool writeUpdate(const std::string &uuid, const std::string &data = "",
+                     std::string *timestamp = nullptr);
 
     /**
      * @brief Записывает операцию REMOVE в журнал
@@ -136,6 +162,14 @@ public:
      */
     bool writeRemove(const std::string &uuid);
 
+    /**
+     * @brief Записывает операцию EXPIRE в журнал
+     * @param uuid Идентификатор строки
+     * @param expiresAt Момент истечения срока хранения строки (ISO 8601)
+     * @return true если запись выполнена успешно
+     */
+    bool writeExpire(const std::string &uuid, const std::string &expiresAt);
+
     /**
      * @brief Создаёт запись контрольной точки в журнале
      * @param snapshotId Идентификатор снимка состояния
@@ -147,10 +181,12 @@ public:
      * @brief Воспроизводит операции из журнала для восстановления данных
      * @param dataStore Ссылка на хранилище данных для восстановления
      * @param lastCheckpoint Идентификатор последней контрольной точки (опционально)
+     * @param metaStore Метаданные строк для восстановления (опционально)
      * @return true если восстановление выполнено успешно
      */
     bool replayJournal(std::unordered_map<std::string, std::string> &dataStore,
-                       const std::optional<std::string> &lastCheckpoint = std::nullopt);
+                       const std::optional<std::string> &lastCheckpoint = std::nullopt,
+                       std::unordered_map<std::string, EntryMeta> *metaStore = nullptr);
 
     /**
      * @brief Получает последний идентификатор контрольной точки из журнала
@@ -173,6 +209,19 @@ public:
      */
     std::optional<size_t> countOperationsSinceLastCheckpoint() const;
 
+    /**
+     * @brief Считывает записи журнала, выполненные начиная с указанного момента
+     * @param since Временная метка в формате ISO 8601 (пустая строка - с начала журнала)
+     * @param skip Количество пропускаемых первых подходящих записей
+     * @param limit Максимальное количество записей
+     * @param maxBytes Максимальный суммарный размер данных записей (не менее одной записи)
+     * @param[out] entries Записи журнала
+     * @param[out] more Остались ли в журнале записи, не вошедшие в результат
+     * @return true если считывание выполнено успешно
+     */
+    bool readEntriesSince(const std::string &since, size_t skip, size_t limit, size_t maxBytes,
+                          std::vector<JournalEntry> &entries, bool &more) const;
+
     /**
      * @brief Проверяет, существует ли файл журнала и корректен ли его формат
      * @return true если журнал существует и корректен
@@ -194,19 +243,22 @@ private:
      * @param opType Тип операции
      * @param uuid Идентификатор строки
      * @param data Данные операции (для INSERT и UPDATE)
+     * @param[out] timestamp Временная метка записанной операции (опционально)
      * @return true если запись выполнена успешно
      */
     bool do_writeOperation(OperationType opType, const std::string &uuid,
-                           const std::string &data = "");
+                           const std::string &data = "", std::string *timestamp = nullptr);
 
     /**
      * @brief Применяет операцию к хранилищу данных
      * @param entry Запись журнала с операцией
      * @param dataStore Хранилище данных для применения операции
+     * @param metaStore Метаданные строк для обновления (опционально)
      * @return true если операция применена успешно
      */
     bool applyOperation(const JournalEntry &entry,
-                        std::unordered_map<std::string, std::string> &dataStore) const;
+                        std::unordered_map<std::string, std::string> &dataStore,
+                        std::unordered_map<std::string, EntryMeta> *metaStore) const;
 
     /**
      * @brief Считывает все записи из журнала
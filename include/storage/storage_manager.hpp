@@ -5,15 +5,42 @@
 #include <condition_variable>
 #include <filesystem>
 #include <optional>
+#include <set>
 #include <shared_mutex>
 #include <string>
 #include <thread>
 #include <unordered_map>
+#include <vector>
 
 #include "journal_manager.hpp"
 #include "uuid_generator.hpp"
 
 namespace octet {
+/**
+ * @enum CompactionState
+ * @brief Состояние сжатия хранилища
+ */
+enum class CompactionState { IDLE, RUNNING, COMPLETED, FAILED };
+
+/**
+ * @struct CompactionStatus
+ * @brief Состояние и результат последнего сжатия хранилища
+ */
+struct CompactionStatus {
+    CompactionState state = CompactionState::IDLE;
+    uintmax_t journalSizeBefore = 0; // Размер журнала до сжатия в байтах
+    uintmax_t journalSizeAfter = 0; // Размер журнала после сжатия в байтах
+};
+
+/**
+ * @struct EntryStat
+ * @brief Размер и метаданные строки хранилища
+ */
+struct EntryStat {
+    size_t size = 0; // Размер данных в байтах
+    EntryMeta meta; // Метаданные (временные метки пусты, если метаданные не сохранились)
+};
+
 /**
  * @class StorageManager
  * @brief Управляет хранением UTF-8 строк и их идентификаторов.
@@ -43,24 +70,31 @@ public:
     /**
      * @brief Добавляет UTF-8 строку в хранилище
      * @param data Строка данных для сохранения
+     * @param ttlSeconds Срок хранения строки в секундах (0 - без ограничения).
+     * По его истечении строка удаляется из хранилища
+     * @param uuid Если указан, строка добавляется с этим UUID, только если такой строки еще нет
      * @return UUID для добавленной строки или std::nullopt при ошибке
      */
-    std::optional<std::string> insert(const std::string &data);
+    std::optional<std::string> insert(const std::string &data, uint64_t ttlSeconds = 0,
+                                      const std::optional<std::string> &uuid = std::nullopt);
 
     /**
      * @brief Извлекает строку по её идентификатору
      * @param uuid Уникальный идентификатор строки
+     * @param version Если указан, заполняется версией строки на момент чтения (0 - неизвестна)
      * @return Сохранённая строка данных или std::nullopt, если не найдена
      */
-    std::optional<std::string> get(const std::string &uuid) const;
+    std::optional<std::string> get(const std::string &uuid, uint64_t *version = nullptr) const;
 
     /**
      * @brief Обновляет существующую строку новыми данными
      * @param uuid Уникальный идентификатор строки для обновления
      * @param data Новые данные для сохранения
+     * @param expectedVersion Если указана, строка обновляется только при совпадении ее текущей версии
      * @return true если обновление выполнено успешно или false при ошибке
      */
-    bool update(const std::string &uuid, const std::string &data);
+    bool update(const std::string &uuid, const std::string &data,
+                std::optional<uint64_t> expectedVersion = std::nullopt);
 
     /**
      * @brief Удаляет строку из хранилища
@@ -80,6 +114,67 @@ public:
      */
     void requestSnapshotAsync();
 
+    /**
+     * @brief Сжимает хранилище: создаёт снимок и удаляет из журнала операции до него
+     * @return true если сжатие выполнено успешно
+     */
+    bool compact();
+
+    /**
+     * @brief Запрашивает асинхронное сжатие хранилища
+     * @return false, если сжатие уже выполняется
+     */
+    bool requestCompactionAsync();
+
+    /**
+     * @brief Возвращает состояние последнего сжатия хранилища
+     * @return Состояние сжатия
+     */
+    CompactionStatus getCompactionStatus() const;
+
+    /**
+     * @brief Возвращает записи журнала операций, выполненных начиная с указанного момента
+     * @param since Временная метка в формате ISO 8601 (пустая строка - с начала журнала)
+     * @param skip Количество пропускаемых первых подходящих записей
+     * @param limit Максимальное количество записей
+     * @param maxBytes Максимальный суммарный размер данных записей
+     * @param[out] more Остались ли в журнале записи, не вошедшие в результат
+     * @return Записи журнала или std::nullopt при ошибке чтения
+     */
+    std::optional<std::vector<JournalEntry>> getJournalEntries(const std::string &since,
+                                                               size_t skip, size_t limit,
+                                                               size_t maxBytes, bool &more) const;
+
+    /**
+     * @brief Возвращает UUID строк хранилища в порядке возрастания, следующие за указанным
+     * @param after UUID, после которого начинается страница (пустая строка - с начала)
+     * @param limit Максимальное количество UUID
+     * @param[out] more Остались ли UUID, не вошедшие в результат
+     * @return UUID строк
+     */
+    std::vector<std::string> listUuids(const std::string &after, size_t limit, bool &more) const;
+
+    /**
+     * @brief Проверяет наличие строки в хранилище
+     * @param uuid Уникальный идентификатор строки
+     * @return true если строка с таким UUID есть в хранилище
+     */
+    bool contains(const std::string &uuid) const;
+
+    /**
+     * @brief Возвращает размер и метаданные строки без копирования ее данных
+     * @param uuid Уникальный идентификатор строки
+     * @return Размер и метаданные строки или std::nullopt, если строка не найдена
+     */
+    std::optional<EntryStat> stat(const std::string &uuid) const;
+
+    /**
+     * @brief Проверяет, достаточно ли места на диске для записи данных
+     * @param bytes Размер записываемых данных
+     * @return true если места достаточно или его не удалось определить
+     */
+    bool hasFreeSpace(std::uintmax_t bytes) const;
+
     /**
      * @brief Возвращает количество записей в хранилище
      * @return Количество записей
@@ -101,9 +196,14 @@ public:
 private:
     // Хранилище данных в памяти
     std::unordered_map<std::string, std::string> dataStore_;
+    // Метаданные строк (изменяются вместе с dataStore_)
+    std::unordered_map<std::string, EntryMeta> metaStore_;
+    // Строки с ограниченным сроком хранения в порядке его истечения: (момент истечения, UUID)
+    std::set<std::pair<std::string, std::string>> expiryIndex_;
 
     const std::filesystem::path dataDir_;
     const std::filesystem::path snapshotPath_;
+    const std::filesystem::path metaSnapshotPath_;
 
     JournalManager journalManager_;
     UuidGenerator uuidGenerator_;
@@ -124,6 +224,16 @@ private:
     std::atomic<bool> snapshotRequested_{ false };
     std::chrono::steady_clock::time_point lastSnapshotTime_;
 
+    // Для удаления строк с истекшим сроком хранения
+    std::thread expiryThread_;
+    std::condition_variable expiryCondition_;
+    std::mutex expiryMutex_;
+
+    // Для управления асинхронным сжатием (выполняется в потоке снапшотов)
+    std::atomic<bool> compactionRequested_{ false };
+    mutable std::mutex compactionMutex_;
+    CompactionStatus compactionStatus_;
+
     /**
      * @brief Загружает данные из файлов в память
      * @return true если загрузка выполнена успешно
@@ -136,6 +246,13 @@ private:
      */
     bool loadSnapshot();
 
+    /**
+     * @brief Загружает метаданные строк из снапшота метаданных
+     * @param checkpointId ID контрольной точки, с которой был записан загруженный снапшот
+     * @return true если загрузка выполнена успешно
+     */
+    bool loadMetaSnapshot(const std::string &checkpointId);
+
     /**
      * @brief Восстанавливает данные из журнала операций
      * @param lastCheckpointId ID последней контрольной точки (опционально)
@@ -150,11 +267,42 @@ private:
      */
     bool writeSnapshotToDisk(const std::unordered_map<std::string, std::string> &data);
 
+    /**
+     * @brief Записывает снапшот метаданных на диск
+     * @param meta Метаданные для записи
+     * @param checkpointId ID контрольной точки, к которой относится снапшот
+     * @return true если запись выполнена успешно
+     */
+    bool writeMetaSnapshotToDisk(const std::unordered_map<std::string, EntryMeta> &meta,
+                                 const std::string &checkpointId);
+
     /**
      * @brief Функция потока для создания снапшотов.
      */
     void snapshotThreadFunction();
 
+    /**
+     * @brief Функция потока удаления строк с истекшим сроком хранения
+     */
+    void expiryThreadFunction();
+
+    /**
+     * @brief Удаляет строки, срок хранения которых истек
+     * @return Количество удаленных строк
+     */
+    size_t removeExpired();
+
+    /**
+     * @brief Выполняет запрошенное асинхронное сжатие и сохраняет его результат
+     */
+    void runRequestedCompaction();
+
+    /**
+     * @brief Возвращает текущий размер файла журнала
+     * @return Размер в байтах (0, если размер не удалось определить)
+     */
+    uintmax_t journalSize() const;
+
     /**
      * @brief Уведомляет о выполнении операции, изменяющей данные.
      */
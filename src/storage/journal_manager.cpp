@@ -17,8 +17,8 @@ static constexpr char JOURNAL_HEADER[] = "# OCTET Journal Format v1.0\n";
 
 // Строковые представления типов операций
 // !!! Порядок должен соответствовать порядку в перечислении OperationType
-static const std::array<std::string, 4> OPERATION_TYPE_STRINGS
-    = { "INSERT", "UPDATE", "REMOVE", "CHECKPOINT" };
+static const std::array<std::string, 5> OPERATION_TYPE_STRINGS
+    = { "INSERT", "UPDATE", "REMOVE", "CHECKPOINT", "EXPIRE" };
 
 /**
  * @brief Экранирует специальные символы в строке для хранения в журнале
@@ -267,7 +267,7 @@ JournalManager::~JournalManager()
 }
 
 bool JournalManager::writeOperation(OperationType opType, const std::string &uuid,
-                                    const std::string &data)
+                                    const std::string &data, std::string *timestamp)
 {
     LOG_DEBUG << "Запись операции в журнал: " << journalFilePath_.string()
               << ", операция = " << operationTypeToString(opType);
@@ -283,20 +283,20 @@ bool JournalManager::writeOperation(OperationType opType, const std::string &uui
     if (opType == OperationType::CHECKPOINT) {
         // Используем блокировку для поддержания атомарности между записью в файл и обновлением кэша
         std::lock_guard<std::mutex> lock(journalMutex_);
-        writeResult = do_writeOperation(opType, uuid, data);
+        writeResult = do_writeOperation(opType, uuid, data, timestamp);
         if (writeResult) {
             // Обновляем кэшированное значение только при успешном добавлении операции в журнал
             lastCheckpointId_ = uuid;
         }
     }
     else {
-        writeResult = do_writeOperation(opType, uuid, data);
+        writeResult = do_writeOperation(opType, uuid, data, timestamp);
     }
     return writeResult;
 }
 
 bool JournalManager::do_writeOperation(OperationType opType, const std::string &uuid,
-                                       const std::string &data)
+                                       const std::string &data, std::string *timestamp)
 {
     // Сериализуем запись
     JournalEntry entry(opType, uuid, data);
@@ -307,17 +307,22 @@ bool JournalManager::do_writeOperation(OperationType opType, const std::string &
         LOG_ERROR << "Не удалось записать операцию в журнал, тип: " << operationTypeToString(opType)
                   << ", UUID: " << uuid;
     }
+    else if (timestamp != nullptr) {
+        *timestamp = entry.timestamp();
+    }
     return writeResult;
 }
 
-bool JournalManager::writeInsert(const std::string &uuid, const std::string &data)
+bool JournalManager::writeInsert(const std::string &uuid, const std::string &data,
+                                 std::string *timestamp)
 {
-    return writeOperation(OperationType::INSERT, uuid, data);
+    return writeOperation(OperationType::INSERT, uuid, data, timestamp);
 }
 
-bool JournalManager::writeUpdate(const std::string &uuid, const std::string &data)
+bool JournalManager::writeUpdate(const std::string &uuid, const std::string &data,
+                                 std::string *timestamp)
 {
-    return writeOperation(OperationType::UPDATE, uuid, data);
+    return writeOperation(OperationType::UPDATE, uuid, data, timestamp);
 }
 
 bool JournalManager::writeRemove(const std::string &uuid)
@@ -325,13 +330,19 @@ bool JournalManager::writeRemove(const std::string &uuid)
     return writeOperation(OperationType::REMOVE, uuid);
 }
 
+bool JournalManager::writeExpire(const std::string &uuid, const std::string &expiresAt)
+{
+    return writeOperation(OperationType::EXPIRE, uuid, expiresAt);
+}
+
 bool JournalManager::writeCheckpoint(const std::string &snapshotId)
 {
     return writeOperation(OperationType::CHECKPOINT, snapshotId);
 }
 
 bool JournalManager::replayJournal(std::unordered_map<std::string, std::string> &dataStore,
-                                   const std::optional<std::string> &lastCheckpoint)
+                                   const std::optional<std::string> &lastCheckpoint,
+                                   std::unordered_map<std::string, EntryMeta> *metaStore)
 {
     LOG_DEBUG << "Воспроизведение действий из журнала: " << journalFilePath_.string()
               << ", начиная с контрольной точки: "
@@ -393,7 +404,7 @@ bool JournalManager::replayJournal(std::unordered_map<std::string, std::string>
         }
 
         // Применяем операцию к хранилищу
-        if (applyOperation(*entry, dataStore)) {
+        if (applyOperation(*entry, dataStore, metaStore)) {
             appliedOperations++;
         }
         else {
@@ -576,11 +587,15 @@ bool JournalManager::isJournalValid() const
 
 // Применение операции к хранилищу
 bool JournalManager::applyOperation(const JournalEntry &entry,
-                                    std::unordered_map<std::string, std::string> &dataStore) const
+                                    std::unordered_map<std::string, std::string> &dataStore,
+                                    std::unordered_map<std::string, EntryMeta> *metaStore) const
 {
     switch (entry.type()) {
     case OperationType::INSERT: {
         dataStore[entry.uuid()] = entry.data();
+        if (metaStore != nullptr) {
+            (*metaStore)[entry.uuid()] = EntryMeta{ entry.timestamp(), entry.timestamp(), 1 };
+        }
         LOG_DEBUG << "Применена операция INSERT для UUID: " << entry.uuid();
         return true;
     }
@@ -592,6 +607,11 @@ bool JournalManager::applyOperation(const JournalEntry &entry,
             return false;
         }
         dataStore[uuid] = entry.data();
+        if (metaStore != nullptr) {
+            auto &meta = (*metaStore)[uuid];
+            meta.modifiedAt = entry.timestamp();
+            meta.version++;
+        }
         LOG_DEBUG << "Применена операция UPDATE для UUID: " << uuid;
         return true;
     }
@@ -603,16 +623,79 @@ bool JournalManager::applyOperation(const JournalEntry &entry,
             return false;
         }
         dataStore.erase(uuid);
+        if (metaStore != nullptr) {
+            metaStore->erase(uuid);
+        }
         LOG_DEBUG << "Применена операция REMOVE для UUID: " << uuid;
         return true;
     }
     case OperationType::CHECKPOINT:
         // Контрольные точки не применяются к хранилищу
         return true;
+    case OperationType::EXPIRE: {
+        const auto uuid = entry.uuid();
+        // Срок хранения записывается сразу после добавления строки
+        if (dataStore.find(uuid) == dataStore.end()) {
+            LOG_WARNING << "Операция EXPIRE для несуществующего UUID: " << uuid;
+            return false;
+        }
+        if (metaStore != nullptr) {
+            (*metaStore)[uuid].expiresAt = entry.data();
+        }
+        LOG_DEBUG << "Применена операция EXPIRE для UUID: " << uuid;
+        return true;
+    }
     }
     UNREACHABLE("Unsupported OperationType");
 }
 
+bool JournalManager::readEntriesSince(const std::string &since, size_t skip, size_t limit,
+                                      size_t maxBytes, std::vector<JournalEntry> &entries,
+                                      bool &more) const
+{
+    LOG_DEBUG << "Считывание записей из журнала: " << journalFilePath_.string()
+              << ", начиная с: " << (since.empty() ? "[начало]" : since);
+
+    std::string journalContent;
+    if (!utils::safeFileRead(journalFilePath_, journalContent)) {
+        LOG_ERROR << "Не удалось прочитать файл журнала: " << journalFilePath_.string();
+        return false;
+    }
+
+    more = false;
+    size_t totalBytes = 0;
+    size_t pos = 0;
+    while (pos < journalContent.size()) {
+        const auto line = readLineFromJournalContent(journalContent, pos);
+        if (!line.has_value()) {
+            continue;
+        }
+
+        auto entry = JournalEntry::deserialize(*line);
+        if (!entry.has_value()) {
+            LOG_WARNING << "Некорректная запись в журнале: " << *line;
+            continue;
+        }
+        // Временные метки имеют одинаковый формат, поэтому сравниваются как строки
+        if (entry->timestamp() < since) {
+            continue;
+        }
+        if (skip > 0) {
+            skip--;
+            continue;
+        }
+
+        if (entries.size() >= limit
+            || (!entries.empty() && totalBytes + entry->data().size() > maxBytes)) {
+            more = true;
+            break;
+        }
+        totalBytes += entry->data().size();
+        entries.push_back(std::move(*entry));
+    }
+    return true;
+}
+
 bool JournalManager::readAllEntriesFrom(const std::optional<std::string> &checkpointId,
                                         std::vector<JournalEntry> &entries) const
 {
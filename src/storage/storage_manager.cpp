@@ -1,11 +1,36 @@
 #include "storage/storage_manager.hpp"
 
+#include <algorithm>
+#include <charconv>
+#include <ctime>
+#include <iomanip>
+#include <sstream>
+
 #include "utils/file_utils.hpp"
 #include "logger.hpp"
 
 namespace {
 static constexpr char SNAPSHOT_FILE_NAME[] = "octet-data.snapshot";
 static constexpr char JOURNAL_FILE_NAME[] = "octet-operations.journal";
+static constexpr char META_SNAPSHOT_FILE_NAME[] = "octet-meta.snapshot";
+// Ключ снапшота метаданных, под которым хранится ID контрольной точки снапшота
+static constexpr char META_CHECKPOINT_KEY[] = "";
+static constexpr char META_FIELD_SEPARATOR = '|';
+// Интервал проверки сроков хранения строк
+static constexpr auto EXPIRY_CHECK_INTERVAL = std::chrono::seconds(1);
+
+// Форматирование момента времени в ISO 8601 (UTC, с миллисекундами), как во временных метках журнала
+std::string formatIsoTimestamp(std::chrono::system_clock::time_point time)
+{
+    const auto seconds = std::chrono::system_clock::to_time_t(time);
+    const auto ms
+        = std::chrono::duration_cast<std::chrono::milliseconds>(time.time_since_epoch()) % 1000;
+
+    std::ostringstream oss;
+    oss << std::put_time(std::gmtime(&seconds), "%Y-%m-%dT%H:%M:%S") << '.' << std::setfill('0')
+        << std::setw(3) << ms.count() << 'Z';
+    return oss.str();
+}
 
 // Быстрое преобразование хранилища в строку
 std::string serializeMap(const std::unordered_map<std::string, std::string> &map)
@@ -106,12 +131,53 @@ std::optional<std::unordered_map<std::string, std::string>> deserializeMap(const
     return map;
 }
 
+// Преобразование метаданных строки в значение снапшота метаданных: created|modified|version,
+// для строк с ограниченным сроком хранения - created|modified|version|expires
+std::string serializeMeta(const octet::EntryMeta &meta)
+{
+    auto value = meta.createdAt + META_FIELD_SEPARATOR + meta.modifiedAt + META_FIELD_SEPARATOR
+                 + std::to_string(meta.version);
+    if (!meta.expiresAt.empty()) {
+        value += META_FIELD_SEPARATOR + meta.expiresAt;
+    }
+    return value;
+}
+
+// Преобразование значения снапшота метаданных в метаданные строки
+std::optional<octet::EntryMeta> deserializeMeta(const std::string &value)
+{
+    const auto first = value.find(META_FIELD_SEPARATOR);
+    if (first == std::string::npos) {
+        return std::nullopt;
+    }
+    const auto second = value.find(META_FIELD_SEPARATOR, first + 1);
+    if (second == std::string::npos) {
+        return std::nullopt;
+    }
+
+    octet::EntryMeta meta;
+    meta.createdAt = value.substr(0, first);
+    meta.modifiedAt = value.substr(first + 1, second - first - 1);
+    const auto third = value.find(META_FIELD_SEPARATOR, second + 1);
+    const auto *begin = value.data() + second + 1;
+    const auto *end = value.data() + (third == std::string::npos ? value.size() : third);
+    const auto [ptr, ec] = std::from_chars(begin, end, meta.version);
+    if (ec != std::errc() || ptr != end) {
+        return std::nullopt;
+    }
+    if (third != std::string::npos) {
+        meta.expiresAt = value.substr(third + 1);
+    }
+    return meta;
+}
+
 } // namespace
 
 namespace octet {
 StorageManager::StorageManager(const std::filesystem::path &dataDir)
     : dataDir_(dataDir)
     , snapshotPath_(dataDir / SNAPSHOT_FILE_NAME)
+    , metaSnapshotPath_(dataDir / META_SNAPSHOT_FILE_NAME)
     , journalManager_(dataDir / JOURNAL_FILE_NAME)
     , lastSnapshotTime_(std::chrono::steady_clock::now())
 {
@@ -128,9 +194,10 @@ StorageManager::StorageManager(const std::filesystem::path &dataDir)
         LOG_WARNING << "Не удалось полностью загрузить данные с диска";
     }
 
-    // Запуск потока для асинхронных снапшотов
+    // Запуск потоков для асинхронных снапшотов и удаления строк с истекшим сроком хранения
     snapshotThread_ = std::thread(&StorageManager::snapshotThreadFunction, this);
-    LOG_INFO << "StorageManager успешно инициализирован, запущен поток снапшотов";
+    expiryThread_ = std::thread(&StorageManager::expiryThreadFunction, this);
+    LOG_INFO << "StorageManager успешно инициализирован, запущены потоки снапшотов и сроков хранения";
 }
 
 StorageManager::~StorageManager()
@@ -143,11 +210,18 @@ StorageManager::~StorageManager()
         shutdownRequested_ = true;
         snapshotCondition_.notify_all();
     }
+    {
+        std::lock_guard<std::mutex> lock(expiryMutex_);
+        expiryCondition_.notify_all();
+    }
 
-    // Ожидание завершения потока
+    // Ожидание завершения потоков
     if (snapshotThread_.joinable()) {
         snapshotThread_.join();
     }
+    if (expiryThread_.joinable()) {
+        expiryThread_.join();
+    }
 
     // Создание финального снапшота перед выходом
     LOG_INFO << "Создание финального снапшота перед завершением работы";
@@ -179,6 +253,11 @@ bool StorageManager::loadFromDisk()
     if (snapshotLoaded) {
         // Если снапшот загружен, восстанавливаем операции после последней контрольной точки
         lastCheckpointId = journalManager_.getLastCheckpointId();
+        // Метаданные строк снапшота восстанавливаются из снапшота метаданных той же точки
+        if (lastCheckpointId.has_value() && !loadMetaSnapshot(*lastCheckpointId)) {
+            LOG_WARNING << "Метаданные строк снапшота не загружены, временные метки строк "
+                           "будут известны только после их изменения";
+        }
     }
 
     LOG_INFO << "Восстановление из журнала"
@@ -189,6 +268,13 @@ bool StorageManager::loadFromDisk()
         LOG_WARNING << "Не удалось полностью восстановить данные из журнала";
     }
 
+    // Строки, срок хранения которых истек до запуска, удаляются при первой проверке
+    for (const auto &[uuid, meta] : metaStore_) {
+        if (!meta.expiresAt.empty() && dataStore_.find(uuid) != dataStore_.end()) {
+            expiryIndex_.emplace(meta.expiresAt, uuid);
+        }
+    }
+
     LOG_INFO << "Загрузка данных с диска завершена, записей в хранилище: " << dataStore_.size();
     return true;
 }
@@ -214,27 +300,102 @@ bool StorageManager::loadSnapshot()
     return false;
 }
 
+bool StorageManager::loadMetaSnapshot(const std::string &checkpointId)
+{
+    LOG_DEBUG << "Загрузка снапшота метаданных: " << metaSnapshotPath_.string();
+
+    if (!utils::isFileReadable(metaSnapshotPath_)) {
+        LOG_INFO << "Файл снапшота метаданных не найден";
+        return false;
+    }
+    std::string content;
+    if (!utils::safeFileRead(metaSnapshotPath_, content)) {
+        LOG_ERROR << "Ошибка чтения файла снапшота метаданных";
+        return false;
+    }
+
+    auto entries = deserializeMap(content);
+    if (!entries.has_value()) {
+        LOG_ERROR << "Снапшот метаданных поврежден или имеет некорректный формат";
+        return false;
+    }
+    // Снапшот метаданных, записанный не с той же контрольной точкой, что и снапшот данных,
+    // не соответствует ему (например, при сбое между записью снапшотов)
+    const auto checkpoint = entries->find(META_CHECKPOINT_KEY);
+    if (checkpoint == entries->end() || checkpoint->second != checkpointId) {
+        LOG_WARNING << "Снапшот метаданных не соответствует контрольной точке: " << checkpointId;
+        return false;
+    }
+    entries->erase(checkpoint);
+
+    std::unordered_map<std::string, EntryMeta> metaStore;
+    metaStore.reserve(entries->size());
+    for (const auto &[uuid, value] : *entries) {
+        auto meta = deserializeMeta(value);
+        if (!meta.has_value()) {
+            LOG_ERROR << "Некорректные метаданные строки в снапшоте метаданных: " << uuid;
+            return false;
+        }
+        metaStore.emplace(uuid, std::move(*meta));
+    }
+
+    std::unique_lock<std::shared_mutex> lock(storageMutex_);
+    metaStore_ = std::move(metaStore);
+    LOG_INFO << "Снапшот метаданных успешно загружен, записей: " << metaStore_.size();
+    return true;
+}
+
 bool StorageManager::restoreFromJournal(const std::optional<std::string> &lastCheckpointId)
 {
     LOG_DEBUG << "Восстановление данных из журнала операций";
     std::unique_lock<std::shared_mutex> lock(storageMutex_);
-    return journalManager_.replayJournal(dataStore_, lastCheckpointId);
+    return journalManager_.replayJournal(dataStore_, lastCheckpointId, &metaStore_);
 }
 
-std::optional<std::string> StorageManager::insert(const std::string &data)
+std::optional<std::string> StorageManager::insert(const std::string &data, uint64_t ttlSeconds,
+                                                  const std::optional<std::string> &requestedUuid)
 {
     // Эксклюзивная блокировка для записи
     std::unique_lock<std::shared_mutex> lock(storageMutex_);
 
-    // Генерируем UUID
-    const auto uuid = uuidGenerator_.generateUuid();
+    // Используем указанный UUID (проверяя его отсутствие под той же блокировкой) или генерируем новый
+    if (requestedUuid.has_value()) {
+        if (!UuidGenerator::isValidUuid(*requestedUuid)) {
+            LOG_WARNING << "Некорректный UUID для добавления: " << *requestedUuid;
+            return std::nullopt;
+        }
+        if (dataStore_.find(*requestedUuid) != dataStore_.end()) {
+            LOG_WARNING << "Попытка добавить существующую запись с UUID: " << *requestedUuid;
+            return std::nullopt;
+        }
+    }
+    const auto uuid = requestedUuid.has_value() ? *requestedUuid : uuidGenerator_.generateUuid();
+    std::string expiresAt;
+    if (ttlSeconds > 0) {
+        expiresAt = formatIsoTimestamp(std::chrono::system_clock::now()
+                                       + std::chrono::seconds(ttlSeconds));
+    }
     // Записываем в журнал
-    if (!journalManager_.writeInsert(uuid, data)) {
+    std::string timestamp;
+    if (!journalManager_.writeInsert(uuid, data, &timestamp)) {
         LOG_ERROR << "Не удалось записать данные: " << data;
         return std::nullopt;
     }
+    // Срок хранения записывается отдельной операцией сразу после добавления
+    if (!expiresAt.empty() && !journalManager_.writeExpire(uuid, expiresAt)) {
+        LOG_ERROR << "Не удалось записать срок хранения строки: " << uuid;
+        // Строка без срока хранения не должна восстановиться из журнала
+        if (!journalManager_.writeRemove(uuid)) {
+            LOG_ERROR << "Не удалось отменить добавление строки: " << uuid;
+        }
+        return std::nullopt;
+    }
     // Обновляем данные в памяти
     dataStore_[uuid] = data;
+    metaStore_[uuid] = EntryMeta{ timestamp, timestamp, 1, expiresAt };
+    if (!expiresAt.empty()) {
+        expiryIndex_.emplace(expiresAt, uuid);
+    }
 
     // Уведомляем о выполнении операции
     notifyOperation();
@@ -243,13 +404,18 @@ std::optional<std::string> StorageManager::insert(const std::string &data)
     return uuid;
 }
 
-std::optional<std::string> StorageManager::get(const std::string &uuid) const
+std::optional<std::string> StorageManager::get(const std::string &uuid, uint64_t *version) const
 {
     // Разделяемая блокировка для чтения
     std::shared_lock<std::shared_mutex> lock(storageMutex_);
     // Ищем запись в хранилище
     auto it = dataStore_.find(uuid);
     if (it != dataStore_.end()) {
+        // Версия читается под той же блокировкой, поэтому соответствует возвращаемым данным
+        if (version != nullptr) {
+            const auto meta = metaStore_.find(uuid);
+            *version = meta != metaStore_.end() ? meta->second.version : 0;
+        }
         // Если нашли, возвращаем данные для переданного UUID
         return it->second;
     }
@@ -257,7 +423,8 @@ std::optional<std::string> StorageManager::get(const std::string &uuid) const
     return std::nullopt;
 }
 
-bool StorageManager::update(const std::string &uuid, const std::string &data)
+bool StorageManager::update(const std::string &uuid, const std::string &data,
+                            std::optional<uint64_t> expectedVersion)
 {
     // Эксклюзивная блокировка для записи
     std::unique_lock<std::shared_mutex> lock(storageMutex_);
@@ -267,12 +434,26 @@ bool StorageManager::update(const std::string &uuid, const std::string &data)
         LOG_WARNING << "Попытка обновить несуществующую запись с UUID: " << uuid;
         return false;
     }
+    // Версия проверяется под той же блокировкой, что и обновление
+    if (expectedVersion.has_value()) {
+        const auto meta = metaStore_.find(uuid);
+        const uint64_t version = meta != metaStore_.end() ? meta->second.version : 0;
+        if (version != *expectedVersion) {
+            LOG_DEBUG << "Версия записи с UUID " << uuid << " (" << version
+                      << ") не совпадает с ожидаемой (" << *expectedVersion << ")";
+            return false;
+        }
+    }
     // Записываем в журнал
-    if (!journalManager_.writeUpdate(uuid, data)) {
+    std::string timestamp;
+    if (!journalManager_.writeUpdate(uuid, data, &timestamp)) {
         return false;
     }
     // Обновляем данные в памяти
     dataStore_[uuid] = data;
+    auto &meta = metaStore_[uuid];
+    meta.modifiedAt = timestamp;
+    meta.version++;
 
     // Уведомляем о выполнении операции
     notifyOperation();
@@ -297,6 +478,12 @@ bool StorageManager::remove(const std::string &uuid)
     }
     // Удаляем из памяти
     dataStore_.erase(uuid);
+    if (const auto meta = metaStore_.find(uuid); meta != metaStore_.end()) {
+        if (!meta->second.expiresAt.empty()) {
+            expiryIndex_.erase({ meta->second.expiresAt, uuid });
+        }
+        metaStore_.erase(meta);
+    }
 
     // Уведомляем о выполнении операции
     notifyOperation();
@@ -311,9 +498,11 @@ bool StorageManager::createSnapshot()
 
     // Копируем данные
     std::unordered_map<std::string, std::string> dataCopy;
+    std::unordered_map<std::string, EntryMeta> metaCopy;
     {
         std::shared_lock<std::shared_mutex> lock(storageMutex_);
         dataCopy = dataStore_;
+        metaCopy = metaStore_;
     }
 
     // Генерируем идентификатор снапшота
@@ -324,6 +513,12 @@ bool StorageManager::createSnapshot()
         LOG_ERROR << "Ошибка создания снапшота: не удалось записать снапшот на диск";
         return false;
     }
+    // Ошибка записи метаданных не прерывает создание снапшота: без них теряются
+    // только временные метки и версии строк, но не данные
+    if (!writeMetaSnapshotToDisk(metaCopy, snapshotId)) {
+        LOG_WARNING << "Не удалось записать снапшот метаданных, метаданные строк снапшота "
+                       "не будут восстановлены";
+    }
 
     // Записываем контрольную точку в журнал
     if (!journalManager_.writeCheckpoint(snapshotId)) {
@@ -356,6 +551,26 @@ bool StorageManager::writeSnapshotToDisk(const std::unordered_map<std::string, s
     return true;
 }
 
+bool StorageManager::writeMetaSnapshotToDisk(const std::unordered_map<std::string, EntryMeta> &meta,
+                                             const std::string &checkpointId)
+{
+    LOG_DEBUG << "Запись снапшота метаданных на диск: " << metaSnapshotPath_.string();
+
+    // Метаданные записываются в формате снапшота данных вместе с ID контрольной точки
+    std::unordered_map<std::string, std::string> entries;
+    entries.reserve(meta.size() + 1);
+    entries.emplace(META_CHECKPOINT_KEY, checkpointId);
+    for (const auto &[uuid, entryMeta] : meta) {
+        entries.emplace(uuid, serializeMeta(entryMeta));
+    }
+
+    if (!utils::atomicFileWrite(metaSnapshotPath_, serializeMap(entries))) {
+        LOG_ERROR << "Не удалось записать снапшот метаданных на диск";
+        return false;
+    }
+    return true;
+}
+
 void StorageManager::snapshotThreadFunction()
 {
     LOG_INFO << "Запущен поток создания снапшотов";
@@ -369,7 +584,9 @@ void StorageManager::snapshotThreadFunction()
             // Ждем уведомления или таймаута
             snapshotCondition_.wait_for(
                 lock, std::chrono::minutes(snapshotTimeThresholdMinutes_),
-                [this] { return snapshotRequested_ || shutdownRequested_; });
+                [this] {
+                    return snapshotRequested_ || compactionRequested_ || shutdownRequested_;
+                });
 
             shouldCreateSnapshot = snapshotRequested_;
             snapshotRequested_ = false;
@@ -386,11 +603,72 @@ void StorageManager::snapshotThreadFunction()
                      << operationsSinceLastSnapshot_;
             createSnapshot();
         }
+
+        if (compactionRequested_) {
+            runRequestedCompaction();
+        }
     }
 
     LOG_INFO << "Поток создания снапшотов завершен";
 }
 
+void StorageManager::expiryThreadFunction()
+{
+    LOG_INFO << "Запущен поток удаления строк с истекшим сроком хранения";
+
+    while (!shutdownRequested_) {
+        {
+            std::unique_lock<std::mutex> lock(expiryMutex_);
+            expiryCondition_.wait_for(lock, EXPIRY_CHECK_INTERVAL,
+                                      [this] { return shutdownRequested_.load(); });
+        }
+        if (shutdownRequested_) {
+            break;
+        }
+
+        const auto removed = removeExpired();
+        if (removed > 0) {
+            LOG_INFO << "Удалено строк с истекшим сроком хранения: " << removed;
+        }
+    }
+
+    LOG_INFO << "Поток удаления строк с истекшим сроком хранения завершен";
+}
+
+size_t StorageManager::removeExpired()
+{
+    // Во время сжатия журнал очищается до контрольной точки, поэтому удаление
+    // откладывается до его завершения, как и остальные изменения
+    if (getCompactionStatus().state == CompactionState::RUNNING) {
+        return 0;
+    }
+
+    const auto now = formatIsoTimestamp(std::chrono::system_clock::now());
+    {
+        std::shared_lock<std::shared_mutex> lock(storageMutex_);
+        if (expiryIndex_.empty() || expiryIndex_.begin()->first > now) {
+            return 0;
+        }
+    }
+
+    size_t removed = 0;
+    std::unique_lock<std::shared_mutex> lock(storageMutex_);
+    while (!expiryIndex_.empty() && expiryIndex_.begin()->first <= now) {
+        const auto uuid = expiryIndex_.begin()->second;
+        if (!journalManager_.writeRemove(uuid)) {
+            // Удаление повторяется при следующей проверке
+            LOG_ERROR << "Не удалось удалить строку с истекшим сроком хранения: " << uuid;
+            break;
+        }
+        expiryIndex_.erase(expiryIndex_.begin());
+        dataStore_.erase(uuid);
+        metaStore_.erase(uuid);
+        notifyOperation();
+        removed++;
+    }
+    return removed;
+}
+
 void StorageManager::requestSnapshotAsync()
 {
     std::lock_guard<std::mutex> lock(snapshotMutex_);
@@ -399,6 +677,69 @@ void StorageManager::requestSnapshotAsync()
     LOG_DEBUG << "Запрошено асинхронное создание снапшота";
 }
 
+bool StorageManager::compact()
+{
+    LOG_INFO << "Сжатие хранилища";
+
+    if (!createSnapshot()) {
+        LOG_ERROR << "Ошибка сжатия хранилища: не удалось создать снапшот";
+        return false;
+    }
+
+    const auto checkpointId = journalManager_.getLastCheckpointId();
+    if (!checkpointId.has_value()) {
+        LOG_ERROR << "Ошибка сжатия хранилища: контрольная точка не найдена";
+        return false;
+    }
+    if (!journalManager_.truncateJournalToCheckpoint(*checkpointId)) {
+        LOG_ERROR << "Ошибка сжатия хранилища: не удалось очистить журнал";
+        return false;
+    }
+
+    LOG_INFO << "Хранилище успешно сжато";
+    return true;
+}
+
+bool StorageManager::requestCompactionAsync()
+{
+    {
+        std::lock_guard<std::mutex> lock(compactionMutex_);
+        if (compactionStatus_.state == CompactionState::RUNNING) {
+            return false;
+        }
+        compactionStatus_ = CompactionStatus{ CompactionState::RUNNING, journalSize(), 0 };
+    }
+
+    std::lock_guard<std::mutex> lock(snapshotMutex_);
+    compactionRequested_ = true;
+    snapshotCondition_.notify_one();
+    LOG_DEBUG << "Запрошено асинхронное сжатие хранилища";
+    return true;
+}
+
+CompactionStatus StorageManager::getCompactionStatus() const
+{
+    std::lock_guard<std::mutex> lock(compactionMutex_);
+    return compactionStatus_;
+}
+
+void StorageManager::runRequestedCompaction()
+{
+    compactionRequested_ = false;
+    const auto success = compact();
+
+    std::lock_guard<std::mutex> lock(compactionMutex_);
+    compactionStatus_.state = success ? CompactionState::COMPLETED : CompactionState::FAILED;
+    compactionStatus_.journalSizeAfter = journalSize();
+}
+
+uintmax_t StorageManager::journalSize() const
+{
+    std::error_code ec;
+    const auto size = std::filesystem::file_size(dataDir_ / JOURNAL_FILE_NAME, ec);
+    return ec ? 0 : size;
+}
+
 void StorageManager::notifyOperation()
 {
     // Увеличиваем счетчик операций
@@ -410,6 +751,85 @@ void StorageManager::notifyOperation()
     }
 }
 
+std::optional<std::vector<JournalEntry>>
+StorageManager::getJournalEntries(const std::string &since, size_t skip, size_t limit,
+                                  size_t maxBytes, bool &more) const
+{
+    std::vector<JournalEntry> entries;
+    if (!journalManager_.readEntriesSince(since, skip, limit, maxBytes, entries, more)) {
+        return std::nullopt;
+    }
+    return entries;
+}
+
+std::vector<std::string> StorageManager::listUuids(const std::string &after, size_t limit,
+                                                   bool &more) const
+{
+    // Отбираем limit + 1 наименьших UUID после after (лишний UUID - признак продолжения),
+    // не копируя и не сортируя все UUID хранилища
+    const auto less = [](const std::string *a, const std::string *b) { return *a < *b; };
+    std::vector<const std::string *> heap;
+
+    std::shared_lock<std::shared_mutex> lock(storageMutex_);
+    heap.reserve(std::min(limit, dataStore_.size()) + 1);
+    for (const auto &[uuid, _] : dataStore_) {
+        if (uuid <= after) {
+            continue;
+        }
+        if (heap.size() <= limit) {
+            heap.push_back(&uuid);
+            std::push_heap(heap.begin(), heap.end(), less);
+        }
+        else if (uuid < *heap.front()) {
+            std::pop_heap(heap.begin(), heap.end(), less);
+            heap.back() = &uuid;
+            std::push_heap(heap.begin(), heap.end(), less);
+        }
+    }
+    std::sort_heap(heap.begin(), heap.end(), less);
+
+    more = heap.size() > limit;
+    std::vector<std::string> uuids;
+    uuids.reserve(std::min(limit, heap.size()));
+    for (size_t i = 0; i < heap.size() && i < limit; ++i) {
+        uuids.push_back(*heap[i]);
+    }
+    return uuids;
+}
+
+bool StorageManager::contains(const std::string &uuid) const
+{
+    std::shared_lock<std::shared_mutex> lock(storageMutex_);
+    return dataStore_.find(uuid) != dataStore_.end();
+}
+
+std::optional<EntryStat> StorageManager::stat(const std::string &uuid) const
+{
+    std::shared_lock<std::shared_mutex> lock(storageMutex_);
+    const auto it = dataStore_.find(uuid);
+    if (it == dataStore_.end()) {
+        return std::nullopt;
+    }
+
+    EntryStat result;
+    result.size = it->second.size();
+    if (const auto meta = metaStore_.find(uuid); meta != metaStore_.end()) {
+        result.meta = meta->second;
+    }
+    return result;
+}
+
+bool StorageManager::hasFreeSpace(std::uintmax_t bytes) const
+{
+    std::error_code ec;
+    const auto space = std::filesystem::space(dataDir_, ec);
+    if (ec) {
+        LOG_WARNING << "Не удалось определить свободное место на диске: " << ec.message();
+        return true;
+    }
+    return space.available > bytes;
+}
+
 size_t StorageManager::getEntriesCount() const
 {
     std::shared_lock<std::shared_mutex> lock(storageMutex_);
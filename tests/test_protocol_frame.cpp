@@ -0,0 +1,126 @@
+#include <gtest/gtest.h>
+#include <string>
+#include <vector>
+
+#include "server/protocol.hpp"
+#include "testing_utils.hpp"
+
+namespace octet::tests {
+using server::FrameParser;
+using server::ProtocolFrame;
+
+namespace {
+// Заголовок фрейма с указанной длиной и флагом продолжения
+std::vector<uint8_t> frameHeader(uint32_t length, bool more)
+{
+    return ProtocolFrame::encodeLength(more ? length | ProtocolFrame::CONTINUATION_FLAG : length);
+}
+
+// Передача данных в FrameParser частями указанного размера с разбором после каждой части
+std::vector<std::string> feed(FrameParser &parser, const std::vector<uint8_t> &data,
+                              size_t partSize)
+{
+    std::vector<std::string> messages;
+    for (size_t offset = 0; offset < data.size(); offset += partSize) {
+        parser.append(data.data() + offset, std::min(partSize, data.size() - offset));
+        std::string message;
+        FrameParser::Result result;
+        while ((result = parser.next(message)) == FrameParser::Result::MESSAGE) {
+            messages.push_back(std::move(message));
+        }
+        EXPECT_EQ(FrameParser::Result::NEED_MORE, result);
+    }
+    return messages;
+}
+} // namespace
+
+// Сообщение больше CHUNK_SIZE разбивается на фреймы с флагом продолжения у всех, кроме последнего
+TEST(ProtocolFrameTest, FrameTestWrapContinuation)
+{
+    const auto message = generateLargeString(3 * ProtocolFrame::CHUNK_SIZE + 100);
+    const auto frames = ProtocolFrame::wrapMessage(message);
+    ASSERT_EQ(message.size() + 4 * sizeof(uint32_t), frames.size());
+
+    size_t offset = 0;
+    for (size_t i = 0; i < 4; ++i) {
+        const auto header = ProtocolFrame::decodeLength(frames.data() + offset);
+        const bool more = (header & ProtocolFrame::CONTINUATION_FLAG) != 0;
+        const auto length = header & ~ProtocolFrame::CONTINUATION_FLAG;
+        EXPECT_EQ(i < 3, more);
+        EXPECT_EQ(i < 3 ? ProtocolFrame::CHUNK_SIZE : 100u, length);
+        offset += sizeof(uint32_t) + length;
+    }
+    EXPECT_EQ(frames.size(), offset);
+}
+
+// Сообщение из нескольких фреймов, полученное мелкими частями, собирается целиком
+TEST(ProtocolFrameTest, FrameTestParseContinuation)
+{
+    const auto message = generateLargeString(3 * ProtocolFrame::CHUNK_SIZE + 100);
+    const auto frames = ProtocolFrame::wrapMessage(message);
+
+    // Части не совпадают с границами фреймов и заголовков
+    for (const size_t partSize : { size_t(1021), size_t(64 * 1024), frames.size() }) {
+        FrameParser parser;
+        const auto messages = feed(parser, frames, partSize);
+        ASSERT_EQ(1u, messages.size()) << "partSize = " << partSize;
+        EXPECT_EQ(message, messages[0]) << "partSize = " << partSize;
+        EXPECT_EQ(0u, parser.pendingSize());
+    }
+}
+
+// Несколько сообщений и heartbeat-фреймы в одном буфере разбираются по порядку
+TEST(ProtocolFrameTest, FrameTestParseSequence)
+{
+    std::vector<uint8_t> data;
+    const auto append = [&data](const std::vector<uint8_t> &bytes) {
+        data.insert(data.end(), bytes.begin(), bytes.end());
+    };
+    append(ProtocolFrame::wrapMessage("first"));
+    append(ProtocolFrame::heartbeatFrame());
+    // Сообщение из фреймов разного размера, включая пустой промежуточный фрейм
+    append(frameHeader(3, true));
+    append({ 's', 'e', 'c' });
+    append(frameHeader(0, true));
+    append(frameHeader(3, false));
+    append({ 'o', 'n', 'd' });
+    append(ProtocolFrame::wrapMessage(""));
+
+    FrameParser parser;
+    parser.append(data.data(), data.size());
+    std::string message;
+    ASSERT_EQ(FrameParser::Result::MESSAGE, parser.next(message));
+    EXPECT_EQ("first", message);
+    ASSERT_EQ(FrameParser::Result::HEARTBEAT, parser.next(message));
+    ASSERT_EQ(FrameParser::Result::MESSAGE, parser.next(message));
+    EXPECT_EQ("second", message);
+    ASSERT_EQ(FrameParser::Result::MESSAGE, parser.next(message));
+    EXPECT_EQ("", message);
+    EXPECT_EQ(FrameParser::Result::NEED_MORE, parser.next(message));
+}
+
+// Сообщение больше максимального размера отклоняется по заголовкам, до получения данных
+TEST(ProtocolFrameTest, FrameTestParseTooLarge)
+{
+    FrameParser parser(1024);
+    std::vector<uint8_t> data = frameHeader(1000, true);
+    data.resize(data.size() + 1000, 'a');
+    const auto header = frameHeader(100, false);
+    data.insert(data.end(), header.begin(), header.end());
+
+    parser.append(data.data(), data.size());
+    std::string message;
+    EXPECT_EQ(FrameParser::Result::TOO_LARGE, parser.next(message));
+    // После превышения размера границы фреймов неизвестны, разбор не продолжается
+    const auto next = ProtocolFrame::wrapMessage("next");
+    parser.append(next.data(), next.size());
+    EXPECT_EQ(FrameParser::Result::TOO_LARGE, parser.next(message));
+
+    // Сообщение ровно максимального размера принимается
+    FrameParser exact(1024);
+    const auto frames = ProtocolFrame::wrapMessage(std::string(1024, 'a'));
+    exact.append(frames.data(), frames.size());
+    ASSERT_EQ(FrameParser::Result::MESSAGE, exact.next(message));
+    EXPECT_EQ(1024u, message.size());
+}
+} // namespace octet::tests
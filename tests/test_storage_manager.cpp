@@ -1,8 +1,10 @@
 #include <gtest/gtest.h>
+#include <algorithm>
 #include <atomic>
 #include <chrono>
 #include <filesystem>
 #include <future>
+#include <limits>
 #include <mutex>
 #include <thread>
 #include <unordered_map>
@@ -204,6 +206,227 @@ TEST_F(StorageManagerTest, BasicCrudOperations)
     ASSERT_FALSE(manager.remove("non_existent_uuid"));
 }
 
+// Тест проверки наличия строки и свободного места
+TEST_F(StorageManagerTest, ContainsAndFreeSpace)
+{
+    const auto dataDir = createSubdir("contains_test");
+    StorageManager manager(dataDir);
+
+    const auto uuid = manager.insert("data");
+    ASSERT_TRUE(uuid.has_value());
+    ASSERT_TRUE(manager.contains(*uuid));
+    ASSERT_FALSE(manager.contains("non_existent_uuid"));
+
+    ASSERT_TRUE(manager.remove(*uuid));
+    ASSERT_FALSE(manager.contains(*uuid));
+
+    // Для небольшой записи места достаточно, для записи больше любого диска - нет
+    ASSERT_TRUE(manager.hasFreeSpace(1));
+    ASSERT_FALSE(manager.hasFreeSpace(std::numeric_limits<std::uintmax_t>::max()));
+}
+
+// Тест размера и метаданных строк
+TEST_F(StorageManagerTest, StatMetadata)
+{
+    const auto dataDir = createSubdir("stat_test");
+    std::string uuid;
+    EntryMeta inserted;
+    {
+        StorageManager manager(dataDir);
+        ASSERT_FALSE(manager.stat("non_existent_uuid").has_value());
+
+        const auto result = manager.insert("data");
+        ASSERT_TRUE(result.has_value());
+        uuid = *result;
+
+        const auto stat = manager.stat(uuid);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_EQ(stat->size, 4);
+        ASSERT_EQ(stat->meta.version, 1);
+        ASSERT_FALSE(stat->meta.createdAt.empty());
+        ASSERT_EQ(stat->meta.createdAt, stat->meta.modifiedAt);
+        inserted = stat->meta;
+
+        // Обновление изменяет размер, время изменения и версию, но не время добавления
+        ASSERT_TRUE(manager.update(uuid, "updated data"));
+        const auto updated = manager.stat(uuid);
+        ASSERT_TRUE(updated.has_value());
+        ASSERT_EQ(updated->size, 12);
+        ASSERT_EQ(updated->meta.version, 2);
+        ASSERT_EQ(updated->meta.createdAt, inserted.createdAt);
+        ASSERT_GE(updated->meta.modifiedAt, inserted.createdAt);
+        inserted = updated->meta;
+
+        // Чтение возвращает версию вместе с данными
+        uint64_t version = 0;
+        const auto data = manager.get(uuid, &version);
+        ASSERT_TRUE(data.has_value());
+        ASSERT_EQ(*data, "updated data");
+        ASSERT_EQ(version, 2);
+
+        // Обновление с ожидаемой версией выполняется только при ее совпадении с текущей
+        ASSERT_FALSE(manager.update(uuid, "stale data", 1));
+        ASSERT_EQ(*manager.get(uuid), "updated data");
+        ASSERT_TRUE(manager.update(uuid, "updated data", 2));
+        ASSERT_EQ(manager.stat(uuid)->meta.version, 3);
+        inserted = manager.stat(uuid)->meta;
+    }
+
+    // Метаданные восстанавливаются из журнала
+    {
+        StorageManager manager(dataDir);
+        const auto stat = manager.stat(uuid);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_EQ(stat->meta.version, inserted.version);
+        ASSERT_EQ(stat->meta.createdAt, inserted.createdAt);
+        ASSERT_EQ(stat->meta.modifiedAt, inserted.modifiedAt);
+
+        // и из снапшота после сжатия журнала
+        ASSERT_TRUE(manager.compact());
+    }
+    {
+        StorageManager manager(dataDir);
+        const auto stat = manager.stat(uuid);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_EQ(stat->meta.version, inserted.version);
+        ASSERT_EQ(stat->meta.createdAt, inserted.createdAt);
+        ASSERT_EQ(stat->meta.modifiedAt, inserted.modifiedAt);
+
+        ASSERT_TRUE(manager.remove(uuid));
+        ASSERT_FALSE(manager.stat(uuid).has_value());
+    }
+}
+
+// Тест добавления строки с указанным UUID
+TEST_F(StorageManagerTest, InsertWithUuid)
+{
+    const auto dataDir = createSubdir("insert_uuid_test");
+    const std::string uuid = "0f8fad5b-d9cb-469f-a165-70867728950e";
+    {
+        StorageManager manager(dataDir);
+        const auto result = manager.insert("data", 0, uuid);
+        ASSERT_TRUE(result.has_value());
+        ASSERT_EQ(*result, uuid);
+        ASSERT_EQ(manager.stat(uuid)->meta.version, 1);
+
+        // Существующая строка не перезаписывается
+        ASSERT_FALSE(manager.insert("other", 0, uuid).has_value());
+        ASSERT_EQ(*manager.get(uuid), "data");
+
+        // UUID, который не мог бы выдать octet, отклоняется
+        ASSERT_FALSE(manager.insert("data", 0, "0F8FAD5B-D9CB-469F-A165-70867728950E").has_value());
+        ASSERT_FALSE(manager.insert("data", 0, "uuid").has_value());
+        ASSERT_EQ(manager.getEntriesCount(), 1);
+    }
+
+    // Строка восстанавливается из журнала с тем же UUID
+    {
+        StorageManager manager(dataDir);
+        ASSERT_EQ(*manager.get(uuid), "data");
+    }
+}
+
+// Тест удаления строк по истечении срока хранения
+TEST_F(StorageManagerTest, ExpiringEntries)
+{
+    const auto dataDir = createSubdir("expiry_test");
+    std::string expiring;
+    std::string permanent;
+    std::string expiresAt;
+    {
+        StorageManager manager(dataDir);
+        const auto first = manager.insert("expiring", 2);
+        ASSERT_TRUE(first.has_value());
+        expiring = *first;
+        const auto second = manager.insert("permanent");
+        ASSERT_TRUE(second.has_value());
+        permanent = *second;
+
+        const auto stat = manager.stat(expiring);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_GT(stat->meta.expiresAt, stat->meta.createdAt);
+        expiresAt = stat->meta.expiresAt;
+        ASSERT_TRUE(manager.stat(permanent)->meta.expiresAt.empty());
+
+        // Обновление не изменяет срок хранения
+        ASSERT_TRUE(manager.update(expiring, "updated"));
+        ASSERT_EQ(manager.stat(expiring)->meta.expiresAt, expiresAt);
+    }
+
+    // Срок хранения восстанавливается из журнала
+    {
+        StorageManager manager(dataDir);
+        const auto stat = manager.stat(expiring);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_EQ(stat->meta.expiresAt, expiresAt);
+
+        // и из снапшота после сжатия журнала
+        ASSERT_TRUE(manager.compact());
+    }
+    {
+        StorageManager manager(dataDir);
+        const auto stat = manager.stat(expiring);
+        ASSERT_TRUE(stat.has_value());
+        ASSERT_EQ(stat->meta.expiresAt, expiresAt);
+
+        // Строка удаляется в течение интервала проверки после истечения срока
+        const auto deadline = std::chrono::steady_clock::now() + std::chrono::seconds(5);
+        while (manager.contains(expiring) && std::chrono::steady_clock::now() < deadline) {
+            std::this_thread::sleep_for(std::chrono::milliseconds(100));
+        }
+        ASSERT_FALSE(manager.get(expiring).has_value());
+        ASSERT_TRUE(manager.get(permanent).has_value());
+        ASSERT_EQ(manager.getEntriesCount(), 1);
+    }
+
+    // Удаление строки с истекшим сроком сохраняется в журнале
+    {
+        StorageManager manager(dataDir);
+        ASSERT_FALSE(manager.contains(expiring));
+        ASSERT_TRUE(manager.contains(permanent));
+    }
+}
+
+// Тест постраничного перечисления UUID
+TEST_F(StorageManagerTest, ListUuidsPagination)
+{
+    const auto dataDir = createSubdir("list_test");
+    StorageManager manager(dataDir);
+
+    bool more = true;
+    ASSERT_TRUE(manager.listUuids("", 10, more).empty());
+    ASSERT_FALSE(more);
+
+    std::vector<std::string> expected;
+    for (size_t i = 0; i < 25; i++) {
+        const auto uuid = manager.insert("data_" + std::to_string(i));
+        ASSERT_TRUE(uuid.has_value());
+        expected.push_back(*uuid);
+    }
+    std::sort(expected.begin(), expected.end());
+
+    // Страницы продолжаются с последнего UUID предыдущей страницы
+    std::vector<std::string> listed;
+    std::string after;
+    do {
+        const auto page = manager.listUuids(after, 10, more);
+        ASSERT_LE(page.size(), 10);
+        ASSERT_TRUE(std::is_sorted(page.begin(), page.end()));
+        ASSERT_EQ(more, listed.size() + page.size() < expected.size());
+        listed.insert(listed.end(), page.begin(), page.end());
+        if (!page.empty()) {
+            after = page.back();
+        }
+    } while (more);
+    ASSERT_EQ(listed, expected);
+
+    // Удаленные строки не перечисляются
+    ASSERT_TRUE(manager.remove(expected.front()));
+    const auto page = manager.listUuids("", expected.size(), more);
+    ASSERT_FALSE(more);
+    ASSERT_EQ(page, std::vector<std::string>(expected.begin() + 1, expected.end()));
+}
+
 // Тест для проверки сохранения множества записей
 TEST_F(StorageManagerTest, MultipleEntriesStorage)
 {